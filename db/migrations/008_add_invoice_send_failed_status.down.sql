@@ -0,0 +1,12 @@
+-- Migration 008 Down: Remove send_failed invoice status
+-- Purpose: Rollback email retry status tracking
+
+DROP INDEX IF EXISTS idx_invoices_send_failed;
+
+-- Move any invoices still awaiting retry back to draft
+UPDATE invoices SET status = 'draft' WHERE status = 'send_failed';
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_status;
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_status CHECK (status IN ('draft', 'pending', 'paid', 'failed', 'refunded', 'voided'));
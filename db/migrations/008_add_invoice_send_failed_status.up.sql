@@ -0,0 +1,12 @@
+-- Migration 008: Add send_failed invoice status
+-- Purpose: Track invoices whose email delivery failed and is awaiting retry
+-- Dependencies: Requires invoices table (006)
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_status;
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_status CHECK (status IN ('draft', 'pending', 'paid', 'failed', 'refunded', 'voided', 'send_failed'));
+
+CREATE INDEX idx_invoices_send_failed ON invoices(updated_at) WHERE status = 'send_failed';
+
+COMMENT ON COLUMN invoices.status IS 'draft, pending, paid, failed, refunded, voided, send_failed (email delivery failed, retry pending)';
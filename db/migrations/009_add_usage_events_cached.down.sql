@@ -0,0 +1,4 @@
+-- Migration 009 Down: Remove cached flag from usage events
+-- Purpose: Rollback response cache usage tracking
+
+ALTER TABLE usage_events DROP COLUMN IF EXISTS cached;
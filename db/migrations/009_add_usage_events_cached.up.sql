@@ -0,0 +1,8 @@
+-- Migration 009: Add cached flag to usage events
+-- Purpose: Distinguish requests served from the gateway response cache so they can be billed differently
+-- Dependencies: Requires usage_events table (004)
+
+ALTER TABLE usage_events
+ADD COLUMN cached BOOLEAN DEFAULT false NOT NULL;
+
+COMMENT ON COLUMN usage_events.cached IS 'True when the response was served from the gateway response cache';
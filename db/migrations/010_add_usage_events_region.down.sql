@@ -0,0 +1,6 @@
+-- Migration 010 Down: Remove client region from usage events
+-- Purpose: Rollback region enrichment
+
+DROP INDEX IF EXISTS idx_usage_org_region_time;
+
+ALTER TABLE usage_events DROP COLUMN IF EXISTS region;
@@ -0,0 +1,10 @@
+-- Migration 010: Add client region to usage events
+-- Purpose: Usage analytics by client region (country code from CDN headers)
+-- Dependencies: Requires usage_events table (004)
+
+ALTER TABLE usage_events
+ADD COLUMN region TEXT DEFAULT 'unknown' NOT NULL;
+
+CREATE INDEX idx_usage_org_region_time ON usage_events(organization_id, region, time DESC);
+
+COMMENT ON COLUMN usage_events.region IS 'Client country code (e.g. US, DE) from CDN headers, or unknown';
@@ -0,0 +1,4 @@
+-- Migration 011 Down: Drop api_key_deletions audit table
+-- Purpose: Rollback revoked key purge auditing
+
+DROP TABLE IF EXISTS api_key_deletions;
@@ -0,0 +1,22 @@
+-- Migration 011: Create api_key_deletions audit table
+-- Purpose: Keep an audit record of revoked API keys purged after the retention period
+-- Dependencies: Requires organizations table (001)
+
+CREATE TABLE IF NOT EXISTS api_key_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    api_key_id UUID NOT NULL,               -- ID of the deleted key (row no longer exists)
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    key_prefix VARCHAR(12) NOT NULL,
+    name VARCHAR(100),
+    revoked_at TIMESTAMPTZ NOT NULL,
+    revoked_reason TEXT,
+    key_created_at TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_by VARCHAR(255) NOT NULL
+);
+
+CREATE INDEX idx_api_key_deletions_org ON api_key_deletions(organization_id, deleted_at DESC);
+CREATE INDEX idx_api_key_deletions_key_id ON api_key_deletions(api_key_id);
+
+COMMENT ON TABLE api_key_deletions IS 'Audit log of revoked API keys hard-deleted by keygen purge';
+COMMENT ON COLUMN api_key_deletions.deleted_by IS 'User or job that ran the purge';
@@ -0,0 +1,5 @@
+-- Migration 012 Down: Remove API key revocation notifications
+-- Purpose: Rollback immediate gateway cache eviction
+
+DROP TRIGGER IF EXISTS api_key_revoked_notify ON api_keys;
+DROP FUNCTION IF EXISTS notify_api_key_revoked();
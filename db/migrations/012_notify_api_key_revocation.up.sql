@@ -0,0 +1,29 @@
+-- Migration 012: Notify on API key revocation
+-- Purpose: Let the gateway evict revoked keys from its in-memory cache immediately
+-- Dependencies: Requires api_keys table (002)
+
+CREATE OR REPLACE FUNCTION notify_api_key_revoked()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('api_key_revoked', OLD.key_hash);
+        RETURN OLD;
+    END IF;
+
+    -- Revoked, deactivated, or expiry moved into the past
+    IF (NEW.revoked_at IS NOT NULL AND OLD.revoked_at IS NULL)
+        OR (NEW.is_active = false AND OLD.is_active = true)
+        OR (NEW.expires_at IS DISTINCT FROM OLD.expires_at AND NEW.expires_at <= NOW()) THEN
+        PERFORM pg_notify('api_key_revoked', NEW.key_hash);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER api_key_revoked_notify
+    AFTER UPDATE OR DELETE ON api_keys
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_api_key_revoked();
+
+COMMENT ON FUNCTION notify_api_key_revoked() IS 'Publishes the key_hash of revoked API keys on the api_key_revoked channel';
@@ -0,0 +1,5 @@
+-- Migration 013 Down: Drop usage_alerts table
+-- Purpose: Rollback usage threshold alert tracking
+
+DROP INDEX IF EXISTS idx_usage_alerts_org_month;
+DROP TABLE IF EXISTS usage_alerts;
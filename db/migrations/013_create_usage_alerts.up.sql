@@ -0,0 +1,18 @@
+-- Migration 013: Create usage_alerts table
+-- Purpose: Track which plan usage thresholds have fired per organization per month
+-- Dependencies: None (organization_id matches organization_subscriptions)
+
+CREATE TABLE IF NOT EXISTS usage_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id VARCHAR(255) NOT NULL,
+    billing_month DATE NOT NULL,            -- First day of the month
+    threshold_percent INTEGER NOT NULL,     -- e.g. 80, 100, 120
+    used_units BIGINT NOT NULL,             -- Billable units when the alert fired
+    fired_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT unique_usage_alert UNIQUE (organization_id, billing_month, threshold_percent)
+);
+
+CREATE INDEX idx_usage_alerts_org_month ON usage_alerts(organization_id, billing_month);
+
+COMMENT ON TABLE usage_alerts IS 'Plan usage threshold notifications already sent (one per org/month/threshold)';
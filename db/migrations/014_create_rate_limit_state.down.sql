@@ -0,0 +1,5 @@
+-- Migration 014 Down: Drop rate_limit_state table
+-- Purpose: Rollback hard-cap enforcement signal
+
+DROP INDEX IF EXISTS idx_rate_limit_state_capped_until;
+DROP TABLE IF EXISTS rate_limit_state;
@@ -0,0 +1,15 @@
+-- Migration 014: Create rate_limit_state table
+-- Purpose: Signal from billing engine to gateway that an org exhausted its plan's hard cap
+-- Dependencies: None (organization_id matches api_keys.organization_id)
+
+CREATE TABLE IF NOT EXISTS rate_limit_state (
+    organization_id VARCHAR(255) PRIMARY KEY,
+    capped_until TIMESTAMP WITH TIME ZONE NOT NULL,  -- Start of the next billing period
+    used_units BIGINT NOT NULL,                      -- Month-to-date billable units when capped
+    max_units BIGINT NOT NULL,                       -- Plan hard cap
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_rate_limit_state_capped_until ON rate_limit_state(capped_until);
+
+COMMENT ON TABLE rate_limit_state IS 'Orgs over their hard usage cap; gateway rejects requests until capped_until';
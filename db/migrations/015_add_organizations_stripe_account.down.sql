@@ -0,0 +1,4 @@
+-- Migration 015 Down: Drop organizations.stripe_account_id
+-- Purpose: Rollback Stripe Connect account routing
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS stripe_account_id;
@@ -0,0 +1,7 @@
+-- Migration 015: Add stripe_account_id to organizations
+-- Purpose: Bill organizations on their own Stripe Connect account (Stripe-Account header)
+-- Dependencies: organizations table
+
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS stripe_account_id VARCHAR(255);
+
+COMMENT ON COLUMN organizations.stripe_account_id IS 'Connected Stripe account (acct_...); NULL bills on the platform account';
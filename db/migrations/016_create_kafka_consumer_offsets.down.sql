@@ -0,0 +1,4 @@
+-- Migration 016 Down: Drop kafka_consumer_offsets table
+-- Purpose: Rollback idempotent offset tracking
+
+DROP TABLE IF EXISTS kafka_consumer_offsets;
@@ -0,0 +1,13 @@
+-- Migration 016: Create kafka_consumer_offsets table
+-- Purpose: Track the highest Kafka offset written to usage_events per partition (idempotent replay)
+-- Dependencies: 004_create_usage_events
+
+CREATE TABLE IF NOT EXISTS kafka_consumer_offsets (
+    topic VARCHAR(255) NOT NULL,
+    partition_id INTEGER NOT NULL,
+    last_offset BIGINT NOT NULL,  -- Highest offset whose events are in usage_events
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (topic, partition_id)
+);
+
+COMMENT ON TABLE kafka_consumer_offsets IS 'Usage processor offsets, updated in the same transaction as usage_events writes';
@@ -0,0 +1,5 @@
+-- Migration 017 Down: Drop unique invoice per organization and billing period
+-- Purpose: Rollback invoice generation idempotency
+-- Duplicates voided by the up migration stay voided (metadata.voided_duplicate_of names the kept invoice)
+
+DROP INDEX IF EXISTS idx_invoices_org_period_unique;
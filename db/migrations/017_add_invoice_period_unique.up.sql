@@ -0,0 +1,62 @@
+-- Migration 017: Add unique invoice per organization and billing period
+-- Purpose: Make monthly invoice generation idempotent (cron overlap, manual re-runs)
+-- Dependencies: 006_create_invoices
+
+-- ======================================================================
+-- 1. RESOLVE EXISTING DUPLICATES
+-- ======================================================================
+-- Overlapping runs before this migration may have invoiced a period more than once. Per
+-- organization and period, keep the paid or refunded invoice if there is one, else the newest,
+-- and void the others that were never paid (the voided copy records which invoice it duplicated)
+WITH ranked AS (
+    SELECT
+        id,
+        status,
+        FIRST_VALUE(id) OVER w AS kept_id,
+        ROW_NUMBER() OVER w AS rank
+    FROM invoices
+    WHERE status <> 'voided'
+    WINDOW w AS (
+        PARTITION BY organization_id, billing_period_start
+        ORDER BY status IN ('paid', 'refunded') DESC, created_at DESC, id
+    )
+)
+UPDATE invoices i
+SET status = 'voided',
+    metadata = COALESCE(i.metadata, '{}'::jsonb) || jsonb_build_object('voided_duplicate_of', ranked.kept_id),
+    updated_at = NOW()
+FROM ranked
+WHERE i.id = ranked.id
+    AND ranked.rank > 1
+    AND ranked.status IN ('draft', 'pending', 'failed', 'send_failed');
+
+-- Duplicates that were both paid need a refund decision, so they are left for an operator
+-- List them with:
+--   SELECT organization_id, billing_period_start, array_agg(id ORDER BY created_at) AS invoice_ids
+--   FROM invoices WHERE status <> 'voided'
+--   GROUP BY 1, 2 HAVING COUNT(*) > 1;
+DO $$
+DECLARE
+    duplicate_periods INTEGER;
+BEGIN
+    SELECT COUNT(*) INTO duplicate_periods
+    FROM (
+        SELECT 1
+        FROM invoices
+        WHERE status <> 'voided'
+        GROUP BY organization_id, billing_period_start
+        HAVING COUNT(*) > 1
+    ) duplicates;
+
+    IF duplicate_periods > 0 THEN
+        RAISE EXCEPTION 'Migration 017: % organization billing period(s) have more than one paid invoice; refund and void the extras before re-running (see the query in this migration)', duplicate_periods;
+    END IF;
+END $$;
+
+-- ======================================================================
+-- 2. UNIQUE INDEX
+-- ======================================================================
+-- Voided invoices don't count, so a period can be re-invoiced after a void
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_org_period_unique
+    ON invoices(organization_id, billing_period_start)
+    WHERE status <> 'voided';
@@ -0,0 +1,4 @@
+-- Migration 018 Down: Drop invoice_number_counters table
+-- Purpose: Rollback per-month invoice number counters
+
+DROP TABLE IF EXISTS invoice_number_counters;
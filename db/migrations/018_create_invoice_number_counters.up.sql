@@ -0,0 +1,24 @@
+-- Migration 018: Create invoice_number_counters table
+-- Purpose: Race-free, gap-free per-month invoice number sequences (INV-YYYY-MM-NNNNN)
+-- Dependencies: 006_create_invoices
+
+CREATE TABLE IF NOT EXISTS invoice_number_counters (
+    year INTEGER NOT NULL,
+    month INTEGER NOT NULL CHECK (month BETWEEN 1 AND 12),
+    next_seq INTEGER NOT NULL DEFAULT 1 CHECK (next_seq >= 1),  -- Sequence handed to the next invoice
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (year, month)
+);
+
+-- Continue the sequences of invoices created before this migration
+INSERT INTO invoice_number_counters (year, month, next_seq)
+SELECT
+    EXTRACT(YEAR FROM billing_period_start)::INTEGER,
+    EXTRACT(MONTH FROM billing_period_start)::INTEGER,
+    MAX(CAST(SUBSTRING(invoice_number FROM 'INV-[0-9]{4}-[0-9]{2}-([0-9]{5})') AS INTEGER)) + 1
+FROM invoices
+WHERE invoice_number ~ '^INV-[0-9]{4}-[0-9]{2}-[0-9]{5}$'
+GROUP BY 1, 2
+ON CONFLICT (year, month) DO NOTHING;
+
+COMMENT ON TABLE invoice_number_counters IS 'Per-month invoice sequence, incremented in the invoice insert transaction';
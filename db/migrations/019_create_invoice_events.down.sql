@@ -0,0 +1,6 @@
+-- Migration 019 Down: Drop invoice_events audit table
+-- Purpose: Rollback invoice audit trail and Stripe void sync
+
+DROP INDEX IF EXISTS idx_invoices_stripe_void_pending;
+ALTER TABLE invoices DROP COLUMN IF EXISTS stripe_voided_at;
+DROP TABLE IF EXISTS invoice_events;
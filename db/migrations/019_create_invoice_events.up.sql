@@ -0,0 +1,24 @@
+-- Migration 019: Create invoice_events audit table
+-- Purpose: Record who changed an invoice and why (voids), and track Stripe void sync
+-- Dependencies: 006_create_invoices
+
+CREATE TABLE IF NOT EXISTS invoice_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,  -- voided
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    actor_user_id VARCHAR(255),       -- NULL for system actions
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_invoice_events_invoice ON invoice_events(invoice_id, created_at DESC);
+
+-- Voids made without Stripe access (dashboard) are pushed to Stripe by the billing engine
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS stripe_voided_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_invoices_stripe_void_pending ON invoices(updated_at)
+    WHERE status = 'voided' AND stripe_invoice_id IS NOT NULL AND stripe_voided_at IS NULL;
+
+COMMENT ON TABLE invoice_events IS 'Audit trail of manual invoice actions (actor, reason, timestamp)';
@@ -0,0 +1,15 @@
+-- Migration 020 Down: Drop invoice_refunds and refunded amount tracking
+-- Purpose: Rollback partial refund support
+
+DROP TABLE IF EXISTS invoice_refunds;
+
+-- Partially refunded invoices were paid
+UPDATE invoices SET status = 'paid' WHERE status = 'partially_refunded';
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_status;
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_status CHECK (status IN ('draft', 'pending', 'paid', 'failed', 'refunded', 'voided', 'send_failed'));
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_refunded_amount;
+ALTER TABLE invoices DROP COLUMN IF EXISTS refunded_amount_cents;
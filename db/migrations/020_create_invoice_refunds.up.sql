@@ -0,0 +1,40 @@
+-- Migration 020: Create invoice_refunds and track refunded amounts
+-- Purpose: Support full and partial refunds issued from the dashboard or billing engine
+-- Dependencies: 006_create_invoices, 008_add_invoice_send_failed_status, 019_create_invoice_events
+
+-- Amount refunded (or reserved by a pending refund); refunds can never exceed the total
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS refunded_amount_cents BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_refunded_amount CHECK (refunded_amount_cents >= 0 AND refunded_amount_cents <= total_cents);
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_status;
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_status CHECK (status IN ('draft', 'pending', 'paid', 'failed', 'refunded', 'voided', 'send_failed', 'partially_refunded'));
+
+COMMENT ON COLUMN invoices.status IS 'draft, pending, paid, failed, refunded, partially_refunded, voided, send_failed (email delivery failed, retry pending)';
+
+-- Fully refunded invoices from before this migration
+UPDATE invoices SET refunded_amount_cents = total_cents WHERE status = 'refunded';
+
+CREATE TABLE IF NOT EXISTS invoice_refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    amount_cents BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    actor_user_id VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, processing, succeeded, failed
+    stripe_refund_id VARCHAR(255),
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    processed_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT valid_refund_amount CHECK (amount_cents > 0),
+    CONSTRAINT valid_refund_status CHECK (status IN ('pending', 'processing', 'succeeded', 'failed'))
+);
+
+CREATE INDEX idx_invoice_refunds_invoice ON invoice_refunds(invoice_id, created_at DESC);
+CREATE INDEX idx_invoice_refunds_pending ON invoice_refunds(created_at) WHERE status = 'pending';
+
+COMMENT ON TABLE invoice_refunds IS 'Refunds requested against paid invoices; pending rows are issued on Stripe by the billing engine';
@@ -0,0 +1,21 @@
+-- Migration 021 Down: Restore the 005 pricing_plans seed values
+-- Purpose: Rollback the plan catalog upsert (plans stay in place; subscriptions reference them)
+
+UPDATE pricing_plans AS pp SET
+    description = v.description,
+    overage_rate_cents = v.overage_rate_cents,
+    features = v.features,
+    updated_at = NOW()
+FROM (VALUES
+    ('free', 'Perfect for testing and small projects', 0,
+     ARRAY['100K requests/month', 'Basic API features', 'Community support']),
+    ('starter', 'Great for growing applications', 500,
+     ARRAY['500K requests/month', '$5 per 1M additional', 'Email support', 'Basic analytics', '99.9% uptime SLA']),
+    ('growth', 'For scaling businesses', 400,
+     ARRAY['2M requests/month', '$4 per 1M additional', 'Priority support', 'Advanced analytics', 'Custom rate limits', '99.95% uptime SLA']),
+    ('business', 'For high-traffic applications', 300,
+     ARRAY['10M requests/month', '$3 per 1M additional', '24/7 phone support', 'Dedicated account manager', 'Custom integrations', 'SSO support', '99.99% uptime SLA']),
+    ('enterprise', 'For mission-critical systems', 200,
+     ARRAY['50M requests/month', '$2 per 1M additional', 'White-glove support', 'Custom SLA', 'On-premise deployment option', 'Advanced security features', 'Volume discounts', 'Custom contracts'])
+) AS v(id, description, overage_rate_cents, features)
+WHERE pp.id = v.id;
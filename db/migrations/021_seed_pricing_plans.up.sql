@@ -0,0 +1,38 @@
+-- Migration 021: Seed pricing_plans with the billing engine's plan catalog
+-- Purpose: Make pricing_plans the source of truth for the billing engine's PlanRepository
+-- Dependencies: 005_create_pricing_plans
+
+-- Upsert the five plans from pricing.PredefinedPlans so behavior is unchanged
+-- out of the box. overage_rate_cents is cents per 1000 units; 005 seeded the
+-- per-1M rates (e.g. 500 for $5 per 1M), which billed overage at 100x.
+INSERT INTO pricing_plans (id, name, description, base_price_cents, included_units, overage_rate_cents, max_units, features, is_active, display_order) VALUES
+    ('free', 'Free', 'For hobby projects and testing', 0, 100000, 0, 100000,
+     ARRAY['100K requests/month', 'Basic rate limiting', 'Community support'],
+     true, 1),
+
+    ('starter', 'Starter', 'For small applications', 2900, 500000, 5, NULL,
+     ARRAY['500K requests/month', '$5 per 1M additional requests', 'Advanced rate limiting', 'Email support', 'Usage analytics'],
+     true, 2),
+
+    ('growth', 'Growth', 'For growing businesses', 9900, 2000000, 4, NULL,
+     ARRAY['2M requests/month', '$4 per 1M additional requests', 'Priority support', 'Advanced analytics', 'Custom rate limits', 'SLA: 99.9% uptime'],
+     true, 3),
+
+    ('business', 'Business', 'For established companies', 29900, 10000000, 3, NULL,
+     ARRAY['10M requests/month', '$3 per 1M additional requests', '24/7 priority support', 'Real-time analytics', 'Custom integrations', 'SLA: 99.95% uptime', 'Dedicated account manager'],
+     true, 4),
+
+    ('enterprise', 'Enterprise', 'Custom solutions for large organizations', 99900, 50000000, 2, NULL,
+     ARRAY['50M+ requests/month', '$2 per 1M additional requests', 'White-glove support', 'Custom SLA', 'On-premise deployment option', 'Advanced security features', 'Custom contract terms'],
+     true, 5)
+ON CONFLICT (id) DO UPDATE SET
+    name = EXCLUDED.name,
+    description = EXCLUDED.description,
+    base_price_cents = EXCLUDED.base_price_cents,
+    included_units = EXCLUDED.included_units,
+    overage_rate_cents = EXCLUDED.overage_rate_cents,
+    max_units = EXCLUDED.max_units,
+    features = EXCLUDED.features,
+    is_active = EXCLUDED.is_active,
+    display_order = EXCLUDED.display_order,
+    updated_at = NOW();
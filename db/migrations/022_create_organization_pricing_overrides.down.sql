@@ -0,0 +1,4 @@
+-- Migration 022 Down: Drop organization_pricing_overrides table
+-- Purpose: Rollback per-organization pricing overrides
+
+DROP TABLE IF EXISTS organization_pricing_overrides;
@@ -0,0 +1,21 @@
+-- Migration 022: Create organization_pricing_overrides table
+-- Purpose: Per-organization negotiated pricing layered over the subscribed plan's tier
+-- Dependencies: 005_create_pricing_plans (organization_id matches organization_subscriptions)
+
+CREATE TABLE IF NOT EXISTS organization_pricing_overrides (
+    organization_id VARCHAR(255) PRIMARY KEY,
+    base_price_cents BIGINT,    -- NULL = use the plan's base price
+    included_units BIGINT,      -- NULL = use the plan's included units
+    overage_rate_cents BIGINT,  -- Per 1000 units; NULL = use the plan's rate
+    notes TEXT,                 -- e.g. contract reference
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT valid_override_values CHECK (
+        COALESCE(base_price_cents, 0) >= 0 AND
+        COALESCE(included_units, 0) >= 0 AND
+        COALESCE(overage_rate_cents, 0) >= 0
+    )
+);
+
+COMMENT ON TABLE organization_pricing_overrides IS 'Negotiated pricing per org; non-NULL columns replace the plan tier values at billing time';
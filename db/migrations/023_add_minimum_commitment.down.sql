@@ -0,0 +1,23 @@
+-- Migration 023 Down: Remove minimum commitment true-ups
+-- Purpose: Rollback minimum charge support
+
+-- True-up line items become generic charges
+UPDATE invoice_line_items SET item_type = 'other' WHERE item_type = 'true_up';
+
+ALTER TABLE invoice_line_items DROP CONSTRAINT IF EXISTS valid_item_type;
+
+ALTER TABLE invoice_line_items
+ADD CONSTRAINT valid_item_type CHECK (item_type IN ('base_plan', 'overage', 'addon', 'discount', 'credit', 'tax', 'other'));
+
+ALTER TABLE billing_records DROP CONSTRAINT IF EXISTS valid_true_up_charge;
+ALTER TABLE billing_records DROP COLUMN IF EXISTS true_up_charge_cents;
+
+ALTER TABLE organization_pricing_overrides DROP CONSTRAINT IF EXISTS valid_override_values;
+ALTER TABLE organization_pricing_overrides DROP COLUMN IF EXISTS minimum_charge_cents;
+
+ALTER TABLE organization_pricing_overrides
+ADD CONSTRAINT valid_override_values CHECK (
+    COALESCE(base_price_cents, 0) >= 0 AND
+    COALESCE(included_units, 0) >= 0 AND
+    COALESCE(overage_rate_cents, 0) >= 0
+);
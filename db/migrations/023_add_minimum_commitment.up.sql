@@ -0,0 +1,30 @@
+-- Migration 023: Add minimum commitment true-ups
+-- Purpose: Bill contracts with a committed monthly spend at least their minimum
+-- Dependencies: 005_create_pricing_plans, 006_create_invoices, 022_create_organization_pricing_overrides
+
+-- Committed spend per billing period; NULL = use the plan (no minimum)
+ALTER TABLE organization_pricing_overrides ADD COLUMN IF NOT EXISTS minimum_charge_cents BIGINT;
+
+ALTER TABLE organization_pricing_overrides DROP CONSTRAINT IF EXISTS valid_override_values;
+
+ALTER TABLE organization_pricing_overrides
+ADD CONSTRAINT valid_override_values CHECK (
+    COALESCE(base_price_cents, 0) >= 0 AND
+    COALESCE(included_units, 0) >= 0 AND
+    COALESCE(overage_rate_cents, 0) >= 0 AND
+    COALESCE(minimum_charge_cents, 0) >= 0
+);
+
+-- Shortfall between base + overage and the minimum; included in subtotal_cents
+ALTER TABLE billing_records ADD COLUMN IF NOT EXISTS true_up_charge_cents INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE billing_records
+ADD CONSTRAINT valid_true_up_charge CHECK (true_up_charge_cents >= 0);
+
+-- Invoices show the true-up as its own line item
+ALTER TABLE invoice_line_items DROP CONSTRAINT IF EXISTS valid_item_type;
+
+ALTER TABLE invoice_line_items
+ADD CONSTRAINT valid_item_type CHECK (item_type IN ('base_plan', 'overage', 'true_up', 'addon', 'discount', 'credit', 'tax', 'other'));
+
+COMMENT ON COLUMN billing_records.true_up_charge_cents IS 'Minimum commitment shortfall billed on top of base and overage charges';
@@ -0,0 +1,10 @@
+-- Migration 024 Down: Drop coupons and organization_coupons tables
+-- Purpose: Rollback coupon discounts (existing invoice discounts are kept)
+
+DROP TRIGGER IF EXISTS invoice_voided_release_coupon ON invoices;
+DROP FUNCTION IF EXISTS release_coupon_redemption();
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS coupon_id;
+
+DROP TABLE IF EXISTS organization_coupons;
+DROP TABLE IF EXISTS coupons;
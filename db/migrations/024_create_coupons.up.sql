@@ -0,0 +1,63 @@
+-- Migration 024: Create coupons and organization_coupons tables
+-- Purpose: Percentage and fixed-amount discounts applied during invoice generation
+-- Dependencies: 006_create_invoices
+
+CREATE TABLE IF NOT EXISTS coupons (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    discount_type VARCHAR(20) NOT NULL,  -- percent, fixed
+    percent_off NUMERIC(5, 2),           -- percent coupons, e.g. 15.00
+    amount_off_cents BIGINT,             -- fixed coupons
+    expires_at TIMESTAMP WITH TIME ZONE, -- NULL = never expires
+    max_redemptions INTEGER,             -- invoices the coupon may discount; NULL = unlimited
+    times_redeemed INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT valid_discount_type CHECK (
+        (discount_type = 'percent' AND percent_off > 0 AND percent_off <= 100 AND amount_off_cents IS NULL) OR
+        (discount_type = 'fixed' AND amount_off_cents > 0 AND percent_off IS NULL)
+    ),
+    CONSTRAINT valid_redemptions CHECK (
+        times_redeemed >= 0 AND
+        (max_redemptions IS NULL OR (max_redemptions > 0 AND times_redeemed <= max_redemptions))
+    )
+);
+
+-- Coupons assigned to an organization; the most recently assigned redeemable one applies
+CREATE TABLE IF NOT EXISTS organization_coupons (
+    organization_id VARCHAR(255) NOT NULL,
+    coupon_id UUID NOT NULL REFERENCES coupons(id) ON DELETE CASCADE,
+    assigned_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (organization_id, coupon_id)
+);
+
+CREATE INDEX idx_organization_coupons_org ON organization_coupons(organization_id, assigned_at DESC);
+
+-- Coupon applied to each invoice
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS coupon_id UUID REFERENCES coupons(id);
+
+-- Voiding an invoice returns its coupon redemption (from the billing engine or the dashboard)
+CREATE OR REPLACE FUNCTION release_coupon_redemption()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status = 'voided' AND OLD.status <> 'voided' AND NEW.coupon_id IS NOT NULL THEN
+        UPDATE coupons
+        SET times_redeemed = GREATEST(times_redeemed - 1, 0), updated_at = NOW()
+        WHERE id = NEW.coupon_id;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER invoice_voided_release_coupon
+    AFTER UPDATE OF status ON invoices
+    FOR EACH ROW
+    EXECUTE FUNCTION release_coupon_redemption();
+
+COMMENT ON TABLE coupons IS 'Discounts applied to invoice subtotals; percent coupons round to the nearest cent';
+COMMENT ON TABLE organization_coupons IS 'Coupon assignments per organization';
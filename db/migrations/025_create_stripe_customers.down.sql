@@ -0,0 +1,4 @@
+-- Migration 025 Down: Drop stripe_customers table
+-- Purpose: Rollback the Stripe customer cache (customers are found by search again)
+
+DROP TABLE IF EXISTS stripe_customers;
@@ -0,0 +1,22 @@
+-- Migration 025: Create stripe_customers table
+-- Purpose: Cache organization → Stripe customer IDs so invoicing skips the rate-limited customer search
+-- Dependencies: 001_create_organizations, 015_add_organizations_stripe_account
+
+CREATE TABLE IF NOT EXISTS stripe_customers (
+    organization_id VARCHAR(255) NOT NULL,
+    stripe_account_id VARCHAR(255) NOT NULL DEFAULT '',  -- Connected account ('' = platform account)
+    stripe_customer_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (organization_id, stripe_account_id)
+);
+
+-- Customers already recorded on organizations live on the platform account
+INSERT INTO stripe_customers (organization_id, stripe_account_id, stripe_customer_id)
+SELECT id::text, '', stripe_customer_id
+FROM organizations
+WHERE stripe_customer_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+COMMENT ON TABLE stripe_customers IS 'Stripe customer per organization and connected account; replaced if the customer is deleted in Stripe';
@@ -0,0 +1,6 @@
+-- Migration 026 Down: Remove per-organization payment terms
+-- Purpose: Rollback to the global payment terms for every organization
+
+ALTER TABLE organizations DROP CONSTRAINT IF EXISTS valid_payment_terms_days;
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS payment_terms_days;
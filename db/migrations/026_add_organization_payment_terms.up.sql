@@ -0,0 +1,13 @@
+-- Migration 026: Add per-organization payment terms
+-- Purpose: Let enterprise contracts set Net 45 / Net 60 instead of the global PAYMENT_TERMS_DAYS
+-- Dependencies: 001_create_organizations
+
+-- Days until an invoice is due; NULL = use PAYMENT_TERMS_DAYS
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS payment_terms_days INTEGER;
+
+ALTER TABLE organizations
+ADD CONSTRAINT valid_payment_terms_days CHECK (
+    payment_terms_days IS NULL OR payment_terms_days BETWEEN 0 AND 120
+);
+
+COMMENT ON COLUMN organizations.payment_terms_days IS 'Invoice payment terms in days (e.g., 45 for Net 45), NULL for the billing engine default';
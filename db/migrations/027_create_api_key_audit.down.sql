@@ -0,0 +1,4 @@
+-- Migration 027 Down: Drop api_key_audit table
+-- Purpose: Rollback the API key audit log (its history is lost)
+
+DROP TABLE IF EXISTS api_key_audit;
@@ -0,0 +1,21 @@
+-- Migration 027: Create api_key_audit table
+-- Purpose: Append-only history of API key creation, revocation and rotation for compliance reviews
+-- Dependencies: 001_create_organizations, 002_create_api_keys
+
+CREATE TABLE IF NOT EXISTS api_key_audit (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    key_id UUID NOT NULL,                   -- No foreign key: history outlives a deleted key
+    action VARCHAR(20) NOT NULL,            -- created, revoked, rotated
+    actor_user_id VARCHAR(255) NOT NULL,    -- User ID from the dashboard JWT
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT valid_api_key_audit_action CHECK (action IN ('created', 'revoked', 'rotated'))
+);
+
+CREATE INDEX idx_api_key_audit_key ON api_key_audit(key_id, created_at);
+CREATE INDEX idx_api_key_audit_org ON api_key_audit(organization_id, created_at DESC);
+
+COMMENT ON TABLE api_key_audit IS 'API key lifecycle events; rows are only ever inserted';
+COMMENT ON COLUMN api_key_audit.ip_address IS 'Client IP of the request, after X-Forwarded-For/X-Real-IP resolution';
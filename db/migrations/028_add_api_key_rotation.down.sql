@@ -0,0 +1,9 @@
+-- Migration 028 Down: Remove API key rotation grace period
+-- Purpose: Rollback rotation grace; keys still rotating are revoked first
+
+UPDATE api_keys SET status = 'revoked', revoked_at = NOW() WHERE status = 'rotating';
+
+DROP INDEX IF EXISTS idx_api_keys_rotation_expires_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS rotation_expires_at;
+
+COMMENT ON COLUMN api_keys.status IS 'API key status: active, revoked, expired';
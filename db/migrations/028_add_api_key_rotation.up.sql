@@ -0,0 +1,12 @@
+-- Migration 028: Add API key rotation grace period
+-- Purpose: Keep a rotated key valid until its replacement is deployed, then revoke it
+-- Dependencies: 002_create_api_keys, 007_create_dashboard_tables
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS rotation_expires_at TIMESTAMPTZ;
+
+-- The dashboard's cleanup job scans for rotations whose grace period has ended
+CREATE INDEX IF NOT EXISTS idx_api_keys_rotation_expires_at ON api_keys(rotation_expires_at)
+    WHERE status = 'rotating';
+
+COMMENT ON COLUMN api_keys.status IS 'API key status: active, rotating, revoked, expired';
+COMMENT ON COLUMN api_keys.rotation_expires_at IS 'End of the grace period for a rotating key; the gateway rejects it from then on';
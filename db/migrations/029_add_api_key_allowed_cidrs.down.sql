@@ -0,0 +1,28 @@
+-- Migration 029 Down: Remove per-key IP allowlists
+-- Purpose: Rollback allowlists (restricted keys work from any IP again)
+
+CREATE OR REPLACE FUNCTION notify_api_key_revoked()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('api_key_revoked', OLD.key_hash);
+        RETURN OLD;
+    END IF;
+
+    -- Revoked, deactivated, or expiry moved into the past
+    IF (NEW.revoked_at IS NOT NULL AND OLD.revoked_at IS NULL)
+        OR (NEW.is_active = false AND OLD.is_active = true)
+        OR (NEW.expires_at IS DISTINCT FROM OLD.expires_at AND NEW.expires_at <= NOW()) THEN
+        PERFORM pg_notify('api_key_revoked', NEW.key_hash);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DELETE FROM api_key_audit WHERE action = 'allowlist_updated';
+ALTER TABLE api_key_audit DROP CONSTRAINT IF EXISTS valid_api_key_audit_action;
+ALTER TABLE api_key_audit ADD CONSTRAINT valid_api_key_audit_action
+    CHECK (action IN ('created', 'revoked', 'rotated'));
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS allowed_cidrs;
@@ -0,0 +1,34 @@
+-- Migration 029: Add per-key IP allowlists
+-- Purpose: Restrict an API key to source IP ranges; the gateway rejects others with 403
+-- Dependencies: 002_create_api_keys, 012_notify_api_key_revocation, 027_create_api_key_audit
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS allowed_cidrs TEXT[] NOT NULL DEFAULT '{}';
+
+-- Allowlist changes are audited alongside the key lifecycle
+ALTER TABLE api_key_audit DROP CONSTRAINT IF EXISTS valid_api_key_audit_action;
+ALTER TABLE api_key_audit ADD CONSTRAINT valid_api_key_audit_action
+    CHECK (action IN ('created', 'revoked', 'rotated', 'allowlist_updated'));
+
+-- Also evict keys from the gateway cache when their allowlist changes, so a narrowed
+-- list applies at once instead of after the next cache refresh
+CREATE OR REPLACE FUNCTION notify_api_key_revoked()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('api_key_revoked', OLD.key_hash);
+        RETURN OLD;
+    END IF;
+
+    -- Revoked, deactivated, expiry moved into the past, or allowlist changed
+    IF (NEW.revoked_at IS NOT NULL AND OLD.revoked_at IS NULL)
+        OR (NEW.is_active = false AND OLD.is_active = true)
+        OR (NEW.expires_at IS DISTINCT FROM OLD.expires_at AND NEW.expires_at <= NOW())
+        OR (NEW.allowed_cidrs IS DISTINCT FROM OLD.allowed_cidrs) THEN
+        PERFORM pg_notify('api_key_revoked', NEW.key_hash);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON COLUMN api_keys.allowed_cidrs IS 'Source IP ranges (IPv4/IPv6 CIDR) the key may be used from; empty allows any IP';
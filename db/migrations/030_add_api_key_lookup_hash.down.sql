@@ -0,0 +1,5 @@
+-- Migration 030 Down: Remove the API key lookup hash
+-- Purpose: Rollback indexed lookup; validation falls back to the prefix scan
+
+DROP INDEX IF EXISTS idx_api_keys_key_lookup;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS key_lookup;
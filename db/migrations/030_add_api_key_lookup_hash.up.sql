@@ -0,0 +1,12 @@
+-- Migration 030: Add an indexed lookup hash for dashboard API key validation
+-- Purpose: Find a key with one indexed SELECT instead of bcrypt-comparing every key sharing its prefix
+-- Dependencies: 007_create_dashboard_tables
+
+-- HMAC-SHA256 of the full key under the dashboard's API_KEY_PEPPER, hex encoded.
+-- Existing keys start out NULL and are filled in the first time they validate.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_lookup VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_lookup ON api_keys(key_lookup)
+    WHERE key_lookup IS NOT NULL;
+
+COMMENT ON COLUMN api_keys.key_lookup IS 'HMAC-SHA256 of the full API key under a server pepper, for exact lookup; bcrypt key_hash still confirms the match';
@@ -0,0 +1,11 @@
+-- Migration 031 Down: Remove organization suspension
+-- Purpose: Rollback organization status; suspended organizations become active again
+
+DROP TRIGGER IF EXISTS organization_status_notify ON organizations;
+DROP FUNCTION IF EXISTS notify_organization_status_changed();
+
+DROP INDEX IF EXISTS idx_organizations_status;
+ALTER TABLE organizations DROP CONSTRAINT IF EXISTS valid_organization_status;
+ALTER TABLE organizations DROP COLUMN IF EXISTS suspended_at;
+ALTER TABLE organizations DROP COLUMN IF EXISTS suspension_reason;
+ALTER TABLE organizations DROP COLUMN IF EXISTS status;
@@ -0,0 +1,35 @@
+-- Migration 031: Add organization suspension
+-- Purpose: Suspend organizations (e.g. for non-payment) so the gateway rejects their traffic with 402
+-- Dependencies: 001_create_organizations, 002_create_api_keys, 012_notify_api_key_revocation
+
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS suspension_reason TEXT;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMPTZ;
+
+ALTER TABLE organizations ADD CONSTRAINT valid_organization_status
+    CHECK (status IN ('active', 'suspended'));
+
+CREATE INDEX IF NOT EXISTS idx_organizations_status ON organizations(status) WHERE status <> 'active';
+
+-- Evict every key of an organization from the gateway cache when its status changes,
+-- so a suspension applies at once instead of after the next cache refresh
+CREATE OR REPLACE FUNCTION notify_organization_status_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status THEN
+        PERFORM pg_notify('api_key_revoked', key_hash)
+        FROM api_keys
+        WHERE organization_id = NEW.id;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER organization_status_notify
+    AFTER UPDATE OF status ON organizations
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_organization_status_changed();
+
+COMMENT ON COLUMN organizations.status IS 'Organization status: active, suspended (API traffic rejected with 402; dashboard login still allowed)';
+COMMENT ON COLUMN organizations.suspension_reason IS 'Why the organization was suspended, e.g. non_payment; NULL when active';
@@ -0,0 +1,6 @@
+-- Migration 032 Down: Remove billing cycle anchor days
+-- Purpose: Rollback to calendar-month billing for every organization
+
+ALTER TABLE organizations DROP CONSTRAINT IF EXISTS valid_billing_anchor_day;
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS billing_anchor_day;
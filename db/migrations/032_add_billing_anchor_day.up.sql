@@ -0,0 +1,14 @@
+-- Migration 032: Add per-organization billing cycle anchor days
+-- Purpose: Let enterprise contracts bill on their signup anniversary (e.g., the 15th) instead of calendar months
+-- Dependencies: 001_create_organizations
+
+-- Day of the month billing periods start on; NULL = 1 (calendar months)
+-- Anchors past a month's last day fall on that last day (anchor 31 starts February's period on the 28th)
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS billing_anchor_day INTEGER;
+
+ALTER TABLE organizations
+ADD CONSTRAINT valid_billing_anchor_day CHECK (
+    billing_anchor_day IS NULL OR billing_anchor_day BETWEEN 1 AND 31
+);
+
+COMMENT ON COLUMN organizations.billing_anchor_day IS 'Day of the month billing periods start (1-31, clamped to short months), NULL for calendar months';
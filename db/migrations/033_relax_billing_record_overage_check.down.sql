@@ -0,0 +1,14 @@
+-- Migration 033 Down: Require uncapped overage in billing records
+-- Purpose: Rollback to overage_units = usage_units - included_units; capped records must be fixed first
+
+ALTER TABLE billing_records DROP CONSTRAINT IF EXISTS valid_usage;
+
+ALTER TABLE billing_records
+ADD CONSTRAINT valid_usage CHECK (
+    usage_units >= 0 AND
+    included_units >= 0 AND
+    overage_units >= 0 AND
+    overage_units = GREATEST(0, usage_units - included_units)
+);
+
+COMMENT ON COLUMN billing_records.overage_units IS NULL;
@@ -0,0 +1,16 @@
+-- Migration 033: Allow capped overage in billing records
+-- Purpose: Hard-capped plans bill overage only up to max_units, so overage_units can be below
+--          usage_units - included_units; the billing record computer writes the billed overage
+-- Dependencies: 005_create_pricing_plans
+
+ALTER TABLE billing_records DROP CONSTRAINT IF EXISTS valid_usage;
+
+ALTER TABLE billing_records
+ADD CONSTRAINT valid_usage CHECK (
+    usage_units >= 0 AND
+    included_units >= 0 AND
+    overage_units >= 0 AND
+    overage_units <= GREATEST(0, usage_units - included_units)
+);
+
+COMMENT ON COLUMN billing_records.overage_units IS 'Billed overage units: usage beyond included units, capped at the plan''s max_units';
@@ -0,0 +1,6 @@
+-- Migration 034 Down: Remove invoice PDF checksums
+-- Purpose: Rollback to unverified PDF downloads
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_pdf_sha256;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS pdf_sha256;
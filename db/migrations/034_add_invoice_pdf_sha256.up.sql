@@ -0,0 +1,13 @@
+-- Migration 034: Add invoice PDF checksums
+-- Purpose: Detect PDFs corrupted or replaced in S3 before they are emailed or displayed
+-- Dependencies: 006_create_invoices
+
+-- Hex SHA-256 of the uploaded PDF; also stored in the S3 object metadata (x-amz-meta-sha256)
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS pdf_sha256 CHAR(64);
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_pdf_sha256 CHECK (
+    pdf_sha256 IS NULL OR pdf_sha256 ~ '^[0-9a-f]{64}$'
+);
+
+COMMENT ON COLUMN invoices.pdf_sha256 IS 'Hex SHA-256 of the invoice PDF in S3, NULL for PDFs uploaded before checksums were recorded';
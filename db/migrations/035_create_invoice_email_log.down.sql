@@ -0,0 +1,4 @@
+-- Migration 035 Down: Drop invoice_email_log table
+-- Purpose: Rollback to sending invoice emails on every billing run
+
+DROP TABLE IF EXISTS invoice_email_log;
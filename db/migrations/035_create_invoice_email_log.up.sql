@@ -0,0 +1,15 @@
+-- Migration 035: Create invoice_email_log table
+-- Purpose: Record delivered invoice emails so a rerun of the billing job does not email customers twice
+-- Dependencies: 006_create_invoices
+
+CREATE TABLE IF NOT EXISTS invoice_email_log (
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    email_type VARCHAR(50) NOT NULL,  -- e.g. 'invoice'
+    recipient VARCHAR(255) NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    send_count INTEGER NOT NULL DEFAULT 1,  -- Incremented by forced resends
+
+    PRIMARY KEY (invoice_id, email_type)
+);
+
+COMMENT ON TABLE invoice_email_log IS 'Last successful send per invoice and email type; a recorded send makes later sends of that type no-ops unless forced';
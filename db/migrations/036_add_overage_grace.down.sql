@@ -0,0 +1,5 @@
+-- Migration 036 Down: Remove overage grace allowances
+-- Purpose: Rollback overage grace support
+
+ALTER TABLE pricing_plans DROP CONSTRAINT IF EXISTS valid_overage_grace;
+ALTER TABLE pricing_plans DROP COLUMN IF EXISTS overage_grace_percent;
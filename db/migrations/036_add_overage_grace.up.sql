@@ -0,0 +1,11 @@
+-- Migration 036: Add overage grace allowances
+-- Purpose: Let plans waive a small buffer of usage above the included units before billing overage
+-- Dependencies: 005_create_pricing_plans
+
+-- Percentage of included_units not billed as overage; 0 = overage starts at included_units
+ALTER TABLE pricing_plans ADD COLUMN IF NOT EXISTS overage_grace_percent NUMERIC(5,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE pricing_plans
+ADD CONSTRAINT valid_overage_grace CHECK (overage_grace_percent >= 0 AND overage_grace_percent <= 100);
+
+COMMENT ON COLUMN pricing_plans.overage_grace_percent IS 'Grace allowance above included units, as a percentage of included_units, waived before overage is billed';
@@ -0,0 +1,5 @@
+-- Migration 037 Down: Drop usage_anomalies table
+-- Purpose: Rollback usage anomaly detection
+
+DROP INDEX IF EXISTS idx_usage_anomalies_detected_at;
+DROP TABLE IF EXISTS usage_anomalies;
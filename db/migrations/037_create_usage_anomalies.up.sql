@@ -0,0 +1,20 @@
+-- Migration 037: Create usage_anomalies table
+-- Purpose: Record usage spikes far above an organization's trailing average (e.g. leaked API keys)
+-- Dependencies: None (organization_id matches organization_subscriptions)
+
+CREATE TABLE IF NOT EXISTS usage_anomalies (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id VARCHAR(255) NOT NULL,
+    billing_month DATE NOT NULL,            -- First day of the month
+    used_units BIGINT NOT NULL,             -- Month-to-date billable units when detected
+    baseline_units BIGINT NOT NULL,         -- Trailing monthly average
+    expected_units BIGINT NOT NULL,         -- Baseline prorated to the elapsed part of the period
+    usage_multiple NUMERIC(12,2),           -- used / expected; NULL when there was no previous usage
+    detected_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT unique_usage_anomaly UNIQUE (organization_id, billing_month)
+);
+
+CREATE INDEX idx_usage_anomalies_detected_at ON usage_anomalies(detected_at DESC);
+
+COMMENT ON TABLE usage_anomalies IS 'Usage spikes flagged by the hourly aggregation (one per org/month)';
@@ -0,0 +1,10 @@
+-- Migration 038 Down: Remove bandwidth usage metrics
+-- Purpose: Rollback bandwidth billing
+
+ALTER TABLE billing_records DROP COLUMN IF EXISTS metric_charges;
+ALTER TABLE pricing_plans DROP COLUMN IF EXISTS metric_pricing;
+
+DROP MATERIALIZED VIEW IF EXISTS usage_bandwidth_hourly;
+
+ALTER TABLE usage_events DROP COLUMN IF EXISTS bytes_out;
+ALTER TABLE usage_events DROP COLUMN IF EXISTS bytes_in;
@@ -0,0 +1,37 @@
+-- Migration 038: Add bandwidth usage metrics
+-- Purpose: Bill request and response bytes alongside request counts
+-- Dependencies: 004_create_usage_events, 005_create_pricing_plans, 006_create_invoices
+
+-- Body sizes recorded by the gateway; 0 for events from gateways that did not send them
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS bytes_in BIGINT DEFAULT 0 NOT NULL;
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS bytes_out BIGINT DEFAULT 0 NOT NULL;
+
+COMMENT ON COLUMN usage_events.bytes_in IS 'Request body bytes read from the client';
+COMMENT ON COLUMN usage_events.bytes_out IS 'Response body bytes written to the client';
+
+-- Hourly bandwidth of billable requests; summed over calendar and anchored billing periods
+CREATE MATERIALIZED VIEW IF NOT EXISTS usage_bandwidth_hourly
+WITH (timescaledb.continuous) AS
+SELECT
+    time_bucket('1 hour', time) AS hour,
+    organization_id,
+    SUM(bytes_in) FILTER (WHERE billable = true) AS bytes_in,
+    SUM(bytes_out) FILTER (WHERE billable = true) AS bytes_out
+FROM usage_events
+GROUP BY hour, organization_id
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('usage_bandwidth_hourly',
+    start_offset => INTERVAL '3 hours',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '15 minutes'
+);
+
+-- Per-metric prices: [{"metric": "bytes_out", "included_units": 10000000000, "unit_size": 1000000000, "unit_name": "GB", "rate": 9}]
+ALTER TABLE pricing_plans ADD COLUMN IF NOT EXISTS metric_pricing JSONB NOT NULL DEFAULT '[]';
+
+-- Charges per metric, included in subtotal_cents
+ALTER TABLE billing_records ADD COLUMN IF NOT EXISTS metric_charges JSONB NOT NULL DEFAULT '[]';
+
+COMMENT ON COLUMN pricing_plans.metric_pricing IS 'Included quantity and rate per usage metric other than requests (bytes_in, bytes_out)';
+COMMENT ON COLUMN billing_records.metric_charges IS 'Usage and charge per priced metric, billed on top of the request charges';
@@ -0,0 +1,7 @@
+-- Migration 039 Down: Remove editable invoice memo fields
+-- Purpose: Rollback to invoices without PO numbers or PDF regeneration
+
+DROP INDEX IF EXISTS idx_invoices_pdf_invalidated;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS pdf_invalidated_at;
+ALTER TABLE invoices DROP COLUMN IF EXISTS po_number;
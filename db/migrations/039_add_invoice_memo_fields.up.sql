@@ -0,0 +1,15 @@
+-- Migration 039: Add editable invoice memo fields
+-- Purpose: Let admins set notes and a PO number on unpaid invoices from the dashboard
+-- Dependencies: 006_create_invoices
+
+-- Purchase-order number required by the customer's AP department
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS po_number VARCHAR(100);
+
+-- Set when an edit makes the stored PDF outdated; the billing engine regenerates it and clears this
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS pdf_invalidated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_invoices_pdf_invalidated ON invoices(pdf_invalidated_at)
+    WHERE pdf_invalidated_at IS NOT NULL;
+
+COMMENT ON COLUMN invoices.po_number IS 'Customer purchase-order number shown on the invoice';
+COMMENT ON COLUMN invoices.pdf_invalidated_at IS 'Last edit not yet reflected in the PDF (NULL = PDF is current)';
@@ -0,0 +1,7 @@
+-- Migration 040 Down: Remove invoice custom fields and organization PO defaults
+-- Purpose: Rollback to PO numbers set per invoice only
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS invoice_custom_fields;
+ALTER TABLE organizations DROP COLUMN IF EXISTS default_po_number;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS custom_fields;
@@ -0,0 +1,14 @@
+-- Migration 040: Add invoice custom fields and organization PO defaults
+-- Purpose: Carry a default PO number and custom fields onto every invoice of an organization
+-- Dependencies: 001_create_organizations, 039_add_invoice_memo_fields
+
+-- Free-form key/value pairs printed on the invoice (e.g. cost center, project code)
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS custom_fields JSONB DEFAULT '{}'::jsonb NOT NULL;
+
+-- Copied onto each new invoice; the invoice's own po_number can then be edited
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS default_po_number VARCHAR(100);
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS invoice_custom_fields JSONB DEFAULT '{}'::jsonb NOT NULL;
+
+COMMENT ON COLUMN invoices.custom_fields IS 'Custom key/value fields shown on the invoice, the PDF, and in Stripe metadata';
+COMMENT ON COLUMN organizations.default_po_number IS 'PO number copied onto new invoices (NULL = none)';
+COMMENT ON COLUMN organizations.invoice_custom_fields IS 'Custom fields copied onto new invoices';
@@ -0,0 +1,4 @@
+-- Migration 041 Down: Drop api_key_expiry_reminders table
+-- Purpose: Rollback API key expiry reminder tracking
+
+DROP TABLE IF EXISTS api_key_expiry_reminders;
@@ -0,0 +1,14 @@
+-- Migration 041: Create api_key_expiry_reminders table
+-- Purpose: Track which expiring API keys the org's billing contact was already reminded about
+-- Dependencies: 002_create_api_keys (the reminder scan uses idx_api_keys_expires_at)
+
+CREATE TABLE IF NOT EXISTS api_key_expiry_reminders (
+    key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,        -- Expiry the reminder was sent for
+    sent_to VARCHAR(255) NOT NULL,
+    sent_at TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (key_id, expires_at)
+);
+
+COMMENT ON TABLE api_key_expiry_reminders IS 'API key expiry reminders already sent (one per key and expiry)';
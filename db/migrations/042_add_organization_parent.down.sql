@@ -0,0 +1,9 @@
+-- Migration 042 Down: Remove parent organizations
+-- Purpose: Rollback to invoicing every organization directly
+
+ALTER TABLE invoice_line_items DROP COLUMN IF EXISTS child_organization_name;
+ALTER TABLE invoice_line_items DROP COLUMN IF EXISTS child_organization_id;
+
+DROP INDEX IF EXISTS idx_organizations_parent;
+ALTER TABLE organizations DROP CONSTRAINT IF EXISTS chk_organizations_parent_not_self;
+ALTER TABLE organizations DROP COLUMN IF EXISTS parent_organization_id;
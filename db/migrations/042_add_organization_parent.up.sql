@@ -0,0 +1,18 @@
+-- Migration 042: Add parent organizations for consolidated invoicing
+-- Purpose: Let a reseller receive one invoice covering the usage of its child organizations
+-- Dependencies: 001_create_organizations, 040_add_invoice_custom_fields
+
+-- Children with a parent are invoiced on the parent's consolidated invoice instead of their own
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS parent_organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+ALTER TABLE organizations ADD CONSTRAINT chk_organizations_parent_not_self CHECK (parent_organization_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_organizations_parent ON organizations(parent_organization_id)
+    WHERE parent_organization_id IS NOT NULL;
+
+-- Which child organization a consolidated invoice's line item was billed for (NULL on regular invoices)
+ALTER TABLE invoice_line_items ADD COLUMN IF NOT EXISTS child_organization_id UUID;
+ALTER TABLE invoice_line_items ADD COLUMN IF NOT EXISTS child_organization_name VARCHAR(255);
+
+COMMENT ON COLUMN organizations.parent_organization_id IS 'Reseller whose consolidated invoice includes this organization (NULL = invoiced directly)';
+COMMENT ON COLUMN invoice_line_items.child_organization_id IS 'Child organization the line item was billed for on a consolidated invoice';
+COMMENT ON COLUMN invoice_line_items.child_organization_name IS 'Child organization name at invoicing time, used as the PDF section heading';
@@ -0,0 +1,7 @@
+-- Migration 043 Down: Remove invoice regeneration and adjustments
+-- Purpose: Rollback to correcting invoices by hand
+
+DROP TABLE IF EXISTS invoice_adjustments;
+
+DROP INDEX IF EXISTS idx_invoices_supersedes;
+ALTER TABLE invoices DROP COLUMN IF EXISTS supersedes_invoice_id;
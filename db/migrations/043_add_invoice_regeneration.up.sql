@@ -0,0 +1,29 @@
+-- Migration 043: Add invoice regeneration and adjustments
+-- Purpose: Supersede invoices after usage corrections, and record credit/debit notes for paid ones
+-- Dependencies: 006_create_invoices, 019_create_invoice_events
+
+-- A regenerated invoice points at the voided invoice it replaces
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS supersedes_invoice_id UUID REFERENCES invoices(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_supersedes ON invoices(supersedes_invoice_id)
+    WHERE supersedes_invoice_id IS NOT NULL;
+
+-- Paid invoices are corrected with a credit note (overcharged) or debit note (undercharged)
+CREATE TABLE IF NOT EXISTS invoice_adjustments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    adjustment_type VARCHAR(10) NOT NULL,  -- credit, debit
+    amount_cents BIGINT NOT NULL,
+    original_total_cents BIGINT NOT NULL,
+    corrected_total_cents BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT valid_adjustment_type CHECK (adjustment_type IN ('credit', 'debit')),
+    CONSTRAINT valid_adjustment_amount CHECK (amount_cents > 0)
+);
+
+CREATE INDEX idx_invoice_adjustments_invoice ON invoice_adjustments(invoice_id, created_at DESC);
+
+COMMENT ON COLUMN invoices.supersedes_invoice_id IS 'Voided invoice this one replaced after a usage correction';
+COMMENT ON TABLE invoice_adjustments IS 'Credit and debit notes correcting paid invoices after usage corrections';
@@ -0,0 +1,7 @@
+-- Migration 044 Down: Drop subscription_plan_changes table
+-- Purpose: Rollback to plan changes through support
+
+DROP TABLE IF EXISTS subscription_plan_changes;
+
+ALTER TABLE organization_subscriptions DROP COLUMN IF EXISTS stripe_subscription_id;
+ALTER TABLE pricing_plans DROP COLUMN IF EXISTS stripe_price_id;
@@ -0,0 +1,31 @@
+-- Migration 044: Create subscription_plan_changes table
+-- Purpose: Self-service plan changes with their proration, and the Stripe subscription they update
+-- Dependencies: 005_create_pricing_plans
+
+-- Stripe price billed for each plan, and the Stripe subscription of orgs billed through one
+ALTER TABLE pricing_plans ADD COLUMN IF NOT EXISTS stripe_price_id VARCHAR(255);
+ALTER TABLE organization_subscriptions ADD COLUMN IF NOT EXISTS stripe_subscription_id VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS subscription_plan_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id VARCHAR(255) NOT NULL,
+    from_plan_id VARCHAR(50) NOT NULL REFERENCES pricing_plans(id),
+    to_plan_id VARCHAR(50) NOT NULL REFERENCES pricing_plans(id),
+    prorated_amount_cents BIGINT NOT NULL,  -- Positive = charge, negative = credit
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    actor_user_id VARCHAR(255),
+    stripe_synced_at TIMESTAMP WITH TIME ZONE,  -- NULL until the billing engine updates the Stripe subscription
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT different_plans CHECK (from_plan_id <> to_plan_id)
+);
+
+CREATE INDEX idx_plan_changes_org ON subscription_plan_changes(organization_id, created_at DESC);
+CREATE INDEX idx_plan_changes_stripe_pending ON subscription_plan_changes(created_at)
+    WHERE stripe_synced_at IS NULL;
+
+COMMENT ON COLUMN pricing_plans.stripe_price_id IS 'Stripe price a Stripe subscription is moved to on a plan change';
+COMMENT ON COLUMN organization_subscriptions.stripe_subscription_id IS 'Stripe subscription updated on plan changes (NULL = invoiced by the billing engine only)';
+COMMENT ON TABLE subscription_plan_changes IS 'Self-service plan changes from the dashboard, prorated over the rest of the billing period';
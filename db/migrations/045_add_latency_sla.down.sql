@@ -0,0 +1,5 @@
+-- Migration 045 Down: Remove latency SLA credits
+-- Purpose: Rollback SLA credits
+
+ALTER TABLE billing_records DROP COLUMN IF EXISTS sla_credit;
+ALTER TABLE pricing_plans DROP COLUMN IF EXISTS sla_policy;
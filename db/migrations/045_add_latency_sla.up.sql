@@ -0,0 +1,13 @@
+-- Migration 045: Add latency SLA credits
+-- Purpose: Credit plans whose period breached a latency SLA on the invoice
+-- Dependencies: 004_create_usage_events, 005_create_pricing_plans, 038_add_bandwidth_metrics
+
+-- Latency SLA per plan: {"latency_threshold_ms": 500, "max_breach_percent": 1, "credit_percent": 10}
+-- NULL for plans without an SLA
+ALTER TABLE pricing_plans ADD COLUMN IF NOT EXISTS sla_policy JSONB;
+
+-- Breach rate and credit of a period that breached its SLA, deducted from subtotal_cents
+ALTER TABLE billing_records ADD COLUMN IF NOT EXISTS sla_credit JSONB;
+
+COMMENT ON COLUMN pricing_plans.sla_policy IS 'Latency threshold, allowed breach percentage and credit percentage of the plan''s SLA';
+COMMENT ON COLUMN billing_records.sla_credit IS 'Requests over the SLA latency threshold and the credit deducted from the subtotal';
@@ -0,0 +1,18 @@
+-- Migration 046 Down: Remove persisted invoice email retry state
+-- Purpose: Rollback database-driven email retries
+
+DROP INDEX IF EXISTS idx_invoices_email_retry;
+
+-- Exhausted emails were marked failed before email_failed existed
+UPDATE invoices SET status = 'failed' WHERE status = 'email_failed';
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_status;
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_status CHECK (status IN ('draft', 'pending', 'paid', 'failed', 'refunded', 'voided', 'send_failed', 'partially_refunded'));
+
+COMMENT ON COLUMN invoices.status IS 'draft, pending, paid, failed, refunded, partially_refunded, voided, send_failed (email delivery failed, retry pending)';
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS email_last_error;
+ALTER TABLE invoices DROP COLUMN IF EXISTS email_next_retry_at;
+ALTER TABLE invoices DROP COLUMN IF EXISTS email_attempts;
@@ -0,0 +1,23 @@
+-- Migration 046: Persist invoice email retry state
+-- Purpose: Retry failed invoice emails from the database, so retries survive restarts and one-off runs,
+--          and give emails that exhausted their retries their own status instead of the payment "failed"
+-- Dependencies: Requires invoices table (006), send_failed status (008), partially_refunded status (020)
+
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS email_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS email_next_retry_at TIMESTAMPTZ;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS email_last_error TEXT;
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS valid_status;
+
+ALTER TABLE invoices
+ADD CONSTRAINT valid_status CHECK (status IN ('draft', 'pending', 'paid', 'failed', 'refunded', 'voided', 'send_failed', 'partially_refunded', 'email_failed'));
+
+-- Invoices queued before retry state was persisted are retried on the next run
+UPDATE invoices SET email_attempts = 1 WHERE status = 'send_failed' AND email_attempts = 0;
+
+CREATE INDEX IF NOT EXISTS idx_invoices_email_retry ON invoices(email_next_retry_at) WHERE status = 'send_failed';
+
+COMMENT ON COLUMN invoices.status IS 'draft, pending, paid, failed (payment failed), refunded, partially_refunded, voided, send_failed (email delivery failed, retry pending), email_failed (email retries exhausted)';
+COMMENT ON COLUMN invoices.email_attempts IS 'Sends attempted for the invoice email''s latest failed delivery, including retries';
+COMMENT ON COLUMN invoices.email_next_retry_at IS 'When a send_failed invoice email is retried next (NULL = as soon as possible)';
+COMMENT ON COLUMN invoices.email_last_error IS 'Error of the last failed invoice email send';
@@ -0,0 +1,14 @@
+-- Migration 047 Down: Restore cascading deletes to usage_events and api_key_deletions
+-- Purpose: Rollback history preservation on API key purge
+-- Fails if usage events or audit rows reference keys or organizations deleted since
+
+ALTER TABLE api_key_deletions
+ADD CONSTRAINT api_key_deletions_organization_id_fkey
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE;
+
+ALTER TABLE usage_events
+ADD CONSTRAINT usage_events_api_key_id_fkey
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
+
+COMMENT ON COLUMN usage_events.api_key_id IS NULL;
+COMMENT ON COLUMN api_key_deletions.organization_id IS NULL;
@@ -0,0 +1,22 @@
+-- Migration 047: Keep usage history and purge audit records when keys or organizations are deleted
+-- Purpose: keygen purge hard-deletes revoked API keys; ON DELETE CASCADE from usage_events
+--          erased the billed usage of every purged key along with it
+-- Dependencies: 004_create_usage_events, 011_create_api_key_deletions
+
+-- ======================================================================
+-- 1. USAGE EVENTS OUTLIVE THEIR API KEY
+-- ======================================================================
+-- api_key_id keeps the purged key's ID, which api_key_deletions maps back to its prefix
+-- and name. The foreign key is dropped rather than made ON DELETE SET NULL so the
+-- compressed hypertable is never rewritten
+ALTER TABLE usage_events DROP CONSTRAINT IF EXISTS usage_events_api_key_id_fkey;
+
+COMMENT ON COLUMN usage_events.api_key_id IS 'API key the request used; may name a key purged since (see api_key_deletions)';
+
+-- ======================================================================
+-- 2. PURGE AUDIT OUTLIVES ITS ORGANIZATION
+-- ======================================================================
+-- api_key_expiry_reminders still cascades: reminders for a deleted key have no use
+ALTER TABLE api_key_deletions DROP CONSTRAINT IF EXISTS api_key_deletions_organization_id_fkey;
+
+COMMENT ON COLUMN api_key_deletions.organization_id IS 'Organization of the deleted key; kept after the organization is deleted';
@@ -0,0 +1,6 @@
+-- Migration 048 Down: Remove refund claim times
+-- Purpose: Rollback reclaiming stale processing refunds
+
+DROP INDEX IF EXISTS idx_invoice_refunds_processing;
+
+ALTER TABLE invoice_refunds DROP COLUMN IF EXISTS claimed_at;
@@ -0,0 +1,13 @@
+-- Migration 048: Record when a refund was claimed for processing
+-- Purpose: A billing engine that crashed between claiming a refund and finishing it left the
+--          refund in processing forever; claims older than a timeout are now taken over
+-- Dependencies: 020_create_invoice_refunds
+
+ALTER TABLE invoice_refunds ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
+
+-- Refunds already stuck in processing are reclaimed on the next run
+UPDATE invoice_refunds SET claimed_at = created_at WHERE status = 'processing' AND claimed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_invoice_refunds_processing ON invoice_refunds(claimed_at) WHERE status = 'processing';
+
+COMMENT ON COLUMN invoice_refunds.claimed_at IS 'When a worker moved the refund to processing; a stale claim is taken over by the next run';
@@ -3,28 +3,40 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/robfig/cron/v3"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/stripe/stripe-go/v76/client"
 
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/admin"
 	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/aggregator"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/alerts"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/billing"
 	billingConfig "github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/config"
 	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/lock"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/notify"
 	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
 )
 
 func main() {
+	once := flag.Bool("once", false, "Run one job and exit instead of starting the scheduler (overrides RUN_ONCE)")
+	job := flag.String("job", "", "Job to run with --once: aggregate or invoice (overrides RUN_JOB)")
+	month := flag.String("month", "", "Month to invoice: previous, current or YYYY-MM (overrides BILLING_PROCESS_MONTH)")
+	flag.Parse()
+
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("🚀 Starting Billing Engine Service...")
 
@@ -33,6 +45,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if err := cfg.ApplyRunFlags(*once, *job, *month); err != nil {
+		log.Fatalf("Invalid command-line flags: %v", err)
+	}
 	log.Printf("✅ Configuration loaded (Schedule: %s, ProcessMonth: %s, DryRun: %v)",
 		cfg.RunSchedule, cfg.ProcessMonth, cfg.DryRun)
 
@@ -55,11 +70,12 @@ func main() {
 	// Initialize AWS S3 client (if enabled)
 	var s3Client *s3.Client
 	if cfg.InvoiceConfig.EnableS3 {
-		awsCfg, err := config.LoadDefaultConfig(context.Background())
+		s3Client, err = invoice.NewS3Client(context.Background(), &cfg.InvoiceConfig)
 		if err != nil {
 			log.Printf("⚠️  Failed to load AWS config: %v", err)
+		} else if cfg.InvoiceConfig.S3Endpoint != "" {
+			log.Printf("✅ S3 client initialized (endpoint: %s)", cfg.InvoiceConfig.S3Endpoint)
 		} else {
-			s3Client = s3.NewFromConfig(awsCfg)
 			log.Println("✅ S3 client initialized")
 		}
 	}
@@ -72,83 +88,255 @@ func main() {
 		log.Println("✅ Stripe client initialized")
 	}
 
+	// Load pricing plans (falls back to the built-in plans until the first load succeeds)
+	planRepo := pricing.NewPlanRepository(db)
+	if err := planRepo.Refresh(context.Background()); err != nil {
+		log.Printf("⚠️  Failed to load pricing plans, using built-in plans: %v", err)
+	} else {
+		log.Println("✅ Pricing plans loaded from database")
+	}
+	pricing.SetPlanRepository(planRepo)
+
 	// Initialize components
-	usageAgg := aggregator.NewUsageAggregator(db)
-	calculator := pricing.NewCalculator()
+	usageAgg := aggregator.NewUsageAggregator(db, cfg.DefaultPlanID)
+	calculator := pricing.NewCalculator().WithRounding(cfg.OverageRounding)
+	recordComputer := billing.NewBillingRecordComputer(usageAgg, billing.NewDBRecordStore(db), calculator)
 	invoiceGen := invoice.NewInvoiceGenerator(db, s3Client, stripeClient, &cfg.InvoiceConfig)
 	pdfGen := invoice.NewPDFGenerator(&cfg.InvoiceConfig)
 	storageManager := invoice.NewStorageManager(s3Client, &cfg.InvoiceConfig)
-	stripeIntegration := invoice.NewStripeIntegration(stripeClient, &cfg.InvoiceConfig)
+	stripeIntegration := invoice.NewStripeIntegration(stripeClient, &cfg.InvoiceConfig).WithCustomerTable(db)
 	emailSender := invoice.NewEmailSender(&cfg.InvoiceConfig)
+	emailQueue := invoice.NewEmailRetryQueue(emailSender, invoiceGen, storageManager, pdfGen, cfg.InvoiceConfig.EmailMaxRetries, cfg.InvoiceConfig.EmailRetryInterval).WithEmailLog(db)
+	refundProcessor := invoice.NewRefundProcessor(invoiceGen, emailSender)
+	jobLocker := lock.NewAdvisoryLocker(db)
 	log.Println("✅ Billing components initialized")
 
-	// Setup cron scheduler
-	c := cron.New(cron.WithSeconds())
-	log.Println("🕐 Setting up cron jobs...")
+	// Invoice retention rule (optional) - scoped to the invoices/ prefix of the bucket
+	if s3Client != nil && cfg.InvoiceConfig.S3ApplyLifecycle {
+		if err := storageManager.CreateBucketIfNotExists(context.Background()); err != nil {
+			log.Printf("⚠️  Failed to apply S3 lifecycle: %v", err)
+		} else {
+			log.Printf("✅ S3 lifecycle applied (transition: %d days, expiration: %d days; 0 = never)",
+				cfg.InvoiceConfig.S3TransitionDays, cfg.InvoiceConfig.S3ExpirationDays)
+		}
+	}
+
+	// Usage alerts (optional) - checked after each hourly aggregation
+	var usageAlerter *alerts.UsageAlerter
+	if cfg.UsageAlertsEnabled {
+		usageAlerter = alerts.NewUsageAlerter(usageAgg, alerts.NewDBAlertStore(db), emailSender, cfg.UsageAlertThresholds)
+		log.Printf("✅ Usage alerts enabled at thresholds %v%%", cfg.UsageAlertThresholds)
+	}
+
+	// Usage anomaly detection (optional) - spikes against the trailing average, checked hourly
+	var anomalyDetector *alerts.AnomalyDetector
+	if cfg.AnomalyDetectionEnabled {
+		anomalyDetector = alerts.NewAnomalyDetector(usageAgg, alerts.NewDBAnomalyStore(db), emailSender, alerts.AnomalyConfig{
+			Multiple:       cfg.AnomalyMultiple,
+			BaselineMonths: cfg.AnomalyBaselineMonths,
+			MinUnits:       cfg.AnomalyMinUnits,
+			AlertEmail:     cfg.AnomalyAlertEmail,
+		})
+		log.Printf("✅ Usage anomaly detection enabled at %.1fx the %d-month average", cfg.AnomalyMultiple, cfg.AnomalyBaselineMonths)
+	}
 
-	// Job 1: Hourly usage aggregation (every hour at :00)
+	// Run notifications (optional) - summary email and/or webhook after each billing run
+	var notifiers notify.Multi
+	if cfg.NotifyOnCompletion {
+		notifiers = append(notifiers, notify.NewEmailNotifier(emailSender, cfg.NotifyEmail))
+	}
+	if cfg.NotifyWebhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(cfg.NotifyWebhookURL, cfg.NotifyWebhookFormat))
+		log.Printf("✅ Webhook notifications enabled (format: %s, failure alert above %d errors)",
+			cfg.NotifyWebhookFormat, cfg.NotifyFailureThreshold)
+	}
+	var notifier notify.Notifier
+	if len(notifiers) > 0 {
+		notifier = notifiers
+	}
+
+	// Job runners shared by the scheduler, RUN_ONCE and the admin API
+	aggregateRun := func() error {
+		return runLocked(jobLocker, lock.JobHourlyAggregation, previousHour(), func() error {
+			return runHourlyAggregation(db, usageAgg, calculator, usageAlerter, anomalyDetector)
+		})
+	}
+	invoiceRun := func(ctx context.Context, month time.Time) (*invoice.RunSummary, error) {
+		var summary *invoice.RunSummary
+		err := runLocked(jobLocker, lock.JobMonthlyBilling, month.Format("2006-01"), func() error {
+			var err error
+			summary, err = runBillingJob(ctx, cfg, month, usageAgg, calculator, recordComputer, invoiceGen, pdfGen, storageManager, stripeIntegration, notifier, emailQueue)
+			return err
+		})
+		return summary, err
+	}
+
+	// Single-run mode (RUN_ONCE / --once): run the selected job, exit non-zero on errors
+	if cfg.RunOnce {
+		code := runOnce(cfg, aggregateRun, invoiceRun)
+		db.Close()
+		os.Exit(code)
+	}
+
+	// Setup cron scheduler in the billing timezone (validated by LoadConfig)
+	loc, err := cfg.Location()
+	if err != nil {
+		log.Fatalf("Invalid billing timezone: %v", err)
+	}
+	c := cron.New(cron.WithParser(billingConfig.ScheduleParser), cron.WithLocation(loc))
+	log.Printf("🕐 Setting up cron jobs (timezone: %s)...", loc)
+
+	// Admin API state: every monthly billing run goes through it, so runs never overlap
+	var billingEntry cron.EntryID
+	adminServer := admin.NewServer(cfg.AdminAPIToken, invoiceRun,
+		func() time.Time { return c.Entry(billingEntry).Next },
+		db.PingContext,
+	)
+
+	// Job 1: Hourly usage aggregation (HOURLY_SCHEDULE, every hour at :00 by default)
 	// Aggregates usage data from the previous hour
 	hourlyJobFunc := func() {
 		log.Println("⏰ Starting hourly usage aggregation...")
-		err := runHourlyAggregation(db, usageAgg)
-		if err != nil {
+		if err := aggregateRun(); err != nil {
 			log.Printf("❌ Hourly aggregation failed: %v", err)
 		} else {
 			log.Println("✅ Hourly aggregation completed")
 		}
 	}
+	scheduleJob(c, cfg, "Hourly aggregation", cfg.HourlySchedule, hourlyJobFunc)
+
+	// Job 2: Monthly billing (BILLING_SCHEDULE, 1st of month at midnight by default)
+	// Computes billing records, then generates, stores, syncs and emails the month's invoices.
+	// This is the only job that bills; it replaces the separate monthly and legacy jobs,
+	// which ran the same month twice on overlapping schedules
+	billingJob := func(trigger string) {
+		log.Println("⏰ Starting monthly billing job...")
+		_, err := adminServer.Track(context.Background(), trigger, cfg.GetProcessMonth())
+		switch {
+		case errors.Is(err, admin.ErrRunInProgress):
+			log.Println("⏭️  Skipping monthly billing: an ad-hoc run is in progress")
+		case err != nil:
+			log.Printf("❌ Billing job failed: %v", err)
+		default:
+			log.Println("✅ Billing job completed successfully")
+		}
+	}
+	billingEntry = scheduleJob(c, cfg, "Monthly billing", cfg.RunSchedule, func() { billingJob(admin.TriggerSchedule) })
 
-	_, err = c.AddFunc("0 0 * * * *", hourlyJobFunc) // Every hour at :00
-	if err != nil {
-		log.Fatalf("Failed to setup hourly aggregation job: %v", err)
+	// Job 3: Retry failed invoice emails (every retry interval)
+	if cfg.InvoiceConfig.EnableEmail && cfg.InvoiceConfig.EmailMaxRetries > 0 {
+		emailRetryJobFunc := func() {
+			result, err := emailQueue.ProcessDue(context.Background())
+			if err != nil {
+				log.Printf("❌ Email retry failed: %v", err)
+				return
+			}
+			if result.Attempted == 0 {
+				return
+			}
+			log.Printf("📧 Email retry: %d attempted, %d sent, %d exhausted, %d rescheduled",
+				result.Attempted, result.Sent, result.Exhausted, result.Rescheduled)
+		}
+		scheduleJob(c, cfg, fmt.Sprintf("Email retry (max %d retries)", cfg.InvoiceConfig.EmailMaxRetries),
+			fmt.Sprintf("@every %s", cfg.InvoiceConfig.EmailRetryInterval), emailRetryJobFunc)
+	}
+
+	// Job 4: Push dashboard voids and plan changes to Stripe (every 15 minutes)
+	if cfg.InvoiceConfig.EnableStripe {
+		stripeVoidJobFunc := func() {
+			synced, err := invoiceGen.SyncStripeVoids(context.Background())
+			if err != nil {
+				log.Printf("❌ Stripe void sync failed: %v", err)
+			} else if synced > 0 {
+				log.Printf("🚫 Voided %d invoices on Stripe", synced)
+			}
+		}
+		scheduleJob(c, cfg, "Stripe void sync", "0 */15 * * * *", stripeVoidJobFunc)
+
+		// Move Stripe subscriptions to the plans chosen in the dashboard
+		stripePlanJobFunc := func() {
+			synced, err := invoiceGen.SyncStripePlanChanges(context.Background())
+			if err != nil {
+				log.Printf("❌ Stripe plan change sync failed: %v", err)
+			} else if synced > 0 {
+				log.Printf("🔁 Updated %d Stripe subscriptions", synced)
+			}
+		}
+		scheduleJob(c, cfg, "Stripe plan change sync", "0 */15 * * * *", stripePlanJobFunc)
 	}
-	log.Printf("✅ Hourly aggregation scheduled: 0 0 * * * * (every hour)")
 
-	// Job 2: Monthly invoice generation (1st of every month at 00:00 UTC)
-	// Generates invoices for the previous month
-	monthlyJobFunc := func() {
-		log.Println("⏰ Starting monthly invoice generation...")
-		err := runMonthlyInvoiceGeneration(cfg, db, usageAgg, calculator, invoiceGen, pdfGen, storageManager, stripeIntegration, emailSender)
+	// Job 5: Issue refunds requested from the dashboard (every 15 minutes)
+	refundJobFunc := func() {
+		processed, err := refundProcessor.ProcessPendingRefunds(context.Background())
 		if err != nil {
-			log.Printf("❌ Monthly invoice generation failed: %v", err)
-		} else {
-			log.Println("✅ Monthly invoice generation completed")
+			log.Printf("❌ Refund processing failed: %v", err)
+		} else if processed > 0 {
+			log.Printf("💸 Issued %d refunds", processed)
 		}
 	}
+	scheduleJob(c, cfg, "Refund processing", "0 */15 * * * *", refundJobFunc)
 
-	_, err = c.AddFunc("0 0 0 1 * *", monthlyJobFunc) // 1st of month at 00:00 UTC
-	if err != nil {
-		log.Fatalf("Failed to setup monthly invoice job: %v", err)
+	// Job 6: Reload pricing plans (every refresh interval)
+	planRefreshJobFunc := func() {
+		if err := planRepo.Refresh(context.Background()); err != nil {
+			log.Printf("❌ Pricing plan refresh failed: %v", err)
+		}
 	}
-	log.Printf("✅ Monthly invoice generation scheduled: 0 0 0 1 * * (1st of month at 00:00 UTC)")
+	scheduleJob(c, cfg, "Pricing plan refresh", fmt.Sprintf("@every %s", cfg.PlanRefreshInterval), planRefreshJobFunc)
 
-	// Job 3: Legacy billing job (keeps existing schedule from config)
-	legacyJobFunc := func() {
-		log.Println("⏰ Starting billing job (legacy schedule)...")
-		err := runBillingJob(cfg, usageAgg, calculator, invoiceGen, pdfGen, storageManager, stripeIntegration, emailSender)
-		if err != nil {
-			log.Printf("❌ Billing job failed: %v", err)
-		} else {
-			log.Println("✅ Billing job completed successfully")
+	// Job 7: Delete PDFs of voided invoices past legal retention (daily at 03:00)
+	if s3Client != nil && cfg.InvoiceConfig.VoidedPDFRetentionDays > 0 {
+		voidedPDFJobFunc := func() {
+			deleted, err := invoiceGen.PurgeVoidedPDFs(context.Background(), storageManager, time.Now())
+			if err != nil {
+				log.Printf("❌ Voided PDF cleanup failed: %v", err)
+			} else if deleted > 0 {
+				log.Printf("🗑️  Deleted %d voided invoice PDFs", deleted)
+			}
 		}
+		scheduleJob(c, cfg, fmt.Sprintf("Voided PDF cleanup (%d days retention)", cfg.InvoiceConfig.VoidedPDFRetentionDays),
+			"0 0 3 * * *", voidedPDFJobFunc)
 	}
 
-	_, err = c.AddFunc(cfg.RunSchedule, legacyJobFunc)
-	if err != nil {
-		log.Fatalf("Failed to setup legacy billing job: %v", err)
+	// Job 8: Regenerate PDFs of invoices edited from the dashboard (every 15 minutes)
+	if s3Client != nil && cfg.InvoiceConfig.EnableS3 {
+		pdfRefreshJobFunc := func() {
+			regenerated, err := invoiceGen.RegenerateInvalidatedPDFs(context.Background(), pdfGen, storageManager)
+			if err != nil {
+				log.Printf("❌ Invoice PDF regeneration failed: %v", err)
+			} else if regenerated > 0 {
+				log.Printf("📄 Regenerated %d edited invoice PDFs", regenerated)
+			}
+		}
+		scheduleJob(c, cfg, "Edited invoice PDF regeneration", "0 */15 * * * *", pdfRefreshJobFunc)
 	}
-	log.Printf("✅ Legacy billing job scheduled: %s", cfg.RunSchedule)
 
 	// Run immediately if requested (for testing)
 	if os.Getenv("RUN_IMMEDIATELY") == "true" {
 		log.Println("🏃 Running billing job immediately (RUN_IMMEDIATELY=true)...")
-		legacyJobFunc()
+		billingJob(admin.TriggerStartup)
 	}
 
 	// Start cron scheduler
 	c.Start()
 	defer c.Stop()
 
+	// Admin API (optional) - health, run status and ad-hoc billing runs
+	var httpServer *http.Server
+	if cfg.AdminPort != "" {
+		httpServer = &http.Server{
+			Addr:              ":" + cfg.AdminPort,
+			Handler:           adminServer.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Admin API failed: %v", err)
+			}
+		}()
+		log.Printf("✅ Admin API listening on :%s", cfg.AdminPort)
+	}
+
 	log.Println("🎧 Billing engine ready, waiting for schedule...")
 
 	// Wait for interrupt signal
@@ -157,36 +345,116 @@ func main() {
 	<-sigCh
 
 	log.Println("👋 Billing engine shutting down gracefully...")
+
+	if httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("⚠️  Admin API shutdown: %v", err)
+		}
+		cancel()
+	}
+
+	// Let an ad-hoc billing run finish rather than stopping it between invoices
+	adminServer.Wait()
+}
+
+// scheduleJob registers a cron job and logs when it first runs, exiting on an invalid schedule
+func scheduleJob(c *cron.Cron, cfg *billingConfig.Config, name, spec string, job func()) cron.EntryID {
+	id, err := c.AddFunc(spec, job)
+	if err != nil {
+		log.Fatalf("Failed to setup %s job (%q): %v", strings.ToLower(name), spec, err)
+	}
+
+	next, err := cfg.NextRun(spec, time.Now())
+	if err != nil {
+		log.Printf("✅ %s scheduled: %s", name, spec)
+		return id
+	}
+	log.Printf("✅ %s scheduled: %s (next run: %s)", name, spec, next.Format(time.RFC3339))
+	return id
+}
+
+// runOnce runs cfg.RunJob a single time without the scheduler and returns the process exit code
+// Failed invoice emails are left in send_failed for the email retry job of a scheduled instance
+func runOnce(cfg *billingConfig.Config, aggregateRun func() error, invoiceRun admin.RunFunc) int {
+	log.Printf("🏃 Running %s job once (RUN_ONCE=true)...", cfg.RunJob)
+
+	var err error
+	switch cfg.RunJob {
+	case billingConfig.RunJobAggregate:
+		err = aggregateRun()
+	default:
+		_, err = invoiceRun(context.Background(), cfg.GetProcessMonth())
+	}
+
+	if err != nil {
+		log.Printf("❌ %s job failed: %v", cfg.RunJob, err)
+		return 1
+	}
+
+	log.Printf("✅ %s job completed successfully", cfg.RunJob)
+	return 0
 }
 
-// runBillingJob executes the monthly billing process with invoice generation
+// runLocked runs a job while holding its advisory lock for the period
+// If another instance (or a slow earlier run) holds the lock, the run is logged and skipped
+func runLocked(jobLocker *lock.AdvisoryLocker, job, period string, run func() error) error {
+	release, acquired, err := jobLocker.TryLock(context.Background(), job, period)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		log.Printf("⏭️  Skipping %s for %s: another run holds the lock", job, period)
+		return nil
+	}
+	defer release()
+
+	return run()
+}
+
+// previousHour identifies the hour runHourlyAggregation aggregates, e.g. "2026-01-15T13"
+func previousHour() string {
+	return time.Now().UTC().Truncate(time.Hour).Add(-time.Hour).Format("2006-01-02T15")
+}
+
+// runBillingJob executes the monthly billing process with invoice generation for a month
+// The summary is returned with the error when invoices failed to generate
 func runBillingJob(
+	ctx context.Context,
 	cfg *billingConfig.Config,
+	month time.Time,
 	usageAgg *aggregator.UsageAggregator,
 	calculator *pricing.Calculator,
+	recordComputer *billing.BillingRecordComputer,
 	invoiceGen *invoice.InvoiceGenerator,
 	pdfGen *invoice.PDFGenerator,
 	storageManager *invoice.StorageManager,
 	stripeIntegration *invoice.StripeIntegration,
-	emailSender *invoice.EmailSender,
-) error {
-	ctx := context.Background()
+	notifier notify.Notifier,
+	emailQueue *invoice.EmailRetryQueue,
+) (*invoice.RunSummary, error) {
 	startTime := time.Now()
 
-	// Determine which month to process
-	processMonth := cfg.GetProcessMonth()
+	processMonth := month
 	monthStr := processMonth.Format("2006-01")
 
 	log.Printf("📅 Processing billing for month: %s", monthStr)
 
+	// Compute billing records from usage, then invoice them
+	if !cfg.DryRun {
+		if err := computeBillingRecords(ctx, recordComputer, processMonth); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate invoices from billing records
 	summary, err := invoiceGen.GenerateMonthly(ctx, processMonth)
 	if err != nil {
-		return fmt.Errorf("failed to generate invoices: %w", err)
+		return nil, fmt.Errorf("failed to generate invoices: %w", err)
 	}
 
-	log.Printf("📊 Generated %d invoices (%d successful, %d failed)",
-		summary.TotalInvoices, summary.SuccessCount, summary.FailureCount)
+	log.Printf("📊 Generated %d invoices (%d successful, %d failed, %d skipped (already exists))",
+		summary.TotalInvoices, summary.SuccessCount, summary.FailureCount, summary.SkippedCount)
 
 	if summary.FailureCount > 0 {
 		log.Printf("⚠️  Errors occurred during invoice generation:")
@@ -195,10 +463,24 @@ func runBillingJob(
 		}
 	}
 
+	// Verify every billing record produced an invoice (and vice versa)
+	consistency, err := invoiceGen.VerifyMonth(ctx, processMonth)
+	if err != nil {
+		log.Printf("⚠️  Consistency check failed: %v", err)
+	} else if !consistency.IsConsistent() {
+		log.Printf("⚠️  %d billing record/invoice mismatches:", len(consistency.Issues))
+		for _, issue := range consistency.Issues {
+			log.Printf("  - [%s] %s", issue.OrganizationID, issue.Type)
+		}
+	} else {
+		log.Printf("✅ Consistency check passed (%d billing records, %d invoices)",
+			consistency.BillingRecords, consistency.Invoices)
+	}
+
 	// Process each invoice (PDF, S3, Stripe, Email)
 	invoiceList, err := getInvoicesForMonth(ctx, invoiceGen, processMonth)
 	if err != nil {
-		return fmt.Errorf("failed to get invoices: %w", err)
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
 	}
 
 	successCount := 0
@@ -206,6 +488,19 @@ func runBillingJob(
 	s3Errors := 0
 	stripeErrors := 0
 	emailErrors := 0
+	emailsQueued := 0
+	var failures []invoice.InvoiceError
+
+	// recordFailure keeps a processing failure for the completion notification
+	recordFailure := func(inv *invoice.Invoice, operation string, err error) {
+		failures = append(failures, invoice.InvoiceError{
+			OrganizationID: inv.OrganizationID,
+			InvoiceID:      inv.ID,
+			Operation:      operation,
+			Error:          err,
+			Timestamp:      time.Now(),
+		})
+	}
 
 	for _, inv := range invoiceList {
 		log.Printf("📄 Processing invoice %s for %s...", inv.InvoiceNumber, inv.OrganizationName)
@@ -215,6 +510,7 @@ func runBillingJob(
 		if err != nil {
 			log.Printf("  ❌ PDF generation failed: %v", err)
 			pdfErrors++
+			recordFailure(inv, "pdf", err)
 			continue
 		}
 		log.Printf("  ✅ PDF generated (%d KB)", len(pdfData)/1024)
@@ -226,12 +522,15 @@ func runBillingJob(
 			if err != nil {
 				log.Printf("  ⚠️  S3 upload failed: %v", err)
 				s3Errors++
+				recordFailure(inv, "upload", err)
 			} else {
 				log.Printf("  ✅ Uploaded to S3: %s", pdfURL)
 
-				// Update invoice with PDF URL
+				// Update invoice with PDF URL and checksum
 				inv.PDFUrl = pdfURL
-				// TODO: Save PDF URL to database
+				if err := invoiceGen.SavePDF(ctx, inv.ID, pdfURL, inv.PDFSHA256); err != nil {
+					log.Printf("  ⚠️  Failed to save PDF URL: %v", err)
+				}
 			}
 		} else if cfg.DryRun {
 			log.Printf("  [DRY RUN] Would upload PDF to S3")
@@ -241,16 +540,18 @@ func runBillingJob(
 		if cfg.InvoiceConfig.EnableStripe && !cfg.DryRun {
 			// Get or create Stripe customer
 			org := &invoice.Organization{
-				ID:             inv.OrganizationID,
-				Name:           inv.OrganizationName,
-				Email:          inv.CustomerEmail,
-				BillingAddress: inv.BillingAddress,
+				ID:              inv.OrganizationID,
+				Name:            inv.OrganizationName,
+				Email:           inv.CustomerEmail,
+				BillingAddress:  inv.BillingAddress,
+				StripeAccountID: inv.StripeAccountID,
 			}
 
 			customer, err := stripeIntegration.CreateOrGetCustomer(ctx, org)
 			if err != nil {
 				log.Printf("  ⚠️  Stripe customer creation failed: %v", err)
 				stripeErrors++
+				recordFailure(inv, "stripe", err)
 			} else {
 				log.Printf("  ✅ Stripe customer: %s", customer.ID)
 
@@ -259,6 +560,7 @@ func runBillingJob(
 				if err != nil {
 					log.Printf("  ⚠️  Stripe invoice creation failed: %v", err)
 					stripeErrors++
+					recordFailure(inv, "stripe", err)
 				} else {
 					log.Printf("  ✅ Stripe invoice: %s", stripeInvoice.ID)
 
@@ -282,19 +584,18 @@ func runBillingJob(
 		}
 
 		// Step 4: Send email (if enabled)
+		// Failed sends are marked "send_failed" and retried by the email retry job
 		if cfg.InvoiceConfig.EnableEmail && !cfg.DryRun {
-			err = emailSender.SendInvoiceEmail(ctx, inv, pdfData)
+			err = emailQueue.Send(ctx, inv, pdfData)
 			if err != nil {
 				log.Printf("  ⚠️  Email sending failed: %v", err)
 				emailErrors++
+				if inv.Status == invoice.InvoiceStatusSendFailed {
+					emailsQueued++
+				}
+				recordFailure(inv, "email", err)
 			} else {
 				log.Printf("  ✅ Invoice emailed to %s", inv.CustomerEmail)
-
-				// Update invoice status to "pending"
-				err = invoiceGen.UpdateInvoiceStatus(ctx, inv.ID, invoice.InvoiceStatusPending)
-				if err != nil {
-					log.Printf("  ⚠️  Failed to update invoice status: %v", err)
-				}
 			}
 		} else if cfg.DryRun {
 			log.Printf("  [DRY RUN] Would email invoice to %s", inv.CustomerEmail)
@@ -311,6 +612,7 @@ func runBillingJob(
 	log.Println("=" + string(make([]byte, 70)))
 	log.Printf("Month: %s", monthStr)
 	log.Printf("Invoices Generated: %d", summary.SuccessCount)
+	log.Printf("Invoices Skipped (already exists): %d", summary.SkippedCount)
 	log.Printf("Invoices Processed: %d", successCount)
 	log.Printf("Total Revenue: %s", pricing.FormatPrice(summary.TotalRevenue))
 	log.Printf("")
@@ -319,18 +621,57 @@ func runBillingJob(
 	log.Printf("  - PDF Generation: %d", pdfErrors)
 	log.Printf("  - S3 Upload: %d", s3Errors)
 	log.Printf("  - Stripe: %d", stripeErrors)
-	log.Printf("  - Email: %d", emailErrors)
+	log.Printf("  - Email: %d (%d queued for retry)", emailErrors, emailsQueued)
 	log.Printf("")
 	log.Printf("Processing Time: %v", duration)
 	log.Printf("Dry Run: %v", cfg.DryRun)
 	log.Println("=" + string(make([]byte, 70)))
 
-	// Notify if configured
-	if cfg.NotifyOnCompletion {
-		// TODO: Send summary email notification
-		log.Printf("📧 Would send summary notification to %s", cfg.NotifyEmail)
+	runSummary := &invoice.RunSummary{
+		BillingMonth: processMonth,
+		Invoices:     summary,
+		Processed:    successCount,
+		PDFErrors:    pdfErrors,
+		S3Errors:     s3Errors,
+		StripeErrors: stripeErrors,
+		EmailErrors:  emailErrors,
+		EmailsQueued: emailsQueued,
+		Failures:     failures,
+		Duration:     duration,
+		DryRun:       cfg.DryRun,
 	}
 
+	// Notify if configured (notifier is nil when no notifications are set up)
+	if notifier != nil {
+		if err := notify.Report(ctx, notifier, runSummary, cfg.NotifyFailureThreshold); err != nil {
+			log.Printf("⚠️  Failed to send run notifications: %v", err)
+		} else {
+			log.Println("📧 Run notifications sent")
+		}
+	}
+
+	// Reported after the summary so the rest of the month still gets processed
+	if summary.FailureCount > 0 {
+		return runSummary, fmt.Errorf("billing job completed with %d invoice generation failures", summary.FailureCount)
+	}
+
+	return runSummary, nil
+}
+
+// computeBillingRecords writes the billing records that close in a month
+// Re-running it recalculates records that have not been invoiced yet
+func computeBillingRecords(ctx context.Context, recordComputer *billing.BillingRecordComputer, month time.Time) error {
+	summary, err := recordComputer.ComputeMonth(ctx, month)
+	if err != nil {
+		return fmt.Errorf("failed to compute billing records: %w", err)
+	}
+
+	log.Printf("🧮 Computed billing records (%d new, %d updated, %d already invoiced, %d failed)",
+		summary.Inserted, summary.Updated, summary.Invoiced, summary.Failed)
+
+	for _, recordErr := range summary.Errors {
+		log.Printf("  - [%s] %v", recordErr.OrganizationID, recordErr.Error)
+	}
 	return nil
 }
 
@@ -343,7 +684,8 @@ func getInvoicesForMonth(ctx context.Context, invoiceGen *invoice.InvoiceGenerat
 
 // runHourlyAggregation performs hourly aggregation of usage data
 // This job runs every hour to aggregate usage metrics for better performance
-func runHourlyAggregation(db *sql.DB, usageAgg *aggregator.UsageAggregator) error {
+// usageAlerter and anomalyDetector may be nil when usage alerts or anomaly detection are disabled
+func runHourlyAggregation(db *sql.DB, usageAgg *aggregator.UsageAggregator, calculator *pricing.Calculator, usageAlerter *alerts.UsageAlerter, anomalyDetector *alerts.AnomalyDetector) error {
 	startTime := time.Now()
 	ctx := context.Background()
 
@@ -368,23 +710,55 @@ func runHourlyAggregation(db *sql.DB, usageAgg *aggregator.UsageAggregator) erro
 
 	successCount := 0
 	errorCount := 0
+	alertCount := 0
+	cappedCount := 0
+	anomalyCount := 0
 
 	// Aggregate usage for each organization
 	for _, org := range orgs {
 		log.Printf("  Processing org: %s (%s)", org.Name, org.ID)
 
-		// Aggregate usage for the hour
-		// Note: You may need to implement AggregateUsageForHour if it doesn't exist
-		// For now, we'll use the existing AggregateUsageForMonth with custom time range
-		_, err := usageAgg.AggregateUsageForMonth(ctx, org.ID, startTimeHour)
+		// Aggregate the hour's billable units from raw events
+		units, err := usageAgg.GetBillableUnits(org.ID, startTimeHour, endTime)
 		if err != nil {
 			log.Printf("  ❌ Failed to aggregate usage for %s: %v", org.ID, err)
 			errorCount++
 			continue
 		}
 
-		log.Printf("  ✅ Aggregated usage for %s", org.ID)
+		log.Printf("  ✅ Aggregated usage for %s (%d billable units)", org.ID, units)
 		successCount++
+
+		// Flag hard-capped orgs so the gateway blocks them until next period
+		capped, err := enforceHardCap(usageAgg, calculator, org.ID)
+		if err != nil {
+			log.Printf("  ⚠️  Hard cap check failed for %s: %v", org.ID, err)
+		} else if capped {
+			log.Printf("  🛑 %s reached its plan hard cap", org.ID)
+			cappedCount++
+		}
+
+		// Notify orgs approaching their plan limit
+		if usageAlerter != nil {
+			fired, err := usageAlerter.Check(ctx, alerts.Organization{ID: org.ID, Name: org.Name, Email: org.Email})
+			if err != nil {
+				log.Printf("  ⚠️  Usage alert check failed for %s: %v", org.ID, err)
+			} else if fired > 0 {
+				log.Printf("  📧 Sent %d%% usage alert to %s", fired, org.ID)
+				alertCount++
+			}
+		}
+
+		// Flag spikes (e.g. a leaked key) before they are billed as overage
+		if anomalyDetector != nil {
+			anomaly, err := anomalyDetector.Check(ctx, alerts.Organization{ID: org.ID, Name: org.Name, Email: org.Email})
+			if err != nil {
+				log.Printf("  ⚠️  Anomaly check failed for %s: %v", org.ID, err)
+			} else if anomaly != nil {
+				log.Printf("  🚨 Usage anomaly for %s: %d units this period vs %d expected", org.ID, anomaly.UsedUnits, anomaly.ExpectedUnits)
+				anomalyCount++
+			}
+		}
 	}
 
 	duration := time.Since(startTime)
@@ -395,6 +769,9 @@ func runHourlyAggregation(db *sql.DB, usageAgg *aggregator.UsageAggregator) erro
 	log.Println("=" + string(make([]byte, 70)))
 	log.Printf("Organizations Processed: %d", successCount)
 	log.Printf("Errors: %d", errorCount)
+	log.Printf("Usage Alerts Sent: %d", alertCount)
+	log.Printf("Capped Organizations: %d", cappedCount)
+	log.Printf("Usage Anomalies: %d", anomalyCount)
 	log.Printf("Processing Time: %v", duration)
 	log.Println("=" + string(make([]byte, 70)))
 
@@ -405,158 +782,34 @@ func runHourlyAggregation(db *sql.DB, usageAgg *aggregator.UsageAggregator) erro
 	return nil
 }
 
-// runMonthlyInvoiceGeneration generates invoices for all organizations
-// This job runs on the 1st of each month at 00:00 UTC
-func runMonthlyInvoiceGeneration(
-	cfg *config.Config,
-	db *sql.DB,
-	usageAgg *aggregator.UsageAggregator,
-	calculator *pricing.Calculator,
-	invoiceGen *invoice.InvoiceGenerator,
-	pdfGen *invoice.PDFGenerator,
-	storageManager *invoice.StorageManager,
-	stripeIntegration *invoice.StripeIntegration,
-	emailSender *invoice.EmailSender,
-) error {
-	startTime := time.Now()
-	ctx := context.Background()
-
-	// Determine which month to process (previous month)
-	now := time.Now()
-	processMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
-	if cfg.ProcessMonth != "" {
-		var err error
-		processMonth, err = time.Parse("2006-01", cfg.ProcessMonth)
-		if err != nil {
-			return fmt.Errorf("invalid process month format: %w", err)
-		}
+// enforceHardCap sets or clears the org's over-limit flag read by the gateway
+// Orgs on unlimited plans (MaxUnits = 0) are always cleared, never capped
+func enforceHardCap(usageAgg *aggregator.UsageAggregator, calculator *pricing.Calculator, orgID string) (bool, error) {
+	plan, err := usageAgg.GetOrganizationPlan(orgID)
+	if err != nil {
+		return false, err
 	}
 
-	monthStr := processMonth.Format("2006-01")
-
-	log.Println("=" + string(make([]byte, 70)))
-	log.Println("💰 MONTHLY INVOICE GENERATION")
-	log.Println("=" + string(make([]byte, 70)))
-	log.Printf("Month: %s", monthStr)
-	log.Printf("Dry Run: %v", cfg.DryRun)
+	if plan.Tier.MaxUnits == 0 {
+		return false, usageAgg.ClearUsageCap(orgID)
+	}
 
-	// Fetch active organizations
-	orgs, err := fetchActiveOrganizations(db)
+	usage, err := usageAgg.GetRealTimeUsage(orgID)
 	if err != nil {
-		log.Printf("❌ Failed to fetch organizations: %v", err)
-		return fmt.Errorf("failed to fetch organizations: %w", err)
+		return false, err
 	}
 
-	log.Printf("📋 Found %d active organizations to invoice", len(orgs))
-
-	successCount := 0
-	errorCount := 0
-	var totalRevenue float64
-
-	// Process each organization
-	for _, org := range orgs {
-		log.Printf("  Processing org: %s (%s)", org.Name, org.ID)
-
-		// Generate invoice for this organization
-		summary, err := invoiceGen.GenerateMonthly(ctx, org.ID, processMonth)
-		if err != nil {
-			log.Printf("  ❌ Failed to generate invoice for %s: %v", org.ID, err)
-			errorCount++
-			continue
-		}
-
-		if summary.SuccessCount == 0 {
-			log.Printf("  ⚠️  No invoices generated for %s", org.ID)
-			continue
-		}
-
-		totalRevenue += summary.TotalRevenue
-		log.Printf("  ✅ Generated invoice for %s (Amount: %s)", org.ID, pricing.FormatPrice(summary.TotalRevenue))
-
-		// Get the generated invoice (assume first one in summary)
-		// TODO: Improve this to get actual invoice from database
-		invoices, err := getInvoicesForMonth(ctx, invoiceGen, processMonth)
-		if err != nil || len(invoices) == 0 {
-			log.Printf("  ⚠️  Could not retrieve invoice for processing")
-			continue
-		}
-
-		inv := invoices[0]
-
-		// Generate PDF
-		if cfg.InvoiceConfig.EnableS3 || cfg.InvoiceConfig.EnableEmail {
-			pdfData, err := pdfGen.GeneratePDF(ctx, inv)
-			if err != nil {
-				log.Printf("  ❌ PDF generation failed: %v", err)
-				errorCount++
-				continue
-			}
-			log.Printf("  ✅ Generated PDF (%d KB)", len(pdfData)/1024)
-
-			// Upload to S3
-			if cfg.InvoiceConfig.EnableS3 && !cfg.DryRun {
-				pdfURL, err := storageManager.UploadPDF(ctx, inv.ID, inv.OrganizationID, pdfData)
-				if err != nil {
-					log.Printf("  ❌ S3 upload failed: %v", err)
-					errorCount++
-					continue
-				}
-				log.Printf("  ✅ Uploaded to S3: %s", pdfURL)
-			}
-
-			// Send email
-			if cfg.InvoiceConfig.EnableEmail && !cfg.DryRun {
-				err = emailSender.SendInvoiceEmail(ctx, inv, pdfData)
-				if err != nil {
-					log.Printf("  ❌ Email sending failed: %v", err)
-					errorCount++
-					continue
-				}
-				log.Printf("  ✅ Invoice emailed to %s", inv.CustomerEmail)
-			}
-		}
-
-		// Create Stripe invoice
-		if cfg.InvoiceConfig.EnableStripe && !cfg.DryRun {
-			_, err = stripeIntegration.CreateOrGetCustomer(ctx, org.ID, org.Name, org.Email)
-			if err != nil {
-				log.Printf("  ❌ Stripe customer creation failed: %v", err)
-				errorCount++
-				continue
-			}
-
-			_, err = stripeIntegration.CreateInvoice(ctx, inv)
-			if err != nil {
-				log.Printf("  ❌ Stripe invoice creation failed: %v", err)
-				errorCount++
-				continue
-			}
-
-			log.Printf("  ✅ Stripe invoice created")
-		}
-
-		successCount++
+	if !calculator.IsOverHardCap(plan.Tier, usage.BillableUnits) {
+		return false, usageAgg.ClearUsageCap(orgID)
 	}
 
-	duration := time.Since(startTime)
-
-	// Summary
-	log.Println("=" + string(make([]byte, 70)))
-	log.Println("📊 MONTHLY INVOICE SUMMARY")
-	log.Println("=" + string(make([]byte, 70)))
-	log.Printf("Month: %s", monthStr)
-	log.Printf("Organizations Processed: %d", successCount)
-	log.Printf("Errors: %d", errorCount)
-	log.Printf("Total Revenue: %s", pricing.FormatPrice(totalRevenue))
-	log.Printf("Processing Time: %v", duration)
-	log.Printf("Dry Run: %v", cfg.DryRun)
-	log.Println("=" + string(make([]byte, 70)))
-
-	if errorCount > 0 {
-		return fmt.Errorf("monthly invoice generation completed with %d errors", errorCount)
+	// Capped until the next billing period starts
+	cappedUntil := usage.PeriodEnd
+	if err := usageAgg.SetUsageCap(orgID, cappedUntil, usage.BillableUnits, plan.Tier.MaxUnits); err != nil {
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
 
 // Organization represents an organization in the system
@@ -600,4 +853,3 @@ func fetchActiveOrganizations(db *sql.DB) ([]*Organization, error) {
 
 	return orgs, nil
 }
-
@@ -0,0 +1,286 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+)
+
+// Run triggers, recorded in RunRecord.Trigger
+const (
+	TriggerSchedule = "schedule"
+	TriggerAPI      = "api"
+	TriggerStartup  = "startup"
+)
+
+// ErrRunInProgress is returned by Track when a billing run is already in progress
+var ErrRunInProgress = errors.New("a billing run is already in progress")
+
+// RunFunc bills one month, the same way the monthly billing job does
+// It returns a nil summary when the run was skipped because another instance holds its lock
+type RunFunc func(ctx context.Context, month time.Time) (*invoice.RunSummary, error)
+
+// RunRecord describes an in-progress or finished billing run
+type RunRecord struct {
+	Month      string          `json:"month"` // YYYY-MM
+	Trigger    string          `json:"trigger"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Skipped    bool            `json:"skipped,omitempty"` // Another run held the lock
+	Error      string          `json:"error,omitempty"`
+	Summary    *RunSummaryView `json:"summary,omitempty"`
+}
+
+// RunSummaryView is the JSON form of an invoice.RunSummary
+type RunSummaryView struct {
+	InvoicesGenerated int     `json:"invoices_generated"`
+	InvoicesSkipped   int     `json:"invoices_skipped"`
+	InvoicesProcessed int     `json:"invoices_processed"`
+	TotalRevenueCents int64   `json:"total_revenue_cents"`
+	ErrorCount        int     `json:"error_count"`
+	EmailsQueued      int     `json:"emails_queued"`
+	DurationSeconds   float64 `json:"duration_seconds"`
+	DryRun            bool    `json:"dry_run"`
+}
+
+// StatusResponse is returned by GET /status
+type StatusResponse struct {
+	Running *RunRecord `json:"running"`
+	LastRun *RunRecord `json:"last_run"`
+	NextRun *time.Time `json:"next_run"` // Next scheduled monthly billing run
+}
+
+// Server exposes the billing engine's status and ad-hoc billing runs over HTTP
+//
+//	GET  /health                          Liveness, including the database
+//	GET  /status                          Current, last and next billing run
+//	GET  /debug/vars                      Expvar metrics, e.g. invoice status transition counts
+//	POST /run/invoices?month=YYYY-MM      Start a billing run (requires the admin token)
+type Server struct {
+	token   string
+	run     RunFunc
+	nextRun func() time.Time
+	health  func(ctx context.Context) error
+
+	mu      sync.Mutex
+	running *RunRecord
+	lastRun *RunRecord
+	wg      sync.WaitGroup // API-triggered runs
+}
+
+// NewServer creates an admin server
+// An empty token disables POST /run/invoices; nextRun and health may be nil
+func NewServer(token string, run RunFunc, nextRun func() time.Time, health func(ctx context.Context) error) *Server {
+	return &Server{
+		token:   token,
+		run:     run,
+		nextRun: nextRun,
+		health:  health,
+	}
+}
+
+// Handler returns the HTTP handler serving the admin routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/run/invoices", s.handleRunInvoices)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Track runs a billing run for a month and records it for /status
+// Scheduled and startup runs go through Track too, so API runs cannot overlap them
+func (s *Server) Track(ctx context.Context, trigger string, month time.Time) (*invoice.RunSummary, error) {
+	record, err := s.begin(trigger, month)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, record, month)
+}
+
+// Wait blocks until API-triggered runs have finished
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// begin marks a run as in progress, failing if another run is
+func (s *Server) begin(trigger string, month time.Time) (*RunRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running != nil {
+		return nil, ErrRunInProgress
+	}
+
+	s.running = &RunRecord{
+		Month:     month.Format("2006-01"),
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+	}
+	return s.running, nil
+}
+
+// execute runs the job and moves its record from running to lastRun
+func (s *Server) execute(ctx context.Context, record *RunRecord, month time.Time) (*invoice.RunSummary, error) {
+	summary, err := s.run(ctx, month)
+
+	finished := *record
+	now := time.Now()
+	finished.FinishedAt = &now
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	if summary != nil {
+		finished.Summary = newRunSummaryView(summary)
+	} else if err == nil {
+		finished.Skipped = true
+	}
+
+	s.mu.Lock()
+	s.running = nil
+	s.lastRun = &finished
+	s.mu.Unlock()
+
+	return summary, err
+}
+
+// handleHealth reports whether the engine and its database are up
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.health != nil {
+		if err := s.health(r.Context()); err != nil {
+			respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unhealthy", "error": err.Error()})
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+}
+
+// handleStatus reports the current, last and next billing run
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.mu.Lock()
+	resp := StatusResponse{Running: copyRecord(s.running), LastRun: copyRecord(s.lastRun)}
+	s.mu.Unlock()
+
+	if s.nextRun != nil {
+		if next := s.nextRun(); !next.IsZero() {
+			resp.NextRun = &next
+		}
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// handleRunInvoices starts a billing run for a month in the background
+// Repeating the request while that month is running returns the same run instead of starting
+// another; re-running a finished month is safe, as months already invoiced are skipped
+func (s *Server) handleRunInvoices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.token == "" {
+		respondError(w, http.StatusServiceUnavailable, "ad-hoc runs are disabled: ADMIN_API_TOKEN is not set")
+		return
+	}
+	if !s.authorized(r) {
+		respondError(w, http.StatusUnauthorized, "invalid or missing admin token")
+		return
+	}
+
+	month, err := time.Parse("2006-01", r.URL.Query().Get("month"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "month is required as YYYY-MM")
+		return
+	}
+	now := time.Now().UTC()
+	if month.After(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)) {
+		respondError(w, http.StatusBadRequest, "month has not started yet")
+		return
+	}
+
+	record, err := s.begin(TriggerAPI, month)
+	if errors.Is(err, ErrRunInProgress) {
+		s.mu.Lock()
+		running := copyRecord(s.running)
+		s.mu.Unlock()
+
+		if running != nil && running.Month == month.Format("2006-01") {
+			respondJSON(w, http.StatusAccepted, running)
+			return
+		}
+		respondJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "running": running})
+		return
+	}
+
+	started := *record
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if _, err := s.execute(context.Background(), record, month); err != nil {
+			log.Printf("❌ Ad-hoc billing run for %s failed: %v", record.Month, err)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, &started)
+}
+
+// authorized checks the shared secret in the Authorization: Bearer header
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// newRunSummaryView converts a run summary for JSON responses
+func newRunSummaryView(summary *invoice.RunSummary) *RunSummaryView {
+	return &RunSummaryView{
+		InvoicesGenerated: summary.Invoices.SuccessCount,
+		InvoicesSkipped:   summary.Invoices.SkippedCount,
+		InvoicesProcessed: summary.Processed,
+		TotalRevenueCents: summary.Invoices.TotalRevenue,
+		ErrorCount:        summary.ErrorCount(),
+		EmailsQueued:      summary.EmailsQueued,
+		DurationSeconds:   summary.Duration.Seconds(),
+		DryRun:            summary.DryRun,
+	}
+}
+
+// copyRecord returns a copy safe to use outside the lock
+func copyRecord(record *RunRecord) *RunRecord {
+	if record == nil {
+		return nil
+	}
+	c := *record
+	return &c
+}
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+)
+
+const testToken = "s3cret"
+
+// blockingRun is a RunFunc that waits for release, counting its calls
+type blockingRun struct {
+	calls   atomic.Int32
+	started chan time.Time
+	release chan struct{}
+}
+
+func newBlockingRun() *blockingRun {
+	return &blockingRun{started: make(chan time.Time, 10), release: make(chan struct{})}
+}
+
+func (b *blockingRun) run(ctx context.Context, month time.Time) (*invoice.RunSummary, error) {
+	b.calls.Add(1)
+	b.started <- month
+	<-b.release
+	return &invoice.RunSummary{
+		BillingMonth: month,
+		Invoices:     &invoice.InvoiceSummary{SuccessCount: 7, TotalRevenue: 69300},
+		Processed:    7,
+	}, nil
+}
+
+func postRun(t *testing.T, h http.Handler, month, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/run/invoices?month="+month, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func getStatus(t *testing.T, h http.Handler) StatusResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /status = %d, want 200", rec.Code)
+	}
+	var status StatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	return status
+}
+
+func TestRunInvoices_Auth(t *testing.T) {
+	runner := newBlockingRun()
+	close(runner.release)
+	h := NewServer(testToken, runner.run, nil, nil).Handler()
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"Missing token", "", http.StatusUnauthorized},
+		{"Wrong token", "guess", http.StatusUnauthorized},
+		{"Token prefix", testToken[:3], http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := postRun(t, h, "2026-01", tt.token); rec.Code != tt.status {
+				t.Errorf("POST /run/invoices = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+
+	if runner.calls.Load() != 0 {
+		t.Errorf("Unauthorized requests started %d runs, want 0", runner.calls.Load())
+	}
+}
+
+func TestRunInvoices_DisabledWithoutToken(t *testing.T) {
+	runner := newBlockingRun()
+	h := NewServer("", runner.run, nil, nil).Handler()
+
+	// No token configured must not mean "no token required"
+	if rec := postRun(t, h, "2026-01", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /run/invoices = %d, want 503", rec.Code)
+	}
+	if runner.calls.Load() != 0 {
+		t.Errorf("Disabled endpoint started %d runs, want 0", runner.calls.Load())
+	}
+}
+
+func TestRunInvoices_InvalidMonth(t *testing.T) {
+	runner := newBlockingRun()
+	h := NewServer(testToken, runner.run, nil, nil).Handler()
+
+	future := time.Now().UTC().AddDate(0, 2, 0).Format("2006-01")
+	for _, month := range []string{"", "2026-13", "January", future} {
+		if rec := postRun(t, h, month, testToken); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /run/invoices?month=%s = %d, want 400", month, rec.Code)
+		}
+	}
+}
+
+func TestRunInvoices_Idempotent(t *testing.T) {
+	runner := newBlockingRun()
+	server := NewServer(testToken, runner.run, nil, nil)
+	h := server.Handler()
+
+	if rec := postRun(t, h, "2026-01", testToken); rec.Code != http.StatusAccepted {
+		t.Fatalf("First POST /run/invoices = %d, want 202", rec.Code)
+	}
+	<-runner.started
+
+	// Repeating the request joins the run in progress
+	rec := postRun(t, h, "2026-01", testToken)
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Repeated POST /run/invoices = %d, want 202", rec.Code)
+	}
+	var record RunRecord
+	json.NewDecoder(rec.Body).Decode(&record)
+	if record.Month != "2026-01" || record.Trigger != TriggerAPI {
+		t.Errorf("Repeated POST returned %+v, want the 2026-01 API run", record)
+	}
+
+	// Another month has to wait for the current run
+	if rec := postRun(t, h, "2025-12", testToken); rec.Code != http.StatusConflict {
+		t.Errorf("POST for another month during a run = %d, want 409", rec.Code)
+	}
+
+	// Scheduled runs cannot overlap either
+	if _, err := server.Track(context.Background(), TriggerSchedule, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("Track() during an API run error = %v, want %v", err, ErrRunInProgress)
+	}
+
+	if status := getStatus(t, h); status.Running == nil || status.Running.Month != "2026-01" {
+		t.Errorf("Status while running = %+v, want the 2026-01 run", status.Running)
+	}
+
+	close(runner.release)
+	server.Wait()
+
+	if got := runner.calls.Load(); got != 1 {
+		t.Errorf("Billing ran %d times, want 1", got)
+	}
+
+	status := getStatus(t, h)
+	if status.Running != nil {
+		t.Errorf("Status running = %+v after the run finished, want nil", status.Running)
+	}
+	if status.LastRun == nil || status.LastRun.FinishedAt == nil || status.LastRun.Summary == nil {
+		t.Fatalf("Status last run = %+v, want the finished run with its summary", status.LastRun)
+	}
+	if status.LastRun.Summary.InvoicesGenerated != 7 || status.LastRun.Summary.TotalRevenueCents != 69300 {
+		t.Errorf("Last run summary = %+v, want 7 invoices and 69300 cents", status.LastRun.Summary)
+	}
+}
+
+func TestStatus_NextRun(t *testing.T) {
+	next := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	h := NewServer(testToken, newBlockingRun().run, func() time.Time { return next }, nil).Handler()
+
+	status := getStatus(t, h)
+	if status.NextRun == nil || !status.NextRun.Equal(next) {
+		t.Errorf("Status next run = %v, want %v", status.NextRun, next)
+	}
+	if status.LastRun != nil {
+		t.Errorf("Status last run = %+v before any run, want nil", status.LastRun)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	healthy := NewServer("", nil, nil, func(ctx context.Context) error { return nil }).Handler()
+	rec := httptest.NewRecorder()
+	healthy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /health = %d, want 200", rec.Code)
+	}
+
+	down := NewServer("", nil, nil, func(ctx context.Context) error { return errors.New("connection refused") }).Handler()
+	rec = httptest.NewRecorder()
+	down.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /health with the database down = %d, want 503", rec.Code)
+	}
+}
+
+// TestDebugVars tests that the admin mux publishes the invoice status transition counters
+func TestDebugVars(t *testing.T) {
+	expvar.Get("invoice_status_transitions").(*expvar.Map).Add("draft->pending", 1)
+
+	h := NewServer("", nil, nil, nil).Handler()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /debug/vars = %d, want 200", rec.Code)
+	}
+
+	var vars struct {
+		Transitions map[string]int64 `json:"invoice_status_transitions"`
+		Rejected    map[string]int64 `json:"invoice_status_transitions_rejected"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&vars); err != nil {
+		t.Fatalf("Failed to decode vars: %v", err)
+	}
+	if vars.Transitions["draft->pending"] < 1 || vars.Rejected == nil {
+		t.Errorf("Vars = %+v, want both transition maps with draft->pending counted", vars)
+	}
+}
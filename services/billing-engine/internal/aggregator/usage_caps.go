@@ -0,0 +1,37 @@
+package aggregator
+
+import (
+	"fmt"
+	"time"
+)
+
+// SetUsageCap marks an organization as over its plan's hard cap until cappedUntil
+// The gateway loads this flag with each cached API key and rejects requests while it is set
+func (a *UsageAggregator) SetUsageCap(orgID string, cappedUntil time.Time, usedUnits, maxUnits int64) error {
+	query := `
+		INSERT INTO rate_limit_state (organization_id, capped_until, used_units, max_units, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (organization_id) DO UPDATE SET
+			capped_until = EXCLUDED.capped_until,
+			used_units = EXCLUDED.used_units,
+			max_units = EXCLUDED.max_units,
+			updated_at = NOW()
+	`
+
+	if _, err := a.db.Exec(query, orgID, cappedUntil, usedUnits, maxUnits); err != nil {
+		return fmt.Errorf("failed to set usage cap: %w", err)
+	}
+
+	return nil
+}
+
+// ClearUsageCap removes an organization's over-limit flag (e.g. after a plan upgrade)
+func (a *UsageAggregator) ClearUsageCap(orgID string) error {
+	query := `DELETE FROM rate_limit_state WHERE organization_id = $1`
+
+	if _, err := a.db.Exec(query, orgID); err != nil {
+		return fmt.Errorf("failed to clear usage cap: %w", err)
+	}
+
+	return nil
+}
@@ -2,7 +2,10 @@ package aggregator
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -10,21 +13,106 @@ import (
 	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
 )
 
+// ErrNoSubscription is returned when an organization has no plan assignment
+var ErrNoSubscription = errors.New("no subscription found")
+
+// ErrPlanNotFound is returned for plan IDs that are unknown or no longer active
+var ErrPlanNotFound = errors.New("plan not found")
+
+// planStore loads subscriptions and plans (implemented by dbPlanStore)
+type planStore interface {
+	subscribedPlan(orgID string) (*pricing.OrganizationPlan, error)
+	pricingTier(planID string) (*pricing.PricingTier, error)
+	pricingOverride(orgID string) (*pricing.PricingOverride, error) // nil if none
+}
+
 // UsageAggregator queries TimescaleDB for usage data
 type UsageAggregator struct {
-	db *sql.DB
+	db            *sql.DB
+	plans         planStore
+	defaultPlanID string // Applied to orgs without a subscription ("" disables)
 }
 
 // NewUsageAggregator creates a new usage aggregator
-func NewUsageAggregator(db *sql.DB) *UsageAggregator {
-	return &UsageAggregator{db: db}
+// defaultPlanID is the fallback plan for organizations with no plan assignment
+func NewUsageAggregator(db *sql.DB, defaultPlanID string) *UsageAggregator {
+	return &UsageAggregator{
+		db:            db,
+		plans:         &dbPlanStore{db: db},
+		defaultPlanID: defaultPlanID,
+	}
 }
 
-// GetMonthlyUsage retrieves usage data for a specific month and organization
+// GetMonthlyUsage retrieves usage data for the billing period that starts in the given month
+// Organizations with a billing anchor day are measured anchor-to-anchor rather than by calendar month
+// The usage includes the period's bandwidth (BytesIn/BytesOut) for metric pricing
 func (a *UsageAggregator) GetMonthlyUsage(orgID string, month time.Time) (*pricing.UsageData, error) {
+	anchorDay, err := a.GetBillingAnchorDay(orgID)
+	if err != nil {
+		return nil, err
+	}
+	return a.getPeriodUsage(orgID, anchorDay, month)
+}
+
+// getPeriodUsage retrieves usage, including bandwidth, for the org's billing period that starts in month
+func (a *UsageAggregator) getPeriodUsage(orgID string, anchorDay int, month time.Time) (*pricing.UsageData, error) {
+	usage, err := a.getPeriodRequestUsage(orgID, anchorDay, month)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.addBandwidthUsage(usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// getPeriodRequestUsage retrieves request usage for the org's billing period that starts in month
+func (a *UsageAggregator) getPeriodRequestUsage(orgID string, anchorDay int, month time.Time) (*pricing.UsageData, error) {
 	// Normalize month to start of month
 	monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	periodStart, periodEnd := pricing.BillingPeriod(anchorDay, monthStart)
+
+	if periodStart.Equal(monthStart) {
+		return a.getCalendarMonthUsage(orgID, monthStart)
+	}
 
+	// Anchored periods span two calendar months, so sum the hourly rollup over the window
+	query := `
+		SELECT
+			COALESCE(SUM(total_requests), 0) as total_requests,
+			COALESCE(SUM(billable_units), 0) as billable_units,
+			COALESCE(SUM(avg_response_time_ms * total_requests) / NULLIF(SUM(total_requests), 0), 0) as avg_response_time_ms,
+			COALESCE(SUM(error_count), 0) as error_count
+		FROM usage_hourly
+		WHERE organization_id = $1
+		  AND hour >= $2
+		  AND hour < $3
+	`
+
+	usage := pricing.UsageData{
+		OrganizationID: orgID,
+		Month:          monthStart,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+	}
+
+	err := a.db.QueryRow(query, orgID, periodStart, periodEnd).Scan(
+		&usage.TotalRequests,
+		&usage.BillableUnits,
+		&usage.AvgResponseTime,
+		&usage.ErrorCount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period usage: %w", err)
+	}
+
+	return &usage, nil
+}
+
+// getCalendarMonthUsage retrieves a calendar month's usage from the monthly rollup
+func (a *UsageAggregator) getCalendarMonthUsage(orgID string, monthStart time.Time) (*pricing.UsageData, error) {
 	query := `
 		SELECT
 			organization_id,
@@ -50,37 +138,96 @@ func (a *UsageAggregator) GetMonthlyUsage(orgID string, month time.Time) (*prici
 
 	if err == sql.ErrNoRows {
 		// No usage for this month, return zero usage
-		return &pricing.UsageData{
+		usage = pricing.UsageData{
 			OrganizationID:  orgID,
 			Month:           monthStart,
 			BillableUnits:   0,
 			TotalRequests:   0,
 			AvgResponseTime: 0,
 			ErrorCount:      0,
-		}, nil
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query monthly usage: %w", err)
 	}
 
+	usage.PeriodStart, usage.PeriodEnd = monthStart, monthStart.AddDate(0, 1, 0)
+	return &usage, nil
+}
+
+// addBandwidthUsage fills in the bytes transferred by billable requests during the usage period
+func (a *UsageAggregator) addBandwidthUsage(usage *pricing.UsageData) error {
+	query := `
+		SELECT
+			COALESCE(SUM(bytes_in), 0) as bytes_in,
+			COALESCE(SUM(bytes_out), 0) as bytes_out
+		FROM usage_bandwidth_hourly
+		WHERE organization_id = $1
+		  AND hour >= $2
+		  AND hour < $3
+	`
+
+	err := a.db.QueryRow(query, usage.OrganizationID, usage.PeriodStart, usage.PeriodEnd).Scan(&usage.BytesIn, &usage.BytesOut)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query monthly usage: %w", err)
+		return fmt.Errorf("failed to query bandwidth usage: %w", err)
 	}
 
-	return &usage, nil
+	return nil
 }
 
-// GetCurrentMonthUsage retrieves usage for the current month
+// GetLatencyBreaches counts the org's requests in [start, end) and those slower than thresholdMs
+// The rollups only keep an average response time, so this reads raw events
+func (a *UsageAggregator) GetLatencyBreaches(orgID string, start, end time.Time, thresholdMs int64) (requests, breached int64, err error) {
+	query := `
+		SELECT
+			COUNT(*) as total_requests,
+			COUNT(*) FILTER (WHERE response_time_ms > $4) as breached_requests
+		FROM usage_events
+		WHERE organization_id = $1
+		  AND time >= $2
+		  AND time < $3
+	`
+
+	err = a.db.QueryRow(query, orgID, start, end, thresholdMs).Scan(&requests, &breached)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query latency breaches: %w", err)
+	}
+
+	return requests, breached, nil
+}
+
+// GetBillingAnchorDay returns the organization's billing anchor day (1 for calendar months)
+func (a *UsageAggregator) GetBillingAnchorDay(orgID string) (int, error) {
+	var anchorDay sql.NullInt64
+	err := a.db.QueryRow("SELECT billing_anchor_day FROM organizations WHERE id = $1", orgID).Scan(&anchorDay)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get billing anchor day: %w", err)
+	}
+	return pricing.NormalizeAnchorDay(int(anchorDay.Int64)), nil
+}
+
+// GetCurrentMonthUsage retrieves usage for the billing period in progress
 func (a *UsageAggregator) GetCurrentMonthUsage(orgID string) (*pricing.UsageData, error) {
-	now := time.Now()
-	return a.GetMonthlyUsage(orgID, now)
+	anchorDay, err := a.GetBillingAnchorDay(orgID)
+	if err != nil {
+		return nil, err
+	}
+	periodStart, _ := pricing.BillingPeriodContaining(anchorDay, time.Now())
+	return a.getPeriodUsage(orgID, anchorDay, periodStart)
 }
 
-// GetPreviousMonthUsage retrieves usage for the previous month (for billing)
+// GetPreviousMonthUsage retrieves usage for the last completed billing period (for billing)
 func (a *UsageAggregator) GetPreviousMonthUsage(orgID string) (*pricing.UsageData, error) {
-	now := time.Now()
-	previousMonth := now.AddDate(0, -1, 0)
-	return a.GetMonthlyUsage(orgID, previousMonth)
+	anchorDay, err := a.GetBillingAnchorDay(orgID)
+	if err != nil {
+		return nil, err
+	}
+	periodStart, _ := pricing.BillingPeriodContaining(anchorDay, time.Now())
+	previousMonth := time.Date(periodStart.Year(), periodStart.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+	return a.getPeriodUsage(orgID, anchorDay, previousMonth)
 }
 
-// GetAllOrganizationsUsage retrieves usage for all organizations for a given month
+// GetAllOrganizationsUsage retrieves usage for all organizations for the billing periods starting in a given month
+// Organizations with a billing anchor day are measured over their own anchor-to-anchor period
 func (a *UsageAggregator) GetAllOrganizationsUsage(month time.Time) ([]pricing.UsageData, error) {
 	monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
 
@@ -125,7 +272,58 @@ func (a *UsageAggregator) GetAllOrganizationsUsage(month time.Time) ([]pricing.U
 		return nil, fmt.Errorf("error iterating usage rows: %w", err)
 	}
 
-	return usageList, nil
+	return a.applyAnchoredPeriods(usageList, monthStart)
+}
+
+// applyAnchoredPeriods replaces the calendar-month usage of anchored organizations with
+// usage over their billing period; organizations with no usage in it are left out
+func (a *UsageAggregator) applyAnchoredPeriods(usageList []pricing.UsageData, monthStart time.Time) ([]pricing.UsageData, error) {
+	rows, err := a.db.Query("SELECT id, billing_anchor_day FROM organizations WHERE billing_anchor_day > 1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query billing anchor days: %w", err)
+	}
+	defer rows.Close()
+
+	anchors := make(map[string]int)
+	for rows.Next() {
+		var orgID string
+		var anchorDay int
+		if err := rows.Scan(&orgID, &anchorDay); err != nil {
+			return nil, fmt.Errorf("failed to scan billing anchor day: %w", err)
+		}
+		anchors[orgID] = anchorDay
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating billing anchor days: %w", err)
+	}
+
+	if len(anchors) == 0 {
+		for i := range usageList {
+			usageList[i].PeriodStart, usageList[i].PeriodEnd = monthStart, monthStart.AddDate(0, 1, 0)
+		}
+		return usageList, nil
+	}
+
+	result := make([]pricing.UsageData, 0, len(usageList))
+	for _, usage := range usageList {
+		if _, anchored := anchors[usage.OrganizationID]; !anchored {
+			usage.PeriodStart, usage.PeriodEnd = monthStart, monthStart.AddDate(0, 1, 0)
+			result = append(result, usage)
+		}
+	}
+
+	for orgID, anchorDay := range anchors {
+		usage, err := a.getPeriodUsage(orgID, anchorDay, monthStart)
+		if err != nil {
+			return nil, err
+		}
+		if usage.TotalRequests > 0 {
+			result = append(result, *usage)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].OrganizationID < result[j].OrganizationID })
+	return result, nil
 }
 
 // GetUsageHistory retrieves usage history for an organization (last N months)
@@ -195,28 +393,138 @@ func (a *UsageAggregator) GetAverageMonthlyUsage(orgID string, months int) (int6
 	return average, nil
 }
 
-// GetUsageTrend calculates the percentage change in usage month-over-month
-func (a *UsageAggregator) GetUsageTrend(orgID string) (float64, error) {
-	// Get current and previous month usage
-	currentUsage, err := a.GetCurrentMonthUsage(orgID)
+// GetDailyUsage retrieves billable units and bandwidth per UTC day in [start, end), oldest first
+// Reads the hourly rollups; days without usage are omitted. Each day's PeriodStart and PeriodEnd
+// bound the day itself
+func (a *UsageAggregator) GetDailyUsage(orgID string, start, end time.Time) ([]pricing.UsageData, error) {
+	query := `
+		SELECT
+			day,
+			SUM(billable_units) as billable_units,
+			SUM(bytes_in) as bytes_in,
+			SUM(bytes_out) as bytes_out
+		FROM (
+			SELECT time_bucket('1 day', hour) as day, COALESCE(billable_units, 0) as billable_units, 0 as bytes_in, 0 as bytes_out
+			FROM usage_hourly
+			WHERE organization_id = $1 AND hour >= $2 AND hour < $3
+			UNION ALL
+			SELECT time_bucket('1 day', hour), 0, COALESCE(bytes_in, 0), COALESCE(bytes_out, 0)
+			FROM usage_bandwidth_hourly
+			WHERE organization_id = $1 AND hour >= $2 AND hour < $3
+		) hourly
+		GROUP BY day
+		ORDER BY day
+	`
+
+	rows, err := a.db.Query(query, orgID, start, end)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("failed to query daily usage: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]pricing.UsageData, 0)
+	for rows.Next() {
+		var day time.Time
+		usage := pricing.UsageData{OrganizationID: orgID}
+		if err := rows.Scan(&day, &usage.BillableUnits, &usage.BytesIn, &usage.BytesOut); err != nil {
+			return nil, fmt.Errorf("failed to scan daily usage: %w", err)
+		}
+		usage.PeriodStart = day.UTC()
+		usage.PeriodEnd = usage.PeriodStart.AddDate(0, 0, 1)
+		usage.Month = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		days = append(days, usage)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating daily usage: %w", err)
 	}
 
-	previousUsage, err := a.GetPreviousMonthUsage(orgID)
+	return days, nil
+}
+
+// UsageTrend compares usage so far in the current billing period with the same elapsed
+// stretch of the previous period (e.g. March 1-10 against February 1-10)
+type UsageTrend struct {
+	CurrentUnits  int64
+	PreviousUnits int64
+	Percent       float64 // Change from PreviousUnits; 0 when NoBaseline
+	NoBaseline    bool    // Usage now after none in the previous window: growth is unbounded, not a percentage
+}
+
+// GetUsageTrend calculates the change in usage against the same point of the previous billing period
+// Comparing a partial period with a full one would understate growth early in every period
+func (a *UsageAggregator) GetUsageTrend(orgID string) (*UsageTrend, error) {
+	anchorDay, err := a.GetBillingAnchorDay(orgID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	if previousUsage.BillableUnits == 0 {
-		if currentUsage.BillableUnits == 0 {
-			return 0, nil // No change
-		}
-		return 100.0, nil // Infinite increase, cap at 100%
+	currentStart, currentEnd, previousStart, previousEnd := trendWindows(anchorDay, time.Now())
+
+	current, err := a.GetBillableUnits(orgID, currentStart, currentEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := a.GetBillableUnits(orgID, previousStart, previousEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	trend := newUsageTrend(current, previous)
+	return &trend, nil
+}
+
+// trendWindows returns the current period up to now and the previous period up to the same
+// day of the month and time (March 10 12:00 against February 10 12:00)
+// The previous window never runs past the previous period's end (Feb 1 - Mar 1 for March 31)
+func trendWindows(anchorDay int, now time.Time) (currentStart, currentEnd, previousStart, previousEnd time.Time) {
+	currentEnd = now.UTC()
+	currentStart, _ = pricing.BillingPeriodContaining(anchorDay, currentEnd)
+	previousStart, _ = pricing.BillingPeriodContaining(anchorDay, currentStart.Add(-time.Nanosecond))
+
+	previousEnd = currentEnd.AddDate(0, -1, 0)
+	if previousEnd.Before(previousStart) {
+		// A clamped anchor (the 31st in February) can start a period after that day last month
+		previousEnd = previousStart.Add(currentEnd.Sub(currentStart))
+	}
+	if previousEnd.After(currentStart) {
+		previousEnd = currentStart
+	}
+
+	return currentStart, currentEnd, previousStart, previousEnd
+}
+
+// newUsageTrend computes the percentage change from previous to current units
+func newUsageTrend(current, previous int64) UsageTrend {
+	trend := UsageTrend{CurrentUnits: current, PreviousUnits: previous}
+
+	if previous == 0 {
+		// No change when both are zero; otherwise there is no baseline to divide by
+		trend.NoBaseline = current > 0
+		return trend
+	}
+
+	trend.Percent = float64(current-previous) / float64(previous) * 100.0
+	return trend
+}
+
+// GetBillableUnits sums billable units from raw events in [start, end)
+func (a *UsageAggregator) GetBillableUnits(orgID string, start, end time.Time) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(weight) FILTER (WHERE billable = true), 0)
+		FROM usage_events
+		WHERE organization_id = $1
+		  AND time >= $2
+		  AND time < $3
+	`
+
+	var units int64
+	if err := a.db.QueryRow(query, orgID, start, end).Scan(&units); err != nil {
+		return 0, fmt.Errorf("failed to query billable units: %w", err)
 	}
 
-	trend := float64(currentUsage.BillableUnits-previousUsage.BillableUnits) / float64(previousUsage.BillableUnits) * 100.0
-	return trend, nil
+	return units, nil
 }
 
 // GetTopOrganizationsByUsage returns top N organizations by usage for a given month
@@ -268,19 +576,25 @@ func (a *UsageAggregator) GetTopOrganizationsByUsage(month time.Time, limit int)
 	return usageList, nil
 }
 
-// GetRealTimeUsage retrieves current month usage from raw events (not aggregated)
+// GetRealTimeUsage retrieves usage for the billing period in progress from raw events (not aggregated)
 // Useful for showing real-time usage before continuous aggregates refresh
+// The usage includes the period's bandwidth (BytesIn/BytesOut) for metric pricing
 func (a *UsageAggregator) GetRealTimeUsage(orgID string) (*pricing.UsageData, error) {
-	now := time.Now()
-	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
-	monthEnd := monthStart.AddDate(0, 1, 0)
+	anchorDay, err := a.GetBillingAnchorDay(orgID)
+	if err != nil {
+		return nil, err
+	}
+	periodStart, periodEnd := pricing.BillingPeriodContaining(anchorDay, time.Now())
+	monthStart := time.Date(periodStart.Year(), periodStart.Month(), 1, 0, 0, 0, 0, time.UTC)
 
 	query := `
 		SELECT
 			COUNT(*) as total_requests,
 			SUM(weight) FILTER (WHERE billable = true) as billable_units,
 			AVG(response_time_ms) as avg_response_time_ms,
-			COUNT(*) FILTER (WHERE status_code >= 500) as error_count
+			COUNT(*) FILTER (WHERE status_code >= 500) as error_count,
+			COALESCE(SUM(bytes_in) FILTER (WHERE billable = true), 0) as bytes_in,
+			COALESCE(SUM(bytes_out) FILTER (WHERE billable = true), 0) as bytes_out
 		FROM usage_events
 		WHERE organization_id = $1
 		  AND time >= $2
@@ -290,15 +604,18 @@ func (a *UsageAggregator) GetRealTimeUsage(orgID string) (*pricing.UsageData, er
 	var usage pricing.UsageData
 	usage.OrganizationID = orgID
 	usage.Month = monthStart
+	usage.PeriodStart, usage.PeriodEnd = periodStart, periodEnd
 
 	var billableUnitsNullable sql.NullInt64
 	var avgResponseTimeNullable sql.NullFloat64
 
-	err := a.db.QueryRow(query, orgID, monthStart, monthEnd).Scan(
+	err = a.db.QueryRow(query, orgID, periodStart, periodEnd).Scan(
 		&usage.TotalRequests,
 		&billableUnitsNullable,
 		&avgResponseTimeNullable,
 		&usage.ErrorCount,
+		&usage.BytesIn,
+		&usage.BytesOut,
 	)
 
 	if err != nil {
@@ -321,6 +638,239 @@ func (a *UsageAggregator) GetRealTimeUsage(orgID string) (*pricing.UsageData, er
 	return &usage, nil
 }
 
+// GetOrganizationPlan retrieves the organization's current subscription with plan limits
+// Organizations without a subscription fall back to the configured default plan
+// A per-organization pricing override, if any, is applied on top of the plan's tier
+func (a *UsageAggregator) GetOrganizationPlan(orgID string) (*pricing.OrganizationPlan, error) {
+	plan, err := a.loadOrganizationPlan(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.applyPricingOverride(plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// GetPlanForOrganization returns an active plan as the organization would be billed on it,
+// with the organization's pricing override applied, e.g. to compare plans before switching
+func (a *UsageAggregator) GetPlanForOrganization(orgID, planID string) (*pricing.OrganizationPlan, error) {
+	tier, err := a.plans.pricingTier(planID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &pricing.OrganizationPlan{
+		OrganizationID: orgID,
+		PlanID:         planID,
+		PlanName:       tier.Name,
+		Tier:           *tier,
+		Status:         "active",
+	}
+	if err := a.applyPricingOverride(plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// applyPricingOverride applies the organization's negotiated pricing, if any, to the plan's tier
+func (a *UsageAggregator) applyPricingOverride(plan *pricing.OrganizationPlan) error {
+	override, err := a.plans.pricingOverride(plan.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if override != nil {
+		plan.Tier = override.Apply(plan.Tier)
+		plan.CustomPricing = true
+	}
+	return nil
+}
+
+// loadOrganizationPlan loads the subscribed plan, or the default plan for unsubscribed orgs
+func (a *UsageAggregator) loadOrganizationPlan(orgID string) (*pricing.OrganizationPlan, error) {
+	plan, err := a.plans.subscribedPlan(orgID)
+	if err == nil {
+		return plan, nil
+	}
+
+	if !errors.Is(err, ErrNoSubscription) || a.defaultPlanID == "" {
+		return nil, err
+	}
+
+	tier, err := a.plans.pricingTier(a.defaultPlanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load default plan %s: %w", a.defaultPlanID, err)
+	}
+
+	log.Printf("[UsageAggregator] WARNING: No subscription for %s, using default plan %s", orgID, a.defaultPlanID)
+
+	// Bill the fallback plan on calendar months
+	now := time.Now().UTC()
+	periodStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return &pricing.OrganizationPlan{
+		OrganizationID:  orgID,
+		PlanID:          a.defaultPlanID,
+		PlanName:        tier.Name,
+		Tier:            *tier,
+		StartDate:       periodStart,
+		NextBillingDate: periodStart.AddDate(0, 1, 0),
+		Status:          "active",
+	}, nil
+}
+
+// dbPlanStore reads subscriptions and plans from PostgreSQL
+type dbPlanStore struct {
+	db *sql.DB
+}
+
+// subscribedPlan loads the plan an organization is subscribed to
+func (s *dbPlanStore) subscribedPlan(orgID string) (*pricing.OrganizationPlan, error) {
+	query := `
+		SELECT
+			os.organization_id,
+			os.plan_id,
+			pp.name,
+			pp.base_price_cents,
+			pp.included_units,
+			pp.overage_rate_cents,
+			COALESCE(pp.max_units, 0) as max_units,
+			COALESCE(pp.overage_grace_percent, 0) as overage_grace_percent,
+			COALESCE(pp.metric_pricing, '[]') as metric_pricing,
+			pp.sla_policy,
+			COALESCE(os.billing_cycle, 'monthly') as billing_cycle,
+			COALESCE(os.current_period_start, NOW()) as current_period_start,
+			COALESCE(os.current_period_end, os.current_period_start, NOW()) as current_period_end,
+			COALESCE(os.status, 'active') as status
+		FROM organization_subscriptions os
+		JOIN pricing_plans pp ON os.plan_id = pp.id
+		WHERE os.organization_id = $1
+	`
+
+	var plan pricing.OrganizationPlan
+	var metrics, slaPolicy []byte
+	err := s.db.QueryRow(query, orgID).Scan(
+		&plan.OrganizationID,
+		&plan.PlanID,
+		&plan.PlanName,
+		&plan.Tier.BasePrice,
+		&plan.Tier.IncludedUnits,
+		&plan.Tier.OverageRate,
+		&plan.Tier.MaxUnits,
+		&plan.Tier.GracePercent,
+		&metrics,
+		&slaPolicy,
+		&plan.Tier.BillingPeriod,
+		&plan.StartDate,
+		&plan.NextBillingDate,
+		&plan.Status,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w for organization: %s", ErrNoSubscription, orgID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organization plan: %w", err)
+	}
+
+	if plan.Tier.Metrics, err = pricing.ParseMetricPricing(metrics); err != nil {
+		return nil, fmt.Errorf("invalid metric pricing for plan %s: %w", plan.PlanID, err)
+	}
+	if plan.Tier.SLA, err = pricing.ParseSLAPolicy(slaPolicy); err != nil {
+		return nil, fmt.Errorf("invalid SLA policy for plan %s: %w", plan.PlanID, err)
+	}
+
+	plan.Tier.Name = plan.PlanName
+	return &plan, nil
+}
+
+// pricingTier loads an active plan's limits by ID
+func (s *dbPlanStore) pricingTier(planID string) (*pricing.PricingTier, error) {
+	query := `
+		SELECT
+			name,
+			base_price_cents,
+			included_units,
+			overage_rate_cents,
+			COALESCE(max_units, 0) as max_units,
+			COALESCE(overage_grace_percent, 0) as overage_grace_percent,
+			COALESCE(metric_pricing, '[]') as metric_pricing,
+			sla_policy
+		FROM pricing_plans
+		WHERE id = $1 AND is_active = true
+	`
+
+	tier := pricing.PricingTier{BillingPeriod: "monthly"}
+	var metrics, slaPolicy []byte
+	err := s.db.QueryRow(query, planID).Scan(
+		&tier.Name,
+		&tier.BasePrice,
+		&tier.IncludedUnits,
+		&tier.OverageRate,
+		&tier.MaxUnits,
+		&tier.GracePercent,
+		&metrics,
+		&slaPolicy,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrPlanNotFound, planID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plan: %w", err)
+	}
+
+	if tier.Metrics, err = pricing.ParseMetricPricing(metrics); err != nil {
+		return nil, fmt.Errorf("invalid metric pricing for plan %s: %w", planID, err)
+	}
+	if tier.SLA, err = pricing.ParseSLAPolicy(slaPolicy); err != nil {
+		return nil, fmt.Errorf("invalid SLA policy for plan %s: %w", planID, err)
+	}
+
+	return &tier, nil
+}
+
+// pricingOverride loads an organization's negotiated pricing, or nil if it has none
+func (s *dbPlanStore) pricingOverride(orgID string) (*pricing.PricingOverride, error) {
+	query := `
+		SELECT base_price_cents, included_units, overage_rate_cents, minimum_charge_cents
+		FROM organization_pricing_overrides
+		WHERE organization_id = $1
+	`
+
+	var basePrice, includedUnits, overageRate, minimumCharge sql.NullInt64
+	err := s.db.QueryRow(query, orgID).Scan(&basePrice, &includedUnits, &overageRate, &minimumCharge)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pricing override: %w", err)
+	}
+
+	return &pricing.PricingOverride{
+		OrganizationID:     orgID,
+		BasePrice:          nullInt64Ptr(basePrice),
+		IncludedUnits:      nullInt64Ptr(includedUnits),
+		OverageRate:        nullInt64Ptr(overageRate),
+		MinimumChargeCents: nullInt64Ptr(minimumCharge),
+	}, nil
+}
+
+// nullInt64Ptr converts a nullable column to a pointer (nil when NULL)
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
+
 // Close closes the database connection
 func (a *UsageAggregator) Close() error {
 	if a.db != nil {
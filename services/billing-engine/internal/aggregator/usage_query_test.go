@@ -0,0 +1,308 @@
+package aggregator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// fakePlanStore serves subscriptions and plans from memory
+type fakePlanStore struct {
+	subscriptions map[string]*pricing.OrganizationPlan
+	tiers         map[string]pricing.PricingTier
+	overrides     map[string]*pricing.PricingOverride
+}
+
+func (f *fakePlanStore) subscribedPlan(orgID string) (*pricing.OrganizationPlan, error) {
+	plan, ok := f.subscriptions[orgID]
+	if !ok {
+		return nil, fmt.Errorf("%w for organization: %s", ErrNoSubscription, orgID)
+	}
+	return plan, nil
+}
+
+func (f *fakePlanStore) pricingTier(planID string) (*pricing.PricingTier, error) {
+	tier, ok := f.tiers[planID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPlanNotFound, planID)
+	}
+	return &tier, nil
+}
+
+func (f *fakePlanStore) pricingOverride(orgID string) (*pricing.PricingOverride, error) {
+	return f.overrides[orgID], nil
+}
+
+var (
+	freeTier    = pricing.PricingTier{Name: "Free", IncludedUnits: 1000, MaxUnits: 1000, BillingPeriod: "monthly"}
+	starterTier = pricing.PricingTier{Name: "Starter", BasePrice: 2900, IncludedUnits: 100000, OverageRate: 50, BillingPeriod: "monthly"}
+)
+
+func newTestPlanStore() *fakePlanStore {
+	return &fakePlanStore{
+		subscriptions: map[string]*pricing.OrganizationPlan{
+			"org-starter": {OrganizationID: "org-starter", PlanID: "starter", PlanName: "Starter", Tier: starterTier, Status: "active"},
+		},
+		tiers: map[string]pricing.PricingTier{
+			"free":    freeTier,
+			"starter": starterTier,
+		},
+	}
+}
+
+func TestGetOrganizationPlan_FallsBackToDefault(t *testing.T) {
+	agg := &UsageAggregator{plans: newTestPlanStore(), defaultPlanID: "free"}
+
+	plan, err := agg.GetOrganizationPlan("org-unassigned")
+	if err != nil {
+		t.Fatalf("GetOrganizationPlan() error = %v", err)
+	}
+
+	if plan.OrganizationID != "org-unassigned" || plan.PlanID != "free" {
+		t.Errorf("Plan = %s/%s, want org-unassigned/free", plan.OrganizationID, plan.PlanID)
+	}
+	if !reflect.DeepEqual(plan.Tier, freeTier) {
+		t.Errorf("Tier = %+v, want %+v", plan.Tier, freeTier)
+	}
+	if !plan.NextBillingDate.After(plan.StartDate) {
+		t.Errorf("NextBillingDate %v not after StartDate %v", plan.NextBillingDate, plan.StartDate)
+	}
+}
+
+func TestGetOrganizationPlan_ExplicitPlanUnaffected(t *testing.T) {
+	agg := &UsageAggregator{plans: newTestPlanStore(), defaultPlanID: "free"}
+
+	plan, err := agg.GetOrganizationPlan("org-starter")
+	if err != nil {
+		t.Fatalf("GetOrganizationPlan() error = %v", err)
+	}
+
+	if plan.PlanID != "starter" || !reflect.DeepEqual(plan.Tier, starterTier) {
+		t.Errorf("Plan = %s %+v, want starter %+v", plan.PlanID, plan.Tier, starterTier)
+	}
+}
+
+func TestGetOrganizationPlan_NoDefault(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultPlanID string
+	}{
+		{"Fallback disabled", ""},
+		{"Default plan missing", "legacy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := &UsageAggregator{plans: newTestPlanStore(), defaultPlanID: tt.defaultPlanID}
+
+			if _, err := agg.GetOrganizationPlan("org-unassigned"); err == nil {
+				t.Fatal("Expected error for org without a subscription")
+			}
+		})
+	}
+
+	agg := &UsageAggregator{plans: newTestPlanStore()}
+	_, err := agg.GetOrganizationPlan("org-unassigned")
+	if !errors.Is(err, ErrNoSubscription) {
+		t.Errorf("Error = %v, want ErrNoSubscription", err)
+	}
+}
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func TestGetOrganizationPlan_PricingOverride(t *testing.T) {
+	store := newTestPlanStore()
+	store.subscriptions["org-negotiated"] = &pricing.OrganizationPlan{
+		OrganizationID: "org-negotiated", PlanID: "starter", PlanName: "Starter", Tier: starterTier, Status: "active",
+	}
+	store.overrides = map[string]*pricing.PricingOverride{
+		"org-negotiated": {
+			OrganizationID: "org-negotiated",
+			BasePrice:      int64Ptr(1900),
+			OverageRate:    int64Ptr(20),
+		},
+	}
+	agg := &UsageAggregator{plans: store}
+
+	standard, err := agg.GetOrganizationPlan("org-starter")
+	if err != nil {
+		t.Fatalf("GetOrganizationPlan(standard) error = %v", err)
+	}
+	negotiated, err := agg.GetOrganizationPlan("org-negotiated")
+	if err != nil {
+		t.Fatalf("GetOrganizationPlan(negotiated) error = %v", err)
+	}
+
+	if standard.CustomPricing || !reflect.DeepEqual(standard.Tier, starterTier) {
+		t.Errorf("Standard org tier = %+v (custom=%v), want unmodified %+v", standard.Tier, standard.CustomPricing, starterTier)
+	}
+	if !negotiated.CustomPricing {
+		t.Error("Negotiated org should be flagged CustomPricing")
+	}
+
+	// Overridden fields replace the plan's; the rest are inherited
+	want := starterTier
+	want.BasePrice = 1900
+	want.OverageRate = 20
+	if !reflect.DeepEqual(negotiated.Tier, want) {
+		t.Errorf("Negotiated tier = %+v, want %+v", negotiated.Tier, want)
+	}
+
+	// Same plan, same usage, different bill
+	calc := pricing.NewCalculator()
+	usage := pricing.UsageData{BillableUnits: 300000}
+	standardBill := calc.CalculateBilling(*standard, usage)
+	negotiatedBill := calc.CalculateBilling(*negotiated, usage)
+
+	// Standard: 2900 + 200000 * 50 / 1000 = 12900; negotiated: 1900 + 200000 * 20 / 1000 = 5900
+	if standardBill.TotalCharge != 12900 {
+		t.Errorf("Standard total = %d, want 12900", standardBill.TotalCharge)
+	}
+	if negotiatedBill.TotalCharge != 5900 {
+		t.Errorf("Negotiated total = %d, want 5900", negotiatedBill.TotalCharge)
+	}
+}
+
+func TestGetOrganizationPlan_PricingOverrideOnDefaultPlan(t *testing.T) {
+	store := newTestPlanStore()
+	store.overrides = map[string]*pricing.PricingOverride{
+		"org-unassigned": {OrganizationID: "org-unassigned", IncludedUnits: int64Ptr(5000)},
+	}
+	agg := &UsageAggregator{plans: store, defaultPlanID: "free"}
+
+	plan, err := agg.GetOrganizationPlan("org-unassigned")
+	if err != nil {
+		t.Fatalf("GetOrganizationPlan() error = %v", err)
+	}
+
+	// The free plan's hard cap is raised to the negotiated allowance
+	if plan.Tier.IncludedUnits != 5000 || plan.Tier.MaxUnits != 5000 {
+		t.Errorf("Tier = %+v, want IncludedUnits and MaxUnits 5000", plan.Tier)
+	}
+}
+
+func TestGetPlanForOrganization(t *testing.T) {
+	store := newTestPlanStore()
+	store.overrides = map[string]*pricing.PricingOverride{
+		"org-starter": {OrganizationID: "org-starter", MinimumChargeCents: int64Ptr(5000)},
+	}
+	agg := &UsageAggregator{plans: store}
+
+	// The negotiated minimum follows the organization onto another plan
+	plan, err := agg.GetPlanForOrganization("org-starter", "free")
+	if err != nil {
+		t.Fatalf("GetPlanForOrganization() error = %v", err)
+	}
+	want := freeTier
+	want.MinimumChargeCents = 5000
+	if plan.PlanID != "free" || !plan.CustomPricing || !reflect.DeepEqual(plan.Tier, want) {
+		t.Errorf("Plan = %+v, want Free with the $50 minimum", plan)
+	}
+
+	if _, err := agg.GetPlanForOrganization("org-starter", "retired"); !errors.Is(err, ErrPlanNotFound) {
+		t.Errorf("Error = %v, want ErrPlanNotFound for a plan that is not active", err)
+	}
+}
+
+func TestNewUsageTrend(t *testing.T) {
+	tests := []struct {
+		name               string
+		current            int64
+		previous           int64
+		expectedPercent    float64
+		expectedNoBaseline bool
+	}{
+		{"Growth", 115000, 100000, 15, false},
+		{"Decline", 50000, 100000, -50, false},
+		{"Unchanged", 100000, 100000, 0, false},
+		{"Usage stopped", 0, 100000, -100, false},
+		{"Zero previous usage", 100000, 0, 0, true},
+		{"No usage either period", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trend := newUsageTrend(tt.current, tt.previous)
+			if trend.Percent != tt.expectedPercent || trend.NoBaseline != tt.expectedNoBaseline {
+				t.Errorf("newUsageTrend(%d, %d) = %v%% (no baseline %v), want %v%% (no baseline %v)",
+					tt.current, tt.previous, trend.Percent, trend.NoBaseline, tt.expectedPercent, tt.expectedNoBaseline)
+			}
+			if trend.CurrentUnits != tt.current || trend.PreviousUnits != tt.previous {
+				t.Errorf("Trend units = %d / %d, want %d / %d", trend.CurrentUnits, trend.PreviousUnits, tt.current, tt.previous)
+			}
+		})
+	}
+}
+
+func TestTrendWindows(t *testing.T) {
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name          string
+		anchorDay     int
+		now           time.Time
+		currentStart  time.Time
+		previousStart time.Time
+		previousEnd   time.Time
+	}{
+		{
+			// Month-to-date against the same days of the previous month, not all of it
+			name:          "Early in a calendar month",
+			anchorDay:     1,
+			now:           date(time.March, 10, 12),
+			currentStart:  date(time.March, 1, 0),
+			previousStart: date(time.February, 1, 0),
+			previousEnd:   date(time.February, 10, 12),
+		},
+		{
+			name:          "Longer than the previous period",
+			anchorDay:     1,
+			now:           date(time.March, 31, 12),
+			currentStart:  date(time.March, 1, 0),
+			previousStart: date(time.February, 1, 0),
+			previousEnd:   date(time.March, 1, 0),
+		},
+		{
+			name:          "Anchored period",
+			anchorDay:     15,
+			now:           date(time.March, 20, 0),
+			currentStart:  date(time.March, 15, 0),
+			previousStart: date(time.February, 15, 0),
+			previousEnd:   date(time.February, 20, 0),
+		},
+		{
+			name:          "Anchored period before this month's anchor",
+			anchorDay:     15,
+			now:           date(time.March, 5, 0),
+			currentStart:  date(time.February, 15, 0),
+			previousStart: date(time.January, 15, 0),
+			previousEnd:   date(time.February, 5, 0),
+		},
+		{
+			name:          "Clamped anchor day",
+			anchorDay:     31,
+			now:           date(time.February, 28, 12),
+			currentStart:  date(time.February, 28, 0),
+			previousStart: date(time.January, 31, 0),
+			previousEnd:   date(time.January, 31, 12),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currentStart, currentEnd, previousStart, previousEnd := trendWindows(tt.anchorDay, tt.now)
+			if !currentStart.Equal(tt.currentStart) || !currentEnd.Equal(tt.now) {
+				t.Errorf("Current window = %s - %s, want %s - %s", currentStart, currentEnd, tt.currentStart, tt.now)
+			}
+			if !previousStart.Equal(tt.previousStart) || !previousEnd.Equal(tt.previousEnd) {
+				t.Errorf("Previous window = %s - %s, want %s - %s", previousStart, previousEnd, tt.previousStart, tt.previousEnd)
+			}
+		})
+	}
+}
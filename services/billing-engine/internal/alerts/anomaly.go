@@ -0,0 +1,268 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// Anomaly detection defaults
+const (
+	DefaultAnomalyMultiple       = 10.0  // Flag usage at 10x the expected amount
+	DefaultAnomalyBaselineMonths = 3     // Trailing months averaged into the baseline
+	DefaultAnomalyMinUnits       = 10000 // Ignore spikes in tiny absolute numbers
+)
+
+// minElapsedWindow keeps the first hours of a period from inflating the multiple
+const minElapsedWindow = 24 * time.Hour
+
+// AnomalySource provides month-to-date usage and past monthly totals
+type AnomalySource interface {
+	GetRealTimeUsage(orgID string) (*pricing.UsageData, error)
+	GetUsageHistory(orgID string, months int) ([]pricing.UsageData, error)
+}
+
+// AnomalyStore records detected anomalies, at most one per organization per billing month
+type AnomalyStore interface {
+	// ClaimAnomaly records an anomaly, returning false if one was already recorded this month
+	ClaimAnomaly(ctx context.Context, anomaly *UsageAnomaly) (bool, error)
+	// ReleaseAnomaly removes a record so the anomaly is reported again on the next check
+	ReleaseAnomaly(ctx context.Context, orgID string, month time.Time) error
+}
+
+// AnomalyNotifier delivers anomaly alerts to operators (implemented by invoice.EmailSender)
+type AnomalyNotifier interface {
+	SendUsageAnomalyEmail(ctx context.Context, to string, notice *invoice.UsageAnomalyNotice) error
+}
+
+// AnomalyConfig controls when usage counts as anomalous
+type AnomalyConfig struct {
+	Multiple       float64 // Usage / expected usage at or above which an anomaly is recorded
+	BaselineMonths int     // Complete months averaged into the baseline
+	MinUnits       int64   // Month-to-date units below which nothing is flagged
+	AlertEmail     string  // Operator address for alerts ("" records anomalies without emailing)
+}
+
+// UsageAnomaly is month-to-date usage far above an organization's trailing average
+type UsageAnomaly struct {
+	OrganizationID   string
+	OrganizationName string
+	BillingMonth     time.Time
+	UsedUnits        int64   // Month-to-date billable units
+	BaselineUnits    int64   // Trailing monthly average
+	ExpectedUnits    int64   // Baseline prorated to the elapsed part of the period
+	Multiple         float64 // UsedUnits / ExpectedUnits, 0 when the baseline is zero
+}
+
+// AnomalyDetector flags usage spikes (e.g. a leaked API key) before they are billed as overage
+type AnomalyDetector struct {
+	source   AnomalySource
+	store    AnomalyStore
+	notifier AnomalyNotifier
+	config   AnomalyConfig
+	now      func() time.Time
+}
+
+// NewAnomalyDetector creates a new anomaly detector
+// Zero config values fall back to the defaults
+func NewAnomalyDetector(source AnomalySource, store AnomalyStore, notifier AnomalyNotifier, config AnomalyConfig) *AnomalyDetector {
+	if config.Multiple <= 0 {
+		config.Multiple = DefaultAnomalyMultiple
+	}
+	if config.BaselineMonths <= 0 {
+		config.BaselineMonths = DefaultAnomalyBaselineMonths
+	}
+
+	return &AnomalyDetector{
+		source:   source,
+		store:    store,
+		notifier: notifier,
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+// Check compares month-to-date usage with the org's trailing average and records an anomaly
+// when it reaches the configured multiple. Returns the newly recorded anomaly, or nil
+// Organizations without a complete previous month have no baseline and are never flagged
+func (d *AnomalyDetector) Check(ctx context.Context, org Organization) (*UsageAnomaly, error) {
+	// Step 1: Load month-to-date usage
+	usage, err := d.source.GetRealTimeUsage(org.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	if usage.BillableUnits < d.config.MinUnits {
+		return nil, nil
+	}
+
+	// Step 2: Average the complete months before this one
+	history, err := d.source.GetUsageHistory(org.ID, d.config.BaselineMonths+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage history: %w", err)
+	}
+
+	baseline, months := TrailingAverage(history, usage.Month, d.config.BaselineMonths)
+	if months == 0 {
+		return nil, nil
+	}
+
+	// Step 3: Compare against the baseline for the same elapsed share of the period
+	expected := ExpectedUsage(baseline, usage.PeriodStart, usage.PeriodEnd, d.now())
+	multiple, ok := UsageMultiple(usage.BillableUnits, expected)
+	if ok && multiple < d.config.Multiple {
+		return nil, nil
+	}
+
+	anomaly := &UsageAnomaly{
+		OrganizationID:   org.ID,
+		OrganizationName: org.Name,
+		BillingMonth:     usage.Month,
+		UsedUnits:        usage.BillableUnits,
+		BaselineUnits:    baseline,
+		ExpectedUnits:    expected,
+		Multiple:         multiple,
+	}
+
+	// Step 4: Record once per month
+	claimed, err := d.store.ClaimAnomaly(ctx, anomaly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record anomaly: %w", err)
+	}
+	if !claimed {
+		return nil, nil
+	}
+
+	// Step 5: Alert operators
+	if d.config.AlertEmail != "" {
+		notice := &invoice.UsageAnomalyNotice{
+			OrganizationID:   anomaly.OrganizationID,
+			OrganizationName: anomaly.OrganizationName,
+			BillingMonth:     anomaly.BillingMonth,
+			UsedUnits:        anomaly.UsedUnits,
+			BaselineUnits:    anomaly.BaselineUnits,
+			ExpectedUnits:    anomaly.ExpectedUnits,
+			Multiple:         anomaly.Multiple,
+		}
+
+		if err := d.notifier.SendUsageAnomalyEmail(ctx, d.config.AlertEmail, notice); err != nil {
+			// Release the record so the next hourly run retries
+			if relErr := d.store.ReleaseAnomaly(ctx, org.ID, usage.Month); relErr != nil {
+				log.Printf("[AnomalyDetector] ERROR: Failed to release anomaly for %s: %v", org.ID, relErr)
+			}
+			return nil, fmt.Errorf("failed to send anomaly alert: %w", err)
+		}
+	}
+
+	return anomaly, nil
+}
+
+// TrailingAverage averages billable units over up to months entries of history before month
+// Returns the average and the number of months it covers (0 = no baseline)
+func TrailingAverage(history []pricing.UsageData, month time.Time, months int) (int64, int) {
+	current := monthStart(month)
+
+	total := int64(0)
+	count := 0
+	for _, usage := range history {
+		if count == months {
+			break
+		}
+		// The in-progress month is what is being judged, not part of the baseline
+		if !monthStart(usage.Month).Before(current) {
+			continue
+		}
+		total += usage.BillableUnits
+		count++
+	}
+
+	if count == 0 {
+		return 0, 0
+	}
+	return total / int64(count), count
+}
+
+// ExpectedUsage prorates a monthly baseline to the part of the period elapsed at now
+// At least one day counts as elapsed; a zero period returns the full baseline
+func ExpectedUsage(monthlyUnits int64, periodStart, periodEnd, now time.Time) int64 {
+	length := periodEnd.Sub(periodStart)
+	if length <= 0 {
+		return monthlyUnits
+	}
+
+	elapsed := now.Sub(periodStart)
+	if elapsed < minElapsedWindow {
+		elapsed = minElapsedWindow
+	}
+	if elapsed > length {
+		elapsed = length
+	}
+
+	return int64(float64(monthlyUnits) * elapsed.Seconds() / length.Seconds())
+}
+
+// UsageMultiple returns used as a multiple of expected
+// ok is false when expected is zero: any usage after none is an unbounded increase
+func UsageMultiple(used, expected int64) (multiple float64, ok bool) {
+	if expected <= 0 {
+		return 0, false
+	}
+	return float64(used) / float64(expected), true
+}
+
+// DBAnomalyStore persists detected anomalies in the usage_anomalies table
+type DBAnomalyStore struct {
+	db *sql.DB
+}
+
+// NewDBAnomalyStore creates a new database-backed anomaly store
+func NewDBAnomalyStore(db *sql.DB) *DBAnomalyStore {
+	return &DBAnomalyStore{db: db}
+}
+
+// ClaimAnomaly inserts an anomaly, relying on the unique constraint to deduplicate
+func (s *DBAnomalyStore) ClaimAnomaly(ctx context.Context, anomaly *UsageAnomaly) (bool, error) {
+	query := `
+		INSERT INTO usage_anomalies (organization_id, billing_month, used_units, baseline_units, expected_units, usage_multiple)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (organization_id, billing_month) DO NOTHING
+	`
+
+	// NULL multiple = no previous usage to compare against
+	multiple := sql.NullFloat64{Float64: anomaly.Multiple, Valid: anomaly.ExpectedUnits > 0}
+
+	result, err := s.db.ExecContext(ctx, query,
+		anomaly.OrganizationID,
+		monthStart(anomaly.BillingMonth),
+		anomaly.UsedUnits,
+		anomaly.BaselineUnits,
+		anomaly.ExpectedUnits,
+		multiple,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert usage anomaly: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// ReleaseAnomaly deletes a recorded anomaly
+func (s *DBAnomalyStore) ReleaseAnomaly(ctx context.Context, orgID string, month time.Time) error {
+	query := `DELETE FROM usage_anomalies WHERE organization_id = $1 AND billing_month = $2`
+
+	if _, err := s.db.ExecContext(ctx, query, orgID, monthStart(month)); err != nil {
+		return fmt.Errorf("failed to delete usage anomaly: %w", err)
+	}
+
+	return nil
+}
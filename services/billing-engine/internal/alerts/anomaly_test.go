@@ -0,0 +1,295 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// Ten days into a 31-day March
+var anomalyNow = time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)
+
+// fakeHistorySource returns fixed month-to-date usage and monthly history (newest first)
+type fakeHistorySource struct {
+	units   int64
+	history []int64 // February, January, ...
+}
+
+func (f *fakeHistorySource) GetRealTimeUsage(orgID string) (*pricing.UsageData, error) {
+	return &pricing.UsageData{
+		OrganizationID: orgID,
+		Month:          testMonth,
+		BillableUnits:  f.units,
+		PeriodStart:    testMonth,
+		PeriodEnd:      testMonth.AddDate(0, 1, 0),
+	}, nil
+}
+
+func (f *fakeHistorySource) GetUsageHistory(orgID string, months int) ([]pricing.UsageData, error) {
+	// usage_monthly already has a row for the month in progress
+	history := []pricing.UsageData{{OrganizationID: orgID, Month: testMonth, BillableUnits: f.units}}
+	for i, units := range f.history {
+		history = append(history, pricing.UsageData{OrganizationID: orgID, Month: testMonth.AddDate(0, -(i + 1), 0), BillableUnits: units})
+	}
+	if len(history) > months {
+		history = history[:months]
+	}
+	return history, nil
+}
+
+// fakeAnomalyStore is an in-memory AnomalyStore
+type fakeAnomalyStore struct {
+	recorded map[string]*UsageAnomaly
+}
+
+func newFakeAnomalyStore() *fakeAnomalyStore {
+	return &fakeAnomalyStore{recorded: make(map[string]*UsageAnomaly)}
+}
+
+func (f *fakeAnomalyStore) ClaimAnomaly(ctx context.Context, anomaly *UsageAnomaly) (bool, error) {
+	k := anomaly.OrganizationID + ":" + anomaly.BillingMonth.Format("2006-01")
+	if f.recorded[k] != nil {
+		return false, nil
+	}
+	f.recorded[k] = anomaly
+	return true, nil
+}
+
+func (f *fakeAnomalyStore) ReleaseAnomaly(ctx context.Context, orgID string, month time.Time) error {
+	delete(f.recorded, orgID+":"+month.Format("2006-01"))
+	return nil
+}
+
+// fakeAnomalyNotifier records sent notices
+type fakeAnomalyNotifier struct {
+	sent []*invoice.UsageAnomalyNotice
+	err  error
+}
+
+func (f *fakeAnomalyNotifier) SendUsageAnomalyEmail(ctx context.Context, to string, notice *invoice.UsageAnomalyNotice) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, notice)
+	return nil
+}
+
+func newTestDetector(source AnomalySource, store AnomalyStore, notifier AnomalyNotifier) *AnomalyDetector {
+	d := NewAnomalyDetector(source, store, notifier, AnomalyConfig{Multiple: 10, BaselineMonths: 3, MinUnits: 1000, AlertEmail: "ops@example.test"})
+	d.now = func() time.Time { return anomalyNow }
+	return d
+}
+
+func TestUsageMultiple(t *testing.T) {
+	tests := []struct {
+		name             string
+		used             int64
+		expected         int64
+		expectedMultiple float64
+		expectedOK       bool
+	}{
+		{"Same as expected", 5000, 5000, 1, true},
+		{"Hundredfold spike", 500000, 5000, 100, true},
+		{"Below expected", 2500, 5000, 0.5, true},
+		{"Zero previous usage", 5000, 0, 0, false},
+		{"Zero previous and current usage", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiple, ok := UsageMultiple(tt.used, tt.expected)
+			if multiple != tt.expectedMultiple || ok != tt.expectedOK {
+				t.Errorf("UsageMultiple(%d, %d) = %v, %v, want %v, %v",
+					tt.used, tt.expected, multiple, ok, tt.expectedMultiple, tt.expectedOK)
+			}
+		})
+	}
+}
+
+func TestTrailingAverage(t *testing.T) {
+	history := []pricing.UsageData{
+		{Month: testMonth, BillableUnits: 900000}, // In progress, excluded
+		{Month: testMonth.AddDate(0, -1, 0), BillableUnits: 30000},
+		{Month: testMonth.AddDate(0, -2, 0), BillableUnits: 20000},
+		{Month: testMonth.AddDate(0, -3, 0), BillableUnits: 10000},
+		{Month: testMonth.AddDate(0, -4, 0), BillableUnits: 1000000},
+	}
+
+	tests := []struct {
+		name           string
+		history        []pricing.UsageData
+		months         int
+		expectedAvg    int64
+		expectedMonths int
+	}{
+		{"Three complete months", history, 3, 20000, 3},
+		{"One month", history, 1, 30000, 1},
+		{"Shorter history than requested", history[:3], 3, 25000, 2},
+		{"Only the current month", history[:1], 3, 0, 0},
+		{"No history", nil, 3, 0, 0},
+		{"Zero previous usage", []pricing.UsageData{{Month: testMonth.AddDate(0, -1, 0)}}, 3, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			avg, months := TrailingAverage(tt.history, testMonth, tt.months)
+			if avg != tt.expectedAvg || months != tt.expectedMonths {
+				t.Errorf("TrailingAverage() = %d over %d months, want %d over %d",
+					avg, months, tt.expectedAvg, tt.expectedMonths)
+			}
+		})
+	}
+}
+
+func TestExpectedUsage(t *testing.T) {
+	periodEnd := testMonth.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected int64
+	}{
+		{"Ten of 31 days elapsed", anomalyNow, 310000 * 10 / 31},
+		{"First hour counts as a day", testMonth.Add(time.Hour), 10000},
+		{"After the period", periodEnd.Add(time.Hour), 310000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpectedUsage(310000, testMonth, periodEnd, tt.now); got != tt.expected {
+				t.Errorf("ExpectedUsage() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+
+	if got := ExpectedUsage(310000, time.Time{}, time.Time{}, anomalyNow); got != 310000 {
+		t.Errorf("ExpectedUsage() without a period = %d, want the full baseline", got)
+	}
+}
+
+func TestAnomalyDetector_FlagsSpikeOnce(t *testing.T) {
+	// 31K/month baseline = 10K expected after 10 days; 1M is 100x that
+	source := &fakeHistorySource{units: 1000000, history: []int64{31000, 31000, 31000}}
+	store := newFakeAnomalyStore()
+	notifier := &fakeAnomalyNotifier{}
+	detector := newTestDetector(source, store, notifier)
+
+	anomaly, err := detector.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if anomaly == nil {
+		t.Fatal("Expected an anomaly for a 100x spike")
+	}
+	if anomaly.BaselineUnits != 31000 || anomaly.ExpectedUnits != 10000 || anomaly.Multiple != 100 {
+		t.Errorf("Anomaly = baseline %d, expected %d, multiple %v, want 31000, 10000, 100",
+			anomaly.BaselineUnits, anomaly.ExpectedUnits, anomaly.Multiple)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].OrganizationID != testOrg.ID {
+		t.Fatalf("Sent %+v, want one notice for %s", notifier.sent, testOrg.ID)
+	}
+
+	// Still spiking next hour - already recorded this month
+	anomaly, _ = detector.Check(context.Background(), testOrg)
+	if anomaly != nil || len(notifier.sent) != 1 {
+		t.Errorf("Repeat check recorded %+v and sent %d notices, want nothing new", anomaly, len(notifier.sent))
+	}
+}
+
+func TestAnomalyDetector_NormalGrowthNotFlagged(t *testing.T) {
+	// 5x the expected usage is growth, not an anomaly at a 10x threshold
+	source := &fakeHistorySource{units: 50000, history: []int64{31000, 31000, 31000}}
+	detector := newTestDetector(source, newFakeAnomalyStore(), &fakeAnomalyNotifier{})
+
+	anomaly, err := detector.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if anomaly != nil {
+		t.Errorf("Flagged %+v, want nil below the threshold", anomaly)
+	}
+}
+
+func TestAnomalyDetector_ZeroPreviousUsage(t *testing.T) {
+	// A dormant organization suddenly sending traffic has no multiple but is flagged
+	source := &fakeHistorySource{units: 200000, history: []int64{0, 0}}
+	store := newFakeAnomalyStore()
+	detector := newTestDetector(source, store, &fakeAnomalyNotifier{})
+
+	anomaly, err := detector.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if anomaly == nil || anomaly.ExpectedUnits != 0 || anomaly.Multiple != 0 {
+		t.Fatalf("Anomaly = %+v, want one with no expected usage and no multiple", anomaly)
+	}
+
+	// Below the minimum, a dormant organization is left alone
+	source.units = 999
+	store = newFakeAnomalyStore()
+	detector = newTestDetector(source, store, &fakeAnomalyNotifier{})
+	if anomaly, _ := detector.Check(context.Background(), testOrg); anomaly != nil {
+		t.Errorf("Flagged %+v below the minimum units, want nil", anomaly)
+	}
+}
+
+func TestAnomalyDetector_NewOrganizationSkipped(t *testing.T) {
+	// No complete previous month means no baseline to compare against
+	source := &fakeHistorySource{units: 1000000}
+	detector := newTestDetector(source, newFakeAnomalyStore(), &fakeAnomalyNotifier{})
+
+	anomaly, err := detector.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if anomaly != nil {
+		t.Errorf("Flagged %+v for an organization without history, want nil", anomaly)
+	}
+}
+
+func TestAnomalyDetector_SendFailureRetries(t *testing.T) {
+	source := &fakeHistorySource{units: 1000000, history: []int64{31000}}
+	store := newFakeAnomalyStore()
+	notifier := &fakeAnomalyNotifier{err: fmt.Errorf("smtp down")}
+	detector := newTestDetector(source, store, notifier)
+
+	if _, err := detector.Check(context.Background(), testOrg); err == nil {
+		t.Fatal("Expected error when the alert fails")
+	}
+	if len(store.recorded) != 0 {
+		t.Errorf("Store kept %d anomalies after a failed alert, want 0", len(store.recorded))
+	}
+
+	// Record was released - next run alerts
+	notifier.err = nil
+	anomaly, err := detector.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if anomaly == nil || len(notifier.sent) != 1 {
+		t.Errorf("Retry recorded %+v and sent %d notices, want the anomaly and 1", anomaly, len(notifier.sent))
+	}
+}
+
+func TestAnomalyDetector_RecordsWithoutAlertEmail(t *testing.T) {
+	source := &fakeHistorySource{units: 1000000, history: []int64{31000}}
+	store := newFakeAnomalyStore()
+	notifier := &fakeAnomalyNotifier{}
+	detector := NewAnomalyDetector(source, store, notifier, AnomalyConfig{Multiple: 10, BaselineMonths: 3})
+	detector.now = func() time.Time { return anomalyNow }
+
+	anomaly, err := detector.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if anomaly == nil || len(store.recorded) != 1 {
+		t.Fatalf("Anomaly = %+v with %d recorded, want it recorded", anomaly, len(store.recorded))
+	}
+	if len(notifier.sent) != 0 {
+		t.Errorf("Sent %d notices without an alert address, want 0", len(notifier.sent))
+	}
+}
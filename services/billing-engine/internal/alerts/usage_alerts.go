@@ -0,0 +1,234 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// DefaultThresholds are the plan usage percentages that trigger an alert
+var DefaultThresholds = []int{80, 100, 120}
+
+// UsageSource provides month-to-date usage and plan limits
+type UsageSource interface {
+	GetRealTimeUsage(orgID string) (*pricing.UsageData, error)
+	GetOrganizationPlan(orgID string) (*pricing.OrganizationPlan, error)
+}
+
+// AlertStore tracks which thresholds have already fired for a billing month
+type AlertStore interface {
+	// ClaimAlert records a threshold as fired, returning false if it already fired this month
+	ClaimAlert(ctx context.Context, orgID string, month time.Time, threshold int, usedUnits int64) (bool, error)
+	// ReleaseAlert removes a claim so the threshold fires again on the next check
+	ReleaseAlert(ctx context.Context, orgID string, month time.Time, threshold int) error
+}
+
+// Notifier delivers usage alerts (implemented by invoice.EmailSender)
+type Notifier interface {
+	SendUsageAlertEmail(ctx context.Context, notice *invoice.UsageAlertNotice) error
+}
+
+// Organization identifies the recipient of an alert
+type Organization struct {
+	ID    string
+	Name  string
+	Email string
+}
+
+// UsageAlerter fires notifications when an organization approaches its plan limit
+type UsageAlerter struct {
+	source     UsageSource
+	store      AlertStore
+	notifier   Notifier
+	thresholds []int
+}
+
+// NewUsageAlerter creates a new usage alerter
+func NewUsageAlerter(source UsageSource, store AlertStore, notifier Notifier, thresholds []int) *UsageAlerter {
+	if len(thresholds) == 0 {
+		thresholds = DefaultThresholds
+	}
+
+	sorted := append([]int(nil), thresholds...)
+	sort.Ints(sorted)
+
+	return &UsageAlerter{
+		source:     source,
+		store:      store,
+		notifier:   notifier,
+		thresholds: sorted,
+	}
+}
+
+// Check compares month-to-date usage with the plan and sends at most one alert
+// Returns the threshold that fired, or 0 if nothing was sent
+func (a *UsageAlerter) Check(ctx context.Context, org Organization) (int, error) {
+	// Step 1: Load usage and plan
+	usage, err := a.source.GetRealTimeUsage(org.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	plan, err := a.source.GetOrganizationPlan(org.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get plan: %w", err)
+	}
+
+	// Step 2: Hard-capped plans (free tier) alert against the cap, others against included units
+	hardCap := plan.Tier.MaxUnits > 0
+	limit := plan.Tier.IncludedUnits
+	if hardCap {
+		limit = plan.Tier.MaxUnits
+	}
+
+	crossed := CrossedThresholds(usage.BillableUnits, limit, a.thresholds)
+	if len(crossed) == 0 {
+		return 0, nil
+	}
+
+	// Usage cannot grow past a hard cap, so thresholds above 100% collapse into "limit reached"
+	if hardCap {
+		crossed = capThresholds(crossed)
+	}
+
+	// Step 3: Claim every crossed threshold not yet fired this month
+	var claimed []int
+	for _, threshold := range crossed {
+		ok, err := a.store.ClaimAlert(ctx, org.ID, usage.Month, threshold, usage.BillableUnits)
+		if err != nil {
+			a.release(ctx, org.ID, usage.Month, claimed)
+			return 0, fmt.Errorf("failed to record alert: %w", err)
+		}
+		if ok {
+			claimed = append(claimed, threshold)
+		}
+	}
+
+	if len(claimed) == 0 {
+		return 0, nil
+	}
+
+	// Step 4: Send a single notice for the highest newly crossed threshold
+	highest := claimed[len(claimed)-1]
+	notice := &invoice.UsageAlertNotice{
+		OrganizationName: org.Name,
+		Email:            org.Email,
+		PlanName:         plan.PlanName,
+		BillingMonth:     usage.Month,
+		ThresholdPercent: highest,
+		UsedUnits:        usage.BillableUnits,
+		LimitUnits:       limit,
+		LimitReached:     hardCap && highest >= 100,
+	}
+
+	if err := a.notifier.SendUsageAlertEmail(ctx, notice); err != nil {
+		// Release the claims so the next hourly run retries
+		a.release(ctx, org.ID, usage.Month, claimed)
+		return 0, fmt.Errorf("failed to send usage alert: %w", err)
+	}
+
+	return highest, nil
+}
+
+// release removes claims after a failed send
+func (a *UsageAlerter) release(ctx context.Context, orgID string, month time.Time, thresholds []int) {
+	for _, threshold := range thresholds {
+		if err := a.store.ReleaseAlert(ctx, orgID, month, threshold); err != nil {
+			log.Printf("[UsageAlerter] ERROR: Failed to release %d%% alert for %s: %v", threshold, orgID, err)
+		}
+	}
+}
+
+// CrossedThresholds returns the sorted thresholds reached by usedUnits against limit
+func CrossedThresholds(usedUnits, limit int64, thresholds []int) []int {
+	if limit <= 0 {
+		return nil
+	}
+
+	var crossed []int
+	for _, threshold := range thresholds {
+		// Integer math: used/limit >= threshold/100
+		if usedUnits*100 >= limit*int64(threshold) {
+			crossed = append(crossed, threshold)
+		}
+	}
+
+	return crossed
+}
+
+// capThresholds drops thresholds above 100%, keeping 100 when a higher one was crossed
+func capThresholds(crossed []int) []int {
+	var capped []int
+	reachedCap := false
+
+	for _, threshold := range crossed {
+		if threshold >= 100 {
+			reachedCap = true
+			continue
+		}
+		capped = append(capped, threshold)
+	}
+
+	if reachedCap {
+		capped = append(capped, 100)
+	}
+
+	return capped
+}
+
+// DBAlertStore persists fired thresholds in the usage_alerts table
+type DBAlertStore struct {
+	db *sql.DB
+}
+
+// NewDBAlertStore creates a new database-backed alert store
+func NewDBAlertStore(db *sql.DB) *DBAlertStore {
+	return &DBAlertStore{db: db}
+}
+
+// ClaimAlert inserts a fired threshold, relying on the unique constraint to deduplicate
+func (s *DBAlertStore) ClaimAlert(ctx context.Context, orgID string, month time.Time, threshold int, usedUnits int64) (bool, error) {
+	query := `
+		INSERT INTO usage_alerts (organization_id, billing_month, threshold_percent, used_units)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id, billing_month, threshold_percent) DO NOTHING
+	`
+
+	result, err := s.db.ExecContext(ctx, query, orgID, monthStart(month), threshold, usedUnits)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert usage alert: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// ReleaseAlert deletes a fired threshold
+func (s *DBAlertStore) ReleaseAlert(ctx context.Context, orgID string, month time.Time, threshold int) error {
+	query := `
+		DELETE FROM usage_alerts
+		WHERE organization_id = $1 AND billing_month = $2 AND threshold_percent = $3
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, orgID, monthStart(month), threshold); err != nil {
+		return fmt.Errorf("failed to delete usage alert: %w", err)
+	}
+
+	return nil
+}
+
+// monthStart normalizes a time to the first day of its month (UTC)
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,181 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+var testMonth = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeSource returns fixed usage and plan data
+type fakeSource struct {
+	units int64
+	tier  pricing.PricingTier
+}
+
+func (f *fakeSource) GetRealTimeUsage(orgID string) (*pricing.UsageData, error) {
+	return &pricing.UsageData{OrganizationID: orgID, Month: testMonth, BillableUnits: f.units}, nil
+}
+
+func (f *fakeSource) GetOrganizationPlan(orgID string) (*pricing.OrganizationPlan, error) {
+	return &pricing.OrganizationPlan{OrganizationID: orgID, PlanName: f.tier.Name, Tier: f.tier}, nil
+}
+
+// fakeStore is an in-memory AlertStore
+type fakeStore struct {
+	fired map[string]bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{fired: make(map[string]bool)}
+}
+
+func (f *fakeStore) key(orgID string, month time.Time, threshold int) string {
+	return fmt.Sprintf("%s:%s:%d", orgID, month.Format("2006-01"), threshold)
+}
+
+func (f *fakeStore) ClaimAlert(ctx context.Context, orgID string, month time.Time, threshold int, usedUnits int64) (bool, error) {
+	k := f.key(orgID, month, threshold)
+	if f.fired[k] {
+		return false, nil
+	}
+	f.fired[k] = true
+	return true, nil
+}
+
+func (f *fakeStore) ReleaseAlert(ctx context.Context, orgID string, month time.Time, threshold int) error {
+	delete(f.fired, f.key(orgID, month, threshold))
+	return nil
+}
+
+// fakeNotifier records sent notices
+type fakeNotifier struct {
+	sent []*invoice.UsageAlertNotice
+	err  error
+}
+
+func (f *fakeNotifier) SendUsageAlertEmail(ctx context.Context, notice *invoice.UsageAlertNotice) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, notice)
+	return nil
+}
+
+var (
+	starterTier = pricing.PricingTier{Name: "Starter", IncludedUnits: 100000}
+	freeTier    = pricing.PricingTier{Name: "Free", IncludedUnits: 1000, MaxUnits: 1000}
+	testOrg     = Organization{ID: "org-1", Name: "Acme", Email: "billing@acme.test"}
+)
+
+func TestCrossedThresholds(t *testing.T) {
+	tests := []struct {
+		name     string
+		used     int64
+		limit    int64
+		expected []int
+	}{
+		{"Below all thresholds", 79999, 100000, nil},
+		{"Exactly 80%", 80000, 100000, []int{80}},
+		{"Over 100%", 100001, 100000, []int{80, 100}},
+		{"Over 120%", 130000, 100000, []int{80, 100, 120}},
+		{"No limit", 500, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CrossedThresholds(tt.used, tt.limit, DefaultThresholds)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("CrossedThresholds() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUsageAlerter_FiresOncePerThreshold(t *testing.T) {
+	source := &fakeSource{units: 85000, tier: starterTier}
+	notifier := &fakeNotifier{}
+	alerter := NewUsageAlerter(source, newFakeStore(), notifier, nil)
+
+	// First check fires 80%
+	fired, err := alerter.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if fired != 80 {
+		t.Errorf("Fired = %d, want 80", fired)
+	}
+
+	// Same usage next hour - no repeat
+	fired, _ = alerter.Check(context.Background(), testOrg)
+	if fired != 0 {
+		t.Errorf("Fired on repeat check = %d, want 0", fired)
+	}
+
+	// Jump past 120% - one notice for the highest threshold
+	source.units = 125000
+	fired, _ = alerter.Check(context.Background(), testOrg)
+	if fired != 120 {
+		t.Errorf("Fired = %d, want 120", fired)
+	}
+
+	if len(notifier.sent) != 2 {
+		t.Fatalf("Sent %d notices, want 2", len(notifier.sent))
+	}
+	if notifier.sent[1].LimitReached {
+		t.Error("Expected regular alert for plan without hard cap")
+	}
+}
+
+func TestUsageAlerter_FreeTierLimitReached(t *testing.T) {
+	source := &fakeSource{units: 1000, tier: freeTier}
+	notifier := &fakeNotifier{}
+	alerter := NewUsageAlerter(source, newFakeStore(), notifier, nil)
+
+	fired, err := alerter.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if fired != 100 {
+		t.Errorf("Fired = %d, want 100", fired)
+	}
+	if len(notifier.sent) != 1 || !notifier.sent[0].LimitReached {
+		t.Fatalf("Expected a single limit reached notice, got %+v", notifier.sent)
+	}
+	if notifier.sent[0].LimitUnits != freeTier.MaxUnits {
+		t.Errorf("LimitUnits = %d, want %d", notifier.sent[0].LimitUnits, freeTier.MaxUnits)
+	}
+
+	// 120% never fires separately for a hard-capped plan
+	source.units = 1300
+	fired, _ = alerter.Check(context.Background(), testOrg)
+	if fired != 0 {
+		t.Errorf("Fired after cap = %d, want 0", fired)
+	}
+}
+
+func TestUsageAlerter_SendFailureRetries(t *testing.T) {
+	source := &fakeSource{units: 90000, tier: starterTier}
+	notifier := &fakeNotifier{err: fmt.Errorf("smtp down")}
+	alerter := NewUsageAlerter(source, newFakeStore(), notifier, nil)
+
+	if _, err := alerter.Check(context.Background(), testOrg); err == nil {
+		t.Fatal("Expected error when notification fails")
+	}
+
+	// Claim was released - next run sends
+	notifier.err = nil
+	fired, err := alerter.Check(context.Background(), testOrg)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if fired != 80 {
+		t.Errorf("Fired = %d, want 80", fired)
+	}
+}
@@ -0,0 +1,338 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// ErrRecordInvoiced is returned by RecordStore.UpsertRecord when the period was already invoiced
+var ErrRecordInvoiced = errors.New("billing record already invoiced")
+
+// LatencySource measures latency SLAs (implemented by aggregator.UsageAggregator)
+type LatencySource interface {
+	// GetLatencyBreaches counts requests in [start, end) and those slower than thresholdMs
+	GetLatencyBreaches(orgID string, start, end time.Time, thresholdMs int64) (requests, breached int64, err error)
+}
+
+// UsageSource provides billing-period usage and plans (implemented by aggregator.UsageAggregator)
+type UsageSource interface {
+	LatencySource
+	GetBillingAnchorDay(orgID string) (int, error)
+	GetMonthlyUsage(orgID string, month time.Time) (*pricing.UsageData, error)
+	GetOrganizationPlan(orgID string) (*pricing.OrganizationPlan, error)
+}
+
+// RecordStore lists billable organizations and persists their billing records
+type RecordStore interface {
+	ListOrganizationIDs(ctx context.Context) ([]string, error)
+	// UpsertRecord writes the record for its org and month, reporting whether it was inserted
+	// Returns ErrRecordInvoiced, leaving the row untouched, once the period has an invoice
+	UpsertRecord(ctx context.Context, record *Record) (bool, error)
+}
+
+// Record is one organization's priced usage for a billing period (a billing_records row)
+type Record struct {
+	OrganizationID     string
+	BillingMonth       time.Time // First day of the month the billing period starts in
+	PlanID             string
+	UsageUnits         int64
+	IncludedUnits      int64
+	OverageUnits       int64
+	BaseChargeCents    int64
+	OverageChargeCents int64
+	TrueUpChargeCents  int64
+	MetricCharges      []pricing.MetricCharge // Bandwidth and other metrics, included in SubtotalCents
+	SLACredit          *pricing.SLACredit     // Breached latency SLA, deducted from SubtotalCents
+	SubtotalCents      int64
+	DiscountCents      int64
+	TotalChargeCents   int64
+}
+
+// ComputeSummary tallies one ComputeMonth run
+type ComputeSummary struct {
+	Month    time.Time
+	Inserted int
+	Updated  int
+	Invoiced int // Left untouched because the period was already invoiced
+	Failed   int
+	Errors   []RecordError
+}
+
+// RecordError describes a failure for one organization
+type RecordError struct {
+	OrganizationID string
+	Error          error
+}
+
+// BillingRecordComputer prices each organization's usage and writes billing_records,
+// which InvoiceGenerator.GenerateMonthly turns into invoices
+type BillingRecordComputer struct {
+	source     UsageSource
+	store      RecordStore
+	calculator *pricing.Calculator
+}
+
+// NewBillingRecordComputer creates a new billing record computer
+func NewBillingRecordComputer(source UsageSource, store RecordStore, calculator *pricing.Calculator) *BillingRecordComputer {
+	return &BillingRecordComputer{
+		source:     source,
+		store:      store,
+		calculator: calculator,
+	}
+}
+
+// ComputeMonth writes the billing record of every organization whose billing period closes in month
+// Re-running is safe: records are upserted per org and month, and invoiced periods are never changed
+func (c *BillingRecordComputer) ComputeMonth(ctx context.Context, month time.Time) (*ComputeSummary, error) {
+	summary := &ComputeSummary{
+		Month:  time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC),
+		Errors: make([]RecordError, 0),
+	}
+
+	orgIDs, err := c.store.ListOrganizationIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	for _, orgID := range orgIDs {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+
+		record, err := c.ComputeRecord(orgID, summary.Month)
+		if err == nil {
+			var inserted bool
+			inserted, err = c.store.UpsertRecord(ctx, record)
+			switch {
+			case errors.Is(err, ErrRecordInvoiced):
+				summary.Invoiced++
+				continue
+			case err == nil && inserted:
+				summary.Inserted++
+			case err == nil:
+				summary.Updated++
+			}
+		}
+
+		if err != nil {
+			log.Printf("[BillingRecordComputer] ERROR: %s: %v", orgID, err)
+			summary.Failed++
+			summary.Errors = append(summary.Errors, RecordError{OrganizationID: orgID, Error: err})
+		}
+	}
+
+	return summary, nil
+}
+
+// ComputeRecord prices the organization's billing period that closes in month
+func (c *BillingRecordComputer) ComputeRecord(orgID string, month time.Time) (*Record, error) {
+	anchorDay, err := c.source.GetBillingAnchorDay(orgID)
+	if err != nil {
+		return nil, err
+	}
+	periodMonth := pricing.PeriodClosingIn(anchorDay, month)
+
+	usage, err := c.source.GetMonthlyUsage(orgID, periodMonth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	plan, err := c.source.GetOrganizationPlan(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+
+	calc, err := PriceUsage(c.source, c.calculator, *plan, *usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRecord(*plan, calc, periodMonth), nil
+}
+
+// PriceUsage prices a period's usage on the plan as its billing record is priced
+func PriceUsage(latency LatencySource, calculator *pricing.Calculator, plan pricing.OrganizationPlan, usage pricing.UsageData) (pricing.BillingCalculation, error) {
+	if err := MeasureSLA(latency, plan, &usage); err != nil {
+		return pricing.BillingCalculation{}, err
+	}
+
+	return calculator.CalculateBilling(plan, usage), nil
+}
+
+// MeasureSLA fills in the usage's SLA request counts
+// Latency is only measured for plans with an SLA, over the same period as the usage
+func MeasureSLA(latency LatencySource, plan pricing.OrganizationPlan, usage *pricing.UsageData) error {
+	sla := plan.Tier.SLA
+	if sla == nil {
+		return nil
+	}
+
+	var err error
+	usage.SLARequests, usage.SLABreachedRequests, err = latency.GetLatencyBreaches(plan.OrganizationID, usage.PeriodStart, usage.PeriodEnd, sla.LatencyThresholdMs)
+	if err != nil {
+		return fmt.Errorf("failed to get SLA latency: %w", err)
+	}
+	return nil
+}
+
+// NewRecord converts a billing calculation into the billing record written for it
+func NewRecord(plan pricing.OrganizationPlan, calc pricing.BillingCalculation, periodMonth time.Time) *Record {
+	return &Record{
+		OrganizationID:     plan.OrganizationID,
+		BillingMonth:       periodMonth,
+		PlanID:             plan.PlanID,
+		UsageUnits:         calc.UsedUnits,
+		IncludedUnits:      calc.IncludedUnits,
+		OverageUnits:       calc.OverageUnits,
+		BaseChargeCents:    calc.BasePrice,
+		OverageChargeCents: calc.OverageCharge,
+		TrueUpChargeCents:  calc.TrueUpCharge,
+		MetricCharges:      calc.MetricCharges,
+		SLACredit:          calc.SLACredit,
+		SubtotalCents:      calc.TotalCharge, // Base + overage + metrics + true-up - SLA credit, before tax
+		DiscountCents:      0,                // Coupons are applied when the invoice is created
+		TotalChargeCents:   calc.TotalCharge,
+	}
+}
+
+// DBRecordStore persists billing records in the billing_records table
+type DBRecordStore struct {
+	db *sql.DB
+}
+
+// NewDBRecordStore creates a new database-backed record store
+func NewDBRecordStore(db *sql.DB) *DBRecordStore {
+	return &DBRecordStore{db: db}
+}
+
+// ListOrganizationIDs returns every active organization, including suspended ones,
+// since their usage up to the suspension is still billed
+func (s *DBRecordStore) ListOrganizationIDs(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM organizations WHERE is_active = true ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var orgIDs []string
+	for rows.Next() {
+		var orgID string
+		if err := rows.Scan(&orgID); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgIDs = append(orgIDs, orgID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organizations: %w", err)
+	}
+
+	return orgIDs, nil
+}
+
+// UpsertRecord inserts or recalculates a pending billing record
+// The conflict update is skipped once a non-voided invoice covers the period
+func (s *DBRecordStore) UpsertRecord(ctx context.Context, record *Record) (bool, error) {
+	query := `
+		INSERT INTO billing_records (
+			organization_id, billing_month, plan_id,
+			usage_units, included_units, overage_units,
+			base_charge_cents, overage_charge_cents, true_up_charge_cents,
+			subtotal_cents, discount_cents, total_charge_cents, metric_charges, sla_credit, calculated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW())
+		ON CONFLICT (organization_id, billing_month) DO UPDATE SET
+			plan_id = EXCLUDED.plan_id,
+			usage_units = EXCLUDED.usage_units,
+			included_units = EXCLUDED.included_units,
+			overage_units = EXCLUDED.overage_units,
+			base_charge_cents = EXCLUDED.base_charge_cents,
+			overage_charge_cents = EXCLUDED.overage_charge_cents,
+			true_up_charge_cents = EXCLUDED.true_up_charge_cents,
+			subtotal_cents = EXCLUDED.subtotal_cents,
+			discount_cents = EXCLUDED.discount_cents,
+			total_charge_cents = EXCLUDED.total_charge_cents,
+			metric_charges = EXCLUDED.metric_charges,
+			sla_credit = EXCLUDED.sla_credit,
+			calculated_at = NOW()
+		WHERE billing_records.payment_status = 'pending'
+		  AND NOT EXISTS (
+			SELECT 1 FROM invoices i
+			WHERE i.organization_id = billing_records.organization_id
+			  AND i.billing_period_start >= billing_records.billing_month
+			  AND i.billing_period_start < billing_records.billing_month + INTERVAL '1 month'
+			  AND i.status <> 'voided'
+		  )
+		RETURNING (xmax = 0) AS inserted
+	`
+
+	metricCharges, err := marshalMetricCharges(record.MetricCharges)
+	if err != nil {
+		return false, err
+	}
+
+	slaCredit, err := marshalSLACredit(record.SLACredit)
+	if err != nil {
+		return false, err
+	}
+
+	var inserted bool
+	err = s.db.QueryRowContext(ctx, query,
+		record.OrganizationID,
+		record.BillingMonth,
+		record.PlanID,
+		record.UsageUnits,
+		record.IncludedUnits,
+		record.OverageUnits,
+		record.BaseChargeCents,
+		record.OverageChargeCents,
+		record.TrueUpChargeCents,
+		record.SubtotalCents,
+		record.DiscountCents,
+		record.TotalChargeCents,
+		metricCharges,
+		slaCredit,
+	).Scan(&inserted)
+
+	if err == sql.ErrNoRows {
+		// The conflict update was skipped
+		return false, ErrRecordInvoiced
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to upsert billing record: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// marshalMetricCharges encodes metric charges for the metric_charges JSONB column
+func marshalMetricCharges(charges []pricing.MetricCharge) ([]byte, error) {
+	if charges == nil {
+		charges = []pricing.MetricCharge{}
+	}
+
+	data, err := json.Marshal(charges)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metric charges: %w", err)
+	}
+	return data, nil
+}
+
+// marshalSLACredit encodes an SLA credit for the sla_credit JSONB column (NULL without one)
+func marshalSLACredit(credit *pricing.SLACredit) ([]byte, error) {
+	if credit == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(credit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SLA credit: %w", err)
+	}
+	return data, nil
+}
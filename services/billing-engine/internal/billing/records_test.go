@@ -0,0 +1,261 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// fakeSource serves usage per org and billing month, and plans per org
+type fakeSource struct {
+	anchors map[string]int
+	usage   map[string]int64 // "org|2006-01" -> billable units
+	plans   map[string]pricing.OrganizationPlan
+	latency map[string][2]int64 // org -> requests, requests slower than the threshold
+}
+
+func (f *fakeSource) GetBillingAnchorDay(orgID string) (int, error) {
+	return pricing.NormalizeAnchorDay(f.anchors[orgID]), nil
+}
+
+func (f *fakeSource) GetMonthlyUsage(orgID string, month time.Time) (*pricing.UsageData, error) {
+	return &pricing.UsageData{
+		OrganizationID: orgID,
+		Month:          month,
+		BillableUnits:  f.usage[orgID+"|"+month.Format("2006-01")],
+	}, nil
+}
+
+func (f *fakeSource) GetLatencyBreaches(orgID string, start, end time.Time, thresholdMs int64) (int64, int64, error) {
+	counts := f.latency[orgID]
+	return counts[0], counts[1], nil
+}
+
+func (f *fakeSource) GetOrganizationPlan(orgID string) (*pricing.OrganizationPlan, error) {
+	plan, ok := f.plans[orgID]
+	if !ok {
+		return nil, fmt.Errorf("no subscription found for organization: %s", orgID)
+	}
+	return &plan, nil
+}
+
+// fakeStore keeps billing records keyed like the org+month unique constraint
+type fakeStore struct {
+	orgIDs   []string
+	records  map[string]Record
+	invoiced map[string]bool
+}
+
+func newFakeStore(orgIDs ...string) *fakeStore {
+	return &fakeStore{orgIDs: orgIDs, records: make(map[string]Record), invoiced: make(map[string]bool)}
+}
+
+func (f *fakeStore) ListOrganizationIDs(ctx context.Context) ([]string, error) {
+	return f.orgIDs, nil
+}
+
+func (f *fakeStore) UpsertRecord(ctx context.Context, record *Record) (bool, error) {
+	key := record.OrganizationID + "|" + record.BillingMonth.Format("2006-01")
+	if f.invoiced[key] {
+		return false, ErrRecordInvoiced
+	}
+	_, exists := f.records[key]
+	f.records[key] = *record
+	return !exists, nil
+}
+
+var (
+	starterTier = pricing.PricingTier{Name: "Starter", BasePrice: 2900, IncludedUnits: 100000, OverageRate: 50}
+	freeTier    = pricing.PricingTier{Name: "Free", IncludedUnits: 1000, MaxUnits: 1000}
+	// Enterprise commits to $500 a month
+	enterpriseTier = pricing.PricingTier{Name: "Enterprise", BasePrice: 20000, IncludedUnits: 1000000, OverageRate: 20, MinimumChargeCents: 50000}
+)
+
+func newTestSource() *fakeSource {
+	return &fakeSource{
+		anchors: map[string]int{"org-anchored": 15},
+		usage: map[string]int64{
+			"org-starter|2026-02":    250000, // 150K over
+			"org-free|2026-02":       5000,   // Capped at 1000
+			"org-enterprise|2026-02": 400000, // Short of the commitment
+			"org-anchored|2026-01":   120000, // Jan 15 - Feb 14 closes in February
+			"org-anchored|2026-02":   999999, // Still open on Mar 1
+		},
+		plans: map[string]pricing.OrganizationPlan{
+			"org-starter":    {OrganizationID: "org-starter", PlanID: "starter", PlanName: "Starter", Tier: starterTier},
+			"org-free":       {OrganizationID: "org-free", PlanID: "free", PlanName: "Free", Tier: freeTier},
+			"org-enterprise": {OrganizationID: "org-enterprise", PlanID: "enterprise", PlanName: "Enterprise", Tier: enterpriseTier},
+			"org-anchored":   {OrganizationID: "org-anchored", PlanID: "starter", PlanName: "Starter", Tier: starterTier},
+		},
+	}
+}
+
+var feb = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+// TestComputeMonth_MatchesCalculator tests that written records carry the calculator's output
+func TestComputeMonth_MatchesCalculator(t *testing.T) {
+	source := newTestSource()
+	store := newFakeStore("org-starter", "org-free", "org-enterprise")
+	calculator := pricing.NewCalculator()
+
+	summary, err := NewBillingRecordComputer(source, store, calculator).ComputeMonth(context.Background(), feb)
+	if err != nil {
+		t.Fatalf("ComputeMonth() error = %v", err)
+	}
+	if summary.Inserted != 3 || summary.Failed != 0 {
+		t.Fatalf("Summary = %+v, want 3 inserted", summary)
+	}
+
+	for _, orgID := range store.orgIDs {
+		plan := source.plans[orgID]
+		usage, _ := source.GetMonthlyUsage(orgID, feb)
+		calc := calculator.CalculateBilling(plan, *usage)
+
+		record, ok := store.records[orgID+"|2026-02"]
+		if !ok {
+			t.Fatalf("%s: no billing record written for 2026-02", orgID)
+		}
+		if record.PlanID != plan.PlanID || record.UsageUnits != calc.UsedUnits || record.IncludedUnits != calc.IncludedUnits ||
+			record.OverageUnits != calc.OverageUnits {
+			t.Errorf("%s: units = %+v, want calculator's %+v", orgID, record, calc)
+		}
+		if record.BaseChargeCents != calc.BasePrice || record.OverageChargeCents != calc.OverageCharge ||
+			record.TrueUpChargeCents != calc.TrueUpCharge || record.TotalChargeCents != calc.TotalCharge {
+			t.Errorf("%s: charges = %+v, want calculator's %+v", orgID, record, calc)
+		}
+		if record.SubtotalCents != record.BaseChargeCents+record.OverageChargeCents+record.TrueUpChargeCents {
+			t.Errorf("%s: subtotal %d is not base + overage + true-up", orgID, record.SubtotalCents)
+		}
+	}
+
+	// Spot-check the interesting cases against hand-computed values
+	if r := store.records["org-starter|2026-02"]; r.OverageUnits != 150000 || r.TotalChargeCents != 10400 {
+		t.Errorf("Starter record = %d overage units, %d cents, want 150000 and 10400", r.OverageUnits, r.TotalChargeCents)
+	}
+	if r := store.records["org-free|2026-02"]; r.UsageUnits != 5000 || r.OverageUnits != 0 || r.TotalChargeCents != 0 {
+		t.Errorf("Free record = %+v, want 5000 units used, no overage, no charge", r)
+	}
+	if r := store.records["org-enterprise|2026-02"]; r.TrueUpChargeCents != 30000 || r.TotalChargeCents != 50000 {
+		t.Errorf("Enterprise record = %+v, want a $300 true-up to the $500 minimum", r)
+	}
+}
+
+// TestComputeMonth_Idempotent tests that a re-run updates the same rows
+func TestComputeMonth_Idempotent(t *testing.T) {
+	source := newTestSource()
+	store := newFakeStore("org-starter", "org-free")
+	computer := NewBillingRecordComputer(source, store, pricing.NewCalculator())
+
+	if _, err := computer.ComputeMonth(context.Background(), feb); err != nil {
+		t.Fatalf("ComputeMonth() error = %v", err)
+	}
+
+	// Late-arriving usage is picked up by the re-run
+	source.usage["org-starter|2026-02"] = 300000
+	summary, err := computer.ComputeMonth(context.Background(), feb)
+	if err != nil {
+		t.Fatalf("ComputeMonth() error = %v", err)
+	}
+
+	if summary.Inserted != 0 || summary.Updated != 2 || len(store.records) != 2 {
+		t.Errorf("Re-run = %+v with %d records, want 2 updated, 2 records", summary, len(store.records))
+	}
+	if r := store.records["org-starter|2026-02"]; r.UsageUnits != 300000 {
+		t.Errorf("Starter usage = %d, want the recalculated 300000", r.UsageUnits)
+	}
+}
+
+// TestComputeMonth_SkipsInvoiced tests that invoiced periods are left as billed
+func TestComputeMonth_SkipsInvoiced(t *testing.T) {
+	store := newFakeStore("org-starter", "org-free")
+	store.invoiced["org-starter|2026-02"] = true
+
+	summary, err := NewBillingRecordComputer(newTestSource(), store, pricing.NewCalculator()).ComputeMonth(context.Background(), feb)
+	if err != nil {
+		t.Fatalf("ComputeMonth() error = %v", err)
+	}
+
+	if summary.Invoiced != 1 || summary.Inserted != 1 || summary.Failed != 0 {
+		t.Errorf("Summary = %+v, want 1 invoiced and 1 inserted", summary)
+	}
+	if _, ok := store.records["org-starter|2026-02"]; ok {
+		t.Error("Invoiced period was rewritten")
+	}
+}
+
+// TestComputeMonth_AnchoredPeriod tests that anchored orgs are billed for the period closing in the month
+func TestComputeMonth_AnchoredPeriod(t *testing.T) {
+	store := newFakeStore("org-anchored")
+
+	if _, err := NewBillingRecordComputer(newTestSource(), store, pricing.NewCalculator()).ComputeMonth(context.Background(), feb); err != nil {
+		t.Fatalf("ComputeMonth() error = %v", err)
+	}
+
+	record, ok := store.records["org-anchored|2026-01"]
+	if !ok {
+		t.Fatalf("Records = %v, want the period starting Jan 15", store.records)
+	}
+	if record.UsageUnits != 120000 || record.OverageUnits != 20000 {
+		t.Errorf("Anchored record = %+v, want 120000 units with 20000 over", record)
+	}
+	if _, ok := store.records["org-anchored|2026-02"]; ok {
+		t.Error("The still-open period starting Feb 15 was billed")
+	}
+}
+
+// TestComputeMonth_ContinuesAfterError tests that one failing org does not block the rest
+func TestComputeMonth_ContinuesAfterError(t *testing.T) {
+	store := newFakeStore("org-unsubscribed", "org-starter")
+
+	summary, err := NewBillingRecordComputer(newTestSource(), store, pricing.NewCalculator()).ComputeMonth(context.Background(), feb)
+	if err != nil {
+		t.Fatalf("ComputeMonth() error = %v", err)
+	}
+
+	if summary.Failed != 1 || summary.Inserted != 1 {
+		t.Fatalf("Summary = %+v, want 1 failed and 1 inserted", summary)
+	}
+	if summary.Errors[0].OrganizationID != "org-unsubscribed" || errors.Is(summary.Errors[0].Error, ErrRecordInvoiced) {
+		t.Errorf("Errors = %+v, want the unsubscribed org's plan error", summary.Errors)
+	}
+}
+
+// TestComputeMonth_SLACredit tests that a period breaching the plan's latency SLA is credited
+// and one meeting it is billed in full
+func TestComputeMonth_SLACredit(t *testing.T) {
+	slaTier := starterTier
+	slaTier.SLA = &pricing.SLAPolicy{LatencyThresholdMs: 500, MaxBreachPercent: 1, CreditPercent: 10}
+
+	source := newTestSource()
+	source.usage["org-breached|2026-02"] = 250000
+	source.usage["org-met|2026-02"] = 250000
+	source.plans["org-breached"] = pricing.OrganizationPlan{OrganizationID: "org-breached", PlanID: "starter-sla", PlanName: "Starter", Tier: slaTier}
+	source.plans["org-met"] = pricing.OrganizationPlan{OrganizationID: "org-met", PlanID: "starter-sla", PlanName: "Starter", Tier: slaTier}
+	source.latency = map[string][2]int64{
+		"org-breached": {200000, 5000}, // 2.5% over 500ms
+		"org-met":      {200000, 2000}, // Exactly the 1% allowed
+	}
+	store := newFakeStore("org-breached", "org-met")
+
+	if _, err := NewBillingRecordComputer(source, store, pricing.NewCalculator()).ComputeMonth(context.Background(), feb); err != nil {
+		t.Fatalf("ComputeMonth() error = %v", err)
+	}
+
+	// $104.00 of base and overage, 10% credited
+	breached := store.records["org-breached|2026-02"]
+	if breached.SLACredit == nil || breached.SLACredit.Credit != 1040 || breached.SLACredit.BreachPercent != 2.5 {
+		t.Fatalf("Breached SLA credit = %+v, want 1040 cents for a 2.5%% breach rate", breached.SLACredit)
+	}
+	if breached.SubtotalCents != 9360 || breached.TotalChargeCents != 9360 {
+		t.Errorf("Breached subtotal = %d, total = %d, want 9360 after the credit", breached.SubtotalCents, breached.TotalChargeCents)
+	}
+
+	met := store.records["org-met|2026-02"]
+	if met.SLACredit != nil || met.SubtotalCents != 10400 {
+		t.Errorf("Met SLA record = %+v, want no credit and the full 10400", met)
+	}
+}
@@ -2,11 +2,24 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/robfig/cron/v3"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/alerts"
 	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/notify"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// Jobs that can be run with RUN_ONCE / --once
+const (
+	RunJobAggregate = "aggregate" // Hourly usage aggregation for the previous hour
+	RunJobInvoice   = "invoice"   // Monthly billing for the process month
 )
 
 // Config holds the configuration for the billing engine
@@ -16,13 +29,42 @@ type Config struct {
 	MaxConnections int
 
 	// Billing settings
-	RunSchedule    string // Cron expression (default: "0 0 1 * *" = 1st of month at midnight)
-	ProcessMonth   string // "previous" or "current"
+	RunSchedule    string // Monthly billing job cron expression (default: "0 0 1 * *" = 1st of month at midnight)
+	HourlySchedule string // Hourly aggregation cron expression (default: "0 * * * *" = every hour at :00)
+	Timezone       string // IANA timezone job schedules run in (default: "UTC")
+	ProcessMonth   string // "previous", "current" or a month as "YYYY-MM"
 	DryRun         bool   // If true, calculate but don't save
+	DefaultPlanID  string // Plan for orgs without a subscription ("" disables fallback)
+
+	PlanRefreshInterval time.Duration // How often pricing_plans is reloaded
+
+	OverageRounding pricing.RoundingMode // How fractional cents of usage charges are rounded
+
+	// Single-run mode: run RunJob once and exit instead of starting the scheduler
+	RunOnce bool
+	RunJob  string // RunJobAggregate or RunJobInvoice
 
 	// Notification settings
-	NotifyOnCompletion bool
-	NotifyEmail        string
+	NotifyOnCompletion     bool
+	NotifyEmail            string
+	NotifyWebhookURL       string // Webhook for run results, e.g. a Slack incoming webhook ("" disables)
+	NotifyWebhookFormat    string // notify.FormatJSON or notify.FormatSlack
+	NotifyFailureThreshold int    // Errors per run above which a failure alert is sent
+
+	// Usage alert settings
+	UsageAlertsEnabled   bool
+	UsageAlertThresholds []int // Percent of plan limit (e.g., 80, 100, 120)
+
+	// Usage anomaly settings (checked after each hourly aggregation)
+	AnomalyDetectionEnabled bool
+	AnomalyMultiple         float64 // Month-to-date usage / expected usage that counts as an anomaly
+	AnomalyBaselineMonths   int     // Complete months averaged into the expected usage
+	AnomalyMinUnits         int64   // Month-to-date units below which nothing is flagged
+	AnomalyAlertEmail       string  // Operator address for anomaly alerts ("" records without emailing)
+
+	// Admin API settings
+	AdminPort     string // Port of the admin/status API ("" disables it)
+	AdminAPIToken string // Shared secret for POST /run/invoices ("" disables ad-hoc runs)
 
 	// Invoice configuration
 	InvoiceConfig invoice.InvoiceConfig
@@ -40,19 +82,56 @@ func LoadConfig() (*Config, error) {
 
 		// Billing defaults
 		RunSchedule:    getEnv("BILLING_SCHEDULE", "0 0 1 * *"), // 1st of month at midnight
+		HourlySchedule: getEnv("HOURLY_SCHEDULE", "0 * * * *"),  // Every hour at :00
+		Timezone:       getEnv("BILLING_TIMEZONE", "UTC"),
 		ProcessMonth:   getEnv("BILLING_PROCESS_MONTH", "previous"),
 		DryRun:         getEnvBool("BILLING_DRY_RUN", false),
+		DefaultPlanID:  getEnv("DEFAULT_PLAN_ID", "free"),
+
+		PlanRefreshInterval: getEnvDuration("PLAN_REFRESH_INTERVAL", pricing.DefaultPlanRefreshInterval),
+
+		OverageRounding: pricing.RoundingMode(getEnv("OVERAGE_ROUNDING", string(pricing.DefaultRoundingMode))),
+
+		// Single-run defaults
+		RunOnce: getEnvBool("RUN_ONCE", false),
+		RunJob:  getEnv("RUN_JOB", RunJobInvoice),
 
 		// Notification defaults
-		NotifyOnCompletion: getEnvBool("BILLING_NOTIFY", false),
-		NotifyEmail:        getEnv("BILLING_NOTIFY_EMAIL", ""),
+		NotifyOnCompletion:     getEnvBool("BILLING_NOTIFY", false),
+		NotifyEmail:            getEnv("BILLING_NOTIFY_EMAIL", ""),
+		NotifyWebhookURL:       getEnv("BILLING_NOTIFY_WEBHOOK_URL", ""),
+		NotifyWebhookFormat:    getEnv("BILLING_NOTIFY_WEBHOOK_FORMAT", notify.FormatJSON),
+		NotifyFailureThreshold: getEnvInt("BILLING_NOTIFY_FAILURE_THRESHOLD", 0),
+
+		// Usage alert defaults
+		UsageAlertsEnabled:   getEnvBool("USAGE_ALERTS_ENABLED", false),
+		UsageAlertThresholds: getEnvIntList("USAGE_ALERT_THRESHOLDS", []int{80, 100, 120}),
+
+		// Usage anomaly defaults
+		AnomalyDetectionEnabled: getEnvBool("USAGE_ANOMALY_DETECTION", false),
+		AnomalyMultiple:         getEnvFloat("USAGE_ANOMALY_MULTIPLE", alerts.DefaultAnomalyMultiple),
+		AnomalyBaselineMonths:   getEnvInt("USAGE_ANOMALY_BASELINE_MONTHS", alerts.DefaultAnomalyBaselineMonths),
+		AnomalyMinUnits:         int64(getEnvInt("USAGE_ANOMALY_MIN_UNITS", alerts.DefaultAnomalyMinUnits)),
+		AnomalyAlertEmail:       getEnv("USAGE_ANOMALY_ALERT_EMAIL", ""),
+
+		// Admin API defaults
+		AdminPort:     getEnv("ADMIN_PORT", "8082"),
+		AdminAPIToken: os.Getenv("ADMIN_API_TOKEN"),
 
 		// Invoice configuration
 		InvoiceConfig: invoice.InvoiceConfig{
 			// S3 storage
-			S3Bucket:   getEnv("S3_BUCKET", "saas-invoices"),
-			S3Region:   getEnv("S3_REGION", "us-east-1"),
-			S3Endpoint: getEnv("S3_ENDPOINT", ""), // For MinIO
+			S3Bucket:       getEnv("S3_BUCKET", "saas-invoices"),
+			S3Region:       getEnv("S3_REGION", "us-east-1"),
+			S3Endpoint:     getEnv("S3_ENDPOINT", ""), // For MinIO
+			S3UsePathStyle: getEnvBool("S3_USE_PATH_STYLE", false),
+
+			// S3 retention (invoices are legal documents: kept indefinitely unless configured)
+			S3TransitionDays:       getEnvInt("S3_TRANSITION_DAYS", 0),
+			S3ExpirationDays:       getEnvInt("S3_EXPIRATION_DAYS", 0),
+			S3ApplyLifecycle:       getEnvBool("S3_APPLY_LIFECYCLE", false),
+			VoidedPDFRetentionDays: getEnvInt("VOIDED_PDF_RETENTION_DAYS", 0),
+			PDFLinkExpiration:      getEnvDuration("PDF_LINK_EXPIRATION", invoice.DefaultPresignExpiration),
 
 			// Stripe
 			StripeAPIKey:  getEnv("STRIPE_API_KEY", ""),
@@ -66,6 +145,9 @@ func LoadConfig() (*Config, error) {
 			FromEmail:    getEnv("FROM_EMAIL", "billing@example.com"),
 			FromName:     getEnv("FROM_NAME", "Billing Team"),
 
+			EmailMaxRetries:    getEnvInt("EMAIL_MAX_RETRIES", invoice.DefaultEmailMaxRetries),
+			EmailRetryInterval: getEnvDuration("EMAIL_RETRY_INTERVAL", invoice.DefaultEmailRetryInterval),
+
 			// Invoice settings
 			CompanyName:    getEnv("COMPANY_NAME", "SaaS Company"),
 			CompanyAddress: getEnv("COMPANY_ADDRESS", "123 Main St, City, State 12345"),
@@ -74,12 +156,18 @@ func LoadConfig() (*Config, error) {
 			CompanyLogo:    getEnv("COMPANY_LOGO", ""),
 			TaxRate:        getEnvFloat("TAX_RATE", 0.0), // e.g., 0.08 for 8%
 			PaymentTerms:   getEnvInt("PAYMENT_TERMS_DAYS", 30), // Net 30
+			UsageUnitLabel: getEnv("USAGE_UNIT_LABEL", invoice.DefaultUsageUnitLabel),
+
+			GenerationWorkers:   getEnvInt("INVOICE_WORKERS", 0), // 0 = GOMAXPROCS
+			LineItemGrouping:    invoice.LineItemGrouping(getEnv("INVOICE_LINE_ITEM_GROUPING", string(invoice.DefaultLineItemGrouping))),
+			PDFMaxAddressLength: getEnvInt("PDF_MAX_ADDRESS_LENGTH", invoice.DefaultMaxAddressLength),
 
 			// Feature flags
-			EnableStripe: getEnvBool("ENABLE_STRIPE", false),
-			EnableEmail:  getEnvBool("ENABLE_EMAIL", false),
-			EnableS3:     getEnvBool("ENABLE_S3", false),
-			EnableTax:    getEnvBool("ENABLE_TAX", false),
+			EnableStripe:        getEnvBool("ENABLE_STRIPE", false),
+			EnableStripeConnect: getEnvBool("ENABLE_STRIPE_CONNECT", false),
+			EnableEmail:         getEnvBool("ENABLE_EMAIL", false),
+			EnableS3:            getEnvBool("ENABLE_S3", false),
+			EnableTax:           getEnvBool("ENABLE_TAX", false),
 		},
 
 		// Logging
@@ -103,19 +191,109 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("DB_MAX_CONNECTIONS must be between 1 and 100")
 	}
 
+	loc, err := c.Location()
+	if err != nil {
+		return fmt.Errorf("BILLING_TIMEZONE is invalid: %w", err)
+	}
+
+	for name, spec := range map[string]string{"BILLING_SCHEDULE": c.RunSchedule, "HOURLY_SCHEDULE": c.HourlySchedule} {
+		if _, err := c.NextRun(spec, time.Now().In(loc)); err != nil {
+			return fmt.Errorf("%s is invalid: %w", name, err)
+		}
+	}
+
 	if c.ProcessMonth != "previous" && c.ProcessMonth != "current" {
-		return fmt.Errorf("BILLING_PROCESS_MONTH must be 'previous' or 'current'")
+		if _, err := time.Parse("2006-01", c.ProcessMonth); err != nil {
+			return fmt.Errorf("BILLING_PROCESS_MONTH must be 'previous', 'current' or a month as YYYY-MM")
+		}
+	}
+
+	if _, err := pricing.ParseRoundingMode(string(c.OverageRounding)); err != nil {
+		return fmt.Errorf("OVERAGE_ROUNDING is invalid: %w", err)
+	}
+
+	if _, err := invoice.ParseLineItemGrouping(string(c.InvoiceConfig.LineItemGrouping)); err != nil {
+		return fmt.Errorf("INVOICE_LINE_ITEM_GROUPING is invalid: %w", err)
+	}
+
+	if c.RunJob != RunJobAggregate && c.RunJob != RunJobInvoice {
+		return fmt.Errorf("RUN_JOB must be '%s' or '%s'", RunJobAggregate, RunJobInvoice)
 	}
 
 	if c.NotifyOnCompletion && c.NotifyEmail == "" {
 		return fmt.Errorf("BILLING_NOTIFY_EMAIL required when BILLING_NOTIFY is true")
 	}
 
+	if c.NotifyOnCompletion && !c.InvoiceConfig.EnableEmail {
+		return fmt.Errorf("ENABLE_EMAIL required when BILLING_NOTIFY is true")
+	}
+
+	if c.NotifyWebhookURL != "" {
+		if u, err := url.Parse(c.NotifyWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("BILLING_NOTIFY_WEBHOOK_URL must be an http(s) URL")
+		}
+		if c.NotifyWebhookFormat != notify.FormatJSON && c.NotifyWebhookFormat != notify.FormatSlack {
+			return fmt.Errorf("BILLING_NOTIFY_WEBHOOK_FORMAT must be '%s' or '%s'", notify.FormatJSON, notify.FormatSlack)
+		}
+	}
+
+	if c.AdminPort != "" {
+		if port, err := strconv.Atoi(c.AdminPort); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("ADMIN_PORT must be a port number")
+		}
+	}
+
+	if c.NotifyFailureThreshold < 0 {
+		return fmt.Errorf("BILLING_NOTIFY_FAILURE_THRESHOLD must not be negative")
+	}
+
+	if c.UsageAlertsEnabled {
+		if len(c.UsageAlertThresholds) == 0 {
+			return fmt.Errorf("USAGE_ALERT_THRESHOLDS required when USAGE_ALERTS_ENABLED is true")
+		}
+		for _, threshold := range c.UsageAlertThresholds {
+			if threshold < 1 || threshold > 1000 {
+				return fmt.Errorf("USAGE_ALERT_THRESHOLDS values must be between 1 and 1000")
+			}
+		}
+	}
+
+	if c.AnomalyDetectionEnabled {
+		if c.AnomalyMultiple <= 1 {
+			return fmt.Errorf("USAGE_ANOMALY_MULTIPLE must be greater than 1")
+		}
+		if c.AnomalyBaselineMonths < 1 || c.AnomalyBaselineMonths > 24 {
+			return fmt.Errorf("USAGE_ANOMALY_BASELINE_MONTHS must be between 1 and 24")
+		}
+		if c.AnomalyMinUnits < 0 {
+			return fmt.Errorf("USAGE_ANOMALY_MIN_UNITS must not be negative")
+		}
+		if c.AnomalyAlertEmail != "" && !c.InvoiceConfig.EnableEmail {
+			return fmt.Errorf("ENABLE_EMAIL required when USAGE_ANOMALY_ALERT_EMAIL is set")
+		}
+	}
+
 	// Validate invoice config
 	if c.InvoiceConfig.EnableS3 && c.InvoiceConfig.S3Bucket == "" {
 		return fmt.Errorf("S3_BUCKET required when ENABLE_S3 is true")
 	}
 
+	if c.InvoiceConfig.S3TransitionDays < 0 || c.InvoiceConfig.S3ExpirationDays < 0 || c.InvoiceConfig.VoidedPDFRetentionDays < 0 {
+		return fmt.Errorf("S3_TRANSITION_DAYS, S3_EXPIRATION_DAYS and VOIDED_PDF_RETENTION_DAYS must not be negative")
+	}
+
+	if e := c.InvoiceConfig.PDFLinkExpiration; e <= 0 || e > invoice.MaxPresignExpiration {
+		return fmt.Errorf("PDF_LINK_EXPIRATION must be between 1s and %s", invoice.MaxPresignExpiration)
+	}
+
+	// S3 only moves objects to Standard-IA after 30 days, and must do so before they expire
+	if t := c.InvoiceConfig.S3TransitionDays; t > 0 && t < 30 {
+		return fmt.Errorf("S3_TRANSITION_DAYS must be at least 30")
+	}
+	if e := c.InvoiceConfig.S3ExpirationDays; e > 0 && e <= c.InvoiceConfig.S3TransitionDays {
+		return fmt.Errorf("S3_EXPIRATION_DAYS must be greater than S3_TRANSITION_DAYS")
+	}
+
 	if c.InvoiceConfig.EnableStripe && c.InvoiceConfig.StripeAPIKey == "" {
 		return fmt.Errorf("STRIPE_API_KEY required when ENABLE_STRIPE is true")
 	}
@@ -135,6 +313,10 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.InvoiceConfig.EmailMaxRetries < 0 || c.InvoiceConfig.EmailMaxRetries > 10 {
+		return fmt.Errorf("EMAIL_MAX_RETRIES must be between 0 and 10")
+	}
+
 	if c.InvoiceConfig.TaxRate < 0 || c.InvoiceConfig.TaxRate > 1 {
 		return fmt.Errorf("TAX_RATE must be between 0 and 1 (e.g., 0.08 for 8%%)")
 	}
@@ -146,15 +328,73 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// ApplyRunFlags overrides the single-run settings with command-line flags and revalidates
+// Empty flags keep the values loaded from the environment
+func (c *Config) ApplyRunFlags(once bool, job, month string) error {
+	if once {
+		c.RunOnce = true
+	}
+	if job != "" {
+		c.RunJob = job
+	}
+	if month != "" {
+		c.ProcessMonth = month
+	}
+	return c.Validate()
+}
+
+// ScheduleParser parses job schedules: classic 5-field specs, 6-field specs with seconds,
+// descriptors such as "@every 15m", and an optional "CRON_TZ=Europe/Berlin" prefix
+var ScheduleParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Location returns the timezone job schedules run in
+func (c *Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// NextRun returns when a schedule next fires after from, in the configured timezone
+// unless the spec carries its own CRON_TZ
+func (c *Config) NextRun(spec string, from time.Time) (time.Time, error) {
+	loc, err := c.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	schedule, err := ScheduleParser.Parse(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Specs without CRON_TZ fire in the time zone of the time they are given, like cron.WithLocation
+	return schedule.Next(from.In(loc)), nil
+}
+
 // GetProcessMonth returns the month to process based on configuration
+// The month is taken in the configured timezone, so a run at midnight on the 1st in
+// Asia/Tokyo bills the month that just ended there, not the one still running in UTC.
+// An explicit "YYYY-MM" month is returned as is
 func (c *Config) GetProcessMonth() time.Time {
-	now := time.Now()
+	if month, err := time.Parse("2006-01", c.ProcessMonth); err == nil {
+		return month
+	}
+
+	loc, err := c.Location()
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
 
 	if c.ProcessMonth == "previous" {
-		return now.AddDate(0, -1, 0)
+		return month.AddDate(0, -1, 0)
 	}
 
-	return now
+	return month
 }
 
 // Helper functions
@@ -175,6 +415,23 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvIntList(key string, defaultValue []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		intVal, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return defaultValue
+		}
+		result = append(result, intVal)
+	}
+	return result
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
@@ -192,3 +449,12 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
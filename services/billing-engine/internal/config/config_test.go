@@ -0,0 +1,207 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Config {
+	return &Config{
+		DatabaseURL:    "postgres://localhost/billing",
+		MaxConnections: 10,
+		RunSchedule:    "0 0 1 * *",
+		HourlySchedule: "0 * * * *",
+		Timezone:       "UTC",
+		ProcessMonth:   "previous",
+		RunJob:         RunJobInvoice,
+		InvoiceConfig: invoice.InvoiceConfig{
+			PaymentTerms:      30,
+			PDFLinkExpiration: invoice.DefaultPresignExpiration,
+		},
+	}
+}
+
+func TestValidate_Schedules(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Validate() error = %v, want a valid base config", err)
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string // Empty when the config is valid
+	}{
+		{"6-field spec with seconds", func(c *Config) { c.RunSchedule = "0 0 0 1 * *" }, ""},
+		{"Descriptor", func(c *Config) { c.HourlySchedule = "@hourly" }, ""},
+		{"Spec with its own timezone", func(c *Config) { c.RunSchedule = "CRON_TZ=Europe/Berlin 0 0 1 * *" }, ""},
+		{"Too few fields", func(c *Config) { c.RunSchedule = "0 0 1 *" }, "BILLING_SCHEDULE"},
+		{"Out-of-range field", func(c *Config) { c.HourlySchedule = "0 25 * * *" }, "HOURLY_SCHEDULE"},
+		{"Garbage", func(c *Config) { c.HourlySchedule = "every hour" }, "HOURLY_SCHEDULE"},
+		{"Unknown spec timezone", func(c *Config) { c.RunSchedule = "CRON_TZ=Mars/Olympus 0 0 1 * *" }, "BILLING_SCHEDULE"},
+		{"Unknown timezone", func(c *Config) { c.Timezone = "Mars/Olympus" }, "BILLING_TIMEZONE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.mutate(c)
+
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want one naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_AnomalyDetection(t *testing.T) {
+	anomalyConfig := func() *Config {
+		c := validConfig()
+		c.AnomalyDetectionEnabled = true
+		c.AnomalyMultiple = 10
+		c.AnomalyBaselineMonths = 3
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string // Empty when the config is valid
+	}{
+		{"Defaults", func(c *Config) {}, ""},
+		{"Multiple of 1", func(c *Config) { c.AnomalyMultiple = 1 }, "USAGE_ANOMALY_MULTIPLE"},
+		{"No baseline months", func(c *Config) { c.AnomalyBaselineMonths = 0 }, "USAGE_ANOMALY_BASELINE_MONTHS"},
+		{"Negative minimum", func(c *Config) { c.AnomalyMinUnits = -1 }, "USAGE_ANOMALY_MIN_UNITS"},
+		{"Alert email without email", func(c *Config) { c.AnomalyAlertEmail = "ops@example.com" }, "ENABLE_EMAIL"},
+		{"Disabled ignores settings", func(c *Config) { c.AnomalyDetectionEnabled = false; c.AnomalyMultiple = 0 }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := anomalyConfig()
+			tt.mutate(c)
+
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want one naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_OverageRounding(t *testing.T) {
+	for _, mode := range []pricing.RoundingMode{"", pricing.RoundDown, pricing.RoundHalfUp, pricing.RoundHalfEven} {
+		c := validConfig()
+		c.OverageRounding = mode
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate() with OVERAGE_ROUNDING=%q error = %v, want nil", mode, err)
+		}
+	}
+
+	c := validConfig()
+	c.OverageRounding = "nearest"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "OVERAGE_ROUNDING") {
+		t.Errorf("Validate() error = %v, want one naming OVERAGE_ROUNDING", err)
+	}
+}
+
+func TestValidate_LineItemGrouping(t *testing.T) {
+	for _, grouping := range []invoice.LineItemGrouping{"", invoice.GroupNone, invoice.GroupByType, invoice.GroupByMetric} {
+		c := validConfig()
+		c.InvoiceConfig.LineItemGrouping = grouping
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate() with INVOICE_LINE_ITEM_GROUPING=%q error = %v, want nil", grouping, err)
+		}
+	}
+
+	c := validConfig()
+	c.InvoiceConfig.LineItemGrouping = "by_day"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "INVOICE_LINE_ITEM_GROUPING") {
+		t.Errorf("Validate() error = %v, want one naming INVOICE_LINE_ITEM_GROUPING", err)
+	}
+}
+
+func TestNextRun_Timezone(t *testing.T) {
+	c := validConfig()
+	c.Timezone = "America/New_York"
+	from := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	next, err := c.NextRun(c.RunSchedule, from)
+	if err != nil {
+		t.Fatalf("NextRun() error = %v", err)
+	}
+
+	// Midnight on Feb 1 in New York is 05:00 UTC (EST)
+	if want := time.Date(2026, 2, 1, 5, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextRun() = %v, want %v", next.UTC(), want)
+	}
+	if next.Location().String() != "America/New_York" {
+		t.Errorf("NextRun() location = %s, want America/New_York", next.Location())
+	}
+
+	// A spec's own CRON_TZ wins over the configured timezone
+	next, err = c.NextRun("CRON_TZ=Asia/Tokyo 0 0 1 * *", from)
+	if err != nil {
+		t.Fatalf("NextRun() error = %v", err)
+	}
+	if want := time.Date(2026, 1, 31, 15, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextRun(CRON_TZ=Asia/Tokyo) = %v, want %v", next.UTC(), want)
+	}
+}
+
+func TestNextRun_DefaultsToUTC(t *testing.T) {
+	c := validConfig()
+	c.Timezone = ""
+
+	next, err := c.NextRun(c.HourlySchedule, time.Date(2026, 1, 15, 12, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("NextRun() error = %v", err)
+	}
+	if want := time.Date(2026, 1, 15, 13, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextRun() = %v, want %v", next, want)
+	}
+}
+
+func TestApplyRunFlags(t *testing.T) {
+	c := validConfig()
+	if err := c.ApplyRunFlags(true, RunJobAggregate, ""); err != nil {
+		t.Fatalf("ApplyRunFlags() error = %v", err)
+	}
+	if !c.RunOnce || c.RunJob != RunJobAggregate || c.ProcessMonth != "previous" {
+		t.Errorf("ApplyRunFlags() = once %v, job %q, month %q, want once aggregate with the month unchanged",
+			c.RunOnce, c.RunJob, c.ProcessMonth)
+	}
+
+	if err := validConfig().ApplyRunFlags(true, "reconcile", ""); err == nil || !strings.Contains(err.Error(), "RUN_JOB") {
+		t.Errorf("ApplyRunFlags(job=reconcile) error = %v, want RUN_JOB error", err)
+	}
+	if err := validConfig().ApplyRunFlags(true, "", "2026-13"); err == nil || !strings.Contains(err.Error(), "BILLING_PROCESS_MONTH") {
+		t.Errorf("ApplyRunFlags(month=2026-13) error = %v, want BILLING_PROCESS_MONTH error", err)
+	}
+}
+
+func TestGetProcessMonth_ExplicitMonth(t *testing.T) {
+	c := validConfig()
+	c.ProcessMonth = "2026-03"
+
+	if got, want := c.GetProcessMonth(), time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("GetProcessMonth() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Consistency issue types
+const (
+	IssueMissingInvoice = "missing_invoice" // Billing record with no invoice
+	IssueOrphanInvoice  = "orphan_invoice"  // Invoice with no billing record
+)
+
+// ConsistencyIssue describes a single billing record / invoice mismatch
+type ConsistencyIssue struct {
+	OrganizationID string `json:"organization_id"`
+	Type           string `json:"type"`
+}
+
+// ConsistencyReport summarizes the consistency check for a billing month
+type ConsistencyReport struct {
+	BillingMonth   time.Time          `json:"billing_month"`
+	BillingRecords int                `json:"billing_records"`
+	Invoices       int                `json:"invoices"`
+	Issues         []ConsistencyIssue `json:"issues"`
+}
+
+// IsConsistent reports whether every billing record has an invoice and vice versa
+func (r *ConsistencyReport) IsConsistent() bool {
+	return len(r.Issues) == 0
+}
+
+// VerifyMonth compares the billing records whose periods close in a month against generated invoices
+// Voided billing records and voided invoices are excluded on both sides, and child organizations'
+// records are matched against their parent's consolidated invoice
+func (g *InvoiceGenerator) VerifyMonth(ctx context.Context, month time.Time) (*ConsistencyReport, error) {
+	billingMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	records, err := g.getBillingRecordsForMonth(ctx, billingMonth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing records: %w", err)
+	}
+
+	// Children are expected on their parent's consolidated invoice
+	parents, err := g.getParentOrganizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parent organizations: %w", err)
+	}
+	standalone, groups := splitConsolidated(records, parents)
+
+	recordOrgs := make([]string, 0, len(standalone)+len(groups))
+	for _, record := range standalone {
+		recordOrgs = append(recordOrgs, record.OrganizationID)
+	}
+	recordOrgs = append(recordOrgs, parentIDs(groups)...)
+
+	// Invoice periods end one second before the next period starts
+	invoiceOrgs, err := g.queryOrganizationIDs(ctx, `
+		SELECT DISTINCT organization_id
+		FROM invoices
+		WHERE billing_period_end >= $1
+		  AND billing_period_end < $2
+		  AND status != 'voided'
+	`, billingMonth, billingMonth.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+
+	return CompareBillingRecordsToInvoices(billingMonth, recordOrgs, invoiceOrgs), nil
+}
+
+// CompareBillingRecordsToInvoices reports organizations present on only one side
+// Issues are sorted by organization ID for stable output
+func CompareBillingRecordsToInvoices(month time.Time, recordOrgIDs, invoiceOrgIDs []string) *ConsistencyReport {
+	report := &ConsistencyReport{
+		BillingMonth: month,
+		Issues:       make([]ConsistencyIssue, 0),
+	}
+
+	records := toSet(recordOrgIDs)
+	invoices := toSet(invoiceOrgIDs)
+	report.BillingRecords = len(records)
+	report.Invoices = len(invoices)
+
+	for orgID := range records {
+		if !invoices[orgID] {
+			report.Issues = append(report.Issues, ConsistencyIssue{OrganizationID: orgID, Type: IssueMissingInvoice})
+		}
+	}
+	for orgID := range invoices {
+		if !records[orgID] {
+			report.Issues = append(report.Issues, ConsistencyIssue{OrganizationID: orgID, Type: IssueOrphanInvoice})
+		}
+	}
+
+	sort.Slice(report.Issues, func(i, j int) bool {
+		if report.Issues[i].OrganizationID != report.Issues[j].OrganizationID {
+			return report.Issues[i].OrganizationID < report.Issues[j].OrganizationID
+		}
+		return report.Issues[i].Type < report.Issues[j].Type
+	})
+
+	return report
+}
+
+// queryOrganizationIDs runs a query returning a single organization_id column
+func (g *InvoiceGenerator) queryOrganizationIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := g.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// toSet converts a slice of IDs to a set
+func toSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,93 @@
+package invoice
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestCompareBillingRecordsToInvoices tests mismatch detection between billing records and invoices
+func TestCompareBillingRecordsToInvoices(t *testing.T) {
+	month := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		recordOrgIDs   []string
+		invoiceOrgIDs  []string
+		expectedIssues []ConsistencyIssue
+	}{
+		{
+			name:           "Clean month",
+			recordOrgIDs:   []string{"org-1", "org-2", "org-3"},
+			invoiceOrgIDs:  []string{"org-3", "org-1", "org-2"},
+			expectedIssues: []ConsistencyIssue{},
+		},
+		{
+			name:           "No billing activity",
+			recordOrgIDs:   nil,
+			invoiceOrgIDs:  nil,
+			expectedIssues: []ConsistencyIssue{},
+		},
+		{
+			name:          "Billing record without invoice",
+			recordOrgIDs:  []string{"org-1", "org-2"},
+			invoiceOrgIDs: []string{"org-1"},
+			expectedIssues: []ConsistencyIssue{
+				{OrganizationID: "org-2", Type: IssueMissingInvoice},
+			},
+		},
+		{
+			name:          "Invoice without billing record",
+			recordOrgIDs:  []string{"org-1"},
+			invoiceOrgIDs: []string{"org-1", "org-9"},
+			expectedIssues: []ConsistencyIssue{
+				{OrganizationID: "org-9", Type: IssueOrphanInvoice},
+			},
+		},
+		{
+			name:          "Mismatch in both directions",
+			recordOrgIDs:  []string{"org-1", "org-2"},
+			invoiceOrgIDs: []string{"org-1", "org-3"},
+			expectedIssues: []ConsistencyIssue{
+				{OrganizationID: "org-2", Type: IssueMissingInvoice},
+				{OrganizationID: "org-3", Type: IssueOrphanInvoice},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := CompareBillingRecordsToInvoices(month, tt.recordOrgIDs, tt.invoiceOrgIDs)
+
+			if report.IsConsistent() != (len(tt.expectedIssues) == 0) {
+				t.Errorf("IsConsistent() = %v, want %v", report.IsConsistent(), len(tt.expectedIssues) == 0)
+			}
+			if len(report.Issues) != len(tt.expectedIssues) {
+				t.Fatalf("Issues = %v, want %v", report.Issues, tt.expectedIssues)
+			}
+			for i, expected := range tt.expectedIssues {
+				if report.Issues[i] != expected {
+					t.Errorf("Issue %d = %+v, want %+v", i, report.Issues[i], expected)
+				}
+			}
+		})
+	}
+}
+
+// TestVerifyMonth tests the consistency check against a seeded database
+func TestVerifyMonth(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gen := NewInvoiceGenerator(db, nil, nil, createTestConfig())
+	month := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	report, err := gen.VerifyMonth(context.Background(), month)
+	if err != nil {
+		t.Fatalf("VerifyMonth() error = %v", err)
+	}
+
+	if !report.BillingMonth.Equal(month) {
+		t.Errorf("BillingMonth = %v, want %v", report.BillingMonth, month)
+	}
+}
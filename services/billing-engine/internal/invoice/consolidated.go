@@ -0,0 +1,231 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// GenerateConsolidatedInvoice creates one invoice for a parent organization (a reseller) covering
+// the billing records of its child organizations whose periods close in month
+// Each child's line items form a section with its own subtotal; the parent's own usage, if any,
+// is a section too. If the parent already has an invoice for the month, that invoice is returned
+func (g *InvoiceGenerator) GenerateConsolidatedInvoice(ctx context.Context, parentOrgID string, month time.Time) (*Invoice, error) {
+	billingMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	records, err := g.getBillingRecordsForMonth(ctx, billingMonth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing records: %w", err)
+	}
+	parents, err := g.getParentOrganizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parent organizations: %w", err)
+	}
+
+	_, groups := splitConsolidated(records, parents)
+	invoice, _, err := g.createConsolidatedInvoice(ctx, parentOrgID, billingMonth, groups[parentOrgID])
+	return invoice, err
+}
+
+// createConsolidatedInvoice creates a parent's consolidated invoice for a month from its group's
+// records, reporting false if an existing one was returned
+func (g *InvoiceGenerator) createConsolidatedInvoice(ctx context.Context, parentOrgID string, month time.Time, records []*BillingRecord) (*Invoice, bool, error) {
+	// Consolidated invoices cover the calendar month; each section keeps its own billing period
+	periodStart := month
+	periodEnd := month.AddDate(0, 1, 0).Add(-time.Second)
+
+	existing, err := g.findInvoiceForPeriod(ctx, parentOrgID, periodStart)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	if len(records) == 0 {
+		return nil, false, fmt.Errorf("no billing records for organization %s or its children in %s", parentOrgID, month.Format("2006-01"))
+	}
+
+	parent, err := g.getOrganization(ctx, parentOrgID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	names, err := g.sectionNames(ctx, parent, records)
+	if err != nil {
+		return nil, false, err
+	}
+
+	invoice := g.buildConsolidatedInvoice(parent, records, names, periodStart, periodEnd, time.Now())
+
+	// The parent's coupon applies to the whole invoice
+	coupon, err := g.activeCoupon(ctx, parentOrgID, periodEnd)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up coupon: %w", err)
+	}
+	discount := invoice.DiscountCents
+	applyCoupon(invoice, coupon, discount)
+
+	return g.saveNewInvoice(ctx, invoice, coupon, discount)
+}
+
+// sectionNames returns the names of the organizations in a parent's group, keyed by ID
+// Section headings use the organizations' current names
+func (g *InvoiceGenerator) sectionNames(ctx context.Context, parent *Organization, records []*BillingRecord) (map[string]string, error) {
+	names := map[string]string{parent.ID: parent.Name}
+	for _, record := range records {
+		if _, ok := names[record.OrganizationID]; ok {
+			continue
+		}
+		child, err := g.getOrganization(ctx, record.OrganizationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get child organization: %w", err)
+		}
+		names[child.ID] = child.Name
+	}
+	return names, nil
+}
+
+// buildConsolidatedInvoice builds a parent's invoice from its children's billing records
+// Line items are tagged with their child organization and ordered by child name; the subtotal and
+// discount are the sums over the records, and tax is charged on the combined subtotal
+func (g *InvoiceGenerator) buildConsolidatedInvoice(parent *Organization, records []*BillingRecord, names map[string]string, periodStart, periodEnd, invoiceDate time.Time) *Invoice {
+	sorted := make([]*BillingRecord, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].OrganizationID, sorted[j].OrganizationID
+		if names[a] != names[b] {
+			return names[a] < names[b]
+		}
+		return a < b
+	})
+
+	lineItems := make([]LineItem, 0)
+	var subtotal, discount int64
+	for _, record := range sorted {
+		start, end := record.BillingPeriod()
+		for _, item := range g.createLineItems(record, start, end) {
+			item.ChildOrganizationID = record.OrganizationID
+			item.ChildOrganizationName = names[record.OrganizationID]
+			lineItems = append(lineItems, item)
+		}
+		subtotal += record.SubtotalCents
+		discount += record.DiscountCents
+	}
+	tax := g.calculateTax(subtotal)
+
+	invoice := &Invoice{
+		OrganizationID:     parent.ID,
+		OrganizationName:   parent.Name,
+		BillingPeriodStart: periodStart,
+		BillingPeriodEnd:   periodEnd,
+		LineItems:          lineItems,
+		SubtotalCents:      subtotal,
+		TaxCents:           tax,
+		DiscountCents:      discount,
+		TotalCents:         subtotal + tax - discount,
+		InvoiceDate:        invoiceDate,
+		Status:             InvoiceStatusDraft,
+		CustomerEmail:      parent.Email,
+		CustomerName:       parent.Name,
+		BillingAddress:     parent.BillingAddress,
+		StripeAccountID:    parent.StripeAccountID,
+		PONumber:           parent.DefaultPONumber,
+		CustomFields:       copyCustomFields(parent.InvoiceCustomFields),
+		CreatedAt:          invoiceDate,
+		UpdatedAt:          invoiceDate,
+	}
+	setPaymentTerms(invoice, paymentTermsDays(parent, g.config.PaymentTerms))
+
+	return invoice
+}
+
+// getParentOrganizations maps each child organization's ID to its parent's
+func (g *InvoiceGenerator) getParentOrganizations(ctx context.Context) (map[string]string, error) {
+	query := `
+		SELECT id::text, parent_organization_id::text
+		FROM organizations
+		WHERE parent_organization_id IS NOT NULL
+	`
+
+	rows, err := g.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	parents := make(map[string]string)
+	for rows.Next() {
+		var childID, parentID string
+		if err := rows.Scan(&childID, &parentID); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		parents[childID] = parentID
+	}
+
+	return parents, rows.Err()
+}
+
+// splitConsolidated separates records invoiced directly from those billed on a parent's
+// consolidated invoice, grouped by parent ID
+// A child rolls up to its direct parent; a parent's own record joins its group so the
+// parent is not invoiced twice for the month
+func splitConsolidated(records []*BillingRecord, parents map[string]string) (standalone []*BillingRecord, groups map[string][]*BillingRecord) {
+	hasChildren := make(map[string]bool, len(parents))
+	for _, parentID := range parents {
+		hasChildren[parentID] = true
+	}
+
+	standalone = make([]*BillingRecord, 0, len(records))
+	groups = make(map[string][]*BillingRecord)
+	for _, record := range records {
+		switch parentID, ok := parents[record.OrganizationID]; {
+		case ok:
+			groups[parentID] = append(groups[parentID], record)
+		case hasChildren[record.OrganizationID]:
+			groups[record.OrganizationID] = append(groups[record.OrganizationID], record)
+		default:
+			standalone = append(standalone, record)
+		}
+	}
+
+	return standalone, groups
+}
+
+// parentIDs returns the parents of consolidated groups in a stable order
+func parentIDs(groups map[string][]*BillingRecord) []string {
+	ids := make([]string, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// lineItemSection is a run of line items billed for the same child organization
+// Regular invoices have a single section with no name
+type lineItemSection struct {
+	OrganizationID string
+	Name           string
+	Items          []LineItem
+	SubtotalCents  int64
+}
+
+// lineItemSections groups consecutive line items by child organization
+func lineItemSections(items []LineItem) []lineItemSection {
+	sections := make([]lineItemSection, 0, 1)
+	for _, item := range items {
+		n := len(sections)
+		if n == 0 || sections[n-1].OrganizationID != item.ChildOrganizationID {
+			sections = append(sections, lineItemSection{
+				OrganizationID: item.ChildOrganizationID,
+				Name:           item.ChildOrganizationName,
+			})
+			n++
+		}
+		sections[n-1].Items = append(sections[n-1].Items, item)
+		sections[n-1].SubtotalCents += item.AmountCents
+	}
+	return sections
+}
@@ -0,0 +1,152 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+)
+
+// resellerRecords returns January billing records for a reseller's two children
+func resellerRecords() []*BillingRecord {
+	january := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []*BillingRecord{
+		{
+			OrganizationID:     "org-zephyr",
+			BillingMonth:       january,
+			PlanName:           "Growth",
+			BaseChargeCents:    9900,
+			OverageChargeCents: 2500,
+			OverageUnits:       50000,
+			SubtotalCents:      12400,
+			DiscountCents:      400,
+		},
+		{
+			OrganizationID:  "org-acme",
+			BillingMonth:    january,
+			PlanName:        "Starter",
+			BaseChargeCents: 2900,
+			SubtotalCents:   2900,
+		},
+	}
+}
+
+// TestInvoiceGenerator_buildConsolidatedInvoice tests a parent's invoice for two children
+func TestInvoiceGenerator_buildConsolidatedInvoice(t *testing.T) {
+	gen := NewInvoiceGenerator(nil, nil, nil, createTestConfig())
+
+	terms := 45
+	parent := &Organization{
+		ID:               "org-reseller",
+		Name:             "Reseller Inc",
+		Email:            "ap@reseller.test",
+		BillingAddress:   "1 Channel Way",
+		PaymentTermsDays: &terms,
+		DefaultPONumber:  "PO-900",
+	}
+	names := map[string]string{"org-reseller": "Reseller Inc", "org-zephyr": "Zephyr Labs", "org-acme": "Acme Corp"}
+	periodStart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+	invoiceDate := time.Date(2026, 2, 1, 6, 0, 0, 0, time.UTC)
+
+	invoice := gen.buildConsolidatedInvoice(parent, resellerRecords(), names, periodStart, periodEnd, invoiceDate)
+
+	// Billed to the parent with the parent's terms and PO number
+	if invoice.OrganizationID != "org-reseller" || invoice.CustomerEmail != "ap@reseller.test" {
+		t.Errorf("Invoice billed to %s <%s>, want the parent", invoice.OrganizationID, invoice.CustomerEmail)
+	}
+	if invoice.PaymentTermsDays != 45 || invoice.PONumber != "PO-900" {
+		t.Errorf("Terms = Net %d, PO = %q, want Net 45 and PO-900", invoice.PaymentTermsDays, invoice.PONumber)
+	}
+	if !invoice.BillingPeriodStart.Equal(periodStart) || !invoice.BillingPeriodEnd.Equal(periodEnd) {
+		t.Errorf("Period = %v - %v, want the calendar month", invoice.BillingPeriodStart, invoice.BillingPeriodEnd)
+	}
+
+	// One section per child, in name order, each subtotaled
+	sections := lineItemSections(invoice.LineItems)
+	expected := []struct {
+		orgID    string
+		name     string
+		items    int
+		subtotal int64
+	}{
+		{"org-acme", "Acme Corp", 1, 2900},
+		{"org-zephyr", "Zephyr Labs", 2, 12400},
+	}
+	if len(sections) != len(expected) {
+		t.Fatalf("Sections = %d, want %d", len(sections), len(expected))
+	}
+	for i, want := range expected {
+		got := sections[i]
+		if got.OrganizationID != want.orgID || got.Name != want.name {
+			t.Errorf("Section %d = %s (%s), want %s (%s)", i, got.Name, got.OrganizationID, want.name, want.orgID)
+		}
+		if len(got.Items) != want.items || got.SubtotalCents != want.subtotal {
+			t.Errorf("Section %s = %d items, %d cents, want %d items, %d cents", want.name, len(got.Items), got.SubtotalCents, want.items, want.subtotal)
+		}
+	}
+
+	// The totals are the sums over the children
+	if invoice.SubtotalCents != 15300 {
+		t.Errorf("SubtotalCents = %d, want 15300", invoice.SubtotalCents)
+	}
+	if invoice.TaxCents != 1224 {
+		t.Errorf("TaxCents = %d, want 1224 (8%% of the combined subtotal)", invoice.TaxCents)
+	}
+	if invoice.DiscountCents != 400 {
+		t.Errorf("DiscountCents = %d, want 400", invoice.DiscountCents)
+	}
+	if invoice.TotalCents != 15300+1224-400 {
+		t.Errorf("TotalCents = %d, want %d", invoice.TotalCents, 15300+1224-400)
+	}
+}
+
+// TestSplitConsolidated tests that children roll up to their parent and are not invoiced alone
+func TestSplitConsolidated(t *testing.T) {
+	records := []*BillingRecord{
+		{OrganizationID: "org-solo"},
+		{OrganizationID: "org-child-1"},
+		{OrganizationID: "org-reseller"},
+		{OrganizationID: "org-child-2"},
+		{OrganizationID: "org-other-child"},
+	}
+	parents := map[string]string{
+		"org-child-1":     "org-reseller",
+		"org-child-2":     "org-reseller",
+		"org-other-child": "org-quiet-reseller", // Parent has no usage of its own
+	}
+
+	standalone, groups := splitConsolidated(records, parents)
+
+	if len(standalone) != 1 || standalone[0].OrganizationID != "org-solo" {
+		t.Errorf("Standalone = %v, want org-solo only", standalone)
+	}
+	if ids := parentIDs(groups); len(ids) != 2 || ids[0] != "org-quiet-reseller" || ids[1] != "org-reseller" {
+		t.Fatalf("Parents = %v, want [org-quiet-reseller org-reseller]", ids)
+	}
+
+	// The parent's own record joins its group
+	var reseller []string
+	for _, record := range groups["org-reseller"] {
+		reseller = append(reseller, record.OrganizationID)
+	}
+	if len(reseller) != 3 || reseller[0] != "org-child-1" || reseller[1] != "org-reseller" || reseller[2] != "org-child-2" {
+		t.Errorf("org-reseller group = %v, want both children and the parent", reseller)
+	}
+	if len(groups["org-quiet-reseller"]) != 1 {
+		t.Errorf("org-quiet-reseller group = %d records, want 1", len(groups["org-quiet-reseller"]))
+	}
+}
+
+// TestLineItemSections_Regular tests that a regular invoice is a single unnamed section
+func TestLineItemSections_Regular(t *testing.T) {
+	sections := lineItemSections(createTestInvoice().LineItems)
+
+	if len(sections) != 1 || sections[0].Name != "" {
+		t.Fatalf("Sections = %+v, want one unnamed section", sections)
+	}
+	if sections[0].SubtotalCents != 10100 {
+		t.Errorf("SubtotalCents = %d, want 10100", sections[0].SubtotalCents)
+	}
+	if len(lineItemSections(nil)) != 0 {
+		t.Error("Expected no sections without line items")
+	}
+}
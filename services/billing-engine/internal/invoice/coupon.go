@@ -0,0 +1,194 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Coupon discount types
+const (
+	CouponTypePercent = "percent" // PercentOff of the subtotal
+	CouponTypeFixed   = "fixed"   // AmountOffCents, capped at the subtotal
+)
+
+// errCouponExhausted is returned when a coupon's last redemption was taken concurrently
+var errCouponExhausted = errors.New("coupon has no redemptions left")
+
+// Coupon is a discount assigned to organizations through organization_coupons
+type Coupon struct {
+	ID             string     `json:"id"`
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discount_type"`
+	PercentOff     float64    `json:"percent_off,omitempty"`      // e.g. 15 = 15% off
+	AmountOffCents int64      `json:"amount_off_cents,omitempty"` // fixed discount
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`       // nil = never expires
+	MaxRedemptions int        `json:"max_redemptions,omitempty"`  // invoices it may discount, 0 = unlimited
+	TimesRedeemed  int        `json:"times_redeemed"`
+}
+
+// IsRedeemable reports whether the coupon can still discount an invoice at the given time
+func (c *Coupon) IsRedeemable(at time.Time) bool {
+	if c.ExpiresAt != nil && !at.Before(*c.ExpiresAt) {
+		return false
+	}
+	if c.MaxRedemptions > 0 && c.TimesRedeemed >= c.MaxRedemptions {
+		return false
+	}
+	return true
+}
+
+// Discount computes the coupon's discount on a subtotal, never exceeding it
+// Percentage discounts are rounded to the nearest cent
+func (c *Coupon) Discount(subtotalCents int64) int64 {
+	if subtotalCents <= 0 {
+		return 0
+	}
+
+	var discount int64
+	switch c.DiscountType {
+	case CouponTypePercent:
+		discount = int64(math.Round(float64(subtotalCents) * c.PercentOff / 100))
+	case CouponTypeFixed:
+		discount = c.AmountOffCents
+	}
+
+	if discount < 0 {
+		return 0
+	}
+	if discount > subtotalCents {
+		return subtotalCents
+	}
+	return discount
+}
+
+// Note describes the coupon for the invoice notes, e.g. "Coupon SPRING25: 25% off"
+func (c *Coupon) Note() string {
+	if c.DiscountType == CouponTypePercent {
+		percent := strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", c.PercentOff), "0"), ".")
+		return fmt.Sprintf("Coupon %s: %s%% off", c.Code, percent)
+	}
+	return fmt.Sprintf("Coupon %s: %s off", c.Code, formatPrice(c.AmountOffCents))
+}
+
+// applyCoupon discounts invoice by coupon on top of baseDiscount (the billing record's discount)
+// and recomputes the total; a nil coupon leaves only baseDiscount
+func applyCoupon(invoice *Invoice, coupon *Coupon, baseDiscount int64) {
+	invoice.DiscountCents = baseDiscount
+
+	if coupon != nil {
+		if discount := coupon.Discount(invoice.SubtotalCents - baseDiscount); discount > 0 {
+			invoice.CouponID = coupon.ID
+			invoice.DiscountCents += discount
+			invoice.Notes = coupon.Note()
+		}
+	}
+
+	invoice.TotalCents = invoice.SubtotalCents + invoice.TaxCents - invoice.DiscountCents
+}
+
+// activeCoupon returns the org's most recently assigned coupon still redeemable at the given time, or nil
+func (g *InvoiceGenerator) activeCoupon(ctx context.Context, orgID string, at time.Time) (*Coupon, error) {
+	query := `
+		SELECT
+			c.id, c.code, c.discount_type,
+			COALESCE(c.percent_off, 0), COALESCE(c.amount_off_cents, 0),
+			c.expires_at, COALESCE(c.max_redemptions, 0), c.times_redeemed
+		FROM organization_coupons oc
+		JOIN coupons c ON c.id = oc.coupon_id
+		WHERE oc.organization_id = $1
+		  AND c.is_active = true
+		ORDER BY oc.assigned_at DESC
+	`
+
+	rows, err := g.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupons: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		coupon := &Coupon{}
+		var expiresAt sql.NullTime
+		err := rows.Scan(
+			&coupon.ID, &coupon.Code, &coupon.DiscountType,
+			&coupon.PercentOff, &coupon.AmountOffCents,
+			&expiresAt, &coupon.MaxRedemptions, &coupon.TimesRedeemed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan coupon: %w", err)
+		}
+
+		if expiresAt.Valid {
+			coupon.ExpiresAt = &expiresAt.Time
+		}
+
+		if coupon.IsRedeemable(at) {
+			return coupon, nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return nil, nil
+}
+
+// getCoupon returns a coupon by ID, whether or not it is still redeemable
+func (g *InvoiceGenerator) getCoupon(ctx context.Context, couponID string) (*Coupon, error) {
+	query := `
+		SELECT
+			id, code, discount_type,
+			COALESCE(percent_off, 0), COALESCE(amount_off_cents, 0),
+			expires_at, COALESCE(max_redemptions, 0), times_redeemed
+		FROM coupons
+		WHERE id = $1
+	`
+
+	coupon := &Coupon{}
+	var expiresAt sql.NullTime
+	err := g.db.QueryRowContext(ctx, query, couponID).Scan(
+		&coupon.ID, &coupon.Code, &coupon.DiscountType,
+		&coupon.PercentOff, &coupon.AmountOffCents,
+		&expiresAt, &coupon.MaxRedemptions, &coupon.TimesRedeemed,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	if expiresAt.Valid {
+		coupon.ExpiresAt = &expiresAt.Time
+	}
+
+	return coupon, nil
+}
+
+// redeemCoupon counts one redemption in the invoice's transaction, so a rollback returns it
+func redeemCoupon(ctx context.Context, tx *sql.Tx, couponID string) error {
+	query := `
+		UPDATE coupons
+		SET times_redeemed = times_redeemed + 1, updated_at = NOW()
+		WHERE id = $1
+		  AND (max_redemptions IS NULL OR times_redeemed < max_redemptions)
+	`
+
+	result, err := tx.ExecContext(ctx, query, couponID)
+	if err != nil {
+		return fmt.Errorf("failed to redeem coupon: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to redeem coupon: %w", err)
+	}
+	if rows == 0 {
+		return errCouponExhausted
+	}
+
+	return nil
+}
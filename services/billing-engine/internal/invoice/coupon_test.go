@@ -0,0 +1,121 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoupon_Discount(t *testing.T) {
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		subtotal int64
+		expected int64
+	}{
+		{"Percentage", Coupon{DiscountType: CouponTypePercent, PercentOff: 25}, 10000, 2500},
+		{"Percentage rounds up", Coupon{DiscountType: CouponTypePercent, PercentOff: 15}, 3333, 500},     // 499.95
+		{"Percentage rounds down", Coupon{DiscountType: CouponTypePercent, PercentOff: 12.5}, 1001, 125}, // 125.125
+		{"Percentage rounds half away from zero", Coupon{DiscountType: CouponTypePercent, PercentOff: 50}, 101, 51},
+		{"Full percentage", Coupon{DiscountType: CouponTypePercent, PercentOff: 100}, 9900, 9900},
+		{"Fixed", Coupon{DiscountType: CouponTypeFixed, AmountOffCents: 1000}, 9900, 1000},
+		{"Fixed capped at subtotal", Coupon{DiscountType: CouponTypeFixed, AmountOffCents: 5000}, 2900, 2900},
+		{"Zero subtotal", Coupon{DiscountType: CouponTypeFixed, AmountOffCents: 1000}, 0, 0},
+		{"Unknown type", Coupon{DiscountType: "bogus", AmountOffCents: 1000}, 9900, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.coupon.Discount(tt.subtotal); got != tt.expected {
+				t.Errorf("Discount(%d) = %d, want %d", tt.subtotal, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCoupon_IsRedeemable(t *testing.T) {
+	now := time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC)
+	past := now.Add(-24 * time.Hour)
+	future := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		expected bool
+	}{
+		{"No expiry or limit", Coupon{}, true},
+		{"Not yet expired", Coupon{ExpiresAt: &future}, true},
+		{"Expired", Coupon{ExpiresAt: &past}, false},
+		{"Expires exactly now", Coupon{ExpiresAt: &now}, false},
+		{"Redemptions left", Coupon{MaxRedemptions: 3, TimesRedeemed: 2}, true},
+		{"Redemptions exhausted", Coupon{MaxRedemptions: 3, TimesRedeemed: 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.coupon.IsRedeemable(now); got != tt.expected {
+				t.Errorf("IsRedeemable() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCoupon_Note(t *testing.T) {
+	tests := []struct {
+		coupon   Coupon
+		expected string
+	}{
+		{Coupon{Code: "SPRING25", DiscountType: CouponTypePercent, PercentOff: 25}, "Coupon SPRING25: 25% off"},
+		{Coupon{Code: "HALFPAST", DiscountType: CouponTypePercent, PercentOff: 12.5}, "Coupon HALFPAST: 12.5% off"},
+		{Coupon{Code: "WELCOME10", DiscountType: CouponTypeFixed, AmountOffCents: 1000}, "Coupon WELCOME10: $10.00 off"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.coupon.Note(); got != tt.expected {
+			t.Errorf("Note() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestApplyCoupon(t *testing.T) {
+	coupon := &Coupon{ID: "coupon-1", Code: "SPRING25", DiscountType: CouponTypePercent, PercentOff: 25}
+
+	// Percentage applies to what is left after the billing record's own discount
+	invoice := &Invoice{SubtotalCents: 10000, TaxCents: 800}
+	applyCoupon(invoice, coupon, 2000)
+
+	if invoice.DiscountCents != 4000 {
+		t.Errorf("DiscountCents = %d, want 4000 (2000 + 25%% of 8000)", invoice.DiscountCents)
+	}
+	if invoice.TotalCents != 6800 {
+		t.Errorf("TotalCents = %d, want 6800", invoice.TotalCents)
+	}
+	if invoice.CouponID != "coupon-1" || invoice.Notes != "Coupon SPRING25: 25% off" {
+		t.Errorf("CouponID/Notes = %q/%q", invoice.CouponID, invoice.Notes)
+	}
+
+	// No coupon leaves the record discount untouched
+	invoice = &Invoice{SubtotalCents: 10000}
+	applyCoupon(invoice, nil, 500)
+	if invoice.DiscountCents != 500 || invoice.TotalCents != 9500 || invoice.CouponID != "" || invoice.Notes != "" {
+		t.Errorf("Without coupon: %+v", invoice)
+	}
+
+	// A coupon with nothing left to discount is not recorded as redeemed
+	invoice = &Invoice{SubtotalCents: 1000}
+	applyCoupon(invoice, coupon, 1000)
+	if invoice.CouponID != "" || invoice.TotalCents != 0 {
+		t.Errorf("Fully discounted: CouponID = %q, TotalCents = %d", invoice.CouponID, invoice.TotalCents)
+	}
+}
+
+func TestDiscountLineItem(t *testing.T) {
+	item := discountLineItem(&Invoice{DiscountCents: 2500, CouponID: "coupon-1", Notes: "Coupon SPRING25: 25% off"})
+	if item.AmountCents != -2500 || item.ItemType != "discount" || item.Description != "Coupon SPRING25: 25% off" {
+		t.Errorf("discountLineItem() = %+v", item)
+	}
+
+	item = discountLineItem(&Invoice{DiscountCents: 500})
+	if item.Description != "Discount" {
+		t.Errorf("Description = %q, want Discount", item.Description)
+	}
+}
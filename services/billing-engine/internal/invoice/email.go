@@ -3,10 +3,16 @@ package invoice
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"fmt"
+	"html/template"
+	"log"
+	"mime/quotedprintable"
 	"net/smtp"
 	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
 )
 
 // EmailSender handles sending invoice emails
@@ -32,7 +38,12 @@ func (es *EmailSender) SendInvoiceEmail(ctx context.Context, invoice *Invoice, p
 	body := es.buildEmailBody(invoice)
 
 	// Create MIME message with attachment
-	message := es.buildMIMEMessage(invoice.CustomerEmail, subject, body, pdfData, invoice.InvoiceNumber)
+	htmlBody, err := es.buildHTMLBody(invoice)
+	if err != nil {
+		log.Printf("[EmailSender] WARNING: Failed to render HTML body for %s, sending text only: %v", invoice.InvoiceNumber, err)
+		htmlBody = ""
+	}
+	message := es.buildMIMEMessageWithHTML(invoice.CustomerEmail, subject, body, htmlBody, pdfData, invoice.InvoiceNumber)
 
 	// Send email
 	if err := es.sendEmail(invoice.CustomerEmail, message); err != nil {
@@ -59,7 +70,6 @@ Invoice Summary:
 - Invoice Date: %s
 - Due Date: %s
 - Amount Due: %s
-
 `,
 		invoice.CustomerName,
 		es.config.CompanyName,
@@ -71,6 +81,15 @@ Invoice Summary:
 		totalAmount,
 	)
 
+	// Add PO number and custom fields
+	if invoice.PONumber != "" {
+		body += fmt.Sprintf("- PO Number: %s\n", invoice.PONumber)
+	}
+	for _, key := range customFieldKeys(invoice.CustomFields) {
+		body += fmt.Sprintf("- %s: %s\n", key, invoice.CustomFields[key])
+	}
+	body += "\n"
+
 	// Add line items
 	body += "Charges:\n"
 	for _, item := range invoice.LineItems {
@@ -118,9 +137,134 @@ This is an automated message. Please do not reply directly to this email.
 	return body
 }
 
+// invoiceHTMLTemplate renders the HTML invoice summary; html/template escapes all fields
+var invoiceHTMLTemplate = template.Must(template.New("invoice").Parse(`<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f4f4f4;font-family:Arial,Helvetica,sans-serif;color:#333333;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:600px;margin:0 auto;background:#ffffff;border-radius:6px;">
+<tr><td style="padding:24px 24px 8px;">
+<h1 style="margin:0;font-size:22px;color:#3c3c3c;">{{.CompanyName}}</h1>
+<p style="margin:4px 0 0;color:#777777;">Invoice {{.InvoiceNumber}} for {{.BillingPeriod}}</p>
+</td></tr>
+<tr><td style="padding:16px 24px;">
+<p style="margin:0 0 16px;">Dear {{.CustomerName}},</p>
+<p style="margin:0 0 16px;">Thank you for your continued business. Your invoice is attached as a PDF.</p>
+<table role="presentation" width="100%" cellpadding="6" cellspacing="0" style="border-collapse:collapse;font-size:14px;">
+<tr><td style="color:#777777;">Invoice Number</td><td align="right">{{.InvoiceNumber}}</td></tr>
+<tr><td style="color:#777777;">Invoice Date</td><td align="right">{{.InvoiceDate}}</td></tr>
+<tr><td style="color:#777777;">Due Date</td><td align="right">{{.DueDate}}</td></tr>
+{{if .PONumber}}<tr><td style="color:#777777;">PO Number</td><td align="right">{{.PONumber}}</td></tr>
+{{end}}{{range .CustomFields}}<tr><td style="color:#777777;">{{.Label}}</td><td align="right">{{.Value}}</td></tr>
+{{end}}</table>
+</td></tr>
+<tr><td style="padding:0 24px;">
+<table role="presentation" width="100%" cellpadding="8" cellspacing="0" style="border-collapse:collapse;font-size:14px;">
+<tr style="background:#3c3c3c;color:#ffffff;"><th align="left">Description</th><th align="right">Amount</th></tr>
+{{range .LineItems}}<tr style="background:{{if .Shaded}}#f5f5f5{{else}}#ffffff{{end}};"><td>{{.Description}}</td><td align="right">{{.Amount}}</td></tr>
+{{end}}{{if .Subtotal}}<tr><td align="right" style="border-top:1px solid #dddddd;">Subtotal</td><td align="right" style="border-top:1px solid #dddddd;">{{.Subtotal}}</td></tr>
+<tr><td align="right">Tax</td><td align="right">{{.Tax}}</td></tr>
+{{end}}{{if .Discount}}<tr><td align="right">Discount</td><td align="right">-{{.Discount}}</td></tr>
+{{end}}<tr><td align="right" style="border-top:2px solid #3c3c3c;font-weight:bold;">Total Due</td><td align="right" style="border-top:2px solid #3c3c3c;font-weight:bold;">{{.Total}}</td></tr>
+</table>
+</td></tr>
+<tr><td style="padding:16px 24px;">
+<p style="margin:0 0 16px;">Payment is due within {{.PaymentTermsDays}} days of the invoice date ({{.DueDate}}).</p>
+{{if .PayURL}}<p style="margin:0 0 16px;"><a href="{{.PayURL}}" style="display:inline-block;padding:10px 18px;background:#1e5ac8;color:#ffffff;text-decoration:none;border-radius:4px;">Pay online</a></p>
+{{end}}<p style="margin:0 0 16px;">If you have any questions about this invoice, please contact us at <a href="mailto:{{.CompanyEmail}}">{{.CompanyEmail}}</a>.</p>
+<p style="margin:0;">Best regards,<br>{{.CompanyName}} Billing Team</p>
+</td></tr>
+<tr><td style="padding:16px 24px;font-size:12px;color:#999999;border-top:1px solid #eeeeee;">This is an automated message. Please do not reply directly to this email.</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// htmlLineItem is a line item formatted for the HTML template
+type htmlLineItem struct {
+	Description string
+	Amount      string
+	Shaded      bool // Alternate row background
+}
+
+// htmlCustomField is a custom field row of the HTML invoice summary
+type htmlCustomField struct {
+	Label string
+	Value string
+}
+
+// buildHTMLBody renders the invoice summary as a styled HTML table
+// It carries the same information as buildEmailBody
+func (es *EmailSender) buildHTMLBody(invoice *Invoice) (string, error) {
+	data := struct {
+		CompanyName      string
+		CompanyEmail     string
+		CustomerName     string
+		InvoiceNumber    string
+		BillingPeriod    string
+		InvoiceDate      string
+		DueDate          string
+		PONumber         string
+		CustomFields     []htmlCustomField
+		LineItems        []htmlLineItem
+		Subtotal         string
+		Tax              string
+		Discount         string
+		Total            string
+		PaymentTermsDays int
+		PayURL           string
+	}{
+		CompanyName:      es.config.CompanyName,
+		CompanyEmail:     es.config.CompanyEmail,
+		CustomerName:     invoice.CustomerName,
+		InvoiceNumber:    invoice.InvoiceNumber,
+		BillingPeriod:    invoice.BillingPeriodStart.Format("January 2006"),
+		InvoiceDate:      invoice.InvoiceDate.Format("January 2, 2006"),
+		DueDate:          invoice.DueDate.Format("January 2, 2006"),
+		PONumber:         invoice.PONumber,
+		Total:            formatPrice(invoice.TotalCents),
+		PaymentTermsDays: invoice.PaymentTermsDays,
+		PayURL:           invoice.StripeInvoiceURL,
+	}
+
+	for _, key := range customFieldKeys(invoice.CustomFields) {
+		data.CustomFields = append(data.CustomFields, htmlCustomField{Label: key, Value: invoice.CustomFields[key]})
+	}
+
+	for i, item := range invoice.LineItems {
+		data.LineItems = append(data.LineItems, htmlLineItem{
+			Description: item.Description,
+			Amount:      formatPrice(item.AmountCents),
+			Shaded:      i%2 == 1,
+		})
+	}
+
+	// Same rules as the text body: subtotal and tax only when taxed
+	if invoice.TaxCents > 0 {
+		data.Subtotal = formatPrice(invoice.SubtotalCents)
+		data.Tax = formatPrice(invoice.TaxCents)
+	}
+	if invoice.DiscountCents > 0 {
+		data.Discount = formatPrice(invoice.DiscountCents)
+	}
+
+	var buf bytes.Buffer
+	if err := invoiceHTMLTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render HTML body: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
 // buildMIMEMessage creates a MIME-formatted email with PDF attachment
 func (es *EmailSender) buildMIMEMessage(to, subject, body string, pdfData []byte, filename string) []byte {
-	boundary := "boundary-" + time.Now().Format("20060102150405")
+	return es.buildMIMEMessageWithHTML(to, subject, body, "", pdfData, filename)
+}
+
+// buildMIMEMessageWithHTML creates a MIME-formatted email with PDF attachment
+// When htmlBody is set, the text and HTML bodies are sent as a multipart/alternative
+// part nested inside the multipart/mixed message, so text-only clients still get body
+func (es *EmailSender) buildMIMEMessageWithHTML(to, subject, body, htmlBody string, pdfData []byte, filename string) []byte {
+	boundary := newMIMEBoundary("mixed")
 
 	var buf bytes.Buffer
 
@@ -134,28 +278,47 @@ func (es *EmailSender) buildMIMEMessage(to, subject, body string, pdfData []byte
 
 	// Body part
 	buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-	buf.WriteString("Content-Transfer-Encoding: 7bit\r\n")
-	buf.WriteString("\r\n")
-	buf.WriteString(body)
-	buf.WriteString("\r\n")
+	if htmlBody == "" {
+		writeTextPart(&buf, body)
+	} else {
+		altBoundary := newMIMEBoundary("alt")
+		buf.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=%s\r\n", altBoundary))
+		buf.WriteString("\r\n")
 
-	// PDF attachment
-	buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-	buf.WriteString("Content-Type: application/pdf\r\n")
-	buf.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s.pdf\"\r\n", filename))
-	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
-	buf.WriteString("\r\n")
+		// Plain text first: clients show the last alternative they support
+		buf.WriteString(fmt.Sprintf("--%s\r\n", altBoundary))
+		writeTextPart(&buf, body)
 
-	// Encode PDF as base64 (76 chars per line)
-	encoded := encodeBase64(pdfData)
-	for i := 0; i < len(encoded); i += 76 {
-		end := i + 76
-		if end > len(encoded) {
-			end = len(encoded)
-		}
-		buf.WriteString(encoded[i:end])
+		buf.WriteString(fmt.Sprintf("--%s\r\n", altBoundary))
+		buf.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
 		buf.WriteString("\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		qp.Write([]byte(htmlBody))
+		qp.Close()
+		buf.WriteString("\r\n")
+
+		buf.WriteString(fmt.Sprintf("--%s--\r\n", altBoundary))
+	}
+
+	// PDF attachment (omitted for notifications that have none)
+	if len(pdfData) > 0 {
+		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		buf.WriteString("Content-Type: application/pdf\r\n")
+		buf.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s.pdf\"\r\n", filename))
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		buf.WriteString("\r\n")
+
+		// Encode PDF as base64 (76 chars per line)
+		encoded := encodeBase64(pdfData)
+		for i := 0; i < len(encoded); i += 76 {
+			end := i + 76
+			if end > len(encoded) {
+				end = len(encoded)
+			}
+			buf.WriteString(encoded[i:end])
+			buf.WriteString("\r\n")
+		}
 	}
 
 	// End boundary
@@ -164,6 +327,25 @@ func (es *EmailSender) buildMIMEMessage(to, subject, body string, pdfData []byte
 	return buf.Bytes()
 }
 
+// writeTextPart writes the plain-text body part
+func writeTextPart(buf *bytes.Buffer, body string) {
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 7bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(body)
+	buf.WriteString("\r\n")
+}
+
+// newMIMEBoundary returns a random boundary, unique per message and per nesting level
+func newMIMEBoundary(prefix string) string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to the clock
+		return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%s-%x", prefix, b[:])
+}
+
 // sendEmail sends the email via SMTP
 func (es *EmailSender) sendEmail(to string, message []byte) error {
 	// Connect to SMTP server
@@ -243,7 +425,17 @@ func (es *EmailSender) SendPaymentReminderEmail(ctx context.Context, invoice *In
 	}
 
 	subject := fmt.Sprintf("Payment Reminder: Invoice %s from %s", invoice.InvoiceNumber, es.config.CompanyName)
+	message := es.buildMIMEMessage(invoice.CustomerEmail, subject, es.buildReminderBody(invoice), nil, "")
+
+	if err := es.sendEmail(invoice.CustomerEmail, message); err != nil {
+		return fmt.Errorf("failed to send reminder email: %w", err)
+	}
+
+	return nil
+}
 
+// buildReminderBody creates the plain text body of a payment reminder
+func (es *EmailSender) buildReminderBody(invoice *Invoice) string {
 	daysOverdue := int(time.Since(invoice.DueDate).Hours() / 24)
 	totalAmount := formatPrice(invoice.TotalCents)
 
@@ -282,13 +474,7 @@ Best regards,
 		es.config.CompanyName,
 	)
 
-	message := es.buildMIMEMessage(invoice.CustomerEmail, subject, body, nil, "")
-
-	if err := es.sendEmail(invoice.CustomerEmail, message); err != nil {
-		return fmt.Errorf("failed to send reminder email: %w", err)
-	}
-
-	return nil
+	return body
 }
 
 // SendPaymentSuccessEmail sends a confirmation email for successful payment
@@ -336,6 +522,55 @@ Best regards,
 	return nil
 }
 
+// SendRefundEmail confirms a full or partial refund of an invoice
+func (es *EmailSender) SendRefundEmail(ctx context.Context, invoice *Invoice, refund *Refund) error {
+	if !es.config.EnableEmail {
+		return fmt.Errorf("email sending is disabled")
+	}
+
+	subject := fmt.Sprintf("Refund Issued: Invoice %s", invoice.InvoiceNumber)
+
+	refundDate := refund.CreatedAt
+	if refund.ProcessedAt != nil {
+		refundDate = *refund.ProcessedAt
+	}
+
+	body := fmt.Sprintf(`Dear %s,
+
+We have issued a refund for invoice %s.
+
+Refund Details:
+- Invoice Number: %s
+- Refund Amount: %s
+- Invoice Total: %s
+- Refund Date: %s
+
+The refund will be returned to your original payment method, which usually takes 5-10 business days.
+
+If you have any questions, please contact us at %s.
+
+Best regards,
+%s Billing Team
+`,
+		invoice.CustomerName,
+		invoice.InvoiceNumber,
+		invoice.InvoiceNumber,
+		formatPrice(refund.AmountCents),
+		formatPrice(invoice.TotalCents),
+		refundDate.Format("January 2, 2006"),
+		es.config.CompanyEmail,
+		es.config.CompanyName,
+	)
+
+	message := es.buildMIMEMessage(invoice.CustomerEmail, subject, body, nil, "")
+
+	if err := es.sendEmail(invoice.CustomerEmail, message); err != nil {
+		return fmt.Errorf("failed to send refund email: %w", err)
+	}
+
+	return nil
+}
+
 // SendPaymentFailedEmail sends a notification for failed payment
 func (es *EmailSender) SendPaymentFailedEmail(ctx context.Context, invoice *Invoice, failureReason string) error {
 	if !es.config.EnableEmail {
@@ -387,6 +622,230 @@ Best regards,
 	return nil
 }
 
+// UsageAlertNotice describes a plan usage threshold crossed by an organization
+type UsageAlertNotice struct {
+	OrganizationName string
+	Email            string
+	PlanName         string
+	BillingMonth     time.Time
+	ThresholdPercent int   // e.g. 80, 100, 120
+	UsedUnits        int64 // Month-to-date billable units
+	LimitUnits       int64 // Included units (or hard cap for capped plans)
+	LimitReached     bool  // Hard-capped plan has hit its limit; requests are being rejected
+}
+
+// SendUsageAlertEmail notifies an organization that it crossed a usage threshold
+func (es *EmailSender) SendUsageAlertEmail(ctx context.Context, notice *UsageAlertNotice) error {
+	if !es.config.EnableEmail {
+		return fmt.Errorf("email sending is disabled")
+	}
+
+	month := notice.BillingMonth.Format("January 2006")
+	var subject, body string
+
+	if notice.LimitReached {
+		subject = fmt.Sprintf("Usage limit reached for your %s plan", notice.PlanName)
+		body = fmt.Sprintf(`Dear %s,
+
+Your organization has reached the %s request limit of the %s plan for %s.
+
+Usage: %s of %s %s
+
+Further API requests will be rejected until your usage resets at the start of next month.
+To keep your application running, upgrade to a paid plan with overage billing.
+
+`,
+			notice.OrganizationName,
+			formatUsage(notice.LimitUnits),
+			notice.PlanName,
+			month,
+			formatUsage(notice.UsedUnits),
+			formatUsage(notice.LimitUnits),
+			es.config.UnitLabel(),
+		)
+	} else {
+		subject = fmt.Sprintf("You've used %d%% of your %s plan", notice.ThresholdPercent, notice.PlanName)
+		body = fmt.Sprintf(`Dear %s,
+
+Your organization has used %d%% of the %s included in the %s plan for %s.
+
+Usage: %s of %s included %s
+
+Requests beyond your included units are billed as overage on your next invoice.
+
+`,
+			notice.OrganizationName,
+			notice.ThresholdPercent,
+			es.config.UnitLabel(),
+			notice.PlanName,
+			month,
+			formatUsage(notice.UsedUnits),
+			formatUsage(notice.LimitUnits),
+			es.config.UnitLabel(),
+		)
+	}
+
+	body += fmt.Sprintf(`If you have any questions, please contact us at %s.
+
+Best regards,
+%s Billing Team
+`,
+		es.config.CompanyEmail,
+		es.config.CompanyName,
+	)
+
+	message := es.buildMIMEMessage(notice.Email, subject, body, nil, "")
+
+	if err := es.sendEmail(notice.Email, message); err != nil {
+		return fmt.Errorf("failed to send usage alert email: %w", err)
+	}
+
+	return nil
+}
+
+// UsageAnomalyNotice describes an organization's usage spike for operators
+type UsageAnomalyNotice struct {
+	OrganizationID   string
+	OrganizationName string
+	BillingMonth     time.Time
+	UsedUnits        int64   // Month-to-date billable units
+	BaselineUnits    int64   // Trailing monthly average
+	ExpectedUnits    int64   // Baseline prorated to the elapsed part of the period
+	Multiple         float64 // UsedUnits / ExpectedUnits, 0 when there was no previous usage
+}
+
+// SendUsageAnomalyEmail alerts an operator address to a usage spike before it is billed
+func (es *EmailSender) SendUsageAnomalyEmail(ctx context.Context, to string, notice *UsageAnomalyNotice) error {
+	if !es.config.EnableEmail {
+		return fmt.Errorf("email sending is disabled")
+	}
+
+	increase := fmt.Sprintf("%.1fx the expected amount", notice.Multiple)
+	if notice.ExpectedUnits == 0 {
+		increase = "above the anomaly minimum after no usage in previous months"
+	}
+
+	subject := fmt.Sprintf("Usage anomaly: %s (%s)", notice.OrganizationName, notice.BillingMonth.Format("January 2006"))
+	body := fmt.Sprintf(`Usage for %s (%s) in %s is %s.
+
+Month-to-date: %s %s
+Expected by now: %s %s
+Trailing monthly average: %s %s
+
+A spike like this can mean a leaked API key or a runaway client. Review the
+organization's traffic before the month is invoiced; the overage will be billed
+as usual unless it is adjusted.
+`,
+		notice.OrganizationName,
+		notice.OrganizationID,
+		notice.BillingMonth.Format("January 2006"),
+		increase,
+		formatUsage(notice.UsedUnits), es.config.UnitLabel(),
+		formatUsage(notice.ExpectedUnits), es.config.UnitLabel(),
+		formatUsage(notice.BaselineUnits), es.config.UnitLabel(),
+	)
+
+	message := es.buildMIMEMessage(to, subject, body, nil, "")
+
+	if err := es.sendEmail(to, message); err != nil {
+		return fmt.Errorf("failed to send usage anomaly email: %w", err)
+	}
+
+	return nil
+}
+
+// RunSummary describes one monthly billing run for the completion notification
+type RunSummary struct {
+	BillingMonth time.Time
+	Invoices     *InvoiceSummary // Invoice generation results
+	Processed    int             // Invoices that went through PDF, S3, Stripe and email
+
+	PDFErrors    int
+	S3Errors     int
+	StripeErrors int
+	EmailErrors  int
+	EmailsQueued int            // Failed emails of the run left in send_failed for retry
+	Failures     []InvoiceError // Processing failures ("pdf", "upload", "stripe", "email")
+
+	Duration time.Duration
+	DryRun   bool
+}
+
+// SendRunSummaryEmail sends the completion summary of a billing run to an operator address
+func (es *EmailSender) SendRunSummaryEmail(ctx context.Context, to string, summary *RunSummary) error {
+	if !es.config.EnableEmail {
+		return fmt.Errorf("email sending is disabled")
+	}
+
+	subject := fmt.Sprintf("Billing run for %s: %d invoices, %d errors",
+		summary.BillingMonth.Format("January 2006"), summary.Invoices.SuccessCount, summary.ErrorCount())
+	if summary.DryRun {
+		subject = "[DRY RUN] " + subject
+	}
+
+	message := es.buildMIMEMessage(to, subject, es.buildRunSummaryBody(summary), nil, "")
+
+	if err := es.sendEmail(to, message); err != nil {
+		return fmt.Errorf("failed to send run summary email: %w", err)
+	}
+
+	return nil
+}
+
+// ErrorCount returns the number of errors across all steps of the run
+func (s *RunSummary) ErrorCount() int {
+	return s.Invoices.FailureCount + s.PDFErrors + s.S3Errors + s.StripeErrors + s.EmailErrors
+}
+
+// buildRunSummaryBody creates the plain-text run summary, listing every failed organization
+func (es *EmailSender) buildRunSummaryBody(summary *RunSummary) string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `Billing run summary for %s
+
+Invoices Generated: %d
+Invoices Skipped (already exists): %d
+Invoices Processed: %d
+Total Revenue: %s
+
+Errors:
+  - Invoice Generation: %d
+  - PDF Generation: %d
+  - S3 Upload: %d
+  - Stripe: %d
+  - Email: %d (%d queued for retry)
+
+Processing Time: %s
+Dry Run: %v
+`,
+		summary.BillingMonth.Format("January 2006"),
+		summary.Invoices.SuccessCount,
+		summary.Invoices.SkippedCount,
+		summary.Processed,
+		pricing.FormatPrice(summary.Invoices.TotalRevenue),
+		summary.Invoices.FailureCount,
+		summary.PDFErrors,
+		summary.S3Errors,
+		summary.StripeErrors,
+		summary.EmailErrors,
+		summary.EmailsQueued,
+		summary.Duration.Round(time.Millisecond),
+		summary.DryRun,
+	)
+
+	failures := append(append([]InvoiceError{}, summary.Invoices.Errors...), summary.Failures...)
+	if len(failures) > 0 {
+		buf.WriteString("\nFailed Organizations:\n")
+		for _, invErr := range failures {
+			fmt.Fprintf(&buf, "  - [%s] %s: %v\n", invErr.OrganizationID, invErr.Operation, invErr.Error)
+		}
+	}
+
+	fmt.Fprintf(&buf, "\n-- \n%s Billing Engine\n", es.config.CompanyName)
+
+	return buf.String()
+}
+
 // encodeBase64 encodes data to base64 string
 func encodeBase64(data []byte) string {
 	const base64Table = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
@@ -0,0 +1,62 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Email types recorded in invoice_email_log
+const (
+	EmailTypeInvoice = "invoice" // The invoice with its PDF, sent by the billing job
+)
+
+// EmailLogEntry records a successful email for an invoice
+type EmailLogEntry struct {
+	InvoiceID string
+	EmailType string
+	Recipient string
+	SentAt    time.Time
+}
+
+// emailLog tracks delivered emails per invoice and type (implemented by dbEmailLog)
+type emailLog interface {
+	lastSent(ctx context.Context, invoiceID, emailType string) (*EmailLogEntry, error) // nil if never sent
+	recordSent(ctx context.Context, entry *EmailLogEntry) error
+}
+
+// dbEmailLog stores delivered emails in invoice_email_log
+type dbEmailLog struct {
+	db *sql.DB
+}
+
+func (l *dbEmailLog) lastSent(ctx context.Context, invoiceID, emailType string) (*EmailLogEntry, error) {
+	entry := &EmailLogEntry{InvoiceID: invoiceID, EmailType: emailType}
+	err := l.db.QueryRowContext(ctx,
+		"SELECT recipient, sent_at FROM invoice_email_log WHERE invoice_id = $1 AND email_type = $2",
+		invoiceID, emailType,
+	).Scan(&entry.Recipient, &entry.SentAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email log for invoice %s: %w", invoiceID, err)
+	}
+	return entry, nil
+}
+
+func (l *dbEmailLog) recordSent(ctx context.Context, entry *EmailLogEntry) error {
+	query := `
+		INSERT INTO invoice_email_log (invoice_id, email_type, recipient, sent_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (invoice_id, email_type) DO UPDATE
+		SET recipient = EXCLUDED.recipient,
+		    sent_at = EXCLUDED.sent_at,
+		    send_count = invoice_email_log.send_count + 1
+	`
+	if _, err := l.db.ExecContext(ctx, query, entry.InvoiceID, entry.EmailType, entry.Recipient, entry.SentAt); err != nil {
+		return fmt.Errorf("failed to record email for invoice %s: %w", entry.InvoiceID, err)
+	}
+	return nil
+}
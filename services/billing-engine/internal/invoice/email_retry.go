@@ -0,0 +1,353 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Default email retry settings
+const (
+	DefaultEmailMaxRetries    = 3
+	DefaultEmailRetryInterval = 15 * time.Minute
+)
+
+// InvoiceMailer sends invoice emails (implemented by EmailSender)
+type InvoiceMailer interface {
+	SendInvoiceEmail(ctx context.Context, invoice *Invoice, pdfData []byte) error
+}
+
+// EmailRetryStore persists invoice statuses and email retry state (implemented by InvoiceGenerator)
+type EmailRetryStore interface {
+	UpdateInvoiceStatus(ctx context.Context, invoiceID, status string) error
+	GetInvoiceByID(ctx context.Context, invoiceID string) (*Invoice, error)
+	// DueEmailRetries returns send_failed invoices whose next retry is at or before now, oldest first
+	DueEmailRetries(ctx context.Context, now time.Time, limit int) ([]EmailRetry, error)
+	// RecordEmailFailure saves a failed send; nextRetryAt is nil once retries are exhausted
+	RecordEmailFailure(ctx context.Context, invoiceID string, attempts int, lastError string, nextRetryAt *time.Time) error
+}
+
+// pdfDownloader fetches a stored invoice PDF (implemented by StorageManager)
+type pdfDownloader interface {
+	DownloadPDF(ctx context.Context, invoice *Invoice) ([]byte, error)
+}
+
+// EmailRetry is a failed invoice email awaiting retry, as stored on the invoice
+type EmailRetry struct {
+	InvoiceID   string
+	Attempts    int        // Sends attempted so far, including the initial one
+	NextRetryAt *time.Time // nil = retry as soon as possible
+}
+
+// EmailRetryResult summarizes a retry pass
+type EmailRetryResult struct {
+	Attempted   int
+	Sent        int
+	Exhausted   int
+	Rescheduled int
+}
+
+// emailRetryBatchSize caps the invoices retried per pass
+const emailRetryBatchSize = 100
+
+// EmailRetryQueue sends invoice emails and retries failed sends on a schedule
+// Retry state lives on the invoice (email_attempts, email_next_retry_at), so retries survive
+// restarts and one-off runs. The invoice stays in "send_failed" until a retry succeeds, or moves
+// to "email_failed" once retries are exhausted. Retried emails attach the PDF stored in S3, or
+// one rendered again if it was never uploaded
+type EmailRetryQueue struct {
+	mailer     InvoiceMailer
+	store      EmailRetryStore
+	storage    pdfDownloader
+	renderer   pdfRenderer
+	emailLog   emailLog // Optional; makes Send idempotent across runs
+	maxRetries int
+	interval   time.Duration
+
+	now func() time.Time // Overridable for tests
+}
+
+// NewEmailRetryQueue creates a new email retry queue
+func NewEmailRetryQueue(mailer InvoiceMailer, store EmailRetryStore, storage pdfDownloader, renderer pdfRenderer, maxRetries int, interval time.Duration) *EmailRetryQueue {
+	if maxRetries < 0 {
+		maxRetries = DefaultEmailMaxRetries
+	}
+	if interval <= 0 {
+		interval = DefaultEmailRetryInterval
+	}
+
+	return &EmailRetryQueue{
+		mailer:     mailer,
+		store:      store,
+		storage:    storage,
+		renderer:   renderer,
+		maxRetries: maxRetries,
+		interval:   interval,
+		now:        time.Now,
+	}
+}
+
+// WithEmailLog records delivered invoice emails in invoice_email_log, so that Send skips
+// invoices an earlier run already emailed (e.g. one that crashed before updating the status)
+// Without it, every Send emails the customer
+func (q *EmailRetryQueue) WithEmailLog(db *sql.DB) *EmailRetryQueue {
+	if db != nil {
+		q.emailLog = &dbEmailLog{db: db}
+	}
+	return q
+}
+
+// Send attempts to email an invoice, scheduling a retry on failure
+// On success the invoice moves to "pending"; on failure it moves to "send_failed".
+// An invoice already recorded as emailed is not sent again
+func (q *EmailRetryQueue) Send(ctx context.Context, invoice *Invoice, pdfData []byte) error {
+	return q.send(ctx, invoice, pdfData, false)
+}
+
+// Resend emails an invoice even if it was already sent, e.g. when a customer asks for a copy
+// or its retries were exhausted
+func (q *EmailRetryQueue) Resend(ctx context.Context, invoice *Invoice, pdfData []byte) error {
+	return q.send(ctx, invoice, pdfData, true)
+}
+
+// send emails an invoice; force skips the email log check
+func (q *EmailRetryQueue) send(ctx context.Context, invoice *Invoice, pdfData []byte, force bool) error {
+	if !force && q.emailLog != nil {
+		sent, err := q.emailLog.lastSent(ctx, invoice.ID, EmailTypeInvoice)
+		if err != nil {
+			// Not sending is safer than a duplicate; the next run checks again
+			return err
+		}
+		if sent != nil {
+			log.Printf("[EmailRetry] Invoice %s was already emailed to %s at %s, skipping",
+				invoice.InvoiceNumber, sent.Recipient, sent.SentAt.Format(time.RFC3339))
+			q.completeSkipped(ctx, invoice, sent)
+			return nil
+		}
+	}
+
+	err := q.mailer.SendInvoiceEmail(ctx, invoice, pdfData)
+	if err == nil {
+		return q.markSent(ctx, invoice)
+	}
+
+	// No retries configured - fail immediately
+	if q.maxRetries == 0 {
+		q.markExhausted(ctx, invoice, 1, err)
+		return fmt.Errorf("failed to send invoice email: %w", err)
+	}
+
+	nextRetryAt, scheduleErr := q.scheduleRetry(ctx, invoice, 1, err)
+	if scheduleErr != nil {
+		return fmt.Errorf("failed to send invoice email (%v): %w", scheduleErr, err)
+	}
+
+	return fmt.Errorf("failed to send invoice email (queued for retry at %s): %w",
+		nextRetryAt.Format(time.RFC3339), err)
+}
+
+// ProcessDue retries every send_failed invoice email whose retry time has passed
+func (q *EmailRetryQueue) ProcessDue(ctx context.Context) (*EmailRetryResult, error) {
+	due, err := q.store.DueEmailRetries(ctx, q.now(), emailRetryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EmailRetryResult{}
+	for _, retry := range due {
+		invoice, err := q.store.GetInvoiceByID(ctx, retry.InvoiceID)
+		if err != nil {
+			log.Printf("[EmailRetry] ERROR: Failed to load invoice %s for email retry: %v", retry.InvoiceID, err)
+			continue
+		}
+		result.Attempted++
+
+		err = q.retry(ctx, invoice)
+		if err == nil {
+			if err := q.markSent(ctx, invoice); err != nil {
+				log.Printf("[EmailRetry] ERROR: %v", err)
+			}
+			result.Sent++
+			continue
+		}
+
+		// Attempts counts the initial send, so the total allowed is maxRetries + 1
+		attempts := retry.Attempts + 1
+		if attempts > q.maxRetries {
+			q.markExhausted(ctx, invoice, attempts, err)
+			result.Exhausted++
+			continue
+		}
+
+		nextRetryAt, scheduleErr := q.scheduleRetry(ctx, invoice, attempts, err)
+		if scheduleErr != nil {
+			log.Printf("[EmailRetry] ERROR: %v", scheduleErr)
+			continue
+		}
+		result.Rescheduled++
+		log.Printf("[EmailRetry] Retry %d/%d failed for invoice %s, next attempt at %s: %v",
+			attempts-1, q.maxRetries, invoice.InvoiceNumber, nextRetryAt.Format(time.RFC3339), err)
+	}
+
+	return result, nil
+}
+
+// retry emails an invoice again with its PDF
+func (q *EmailRetryQueue) retry(ctx context.Context, invoice *Invoice) error {
+	pdfData, err := q.invoicePDF(ctx, invoice)
+	if err != nil {
+		return err
+	}
+	return q.mailer.SendInvoiceEmail(ctx, invoice, pdfData)
+}
+
+// invoicePDF returns the invoice's PDF from S3, rendering it again if it was never uploaded
+// or cannot be fetched
+func (q *EmailRetryQueue) invoicePDF(ctx context.Context, invoice *Invoice) ([]byte, error) {
+	if invoice.PDFUrl != "" && q.storage != nil {
+		pdfData, err := q.storage.DownloadPDF(ctx, invoice)
+		if err == nil {
+			return pdfData, nil
+		}
+		log.Printf("[EmailRetry] WARNING: Failed to download PDF of invoice %s, rendering it again: %v", invoice.InvoiceNumber, err)
+	}
+
+	pdfData, err := q.renderer.GeneratePDF(invoice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render invoice PDF: %w", err)
+	}
+	return pdfData, nil
+}
+
+// backoff returns the delay before the next retry (doubles after each attempt)
+func (q *EmailRetryQueue) backoff(attempts int) time.Duration {
+	delay := q.interval
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+	}
+	return delay
+}
+
+// markSent records a successful send
+func (q *EmailRetryQueue) markSent(ctx context.Context, invoice *Invoice) error {
+	now := q.now()
+	invoice.SentAt = &now
+
+	if q.emailLog != nil {
+		entry := &EmailLogEntry{
+			InvoiceID: invoice.ID,
+			EmailType: EmailTypeInvoice,
+			Recipient: invoice.CustomerEmail,
+			SentAt:    now,
+		}
+		// The email is out either way; without the record a rerun may send it again
+		if err := q.emailLog.recordSent(ctx, entry); err != nil {
+			log.Printf("[EmailRetry] ERROR: %v", err)
+		}
+	}
+
+	if err := q.store.UpdateInvoiceStatus(ctx, invoice.ID, InvoiceStatusPending); err != nil {
+		return fmt.Errorf("failed to update invoice status: %w", err)
+	}
+	invoice.Status = InvoiceStatusPending
+
+	return nil
+}
+
+// completeSkipped finishes an invoice whose email went out in an earlier run
+// A run that crashed after sending may have left it in "draft", "send_failed" or "email_failed"
+func (q *EmailRetryQueue) completeSkipped(ctx context.Context, invoice *Invoice, sent *EmailLogEntry) {
+	sentAt := sent.SentAt
+	invoice.SentAt = &sentAt
+
+	switch invoice.Status {
+	case InvoiceStatusDraft, InvoiceStatusSendFailed, InvoiceStatusEmailFailed:
+		q.setStatus(ctx, invoice, InvoiceStatusPending)
+	}
+}
+
+// scheduleRetry records a failed send and moves the invoice to send_failed until the next attempt
+func (q *EmailRetryQueue) scheduleRetry(ctx context.Context, invoice *Invoice, attempts int, sendErr error) (time.Time, error) {
+	nextRetryAt := q.now().Add(q.backoff(attempts))
+	if err := q.store.RecordEmailFailure(ctx, invoice.ID, attempts, sendErr.Error(), &nextRetryAt); err != nil {
+		return time.Time{}, err
+	}
+	q.setStatus(ctx, invoice, InvoiceStatusSendFailed)
+	return nextRetryAt, nil
+}
+
+// markExhausted moves an invoice to email_failed after the final attempt
+func (q *EmailRetryQueue) markExhausted(ctx context.Context, invoice *Invoice, attempts int, sendErr error) {
+	log.Printf("[EmailRetry] ERROR: Giving up on invoice %s after %d attempts: %v",
+		invoice.InvoiceNumber, attempts, sendErr)
+	if err := q.store.RecordEmailFailure(ctx, invoice.ID, attempts, sendErr.Error(), nil); err != nil {
+		log.Printf("[EmailRetry] ERROR: %v", err)
+	}
+	q.setStatus(ctx, invoice, InvoiceStatusEmailFailed)
+}
+
+// setStatus updates the invoice status, logging persistence errors
+func (q *EmailRetryQueue) setStatus(ctx context.Context, invoice *Invoice, status string) {
+	if err := q.store.UpdateInvoiceStatus(ctx, invoice.ID, status); err != nil {
+		log.Printf("[EmailRetry] ERROR: Failed to set invoice %s to %s: %v", invoice.ID, status, err)
+		return
+	}
+	invoice.Status = status
+}
+
+// DueEmailRetries returns send_failed invoices whose next email retry is at or before now
+func (g *InvoiceGenerator) DueEmailRetries(ctx context.Context, now time.Time, limit int) ([]EmailRetry, error) {
+	query := `
+		SELECT id, email_attempts, email_next_retry_at
+		FROM invoices
+		WHERE status = 'send_failed'
+		  AND (email_next_retry_at IS NULL OR email_next_retry_at <= $1)
+		ORDER BY email_next_retry_at NULLS FIRST, id
+		LIMIT $2
+	`
+
+	rows, err := g.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice email retries: %w", err)
+	}
+	defer rows.Close()
+
+	retries := make([]EmailRetry, 0)
+	for rows.Next() {
+		var retry EmailRetry
+		var nextRetryAt sql.NullTime
+		if err := rows.Scan(&retry.InvoiceID, &retry.Attempts, &nextRetryAt); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice email retry: %w", err)
+		}
+		if nextRetryAt.Valid {
+			retry.NextRetryAt = &nextRetryAt.Time
+		}
+		retries = append(retries, retry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invoice email retries: %w", err)
+	}
+
+	return retries, nil
+}
+
+// RecordEmailFailure saves the attempt count, error and next retry time of a failed invoice email
+func (g *InvoiceGenerator) RecordEmailFailure(ctx context.Context, invoiceID string, attempts int, lastError string, nextRetryAt *time.Time) error {
+	query := `
+		UPDATE invoices
+		SET email_attempts = $1, email_last_error = $2, email_next_retry_at = $3, updated_at = NOW()
+		WHERE id = $4
+	`
+
+	result, err := g.db.ExecContext(ctx, query, attempts, lastError, nextRetryAt, invoiceID)
+	if err != nil {
+		return fmt.Errorf("failed to record email failure for invoice %s: %w", invoiceID, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("invoice not found: %s", invoiceID)
+	}
+	return nil
+}
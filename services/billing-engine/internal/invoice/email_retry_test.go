@@ -0,0 +1,400 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// mockMailer fails the first N sends, then succeeds
+type mockMailer struct {
+	failures int
+	calls    int
+	lastPDF  []byte
+}
+
+func (m *mockMailer) SendInvoiceEmail(ctx context.Context, invoice *Invoice, pdfData []byte) error {
+	m.calls++
+	m.lastPDF = pdfData
+	if m.calls <= m.failures {
+		return errors.New("smtp: connection refused")
+	}
+	return nil
+}
+
+// fakeRetryStore keeps invoices and their email retry state in memory, like the invoices table
+type fakeRetryStore struct {
+	invoices map[string]*Invoice
+	retries  map[string]*EmailRetry
+	statuses []string // Every status transition
+}
+
+func newFakeRetryStore(invoices ...*Invoice) *fakeRetryStore {
+	store := &fakeRetryStore{invoices: make(map[string]*Invoice), retries: make(map[string]*EmailRetry)}
+	for _, inv := range invoices {
+		stored := *inv
+		store.invoices[inv.ID] = &stored
+	}
+	return store
+}
+
+func (s *fakeRetryStore) UpdateInvoiceStatus(ctx context.Context, invoiceID, status string) error {
+	inv, ok := s.invoices[invoiceID]
+	if !ok {
+		return fmt.Errorf("invoice not found: %s", invoiceID)
+	}
+	if err := ValidateTransition(inv.Status, status); err != nil {
+		return err
+	}
+	inv.Status = status
+	s.statuses = append(s.statuses, status)
+	return nil
+}
+
+func (s *fakeRetryStore) GetInvoiceByID(ctx context.Context, invoiceID string) (*Invoice, error) {
+	inv, ok := s.invoices[invoiceID]
+	if !ok {
+		return nil, fmt.Errorf("invoice not found: %s", invoiceID)
+	}
+	loaded := *inv
+	return &loaded, nil
+}
+
+func (s *fakeRetryStore) DueEmailRetries(ctx context.Context, now time.Time, limit int) ([]EmailRetry, error) {
+	var due []EmailRetry
+	for id, inv := range s.invoices {
+		retry := s.retries[id]
+		if inv.Status != InvoiceStatusSendFailed || retry == nil {
+			continue
+		}
+		if retry.NextRetryAt == nil || !retry.NextRetryAt.After(now) {
+			due = append(due, *retry)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeRetryStore) RecordEmailFailure(ctx context.Context, invoiceID string, attempts int, lastError string, nextRetryAt *time.Time) error {
+	s.retries[invoiceID] = &EmailRetry{InvoiceID: invoiceID, Attempts: attempts, NextRetryAt: nextRetryAt}
+	return nil
+}
+
+func (s *fakeRetryStore) status(invoiceID string) string {
+	return s.invoices[invoiceID].Status
+}
+
+// fakePDFs serves stored PDFs and renders new ones, counting both
+type fakePDFs struct {
+	stored    []byte // nil = download fails
+	downloads int
+	renders   int
+}
+
+func (p *fakePDFs) DownloadPDF(ctx context.Context, invoice *Invoice) ([]byte, error) {
+	p.downloads++
+	if p.stored == nil {
+		return nil, errors.New("failed to download from S3: NoSuchKey")
+	}
+	return p.stored, nil
+}
+
+func (p *fakePDFs) GeneratePDF(invoice *Invoice) ([]byte, error) {
+	p.renders++
+	return []byte("rendered " + invoice.InvoiceNumber), nil
+}
+
+// newTestRetryQueue creates a queue over store with a controllable clock
+func newTestRetryQueue(mailer InvoiceMailer, store EmailRetryStore, pdfs *fakePDFs, maxRetries int) (*EmailRetryQueue, *time.Time) {
+	now := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	q := NewEmailRetryQueue(mailer, store, pdfs, pdfs, maxRetries, time.Minute)
+	q.now = func() time.Time { return now }
+	return q, &now
+}
+
+// TestEmailRetryQueue_SendSucceeds tests the happy path
+func TestEmailRetryQueue_SendSucceeds(t *testing.T) {
+	mailer := &mockMailer{}
+	inv := createTestInvoice()
+	store := newFakeRetryStore(inv)
+	q, _ := newTestRetryQueue(mailer, store, &fakePDFs{}, 3)
+
+	if err := q.Send(context.Background(), inv, []byte("pdf")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if inv.Status != InvoiceStatusPending || store.status(inv.ID) != InvoiceStatusPending {
+		t.Errorf("Status = %s (stored %s), want %s", inv.Status, store.status(inv.ID), InvoiceStatusPending)
+	}
+	if inv.SentAt == nil {
+		t.Error("Expected SentAt to be set")
+	}
+	if len(store.retries) != 0 {
+		t.Errorf("Retries = %v, want none", store.retries)
+	}
+}
+
+// TestEmailRetryQueue_FailThenSucceed tests a send failing then succeeding on retry
+func TestEmailRetryQueue_FailThenSucceed(t *testing.T) {
+	mailer := &mockMailer{failures: 1}
+	inv := createTestInvoice()
+	inv.PDFUrl = "s3://invoices/" + inv.ID + ".pdf"
+	store := newFakeRetryStore(inv)
+	pdfs := &fakePDFs{stored: []byte("stored pdf")}
+	q, now := newTestRetryQueue(mailer, store, pdfs, 3)
+
+	ctx := context.Background()
+	if err := q.Send(ctx, inv, []byte("pdf")); err == nil {
+		t.Fatal("Expected error from first send")
+	}
+
+	if store.status(inv.ID) != InvoiceStatusSendFailed {
+		t.Errorf("Status after failure = %s, want %s", store.status(inv.ID), InvoiceStatusSendFailed)
+	}
+	retry := store.retries[inv.ID]
+	if retry == nil || retry.Attempts != 1 || retry.NextRetryAt == nil || !retry.NextRetryAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Retry = %+v, want 1 attempt retried in a minute", retry)
+	}
+
+	// Not yet due - nothing should be attempted
+	result, err := q.ProcessDue(ctx)
+	if err != nil {
+		t.Fatalf("ProcessDue() error = %v", err)
+	}
+	if result.Attempted != 0 {
+		t.Errorf("Attempted = %d before retry time, want 0", result.Attempted)
+	}
+
+	// Advance past the retry interval
+	*now = now.Add(time.Minute)
+	result, err = q.ProcessDue(ctx)
+	if err != nil {
+		t.Fatalf("ProcessDue() error = %v", err)
+	}
+
+	if result.Sent != 1 || result.Rescheduled != 0 {
+		t.Errorf("Result = %+v, want 1 sent and none rescheduled", result)
+	}
+	if store.status(inv.ID) != InvoiceStatusPending {
+		t.Errorf("Status after retry = %s, want %s", store.status(inv.ID), InvoiceStatusPending)
+	}
+	if string(mailer.lastPDF) != "stored pdf" || pdfs.renders != 0 {
+		t.Errorf("Retried with %q after %d renders, want the PDF stored in S3", mailer.lastPDF, pdfs.renders)
+	}
+
+	expected := []string{InvoiceStatusSendFailed, InvoiceStatusPending}
+	if len(store.statuses) != len(expected) {
+		t.Fatalf("Status transitions = %v, want %v", store.statuses, expected)
+	}
+	for i, status := range expected {
+		if store.statuses[i] != status {
+			t.Errorf("Transition %d = %s, want %s", i, store.statuses[i], status)
+		}
+	}
+}
+
+// TestEmailRetryQueue_RetriesAfterRestart tests that a new process retries an email an earlier one failed
+// to send, rendering the PDF again when it was never uploaded
+func TestEmailRetryQueue_RetriesAfterRestart(t *testing.T) {
+	inv := createTestInvoice()
+	store := newFakeRetryStore(inv)
+
+	first, now := newTestRetryQueue(&mockMailer{failures: 1}, store, &fakePDFs{}, 3)
+	if err := first.Send(context.Background(), inv, []byte("pdf")); err == nil {
+		t.Fatal("Expected error from first send")
+	}
+
+	mailer := &mockMailer{}
+	pdfs := &fakePDFs{}
+	restarted, later := newTestRetryQueue(mailer, store, pdfs, 3)
+	*later = now.Add(time.Hour)
+
+	result, err := restarted.ProcessDue(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessDue() error = %v", err)
+	}
+	if result.Attempted != 1 || result.Sent != 1 {
+		t.Errorf("Result = %+v, want the failed email sent", result)
+	}
+	if store.status(inv.ID) != InvoiceStatusPending {
+		t.Errorf("Status = %s, want %s", store.status(inv.ID), InvoiceStatusPending)
+	}
+	if string(mailer.lastPDF) != "rendered "+inv.InvoiceNumber || pdfs.downloads != 0 {
+		t.Errorf("Retried with %q after %d downloads, want a rendered PDF", mailer.lastPDF, pdfs.downloads)
+	}
+}
+
+// TestEmailRetryQueue_RendersMissingPDF tests that a PDF missing from S3 is rendered again
+func TestEmailRetryQueue_RendersMissingPDF(t *testing.T) {
+	inv := createTestInvoice()
+	inv.PDFUrl = "s3://invoices/" + inv.ID + ".pdf"
+	store := newFakeRetryStore(inv)
+	mailer := &mockMailer{failures: 1}
+	pdfs := &fakePDFs{}
+	q, now := newTestRetryQueue(mailer, store, pdfs, 3)
+
+	q.Send(context.Background(), inv, []byte("pdf"))
+	*now = now.Add(time.Minute)
+	if _, err := q.ProcessDue(context.Background()); err != nil {
+		t.Fatalf("ProcessDue() error = %v", err)
+	}
+
+	if pdfs.downloads != 1 || pdfs.renders != 1 || string(mailer.lastPDF) != "rendered "+inv.InvoiceNumber {
+		t.Errorf("Retried with %q after %d downloads and %d renders, want a rendered PDF after a failed download",
+			mailer.lastPDF, pdfs.downloads, pdfs.renders)
+	}
+}
+
+// TestEmailRetryQueue_ExhaustsRetries tests that exhausting retries marks the email failed, not the payment
+func TestEmailRetryQueue_ExhaustsRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+	}{
+		{"No retries", 0},
+		{"One retry", 1},
+		{"Three retries", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := &mockMailer{failures: 100}
+			inv := createTestInvoice()
+			store := newFakeRetryStore(inv)
+			q, now := newTestRetryQueue(mailer, store, &fakePDFs{}, tt.maxRetries)
+
+			ctx := context.Background()
+			if err := q.Send(ctx, inv, []byte("pdf")); err == nil {
+				t.Fatal("Expected error from first send")
+			}
+
+			// Advance well past each backoff window
+			for i := 0; i < tt.maxRetries; i++ {
+				if store.status(inv.ID) != InvoiceStatusSendFailed {
+					t.Errorf("Status before retry %d = %s, want %s", i+1, store.status(inv.ID), InvoiceStatusSendFailed)
+				}
+				*now = now.Add(24 * time.Hour)
+				if _, err := q.ProcessDue(ctx); err != nil {
+					t.Fatalf("ProcessDue() error = %v", err)
+				}
+			}
+
+			if store.status(inv.ID) != InvoiceStatusEmailFailed {
+				t.Errorf("Status = %s, want %s", store.status(inv.ID), InvoiceStatusEmailFailed)
+			}
+			if mailer.calls != tt.maxRetries+1 {
+				t.Errorf("Send attempts = %d, want %d", mailer.calls, tt.maxRetries+1)
+			}
+			if retry := store.retries[inv.ID]; retry == nil || retry.Attempts != tt.maxRetries+1 || retry.NextRetryAt != nil {
+				t.Errorf("Retry = %+v, want %d attempts and no next retry", retry, tt.maxRetries+1)
+			}
+
+			// Exhausted emails are no longer retried
+			*now = now.Add(24 * time.Hour)
+			if result, _ := q.ProcessDue(ctx); result.Attempted != 0 {
+				t.Errorf("Attempted = %d after exhaustion, want 0", result.Attempted)
+			}
+		})
+	}
+}
+
+// TestEmailRetryQueue_Backoff tests that the retry delay doubles per attempt
+func TestEmailRetryQueue_Backoff(t *testing.T) {
+	q := NewEmailRetryQueue(&mockMailer{}, newFakeRetryStore(), &fakePDFs{}, &fakePDFs{}, 3, time.Minute)
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 1 * time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := q.backoff(tt.attempts); got != tt.expected {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+// fakeEmailLog keeps delivered emails in memory
+type fakeEmailLog struct {
+	entries map[string]*EmailLogEntry
+	records int
+}
+
+func newFakeEmailLog() *fakeEmailLog {
+	return &fakeEmailLog{entries: make(map[string]*EmailLogEntry)}
+}
+
+func (l *fakeEmailLog) lastSent(ctx context.Context, invoiceID, emailType string) (*EmailLogEntry, error) {
+	return l.entries[invoiceID+"/"+emailType], nil
+}
+
+func (l *fakeEmailLog) recordSent(ctx context.Context, entry *EmailLogEntry) error {
+	l.records++
+	l.entries[entry.InvoiceID+"/"+entry.EmailType] = entry
+	return nil
+}
+
+// TestEmailRetryQueue_SendIsIdempotent tests that a second send of the same invoice is a no-op
+func TestEmailRetryQueue_SendIsIdempotent(t *testing.T) {
+	mailer := &mockMailer{}
+	inv := createTestInvoice()
+	q, _ := newTestRetryQueue(mailer, newFakeRetryStore(inv), &fakePDFs{}, 3)
+	emails := newFakeEmailLog()
+	q.emailLog = emails
+
+	for i := 0; i < 2; i++ {
+		if err := q.Send(context.Background(), inv, []byte("pdf")); err != nil {
+			t.Fatalf("Send() #%d error = %v", i+1, err)
+		}
+	}
+
+	if mailer.calls != 1 {
+		t.Errorf("Mailer called %d times, want 1", mailer.calls)
+	}
+	entry := emails.entries[inv.ID+"/"+EmailTypeInvoice]
+	if entry == nil || entry.Recipient != inv.CustomerEmail || entry.SentAt.IsZero() {
+		t.Errorf("Email log entry = %+v, want the recipient and send time", entry)
+	}
+
+	// A forced resend goes out and is recorded again
+	if err := q.Resend(context.Background(), inv, []byte("pdf")); err != nil {
+		t.Fatalf("Resend() error = %v", err)
+	}
+	if mailer.calls != 2 || emails.records != 2 {
+		t.Errorf("After Resend() mailer calls = %d, log records = %d, want 2 and 2", mailer.calls, emails.records)
+	}
+}
+
+// TestEmailRetryQueue_SkipCompletesInterruptedRun tests a rerun after a crash between send and status update
+func TestEmailRetryQueue_SkipCompletesInterruptedRun(t *testing.T) {
+	mailer := &mockMailer{}
+	inv := createTestInvoice()
+	inv.Status = InvoiceStatusDraft
+	store := newFakeRetryStore(inv)
+	q, now := newTestRetryQueue(mailer, store, &fakePDFs{}, 3)
+	emails := newFakeEmailLog()
+	q.emailLog = emails
+
+	emails.entries[inv.ID+"/"+EmailTypeInvoice] = &EmailLogEntry{
+		InvoiceID: inv.ID, EmailType: EmailTypeInvoice, Recipient: inv.CustomerEmail, SentAt: now.Add(-time.Hour),
+	}
+
+	if err := q.Send(context.Background(), inv, []byte("pdf")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if mailer.calls != 0 {
+		t.Errorf("Mailer called %d times, want 0", mailer.calls)
+	}
+	if inv.Status != InvoiceStatusPending || len(store.statuses) != 1 {
+		t.Errorf("Status = %s after %v, want %s", inv.Status, store.statuses, InvoiceStatusPending)
+	}
+	if inv.SentAt == nil || !inv.SentAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("SentAt = %v, want the recorded send time", inv.SentAt)
+	}
+}
@@ -3,7 +3,12 @@ package invoice
 import (
 	"bytes"
 	"context"
+	"errors"
 	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
 	"strings"
 	"testing"
 	"time"
@@ -39,11 +44,11 @@ func TestEmailSender_buildEmailBody(t *testing.T) {
 			name:    "Standard invoice email",
 			invoice: invoice,
 			expectedContains: []string{
-				"Invoice #" + invoice.InvoiceNumber,
+				"Invoice Number: " + invoice.InvoiceNumber,
 				invoice.OrganizationName,
 				"$109.08", // Total amount
-				invoice.InvoiceDate.Format("Jan 2, 2006"),
-				invoice.DueDate.Format("Jan 2, 2006"),
+				invoice.InvoiceDate.Format("January 2, 2006"),
+				invoice.DueDate.Format("January 2, 2006"),
 				"Growth Plan",
 			},
 		},
@@ -55,10 +60,23 @@ func TestEmailSender_buildEmailBody(t *testing.T) {
 				return inv
 			}(),
 			expectedContains: []string{
-				"Invoice #",
+				"Invoice Number:",
 				"https://invoice.stripe.com/test",
 			},
 		},
+		{
+			name: "Invoice with PO number and custom fields",
+			invoice: func() *Invoice {
+				inv := createTestInvoice()
+				inv.PONumber = "PO-7781"
+				inv.CustomFields = map[string]string{"Cost Center": "CC-42"}
+				return inv
+			}(),
+			expectedContains: []string{
+				"- PO Number: PO-7781",
+				"- Cost Center: CC-42",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -114,7 +132,7 @@ func TestEmailSender_buildMIMEMessage(t *testing.T) {
 		expectedHeaders := []string{
 			"MIME-Version: 1.0",
 			"Content-Type: multipart/mixed",
-			"From: " + config.FromEmail,
+			"From: " + config.FromName + " <" + config.FromEmail + ">",
 			"To: " + invoice.CustomerEmail,
 			"Subject: " + subject,
 		}
@@ -166,6 +184,99 @@ func TestEmailSender_buildMIMEMessage(t *testing.T) {
 	})
 }
 
+// TestEmailSender_buildMIMEMessageWithHTML tests the nested multipart/alternative body
+func TestEmailSender_buildMIMEMessageWithHTML(t *testing.T) {
+	sender := NewEmailSender(createTestConfig())
+	invoice := createTestInvoice()
+	invoice.CustomerName = "Acme <Widgets> & Co"
+	pdfData := []byte("%PDF-1.4\nTest PDF content")
+
+	textBody := sender.buildEmailBody(invoice)
+	htmlBody, err := sender.buildHTMLBody(invoice)
+	if err != nil {
+		t.Fatalf("buildHTMLBody() error = %v", err)
+	}
+
+	msg := sender.buildMIMEMessageWithHTML(invoice.CustomerEmail, "Test Invoice", textBody, htmlBody, pdfData, invoice.InvoiceNumber)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Top-level Content-Type = %q (%v), want multipart/mixed", mediaType, err)
+	}
+	mixedBoundary := params["boundary"]
+
+	mixed := multipart.NewReader(parsed.Body, mixedBoundary)
+
+	// Part 1: multipart/alternative with text then HTML
+	bodyPart, err := mixed.NextPart()
+	if err != nil {
+		t.Fatalf("Failed to read body part: %v", err)
+	}
+	altType, altParams, err := mime.ParseMediaType(bodyPart.Header.Get("Content-Type"))
+	if err != nil || altType != "multipart/alternative" {
+		t.Fatalf("Body Content-Type = %q (%v), want multipart/alternative", altType, err)
+	}
+	if altParams["boundary"] == mixedBoundary {
+		t.Error("Expected nested boundary to differ from the outer boundary")
+	}
+
+	alt := multipart.NewReader(bodyPart, altParams["boundary"])
+	var contentTypes []string
+	for {
+		part, err := alt.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read alternative part: %v", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		contentTypes = append(contentTypes, partType)
+
+		// multipart.Reader decodes quoted-printable parts transparently
+		content, _ := io.ReadAll(part)
+		switch partType {
+		case "text/plain":
+			if strings.TrimSuffix(string(content), "\r\n") != textBody {
+				t.Error("Expected plain-text part to match buildEmailBody exactly")
+			}
+		case "text/html":
+			if !strings.Contains(string(content), "Acme &lt;Widgets&gt; &amp; Co") {
+				t.Error("Expected customer name to be HTML-escaped")
+			}
+			if !strings.Contains(string(content), invoice.InvoiceNumber) {
+				t.Error("Expected invoice number in HTML part")
+			}
+		}
+	}
+	if strings.Join(contentTypes, ",") != "text/plain,text/html" {
+		t.Errorf("Alternative parts = %v, want [text/plain text/html]", contentTypes)
+	}
+
+	// Part 2: the PDF attachment
+	attachment, err := mixed.NextPart()
+	if err != nil {
+		t.Fatalf("Failed to read attachment: %v", err)
+	}
+	if attachment.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("Attachment Content-Type = %q, want application/pdf", attachment.Header.Get("Content-Type"))
+	}
+	if _, err := mixed.NextPart(); err != io.EOF {
+		t.Errorf("Expected exactly two top-level parts, got err = %v", err)
+	}
+
+	// Boundaries are unique per message
+	other := sender.buildMIMEMessageWithHTML(invoice.CustomerEmail, "Test Invoice", textBody, htmlBody, pdfData, invoice.InvoiceNumber)
+	if bytes.Contains(other, []byte(mixedBoundary)) {
+		t.Error("Expected a new boundary for each message")
+	}
+}
+
 // TestEmailSender_encodeBase64 tests base64 encoding
 func TestEmailSender_encodeBase64(t *testing.T) {
 	tests := []struct {
@@ -272,7 +383,7 @@ func TestEmailSender_SendPaymentReminderEmail(t *testing.T) {
 
 	t.Run("Reminder email content", func(t *testing.T) {
 		// We can test the email body generation without actually sending
-		body := sender.buildEmailBody(invoice)
+		body := sender.buildReminderBody(invoice)
 
 		// Should mention overdue status
 		if !strings.Contains(body, "overdue") && !strings.Contains(body, "past due") {
@@ -352,7 +463,7 @@ func TestEmailSender_EmailFormatting(t *testing.T) {
 		}
 
 		// Check date formatting
-		expectedDate := invoice.InvoiceDate.Format("Jan 2, 2006")
+		expectedDate := invoice.InvoiceDate.Format("January 2, 2006")
 		if !strings.Contains(body, expectedDate) {
 			t.Errorf("Expected date format %q", expectedDate)
 		}
@@ -591,3 +702,65 @@ func BenchmarkEmailSender_encodeBase64(b *testing.B) {
 		encodeBase64(data)
 	}
 }
+
+// TestEmailSender_buildRunSummaryBody tests the billing run completion summary
+func TestEmailSender_buildRunSummaryBody(t *testing.T) {
+	sender := NewEmailSender(createTestConfig())
+
+	summary := &RunSummary{
+		BillingMonth: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Invoices: &InvoiceSummary{
+			SuccessCount: 42,
+			FailureCount: 1,
+			SkippedCount: 3,
+			TotalRevenue: 1234567,
+			Errors: []InvoiceError{
+				{OrganizationID: "org-failed", Operation: "generate", Error: errors.New("no billing plan")},
+			},
+		},
+		Processed:    41,
+		S3Errors:     2,
+		EmailErrors:  1,
+		EmailsQueued: 1,
+		Failures: []InvoiceError{
+			{OrganizationID: "org-bounced", Operation: "email", Error: errors.New("mailbox unavailable")},
+		},
+		Duration: 95 * time.Second,
+	}
+
+	body := sender.buildRunSummaryBody(summary)
+
+	for _, want := range []string{
+		"January 2026",
+		"Invoices Generated: 42",
+		"Invoices Skipped (already exists): 3",
+		"Invoices Processed: 41",
+		"Total Revenue: $12345.67",
+		"Invoice Generation: 1",
+		"S3 Upload: 2",
+		"Email: 1 (1 queued for retry)",
+		"Processing Time: 1m35s",
+		"[org-failed] generate: no billing plan",
+		"[org-bounced] email: mailbox unavailable",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Run summary body missing %q:\n%s", want, body)
+		}
+	}
+
+	if got := summary.ErrorCount(); got != 4 {
+		t.Errorf("ErrorCount() = %d, want 4", got)
+	}
+}
+
+// TestEmailSender_SendRunSummaryEmail_Disabled tests that the summary honors ENABLE_EMAIL
+func TestEmailSender_SendRunSummaryEmail_Disabled(t *testing.T) {
+	config := createTestConfig()
+	config.EnableEmail = false
+	sender := NewEmailSender(config)
+
+	err := sender.SendRunSummaryEmail(context.Background(), "ops@example.com", &RunSummary{Invoices: &InvoiceSummary{}})
+	if err == nil {
+		t.Error("Expected error when email is disabled")
+	}
+}
@@ -3,11 +3,24 @@ package invoice
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
 )
 
+// errInvoiceExists is returned by saveInvoice when the org already has an invoice for the period
+var errInvoiceExists = errors.New("invoice already exists for billing period")
+
 // GenerateMonthly generates invoices for all organizations for the specified month
+// Organizations with a parent are billed on the parent's consolidated invoice instead of their own
+// Each invoice covers a billing period that closes during the month: the calendar month itself,
+// or for anchored organizations the anchor-to-anchor period that ended in it
 func (g *InvoiceGenerator) GenerateMonthly(ctx context.Context, month time.Time) (*InvoiceSummary, error) {
 	startTime := time.Now()
 	summary := &InvoiceSummary{
@@ -23,147 +36,253 @@ func (g *InvoiceGenerator) GenerateMonthly(ctx context.Context, month time.Time)
 		return nil, fmt.Errorf("failed to get billing records: %w", err)
 	}
 
-	summary.TotalInvoices = len(billingRecords)
+	// Child organizations roll up to their parent's consolidated invoice
+	parents, err := g.getParentOrganizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parent organizations: %w", err)
+	}
+	standalone, groups := splitConsolidated(billingRecords, parents)
 
-	// Generate invoice for each billing record
-	for _, record := range billingRecords {
-		invoice, err := g.CreateFromBillingRecord(ctx, record)
-		if err != nil {
-			summary.FailureCount++
-			summary.Errors = append(summary.Errors, InvoiceError{
-				OrganizationID: record.OrganizationID,
-				Operation:      "generate",
-				Error:          err,
-				Timestamp:      time.Now(),
-			})
-			continue
-		}
+	summary.TotalInvoices = len(standalone) + len(groups)
+
+	// Generate invoices across the worker pool
+	generateConcurrently(ctx, standalone, g.config.Workers(), g.createFromBillingRecord, summary)
 
-		summary.SuccessCount++
-		summary.TotalRevenue += invoice.TotalCents
+	// One job per parent; the placeholder record only carries the parent's ID
+	parentRecords := make([]*BillingRecord, 0, len(groups))
+	for _, parentID := range parentIDs(groups) {
+		parentRecords = append(parentRecords, &BillingRecord{OrganizationID: parentID})
 	}
+	createConsolidated := func(ctx context.Context, record *BillingRecord) (*Invoice, bool, error) {
+		return g.createConsolidatedInvoice(ctx, record.OrganizationID, billingMonth, groups[record.OrganizationID])
+	}
+	generateConcurrently(ctx, parentRecords, g.config.Workers(), createConsolidated, summary)
 
 	summary.ProcessingTime = time.Since(startTime)
 	return summary, nil
 }
 
+// invoiceCreator creates one invoice, reporting false when an existing invoice was returned
+type invoiceCreator func(ctx context.Context, record *BillingRecord) (*Invoice, bool, error)
+
+// generateConcurrently runs create for every record on a pool of workers and tallies the results
+func generateConcurrently(ctx context.Context, records []*BillingRecord, workers int, create invoiceCreator, summary *InvoiceSummary) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan *BillingRecord)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for record := range jobs {
+				invoice, created, err := create(ctx, record)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					summary.FailureCount++
+					summary.Errors = append(summary.Errors, InvoiceError{
+						OrganizationID: record.OrganizationID,
+						Operation:      "generate",
+						Error:          err,
+						Timestamp:      time.Now(),
+					})
+				case !created:
+					// Re-runs for the same month leave existing invoices untouched
+					summary.SkippedCount++
+				default:
+					summary.SuccessCount++
+					summary.TotalRevenue += invoice.TotalCents
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, record := range records {
+		jobs <- record
+	}
+	close(jobs)
+
+	wg.Wait()
+}
+
 // CreateFromBillingRecord creates an invoice from a billing record
+// If the organization already has an invoice for the period, that invoice is returned instead
 func (g *InvoiceGenerator) CreateFromBillingRecord(ctx context.Context, record *BillingRecord) (*Invoice, error) {
+	invoice, _, err := g.createFromBillingRecord(ctx, record)
+	return invoice, err
+}
+
+// createFromBillingRecord creates an invoice, reporting false if an existing one was returned
+func (g *InvoiceGenerator) createFromBillingRecord(ctx context.Context, record *BillingRecord) (*Invoice, bool, error) {
+	// Calculate billing period (anchor to anchor; calendar month without an anchor day)
+	periodStart, periodEnd := record.BillingPeriod()
+
+	// Return the existing invoice for this org and period, if any
+	existing, err := g.findInvoiceForPeriod(ctx, record.OrganizationID, periodStart)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
 	// Get organization details
 	org, err := g.getOrganization(ctx, record.OrganizationID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get organization: %w", err)
+		return nil, false, fmt.Errorf("failed to get organization: %w", err)
 	}
 
-	// Generate invoice number
-	invoiceNumber, err := g.generateInvoiceNumber(ctx, record.BillingMonth)
+	// Look up the org's coupon; one valid at any point in the period applies
+	coupon, err := g.activeCoupon(ctx, record.OrganizationID, periodEnd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate invoice number: %w", err)
+		return nil, false, fmt.Errorf("failed to look up coupon: %w", err)
 	}
 
-	// Calculate billing period
-	periodStart := record.BillingMonth
-	periodEnd := periodStart.AddDate(0, 1, 0).Add(-time.Second)
+	invoice := g.buildInvoice(org, record, time.Now())
+	discount := invoice.DiscountCents
+	applyCoupon(invoice, coupon, discount)
+
+	return g.saveNewInvoice(ctx, invoice, coupon, discount)
+}
+
+// saveNewInvoice saves a new invoice with its coupon applied on top of baseDiscount,
+// reporting false if a concurrent run saved the org's invoice for the period first
+func (g *InvoiceGenerator) saveNewInvoice(ctx context.Context, invoice *Invoice, coupon *Coupon, baseDiscount int64) (*Invoice, bool, error) {
+	// Save to database (assigns the invoice number)
+	err := g.saveInvoice(ctx, invoice)
+	if errors.Is(err, errCouponExhausted) {
+		// Another invoice took the coupon's last redemption; bill without it
+		log.Printf("[InvoiceGenerator] WARNING: Coupon %s exhausted, invoicing %s without it", coupon.Code, invoice.OrganizationID)
+		invoice.CouponID, invoice.Notes = "", ""
+		applyCoupon(invoice, nil, baseDiscount)
+		err = g.saveInvoice(ctx, invoice)
+	}
+	if err != nil {
+		// A concurrent run inserted the invoice after our check
+		if errors.Is(err, errInvoiceExists) {
+			existing, err := g.findInvoiceForPeriod(ctx, invoice.OrganizationID, invoice.BillingPeriodStart)
+			if err != nil {
+				return nil, false, err
+			}
+			if existing != nil {
+				return existing, false, nil
+			}
+		}
+		return nil, false, fmt.Errorf("failed to save invoice: %w", err)
+	}
+
+	return invoice, true, nil
+}
 
-	// Create line items
-	lineItems := g.createLineItems(record, periodStart, periodEnd)
+// buildInvoice builds an org's draft invoice from its billing record, before any coupon
+func (g *InvoiceGenerator) buildInvoice(org *Organization, record *BillingRecord, invoiceDate time.Time) *Invoice {
+	periodStart, periodEnd := record.BillingPeriod()
 
 	// Calculate totals
 	subtotal := record.SubtotalCents
-	tax := int64(0)
-	if g.config.EnableTax && g.config.TaxRate > 0 {
-		tax = int64(float64(subtotal) * g.config.TaxRate)
-	}
+	tax := g.calculateTax(subtotal)
 	discount := record.DiscountCents
 	total := subtotal + tax - discount
 
-	// Create invoice
 	invoice := &Invoice{
 		OrganizationID:     record.OrganizationID,
 		OrganizationName:   org.Name,
 		BillingPeriodStart: periodStart,
 		BillingPeriodEnd:   periodEnd,
-		LineItems:          lineItems,
+		LineItems:          g.createLineItems(record, periodStart, periodEnd),
 		SubtotalCents:      subtotal,
 		TaxCents:           tax,
 		DiscountCents:      discount,
 		TotalCents:         total,
-		InvoiceNumber:      invoiceNumber,
-		InvoiceDate:        time.Now(),
-		DueDate:            time.Now().AddDate(0, 0, g.config.PaymentTerms),
-		PaymentTermsDays:   g.config.PaymentTerms,
+		InvoiceDate:        invoiceDate,
 		Status:             InvoiceStatusDraft,
 		CustomerEmail:      org.Email,
 		CustomerName:       org.Name,
 		BillingAddress:     org.BillingAddress,
-		CreatedAt:          time.Now(),
-		UpdatedAt:          time.Now(),
+		StripeAccountID:    org.StripeAccountID,
+		PONumber:           org.DefaultPONumber,
+		CustomFields:       copyCustomFields(org.InvoiceCustomFields),
+		CreatedAt:          invoiceDate,
+		UpdatedAt:          invoiceDate,
 	}
+	setPaymentTerms(invoice, paymentTermsDays(org, g.config.PaymentTerms))
+
+	return invoice
+}
+
+// findInvoiceForPeriod returns the org's non-voided invoice for a billing period, or nil if none exists
+func (g *InvoiceGenerator) findInvoiceForPeriod(ctx context.Context, orgID string, periodStart time.Time) (*Invoice, error) {
+	query := `
+		SELECT id
+		FROM invoices
+		WHERE organization_id = $1
+		  AND billing_period_start = $2
+		  AND status <> 'voided'
+		LIMIT 1
+	`
 
-	// Save to database
-	if err := g.saveInvoice(ctx, invoice); err != nil {
-		return nil, fmt.Errorf("failed to save invoice: %w", err)
+	var invoiceID string
+	err := g.db.QueryRowContext(ctx, query, orgID, periodStart).Scan(&invoiceID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing invoice: %w", err)
 	}
 
-	return invoice, nil
+	return g.GetInvoiceByID(ctx, invoiceID)
 }
 
-// createLineItems generates line items from billing record
+// createLineItems generates line items from billing record, grouped per the LineItemGrouping config
 func (g *InvoiceGenerator) createLineItems(record *BillingRecord, periodStart, periodEnd time.Time) []LineItem {
-	items := make([]LineItem, 0)
+	items := buildLineItems(record.Calculation(), periodStart, periodEnd, g.config.UnitLabel())
+	return groupLineItems(items, g.config.LineItemGrouping)
+}
 
-	// Base plan charge
-	if record.BaseChargeCents > 0 {
-		items = append(items, LineItem{
-			Description:    fmt.Sprintf("%s Plan - %s", record.PlanName, formatPeriod(periodStart, periodEnd)),
-			Quantity:       1,
-			UnitPriceCents: record.BaseChargeCents,
-			AmountCents:    record.BaseChargeCents,
-			ItemType:       "base_plan",
-			PeriodStart:    &periodStart,
-			PeriodEnd:      &periodEnd,
-		})
-	}
-
-	// Overage charge
-	if record.OverageChargeCents > 0 {
-		items = append(items, LineItem{
-			Description:    fmt.Sprintf("Usage overage - %s requests over limit", formatUsage(record.OverageUnits)),
-			Quantity:       record.OverageUnits,
-			UnitPriceCents: calculateUnitPrice(record.OverageChargeCents, record.OverageUnits),
-			AmountCents:    record.OverageChargeCents,
-			ItemType:       "overage",
-			PeriodStart:    &periodStart,
-			PeriodEnd:      &periodEnd,
-		})
-	}
-
-	return items
+// calculateTax returns the tax on a subtotal, or zero when tax is disabled
+func (g *InvoiceGenerator) calculateTax(subtotal int64) int64 {
+	if !g.config.EnableTax || g.config.TaxRate <= 0 {
+		return 0
+	}
+	return int64(float64(subtotal) * g.config.TaxRate)
 }
 
-// generateInvoiceNumber creates a unique invoice number
+// rowQuerier is satisfied by *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// generateInvoiceNumber allocates the next invoice number for a month
 func (g *InvoiceGenerator) generateInvoiceNumber(ctx context.Context, month time.Time) (string, error) {
+	return nextInvoiceNumber(ctx, g.db, month)
+}
+
+// nextInvoiceNumber atomically allocates the month's next sequence from invoice_number_counters
+// The counter row stays locked until q's transaction ends, and a rollback returns the number,
+// so numbers are unique and gap-free under concurrency
+func nextInvoiceNumber(ctx context.Context, q rowQuerier, month time.Time) (string, error) {
 	year := month.Year()
 	monthNum := int(month.Month())
 
-	// Get next sequence number for this month
-	var sequence int
 	query := `
-		SELECT COALESCE(MAX(
-			CAST(SUBSTRING(invoice_number FROM 'INV-[0-9]{4}-[0-9]{2}-([0-9]{5})') AS INTEGER)
-		), 0) + 1
-		FROM invoices
-		WHERE EXTRACT(YEAR FROM billing_period_start) = $1
-		  AND EXTRACT(MONTH FROM billing_period_start) = $2
+		INSERT INTO invoice_number_counters (year, month, next_seq)
+		VALUES ($1, $2, 2)
+		ON CONFLICT (year, month)
+		DO UPDATE SET next_seq = invoice_number_counters.next_seq + 1, updated_at = NOW()
+		RETURNING next_seq - 1
 	`
 
-	err := g.db.QueryRowContext(ctx, query, year, monthNum).Scan(&sequence)
-	if err != nil && err != sql.ErrNoRows {
-		return "", fmt.Errorf("failed to get sequence: %w", err)
-	}
-
-	if sequence == 0 {
-		sequence = 1
+	var sequence int
+	if err := q.QueryRowContext(ctx, query, year, monthNum).Scan(&sequence); err != nil {
+		return "", fmt.Errorf("failed to allocate sequence: %w", err)
 	}
 
 	return FormatInvoiceNumber(year, monthNum, sequence), nil
@@ -177,30 +296,70 @@ func (g *InvoiceGenerator) saveInvoice(ctx context.Context, invoice *Invoice) er
 	}
 	defer tx.Rollback()
 
+	if err := insertInvoice(ctx, tx, invoice); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertInvoice inserts an invoice and its line items in tx, allocating its number and redeeming its coupon
+// A replacement keeps the coupon redemption of the invoice it supersedes
+func insertInvoice(ctx context.Context, tx *sql.Tx, invoice *Invoice) error {
+	var err error
+
+	// Allocate the number in this transaction so a failed insert doesn't leave a gap
+	invoice.InvoiceNumber, err = nextInvoiceNumber(ctx, tx, invoice.BillingPeriodStart)
+	if err != nil {
+		return fmt.Errorf("failed to generate invoice number: %w", err)
+	}
+
+	customFields := []byte("{}")
+	if len(invoice.CustomFields) > 0 {
+		if customFields, err = json.Marshal(invoice.CustomFields); err != nil {
+			return fmt.Errorf("failed to encode custom fields: %w", err)
+		}
+	}
+
 	// Insert invoice
 	query := `
 		INSERT INTO invoices (
 			organization_id, billing_period_start, billing_period_end,
-			subtotal_cents, tax_cents, discount_cents, total_cents,
+			subtotal_cents, tax_cents, discount_cents, total_cents, coupon_id,
 			invoice_number, invoice_date, due_date, payment_terms_days,
 			status, customer_email, customer_name, billing_address,
-			created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+			created_at, updated_at, notes, po_number, custom_fields, supersedes_invoice_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, NULLIF($19, ''), NULLIF($20, ''), $21, NULLIF($22, '')::uuid)
+		ON CONFLICT (organization_id, billing_period_start) WHERE status <> 'voided' DO NOTHING
 		RETURNING id
 	`
 
 	err = tx.QueryRowContext(ctx, query,
 		invoice.OrganizationID, invoice.BillingPeriodStart, invoice.BillingPeriodEnd,
-		invoice.SubtotalCents, invoice.TaxCents, invoice.DiscountCents, invoice.TotalCents,
+		invoice.SubtotalCents, invoice.TaxCents, invoice.DiscountCents, invoice.TotalCents, invoice.CouponID,
 		invoice.InvoiceNumber, invoice.InvoiceDate, invoice.DueDate, invoice.PaymentTermsDays,
 		invoice.Status, invoice.CustomerEmail, invoice.CustomerName, invoice.BillingAddress,
-		invoice.CreatedAt, invoice.UpdatedAt,
+		invoice.CreatedAt, invoice.UpdatedAt, invoice.Notes, invoice.PONumber, customFields, invoice.SupersedesInvoiceID,
 	).Scan(&invoice.ID)
 
+	if err == sql.ErrNoRows {
+		return errInvoiceExists
+	}
+
 	if err != nil {
 		return fmt.Errorf("failed to insert invoice: %w", err)
 	}
 
+	if invoice.CouponID != "" && invoice.SupersedesInvoiceID == "" {
+		if err := redeemCoupon(ctx, tx, invoice.CouponID); err != nil {
+			return err
+		}
+	}
+
 	// Insert line items
 	for i := range invoice.LineItems {
 		item := &invoice.LineItems[i]
@@ -209,14 +368,16 @@ func (g *InvoiceGenerator) saveInvoice(ctx context.Context, invoice *Invoice) er
 		itemQuery := `
 			INSERT INTO invoice_line_items (
 				invoice_id, description, quantity, unit_price_cents,
-				amount_cents, item_type, period_start, period_end
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				amount_cents, item_type, period_start, period_end,
+				child_organization_id, child_organization_name
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::uuid, NULLIF($10, ''))
 			RETURNING id
 		`
 
 		err = tx.QueryRowContext(ctx, itemQuery,
 			item.InvoiceID, item.Description, item.Quantity, item.UnitPriceCents,
 			item.AmountCents, item.ItemType, item.PeriodStart, item.PeriodEnd,
+			item.ChildOrganizationID, item.ChildOrganizationName,
 		).Scan(&item.ID)
 
 		if err != nil {
@@ -224,14 +385,11 @@ func (g *InvoiceGenerator) saveInvoice(ctx context.Context, invoice *Invoice) er
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
-// getBillingRecordsForMonth retrieves all billing records for a month
+// getBillingRecordsForMonth retrieves the billing records whose periods close during a month
+// An anchored period that started last month (e.g. Jan 15 - Feb 14) closes in this one
 func (g *InvoiceGenerator) getBillingRecordsForMonth(ctx context.Context, month time.Time) ([]*BillingRecord, error) {
 	query := `
 		SELECT
@@ -244,17 +402,22 @@ func (g *InvoiceGenerator) getBillingRecordsForMonth(ctx context.Context, month
 			br.overage_units,
 			br.base_charge_cents,
 			br.overage_charge_cents,
+			COALESCE(br.true_up_charge_cents, 0) AS true_up_charge_cents,
 			br.subtotal_cents,
 			br.discount_cents,
-			br.total_charge_cents
+			br.total_charge_cents,
+			COALESCE(br.metric_charges, '[]') AS metric_charges,
+			br.sla_credit,
+			COALESCE(o.billing_anchor_day, 1) AS billing_anchor_day
 		FROM billing_records br
 		JOIN pricing_plans pp ON br.plan_id = pp.id
-		WHERE br.billing_month = $1
+		LEFT JOIN organizations o ON br.organization_id = o.id::text
+		WHERE br.billing_month IN ($1, $2)
 		  AND br.payment_status != 'voided'
 		ORDER BY br.organization_id
 	`
 
-	rows, err := g.db.QueryContext(ctx, query, month)
+	rows, err := g.db.QueryContext(ctx, query, month, month.AddDate(0, -1, 0))
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -263,6 +426,7 @@ func (g *InvoiceGenerator) getBillingRecordsForMonth(ctx context.Context, month
 	records := make([]*BillingRecord, 0)
 	for rows.Next() {
 		record := &BillingRecord{}
+		var metricCharges, slaCredit []byte
 		err := rows.Scan(
 			&record.OrganizationID,
 			&record.BillingMonth,
@@ -273,14 +437,28 @@ func (g *InvoiceGenerator) getBillingRecordsForMonth(ctx context.Context, month
 			&record.OverageUnits,
 			&record.BaseChargeCents,
 			&record.OverageChargeCents,
+			&record.TrueUpChargeCents,
 			&record.SubtotalCents,
 			&record.DiscountCents,
 			&record.TotalChargeCents,
+			&metricCharges,
+			&slaCredit,
+			&record.BillingAnchorDay,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan failed: %w", err)
 		}
-		records = append(records, record)
+		if err := json.Unmarshal(metricCharges, &record.MetricCharges); err != nil {
+			return nil, fmt.Errorf("invalid metric charges for %s: %w", record.OrganizationID, err)
+		}
+		if len(slaCredit) > 0 {
+			if err := json.Unmarshal(slaCredit, &record.SLACredit); err != nil {
+				return nil, fmt.Errorf("invalid SLA credit for %s: %w", record.OrganizationID, err)
+			}
+		}
+		if record.closesIn(month) {
+			records = append(records, record)
+		}
 	}
 
 	if err := rows.Err(); err != nil {
@@ -293,23 +471,41 @@ func (g *InvoiceGenerator) getBillingRecordsForMonth(ctx context.Context, month
 // getOrganization retrieves organization details
 func (g *InvoiceGenerator) getOrganization(ctx context.Context, orgID string) (*Organization, error) {
 	query := `
-		SELECT id, name, email, billing_address
+		SELECT id, name, email, billing_address, COALESCE(stripe_account_id, ''), payment_terms_days,
+			COALESCE(default_po_number, ''), COALESCE(invoice_custom_fields, '{}'),
+			COALESCE(parent_organization_id::text, '')
 		FROM organizations
 		WHERE id = $1
 	`
 
 	org := &Organization{}
+	var paymentTerms sql.NullInt64
+	var customFields []byte
 	err := g.db.QueryRowContext(ctx, query, orgID).Scan(
 		&org.ID,
 		&org.Name,
 		&org.Email,
 		&org.BillingAddress,
+		&org.StripeAccountID,
+		&paymentTerms,
+		&org.DefaultPONumber,
+		&customFields,
+		&org.ParentOrganizationID,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get organization: %w", err)
 	}
 
+	if err := json.Unmarshal(customFields, &org.InvoiceCustomFields); err != nil {
+		return nil, fmt.Errorf("invalid invoice custom fields for %s: %w", orgID, err)
+	}
+
+	if paymentTerms.Valid {
+		days := int(paymentTerms.Int64)
+		org.PaymentTermsDays = &days
+	}
+
 	return org, nil
 }
 
@@ -318,32 +514,44 @@ func (g *InvoiceGenerator) GetInvoiceByID(ctx context.Context, invoiceID string)
 	query := `
 		SELECT
 			id, organization_id, billing_period_start, billing_period_end,
-			subtotal_cents, tax_cents, discount_cents, total_cents,
+			subtotal_cents, tax_cents, discount_cents, total_cents, refunded_amount_cents,
 			invoice_number, invoice_date, due_date, payment_terms_days,
 			pdf_url, stripe_invoice_id, stripe_invoice_url, status,
 			customer_email, customer_name, billing_address,
-			created_at, updated_at, sent_at, paid_at, notes
+			created_at, updated_at, sent_at, paid_at, notes,
+			COALESCE(coupon_id::text, ''), COALESCE(pdf_sha256, ''),
+			COALESCE(po_number, ''), COALESCE(custom_fields, '{}'),
+			COALESCE(supersedes_invoice_id::text, ''),
+			COALESCE((SELECT s.invoice_number FROM invoices s WHERE s.id = invoices.supersedes_invoice_id), '')
 		FROM invoices
 		WHERE id = $1
 	`
 
 	invoice := &Invoice{}
+	var customFields []byte
 	var sentAt, paidAt sql.NullTime
 	var pdfUrl, stripeInvoiceID, stripeInvoiceURL, notes sql.NullString
 
 	err := g.db.QueryRowContext(ctx, query, invoiceID).Scan(
 		&invoice.ID, &invoice.OrganizationID, &invoice.BillingPeriodStart, &invoice.BillingPeriodEnd,
-		&invoice.SubtotalCents, &invoice.TaxCents, &invoice.DiscountCents, &invoice.TotalCents,
+		&invoice.SubtotalCents, &invoice.TaxCents, &invoice.DiscountCents, &invoice.TotalCents, &invoice.RefundedAmountCents,
 		&invoice.InvoiceNumber, &invoice.InvoiceDate, &invoice.DueDate, &invoice.PaymentTermsDays,
 		&pdfUrl, &stripeInvoiceID, &stripeInvoiceURL, &invoice.Status,
 		&invoice.CustomerEmail, &invoice.CustomerName, &invoice.BillingAddress,
 		&invoice.CreatedAt, &invoice.UpdatedAt, &sentAt, &paidAt, &notes,
+		&invoice.CouponID, &invoice.PDFSHA256,
+		&invoice.PONumber, &customFields,
+		&invoice.SupersedesInvoiceID, &invoice.SupersedesInvoiceNumber,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get invoice: %w", err)
 	}
 
+	if err := json.Unmarshal(customFields, &invoice.CustomFields); err != nil {
+		return nil, fmt.Errorf("invalid custom fields on invoice %s: %w", invoiceID, err)
+	}
+
 	if sentAt.Valid {
 		invoice.SentAt = &sentAt.Time
 	}
@@ -377,10 +585,11 @@ func (g *InvoiceGenerator) GetInvoiceByID(ctx context.Context, invoiceID string)
 func (g *InvoiceGenerator) getLineItems(ctx context.Context, invoiceID string) ([]LineItem, error) {
 	query := `
 		SELECT id, invoice_id, description, quantity, unit_price_cents,
-		       amount_cents, item_type, period_start, period_end
+		       amount_cents, item_type, period_start, period_end,
+		       COALESCE(child_organization_id::text, ''), COALESCE(child_organization_name, '')
 		FROM invoice_line_items
 		WHERE invoice_id = $1
-		ORDER BY item_type, id
+		ORDER BY COALESCE(child_organization_name, ''), child_organization_id, item_type, id
 	`
 
 	rows, err := g.db.QueryContext(ctx, query, invoiceID)
@@ -397,6 +606,7 @@ func (g *InvoiceGenerator) getLineItems(ctx context.Context, invoiceID string) (
 		err := rows.Scan(
 			&item.ID, &item.InvoiceID, &item.Description, &item.Quantity, &item.UnitPriceCents,
 			&item.AmountCents, &item.ItemType, &periodStart, &periodEnd,
+			&item.ChildOrganizationID, &item.ChildOrganizationName,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan failed: %w", err)
@@ -415,26 +625,36 @@ func (g *InvoiceGenerator) getLineItems(ctx context.Context, invoiceID string) (
 	return items, rows.Err()
 }
 
-// UpdateInvoiceStatus updates the status of an invoice
-func (g *InvoiceGenerator) UpdateInvoiceStatus(ctx context.Context, invoiceID, status string) error {
+// SavePDF records an uploaded invoice PDF's URL and checksum
+func (g *InvoiceGenerator) SavePDF(ctx context.Context, invoiceID, pdfURL, checksum string) error {
 	query := `
 		UPDATE invoices
-		SET status = $1, updated_at = $2
+		SET pdf_url = $1, pdf_sha256 = NULLIF($2, ''), updated_at = NOW()
 		WHERE id = $3
 	`
 
-	_, err := g.db.ExecContext(ctx, query, status, time.Now(), invoiceID)
+	result, err := g.db.ExecContext(ctx, query, pdfURL, checksum, invoiceID)
 	if err != nil {
-		return fmt.Errorf("failed to update invoice status: %w", err)
+		return fmt.Errorf("failed to save invoice PDF: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("invoice not found: %s", invoiceID)
 	}
-
 	return nil
 }
 
+// UpdateInvoiceStatus updates the status of an invoice, rejecting invalid transitions
+// Use TransitionInvoiceStatus for the previous status
+func (g *InvoiceGenerator) UpdateInvoiceStatus(ctx context.Context, invoiceID, status string) error {
+	_, err := g.TransitionInvoiceStatus(ctx, invoiceID, status)
+	return err
+}
+
 // Helper types for database queries
 type BillingRecord struct {
 	OrganizationID     string
-	BillingMonth       time.Time
+	BillingMonth       time.Time // First day of the month the billing period starts in
+	BillingAnchorDay   int       // Day of the month periods start on; 0 or 1 bills calendar months
 	PlanID             string
 	PlanName           string
 	UsageUnits         int64
@@ -442,26 +662,93 @@ type BillingRecord struct {
 	OverageUnits       int64
 	BaseChargeCents    int64
 	OverageChargeCents int64
+	TrueUpChargeCents  int64                  // Shortfall below the minimum commitment, included in SubtotalCents
+	MetricCharges      []pricing.MetricCharge // Bandwidth and other metrics, included in SubtotalCents
+	SLACredit          *pricing.SLACredit     // Breached latency SLA, deducted from SubtotalCents
 	SubtotalCents      int64
 	DiscountCents      int64
 	TotalChargeCents   int64
 }
 
+// BillingPeriod returns the record's billing period from anchor day to anchor day
+// The end is inclusive: one second before the next period starts
+func (r *BillingRecord) BillingPeriod() (start, end time.Time) {
+	start, next := pricing.BillingPeriod(r.BillingAnchorDay, r.BillingMonth)
+	return start, next.Add(-time.Second)
+}
+
+// closesIn reports whether the record's billing period ends during month, so that the
+// run on the 1st of the following month is the first to see it complete
+func (r *BillingRecord) closesIn(month time.Time) bool {
+	monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	_, next := pricing.BillingPeriod(r.BillingAnchorDay, r.BillingMonth)
+	return next.After(monthStart) && !next.After(monthStart.AddDate(0, 1, 0))
+}
+
 type Organization struct {
-	ID             string
-	Name           string
-	Email          string
-	BillingAddress string
+	ID               string
+	Name             string
+	Email            string
+	BillingAddress   string
+	StripeAccountID  string // Connected Stripe account (acct_...), empty for the platform account
+	PaymentTermsDays *int   // Contracted terms (e.g., 45 for Net 45), nil for the config default
+
+	// Reseller billed for this org on a consolidated invoice, empty when invoiced directly
+	ParentOrganizationID string
+
+	// Copied onto each new invoice, where they can be overridden
+	DefaultPONumber     string
+	InvoiceCustomFields map[string]string
 }
 
-// Helper functions
-func formatPeriod(start, end time.Time) string {
-	return start.Format("Jan 2") + " - " + end.Format("Jan 2, 2006")
+// MaxPaymentTermsDays is the longest per-organization payment term accepted (Net 120)
+const MaxPaymentTermsDays = 120
+
+// ValidatePaymentTerms checks that payment terms are between 0 and MaxPaymentTermsDays
+func ValidatePaymentTerms(days int) error {
+	if days < 0 || days > MaxPaymentTermsDays {
+		return fmt.Errorf("payment terms must be between 0 and %d days, got %d", MaxPaymentTermsDays, days)
+	}
+	return nil
 }
 
-func calculateUnitPrice(total, quantity int64) int64 {
-	if quantity == 0 {
-		return 0
+// paymentTermsDays returns the org's payment terms, falling back to defaultDays
+// when the org has none or they are out of range
+func paymentTermsDays(org *Organization, defaultDays int) int {
+	if org == nil || org.PaymentTermsDays == nil {
+		return defaultDays
+	}
+	if err := ValidatePaymentTerms(*org.PaymentTermsDays); err != nil {
+		log.Printf("[InvoiceGenerator] WARNING: Organization %s: %v, using Net %d", org.ID, err, defaultDays)
+		return defaultDays
+	}
+	return *org.PaymentTermsDays
+}
+
+// setPaymentTerms sets the invoice's payment terms and the due date that follows from its invoice date
+func setPaymentTerms(invoice *Invoice, days int) {
+	invoice.PaymentTermsDays = days
+	invoice.DueDate = invoice.InvoiceDate.AddDate(0, 0, days)
+}
+
+// copyCustomFields copies an org's custom fields so per-invoice overrides don't leak back to it
+func copyCustomFields(fields map[string]string) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(fields))
+	for k, v := range fields {
+		copied[k] = v
+	}
+	return copied
+}
+
+// customFieldKeys returns the keys of an invoice's custom fields in the order they are rendered
+func customFieldKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
 	}
-	return total / quantity
+	sort.Strings(keys)
+	return keys
 }
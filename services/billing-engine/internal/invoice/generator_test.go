@@ -3,9 +3,12 @@ package invoice
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
 	_ "github.com/lib/pq"
 )
 
@@ -218,6 +221,98 @@ func TestInvoiceGenerator_generateInvoiceNumber(t *testing.T) {
 	}
 }
 
+// TestNextInvoiceNumber_Concurrent tests that parallel allocations are unique and gap-free
+func TestNextInvoiceNumber_Concurrent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	month := time.Date(2099, 7, 1, 0, 0, 0, 0, time.UTC) // Far future, so no real invoices share the counter
+
+	resetCounter := func() {
+		if _, err := db.ExecContext(ctx, "DELETE FROM invoice_number_counters WHERE year = $1 AND month = $2", 2099, 7); err != nil {
+			t.Fatalf("Failed to reset counter: %v", err)
+		}
+	}
+	resetCounter()
+	defer resetCounter()
+
+	const workers = 20
+	const perWorker = 25
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[string]bool)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < perWorker; i++ {
+				number, err := nextInvoiceNumber(ctx, db, month)
+				if err != nil {
+					t.Errorf("nextInvoiceNumber() error = %v", err)
+					return
+				}
+
+				mu.Lock()
+				if seen[number] {
+					t.Errorf("Duplicate invoice number %s", number)
+				}
+				seen[number] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Gap-free: exactly sequences 1..N were handed out
+	total := workers * perWorker
+	if len(seen) != total {
+		t.Fatalf("Allocated %d distinct numbers, want %d", len(seen), total)
+	}
+	for seq := 1; seq <= total; seq++ {
+		if number := FormatInvoiceNumber(2099, 7, seq); !seen[number] {
+			t.Errorf("Missing invoice number %s", number)
+		}
+	}
+}
+
+// TestNextInvoiceNumber_RollbackLeavesNoGap tests that an aborted invoice returns its number
+func TestNextInvoiceNumber_RollbackLeavesNoGap(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	month := time.Date(2099, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	resetCounter := func() {
+		if _, err := db.ExecContext(ctx, "DELETE FROM invoice_number_counters WHERE year = $1 AND month = $2", 2099, 8); err != nil {
+			t.Fatalf("Failed to reset counter: %v", err)
+		}
+	}
+	resetCounter()
+	defer resetCounter()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if _, err := nextInvoiceNumber(ctx, tx, month); err != nil {
+		t.Fatalf("nextInvoiceNumber() error = %v", err)
+	}
+	tx.Rollback()
+
+	number, err := nextInvoiceNumber(ctx, db, month)
+	if err != nil {
+		t.Fatalf("nextInvoiceNumber() error = %v", err)
+	}
+	if want := FormatInvoiceNumber(2099, 8, 1); number != want {
+		t.Errorf("Number after rollback = %s, want %s", number, want)
+	}
+}
+
 // TestInvoiceGenerator_CreateFromBillingRecord tests invoice creation
 func TestInvoiceGenerator_CreateFromBillingRecord(t *testing.T) {
 	db := setupTestDB(t)
@@ -320,7 +415,267 @@ func TestInvoiceGenerator_CreateFromBillingRecord(t *testing.T) {
 	}
 }
 
+// TestInvoiceGenerator_GenerateMonthly_Idempotent tests that re-running a month creates no duplicates
+func TestInvoiceGenerator_GenerateMonthly_Idempotent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gen := NewInvoiceGenerator(db, nil, nil, createTestConfig())
+	ctx := context.Background()
+	billingMonth := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	first, err := gen.GenerateMonthly(ctx, billingMonth)
+	if err != nil {
+		t.Fatalf("First GenerateMonthly() error = %v", err)
+	}
+
+	second, err := gen.GenerateMonthly(ctx, billingMonth)
+	if err != nil {
+		t.Fatalf("Second GenerateMonthly() error = %v", err)
+	}
+
+	// Every record invoiced by the first run is skipped by the second
+	if second.SuccessCount != 0 {
+		t.Errorf("Second run created %d invoices, want 0", second.SuccessCount)
+	}
+	if second.SkippedCount != first.SuccessCount+first.SkippedCount {
+		t.Errorf("Second run skipped %d, want %d", second.SkippedCount, first.SuccessCount+first.SkippedCount)
+	}
+
+	// Exactly one invoice per organization for the period
+	var duplicates int
+	query := `
+		SELECT COUNT(*) FROM (
+			SELECT organization_id
+			FROM invoices
+			WHERE billing_period_start = $1 AND status <> 'voided'
+			GROUP BY organization_id
+			HAVING COUNT(*) > 1
+		) d
+	`
+	if err := db.QueryRowContext(ctx, query, billingMonth).Scan(&duplicates); err != nil {
+		t.Fatalf("Failed to count duplicates: %v", err)
+	}
+	if duplicates != 0 {
+		t.Errorf("Found %d organizations with duplicate invoices", duplicates)
+	}
+}
+
+// TestGenerateConcurrently tests that the worker pool tallies every record exactly once
+func TestGenerateConcurrently(t *testing.T) {
+	const recordCount = 500
+	month := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	records := make([]*BillingRecord, recordCount)
+	for i := range records {
+		records[i] = &BillingRecord{OrganizationID: fmt.Sprintf("org-%03d", i), BillingMonth: month}
+	}
+
+	// Fake creator: a locked sequence stands in for invoice_number_counters
+	var mu sync.Mutex
+	sequence := 0
+	numbers := make(map[string]string)
+
+	create := func(ctx context.Context, record *BillingRecord) (*Invoice, bool, error) {
+		var n int
+		fmt.Sscanf(record.OrganizationID, "org-%d", &n)
+
+		switch {
+		case n%10 == 0:
+			return nil, false, fmt.Errorf("organization not found")
+		case n%10 == 1:
+			return &Invoice{OrganizationID: record.OrganizationID}, false, nil
+		}
+
+		mu.Lock()
+		sequence++
+		number := FormatInvoiceNumber(month.Year(), int(month.Month()), sequence)
+		if other, ok := numbers[number]; ok {
+			t.Errorf("Invoice number %s assigned to %s and %s", number, other, record.OrganizationID)
+		}
+		numbers[number] = record.OrganizationID
+		mu.Unlock()
+
+		return &Invoice{OrganizationID: record.OrganizationID, InvoiceNumber: number, TotalCents: 100}, true, nil
+	}
+
+	summary := &InvoiceSummary{}
+	generateConcurrently(context.Background(), records, 8, create, summary)
+
+	if summary.FailureCount != 50 || len(summary.Errors) != 50 {
+		t.Errorf("Failures = %d (%d errors), want 50", summary.FailureCount, len(summary.Errors))
+	}
+	if summary.SkippedCount != 50 {
+		t.Errorf("Skipped = %d, want 50", summary.SkippedCount)
+	}
+	if summary.SuccessCount != 400 || len(numbers) != 400 {
+		t.Errorf("Success = %d (%d numbers), want 400", summary.SuccessCount, len(numbers))
+	}
+	if summary.TotalRevenue != 40000 {
+		t.Errorf("TotalRevenue = %d, want 40000", summary.TotalRevenue)
+	}
+}
+
+// TestInvoiceGenerator_GenerateMonthly_ConcurrentNumbers tests invoice numbers stay unique across workers
+func TestInvoiceGenerator_GenerateMonthly_ConcurrentNumbers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	config := createTestConfig()
+	config.GenerationWorkers = 8
+	gen := NewInvoiceGenerator(db, nil, nil, config)
+
+	ctx := context.Background()
+	billingMonth := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	summary, err := gen.GenerateMonthly(ctx, billingMonth)
+	if err != nil {
+		t.Fatalf("GenerateMonthly() error = %v", err)
+	}
+
+	// A duplicate number would violate the unique constraint and count as a failure
+	if summary.FailureCount != 0 {
+		t.Fatalf("GenerateMonthly() had %d failures: %+v", summary.FailureCount, summary.Errors)
+	}
+
+	var total, distinct int
+	query := `
+		SELECT COUNT(*), COUNT(DISTINCT invoice_number)
+		FROM invoices
+		WHERE billing_period_start = $1
+	`
+	if err := db.QueryRowContext(ctx, query, billingMonth).Scan(&total, &distinct); err != nil {
+		t.Fatalf("Failed to count invoice numbers: %v", err)
+	}
+	if total != distinct {
+		t.Errorf("Found %d invoices but only %d distinct numbers", total, distinct)
+	}
+}
+
+// TestInvoiceGenerator_CreateFromBillingRecord_ReturnsExisting tests that a second call returns the first invoice
+func TestInvoiceGenerator_CreateFromBillingRecord_ReturnsExisting(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gen := NewInvoiceGenerator(db, nil, nil, createTestConfig())
+	ctx := context.Background()
+
+	record := &BillingRecord{
+		OrganizationID:   "org-test-001",
+		BillingMonth:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		PlanID:           "starter",
+		PlanName:         "Starter",
+		BaseChargeCents:  2900,
+		SubtotalCents:    2900,
+		TotalChargeCents: 2900,
+	}
+
+	first, err := gen.CreateFromBillingRecord(ctx, record)
+	if err != nil {
+		t.Fatalf("First CreateFromBillingRecord() error = %v", err)
+	}
+
+	second, err := gen.CreateFromBillingRecord(ctx, record)
+	if err != nil {
+		t.Fatalf("Second CreateFromBillingRecord() error = %v", err)
+	}
+
+	if second.ID != first.ID || second.InvoiceNumber != first.InvoiceNumber {
+		t.Errorf("Second invoice = %s (%s), want existing %s (%s)",
+			second.ID, second.InvoiceNumber, first.ID, first.InvoiceNumber)
+	}
+}
+
 // TestInvoiceGenerator_createLineItems tests line item creation
+// TestBillingRecord_BillingPeriod tests invoice periods for anchored billing cycles
+func TestBillingRecord_BillingPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		anchorDay  int
+		month      time.Time
+		wantStart  time.Time
+		wantEnd    time.Time
+		wantPeriod string
+	}{
+		{
+			name:       "Calendar month",
+			anchorDay:  0,
+			month:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantStart:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:    time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+			wantPeriod: "Jan 1 - Jan 31, 2026",
+		},
+		{
+			name:       "Mid-month anchor",
+			anchorDay:  15,
+			month:      time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantStart:  time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC),
+			wantEnd:    time.Date(2026, 4, 14, 23, 59, 59, 0, time.UTC),
+			wantPeriod: "Mar 15 - Apr 14, 2026",
+		},
+		{
+			name:       "Anchor 31 ending in February",
+			anchorDay:  31,
+			month:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantStart:  time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			wantEnd:    time.Date(2026, 2, 27, 23, 59, 59, 0, time.UTC),
+			wantPeriod: "Jan 31 - Feb 27, 2026",
+		},
+		{
+			name:       "Anchor 31 starting in February",
+			anchorDay:  31,
+			month:      time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			wantStart:  time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC),
+			wantEnd:    time.Date(2026, 3, 30, 23, 59, 59, 0, time.UTC),
+			wantPeriod: "Feb 28 - Mar 30, 2026",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &BillingRecord{BillingMonth: tt.month, BillingAnchorDay: tt.anchorDay}
+			start, end := record.BillingPeriod()
+
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("BillingPeriod() = %s - %s, want %s - %s", start, end, tt.wantStart, tt.wantEnd)
+			}
+			if got := pricing.FormatPeriod(start, end); got != tt.wantPeriod {
+				t.Errorf("FormatPeriod() = %q, want %q", got, tt.wantPeriod)
+			}
+		})
+	}
+}
+
+// TestBillingRecord_closesIn tests which monthly run invoices an anchored period
+func TestBillingRecord_closesIn(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		anchorDay int
+		month     time.Time // Month the period starts in
+		closesIn  time.Time // Only month whose run invoices it
+	}{
+		{"Calendar month closes in itself", 1, jan, jan},
+		{"Mid-month period closes the next month", 15, jan, feb},
+		{"Anchor 31 closes on the last day of February", 31, jan, feb},
+		{"Anchor 31 starting in February closes in March", 31, feb, mar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &BillingRecord{BillingMonth: tt.month, BillingAnchorDay: tt.anchorDay}
+			for _, month := range []time.Time{jan, feb, mar} {
+				if got := record.closesIn(month); got != month.Equal(tt.closesIn) {
+					t.Errorf("closesIn(%s) = %v, want %v", month.Format("2006-01"), got, !got)
+				}
+			}
+		})
+	}
+}
+
 func TestInvoiceGenerator_createLineItems(t *testing.T) {
 	config := createTestConfig()
 	gen := NewInvoiceGenerator(nil, nil, nil, config)
@@ -353,6 +708,17 @@ func TestInvoiceGenerator_createLineItems(t *testing.T) {
 			},
 			expectedItemCount: 2,
 		},
+		{
+			name: "Below minimum commitment",
+			record: &BillingRecord{
+				PlanName:           "Starter",
+				BaseChargeCents:    2900,
+				OverageChargeCents: 500,
+				OverageUnits:       100000,
+				TrueUpChargeCents:  6600,
+			},
+			expectedItemCount: 3,
+		},
 		{
 			name: "Free plan (no base charge)",
 			record: &BillingRecord{
@@ -379,7 +745,7 @@ func TestInvoiceGenerator_createLineItems(t *testing.T) {
 				totalAmount += item.AmountCents
 			}
 
-			expectedTotal := tt.record.BaseChargeCents + tt.record.OverageChargeCents
+			expectedTotal := tt.record.BaseChargeCents + tt.record.OverageChargeCents + tt.record.TrueUpChargeCents
 			if totalAmount != expectedTotal {
 				t.Errorf("Expected total amount %d, got %d", expectedTotal, totalAmount)
 			}
@@ -387,6 +753,45 @@ func TestInvoiceGenerator_createLineItems(t *testing.T) {
 	}
 }
 
+// TestInvoiceGenerator_createLineItems_UnitLabel tests the overage description uses the configured unit
+func TestInvoiceGenerator_createLineItems_UnitLabel(t *testing.T) {
+	periodStart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	record := &BillingRecord{
+		PlanName:           "Growth",
+		BaseChargeCents:    9900,
+		OverageChargeCents: 200,
+		OverageUnits:       500000,
+	}
+
+	tests := []struct {
+		name         string
+		unitLabel    string
+		expectedDesc string
+	}{
+		{"Default label", "", "Usage overage - 500.0K requests over limit"},
+		{"Custom label", "messages", "Usage overage - 500.0K messages over limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestConfig()
+			config.UsageUnitLabel = tt.unitLabel
+			gen := NewInvoiceGenerator(nil, nil, nil, config)
+
+			items := gen.createLineItems(record, periodStart, periodEnd)
+			if len(items) != 2 {
+				t.Fatalf("Expected 2 line items, got %d", len(items))
+			}
+
+			if items[1].Description != tt.expectedDesc {
+				t.Errorf("Overage description = %q, want %q", items[1].Description, tt.expectedDesc)
+			}
+		})
+	}
+}
+
 // TestInvoiceGenerator_GetInvoiceByID tests retrieving an invoice
 func TestInvoiceGenerator_GetInvoiceByID(t *testing.T) {
 	db := setupTestDB(t)
@@ -466,3 +871,117 @@ func BenchmarkCreateLineItems(b *testing.B) {
 		gen.createLineItems(record, periodStart, periodEnd)
 	}
 }
+
+// TestInvoiceGenerator_createLineItems_TrueUp tests the minimum commitment shortfall gets its own line item
+func TestInvoiceGenerator_createLineItems_TrueUp(t *testing.T) {
+	gen := NewInvoiceGenerator(nil, nil, nil, createTestConfig())
+
+	periodStart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	items := gen.createLineItems(&BillingRecord{
+		PlanName:          "Starter",
+		BaseChargeCents:   2900,
+		TrueUpChargeCents: 7100,
+	}, periodStart, periodEnd)
+
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 line items, got %d", len(items))
+	}
+	trueUp := items[1]
+	if trueUp.Description != "Minimum commitment true-up" || trueUp.ItemType != "true_up" || trueUp.AmountCents != 7100 {
+		t.Errorf("True-up item = %q/%s/%d, want \"Minimum commitment true-up\"/true_up/7100",
+			trueUp.Description, trueUp.ItemType, trueUp.AmountCents)
+	}
+
+	// No shortfall, no line item
+	items = gen.createLineItems(&BillingRecord{PlanName: "Starter", BaseChargeCents: 2900, OverageChargeCents: 9000}, periodStart, periodEnd)
+	for _, item := range items {
+		if item.ItemType == "true_up" {
+			t.Errorf("Unexpected true-up item %+v", item)
+		}
+	}
+}
+
+// TestInvoiceGenerator_createLineItems_Metrics tests each billed metric gets its own line item
+func TestInvoiceGenerator_createLineItems_Metrics(t *testing.T) {
+	gen := NewInvoiceGenerator(nil, nil, nil, createTestConfig())
+
+	periodStart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	items := gen.createLineItems(&BillingRecord{
+		PlanName:        "Starter",
+		BaseChargeCents: 2900,
+		MetricCharges: []pricing.MetricCharge{
+			{Metric: pricing.MetricBytesOut, OverageUnits: 12500000000, IncludedUnits: 10000000000, UnitSize: 1000000000, UnitName: "GB", Rate: 9, Charge: 112},
+			{Metric: pricing.MetricBytesIn, UsedUnits: 1000000000, IncludedUnits: 50000000000, UnitSize: 1000000000, UnitName: "GB", Rate: 2},
+		},
+	}, periodStart, periodEnd)
+
+	// Metrics within their allowance are not listed
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 line items, got %d", len(items))
+	}
+	bandwidth := items[1]
+	want := "Data transfer out - 12.50 GB over 10.00 GB included at $0.09/GB"
+	if bandwidth.Description != want || bandwidth.ItemType != "overage" || bandwidth.AmountCents != 112 {
+		t.Errorf("Bandwidth item = %q/%s/%d, want %q/overage/112",
+			bandwidth.Description, bandwidth.ItemType, bandwidth.AmountCents, want)
+	}
+}
+
+func TestPaymentTermsDays(t *testing.T) {
+	days := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		org      *Organization
+		expected int
+	}{
+		{"No organization terms", &Organization{ID: "org-1"}, 30},
+		{"Net 45", &Organization{ID: "org-1", PaymentTermsDays: days(45)}, 45},
+		{"Due on receipt", &Organization{ID: "org-1", PaymentTermsDays: days(0)}, 0},
+		{"Maximum", &Organization{ID: "org-1", PaymentTermsDays: days(MaxPaymentTermsDays)}, MaxPaymentTermsDays},
+		{"Too long falls back", &Organization{ID: "org-1", PaymentTermsDays: days(365)}, 30},
+		{"Negative falls back", &Organization{ID: "org-1", PaymentTermsDays: days(-1)}, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paymentTermsDays(tt.org, 30); got != tt.expected {
+				t.Errorf("paymentTermsDays() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSetPaymentTerms_Net45 tests that an org on Net 45 gets a due date 45 days out
+func TestSetPaymentTerms_Net45(t *testing.T) {
+	net45 := 45
+	org := &Organization{ID: "org-1", PaymentTermsDays: &net45}
+	invoice := &Invoice{InvoiceDate: time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)}
+
+	setPaymentTerms(invoice, paymentTermsDays(org, 30))
+
+	if invoice.PaymentTermsDays != 45 {
+		t.Errorf("PaymentTermsDays = %d, want 45", invoice.PaymentTermsDays)
+	}
+	expectedDueDate := time.Date(2026, 3, 18, 9, 0, 0, 0, time.UTC)
+	if !invoice.DueDate.Equal(expectedDueDate) {
+		t.Errorf("DueDate = %v, want %v", invoice.DueDate, expectedDueDate)
+	}
+}
+
+func TestValidatePaymentTerms(t *testing.T) {
+	for _, days := range []int{0, 30, 60, 120} {
+		if err := ValidatePaymentTerms(days); err != nil {
+			t.Errorf("ValidatePaymentTerms(%d) error = %v", days, err)
+		}
+	}
+	for _, days := range []int{-1, 121, 365} {
+		if err := ValidatePaymentTerms(days); err == nil {
+			t.Errorf("ValidatePaymentTerms(%d) should fail", days)
+		}
+	}
+}
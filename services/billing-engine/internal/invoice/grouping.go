@@ -0,0 +1,127 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// LineItemGrouping decides how an invoice's line items are collapsed
+type LineItemGrouping string
+
+// Line item groupings, as configured with INVOICE_LINE_ITEM_GROUPING
+const (
+	GroupNone     LineItemGrouping = "none"      // One line per charge (the historical behavior)
+	GroupByType   LineItemGrouping = "by_type"   // One line per item type, e.g. all overage charges together
+	GroupByMetric LineItemGrouping = "by_metric" // One line per usage metric; plan fees and true-ups by type
+)
+
+// DefaultLineItemGrouping keeps one line per charge
+const DefaultLineItemGrouping = GroupNone
+
+// ParseLineItemGrouping validates a grouping name ("" = DefaultLineItemGrouping)
+func ParseLineItemGrouping(s string) (LineItemGrouping, error) {
+	switch grouping := LineItemGrouping(s); grouping {
+	case "":
+		return DefaultLineItemGrouping, nil
+	case GroupNone, GroupByType, GroupByMetric:
+		return grouping, nil
+	default:
+		return "", fmt.Errorf("unknown line item grouping %q (want %s, %s or %s)", s, GroupNone, GroupByType, GroupByMetric)
+	}
+}
+
+// requestsMetric tags request overage line items, which have no pricing metric of their own
+const requestsMetric = pricing.RequestsMetric
+
+// itemTypeLabels names each item type on grouped lines
+var itemTypeLabels = map[string]string{
+	"base_plan": "Plan fees",
+	"overage":   "Usage overage",
+	"true_up":   "Minimum commitment true-up",
+	"addon":     "Add-ons",
+	"credit":    "Credits",
+}
+
+// groupLineItems collapses line items per grouping, keeping the order in which groups first
+// appear. Amounts are summed, so the grouped lines add up to the same subtotal
+// Items of different child organizations are never merged, so consolidated sections keep their subtotals
+func groupLineItems(items []LineItem, grouping LineItemGrouping) []LineItem {
+	if grouping != GroupByType && grouping != GroupByMetric {
+		return items
+	}
+
+	type groupKey struct{ child, key string }
+	order := make([]groupKey, 0, len(items))
+	groups := make(map[groupKey][]LineItem)
+	for _, item := range items {
+		key := groupKey{child: item.ChildOrganizationID, key: item.ItemType}
+		if grouping == GroupByMetric && item.metric != "" {
+			key.key = "metric:" + item.metric
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], item)
+	}
+
+	grouped := make([]LineItem, 0, len(order))
+	for _, key := range order {
+		grouped = append(grouped, mergeLineItems(groups[key], grouping))
+	}
+	return grouped
+}
+
+// mergeLineItems combines a group into one line whose description says what it rolls up,
+// e.g. "Usage overage (3 charges)". A single item is returned unchanged
+func mergeLineItems(items []LineItem, grouping LineItemGrouping) LineItem {
+	if len(items) == 1 {
+		return items[0]
+	}
+
+	merged := items[0]
+	merged.AmountCents = 0
+	merged.Quantity = 0
+	sameMetric := true
+	for _, item := range items {
+		merged.AmountCents += item.AmountCents
+		merged.Quantity += item.Quantity
+		merged.PeriodStart = earlier(merged.PeriodStart, item.PeriodStart)
+		merged.PeriodEnd = later(merged.PeriodEnd, item.PeriodEnd)
+		sameMetric = sameMetric && item.metric == merged.metric
+	}
+
+	// Quantities only add up when they count the same unit
+	if !sameMetric || merged.metric == "" {
+		merged.Quantity = 1
+	}
+	merged.UnitPriceCents = pricing.UnitPrice(merged.AmountCents, merged.Quantity)
+
+	label := itemTypeLabels[merged.ItemType]
+	if grouping == GroupByMetric && merged.metric != "" && merged.metric != requestsMetric {
+		label = pricing.MetricLabel(merged.metric)
+	}
+	if label == "" {
+		label = merged.ItemType
+	}
+	merged.Description = fmt.Sprintf("%s (%d charges)", label, len(items))
+
+	return merged
+}
+
+// earlier returns the earlier of two optional times
+func earlier(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
+
+// later returns the later of two optional times
+func later(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
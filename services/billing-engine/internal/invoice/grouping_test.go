@@ -0,0 +1,152 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// groupingRecord has one charge of every kind createLineItems produces
+func groupingRecord() *BillingRecord {
+	return &BillingRecord{
+		OrganizationID:     "org-1",
+		BillingMonth:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		PlanName:           "Growth",
+		BaseChargeCents:    9900,
+		OverageUnits:       500000,
+		OverageChargeCents: 2500,
+		MetricCharges: []pricing.MetricCharge{
+			{Metric: pricing.MetricBytesIn, OverageUnits: 3000000000, UnitSize: 1000000000, UnitName: "GB", Rate: 10, Charge: 30},
+			{Metric: pricing.MetricBytesOut, OverageUnits: 5000000000, UnitSize: 1000000000, UnitName: "GB", Rate: 9, Charge: 45},
+		},
+		TrueUpChargeCents: 1525,
+		SubtotalCents:     9900 + 2500 + 30 + 45 + 1525,
+	}
+}
+
+func TestParseLineItemGrouping(t *testing.T) {
+	for _, s := range []string{"", "none", "by_type", "by_metric"} {
+		if _, err := ParseLineItemGrouping(s); err != nil {
+			t.Errorf("ParseLineItemGrouping(%q) error = %v, want nil", s, err)
+		}
+	}
+	if grouping, _ := ParseLineItemGrouping(""); grouping != GroupNone {
+		t.Errorf("ParseLineItemGrouping(\"\") = %s, want %s", grouping, GroupNone)
+	}
+	if _, err := ParseLineItemGrouping("by_day"); err == nil {
+		t.Error("Expected an error for an unknown grouping")
+	}
+}
+
+// TestCreateLineItems_Grouping tests that grouped totals equal ungrouped totals
+func TestCreateLineItems_Grouping(t *testing.T) {
+	record := groupingRecord()
+	periodStart, periodEnd := record.BillingPeriod()
+
+	tests := []struct {
+		grouping     LineItemGrouping
+		descriptions []string
+	}{
+		{GroupNone, nil}, // Unchanged: one line per charge
+		{GroupByType, []string{"Growth Plan - Jan 1 - Jan 31, 2026", "Usage overage (3 charges)", "Minimum commitment true-up"}},
+		{GroupByMetric, nil}, // Every metric has a single charge per record
+	}
+
+	config := createTestConfig()
+	ungrouped := NewInvoiceGenerator(nil, nil, nil, config).createLineItems(record, periodStart, periodEnd)
+
+	for _, tt := range tests {
+		t.Run(string(tt.grouping), func(t *testing.T) {
+			config := createTestConfig()
+			config.LineItemGrouping = tt.grouping
+			items := NewInvoiceGenerator(nil, nil, nil, config).createLineItems(record, periodStart, periodEnd)
+
+			if total := sumLineItems(items); total != record.SubtotalCents || total != sumLineItems(ungrouped) {
+				t.Errorf("Grouped total = %d, want the ungrouped %d", total, record.SubtotalCents)
+			}
+
+			want := tt.descriptions
+			if want == nil {
+				if len(items) != len(ungrouped) {
+					t.Fatalf("Got %d items, want %d", len(items), len(ungrouped))
+				}
+				return
+			}
+			if len(items) != len(want) {
+				t.Fatalf("Got %d items, want %d: %+v", len(items), len(want), items)
+			}
+			for i, description := range want {
+				if items[i].Description != description {
+					t.Errorf("Item %d = %q, want %q", i, items[i].Description, description)
+				}
+			}
+		})
+	}
+}
+
+// TestGroupLineItems_ByMetric tests that repeated charges for a metric collapse into one line
+func TestGroupLineItems_ByMetric(t *testing.T) {
+	jan1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	jan15 := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	jan31 := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	items := []LineItem{
+		{Description: "Growth Plan", Quantity: 1, UnitPriceCents: 9900, AmountCents: 9900, ItemType: "base_plan"},
+		{Description: "API overage Jan 1-14", Quantity: 200000, AmountCents: 800, ItemType: "overage", PeriodStart: &jan1, PeriodEnd: &jan15, metric: requestsMetric},
+		{Description: "Data transfer out", Quantity: 1, AmountCents: 45, ItemType: "overage", PeriodStart: &jan1, PeriodEnd: &jan31, metric: pricing.MetricBytesOut},
+		{Description: "API overage Jan 15-31", Quantity: 300000, AmountCents: 1200, ItemType: "overage", PeriodStart: &jan15, PeriodEnd: &jan31, metric: requestsMetric},
+	}
+
+	grouped := groupLineItems(items, GroupByMetric)
+
+	if len(grouped) != 3 {
+		t.Fatalf("Got %d items, want 3: %+v", len(grouped), grouped)
+	}
+	if sumLineItems(grouped) != sumLineItems(items) {
+		t.Errorf("Grouped total = %d, want %d", sumLineItems(grouped), sumLineItems(items))
+	}
+
+	requests := grouped[1]
+	if requests.Description != "Usage overage (2 charges)" {
+		t.Errorf("Description = %q, want %q", requests.Description, "Usage overage (2 charges)")
+	}
+	if requests.Quantity != 500000 || requests.AmountCents != 2000 {
+		t.Errorf("Quantity = %d, amount = %d, want 500000 and 2000", requests.Quantity, requests.AmountCents)
+	}
+	if !requests.PeriodStart.Equal(jan1) || !requests.PeriodEnd.Equal(jan31) {
+		t.Errorf("Period = %v - %v, want the span of both charges", requests.PeriodStart, requests.PeriodEnd)
+	}
+	if grouped[2].Description != "Data transfer out" {
+		t.Errorf("Single charge = %q, want it unchanged", grouped[2].Description)
+	}
+
+	// By type, quantities of different units are not added up
+	byType := groupLineItems(items, GroupByType)
+	if len(byType) != 2 || byType[1].Quantity != 1 || byType[1].UnitPriceCents != 2045 {
+		t.Errorf("By type = %+v, want one overage line of quantity 1 at 2045", byType)
+	}
+}
+
+// TestGroupLineItems_KeepsSections tests that consolidated sections are grouped separately
+func TestGroupLineItems_KeepsSections(t *testing.T) {
+	items := []LineItem{
+		{AmountCents: 100, ItemType: "overage", ChildOrganizationID: "org-a", ChildOrganizationName: "A", metric: requestsMetric},
+		{AmountCents: 200, ItemType: "overage", ChildOrganizationID: "org-a", ChildOrganizationName: "A", metric: pricing.MetricBytesIn},
+		{AmountCents: 400, ItemType: "overage", ChildOrganizationID: "org-b", ChildOrganizationName: "B", metric: requestsMetric},
+	}
+
+	sections := lineItemSections(groupLineItems(items, GroupByType))
+	if len(sections) != 2 || sections[0].SubtotalCents != 300 || sections[1].SubtotalCents != 400 {
+		t.Errorf("Sections = %+v, want A at 300 and B at 400", sections)
+	}
+}
+
+// sumLineItems adds up line item amounts
+func sumLineItems(items []LineItem) int64 {
+	var total int64
+	for _, item := range items {
+		total += item.AmountCents
+	}
+	return total
+}
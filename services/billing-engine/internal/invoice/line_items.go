@@ -0,0 +1,46 @@
+package invoice
+
+import (
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// buildLineItems lists a billing calculation's charges for the period with the overage described
+// in unitLabel, using the same builder as the dashboard's invoice previews
+func buildLineItems(calc pricing.BillingCalculation, periodStart, periodEnd time.Time, unitLabel string) []LineItem {
+	charges := pricing.BuildLineItems(calc, periodStart, periodEnd, unitLabel)
+
+	items := make([]LineItem, 0, len(charges))
+	for _, charge := range charges {
+		items = append(items, LineItem{
+			Description:    charge.Description,
+			Quantity:       charge.Quantity,
+			UnitPriceCents: charge.UnitPriceCents,
+			AmountCents:    charge.AmountCents,
+			ItemType:       charge.ItemType,
+			PeriodStart:    &periodStart,
+			PeriodEnd:      &periodEnd,
+			metric:         charge.Metric,
+		})
+	}
+	return items
+}
+
+// Calculation returns the billing calculation the record stores
+func (r *BillingRecord) Calculation() pricing.BillingCalculation {
+	return pricing.BillingCalculation{
+		OrganizationID: r.OrganizationID,
+		Month:          r.BillingMonth,
+		PlanName:       r.PlanName,
+		BasePrice:      r.BaseChargeCents,
+		IncludedUnits:  r.IncludedUnits,
+		UsedUnits:      r.UsageUnits,
+		OverageUnits:   r.OverageUnits,
+		OverageCharge:  r.OverageChargeCents,
+		TrueUpCharge:   r.TrueUpChargeCents,
+		MetricCharges:  r.MetricCharges,
+		SLACredit:      r.SLACredit,
+		TotalCharge:    r.SubtotalCents,
+	}
+}
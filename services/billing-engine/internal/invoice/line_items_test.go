@@ -0,0 +1,112 @@
+package invoice
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// TestBuildLineItems tests that a calculation and its billing record produce the same line items
+func TestBuildLineItems(t *testing.T) {
+	periodStart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+	period := func(item LineItem) LineItem {
+		item.PeriodStart, item.PeriodEnd = &periodStart, &periodEnd
+		return item
+	}
+
+	tests := []struct {
+		name string
+		calc pricing.BillingCalculation
+		want []LineItem
+	}{
+		{
+			name: "Base plan only",
+			calc: pricing.BillingCalculation{PlanName: "Growth", BasePrice: 9900, IncludedUnits: 1000000, UsedUnits: 800000, TotalCharge: 9900},
+			want: []LineItem{
+				period(LineItem{Description: "Growth Plan - Jan 1 - Jan 31, 2026", Quantity: 1, UnitPriceCents: 9900, AmountCents: 9900, ItemType: "base_plan"}),
+			},
+		},
+		{
+			name: "Overage",
+			calc: pricing.BillingCalculation{
+				PlanName: "Growth", BasePrice: 9900, IncludedUnits: 1000000, UsedUnits: 1500000,
+				OverageUnits: 500000, OverageRate: 4, OverageCharge: 200,
+				MetricCharges: []pricing.MetricCharge{
+					{Metric: pricing.MetricBytesOut, UsedUnits: 15000000000, IncludedUnits: 10000000000, OverageUnits: 5000000000, UnitSize: 1000000000, UnitName: "GB", Rate: 9, Charge: 45},
+					{Metric: pricing.MetricBytesIn, UsedUnits: 1000000000, IncludedUnits: 10000000000, UnitSize: 1000000000, UnitName: "GB", Rate: 10}, // Within the allowance
+				},
+				TotalCharge: 9900 + 200 + 45,
+			},
+			want: []LineItem{
+				period(LineItem{Description: "Growth Plan - Jan 1 - Jan 31, 2026", Quantity: 1, UnitPriceCents: 9900, AmountCents: 9900, ItemType: "base_plan"}),
+				period(LineItem{Description: "Usage overage - 500.0K requests over limit", Quantity: 500000, UnitPriceCents: 0, AmountCents: 200, ItemType: "overage", metric: requestsMetric}),
+				period(LineItem{Description: "Data transfer out - 5.00 GB over 10.00 GB included at $0.09/GB", Quantity: 1, UnitPriceCents: 45, AmountCents: 45, ItemType: "overage", metric: pricing.MetricBytesOut}),
+			},
+		},
+		{
+			name: "Free plan",
+			calc: pricing.BillingCalculation{PlanName: "Free", IncludedUnits: 10000, UsedUnits: 4000},
+			want: []LineItem{},
+		},
+		{
+			name: "SLA breached",
+			calc: pricing.BillingCalculation{
+				PlanName: "Growth", BasePrice: 9900, IncludedUnits: 1000000, UsedUnits: 800000,
+				SLACredit: &pricing.SLACredit{
+					LatencyThresholdMs: 500, MaxBreachPercent: 1, Requests: 800000, BreachedRequests: 20000,
+					BreachPercent: 2.5, CreditPercent: 10, Credit: 990,
+				},
+				TotalCharge: 9900 - 990,
+			},
+			want: []LineItem{
+				period(LineItem{Description: "Growth Plan - Jan 1 - Jan 31, 2026", Quantity: 1, UnitPriceCents: 9900, AmountCents: 9900, ItemType: "base_plan"}),
+				period(LineItem{Description: "SLA credit - 2.5% of requests over 500ms (SLA allows 1%), 10% off", Quantity: 1, UnitPriceCents: -990, AmountCents: -990, ItemType: "credit"}),
+			},
+		},
+		{
+			name: "SLA breached on a free plan",
+			calc: pricing.BillingCalculation{
+				PlanName: "Free", IncludedUnits: 10000, UsedUnits: 4000,
+				SLACredit: &pricing.SLACredit{LatencyThresholdMs: 500, MaxBreachPercent: 1, Requests: 4000, BreachedRequests: 400, BreachPercent: 10, CreditPercent: 10},
+			},
+			want: []LineItem{},
+		},
+	}
+
+	gen := NewInvoiceGenerator(nil, nil, nil, createTestConfig())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildLineItems(tt.calc, periodStart, periodEnd, DefaultUsageUnitLabel)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildLineItems() = %+v, want %+v", got, tt.want)
+			}
+
+			// The generator bills the same calculation, stored as a billing record, identically
+			record := &BillingRecord{
+				PlanName:           tt.calc.PlanName,
+				BaseChargeCents:    tt.calc.BasePrice,
+				OverageUnits:       tt.calc.OverageUnits,
+				OverageChargeCents: tt.calc.OverageCharge,
+				TrueUpChargeCents:  tt.calc.TrueUpCharge,
+				MetricCharges:      tt.calc.MetricCharges,
+				SLACredit:          tt.calc.SLACredit,
+			}
+			if fromRecord := gen.createLineItems(record, periodStart, periodEnd); !reflect.DeepEqual(fromRecord, got) {
+				t.Errorf("createLineItems() = %+v, want %+v", fromRecord, got)
+			}
+
+			// The items add up to the subtotal, credits included
+			var sum int64
+			for _, item := range got {
+				sum += item.AmountCents
+			}
+			if sum != tt.calc.TotalCharge {
+				t.Errorf("Line items sum to %d, want the %d subtotal", sum, tt.calc.TotalCharge)
+			}
+		})
+	}
+}
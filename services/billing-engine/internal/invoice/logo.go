@@ -0,0 +1,170 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Logo loading limits
+const (
+	logoFetchTimeout  = 5 * time.Second
+	logoMaxBytes      = 2 << 20 // 2 MiB
+	logoRetryInterval = 5 * time.Minute
+	logoMaxWidth      = 50.0 // mm
+	logoMaxHeight     = 20.0 // mm
+	logoImageName     = "company-logo"
+)
+
+// companyLogo is a loaded logo image ready to register with gofpdf
+type companyLogo struct {
+	data      []byte
+	imageType string // "PNG" or "JPG"
+}
+
+// logoCache loads the configured logo once and shares it across PDFs
+// Failed loads are retried after logoRetryInterval rather than on every invoice
+type logoCache struct {
+	mu         sync.Mutex
+	logo       *companyLogo
+	lastFailed time.Time
+	client     *http.Client
+}
+
+// get returns the logo for source, or nil if it is unset or cannot be loaded
+func (c *logoCache) get(source string) *companyLogo {
+	if source == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.logo != nil {
+		return c.logo
+	}
+	if !c.lastFailed.IsZero() && time.Since(c.lastFailed) < logoRetryInterval {
+		return nil
+	}
+
+	logo, err := loadLogo(c.client, source)
+	if err != nil {
+		log.Printf("[PDFGenerator] WARNING: Failed to load company logo %s, using text header: %v", source, err)
+		c.lastFailed = time.Now()
+		return nil
+	}
+
+	c.logo = logo
+	return logo
+}
+
+// loadLogo reads a PNG or JPEG logo from a local file or an http(s) URL
+func loadLogo(client *http.Client, source string) (*companyLogo, error) {
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = fetchLogo(client, source)
+	} else {
+		data, err = readLogoFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	imageType, err := logoImageType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &companyLogo{data: data, imageType: imageType}, nil
+}
+
+// fetchLogo downloads a logo, bounded by logoFetchTimeout and logoMaxBytes
+func fetchLogo(client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: logoFetchTimeout}
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch logo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch logo: status %d", resp.StatusCode)
+	}
+
+	return readLimited(resp.Body)
+}
+
+// readLogoFile reads a logo from disk, bounded by logoMaxBytes
+func readLogoFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open logo: %w", err)
+	}
+	defer f.Close()
+
+	return readLimited(f)
+}
+
+// readLimited reads r, failing if it holds more than logoMaxBytes
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, logoMaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo: %w", err)
+	}
+	if len(data) > logoMaxBytes {
+		return nil, fmt.Errorf("logo exceeds %d bytes", logoMaxBytes)
+	}
+	return data, nil
+}
+
+// logoImageType sniffs the gofpdf image type from the logo contents
+func logoImageType(data []byte) (string, error) {
+	switch contentType := http.DetectContentType(data); contentType {
+	case "image/png":
+		return "PNG", nil
+	case "image/jpeg":
+		return "JPG", nil
+	default:
+		return "", fmt.Errorf("unsupported logo format %s (want PNG or JPEG)", contentType)
+	}
+}
+
+// drawLogo places the logo in the top-right corner, scaled to fit logoMaxWidth x logoMaxHeight
+// Returns false, leaving pdf usable, if gofpdf cannot parse the image
+func drawLogo(pdf *gofpdf.Fpdf, logo *companyLogo) bool {
+	options := gofpdf.ImageOptions{ImageType: logo.imageType}
+	info := pdf.RegisterImageOptionsReader(logoImageName, options, bytes.NewReader(logo.data))
+	if err := pdf.Error(); err != nil {
+		// e.g. interlaced PNGs, which gofpdf does not support
+		log.Printf("[PDFGenerator] WARNING: Failed to embed company logo, using text header: %v", err)
+		pdf.ClearError()
+		return false
+	}
+
+	width, height := logoMaxWidth, logoMaxHeight
+	if w, h := info.Extent(); w > 0 && h > 0 {
+		if w/h > logoMaxWidth/logoMaxHeight {
+			height = logoMaxWidth * h / w
+		} else {
+			width = logoMaxHeight * w / h
+		}
+	}
+
+	pageWidth, _ := pdf.GetPageSize()
+	_, top, right, _ := pdf.GetMargins()
+	pdf.ImageOptions(logoImageName, pageWidth-right-width, top, width, height, false, options, 0, "")
+	return true
+}
@@ -3,6 +3,8 @@ package invoice
 import (
 	"database/sql"
 	"fmt"
+	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -11,12 +13,15 @@ import (
 
 // Invoice statuses
 const (
-	InvoiceStatusDraft    = "draft"     // Created but not finalized
-	InvoiceStatusPending  = "pending"   // Sent to customer, awaiting payment
-	InvoiceStatusPaid     = "paid"      // Payment received
-	InvoiceStatusFailed   = "failed"    // Payment attempt failed
-	InvoiceStatusRefunded = "refunded"  // Payment refunded
-	InvoiceStatusVoided   = "voided"    // Invoice cancelled
+	InvoiceStatusDraft             = "draft"              // Created but not finalized
+	InvoiceStatusPending           = "pending"            // Sent to customer, awaiting payment
+	InvoiceStatusPaid              = "paid"               // Payment received
+	InvoiceStatusFailed            = "failed"             // Payment attempt failed
+	InvoiceStatusRefunded          = "refunded"           // Payment refunded
+	InvoiceStatusPartiallyRefunded = "partially_refunded" // Part of the payment refunded
+	InvoiceStatusVoided            = "voided"             // Invoice cancelled
+	InvoiceStatusSendFailed        = "send_failed"        // Email delivery failed, awaiting retry
+	InvoiceStatusEmailFailed       = "email_failed"       // Email retries exhausted; resend manually
 )
 
 // Invoice represents a billing invoice for an organization
@@ -36,6 +41,16 @@ type Invoice struct {
 	DiscountCents int64 `json:"discount_cents"`
 	TotalCents    int64 `json:"total_cents"`
 
+	// Coupon that produced (part of) DiscountCents, empty if none
+	CouponID string `json:"coupon_id,omitempty"`
+
+	// Voided invoice this one replaced after a usage correction, empty if none
+	SupersedesInvoiceID     string `json:"supersedes_invoice_id,omitempty"`
+	SupersedesInvoiceNumber string `json:"supersedes_invoice_number,omitempty"`
+
+	// Refunds (in cents, including refunds still being issued)
+	RefundedAmountCents int64 `json:"refunded_amount_cents"`
+
 	// Invoice metadata
 	InvoiceNumber    string    `json:"invoice_number"`
 	InvoiceDate      time.Time `json:"invoice_date"`
@@ -44,8 +59,10 @@ type Invoice struct {
 
 	// Storage and delivery
 	PDFUrl            string `json:"pdf_url,omitempty"`
+	PDFSHA256         string `json:"pdf_sha256,omitempty"` // Hex SHA-256 of the uploaded PDF
 	StripeInvoiceID   string `json:"stripe_invoice_id,omitempty"`
 	StripeInvoiceURL  string `json:"stripe_invoice_url,omitempty"`
+	StripeAccountID   string `json:"stripe_account_id,omitempty"` // Connected account the invoice lives on
 	Status            string `json:"status"`
 
 	// Customer details
@@ -60,8 +77,10 @@ type Invoice struct {
 	PaidAt    *time.Time `json:"paid_at,omitempty"`
 
 	// Additional metadata
-	Notes    string                 `json:"notes,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Notes        string                 `json:"notes,omitempty"`
+	PONumber     string                 `json:"po_number,omitempty"`     // Customer purchase-order number
+	CustomFields map[string]string      `json:"custom_fields,omitempty"` // Printed on the PDF and sent as Stripe metadata
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // LineItem represents a single charge on an invoice
@@ -72,9 +91,15 @@ type LineItem struct {
 	Quantity         int64   `json:"quantity"`
 	UnitPriceCents   int64   `json:"unit_price_cents"`
 	AmountCents      int64   `json:"amount_cents"`
-	ItemType         string  `json:"item_type"` // "base_plan", "overage", "addon"
+	ItemType         string  `json:"item_type"` // "base_plan", "overage", "true_up", "addon", "credit"
 	PeriodStart      *time.Time `json:"period_start,omitempty"`
 	PeriodEnd        *time.Time `json:"period_end,omitempty"`
+
+	// Set on consolidated invoices: the child organization the item was billed for
+	ChildOrganizationID   string `json:"child_organization_id,omitempty"`
+	ChildOrganizationName string `json:"child_organization_name,omitempty"`
+
+	metric string // Usage metric the item charges for, used to group line items (not stored)
 }
 
 // InvoiceGenerator handles invoice creation and delivery
@@ -82,6 +107,7 @@ type InvoiceGenerator struct {
 	db           *sql.DB
 	s3Client     *s3.Client
 	stripeClient *client.API
+	stripe       stripeOperations
 	config       *InvoiceConfig
 }
 
@@ -91,6 +117,12 @@ type InvoiceConfig struct {
 	S3Bucket       string
 	S3Region       string
 	S3Endpoint     string // For MinIO or custom S3-compatible storage
+	S3UsePathStyle bool   // Address buckets as endpoint/bucket instead of bucket.endpoint (MinIO)
+	S3TransitionDays int  // Days before invoice PDFs move to Standard-IA (0 = never)
+	S3ExpirationDays int  // Days before S3 deletes invoice PDFs (0 = keep indefinitely)
+	S3ApplyLifecycle bool // Apply the retention rule in CreateBucketIfNotExists
+	VoidedPDFRetentionDays int // Days after the invoice date voided invoices' PDFs are kept (0 = keep indefinitely)
+	PDFLinkExpiration time.Duration // Lifetime of the presigned PDF links UploadPDF returns (0 = default, max 7 days)
 
 	// Stripe
 	StripeAPIKey   string
@@ -103,21 +135,66 @@ type InvoiceConfig struct {
 	SMTPPassword   string
 	FromEmail      string
 	FromName       string
+	EmailMaxRetries    int           // Retries after the first failed send (0 disables retry)
+	EmailRetryInterval time.Duration // Delay before first retry (doubles each attempt)
 
 	// Invoice settings
 	CompanyName    string
 	CompanyAddress string
 	CompanyEmail   string
 	CompanyPhone   string
-	CompanyLogo    string // URL to logo
+	CompanyLogo    string // Logo file path or http(s) URL (PNG or JPEG)
 	TaxRate        float64 // e.g., 0.08 for 8% tax
 	PaymentTerms   int    // Days until due (e.g., 30 for Net 30)
+	UsageUnitLabel string // Plural name of a billable unit (e.g., "requests", "messages")
+	GenerationWorkers int // Concurrent invoice creations in GenerateMonthly (0 = GOMAXPROCS)
+	LineItemGrouping LineItemGrouping // How line items are collapsed ("" = one line per charge)
+	PDFMaxAddressLength int // Billing address characters rendered on PDFs (0 = default)
 
 	// Feature flags
-	EnableStripe   bool
-	EnableEmail    bool
-	EnableS3       bool
-	EnableTax      bool
+	EnableStripe        bool
+	EnableStripeConnect bool // Create customers/invoices on orgs' connected accounts
+	EnableEmail         bool
+	EnableS3            bool
+	EnableTax           bool
+}
+
+// DefaultUsageUnitLabel is used when no unit label is configured
+const DefaultUsageUnitLabel = "requests"
+
+// DefaultMaxAddressLength bounds billing addresses rendered on PDFs
+const DefaultMaxAddressLength = 300
+
+// MaxAddressLength returns the configured PDF billing address limit
+func (c *InvoiceConfig) MaxAddressLength() int {
+	if c.PDFMaxAddressLength > 0 {
+		return c.PDFMaxAddressLength
+	}
+	return DefaultMaxAddressLength
+}
+
+// PresignExpiration returns the lifetime of presigned PDF links, defaulting to 7 days
+func (c *InvoiceConfig) PresignExpiration() time.Duration {
+	if c.PDFLinkExpiration > 0 {
+		return c.PDFLinkExpiration
+	}
+	return DefaultPresignExpiration
+}
+
+// Workers returns the invoice generation pool size, defaulting to GOMAXPROCS
+func (c *InvoiceConfig) Workers() int {
+	if c.GenerationWorkers > 0 {
+		return c.GenerationWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// UnitLabel returns the configured usage unit label, falling back to "requests"
+func (c *InvoiceConfig) UnitLabel() string {
+	if c.UsageUnitLabel == "" {
+		return DefaultUsageUnitLabel
+	}
+	return c.UsageUnitLabel
 }
 
 // NewInvoiceGenerator creates a new invoice generator
@@ -126,6 +203,7 @@ func NewInvoiceGenerator(db *sql.DB, s3Client *s3.Client, stripeClient *client.A
 		db:           db,
 		s3Client:     s3Client,
 		stripeClient: stripeClient,
+		stripe:       NewStripeIntegration(stripeClient, config),
 		config:       config,
 	}
 }
@@ -135,6 +213,7 @@ type InvoiceSummary struct {
 	TotalInvoices   int
 	SuccessCount    int
 	FailureCount    int
+	SkippedCount    int // Already invoiced for the period
 	TotalRevenue    int64
 	Errors          []InvoiceError
 	ProcessingTime  time.Duration
@@ -193,14 +272,18 @@ type InvoiceFilter struct {
 
 // Utility functions
 
-// formatPrice formats cents to currency string
+// formatPrice formats cents to currency string with thousands separators ($1,234.56)
 func formatPrice(cents int64) string {
-	dollars := float64(cents) / 100.0
-	// Add thousands separator for large amounts
-	if dollars >= 1000 {
-		return fmt.Sprintf("$%,.2f", dollars)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	dollars := strconv.FormatInt(cents/100, 10)
+	for i := len(dollars) - 3; i > 0; i -= 3 {
+		dollars = dollars[:i] + "," + dollars[i:]
 	}
-	return fmt.Sprintf("$%.2f", dollars)
+	return fmt.Sprintf("%s$%s.%02d", sign, dollars, cents%100)
 }
 
 // formatUsage formats large usage numbers with K/M suffix
@@ -3,7 +3,10 @@ package invoice
 import (
 	"bytes"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/jung-kurt/gofpdf"
 )
@@ -11,12 +14,14 @@ import (
 // PDFGenerator handles PDF generation for invoices
 type PDFGenerator struct {
 	config *InvoiceConfig
+	logos  *logoCache
 }
 
 // NewPDFGenerator creates a new PDF generator
 func NewPDFGenerator(config *InvoiceConfig) *PDFGenerator {
 	return &PDFGenerator{
 		config: config,
+		logos:  &logoCache{client: &http.Client{Timeout: logoFetchTimeout}},
 	}
 }
 
@@ -59,6 +64,13 @@ func (p *PDFGenerator) GeneratePDF(invoice *Invoice) ([]byte, error) {
 
 // addHeader adds company logo and header
 func (p *PDFGenerator) addHeader(pdf *gofpdf.Fpdf) {
+	// Logo in the top-right corner; a missing or broken logo falls back to text only
+	if p.logos != nil {
+		if logo := p.logos.get(p.config.CompanyLogo); logo != nil {
+			drawLogo(pdf, logo)
+		}
+	}
+
 	// Company name and details
 	pdf.SetFont("Arial", "B", 24)
 	pdf.CellFormat(190, 10, p.config.CompanyName, "", 1, "L", false, 0, "")
@@ -81,11 +93,17 @@ func (p *PDFGenerator) addHeader(pdf *gofpdf.Fpdf) {
 	pdf.Ln(10)
 }
 
-// addInvoiceDetails adds invoice number, date, and due date
+// addInvoiceDetails adds invoice number, dates, PO number, and custom fields, noting the invoice replaced
 func (p *PDFGenerator) addInvoiceDetails(pdf *gofpdf.Fpdf, invoice *Invoice) {
 	// Invoice title
 	pdf.SetFont("Arial", "B", 20)
 	pdf.CellFormat(190, 10, "INVOICE", "", 1, "L", false, 0, "")
+
+	// Regenerated invoices name the voided invoice they replace
+	if invoice.SupersedesInvoiceNumber != "" {
+		pdf.SetFont("Arial", "I", 10)
+		pdf.CellFormat(190, 6, "Replaces "+invoice.SupersedesInvoiceNumber, "", 1, "L", false, 0, "")
+	}
 	pdf.Ln(5)
 
 	// Invoice details in a box
@@ -115,9 +133,29 @@ func (p *PDFGenerator) addInvoiceDetails(pdf *gofpdf.Fpdf, invoice *Invoice) {
 	pdf.SetFont("Arial", "", 10)
 	billingPeriod := invoice.BillingPeriodStart.Format("Jan 2") + " - " + invoice.BillingPeriodEnd.Format("Jan 2, 2006")
 	pdf.CellFormat(60, 6, billingPeriod, "", 1, "L", true, 0, "")
+
+	// PO Number, then custom fields sorted by name
+	if po := sanitizePDFText(invoice.PONumber, maxFieldLength, 1); po != "" {
+		p.addDetailRow(pdf, "PO Number:", po)
+	}
+	for _, key := range customFieldKeys(invoice.CustomFields) {
+		label := sanitizePDFText(key, maxFieldLabelLength, 1)
+		value := sanitizePDFText(invoice.CustomFields[key], maxFieldLength, 1)
+		if label != "" && value != "" {
+			p.addDetailRow(pdf, label+":", value)
+		}
+	}
 	pdf.Ln(8)
 }
 
+// addDetailRow adds one labeled row to the invoice details box
+func (p *PDFGenerator) addDetailRow(pdf *gofpdf.Fpdf, label, value string) {
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(40, 6, label, "", 0, "L", true, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(60, 6, value, "", 1, "L", true, 0, "")
+}
+
 // addCustomerDetails adds bill-to information
 func (p *PDFGenerator) addCustomerDetails(pdf *gofpdf.Fpdf, invoice *Invoice) {
 	pdf.SetFont("Arial", "B", 12)
@@ -130,70 +168,150 @@ func (p *PDFGenerator) addCustomerDetails(pdf *gofpdf.Fpdf, invoice *Invoice) {
 		pdf.CellFormat(190, 5, invoice.CustomerEmail, "", 1, "L", false, 0, "")
 	}
 
-	if invoice.BillingAddress != "" {
-		pdf.MultiCell(0, 5, invoice.BillingAddress, "", "L", false)
+	if address := sanitizePDFText(invoice.BillingAddress, p.config.MaxAddressLength(), maxAddressLines); address != "" {
+		pdf.MultiCell(0, 5, address, "", "L", false)
 	}
 
 	pdf.Ln(10)
 }
 
+// Line items table layout (mm)
+const (
+	tableDescWidth    = 90.0
+	tableQtyWidth     = 25.0
+	tablePriceWidth   = 35.0
+	tableAmountWidth  = 40.0
+	tableWidth        = tableDescWidth + tableQtyWidth + tablePriceWidth + tableAmountWidth
+	tableHeaderHeight = 8.0
+	tableLineHeight   = 6.0
+
+	// Heading and subtotal rows of a consolidated invoice section
+	tableSectionHeight = 7.0
+
+	// Space kept below the last row so the totals never start a page on their own
+	// (subtotal, tax and discount rows, the total row, and the gap after the table)
+	totalsBlockHeight = 3*6.0 + 8.0 + 5.0
+)
+
 // addLineItemsTable adds the line items table
+// Rows that would cross the bottom margin move to a new page, which repeats the column header
+// Consolidated invoices get a heading and a subtotal row for each child organization's section
 func (p *PDFGenerator) addLineItemsTable(pdf *gofpdf.Fpdf, lineItems []LineItem) {
-	// Table header
+	p.addTableHeader(pdf)
+
+	sections := lineItemSections(lineItems)
+	fill := false
+	for s, section := range sections {
+		for i, item := range section.Items {
+			first, last := i == 0, i == len(section.Items)-1
+
+			// Measure the wrapped description before drawing so a row is never split across pages
+			lines := len(pdf.SplitLines([]byte(item.Description), tableDescWidth))
+			if lines < 1 {
+				lines = 1
+			}
+			needed := float64(lines) * tableLineHeight
+
+			// Keep section headings and subtotals on the same page as their rows
+			if section.Name != "" && first {
+				needed += tableSectionHeight
+			}
+			if section.Name != "" && last {
+				needed += tableSectionHeight
+			}
+			// Keep the last row on the same page as the totals
+			if last && s == len(sections)-1 {
+				needed += totalsBlockHeight
+			}
+			p.breakTableIfNeeded(pdf, needed)
+
+			if section.Name != "" && first {
+				p.addSectionHeading(pdf, section.Name)
+			}
+			p.addLineItemRow(pdf, item, fill)
+			fill = !fill
+			if section.Name != "" && last {
+				p.addSectionSubtotal(pdf, section)
+			}
+		}
+	}
+
+	// Close table
+	pdf.CellFormat(tableWidth, 0, "", "T", 1, "", false, 0, "")
+	pdf.Ln(5)
+}
+
+// breakTableIfNeeded closes the table and continues it on a new page when the next
+// needed millimetres would cross the bottom margin
+func (p *PDFGenerator) breakTableIfNeeded(pdf *gofpdf.Fpdf, needed float64) {
+	_, pageHeight := pdf.GetPageSize()
+	_, bottomMargin := pdf.GetAutoPageBreak()
+	if pdf.GetY()+needed <= pageHeight-bottomMargin {
+		return
+	}
+
+	pdf.CellFormat(tableWidth, 0, "", "T", 1, "", false, 0, "")
+	pdf.AddPage()
+	p.addTableHeader(pdf)
+}
+
+// addSectionHeading draws the name of a consolidated invoice section across the table
+func (p *PDFGenerator) addSectionHeading(pdf *gofpdf.Fpdf, name string) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(tableWidth, tableSectionHeight, name, "1", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+}
+
+// addSectionSubtotal draws the total of a consolidated invoice section
+func (p *PDFGenerator) addSectionSubtotal(pdf *gofpdf.Fpdf, section lineItemSection) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(tableWidth-tableAmountWidth, tableSectionHeight, "Subtotal - "+section.Name, "1", 0, "R", false, 0, "")
+	pdf.CellFormat(tableAmountWidth, tableSectionHeight, p.formatPrice(section.SubtotalCents), "1", 1, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+}
+
+// addTableHeader draws the line items column header and sets the row style
+func (p *PDFGenerator) addTableHeader(pdf *gofpdf.Fpdf) {
 	pdf.SetFillColor(60, 60, 60)
 	pdf.SetTextColor(255, 255, 255)
 	pdf.SetFont("Arial", "B", 10)
 
-	// Column widths
-	descWidth := 90.0
-	qtyWidth := 25.0
-	priceWidth := 35.0
-	amountWidth := 40.0
-
-	pdf.CellFormat(descWidth, 8, "Description", "1", 0, "L", true, 0, "")
-	pdf.CellFormat(qtyWidth, 8, "Quantity", "1", 0, "C", true, 0, "")
-	pdf.CellFormat(priceWidth, 8, "Unit Price", "1", 0, "R", true, 0, "")
-	pdf.CellFormat(amountWidth, 8, "Amount", "1", 1, "R", true, 0, "")
+	pdf.CellFormat(tableDescWidth, tableHeaderHeight, "Description", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(tableQtyWidth, tableHeaderHeight, "Quantity", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(tablePriceWidth, tableHeaderHeight, "Unit Price", "1", 0, "R", true, 0, "")
+	pdf.CellFormat(tableAmountWidth, tableHeaderHeight, "Amount", "1", 1, "R", true, 0, "")
 
 	// Table rows
 	pdf.SetFillColor(245, 245, 245)
 	pdf.SetTextColor(0, 0, 0)
 	pdf.SetFont("Arial", "", 9)
+}
 
-	fill := false
-	for _, item := range lineItems {
-		// Description (with word wrap if needed)
-		x := pdf.GetX()
-		y := pdf.GetY()
-		pdf.MultiCell(descWidth, 6, item.Description, "LR", "L", fill)
-
-		// Get height of description cell
-		height := pdf.GetY() - y
-
-		// Move to quantity column
-		pdf.SetXY(x+descWidth, y)
-		quantityStr := fmt.Sprintf("%d", item.Quantity)
-		if item.ItemType == "base_plan" {
-			quantityStr = "1"
-		} else {
-			quantityStr = p.formatUsage(item.Quantity)
-		}
-		pdf.CellFormat(qtyWidth, height, quantityStr, "LR", 0, "C", fill, 0, "")
-
-		// Unit price
-		unitPrice := p.formatPrice(item.UnitPriceCents)
-		pdf.CellFormat(priceWidth, height, unitPrice, "LR", 0, "R", fill, 0, "")
-
-		// Amount
-		amount := p.formatPrice(item.AmountCents)
-		pdf.CellFormat(amountWidth, height, amount, "LR", 1, "R", fill, 0, "")
-
-		fill = !fill
+// addLineItemRow draws a single line item
+func (p *PDFGenerator) addLineItemRow(pdf *gofpdf.Fpdf, item LineItem, fill bool) {
+	// Description (with word wrap if needed)
+	x := pdf.GetX()
+	y := pdf.GetY()
+	pdf.MultiCell(tableDescWidth, tableLineHeight, item.Description, "LR", "L", fill)
+
+	// Get height of description cell
+	height := pdf.GetY() - y
+
+	// Move to quantity column
+	pdf.SetXY(x+tableDescWidth, y)
+	quantityStr := "1"
+	if item.ItemType != "base_plan" {
+		quantityStr = p.formatUsage(item.Quantity)
 	}
+	pdf.CellFormat(tableQtyWidth, height, quantityStr, "LR", 0, "C", fill, 0, "")
 
-	// Close table
-	pdf.CellFormat(descWidth+qtyWidth+priceWidth+amountWidth, 0, "", "T", 1, "", false, 0, "")
-	pdf.Ln(5)
+	// Unit price
+	unitPrice := p.formatPrice(item.UnitPriceCents)
+	pdf.CellFormat(tablePriceWidth, height, unitPrice, "LR", 0, "R", fill, 0, "")
+
+	// Amount
+	amount := p.formatPrice(item.AmountCents)
+	pdf.CellFormat(tableAmountWidth, height, amount, "LR", 1, "R", fill, 0, "")
 }
 
 // addTotals adds subtotal, tax, discount, and total
@@ -250,12 +368,12 @@ func (p *PDFGenerator) addFooter(pdf *gofpdf.Fpdf, invoice *Invoice) {
 	pdf.Ln(5)
 
 	// Additional notes
-	if invoice.Notes != "" {
+	if notes := sanitizePDFText(invoice.Notes, maxNotesLength, maxNotesLines); notes != "" {
 		pdf.SetFont("Arial", "B", 10)
 		pdf.CellFormat(190, 6, "Notes:", "", 1, "L", false, 0, "")
 
 		pdf.SetFont("Arial", "", 9)
-		pdf.MultiCell(0, 5, invoice.Notes, "", "L", false)
+		pdf.MultiCell(0, 5, notes, "", "L", false)
 		pdf.Ln(5)
 	}
 
@@ -267,14 +385,88 @@ func (p *PDFGenerator) addFooter(pdf *gofpdf.Fpdf, invoice *Invoice) {
 	pdf.CellFormat(190, 5, fmt.Sprintf("Invoice generated on %s", time.Now().Format("January 2, 2006")), "", 1, "C", false, 0, "")
 }
 
+// Limits for free-text fields rendered with MultiCell
+const (
+	maxAddressLines = 6
+	maxNotesLength  = 1000
+	maxNotesLines   = 10
+	maxTokenLength  = 40 // Longer words are split so MultiCell can wrap them
+)
+
+// Limits for the single-line rows of the invoice details box (PO number, custom fields);
+// the dashboard caps custom field names and values to fit them
+const (
+	maxFieldLabelLength = 20
+	maxFieldLength      = 40
+)
+
+// sanitizePDFText strips control characters, splits long words, and bounds
+// text to maxLength runes and maxLines lines before it is rendered
+func sanitizePDFText(text string, maxLength, maxLines int) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		// Drop control characters (tabs become spaces)
+		line = strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return ' '
+			}
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, line)
+
+		words := strings.Fields(line)
+		for i, word := range words {
+			words[i] = splitLongWord(word, maxTokenLength)
+		}
+
+		if line = strings.Join(words, " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+
+	return truncateRunes(strings.Join(lines, "\n"), maxLength)
+}
+
+// splitLongWord inserts spaces into words longer than size runes
+func splitLongWord(word string, size int) string {
+	runes := []rune(word)
+	if len(runes) <= size {
+		return word
+	}
+
+	var parts []string
+	for len(runes) > size {
+		parts = append(parts, string(runes[:size]))
+		runes = runes[size:]
+	}
+	parts = append(parts, string(runes))
+
+	return strings.Join(parts, " ")
+}
+
+// truncateRunes shortens text to at most maxLength runes, marking the cut with "..."
+func truncateRunes(text string, maxLength int) string {
+	runes := []rune(text)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return text
+	}
+	if maxLength <= 3 {
+		return string(runes[:maxLength])
+	}
+	return strings.TrimSpace(string(runes[:maxLength-3])) + "..."
+}
+
 // formatPrice formats cents to currency string
 func (p *PDFGenerator) formatPrice(cents int64) string {
-	dollars := float64(cents) / 100.0
-	// Add thousands separator
-	if dollars >= 1000 {
-		return fmt.Sprintf("$%,.2f", dollars)
-	}
-	return fmt.Sprintf("$%.2f", dollars)
+	return formatPrice(cents)
 }
 
 // formatUsage formats large usage numbers with K/M suffix
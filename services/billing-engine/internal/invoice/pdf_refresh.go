@@ -0,0 +1,93 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// pdfRenderer renders an invoice PDF (implemented by PDFGenerator)
+type pdfRenderer interface {
+	GeneratePDF(invoice *Invoice) ([]byte, error)
+}
+
+// pdfUploader stores an invoice PDF and returns its URL (implemented by StorageManager)
+type pdfUploader interface {
+	UploadPDF(ctx context.Context, invoice *Invoice, pdfData []byte) (string, error)
+}
+
+// RegenerateInvalidatedPDFs re-renders and uploads the PDFs of invoices edited since their PDF
+// was stored (invoices.pdf_invalidated_at, set by the dashboard when notes change)
+// An invoice edited again while its PDF is being regenerated stays invalidated for the next run
+// Returns the number of PDFs regenerated
+func (g *InvoiceGenerator) RegenerateInvalidatedPDFs(ctx context.Context, renderer pdfRenderer, storage pdfUploader) (int, error) {
+	query := `
+		SELECT id, pdf_invalidated_at
+		FROM invoices
+		WHERE pdf_invalidated_at IS NOT NULL
+		  AND status <> 'voided'
+		ORDER BY pdf_invalidated_at
+		LIMIT 100
+	`
+
+	rows, err := g.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query invalidated invoice PDFs: %w", err)
+	}
+
+	type invalidated struct {
+		id string
+		at time.Time
+	}
+	var pending []invalidated
+	for rows.Next() {
+		var inv invalidated
+		if err := rows.Scan(&inv.id, &inv.at); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan invalidated invoice: %w", err)
+		}
+		pending = append(pending, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating invalidated invoices: %w", err)
+	}
+
+	regenerated := 0
+	for _, p := range pending {
+		inv, err := g.GetInvoiceByID(ctx, p.id)
+		if err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to load invoice %s for PDF regeneration: %v", p.id, err)
+			continue
+		}
+
+		pdfData, err := renderer.GeneratePDF(inv)
+		if err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to regenerate PDF of invoice %s: %v", inv.InvoiceNumber, err)
+			continue
+		}
+
+		pdfURL, err := storage.UploadPDF(ctx, inv, pdfData)
+		if err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to upload regenerated PDF of invoice %s: %v", inv.InvoiceNumber, err)
+			continue
+		}
+
+		// Only clear the flag if no edit arrived after the one this PDF reflects
+		result, err := g.db.ExecContext(ctx, `
+			UPDATE invoices
+			SET pdf_url = $1, pdf_sha256 = NULLIF($2, ''), pdf_invalidated_at = NULL, updated_at = NOW()
+			WHERE id = $3 AND pdf_invalidated_at = $4
+		`, pdfURL, inv.PDFSHA256, inv.ID, p.at)
+		if err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to save regenerated PDF of invoice %s: %v", inv.InvoiceNumber, err)
+			continue
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			regenerated++
+		}
+	}
+
+	return regenerated, nil
+}
@@ -2,6 +2,15 @@ package invoice
 
 import (
 	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -164,6 +173,76 @@ func TestPDFGenerator_addInvoiceDetails(t *testing.T) {
 	}
 }
 
+// TestPDFGenerator_addInvoiceDetails_PONumber tests that the PO number and custom fields are rendered
+func TestPDFGenerator_addInvoiceDetails_PONumber(t *testing.T) {
+	gen := NewPDFGenerator(createTestConfig())
+
+	invoice := createTestInvoice()
+	invoice.PONumber = "PO-7781"
+	invoice.CustomFields = map[string]string{"Cost Center": "CC-42", "Project": "Apollo"}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false) // Keep text readable in the page stream
+	pdf.AddPage()
+	gen.addInvoiceDetails(pdf, invoice)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatalf("Failed to output PDF: %v", err)
+	}
+
+	for _, text := range []string{"(PO Number:)", "(PO-7781)", "(Cost Center:)", "(CC-42)", "(Project:)", "(Apollo)"} {
+		if !bytes.Contains(buf.Bytes(), []byte(text)) {
+			t.Errorf("Expected PDF to contain %s", text)
+		}
+	}
+
+	// Custom fields are sorted by name
+	if bytes.Index(buf.Bytes(), []byte("(Cost Center:)")) > bytes.Index(buf.Bytes(), []byte("(Project:)")) {
+		t.Error("Expected custom fields in name order")
+	}
+}
+
+// TestPDFGenerator_addInvoiceDetails_NoPONumber tests that no PO row is drawn without a PO number
+func TestPDFGenerator_addInvoiceDetails_NoPONumber(t *testing.T) {
+	gen := NewPDFGenerator(createTestConfig())
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	gen.addInvoiceDetails(pdf, createTestInvoice())
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatalf("Failed to output PDF: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("PO Number")) {
+		t.Error("Expected no PO Number row")
+	}
+}
+
+// TestPDFGenerator_addInvoiceDetails_Replaces tests the note on a regenerated invoice
+func TestPDFGenerator_addInvoiceDetails_Replaces(t *testing.T) {
+	gen := NewPDFGenerator(createTestConfig())
+
+	invoice := createTestInvoice()
+	invoice.SupersedesInvoiceID = "inv-original"
+	invoice.SupersedesInvoiceNumber = "INV-2026-01-00001"
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	gen.addInvoiceDetails(pdf, invoice)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatalf("Failed to output PDF: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("(Replaces INV-2026-01-00001)")) {
+		t.Error("Expected the PDF to note the invoice it replaces")
+	}
+}
+
 // TestPDFGenerator_addCustomerDetails tests customer details section
 func TestPDFGenerator_addCustomerDetails(t *testing.T) {
 	config := createTestConfig()
@@ -190,6 +269,78 @@ func TestPDFGenerator_addCustomerDetails(t *testing.T) {
 	}
 }
 
+// TestSanitizePDFText tests cleaning free text before it is rendered
+func TestSanitizePDFText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		maxLines  int
+		expected  string
+	}{
+		{"Clean address", "123 Main St\nSpringfield", 300, 6, "123 Main St\nSpringfield"},
+		{"Control characters", "123 Main St\x00\x1b\x07\r\nSuite\t5\x7f", 300, 6, "123 Main St\nSuite 5"},
+		{"Blank lines dropped", "Line 1\n\n \nLine 2", 300, 6, "Line 1\nLine 2"},
+		{"Too many lines", "a\nb\nc\nd", 300, 2, "a\nb"},
+		{"Truncated", "123 Main Street", 10, 6, "123 Mai..."},
+		{"Long word split", strings.Repeat("x", 45), 300, 6, strings.Repeat("x", 40) + " xxxxx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizePDFText(tt.text, tt.maxLength, tt.maxLines)
+			if got != tt.expected {
+				t.Errorf("sanitizePDFText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPDFGenerator_addCustomerDetails_MalformedAddress tests hostile addresses render on a single page
+func TestPDFGenerator_addCustomerDetails_MalformedAddress(t *testing.T) {
+	gen := NewPDFGenerator(createTestConfig())
+
+	tests := []struct {
+		name    string
+		address string
+	}{
+		{"Control characters", "1 Infinite Loop\x00\x1b[2J\x08\x08\r\nBT /F1 12 Tf ET\x0c\nCupertino"},
+		{"Long single word", strings.Repeat("A", 5000)},
+		{"Many lines", strings.Repeat("Line\n", 500)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := createTestInvoice()
+			invoice.BillingAddress = tt.address
+
+			pdf := gofpdf.New("P", "mm", "A4", "")
+			pdf.AddPage()
+			gen.addCustomerDetails(pdf, invoice)
+
+			if err := pdf.Error(); err != nil {
+				t.Fatalf("PDF error after adding customer details: %v", err)
+			}
+			if pdf.PageNo() != 1 {
+				t.Errorf("Address spilled onto %d pages, want 1", pdf.PageNo())
+			}
+
+			rendered := sanitizePDFText(tt.address, gen.config.MaxAddressLength(), maxAddressLines)
+			if strings.IndexFunc(rendered, func(r rune) bool { return r < 0x20 && r != '\n' }) >= 0 {
+				t.Errorf("Rendered address still contains control characters: %q", rendered)
+			}
+			if n := len([]rune(rendered)); n > gen.config.MaxAddressLength() {
+				t.Errorf("Rendered address length = %d, want <= %d", n, gen.config.MaxAddressLength())
+			}
+
+			var buf bytes.Buffer
+			if err := pdf.Output(&buf); err != nil {
+				t.Errorf("Failed to output PDF: %v", err)
+			}
+		})
+	}
+}
+
 // TestPDFGenerator_addLineItemsTable tests line items table
 func TestPDFGenerator_addLineItemsTable(t *testing.T) {
 	config := createTestConfig()
@@ -461,8 +612,8 @@ func TestPDFGenerator_CompleteInvoice(t *testing.T) {
 				t.Error("Expected valid PDF file")
 			}
 			
-			// PDF should be reasonably sized (at least 5KB for a complete invoice)
-			if len(pdfData) < 5000 {
+			// PDF should be reasonably sized (content streams are compressed, so a few KB)
+			if len(pdfData) < 1000 {
 				t.Errorf("PDF seems too small (%d bytes)", len(pdfData))
 			}
 		})
@@ -546,4 +697,208 @@ func TestPDFGenerator_MultiPageInvoice(t *testing.T) {
 	if len(pdfData) < 10000 {
 		t.Errorf("Multi-page PDF seems too small (%d bytes)", len(pdfData))
 	}
+
+	if pages := len(splitPDFPages(pdfData)); pages < 2 {
+		t.Errorf("Page count = %d, want at least 2", pages)
+	}
+}
+
+// splitPDFPages returns the raw page objects of a PDF written by gofpdf
+// gofpdf writes each page dictionary followed by its content stream
+func splitPDFPages(pdfData []byte) [][]byte {
+	parts := bytes.Split(pdfData, []byte("<</Type /Page\n"))
+	if len(parts) < 2 {
+		return nil
+	}
+	return parts[1:]
+}
+
+// TestPDFGenerator_addLineItemsTable_PageBreaks tests that overflowing rows start a new page with the header repeated
+func TestPDFGenerator_addLineItemsTable_PageBreaks(t *testing.T) {
+	gen := NewPDFGenerator(createTestConfig())
+	invoice := createTestInvoice()
+
+	invoice.LineItems = make([]LineItem, 80)
+	for i := range invoice.LineItems {
+		invoice.LineItems[i] = LineItem{
+			Description:    fmt.Sprintf("Service item %d with a somewhat long description to test wrapping across the column", i+1),
+			Quantity:       int64(i + 1),
+			UnitPriceCents: 100,
+			AmountCents:    int64(i+1) * 100,
+		}
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false) // Keep page text searchable
+	pdf.AddPage()
+	gen.addLineItemsTable(pdf, invoice.LineItems)
+	gen.addTotals(pdf, invoice)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatalf("Failed to output PDF: %v", err)
+	}
+
+	pages := splitPDFPages(buf.Bytes())
+	if len(pages) < 2 || len(pages) != pdf.PageCount() {
+		t.Fatalf("Parsed %d pages, PageCount() = %d, want matching counts of at least 2", len(pages), pdf.PageCount())
+	}
+
+	rows := 0
+	for i, page := range pages {
+		if !bytes.Contains(page, []byte("(Description)")) {
+			t.Errorf("Page %d is missing the table header", i+1)
+		}
+		rows += bytes.Count(page, []byte("(Service item "))
+	}
+	if rows != len(invoice.LineItems) {
+		t.Errorf("Rendered %d rows, want %d (rows must not be split across pages)", rows, len(invoice.LineItems))
+	}
+
+	// Totals share the last page with at least one row
+	last := pages[len(pages)-1]
+	if !bytes.Contains(last, []byte("(Total Due:)")) {
+		t.Error("Expected totals on the last page")
+	}
+	if !bytes.Contains(last, []byte("(Service item 80 ")) {
+		t.Error("Expected the last row on the same page as the totals")
+	}
+}
+
+// TestPDFGenerator_addLineItemsTable_Sections tests the per-child sections of a consolidated invoice
+func TestPDFGenerator_addLineItemsTable_Sections(t *testing.T) {
+	gen := NewPDFGenerator(createTestConfig())
+
+	lineItems := []LineItem{
+		{Description: "Starter Plan", Quantity: 1, UnitPriceCents: 2900, AmountCents: 2900, ItemType: "base_plan", ChildOrganizationID: "org-acme", ChildOrganizationName: "Acme Corp"},
+		{Description: "Growth Plan", Quantity: 1, UnitPriceCents: 9900, AmountCents: 9900, ItemType: "base_plan", ChildOrganizationID: "org-zephyr", ChildOrganizationName: "Zephyr Labs"},
+		{Description: "Usage overage", Quantity: 50000, UnitPriceCents: 0, AmountCents: 2500, ItemType: "overage", ChildOrganizationID: "org-zephyr", ChildOrganizationName: "Zephyr Labs"},
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false) // Keep page text searchable
+	pdf.AddPage()
+	gen.addLineItemsTable(pdf, lineItems)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatalf("Failed to output PDF: %v", err)
+	}
+	out := buf.Bytes()
+
+	// Heading, rows, then subtotal for each child in order
+	order := []string{"(Acme Corp)", "(Starter Plan)", "(Subtotal - Acme Corp)", "($29.00)",
+		"(Zephyr Labs)", "(Growth Plan)", "(Usage overage)", "(Subtotal - Zephyr Labs)", "($124.00)"}
+	last := -1
+	for _, text := range order {
+		i := bytes.Index(out[last+1:], []byte(text))
+		if i < 0 {
+			t.Fatalf("Expected %s after offset %d", text, last)
+		}
+		last += 1 + i
+	}
+}
+
+// writeTestLogo encodes a small solid-color image as PNG or JPEG
+func writeTestLogo(t *testing.T, format string) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 120, 40))
+	for x := 0; x < 120; x++ {
+		for y := 0; y < 40; y++ {
+			img.Set(x, y, color.RGBA{R: 30, G: 90, B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode test logo: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestPDFGenerator_Logo tests that configured logos are embedded and bad logos fall back to text
+func TestPDFGenerator_Logo(t *testing.T) {
+	pngLogo := writeTestLogo(t, "png")
+	jpegLogo := writeTestLogo(t, "jpeg")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logo.jpg":
+			w.Write(jpegLogo)
+		case "/huge.png":
+			w.Write(make([]byte, logoMaxBytes+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	pngPath := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(pngPath, pngLogo, 0o644); err != nil {
+		t.Fatalf("Failed to write logo: %v", err)
+	}
+	textPath := filepath.Join(dir, "logo.txt")
+	if err := os.WriteFile(textPath, []byte("not an image"), 0o644); err != nil {
+		t.Fatalf("Failed to write logo: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		logo         string
+		wantEmbedded bool
+	}{
+		{"Local PNG", pngPath, true},
+		{"JPEG URL", server.URL + "/logo.jpg", true},
+		{"URL not found", server.URL + "/missing.png", false},
+		{"Oversized URL", server.URL + "/huge.png", false},
+		{"Missing file", filepath.Join(dir, "missing.png"), false},
+		{"Not an image", textPath, false},
+		{"No logo configured", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestConfig()
+			config.CompanyLogo = tt.logo
+			gen := NewPDFGenerator(config)
+
+			pdfData, err := gen.GeneratePDF(createTestInvoice())
+			if err != nil {
+				t.Fatalf("GeneratePDF() error = %v", err)
+			}
+			if !bytes.HasPrefix(pdfData, []byte("%PDF-")) {
+				t.Error("Expected PDF to start with %PDF header")
+			}
+
+			embedded := bytes.Contains(pdfData, []byte("/Subtype /Image"))
+			if embedded != tt.wantEmbedded {
+				t.Errorf("Logo embedded = %v, want %v", embedded, tt.wantEmbedded)
+			}
+		})
+	}
+}
+
+// TestDrawLogo_UnsupportedImage tests that gofpdf parse errors leave the PDF usable
+func TestDrawLogo_UnsupportedImage(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+
+	// PNG signature with a truncated body sniffs as PNG but cannot be parsed
+	logo := &companyLogo{data: []byte("\x89PNG\r\n\x1a\ngarbage"), imageType: "PNG"}
+	if drawLogo(pdf, logo) {
+		t.Error("Expected drawLogo to fail for a corrupt image")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Errorf("Failed to output PDF after logo failure: %v", err)
+	}
 }
@@ -0,0 +1,138 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+)
+
+// stripeSubscriptionUpdater moves Stripe subscriptions to another price (implemented by StripeIntegration)
+type stripeSubscriptionUpdater interface {
+	UpdateSubscriptionPrice(ctx context.Context, subscriptionID, priceID, accountID string, prorationDate time.Time) (*stripe.Subscription, error)
+}
+
+// UpdateSubscriptionPrice replaces the plan price of a single-item Stripe subscription
+// Stripe prorates from prorationDate, the moment the plan change took effect
+func (si *StripeIntegration) UpdateSubscriptionPrice(ctx context.Context, subscriptionID, priceID, accountID string, prorationDate time.Time) (*stripe.Subscription, error) {
+	if !si.config.EnableStripe {
+		return nil, fmt.Errorf("Stripe integration is disabled")
+	}
+
+	getParams := &stripe.SubscriptionParams{}
+	si.setConnectedAccount(getParams, accountID)
+	subscription, err := si.client.Subscriptions.Get(subscriptionID, getParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Stripe subscription: %w", err)
+	}
+
+	params, err := si.subscriptionPriceParams(subscription, priceID, accountID, prorationDate)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := si.client.Subscriptions.Update(subscriptionID, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update Stripe subscription: %w", err)
+	}
+
+	return updated, nil
+}
+
+// subscriptionPriceParams builds the update swapping a subscription's only item to priceID
+// Subscriptions with add-on items are left for support to change by hand
+func (si *StripeIntegration) subscriptionPriceParams(subscription *stripe.Subscription, priceID, accountID string, prorationDate time.Time) (*stripe.SubscriptionParams, error) {
+	if subscription.Items == nil || len(subscription.Items.Data) != 1 {
+		count := 0
+		if subscription.Items != nil {
+			count = len(subscription.Items.Data)
+		}
+		return nil, fmt.Errorf("Stripe subscription %s has %d items, want exactly one plan item", subscription.ID, count)
+	}
+
+	params := &stripe.SubscriptionParams{
+		Items: []*stripe.SubscriptionItemsParams{
+			{ID: stripe.String(subscription.Items.Data[0].ID), Price: stripe.String(priceID)},
+		},
+		ProrationBehavior: stripe.String("create_prorations"),
+		ProrationDate:     stripe.Int64(prorationDate.Unix()),
+	}
+	si.setConnectedAccount(params, accountID)
+
+	return params, nil
+}
+
+// SyncStripePlanChanges updates the Stripe subscription of every organization that changed plan
+// from the dashboard (subscription_plan_changes) and is billed through one. Only an org's latest
+// unsynced change is pushed; it also settles the earlier ones. Changes of orgs without a Stripe
+// subscription, or to a plan without a Stripe price, are not Stripe's to sync and are skipped
+// Returns the number of subscriptions updated
+func (g *InvoiceGenerator) SyncStripePlanChanges(ctx context.Context) (int, error) {
+	if !g.config.EnableStripe {
+		return 0, nil
+	}
+
+	query := `
+		SELECT * FROM (
+			SELECT DISTINCT ON (c.organization_id)
+			       c.id, c.organization_id, os.stripe_subscription_id, pp.stripe_price_id,
+			       COALESCE(o.stripe_account_id, ''), c.effective_at, c.created_at
+			FROM subscription_plan_changes c
+			JOIN organization_subscriptions os ON os.organization_id = c.organization_id
+			JOIN pricing_plans pp ON pp.id = c.to_plan_id
+			LEFT JOIN organizations o ON o.id::text = c.organization_id
+			WHERE c.stripe_synced_at IS NULL
+			  AND os.stripe_subscription_id IS NOT NULL
+			  AND pp.stripe_price_id IS NOT NULL
+			ORDER BY c.organization_id, c.created_at DESC
+		) latest
+		ORDER BY created_at
+		LIMIT 100
+	`
+
+	rows, err := g.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending plan changes: %w", err)
+	}
+
+	type pendingChange struct {
+		changeID, orgID, subscriptionID, priceID, accountID string
+		effectiveAt, createdAt                              time.Time
+	}
+	var pending []pendingChange
+	for rows.Next() {
+		var p pendingChange
+		if err := rows.Scan(&p.changeID, &p.orgID, &p.subscriptionID, &p.priceID, &p.accountID, &p.effectiveAt, &p.createdAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan pending plan change: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating pending plan changes: %w", err)
+	}
+
+	synced := 0
+	for _, p := range pending {
+		if _, err := g.stripe.UpdateSubscriptionPrice(ctx, p.subscriptionID, p.priceID, p.accountID, p.effectiveAt); err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to update Stripe subscription %s for plan change %s: %v", p.subscriptionID, p.changeID, err)
+			continue
+		}
+
+		// Earlier unsynced changes are superseded by this one, so they must never be replayed
+		query := `
+			UPDATE subscription_plan_changes SET stripe_synced_at = NOW()
+			WHERE organization_id = $1 AND stripe_synced_at IS NULL AND created_at <= $2
+		`
+		if _, err := g.db.ExecContext(ctx, query, p.orgID, p.createdAt); err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to mark Stripe sync for plan change %s: %v", p.changeID, err)
+			continue
+		}
+		synced++
+	}
+
+	return synced, nil
+}
@@ -0,0 +1,39 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+)
+
+func TestSubscriptionPriceParams(t *testing.T) {
+	si := newConnectTestIntegration(true)
+	effectiveAt := time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)
+
+	subscription := &stripe.Subscription{
+		ID:    "sub_1",
+		Items: &stripe.SubscriptionItemList{Data: []*stripe.SubscriptionItem{{ID: "si_1"}}},
+	}
+
+	params, err := si.subscriptionPriceParams(subscription, "price_growth", "acct_123", effectiveAt)
+	if err != nil {
+		t.Fatalf("subscriptionPriceParams() error = %v", err)
+	}
+
+	if len(params.Items) != 1 || *params.Items[0].ID != "si_1" || *params.Items[0].Price != "price_growth" {
+		t.Errorf("Items = %+v, want si_1 moved to price_growth", params.Items)
+	}
+	if *params.ProrationBehavior != "create_prorations" || *params.ProrationDate != effectiveAt.Unix() {
+		t.Errorf("Proration = %s from %d, want create_prorations from %d", *params.ProrationBehavior, *params.ProrationDate, effectiveAt.Unix())
+	}
+	if params.StripeAccount == nil || *params.StripeAccount != "acct_123" {
+		t.Errorf("StripeAccount = %v, want acct_123", params.StripeAccount)
+	}
+
+	// Subscriptions with add-ons are not changed automatically
+	subscription.Items.Data = append(subscription.Items.Data, &stripe.SubscriptionItem{ID: "si_addon"})
+	if _, err := si.subscriptionPriceParams(subscription, "price_growth", "", effectiveAt); err == nil {
+		t.Error("Expected an error for a subscription with several items")
+	}
+}
@@ -0,0 +1,420 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+)
+
+// Refund errors
+var (
+	ErrInvoiceNotPaid          = errors.New("only paid invoices can be refunded")
+	ErrRefundExceedsBalance    = errors.New("refund exceeds the invoice's unrefunded balance")
+	ErrInvalidRefundAmount     = errors.New("refund amount must be positive")
+	ErrRefundReasonRequired    = errors.New("refund reason is required")
+	ErrRefundAlreadyProcessing = errors.New("refund is already being processed")
+)
+
+// Refund statuses
+const (
+	RefundStatusPending    = "pending"    // Amount reserved, not yet issued
+	RefundStatusProcessing = "processing" // Claimed by a worker, Stripe call in flight
+	RefundStatusSucceeded  = "succeeded"  // Issued (on Stripe when the invoice has a Stripe ID)
+	RefundStatusFailed     = "failed"     // Issuing failed; the reserved amount was released
+)
+
+// RefundClaimTimeout is how long a refund may stay processing before another run takes it over
+// A claim that old was left by a worker that crashed. It must stay well under the 24 hours Stripe
+// keeps idempotency keys, so a takeover of a refund Stripe already issued gets the same refund back
+const RefundClaimTimeout = time.Hour
+
+// invoice_events types recorded for refunds
+const (
+	InvoiceEventRefundRequested = "refund_requested"
+	InvoiceEventRefunded        = "refunded"
+	InvoiceEventRefundFailed    = "refund_failed"
+)
+
+// Refund is a full or partial refund of a paid invoice
+type Refund struct {
+	ID             string     `json:"id"`
+	InvoiceID      string     `json:"invoice_id"`
+	AmountCents    int64      `json:"amount_cents"`
+	Reason         string     `json:"reason"`
+	ActorUserID    string     `json:"actor_user_id,omitempty"`
+	Status         string     `json:"status"`
+	StripeRefundID string     `json:"stripe_refund_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ProcessedAt    *time.Time `json:"processed_at,omitempty"`
+}
+
+// stripeRefunder refunds invoice charges on Stripe (implemented by StripeIntegration)
+type stripeRefunder interface {
+	CreateRefund(ctx context.Context, stripeInvoiceID string, amount int64, reason, idempotencyKey string) (*stripe.Refund, error)
+}
+
+// refundIdempotencyKey is the Stripe idempotency key of a refund, the same on every attempt
+func refundIdempotencyKey(refundID string) string {
+	return "refund_" + refundID
+}
+
+// RefundMailer sends refund confirmations (implemented by EmailSender)
+type RefundMailer interface {
+	SendRefundEmail(ctx context.Context, invoice *Invoice, refund *Refund) error
+}
+
+// checkRefundable returns an error if amountCents cannot be refunded from an invoice
+func checkRefundable(status string, totalCents, refundedCents, amountCents int64) error {
+	if amountCents <= 0 {
+		return ErrInvalidRefundAmount
+	}
+	if status != InvoiceStatusPaid && status != InvoiceStatusPartiallyRefunded {
+		return ErrInvoiceNotPaid
+	}
+	if refundedCents+amountCents > totalCents {
+		return ErrRefundExceedsBalance
+	}
+	return nil
+}
+
+// refundedStatus returns the invoice status once refundedCents of totalCents has been refunded
+func refundedStatus(totalCents, refundedCents int64) string {
+	if refundedCents >= totalCents {
+		return InvoiceStatusRefunded
+	}
+	return InvoiceStatusPartiallyRefunded
+}
+
+// stripeRefundReason maps a free-text reason to one Stripe accepts
+// The full reason is kept in invoice_refunds and invoice_events
+func stripeRefundReason(reason string) string {
+	switch reason {
+	case string(stripe.RefundReasonDuplicate), string(stripe.RefundReasonFraudulent), string(stripe.RefundReasonRequestedByCustomer):
+		return reason
+	}
+	return string(stripe.RefundReasonRequestedByCustomer)
+}
+
+// RefundProcessor issues invoice refunds on Stripe and emails a confirmation
+type RefundProcessor struct {
+	generator *InvoiceGenerator
+	mailer    RefundMailer
+}
+
+// NewRefundProcessor creates a new refund processor
+func NewRefundProcessor(generator *InvoiceGenerator, mailer RefundMailer) *RefundProcessor {
+	return &RefundProcessor{
+		generator: generator,
+		mailer:    mailer,
+	}
+}
+
+// RefundInvoice refunds amountCents of a paid invoice
+// Partial refunds may be repeated until the invoice total has been refunded
+func (p *RefundProcessor) RefundInvoice(ctx context.Context, invoiceID string, amountCents int64, reason, actorUserID string) (*Refund, error) {
+	refund, err := p.generator.reserveRefund(ctx, invoiceID, amountCents, reason, actorUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.process(ctx, refund); err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// ProcessPendingRefunds issues refunds requested without Stripe access (e.g. from the dashboard),
+// and takes over refunds claimed more than RefundClaimTimeout ago by a worker that never finished
+// Returns the number of refunds issued
+func (p *RefundProcessor) ProcessPendingRefunds(ctx context.Context) (int, error) {
+	query := `
+		SELECT id, invoice_id, amount_cents, reason, COALESCE(actor_user_id, ''), status, created_at
+		FROM invoice_refunds
+		WHERE status = $1 OR (status = $2 AND claimed_at < $3)
+		ORDER BY created_at
+		LIMIT 100
+	`
+
+	staleBefore := time.Now().Add(-RefundClaimTimeout)
+	rows, err := p.generator.db.QueryContext(ctx, query, RefundStatusPending, RefundStatusProcessing, staleBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending refunds: %w", err)
+	}
+
+	var pending []*Refund
+	for rows.Next() {
+		refund := &Refund{}
+		if err := rows.Scan(&refund.ID, &refund.InvoiceID, &refund.AmountCents, &refund.Reason,
+			&refund.ActorUserID, &refund.Status, &refund.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan pending refund: %w", err)
+		}
+		pending = append(pending, refund)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating pending refunds: %w", err)
+	}
+
+	processed := 0
+	for _, refund := range pending {
+		if refund.Status == RefundStatusProcessing {
+			log.Printf("[RefundProcessor] WARNING: Refund %s for %s was claimed over %s ago and never finished, retrying it",
+				refund.ID, refund.InvoiceID, RefundClaimTimeout)
+		}
+		if err := p.process(ctx, refund); err != nil {
+			log.Printf("[RefundProcessor] ERROR: Failed to issue refund %s for %s: %v", refund.ID, refund.InvoiceID, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// process issues a reserved refund on Stripe, finalizes it, and emails the customer
+func (p *RefundProcessor) process(ctx context.Context, refund *Refund) error {
+	g := p.generator
+
+	// Step 1: Claim the refund so overlapping runs don't issue it at the same time
+	if err := g.claimRefund(ctx, refund.ID); err != nil {
+		return err
+	}
+
+	invoice, err := g.GetInvoiceByID(ctx, refund.InvoiceID)
+	if err != nil {
+		return err
+	}
+
+	// Step 2: Refund the charge on Stripe (invoices paid outside Stripe are refunded manually)
+	// The idempotency key makes a retry after a crash return the refund Stripe already issued
+	if invoice.StripeInvoiceID != "" && g.config.EnableStripe {
+		stripeRefund, err := g.stripe.CreateRefund(ctx, invoice.StripeInvoiceID, refund.AmountCents,
+			stripeRefundReason(refund.Reason), refundIdempotencyKey(refund.ID))
+		if err != nil {
+			if failErr := g.failRefund(ctx, refund, err); failErr != nil {
+				log.Printf("[RefundProcessor] ERROR: Failed to release refund %s: %v", refund.ID, failErr)
+			}
+			return err
+		}
+		refund.StripeRefundID = stripeRefund.ID
+	}
+
+	// Step 3: Mark the refund issued and move the invoice to refunded/partially_refunded
+	if err := g.completeRefund(ctx, refund); err != nil {
+		return err
+	}
+
+	// Step 4: Confirm by email; the money has moved, so a failed email does not fail the refund
+	if p.mailer != nil && invoice.CustomerEmail != "" {
+		if err := p.mailer.SendRefundEmail(ctx, invoice, refund); err != nil {
+			log.Printf("[RefundProcessor] WARNING: Failed to send refund confirmation for %s: %v", invoice.InvoiceNumber, err)
+		}
+	}
+
+	return nil
+}
+
+// reserveRefund validates a refund and reserves its amount against the invoice
+// Reserving under the row lock keeps concurrent partial refunds from exceeding the total
+func (g *InvoiceGenerator) reserveRefund(ctx context.Context, invoiceID string, amountCents int64, reason, actorUserID string) (*Refund, error) {
+	if reason == "" {
+		return nil, ErrRefundReasonRequired
+	}
+
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var totalCents, refundedCents int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, total_cents, refunded_amount_cents FROM invoices WHERE id = $1 FOR UPDATE", invoiceID,
+	).Scan(&status, &totalCents, &refundedCents)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invoice not found: %s", invoiceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	if err := checkRefundable(status, totalCents, refundedCents, amountCents); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE invoices SET refunded_amount_cents = refunded_amount_cents + $1, updated_at = $2 WHERE id = $3",
+		amountCents, now, invoiceID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to reserve refund: %w", err)
+	}
+
+	refund := &Refund{
+		InvoiceID:   invoiceID,
+		AmountCents: amountCents,
+		Reason:      reason,
+		ActorUserID: actorUserID,
+		Status:      RefundStatusPending,
+		CreatedAt:   now,
+	}
+
+	query := `
+		INSERT INTO invoice_refunds (invoice_id, amount_cents, reason, actor_user_id, status, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		RETURNING id
+	`
+	if err := tx.QueryRowContext(ctx, query, invoiceID, amountCents, reason, actorUserID, RefundStatusPending, now).Scan(&refund.ID); err != nil {
+		return nil, fmt.Errorf("failed to insert refund: %w", err)
+	}
+
+	if err := insertInvoiceEvent(ctx, tx, invoiceID, InvoiceEventRefundRequested, status, status, actorUserID, reason, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return refund, nil
+}
+
+// claimRefund moves a pending refund to processing, or takes over a processing refund whose
+// claim is older than RefundClaimTimeout
+func (g *InvoiceGenerator) claimRefund(ctx context.Context, refundID string) error {
+	now := time.Now()
+	result, err := g.db.ExecContext(ctx, `
+		UPDATE invoice_refunds SET status = $1, claimed_at = $2
+		WHERE id = $3 AND (status = $4 OR (status = $1 AND claimed_at < $5))`,
+		RefundStatusProcessing, now, refundID, RefundStatusPending, now.Add(-RefundClaimTimeout),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to claim refund: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows != 1 {
+		return ErrRefundAlreadyProcessing
+	}
+
+	return nil
+}
+
+// completeRefund records an issued refund and updates the invoice status
+func (g *InvoiceGenerator) completeRefund(ctx context.Context, refund *Refund) error {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	var totalCents, refundedCents int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, total_cents, refunded_amount_cents FROM invoices WHERE id = $1 FOR UPDATE", refund.InvoiceID,
+	).Scan(&status, &totalCents, &refundedCents)
+	if err != nil {
+		return fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	// refunded_amount_cents includes other pending refunds, so the invoice is only
+	// fully refunded once every reservation has been issued
+	var issuedCents int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(amount_cents), 0) FROM invoice_refunds WHERE invoice_id = $1 AND status = $2",
+		refund.InvoiceID, RefundStatusSucceeded,
+	).Scan(&issuedCents)
+	if err != nil {
+		return fmt.Errorf("failed to sum issued refunds: %w", err)
+	}
+
+	toStatus := refundedStatus(totalCents, issuedCents+refund.AmountCents)
+	if err := ValidateTransition(status, toStatus); err != nil {
+		recordStatusTransition(status, toStatus, false)
+		return err
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE invoice_refunds SET status = $1, stripe_refund_id = NULLIF($2, ''), processed_at = $3 WHERE id = $4",
+		RefundStatusSucceeded, refund.StripeRefundID, now, refund.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update refund: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE invoices SET status = $1, updated_at = $2 WHERE id = $3",
+		toStatus, now, refund.InvoiceID,
+	); err != nil {
+		return fmt.Errorf("failed to update invoice status: %w", err)
+	}
+
+	if err := insertInvoiceEvent(ctx, tx, refund.InvoiceID, InvoiceEventRefunded, status, toStatus, refund.ActorUserID, refund.Reason, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	refund.Status = RefundStatusSucceeded
+	refund.ProcessedAt = &now
+	if status != toStatus {
+		recordStatusTransition(status, toStatus, true)
+	}
+	return nil
+}
+
+// failRefund marks a refund failed and releases its reserved amount
+func (g *InvoiceGenerator) failRefund(ctx context.Context, refund *Refund, cause error) error {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx,
+		"SELECT status FROM invoices WHERE id = $1 FOR UPDATE", refund.InvoiceID,
+	).Scan(&status)
+	if err != nil {
+		return fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE invoice_refunds SET status = $1, error = $2, processed_at = $3 WHERE id = $4",
+		RefundStatusFailed, cause.Error(), now, refund.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update refund: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE invoices SET refunded_amount_cents = refunded_amount_cents - $1, updated_at = $2 WHERE id = $3",
+		refund.AmountCents, now, refund.InvoiceID,
+	); err != nil {
+		return fmt.Errorf("failed to release refund: %w", err)
+	}
+
+	if err := insertInvoiceEvent(ctx, tx, refund.InvoiceID, InvoiceEventRefundFailed, status, status, refund.ActorUserID, cause.Error(), now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	refund.Status = RefundStatusFailed
+	return nil
+}
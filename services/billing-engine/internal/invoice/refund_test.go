@@ -0,0 +1,160 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckRefundable(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		totalCents    int64
+		refundedCents int64
+		amountCents   int64
+		wantErr       error
+	}{
+		{"Full refund of paid invoice", InvoiceStatusPaid, 10000, 0, 10000, nil},
+		{"Partial refund", InvoiceStatusPaid, 10000, 0, 2500, nil},
+		{"Second partial refund", InvoiceStatusPartiallyRefunded, 10000, 2500, 7500, nil},
+		{"Exceeds total", InvoiceStatusPaid, 10000, 0, 10001, ErrRefundExceedsBalance},
+		{"Partials exceed total", InvoiceStatusPartiallyRefunded, 10000, 8000, 2001, ErrRefundExceedsBalance},
+		{"Zero amount", InvoiceStatusPaid, 10000, 0, 0, ErrInvalidRefundAmount},
+		{"Pending invoice", InvoiceStatusPending, 10000, 0, 100, ErrInvoiceNotPaid},
+		{"Already refunded", InvoiceStatusRefunded, 10000, 10000, 100, ErrInvoiceNotPaid},
+		{"Voided invoice", InvoiceStatusVoided, 10000, 0, 100, ErrInvoiceNotPaid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRefundable(tt.status, tt.totalCents, tt.refundedCents, tt.amountCents)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkRefundable() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefundedStatus(t *testing.T) {
+	if got := refundedStatus(10000, 2500); got != InvoiceStatusPartiallyRefunded {
+		t.Errorf("refundedStatus(partial) = %s, want %s", got, InvoiceStatusPartiallyRefunded)
+	}
+	if got := refundedStatus(10000, 10000); got != InvoiceStatusRefunded {
+		t.Errorf("refundedStatus(full) = %s, want %s", got, InvoiceStatusRefunded)
+	}
+
+	// Partial and full refunds are both valid from paid, and partial can complete
+	for _, tt := range []struct{ from, to string }{
+		{InvoiceStatusPaid, InvoiceStatusPartiallyRefunded},
+		{InvoiceStatusPaid, InvoiceStatusRefunded},
+		{InvoiceStatusPartiallyRefunded, InvoiceStatusPartiallyRefunded},
+		{InvoiceStatusPartiallyRefunded, InvoiceStatusRefunded},
+	} {
+		if !CanTransition(tt.from, tt.to) {
+			t.Errorf("CanTransition(%s, %s) = false, want true", tt.from, tt.to)
+		}
+	}
+}
+
+func TestStripeRefundReason(t *testing.T) {
+	tests := []struct {
+		reason   string
+		expected string
+	}{
+		{"duplicate", "duplicate"},
+		{"fraudulent", "fraudulent"},
+		{"Customer was double charged in March", "requested_by_customer"},
+		{"", "requested_by_customer"},
+	}
+
+	for _, tt := range tests {
+		if got := stripeRefundReason(tt.reason); got != tt.expected {
+			t.Errorf("stripeRefundReason(%q) = %s, want %s", tt.reason, got, tt.expected)
+		}
+	}
+}
+
+func TestRefundInvoice_RequiresReason(t *testing.T) {
+	processor := NewRefundProcessor(NewInvoiceGenerator(nil, nil, nil, createTestConfig()), nil)
+
+	if _, err := processor.RefundInvoice(context.Background(), "inv-123", 500, "", "user-1"); !errors.Is(err, ErrRefundReasonRequired) {
+		t.Errorf("RefundInvoice() error = %v, want %v", err, ErrRefundReasonRequired)
+	}
+}
+
+// TestRefundInvoice_ExceedsBalance tests that a refund larger than the unrefunded balance is rejected
+func TestRefundInvoice_ExceedsBalance(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	processor := NewRefundProcessor(NewInvoiceGenerator(db, nil, nil, createTestConfig()), nil)
+	ctx := context.Background()
+
+	var invoiceID string
+	var totalCents, refundedCents int64
+	err := db.QueryRowContext(ctx,
+		"SELECT id, total_cents, refunded_amount_cents FROM invoices WHERE status IN ('paid', 'partially_refunded') LIMIT 1",
+	).Scan(&invoiceID, &totalCents, &refundedCents)
+	if err != nil {
+		t.Skipf("Skipping test: no paid invoice available: %v", err)
+	}
+
+	_, err = processor.RefundInvoice(ctx, invoiceID, totalCents-refundedCents+1, "over refund", "user-1")
+	if !errors.Is(err, ErrRefundExceedsBalance) {
+		t.Errorf("RefundInvoice() error = %v, want %v", err, ErrRefundExceedsBalance)
+	}
+}
+
+// TestRefundIdempotencyKey tests that every attempt at a refund sends Stripe the same idempotency key
+func TestRefundIdempotencyKey(t *testing.T) {
+	first := refundParams("ch_123", 500, "requested_by_customer", refundIdempotencyKey("refund-1"))
+	retry := refundParams("ch_123", 500, "requested_by_customer", refundIdempotencyKey("refund-1"))
+	other := refundParams("ch_123", 500, "requested_by_customer", refundIdempotencyKey("refund-2"))
+
+	if first.IdempotencyKey == nil || *first.IdempotencyKey == "" {
+		t.Fatal("Refund params have no idempotency key")
+	}
+	if *retry.IdempotencyKey != *first.IdempotencyKey {
+		t.Errorf("Retry idempotency key = %s, want %s", *retry.IdempotencyKey, *first.IdempotencyKey)
+	}
+	if *other.IdempotencyKey == *first.IdempotencyKey {
+		t.Errorf("Two refunds share idempotency key %s", *first.IdempotencyKey)
+	}
+}
+
+// TestClaimRefund_TakesOverStaleClaims tests that a refund left processing by a crashed worker is reclaimed
+func TestClaimRefund_TakesOverStaleClaims(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	g := NewInvoiceGenerator(db, nil, nil, createTestConfig())
+	ctx := context.Background()
+
+	var invoiceID string
+	if err := db.QueryRowContext(ctx, "SELECT id FROM invoices LIMIT 1").Scan(&invoiceID); err != nil {
+		t.Skipf("Skipping test: no invoice available: %v", err)
+	}
+
+	insert := func(claimedAgo time.Duration) string {
+		var id string
+		err := db.QueryRowContext(ctx, `
+			INSERT INTO invoice_refunds (invoice_id, amount_cents, reason, status, claimed_at)
+			VALUES ($1, 1, 'claim test', $2, $3) RETURNING id`,
+			invoiceID, RefundStatusProcessing, time.Now().Add(-claimedAgo),
+		).Scan(&id)
+		if err != nil {
+			t.Fatalf("Failed to insert refund: %v", err)
+		}
+		t.Cleanup(func() { db.Exec("DELETE FROM invoice_refunds WHERE id = $1", id) })
+		return id
+	}
+
+	if err := g.claimRefund(ctx, insert(RefundClaimTimeout+time.Minute)); err != nil {
+		t.Errorf("claimRefund(stale claim) error = %v, want nil", err)
+	}
+	if err := g.claimRefund(ctx, insert(time.Minute)); !errors.Is(err, ErrRefundAlreadyProcessing) {
+		t.Errorf("claimRefund(recent claim) error = %v, want %v", err, ErrRefundAlreadyProcessing)
+	}
+}
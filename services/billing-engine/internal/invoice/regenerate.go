@@ -0,0 +1,292 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Regeneration errors
+var (
+	ErrInvoiceRefunded       = errors.New("invoice is fully refunded and cannot be corrected")
+	ErrBillingRecordNotFound = errors.New("no billing record for the invoice's billing period")
+)
+
+// invoice_events types recorded by RegenerateInvoice
+const (
+	InvoiceEventSuperseded = "superseded" // Voided and replaced by a regenerated invoice
+	InvoiceEventAdjusted   = "adjusted"   // Paid invoice corrected with a credit or debit note
+)
+
+// Adjustment types
+const (
+	AdjustmentTypeCredit = "credit" // Customer was overcharged
+	AdjustmentTypeDebit  = "debit"  // Customer was undercharged
+)
+
+// regenerationReason is recorded on invoice events and adjustments created by RegenerateInvoice
+const regenerationReason = "Usage corrected after the invoice was issued"
+
+// InvoiceAdjustment is a credit or debit note correcting a paid invoice
+type InvoiceAdjustment struct {
+	ID                  string    `json:"id"`
+	InvoiceID           string    `json:"invoice_id"`
+	Type                string    `json:"adjustment_type"`
+	AmountCents         int64     `json:"amount_cents"` // Always positive; Type gives the direction
+	OriginalTotalCents  int64     `json:"original_total_cents"`
+	CorrectedTotalCents int64     `json:"corrected_total_cents"`
+	Reason              string    `json:"reason"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// Regeneration is the outcome of RegenerateInvoice
+// Unpaid invoices get a Replacement; paid ones get an Adjustment, which is nil if the total is unchanged
+type Regeneration struct {
+	Original    *Invoice
+	Replacement *Invoice
+	Adjustment  *InvoiceAdjustment
+}
+
+// checkCorrectable returns an error if an invoice in status cannot be regenerated or adjusted
+func checkCorrectable(status string) error {
+	switch status {
+	case InvoiceStatusVoided:
+		return ErrInvoiceAlreadyVoided
+	case InvoiceStatusRefunded:
+		return ErrInvoiceRefunded
+	}
+	return nil
+}
+
+// RegenerateInvoice recomputes an invoice from its corrected billing record
+// Unpaid invoices are voided and replaced by a new draft that references the original
+// (supersedes_invoice_id). Paid invoices are never voided: the difference is recorded as a
+// credit note (overcharged) or debit note (undercharged) instead
+func (g *InvoiceGenerator) RegenerateInvoice(ctx context.Context, invoiceID string) (*Regeneration, error) {
+	original, err := g.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCorrectable(original.Status); err != nil {
+		return nil, err
+	}
+
+	corrected, err := g.recomputeInvoice(ctx, original, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := planRegeneration(original, corrected)
+	switch {
+	case result.Replacement != nil:
+		err = g.supersedeInvoice(ctx, original, result.Replacement)
+	case result.Adjustment != nil:
+		err = g.saveAdjustment(ctx, result.Adjustment)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// recomputeInvoice builds a fresh invoice for the original's organization and billing period
+// from the billing records as they are now, including the original's coupon
+func (g *InvoiceGenerator) recomputeInvoice(ctx context.Context, original *Invoice, invoiceDate time.Time) (*Invoice, error) {
+	// Invoices are generated by the run for the month their period closes in
+	closing := time.Date(original.BillingPeriodEnd.Year(), original.BillingPeriodEnd.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	records, err := g.getBillingRecordsForMonth(ctx, closing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing records: %w", err)
+	}
+	parents, err := g.getParentOrganizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parent organizations: %w", err)
+	}
+	standalone, groups := splitConsolidated(records, parents)
+
+	org, err := g.getOrganization(ctx, original.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	var corrected *Invoice
+	if group, ok := groups[original.OrganizationID]; ok {
+		names, err := g.sectionNames(ctx, org, group)
+		if err != nil {
+			return nil, err
+		}
+		corrected = g.buildConsolidatedInvoice(org, group, names, original.BillingPeriodStart, original.BillingPeriodEnd, invoiceDate)
+	} else {
+		for _, record := range standalone {
+			start, _ := record.BillingPeriod()
+			if record.OrganizationID == original.OrganizationID && start.Equal(original.BillingPeriodStart) {
+				corrected = g.buildInvoice(org, record, invoiceDate)
+				break
+			}
+		}
+	}
+	if corrected == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrBillingRecordNotFound, original.OrganizationID, original.BillingPeriodStart.Format("2006-01-02"))
+	}
+
+	// The original's coupon still applies, even if it has expired since
+	var coupon *Coupon
+	if original.CouponID != "" {
+		if coupon, err = g.getCoupon(ctx, original.CouponID); err != nil {
+			return nil, err
+		}
+	}
+	applyCoupon(corrected, coupon, corrected.DiscountCents)
+
+	return corrected, nil
+}
+
+// planRegeneration decides how a recomputed invoice corrects the original
+// Unpaid invoices get a replacement carrying the original's PO number, custom fields and
+// payment terms; paid ones get a credit or debit note for the change in total
+func planRegeneration(original, corrected *Invoice) *Regeneration {
+	result := &Regeneration{Original: original}
+
+	if checkVoidable(original.Status) == nil {
+		replacement := corrected
+		replacement.SupersedesInvoiceID = original.ID
+		replacement.SupersedesInvoiceNumber = original.InvoiceNumber
+		replacement.PONumber = original.PONumber
+		replacement.CustomFields = copyCustomFields(original.CustomFields)
+		setPaymentTerms(replacement, original.PaymentTermsDays)
+		result.Replacement = replacement
+		return result
+	}
+
+	diff := corrected.TotalCents - original.TotalCents
+	if diff == 0 {
+		return result
+	}
+
+	adjustment := &InvoiceAdjustment{
+		InvoiceID:           original.ID,
+		Type:                AdjustmentTypeDebit,
+		AmountCents:         diff,
+		OriginalTotalCents:  original.TotalCents,
+		CorrectedTotalCents: corrected.TotalCents,
+		Reason:              regenerationReason,
+	}
+	if diff < 0 {
+		adjustment.Type = AdjustmentTypeCredit
+		adjustment.AmountCents = -diff
+	}
+	result.Adjustment = adjustment
+
+	return result
+}
+
+// supersedeInvoice voids the original and saves its replacement in one transaction
+func (g *InvoiceGenerator) supersedeInvoice(ctx context.Context, original, replacement *Invoice) error {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Step 1: Lock the original and check it is still unpaid
+	var status string
+	var stripeInvoiceID sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, stripe_invoice_id FROM invoices WHERE id = $1 FOR UPDATE", original.ID,
+	).Scan(&status, &stripeInvoiceID)
+	if err != nil {
+		return fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	if err := checkVoidable(status); err != nil {
+		recordStatusTransition(status, InvoiceStatusVoided, false)
+		return err
+	}
+
+	// Step 2: Void on Stripe first, so a Stripe failure leaves the original collectible in both places
+	now := time.Now()
+	var stripeVoidedAt *time.Time
+	if stripeInvoiceID.Valid && stripeInvoiceID.String != "" && g.config.EnableStripe {
+		if _, err := g.stripe.VoidInvoice(ctx, stripeInvoiceID.String); err != nil {
+			return err
+		}
+		stripeVoidedAt = &now
+	}
+
+	// Step 3: Void the original, then insert the replacement for the same period
+	query := `
+		UPDATE invoices
+		SET status = $1, updated_at = $2, stripe_voided_at = $3
+		WHERE id = $4
+	`
+	if _, err := tx.ExecContext(ctx, query, InvoiceStatusVoided, now, stripeVoidedAt, original.ID); err != nil {
+		return fmt.Errorf("failed to void invoice: %w", err)
+	}
+
+	if err := insertInvoice(ctx, tx, replacement); err != nil {
+		return fmt.Errorf("failed to save replacement invoice: %w", err)
+	}
+
+	reason := fmt.Sprintf("%s; replaced by %s", regenerationReason, replacement.InvoiceNumber)
+	if err := insertInvoiceEvent(ctx, tx, original.ID, InvoiceEventSuperseded, status, InvoiceStatusVoided, "", reason, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	recordStatusTransition(status, InvoiceStatusVoided, true)
+	original.Status = InvoiceStatusVoided
+	return nil
+}
+
+// saveAdjustment records a credit or debit note against a paid invoice
+func (g *InvoiceGenerator) saveAdjustment(ctx context.Context, adjustment *InvoiceAdjustment) error {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the invoice so a concurrent refund or regeneration sees the adjustment
+	var status string
+	err = tx.QueryRowContext(ctx,
+		"SELECT status FROM invoices WHERE id = $1 FOR UPDATE", adjustment.InvoiceID,
+	).Scan(&status)
+	if err != nil {
+		return fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if err := checkCorrectable(status); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO invoice_adjustments (
+			invoice_id, adjustment_type, amount_cents, original_total_cents, corrected_total_cents, reason
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, query,
+		adjustment.InvoiceID, adjustment.Type, adjustment.AmountCents,
+		adjustment.OriginalTotalCents, adjustment.CorrectedTotalCents, adjustment.Reason,
+	).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert adjustment: %w", err)
+	}
+
+	reason := fmt.Sprintf("%s; %s note for %s", regenerationReason, adjustment.Type, formatPrice(adjustment.AmountCents))
+	if err := insertInvoiceEvent(ctx, tx, adjustment.InvoiceID, InvoiceEventAdjusted, status, status, "", reason, adjustment.CreatedAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
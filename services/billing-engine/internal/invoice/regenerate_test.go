@@ -0,0 +1,138 @@
+package invoice
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// correctedInvoice recomputes createTestInvoice's January bill after a late usage batch
+func correctedInvoice(t *testing.T, overageUnits, overageCents int64) *Invoice {
+	t.Helper()
+
+	gen := NewInvoiceGenerator(nil, nil, nil, createTestConfig())
+	org := &Organization{ID: "org-123", Name: "Acme Corp", Email: "billing@acme.com", DefaultPONumber: "PO-DEFAULT"}
+	record := &BillingRecord{
+		OrganizationID:     "org-123",
+		BillingMonth:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		PlanName:           "Growth",
+		BaseChargeCents:    9900,
+		OverageUnits:       overageUnits,
+		OverageChargeCents: overageCents,
+		SubtotalCents:      9900 + overageCents,
+	}
+	return gen.buildInvoice(org, record, time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC))
+}
+
+func TestCheckCorrectable(t *testing.T) {
+	tests := []struct {
+		status  string
+		wantErr error
+	}{
+		{InvoiceStatusDraft, nil},
+		{InvoiceStatusPending, nil},
+		{InvoiceStatusFailed, nil},
+		{InvoiceStatusPaid, nil},
+		{InvoiceStatusPartiallyRefunded, nil},
+		{InvoiceStatusRefunded, ErrInvoiceRefunded},
+		{InvoiceStatusVoided, ErrInvoiceAlreadyVoided},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if err := checkCorrectable(tt.status); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkCorrectable(%s) = %v, want %v", tt.status, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPlanRegeneration_Draft tests that an unpaid invoice is replaced rather than adjusted
+func TestPlanRegeneration_Draft(t *testing.T) {
+	original := createTestInvoice()
+	original.PONumber = "PO-7781" // Edited on the invoice after it was generated
+	original.CustomFields = map[string]string{"Cost Center": "CC-42"}
+	original.PaymentTermsDays = 45
+
+	corrected := correctedInvoice(t, 1500000, 600)
+	result := planRegeneration(original, corrected)
+
+	if result.Adjustment != nil {
+		t.Errorf("Adjustment = %+v, want none for an unpaid invoice", result.Adjustment)
+	}
+	replacement := result.Replacement
+	if replacement == nil {
+		t.Fatal("Expected a replacement invoice")
+	}
+
+	if replacement.SupersedesInvoiceID != original.ID || replacement.SupersedesInvoiceNumber != original.InvoiceNumber {
+		t.Errorf("Supersedes = %s (%s), want %s (%s)", replacement.SupersedesInvoiceID, replacement.SupersedesInvoiceNumber, original.ID, original.InvoiceNumber)
+	}
+	if replacement.Status != InvoiceStatusDraft {
+		t.Errorf("Status = %s, want %s", replacement.Status, InvoiceStatusDraft)
+	}
+
+	// Amounts come from the corrected record
+	if replacement.SubtotalCents != 10500 || replacement.TotalCents != 10500+840 {
+		t.Errorf("Subtotal = %d, total = %d, want 10500 and %d", replacement.SubtotalCents, replacement.TotalCents, 10500+840)
+	}
+	if len(replacement.LineItems) != 2 || replacement.LineItems[1].AmountCents != 600 {
+		t.Errorf("Line items = %+v, want the base plan and the corrected overage", replacement.LineItems)
+	}
+
+	// Per-invoice edits and terms carry over from the original
+	if replacement.PONumber != "PO-7781" || replacement.CustomFields["Cost Center"] != "CC-42" {
+		t.Errorf("PO = %q, custom fields = %v, want the original's", replacement.PONumber, replacement.CustomFields)
+	}
+	if replacement.PaymentTermsDays != 45 || !replacement.DueDate.Equal(replacement.InvoiceDate.AddDate(0, 0, 45)) {
+		t.Errorf("Terms = Net %d due %v, want Net 45 from the new invoice date", replacement.PaymentTermsDays, replacement.DueDate)
+	}
+}
+
+// TestPlanRegeneration_PaidCorrection tests that paid invoices get a credit or debit note instead of a void
+func TestPlanRegeneration_PaidCorrection(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string
+		overageCents int64
+		wantType     string // Empty for no adjustment
+		wantAmount   int64
+		wantTotal    int64 // Corrected total
+	}{
+		// The original charged 200 cents of overage (total 10908 with 8% tax)
+		{"Late events raised usage", InvoiceStatusPaid, 600, AdjustmentTypeDebit, 432, 11340},
+		{"Duplicate events removed", InvoiceStatusPaid, 0, AdjustmentTypeCredit, 216, 10692},
+		{"Partially refunded", InvoiceStatusPartiallyRefunded, 0, AdjustmentTypeCredit, 216, 10692},
+		{"No change", InvoiceStatusPaid, 200, "", 0, 10908},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := createTestInvoice()
+			original.Status = tt.status
+
+			result := planRegeneration(original, correctedInvoice(t, 500000, tt.overageCents))
+
+			if result.Replacement != nil {
+				t.Error("Expected a paid invoice to be adjusted, not replaced")
+			}
+			if tt.wantType == "" {
+				if result.Adjustment != nil {
+					t.Errorf("Adjustment = %+v, want none", result.Adjustment)
+				}
+				return
+			}
+
+			adjustment := result.Adjustment
+			if adjustment == nil {
+				t.Fatal("Expected an adjustment")
+			}
+			if adjustment.Type != tt.wantType || adjustment.AmountCents != tt.wantAmount {
+				t.Errorf("Adjustment = %s of %d, want %s of %d", adjustment.Type, adjustment.AmountCents, tt.wantType, tt.wantAmount)
+			}
+			if adjustment.InvoiceID != original.ID || adjustment.OriginalTotalCents != 10908 || adjustment.CorrectedTotalCents != tt.wantTotal {
+				t.Errorf("Adjustment = %+v, want %s's total corrected from 10908 to %d", adjustment, original.ID, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// invoiceTransitions lists the statuses each invoice status may move to
+var invoiceTransitions = map[string][]string{
+	InvoiceStatusDraft:             {InvoiceStatusPending, InvoiceStatusSendFailed, InvoiceStatusEmailFailed, InvoiceStatusVoided},
+	InvoiceStatusPending:           {InvoiceStatusPaid, InvoiceStatusFailed, InvoiceStatusSendFailed, InvoiceStatusEmailFailed, InvoiceStatusVoided},
+	InvoiceStatusSendFailed:        {InvoiceStatusPending, InvoiceStatusEmailFailed, InvoiceStatusVoided},
+	InvoiceStatusEmailFailed:       {InvoiceStatusPending, InvoiceStatusSendFailed, InvoiceStatusPaid, InvoiceStatusVoided},
+	InvoiceStatusFailed:            {InvoiceStatusPending, InvoiceStatusPaid, InvoiceStatusVoided},
+	InvoiceStatusPaid:              {InvoiceStatusRefunded, InvoiceStatusPartiallyRefunded},
+	InvoiceStatusPartiallyRefunded: {InvoiceStatusRefunded},
+	InvoiceStatusRefunded:          {},
+	InvoiceStatusVoided:            {},
+}
+
+// Status transition metrics, published at /debug/vars by the admin server
+// Keys are "from->to"
+var (
+	statusTransitions         = expvar.NewMap("invoice_status_transitions")
+	rejectedStatusTransitions = expvar.NewMap("invoice_status_transitions_rejected")
+)
+
+// InvalidTransitionError is returned when an invoice cannot move between two statuses
+type InvalidTransitionError struct {
+	InvoiceID string
+	From      string
+	To        string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	if e.InvoiceID == "" {
+		return fmt.Sprintf("invalid invoice status transition: %s -> %s (%s)", e.From, e.To, e.reason())
+	}
+	return fmt.Sprintf("invalid invoice status transition for %s: %s -> %s (%s)", e.InvoiceID, e.From, e.To, e.reason())
+}
+
+// reason explains why the transition is rejected, e.g. "paid can only move to refunded, partially_refunded"
+func (e *InvalidTransitionError) reason() string {
+	if _, ok := invoiceTransitions[e.To]; !ok {
+		return fmt.Sprintf("unknown status %q", e.To)
+	}
+	targets, ok := invoiceTransitions[e.From]
+	switch {
+	case !ok:
+		return fmt.Sprintf("unknown current status %q", e.From)
+	case len(targets) == 0:
+		return e.From + " is final"
+	default:
+		return e.From + " can only move to " + strings.Join(targets, ", ")
+	}
+}
+
+// StatusTransition describes the result of an invoice status update
+type StatusTransition struct {
+	InvoiceID string
+	From      string
+	To        string
+	Changed   bool // False when the invoice already had the target status
+	ChangedAt time.Time
+}
+
+// CanTransition reports whether an invoice may move from one status to another
+func CanTransition(from, to string) bool {
+	if _, ok := invoiceTransitions[to]; !ok {
+		return false
+	}
+
+	// Repeating the current status is a no-op (e.g. duplicate webhooks)
+	if from == to {
+		return true
+	}
+
+	for _, allowed := range invoiceTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition returns an *InvalidTransitionError if from -> to is not allowed
+func ValidateTransition(from, to string) error {
+	if !CanTransition(from, to) {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// recordStatusTransition counts an applied or rejected transition
+func recordStatusTransition(from, to string, applied bool) {
+	key := from + "->" + to
+	if applied {
+		statusTransitions.Add(key, 1)
+	} else {
+		rejectedStatusTransitions.Add(key, 1)
+	}
+}
+
+// TransitionInvoiceStatus validates and applies a status change, returning what changed
+func (g *InvoiceGenerator) TransitionInvoiceStatus(ctx context.Context, invoiceID, status string) (*StatusTransition, error) {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Step 1: Lock the invoice row and read its current status
+	var current string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM invoices WHERE id = $1 FOR UPDATE", invoiceID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invoice not found: %s", invoiceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice status: %w", err)
+	}
+
+	// Step 2: Validate the transition
+	if !CanTransition(current, status) {
+		recordStatusTransition(current, status, false)
+		return nil, &InvalidTransitionError{InvoiceID: invoiceID, From: current, To: status}
+	}
+
+	transition := &StatusTransition{
+		InvoiceID: invoiceID,
+		From:      current,
+		To:        status,
+		ChangedAt: time.Now(),
+	}
+
+	if current == status {
+		return transition, nil
+	}
+
+	// Step 3: Apply it
+	query := `
+		UPDATE invoices
+		SET status = $1, updated_at = $2
+		WHERE id = $3
+	`
+
+	if _, err := tx.ExecContext(ctx, query, status, transition.ChangedAt, invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to update invoice status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	transition.Changed = true
+	recordStatusTransition(current, status, true)
+
+	return transition, nil
+}
@@ -0,0 +1,157 @@
+package invoice
+
+import (
+	"errors"
+	"expvar"
+	"testing"
+)
+
+// expvarInt reads a counter from an expvar map (0 if unset)
+func expvarInt(v expvar.Var) int64 {
+	if counter, ok := v.(*expvar.Int); ok {
+		return counter.Value()
+	}
+	return 0
+}
+
+func TestCanTransition_Valid(t *testing.T) {
+	for from, targets := range invoiceTransitions {
+		for _, to := range targets {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				if err := ValidateTransition(from, to); err != nil {
+					t.Errorf("ValidateTransition(%s, %s) error = %v", from, to, err)
+				}
+			})
+		}
+	}
+
+	// The main lifecycle
+	lifecycle := []string{InvoiceStatusDraft, InvoiceStatusPending, InvoiceStatusPaid, InvoiceStatusRefunded}
+	for i := 1; i < len(lifecycle); i++ {
+		if !CanTransition(lifecycle[i-1], lifecycle[i]) {
+			t.Errorf("CanTransition(%s, %s) = false, want true", lifecycle[i-1], lifecycle[i])
+		}
+	}
+}
+
+func TestCanTransition_SameStatusIsNoOp(t *testing.T) {
+	for status := range invoiceTransitions {
+		if !CanTransition(status, status) {
+			t.Errorf("CanTransition(%s, %s) = false, want true", status, status)
+		}
+	}
+}
+
+func TestValidateTransition_Invalid(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+	}{
+		{InvoiceStatusVoided, InvoiceStatusPaid},
+		{InvoiceStatusVoided, InvoiceStatusDraft},
+		{InvoiceStatusPaid, InvoiceStatusDraft},
+		{InvoiceStatusPaid, InvoiceStatusVoided},
+		{InvoiceStatusRefunded, InvoiceStatusPaid},
+		{InvoiceStatusDraft, InvoiceStatusPaid},
+		{InvoiceStatusDraft, InvoiceStatusRefunded},
+		{InvoiceStatusPending, InvoiceStatusDraft},
+		{InvoiceStatusPending, "archived"}, // Unknown target
+		{"archived", InvoiceStatusPaid},    // Unknown source
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			err := ValidateTransition(tt.from, tt.to)
+
+			var transitionErr *InvalidTransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Fatalf("ValidateTransition() error = %v, want *InvalidTransitionError", err)
+			}
+			if transitionErr.From != tt.from || transitionErr.To != tt.to {
+				t.Errorf("Error transition = %s -> %s, want %s -> %s", transitionErr.From, transitionErr.To, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+// TestCanTransition_Matrix enumerates every pair of statuses, so a change to the state machine
+// must be made here as well
+func TestCanTransition_Matrix(t *testing.T) {
+	statuses := []string{
+		InvoiceStatusDraft, InvoiceStatusPending, InvoiceStatusSendFailed, InvoiceStatusEmailFailed, InvoiceStatusFailed,
+		InvoiceStatusPaid, InvoiceStatusPartiallyRefunded, InvoiceStatusRefunded, InvoiceStatusVoided,
+	}
+
+	// Legal moves to a different status; staying put is always allowed
+	legal := map[string][]string{
+		InvoiceStatusDraft:             {InvoiceStatusPending, InvoiceStatusSendFailed, InvoiceStatusEmailFailed, InvoiceStatusVoided},
+		InvoiceStatusPending:           {InvoiceStatusPaid, InvoiceStatusFailed, InvoiceStatusSendFailed, InvoiceStatusEmailFailed, InvoiceStatusVoided},
+		InvoiceStatusSendFailed:        {InvoiceStatusPending, InvoiceStatusEmailFailed, InvoiceStatusVoided},
+		InvoiceStatusEmailFailed:       {InvoiceStatusPending, InvoiceStatusSendFailed, InvoiceStatusPaid, InvoiceStatusVoided},
+		InvoiceStatusFailed:            {InvoiceStatusPending, InvoiceStatusPaid, InvoiceStatusVoided},
+		InvoiceStatusPaid:              {InvoiceStatusRefunded, InvoiceStatusPartiallyRefunded},
+		InvoiceStatusPartiallyRefunded: {InvoiceStatusRefunded},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			expected := from == to
+			for _, allowed := range legal[from] {
+				expected = expected || allowed == to
+			}
+
+			t.Run(from+" to "+to, func(t *testing.T) {
+				if got := CanTransition(from, to); got != expected {
+					t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, expected)
+				}
+			})
+		}
+	}
+}
+
+func TestInvalidTransitionError_Message(t *testing.T) {
+	tests := []struct {
+		err      *InvalidTransitionError
+		expected string
+	}{
+		{
+			&InvalidTransitionError{InvoiceID: "inv-1", From: InvoiceStatusPaid, To: InvoiceStatusDraft},
+			"invalid invoice status transition for inv-1: paid -> draft (paid can only move to refunded, partially_refunded)",
+		},
+		{
+			&InvalidTransitionError{From: InvoiceStatusVoided, To: InvoiceStatusPaid},
+			"invalid invoice status transition: voided -> paid (voided is final)",
+		},
+		{
+			&InvalidTransitionError{From: InvoiceStatusPending, To: "typo"},
+			`invalid invoice status transition: pending -> typo (unknown status "typo")`,
+		},
+		{
+			&InvalidTransitionError{From: "archived", To: InvoiceStatusPaid},
+			`invalid invoice status transition: archived -> paid (unknown current status "archived")`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.expected {
+			t.Errorf("Error() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestRecordStatusTransition(t *testing.T) {
+	key := InvoiceStatusDraft + "->" + InvoiceStatusPending
+	before := expvarInt(statusTransitions.Get(key))
+	rejectedBefore := expvarInt(rejectedStatusTransitions.Get(key))
+
+	recordStatusTransition(InvoiceStatusDraft, InvoiceStatusPending, true)
+	recordStatusTransition(InvoiceStatusDraft, InvoiceStatusPending, true)
+	recordStatusTransition(InvoiceStatusDraft, InvoiceStatusPending, false)
+
+	if got := expvarInt(statusTransitions.Get(key)) - before; got != 2 {
+		t.Errorf("Applied transitions recorded = %d, want 2", got)
+	}
+	if got := expvarInt(rejectedStatusTransitions.Get(key)) - rejectedBefore; got != 1 {
+		t.Errorf("Rejected transitions recorded = %d, want 1", got)
+	}
+}
@@ -3,14 +3,83 @@ package invoice
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
+// PDFChecksumMetadataKey is the S3 object metadata key holding the PDF's SHA-256
+const PDFChecksumMetadataKey = "sha256"
+
+// InvoiceObjectPrefix is the key prefix of every invoice PDF, which lifecycle rules are scoped to
+const InvoiceObjectPrefix = "invoices/"
+
+// InvoiceLifecycleRuleID identifies the invoice retention rule among a bucket's lifecycle rules
+const InvoiceLifecycleRuleID = "invoice-retention"
+
+// Presigned URL lifetimes; signature v4 URLs cannot outlive 7 days
+const (
+	MaxPresignExpiration     = 7 * 24 * time.Hour
+	DefaultPresignExpiration = MaxPresignExpiration
+)
+
+// ErrInvalidPresignExpiration is returned for presigned URL lifetimes outside (0, MaxPresignExpiration]
+var ErrInvalidPresignExpiration = errors.New("invalid presigned URL expiration")
+
+// presignWorkers bounds the concurrent presigns in GetPDFURLs
+const presignWorkers = 8
+
+// PresignBatchError reports the invoices GetPDFURLs could not presign, keyed by invoice ID
+type PresignBatchError struct {
+	Failed map[string]error
+}
+
+func (e *PresignBatchError) Error() string {
+	ids := make([]string, 0, len(e.Failed))
+	for id := range e.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", id, e.Failed[id]))
+	}
+	return fmt.Sprintf("failed to presign %d invoice PDFs: %s", len(ids), strings.Join(msgs, "; "))
+}
+
+// ErrPDFChecksumMismatch is returned when a downloaded PDF does not match its stored checksum
+var ErrPDFChecksumMismatch = errors.New("invoice PDF checksum mismatch")
+
+// PDFChecksum returns the hex-encoded SHA-256 of a PDF
+func PDFChecksum(pdfData []byte) string {
+	sum := sha256.Sum256(pdfData)
+	return hex.EncodeToString(sum[:])
+}
+
+// validatePresignExpiration checks a presigned URL lifetime before it reaches S3,
+// which would otherwise fail the request with an opaque signing error
+func validatePresignExpiration(expiresIn time.Duration) error {
+	if expiresIn <= 0 {
+		return fmt.Errorf("%w: %s must be positive", ErrInvalidPresignExpiration, expiresIn)
+	}
+	if expiresIn > MaxPresignExpiration {
+		return fmt.Errorf("%w: %s exceeds the %s signature v4 maximum", ErrInvalidPresignExpiration, expiresIn, MaxPresignExpiration)
+	}
+	return nil
+}
+
 // StorageManager handles uploading invoices to S3/MinIO
 type StorageManager struct {
 	client *s3.Client
@@ -25,7 +94,25 @@ func NewStorageManager(client *s3.Client, config *InvoiceConfig) *StorageManager
 	}
 }
 
+// NewS3Client creates an S3 client for config.S3Region using the default AWS credential chain
+// Setting S3Endpoint targets MinIO or other S3-compatible storage, including presigned URLs;
+// MinIO usually also needs S3UsePathStyle (http://minio:9000/bucket/key)
+func NewS3Client(ctx context.Context, config *InvoiceConfig) (*s3.Client, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(config.S3Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if config.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.S3Endpoint)
+		}
+		o.UsePathStyle = config.S3UsePathStyle
+	}), nil
+}
+
 // UploadPDF uploads invoice PDF to S3 and returns the URL
+// The PDF's checksum is stored in the object metadata and set on invoice.PDFSHA256
 func (s *StorageManager) UploadPDF(ctx context.Context, invoice *Invoice, pdfData []byte) (string, error) {
 	if !s.config.EnableS3 {
 		return "", fmt.Errorf("S3 upload is disabled")
@@ -33,6 +120,7 @@ func (s *StorageManager) UploadPDF(ctx context.Context, invoice *Invoice, pdfDat
 
 	// Generate object key
 	key := s.generateObjectKey(invoice)
+	checksum := PDFChecksum(pdfData)
 
 	// Upload to S3
 	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
@@ -41,10 +129,11 @@ func (s *StorageManager) UploadPDF(ctx context.Context, invoice *Invoice, pdfDat
 		Body:        bytes.NewReader(pdfData),
 		ContentType: aws.String("application/pdf"),
 		Metadata: map[string]string{
-			"invoice-id":      invoice.ID,
-			"invoice-number":  invoice.InvoiceNumber,
-			"organization-id": invoice.OrganizationID,
-			"upload-date":     time.Now().Format(time.RFC3339),
+			"invoice-id":           invoice.ID,
+			"invoice-number":       invoice.InvoiceNumber,
+			"organization-id":      invoice.OrganizationID,
+			"upload-date":          time.Now().Format(time.RFC3339),
+			PDFChecksumMetadataKey: checksum,
 		},
 		// Set ACL to private (default)
 		ACL: types.ObjectCannedACLPrivate,
@@ -53,21 +142,10 @@ func (s *StorageManager) UploadPDF(ctx context.Context, invoice *Invoice, pdfDat
 	if err != nil {
 		return "", fmt.Errorf("failed to upload to S3: %w", err)
 	}
+	invoice.PDFSHA256 = checksum
 
-	// Generate presigned URL (valid for 7 days)
-	presignClient := s3.NewPresignClient(s.client)
-	presignedReq, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.config.S3Bucket),
-		Key:    aws.String(key),
-	}, func(opts *s3.PresignOptions) {
-		opts.Expires = 7 * 24 * time.Hour // 7 days
-	})
-
-	if err != nil {
-		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
-	}
-
-	return presignedReq.URL, nil
+	// Generate presigned URL (valid for 7 days unless configured)
+	return s.presignGetURL(ctx, key, s.config.PresignExpiration())
 }
 
 // generateObjectKey creates S3 object key for invoice
@@ -76,8 +154,8 @@ func (s *StorageManager) generateObjectKey(invoice *Invoice) string {
 	year := invoice.BillingPeriodStart.Year()
 	month := int(invoice.BillingPeriodStart.Month())
 
-	return fmt.Sprintf("invoices/%04d/%02d/%s/%s.pdf",
-		year, month, invoice.OrganizationID, invoice.InvoiceNumber)
+	return fmt.Sprintf("%s%04d/%02d/%s/%s.pdf",
+		InvoiceObjectPrefix, year, month, invoice.OrganizationID, invoice.InvoiceNumber)
 }
 
 // DeletePDF deletes an invoice PDF from S3
@@ -101,12 +179,76 @@ func (s *StorageManager) DeletePDF(ctx context.Context, invoice *Invoice) error
 }
 
 // GetPDFURL generates a new presigned URL for an existing invoice
+// expiresIn must be positive and at most MaxPresignExpiration, else ErrInvalidPresignExpiration is returned
 func (s *StorageManager) GetPDFURL(ctx context.Context, invoice *Invoice, expiresIn time.Duration) (string, error) {
 	if !s.config.EnableS3 {
 		return "", fmt.Errorf("S3 is disabled")
 	}
 
-	key := s.generateObjectKey(invoice)
+	return s.presignGetURL(ctx, s.generateObjectKey(invoice), expiresIn)
+}
+
+// GetPDFURLs generates presigned URLs for a page of invoices, keyed by invoice ID
+// Presigning is local signing, so it runs on a small worker pool. Invoices that fail are left
+// out of the map and reported in a *PresignBatchError alongside the URLs that succeeded
+func (s *StorageManager) GetPDFURLs(ctx context.Context, invoices []*Invoice, expiresIn time.Duration) (map[string]string, error) {
+	if !s.config.EnableS3 {
+		return nil, fmt.Errorf("S3 is disabled")
+	}
+	if err := validatePresignExpiration(expiresIn); err != nil {
+		return nil, err
+	}
+
+	workers := presignWorkers
+	if len(invoices) < workers {
+		workers = len(invoices)
+	}
+
+	jobs := make(chan *Invoice)
+	urls := make(map[string]string, len(invoices))
+	failed := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for invoice := range jobs {
+				url, err := s.presignGetURL(ctx, s.generateObjectKey(invoice), expiresIn)
+
+				mu.Lock()
+				if err != nil {
+					failed[invoice.ID] = err
+				} else {
+					urls[invoice.ID] = url
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, invoice := range invoices {
+		if invoice != nil {
+			jobs <- invoice
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return urls, &PresignBatchError{Failed: failed}
+	}
+	return urls, nil
+}
+
+// presignGetURL generates a presigned GET URL for an object, valid for expiresIn
+func (s *StorageManager) presignGetURL(ctx context.Context, key string, expiresIn time.Duration) (string, error) {
+	if err := validatePresignExpiration(expiresIn); err != nil {
+		return "", err
+	}
 
 	presignClient := s3.NewPresignClient(s.client)
 	presignedReq, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
@@ -123,7 +265,9 @@ func (s *StorageManager) GetPDFURL(ctx context.Context, invoice *Invoice, expire
 	return presignedReq.URL, nil
 }
 
-// DownloadPDF downloads invoice PDF from S3
+// DownloadPDF downloads invoice PDF from S3 and verifies it against its checksum
+// The checksum saved with the invoice takes precedence over the object metadata;
+// PDFs uploaded before checksums were recorded are returned unverified
 func (s *StorageManager) DownloadPDF(ctx context.Context, invoice *Invoice) ([]byte, error) {
 	if !s.config.EnableS3 {
 		return nil, fmt.Errorf("S3 is disabled")
@@ -148,6 +292,16 @@ func (s *StorageManager) DownloadPDF(ctx context.Context, invoice *Invoice) ([]b
 		return nil, fmt.Errorf("failed to read PDF data: %w", err)
 	}
 
+	expected := invoice.PDFSHA256
+	if expected == "" {
+		expected = result.Metadata[PDFChecksumMetadataKey]
+	}
+	if expected != "" {
+		if actual := PDFChecksum(buf.Bytes()); actual != expected {
+			return nil, fmt.Errorf("%w for invoice %s: expected %s, got %s", ErrPDFChecksumMismatch, invoice.InvoiceNumber, expected, actual)
+		}
+	}
+
 	return buf.Bytes(), nil
 }
 
@@ -157,7 +311,7 @@ func (s *StorageManager) ListInvoicePDFs(ctx context.Context, organizationID str
 		return nil, fmt.Errorf("S3 is disabled")
 	}
 
-	prefix := fmt.Sprintf("invoices/%04d/%02d/%s/", year, month, organizationID)
+	prefix := fmt.Sprintf("%s%04d/%02d/%s/", InvoiceObjectPrefix, year, month, organizationID)
 
 	result, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.config.S3Bucket),
@@ -196,19 +350,28 @@ func (s *StorageManager) CheckBucketExists(ctx context.Context) error {
 }
 
 // CreateBucketIfNotExists creates the S3 bucket if it doesn't exist
+// With S3ApplyLifecycle set it also applies the invoice retention rule, to new and existing buckets
 func (s *StorageManager) CreateBucketIfNotExists(ctx context.Context) error {
 	if !s.config.EnableS3 {
 		return fmt.Errorf("S3 is disabled")
 	}
 
 	// Check if bucket exists
-	err := s.CheckBucketExists(ctx)
-	if err == nil {
-		return nil // Bucket already exists
+	if err := s.CheckBucketExists(ctx); err != nil {
+		if err := s.createBucket(ctx); err != nil {
+			return err
+		}
 	}
 
-	// Create bucket
-	_, err = s.client.CreateBucket(ctx, &s3.CreateBucketInput{
+	if s.config.S3ApplyLifecycle {
+		return s.ApplyLifecycle(ctx)
+	}
+	return nil
+}
+
+// createBucket creates the S3 bucket with versioning enabled
+func (s *StorageManager) createBucket(ctx context.Context) error {
+	_, err := s.client.CreateBucket(ctx, &s3.CreateBucketInput{
 		Bucket: aws.String(s.config.S3Bucket),
 	})
 
@@ -231,3 +394,97 @@ func (s *StorageManager) CreateBucketIfNotExists(ctx context.Context) error {
 
 	return nil
 }
+
+// InvoiceLifecycleRule returns the bucket lifecycle rule for invoice PDFs, scoped to
+// InvoiceObjectPrefix so other objects in the bucket are unaffected
+// Returns nil when neither a transition nor an expiration is configured (keep indefinitely)
+func (s *StorageManager) InvoiceLifecycleRule() *types.LifecycleRule {
+	transitionDays, expirationDays := s.config.S3TransitionDays, s.config.S3ExpirationDays
+	if transitionDays <= 0 && expirationDays <= 0 {
+		return nil
+	}
+
+	rule := &types.LifecycleRule{
+		ID:     aws.String(InvoiceLifecycleRuleID),
+		Status: types.ExpirationStatusEnabled,
+		Filter: &types.LifecycleRuleFilterMemberPrefix{Value: InvoiceObjectPrefix},
+	}
+
+	if transitionDays > 0 {
+		rule.Transitions = []types.Transition{{
+			Days:         aws.Int32(int32(transitionDays)),
+			StorageClass: types.TransitionStorageClassStandardIa,
+		}}
+	}
+
+	if expirationDays > 0 {
+		rule.Expiration = &types.LifecycleExpiration{Days: aws.Int32(int32(expirationDays))}
+		// The bucket is versioned, so expiring only adds a delete marker; remove the old version too
+		rule.NoncurrentVersionExpiration = &types.NoncurrentVersionExpiration{NoncurrentDays: aws.Int32(1)}
+	}
+
+	return rule
+}
+
+// ApplyLifecycle installs (or, when retention is unconfigured, removes) the invoice lifecycle rule
+// Other lifecycle rules on the bucket are preserved
+func (s *StorageManager) ApplyLifecycle(ctx context.Context) error {
+	if !s.config.EnableS3 {
+		return fmt.Errorf("S3 is disabled")
+	}
+
+	existing, err := s.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.config.S3Bucket),
+	})
+
+	var current []types.LifecycleRule
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		current = existing.Rules
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration":
+		// No rules yet
+	default:
+		return fmt.Errorf("failed to get bucket lifecycle: %w", err)
+	}
+
+	rules := mergeLifecycleRules(current, s.InvoiceLifecycleRule())
+
+	if len(rules) == 0 {
+		if len(current) == 0 {
+			return nil
+		}
+		if _, err := s.client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(s.config.S3Bucket),
+		}); err != nil {
+			return fmt.Errorf("failed to delete bucket lifecycle: %w", err)
+		}
+		return nil
+	}
+
+	_, err = s.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(s.config.S3Bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put bucket lifecycle: %w", err)
+	}
+
+	return nil
+}
+
+// mergeLifecycleRules replaces the invoice rule in a bucket's rules, dropping it when rule is nil
+func mergeLifecycleRules(current []types.LifecycleRule, rule *types.LifecycleRule) []types.LifecycleRule {
+	merged := make([]types.LifecycleRule, 0, len(current)+1)
+	for _, r := range current {
+		if aws.ToString(r.ID) != InvoiceLifecycleRuleID {
+			merged = append(merged, r)
+		}
+	}
+
+	if rule != nil {
+		merged = append(merged, *rule)
+	}
+	return merged
+}
@@ -3,11 +3,97 @@ package invoice
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// fakeS3Object is an object stored by fakeS3
+type fakeS3Object struct {
+	body   []byte
+	header http.Header
+}
+
+// fakeS3 serves path-style PutObject and GetObject requests from memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]*fakeS3Object
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		header := http.Header{}
+		for name, values := range r.Header {
+			if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+				header[name] = values
+			}
+		}
+		f.objects[r.URL.Path] = &fakeS3Object{body: body, header: header}
+	case http.MethodGet:
+		obj, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		for name, values := range obj.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(obj.body)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// newFakeS3Manager returns a storage manager backed by an in-memory S3 at a custom endpoint
+func newFakeS3Manager(t *testing.T) (*StorageManager, *fakeS3) {
+	fake := &fakeS3{objects: make(map[string]*fakeS3Object)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	setTestAWSCredentials(t, "test", "test")
+
+	config := createTestConfig()
+	config.EnableS3 = true
+	config.S3Bucket = "invoices"
+	config.S3Region = "us-east-1"
+	config.S3Endpoint = server.URL
+	config.S3UsePathStyle = true
+
+	client, err := NewS3Client(context.Background(), config)
+	if err != nil {
+		t.Fatalf("NewS3Client() error = %v", err)
+	}
+	return NewStorageManager(client, config), fake
+}
+
+// setTestAWSCredentials points the default AWS credential chain at static keys only
+func setTestAWSCredentials(t *testing.T, accessKey, secretKey string) {
+	t.Setenv("AWS_ACCESS_KEY_ID", accessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", secretKey)
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
 // TestNewStorageManager tests storage manager initialization
 func TestNewStorageManager(t *testing.T) {
 	config := createTestConfig()
@@ -41,9 +127,9 @@ func TestStorageManager_generateObjectKey(t *testing.T) {
 		{
 			name: "January 2026 invoice",
 			invoice: &Invoice{
-				OrganizationID: "org-123",
-				InvoiceNumber:  "INV-2026-01-00001",
-				InvoiceDate:    time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+				OrganizationID:     "org-123",
+				InvoiceNumber:      "INV-2026-01-00001",
+				BillingPeriodStart: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 			},
 			expectedPrefix: "invoices/2026/01/org-123/",
 			expectedSuffix: "INV-2026-01-00001.pdf",
@@ -51,9 +137,9 @@ func TestStorageManager_generateObjectKey(t *testing.T) {
 		{
 			name: "December 2025 invoice",
 			invoice: &Invoice{
-				OrganizationID: "org-456",
-				InvoiceNumber:  "INV-2025-12-99999",
-				InvoiceDate:    time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+				OrganizationID:     "org-456",
+				InvoiceNumber:      "INV-2025-12-99999",
+				BillingPeriodStart: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
 			},
 			expectedPrefix: "invoices/2025/12/org-456/",
 			expectedSuffix: "INV-2025-12-99999.pdf",
@@ -212,6 +298,269 @@ func TestStorageManager_DownloadPDF(t *testing.T) {
 	})
 }
 
+// TestStorageManager_DownloadPDF_Checksum tests that downloads are verified against the upload checksum
+func TestStorageManager_DownloadPDF_Checksum(t *testing.T) {
+	manager, fake := newFakeS3Manager(t)
+	ctx := context.Background()
+	uploadData := []byte("%PDF-1.4\nTest PDF content")
+
+	upload := func(t *testing.T) *Invoice {
+		invoice := createTestInvoice()
+		if _, err := manager.UploadPDF(ctx, invoice, uploadData); err != nil {
+			t.Fatalf("UploadPDF() error = %v", err)
+		}
+		return invoice
+	}
+
+	tamper := func(invoice *Invoice) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		obj := fake.objects["/invoices/"+manager.generateObjectKey(invoice)]
+		obj.body = []byte("%PDF-1.4\nTampered PDF content")
+	}
+
+	t.Run("Checksum recorded on upload", func(t *testing.T) {
+		invoice := upload(t)
+		if invoice.PDFSHA256 != PDFChecksum(uploadData) {
+			t.Errorf("PDFSHA256 = %q, want %q", invoice.PDFSHA256, PDFChecksum(uploadData))
+		}
+
+		data, err := manager.DownloadPDF(ctx, invoice)
+		if err != nil {
+			t.Fatalf("DownloadPDF() error = %v", err)
+		}
+		if !bytes.Equal(data, uploadData) {
+			t.Error("Downloaded data does not match uploaded data")
+		}
+	})
+
+	t.Run("Tampered download rejected", func(t *testing.T) {
+		invoice := upload(t)
+		tamper(invoice)
+
+		if _, err := manager.DownloadPDF(ctx, invoice); !errors.Is(err, ErrPDFChecksumMismatch) {
+			t.Errorf("DownloadPDF() error = %v, want %v", err, ErrPDFChecksumMismatch)
+		}
+	})
+
+	t.Run("Tampered download rejected by object metadata", func(t *testing.T) {
+		invoice := upload(t)
+		tamper(invoice)
+		invoice.PDFSHA256 = "" // e.g. loaded from a row written before checksums were saved
+
+		if _, err := manager.DownloadPDF(ctx, invoice); !errors.Is(err, ErrPDFChecksumMismatch) {
+			t.Errorf("DownloadPDF() error = %v, want %v", err, ErrPDFChecksumMismatch)
+		}
+	})
+
+	t.Run("Replaced object rejected by saved checksum", func(t *testing.T) {
+		invoice := upload(t)
+		saved := invoice.PDFSHA256
+
+		// Re-uploading different bytes rewrites the metadata too
+		if _, err := manager.UploadPDF(ctx, createTestInvoice(), []byte("%PDF-1.4\nOther PDF")); err != nil {
+			t.Fatalf("UploadPDF() error = %v", err)
+		}
+		invoice.PDFSHA256 = saved
+
+		if _, err := manager.DownloadPDF(ctx, invoice); !errors.Is(err, ErrPDFChecksumMismatch) {
+			t.Errorf("DownloadPDF() error = %v, want %v", err, ErrPDFChecksumMismatch)
+		}
+	})
+}
+
+// TestNewS3Client_CustomEndpoint tests that uploads and presigned URLs target a custom endpoint
+func TestNewS3Client_CustomEndpoint(t *testing.T) {
+	manager, fake := newFakeS3Manager(t)
+	ctx := context.Background()
+	invoice := createTestInvoice()
+	key := manager.generateObjectKey(invoice)
+
+	pdfURL, err := manager.UploadPDF(ctx, invoice, []byte("%PDF-1.4\nTest PDF content"))
+	if err != nil {
+		t.Fatalf("UploadPDF() error = %v", err)
+	}
+	if _, ok := fake.objects["/invoices/"+key]; !ok {
+		t.Errorf("Custom endpoint did not receive the upload, objects = %v", fake.objects)
+	}
+
+	endpoint, _ := url.Parse(manager.config.S3Endpoint)
+	presigned, err := url.Parse(pdfURL)
+	if err != nil {
+		t.Fatalf("Invalid presigned URL %q: %v", pdfURL, err)
+	}
+	if presigned.Host != endpoint.Host {
+		t.Errorf("Presigned URL host = %s, want the custom endpoint %s", presigned.Host, endpoint.Host)
+	}
+	if presigned.Path != "/invoices/"+key {
+		t.Errorf("Presigned URL path = %s, want path-style /invoices/%s", presigned.Path, key)
+	}
+
+	// The presigned URL is served by the custom endpoint
+	resp, err := http.Get(pdfURL)
+	if err != nil {
+		t.Fatalf("GET presigned URL error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET presigned URL status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+// TestStorageManager_MinIO tests an upload, presign and download round trip against MinIO
+// Run with a MinIO container:
+//
+//	docker run -d -p 9000:9000 minio/minio server /data
+//	MINIO_ENDPOINT=http://localhost:9000 go test ./internal/invoice -run MinIO
+func TestStorageManager_MinIO(t *testing.T) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("Skipping MinIO test (set MINIO_ENDPOINT, e.g. http://localhost:9000)")
+	}
+
+	accessKey, secretKey := os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY")
+	if accessKey == "" {
+		accessKey, secretKey = "minioadmin", "minioadmin"
+	}
+	setTestAWSCredentials(t, accessKey, secretKey)
+
+	config := createTestConfig()
+	config.EnableS3 = true
+	config.S3Bucket = "billing-engine-test"
+	config.S3Region = "us-east-1"
+	config.S3Endpoint = endpoint
+	config.S3UsePathStyle = true
+
+	ctx := context.Background()
+	client, err := NewS3Client(ctx, config)
+	if err != nil {
+		t.Fatalf("NewS3Client() error = %v", err)
+	}
+	manager := NewStorageManager(client, config)
+	if err := manager.CreateBucketIfNotExists(ctx); err != nil {
+		t.Fatalf("CreateBucketIfNotExists() error = %v", err)
+	}
+
+	invoice := createTestInvoice()
+	uploadData := []byte("%PDF-1.4\nMinIO round trip")
+	pdfURL, err := manager.UploadPDF(ctx, invoice, uploadData)
+	if err != nil {
+		t.Fatalf("UploadPDF() error = %v", err)
+	}
+	defer manager.DeletePDF(ctx, invoice)
+
+	resp, err := http.Get(pdfURL)
+	if err != nil {
+		t.Fatalf("GET presigned URL error = %v", err)
+	}
+	presignedData, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(presignedData, uploadData) {
+		t.Errorf("Presigned download = %d %q, want 200 with the uploaded PDF", resp.StatusCode, presignedData)
+	}
+
+	data, err := manager.DownloadPDF(ctx, invoice)
+	if err != nil {
+		t.Fatalf("DownloadPDF() error = %v", err)
+	}
+	if !bytes.Equal(data, uploadData) {
+		t.Error("Downloaded data does not match uploaded data")
+	}
+}
+
+// TestStorageManager_InvoiceLifecycleRule tests the retention rule built from config
+func TestStorageManager_InvoiceLifecycleRule(t *testing.T) {
+	config := createTestConfig()
+	manager := NewStorageManager(nil, config)
+
+	t.Run("Keep indefinitely by default", func(t *testing.T) {
+		if rule := manager.InvoiceLifecycleRule(); rule != nil {
+			t.Errorf("InvoiceLifecycleRule() = %+v, want nil", rule)
+		}
+	})
+
+	t.Run("Transition and expiration", func(t *testing.T) {
+		config.S3TransitionDays = 90
+		config.S3ExpirationDays = 3650
+		defer func() { config.S3TransitionDays, config.S3ExpirationDays = 0, 0 }()
+
+		rule := manager.InvoiceLifecycleRule()
+		if rule == nil {
+			t.Fatal("InvoiceLifecycleRule() = nil, want a rule")
+		}
+		if aws.ToString(rule.ID) != InvoiceLifecycleRuleID || rule.Status != types.ExpirationStatusEnabled {
+			t.Errorf("Rule ID/status = %s/%s, want %s/Enabled", aws.ToString(rule.ID), rule.Status, InvoiceLifecycleRuleID)
+		}
+		if len(rule.Transitions) != 1 || aws.ToInt32(rule.Transitions[0].Days) != 90 ||
+			rule.Transitions[0].StorageClass != types.TransitionStorageClassStandardIa {
+			t.Errorf("Transitions = %+v, want Standard-IA after 90 days", rule.Transitions)
+		}
+		if rule.Expiration == nil || aws.ToInt32(rule.Expiration.Days) != 3650 {
+			t.Errorf("Expiration = %+v, want 3650 days", rule.Expiration)
+		}
+		if rule.NoncurrentVersionExpiration == nil {
+			t.Error("Expected noncurrent versions to expire in the versioned bucket")
+		}
+	})
+
+	t.Run("Transition only", func(t *testing.T) {
+		config.S3TransitionDays = 30
+		defer func() { config.S3TransitionDays = 0 }()
+
+		rule := manager.InvoiceLifecycleRule()
+		if rule == nil || rule.Expiration != nil || rule.NoncurrentVersionExpiration != nil {
+			t.Errorf("InvoiceLifecycleRule() = %+v, want a transition without expiration", rule)
+		}
+	})
+}
+
+// TestStorageManager_LifecyclePrefix tests that the lifecycle rule only targets invoice objects
+func TestStorageManager_LifecyclePrefix(t *testing.T) {
+	config := createTestConfig()
+	config.S3ExpirationDays = 3650
+	manager := NewStorageManager(nil, config)
+
+	filter, ok := manager.InvoiceLifecycleRule().Filter.(*types.LifecycleRuleFilterMemberPrefix)
+	if !ok {
+		t.Fatalf("Filter = %T, want a prefix filter", manager.InvoiceLifecycleRule().Filter)
+	}
+	if filter.Value != InvoiceObjectPrefix {
+		t.Errorf("Filter prefix = %q, want %q", filter.Value, InvoiceObjectPrefix)
+	}
+
+	// Every invoice key falls under the prefix...
+	invoice := createTestInvoice()
+	invoice.InvoiceNumber = "INV-2026-01-00001"
+	if key := manager.generateObjectKey(invoice); !strings.HasPrefix(key, filter.Value) {
+		t.Errorf("Invoice key %q is outside the lifecycle prefix %q", key, filter.Value)
+	}
+
+	// ...and other objects in the bucket do not
+	for _, key := range []string{"exports/2026/01/usage.csv", "invoices-archive/2020.zip", "logos/company.png"} {
+		if strings.HasPrefix(key, filter.Value) {
+			t.Errorf("Object %q would be affected by the invoice lifecycle rule", key)
+		}
+	}
+}
+
+// TestMergeLifecycleRules tests that applying the invoice rule preserves other bucket rules
+func TestMergeLifecycleRules(t *testing.T) {
+	exports := types.LifecycleRule{ID: aws.String("expire-exports"), Status: types.ExpirationStatusEnabled}
+	oldInvoice := types.LifecycleRule{ID: aws.String(InvoiceLifecycleRuleID), Status: types.ExpirationStatusEnabled}
+	newInvoice := &types.LifecycleRule{ID: aws.String(InvoiceLifecycleRuleID), Status: types.ExpirationStatusDisabled}
+
+	merged := mergeLifecycleRules([]types.LifecycleRule{exports, oldInvoice}, newInvoice)
+	if len(merged) != 2 || aws.ToString(merged[0].ID) != "expire-exports" || merged[1].Status != types.ExpirationStatusDisabled {
+		t.Errorf("Merged rules = %+v, want the exports rule and the replaced invoice rule", merged)
+	}
+
+	// Unconfigured retention removes only the invoice rule
+	merged = mergeLifecycleRules([]types.LifecycleRule{exports, oldInvoice}, nil)
+	if len(merged) != 1 || aws.ToString(merged[0].ID) != "expire-exports" {
+		t.Errorf("Merged rules = %+v, want only the exports rule", merged)
+	}
+}
+
 // TestStorageManager_DeletePDF tests deleting PDF
 func TestStorageManager_DeletePDF(t *testing.T) {
 	config := createTestConfig()
@@ -252,9 +601,8 @@ func TestStorageManager_ListInvoicePDFs(t *testing.T) {
 
 	t.Run("List PDFs for organization", func(t *testing.T) {
 		orgID := "org-123"
-		month := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 
-		keys, err := manager.ListInvoicePDFs(ctx, orgID, month)
+		keys, err := manager.ListInvoicePDFs(ctx, orgID, 2026, 1)
 		if err != nil {
 			t.Fatalf("Failed to list PDFs: %v", err)
 		}
@@ -287,14 +635,9 @@ func TestStorageManager_CheckBucketExists(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("Check existing bucket", func(t *testing.T) {
-		exists, err := manager.CheckBucketExists(ctx)
-		if err != nil {
+		if err := manager.CheckBucketExists(ctx); err != nil {
 			t.Fatalf("Failed to check bucket: %v", err)
 		}
-
-		if !exists {
-			t.Error("Expected bucket to exist")
-		}
 	})
 }
 
@@ -316,12 +659,8 @@ func TestStorageManager_CreateBucketIfNotExists(t *testing.T) {
 		}
 
 		// Verify bucket exists after creation
-		exists, err := manager.CheckBucketExists(ctx)
-		if err != nil {
-			t.Fatalf("Failed to check bucket: %v", err)
-		}
-		if !exists {
-			t.Error("Expected bucket to exist after creation")
+		if err := manager.CheckBucketExists(ctx); err != nil {
+			t.Fatalf("Expected bucket to exist after creation: %v", err)
 		}
 	})
 }
@@ -335,21 +674,21 @@ func TestStorageManager_ObjectKeyFormat(t *testing.T) {
 		name           string
 		orgID          string
 		invoiceNumber  string
-		invoiceDate    time.Time
+		billingPeriod  time.Time
 		expectedFormat string
 	}{
 		{
 			name:           "Standard format",
 			orgID:          "org-123",
 			invoiceNumber:  "INV-2026-01-00001",
-			invoiceDate:    time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+			billingPeriod:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 			expectedFormat: "invoices/2026/01/org-123/INV-2026-01-00001.pdf",
 		},
 		{
 			name:           "Different month",
 			orgID:          "org-456",
 			invoiceNumber:  "INV-2026-12-12345",
-			invoiceDate:    time.Date(2026, 12, 25, 0, 0, 0, 0, time.UTC),
+			billingPeriod:  time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
 			expectedFormat: "invoices/2026/12/org-456/INV-2026-12-12345.pdf",
 		},
 	}
@@ -357,9 +696,9 @@ func TestStorageManager_ObjectKeyFormat(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			invoice := &Invoice{
-				OrganizationID: tt.orgID,
-				InvoiceNumber:  tt.invoiceNumber,
-				InvoiceDate:    tt.invoiceDate,
+				OrganizationID:     tt.orgID,
+				InvoiceNumber:      tt.invoiceNumber,
+				BillingPeriodStart: tt.billingPeriod,
 			}
 
 			key := manager.generateObjectKey(invoice)
@@ -378,12 +717,12 @@ func TestStorageManager_ObjectKeyFormat(t *testing.T) {
 				t.Errorf("Expected first part to be 'invoices', got %s", parts[0])
 			}
 
-			if parts[1] != tt.invoiceDate.Format("2006") {
-				t.Errorf("Expected year %s, got %s", tt.invoiceDate.Format("2006"), parts[1])
+			if parts[1] != tt.billingPeriod.Format("2006") {
+				t.Errorf("Expected year %s, got %s", tt.billingPeriod.Format("2006"), parts[1])
 			}
 
-			if parts[2] != tt.invoiceDate.Format("01") {
-				t.Errorf("Expected month %s, got %s", tt.invoiceDate.Format("01"), parts[2])
+			if parts[2] != tt.billingPeriod.Format("01") {
+				t.Errorf("Expected month %s, got %s", tt.billingPeriod.Format("01"), parts[2])
 			}
 
 			if parts[3] != tt.orgID {
@@ -487,8 +826,9 @@ func TestStorageManager_Concurrency(t *testing.T) {
 
 // TestStorageManager_PresignedURLExpiration tests URL expiration handling
 func TestStorageManager_PresignedURLExpiration(t *testing.T) {
-	config := createTestConfig()
-	manager := NewStorageManager(nil, config)
+	manager, _ := newFakeS3Manager(t)
+	ctx := context.Background()
+	invoice := createTestInvoice()
 
 	tests := []struct {
 		name       string
@@ -507,9 +847,14 @@ func TestStorageManager_PresignedURLExpiration(t *testing.T) {
 		},
 		{
 			name:       "Max expiration (7 days)",
-			expiration: 7 * 24 * time.Hour,
+			expiration: MaxPresignExpiration,
 			valid:      true,
 		},
+		{
+			name:       "Over max expiration",
+			expiration: MaxPresignExpiration + time.Second,
+			valid:      false,
+		},
 		{
 			name:       "Zero expiration",
 			expiration: 0,
@@ -524,17 +869,94 @@ func TestStorageManager_PresignedURLExpiration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Just verify expiration value validation
-			if tt.expiration <= 0 && tt.valid {
-				t.Error("Invalid expiration marked as valid")
+			pdfURL, err := manager.GetPDFURL(ctx, invoice, tt.expiration)
+
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidPresignExpiration) {
+					t.Errorf("GetPDFURL(%s) error = %v, want %v", tt.expiration, err, ErrInvalidPresignExpiration)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("GetPDFURL(%s) error = %v", tt.expiration, err)
 			}
-			if tt.expiration > 0 && !tt.valid {
-				t.Error("Valid expiration marked as invalid")
+			presigned, _ := url.Parse(pdfURL)
+			if got, want := presigned.Query().Get("X-Amz-Expires"), fmt.Sprint(int(tt.expiration.Seconds())); got != want {
+				t.Errorf("X-Amz-Expires = %s, want %s", got, want)
 			}
 		})
 	}
 }
 
+// TestStorageManager_GetPDFURLs tests presigning a page of invoices concurrently
+func TestStorageManager_GetPDFURLs(t *testing.T) {
+	manager, _ := newFakeS3Manager(t)
+	ctx := context.Background()
+
+	invoices := make([]*Invoice, 50)
+	for i := range invoices {
+		invoices[i] = createTestInvoice()
+		invoices[i].ID = fmt.Sprintf("inv-%03d", i)
+		invoices[i].InvoiceNumber = fmt.Sprintf("INV-2026-01-%05d", i)
+	}
+
+	urls, err := manager.GetPDFURLs(ctx, invoices, time.Hour)
+	if err != nil {
+		t.Fatalf("GetPDFURLs() error = %v", err)
+	}
+	if len(urls) != len(invoices) {
+		t.Fatalf("GetPDFURLs() returned %d URLs, want %d", len(urls), len(invoices))
+	}
+
+	for _, invoice := range invoices {
+		presigned, err := url.Parse(urls[invoice.ID])
+		if err != nil {
+			t.Fatalf("Invalid URL for %s: %v", invoice.ID, err)
+		}
+		if want := "/invoices/" + manager.generateObjectKey(invoice); presigned.Path != want {
+			t.Errorf("URL path for %s = %s, want %s", invoice.ID, presigned.Path, want)
+		}
+	}
+
+	t.Run("Empty page", func(t *testing.T) {
+		if urls, err := manager.GetPDFURLs(ctx, nil, time.Hour); err != nil || len(urls) != 0 {
+			t.Errorf("GetPDFURLs(nil) = %v, %v, want an empty map", urls, err)
+		}
+	})
+
+	t.Run("Invalid expiration", func(t *testing.T) {
+		if _, err := manager.GetPDFURLs(ctx, invoices, 8*24*time.Hour); !errors.Is(err, ErrInvalidPresignExpiration) {
+			t.Errorf("GetPDFURLs() error = %v, want %v", err, ErrInvalidPresignExpiration)
+		}
+	})
+}
+
+func TestPresignBatchError(t *testing.T) {
+	err := &PresignBatchError{Failed: map[string]error{
+		"inv-2": errors.New("signing failed"),
+		"inv-1": errors.New("signing failed"),
+	}}
+
+	want := "failed to presign 2 invoice PDFs: inv-1: signing failed; inv-2: signing failed"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+// TestInvoiceConfig_PresignExpiration tests the upload link lifetime default
+func TestInvoiceConfig_PresignExpiration(t *testing.T) {
+	config := createTestConfig()
+	if got := config.PresignExpiration(); got != DefaultPresignExpiration {
+		t.Errorf("PresignExpiration() = %s, want the %s default when unset", got, DefaultPresignExpiration)
+	}
+
+	config.PDFLinkExpiration = 24 * time.Hour
+	if got := config.PresignExpiration(); got != 24*time.Hour {
+		t.Errorf("PresignExpiration() = %s, want 24h", got)
+	}
+}
+
 // Benchmark tests
 func BenchmarkStorageManager_generateObjectKey(b *testing.B) {
 	config := createTestConfig()
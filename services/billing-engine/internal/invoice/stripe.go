@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"time"
+	"log"
+	"math"
 
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/client"
@@ -14,37 +15,137 @@ import (
 type StripeIntegration struct {
 	client *client.API
 	config *InvoiceConfig
+
+	// Customer lookups: memory, then stripe_customers (if set), then Stripe search
+	customerAPI   stripeCustomerAPI
+	customerStore customerStore
+	customers     *customerCache
 }
 
 // NewStripeIntegration creates a new Stripe integration
 func NewStripeIntegration(stripeClient *client.API, config *InvoiceConfig) *StripeIntegration {
 	return &StripeIntegration{
-		client: stripeClient,
-		config: config,
+		client:      stripeClient,
+		config:      config,
+		customerAPI: &clientCustomerAPI{client: stripeClient},
+		customers:   &customerCache{ids: make(map[string]string)},
 	}
 }
 
+// stripeOperations is the part of StripeIntegration used directly by InvoiceGenerator
+type stripeOperations interface {
+	stripeVoider
+	stripeRefunder
+	stripeSubscriptionUpdater
+}
+
 // CreateOrGetCustomer creates a Stripe customer or retrieves existing one
+// Known customer IDs are reused without a (rate-limited) customer search; a cached
+// customer that was deleted in Stripe is replaced with a new one
 func (si *StripeIntegration) CreateOrGetCustomer(ctx context.Context, org *Organization) (*stripe.Customer, error) {
 	if !si.config.EnableStripe {
 		return nil, fmt.Errorf("Stripe integration is disabled")
 	}
 
-	// Search for existing customer by organization ID
+	if customerID := si.cachedCustomerID(ctx, org); customerID != "" {
+		customer, err := si.customerAPI.get(customerID, si.customerGetParams(org))
+		if !isCustomerGone(customer, err) {
+			if err != nil {
+				return nil, fmt.Errorf("failed to get Stripe customer: %w", err)
+			}
+			return customer, nil
+		}
+		log.Printf("[StripeIntegration] WARNING: Customer %s for %s was deleted in Stripe, creating a new one", customerID, org.ID)
+	} else {
+		// Search for existing customer by organization ID
+		customer, err := si.customerAPI.search(si.customerSearchParams(org))
+		if err != nil {
+			return nil, fmt.Errorf("failed to search Stripe customers: %w", err)
+		}
+		if customer != nil {
+			// Customer already exists
+			si.rememberCustomer(ctx, org, customer.ID)
+			return customer, nil
+		}
+	}
+
+	// Create new customer
+	customer, err := si.customerAPI.create(si.customerParams(org))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Stripe customer: %w", err)
+	}
+	si.rememberCustomer(ctx, org, customer.ID)
+
+	return customer, nil
+}
+
+// CreateInvoice creates a Stripe invoice from our invoice data
+func (si *StripeIntegration) CreateInvoice(ctx context.Context, invoice *Invoice, customer *stripe.Customer) (*stripe.Invoice, error) {
+	if !si.config.EnableStripe {
+		return nil, fmt.Errorf("Stripe integration is disabled")
+	}
+
+	// Add line items
+	for _, item := range invoice.LineItems {
+		_, err := si.client.InvoiceItems.New(si.invoiceItemParams(invoice, item, customer.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create invoice item: %w", err)
+		}
+	}
+
+	// Discounts (coupons and billing record discounts) as a negative item
+	if invoice.DiscountCents > 0 {
+		_, err := si.client.InvoiceItems.New(si.invoiceItemParams(invoice, discountLineItem(invoice), customer.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discount item: %w", err)
+		}
+	}
+
+	// Create the invoice
+	stripeInvoice, err := si.client.Invoices.New(si.invoiceParams(invoice, customer.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Stripe invoice: %w", err)
+	}
+
+	return stripeInvoice, nil
+}
+
+// stripeAccountSetter is implemented by all Stripe params types (Stripe-Account header)
+type stripeAccountSetter interface {
+	SetStripeAccount(val string)
+}
+
+// setConnectedAccount routes a request to the org's connected account when Connect is enabled
+// An empty account ID uses the platform account
+func (si *StripeIntegration) setConnectedAccount(params stripeAccountSetter, accountID string) {
+	if si.config.EnableStripeConnect && accountID != "" {
+		params.SetStripeAccount(accountID)
+	}
+}
+
+// customerSearchParams builds the customer lookup for an organization
+func (si *StripeIntegration) customerSearchParams(org *Organization) *stripe.CustomerSearchParams {
 	params := &stripe.CustomerSearchParams{
 		SearchParams: stripe.SearchParams{
 			Query: fmt.Sprintf("metadata['organization_id']:'%s'", org.ID),
 		},
 	}
+	si.setConnectedAccount(params, org.StripeAccountID)
 
-	result := si.client.Customers.Search(params)
-	if result.Next() {
-		// Customer already exists
-		return result.Customer(), nil
-	}
+	return params
+}
 
-	// Create new customer
-	customerParams := &stripe.CustomerParams{
+// customerGetParams builds the retrieval of a known customer for an organization
+func (si *StripeIntegration) customerGetParams(org *Organization) *stripe.CustomerParams {
+	params := &stripe.CustomerParams{}
+	si.setConnectedAccount(params, org.StripeAccountID)
+
+	return params
+}
+
+// customerParams builds a new Stripe customer for an organization
+func (si *StripeIntegration) customerParams(org *Organization) *stripe.CustomerParams {
+	params := &stripe.CustomerParams{
 		Email:       stripe.String(org.Email),
 		Name:        stripe.String(org.Name),
 		Description: stripe.String(fmt.Sprintf("Organization: %s", org.Name)),
@@ -54,28 +155,19 @@ func (si *StripeIntegration) CreateOrGetCustomer(ctx context.Context, org *Organ
 	}
 
 	if org.BillingAddress != "" {
-		customerParams.Address = &stripe.AddressParams{
+		params.Address = &stripe.AddressParams{
 			Line1: stripe.String(org.BillingAddress),
 		}
 	}
+	si.setConnectedAccount(params, org.StripeAccountID)
 
-	customer, err := si.client.Customers.New(customerParams)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Stripe customer: %w", err)
-	}
-
-	return customer, nil
+	return params
 }
 
-// CreateInvoice creates a Stripe invoice from our invoice data
-func (si *StripeIntegration) CreateInvoice(ctx context.Context, invoice *Invoice, customer *stripe.Customer) (*stripe.Invoice, error) {
-	if !si.config.EnableStripe {
-		return nil, fmt.Errorf("Stripe integration is disabled")
-	}
-
-	// Create invoice
-	invoiceParams := &stripe.InvoiceParams{
-		Customer:    stripe.String(customer.ID),
+// invoiceParams builds a Stripe invoice from our invoice data
+func (si *StripeIntegration) invoiceParams(invoice *Invoice, customerID string) *stripe.InvoiceParams {
+	params := &stripe.InvoiceParams{
+		Customer:    stripe.String(customerID),
 		Description: stripe.String(fmt.Sprintf("Invoice for %s", invoice.BillingPeriodStart.Format("January 2006"))),
 		DueDate:     stripe.Int64(invoice.DueDate.Unix()),
 		Metadata: map[string]string{
@@ -87,33 +179,78 @@ func (si *StripeIntegration) CreateInvoice(ctx context.Context, invoice *Invoice
 		AutoAdvance: stripe.Bool(false), // Don't auto-finalize
 	}
 
-	// Add line items
-	for _, item := range invoice.LineItems {
-		invoiceItemParams := &stripe.InvoiceItemParams{
-			Customer:    stripe.String(customer.ID),
-			Invoice:     nil, // Will attach to invoice automatically
-			Description: stripe.String(item.Description),
-			Amount:      stripe.Int64(item.AmountCents),
-			Currency:    stripe.String("usd"),
-			Quantity:    stripe.Int64(1),
-			Metadata: map[string]string{
-				"item_type": item.ItemType,
-			},
+	// Custom fields never replace the keys webhooks use to find our invoice
+	for key, value := range invoice.CustomFields {
+		if _, reserved := params.Metadata[key]; !reserved {
+			params.Metadata[key] = value
 		}
+	}
+	if invoice.PONumber != "" {
+		params.Metadata["po_number"] = invoice.PONumber
+	}
+	si.setConnectedAccount(params, invoice.StripeAccountID)
 
-		_, err := si.client.InvoiceItems.New(invoiceItemParams)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create invoice item: %w", err)
-		}
+	return params
+}
+
+// invoiceItemParams builds a pending invoice item for one line item
+// Usage items carry their real quantity and unit price so the Stripe invoice matches our PDF
+func (si *StripeIntegration) invoiceItemParams(invoice *Invoice, item LineItem, customerID string) *stripe.InvoiceItemParams {
+	params := &stripe.InvoiceItemParams{
+		Customer:    stripe.String(customerID),
+		Invoice:     nil, // Will attach to invoice automatically
+		Description: stripe.String(item.Description),
+		Currency:    stripe.String("usd"),
+		Metadata: map[string]string{
+			"item_type": item.ItemType,
+		},
 	}
 
-	// Create the invoice
-	stripeInvoice, err := si.client.Invoices.New(invoiceParams)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Stripe invoice: %w", err)
+	// Stripe has no sections, so consolidated items name their child organization
+	if item.ChildOrganizationID != "" {
+		params.Description = stripe.String(item.ChildOrganizationName + ": " + item.Description)
+		params.Metadata["child_organization_id"] = item.ChildOrganizationID
 	}
 
-	return stripeInvoice, nil
+	switch {
+	case item.Quantity <= 1:
+		// Flat charges (base plan, true-up, discounts)
+		params.Amount = stripe.Int64(item.AmountCents)
+	case item.AmountCents%item.Quantity == 0:
+		params.Quantity = stripe.Int64(item.Quantity)
+		params.UnitAmount = stripe.Int64(item.AmountCents / item.Quantity)
+	default:
+		// Sub-cent rates; Stripe rounds quantity x unit_amount_decimal back to AmountCents
+		params.Quantity = stripe.Int64(item.Quantity)
+		params.UnitAmountDecimal = stripe.Float64(stripeUnitAmountDecimal(item.AmountCents, item.Quantity))
+	}
+	si.setConnectedAccount(params, invoice.StripeAccountID)
+
+	return params
+}
+
+// stripeMaxUnitAmountDecimals is the precision Stripe accepts for unit_amount_decimal
+const stripeMaxUnitAmountDecimals = 12
+
+// stripeUnitAmountDecimal is the exact per-unit price in cents, rounded to Stripe's precision
+// Unlike LineItem.UnitPriceCents (truncated to whole cents) it reproduces the amount
+func stripeUnitAmountDecimal(amountCents, quantity int64) float64 {
+	scale := math.Pow10(stripeMaxUnitAmountDecimals)
+	return math.Round(float64(amountCents)/float64(quantity)*scale) / scale
+}
+
+// discountLineItem is the invoice's discount as a credit line for Stripe
+func discountLineItem(invoice *Invoice) LineItem {
+	description := "Discount"
+	if invoice.CouponID != "" && invoice.Notes != "" {
+		description = invoice.Notes
+	}
+
+	return LineItem{
+		Description: description,
+		AmountCents: -invoice.DiscountCents,
+		ItemType:    "discount",
+	}
 }
 
 // FinalizeInvoice finalizes a Stripe invoice (makes it ready for payment)
@@ -193,7 +330,8 @@ func (si *StripeIntegration) SendInvoice(ctx context.Context, stripeInvoiceID st
 }
 
 // CreateRefund creates a refund for a paid invoice
-func (si *StripeIntegration) CreateRefund(ctx context.Context, stripeInvoiceID string, amount int64, reason string) (*stripe.Refund, error) {
+// Stripe returns the original refund for a repeated idempotencyKey (for 24 hours) instead of refunding again
+func (si *StripeIntegration) CreateRefund(ctx context.Context, stripeInvoiceID string, amount int64, reason, idempotencyKey string) (*stripe.Refund, error) {
 	if !si.config.EnableStripe {
 		return nil, fmt.Errorf("Stripe integration is disabled")
 	}
@@ -208,13 +346,7 @@ func (si *StripeIntegration) CreateRefund(ctx context.Context, stripeInvoiceID s
 		return nil, fmt.Errorf("invoice has no associated charge")
 	}
 
-	params := &stripe.RefundParams{
-		Charge: stripe.String(invoice.Charge.ID),
-		Amount: stripe.Int64(amount),
-		Reason: stripe.String(reason),
-	}
-
-	refund, err := si.client.Refunds.New(params)
+	refund, err := si.client.Refunds.New(refundParams(invoice.Charge.ID, amount, reason, idempotencyKey))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create refund: %w", err)
 	}
@@ -222,6 +354,18 @@ func (si *StripeIntegration) CreateRefund(ctx context.Context, stripeInvoiceID s
 	return refund, nil
 }
 
+// refundParams builds a refund of amount cents of a charge
+func refundParams(chargeID string, amount int64, reason, idempotencyKey string) *stripe.RefundParams {
+	params := &stripe.RefundParams{
+		Charge: stripe.String(chargeID),
+		Amount: stripe.Int64(amount),
+		Reason: stripe.String(reason),
+	}
+	params.SetIdempotencyKey(idempotencyKey)
+
+	return params
+}
+
 // HandleWebhook processes Stripe webhook events
 func (si *StripeIntegration) HandleWebhook(ctx context.Context, event *stripe.Event) error {
 	if !si.config.EnableStripe {
@@ -0,0 +1,59 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+)
+
+func newConnectTestIntegration(enableConnect bool) *StripeIntegration {
+	return NewStripeIntegration(nil, &InvoiceConfig{
+		EnableStripe:        true,
+		EnableStripeConnect: enableConnect,
+	})
+}
+
+func TestStripeParams_ConnectedAccount(t *testing.T) {
+	tests := []struct {
+		name          string
+		enableConnect bool
+		accountID     string
+		expected      string
+	}{
+		{"Connect enabled with account", true, "acct_123", "acct_123"},
+		{"Connect enabled without account", true, "", ""},
+		{"Connect disabled", false, "acct_123", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			si := newConnectTestIntegration(tt.enableConnect)
+			org := &Organization{ID: "org-1", Name: "Acme", Email: "billing@acme.test", StripeAccountID: tt.accountID}
+			inv := &Invoice{
+				ID:                 "inv-1",
+				OrganizationID:     "org-1",
+				BillingPeriodStart: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+				DueDate:            time.Date(2026, 4, 30, 0, 0, 0, 0, time.UTC),
+				StripeAccountID:    tt.accountID,
+			}
+
+			got := map[string]*string{
+				"customer search": si.customerSearchParams(org).StripeAccount,
+				"customer":        si.customerParams(org).StripeAccount,
+				"invoice":         si.invoiceParams(inv, "cus_1").StripeAccount,
+				"invoice item":    si.invoiceItemParams(inv, LineItem{Description: "Base", AmountCents: 100}, "cus_1").StripeAccount,
+			}
+
+			for params, account := range got {
+				if tt.expected == "" {
+					if account != nil {
+						t.Errorf("%s StripeAccount = %q, want unset", params, *account)
+					}
+					continue
+				}
+				if account == nil || *account != tt.expected {
+					t.Errorf("%s StripeAccount = %v, want %q", params, account, tt.expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/client"
+)
+
+// stripeCustomerAPI is the Stripe customer API used by CreateOrGetCustomer
+type stripeCustomerAPI interface {
+	search(params *stripe.CustomerSearchParams) (*stripe.Customer, error) // nil if no match
+	get(id string, params *stripe.CustomerParams) (*stripe.Customer, error)
+	create(params *stripe.CustomerParams) (*stripe.Customer, error)
+}
+
+// clientCustomerAPI adapts the stripe-go client to stripeCustomerAPI
+type clientCustomerAPI struct {
+	client *client.API
+}
+
+func (c *clientCustomerAPI) search(params *stripe.CustomerSearchParams) (*stripe.Customer, error) {
+	result := c.client.Customers.Search(params)
+	if result.Next() {
+		return result.Customer(), nil
+	}
+	return nil, result.Err()
+}
+
+func (c *clientCustomerAPI) get(id string, params *stripe.CustomerParams) (*stripe.Customer, error) {
+	return c.client.Customers.Get(id, params)
+}
+
+func (c *clientCustomerAPI) create(params *stripe.CustomerParams) (*stripe.Customer, error) {
+	return c.client.Customers.New(params)
+}
+
+// customerStore persists organization → Stripe customer mappings (implemented by dbCustomerStore)
+// accountID is the connected account the customer lives on ("" for the platform account)
+type customerStore interface {
+	getCustomerID(ctx context.Context, orgID, accountID string) (string, error) // "" if none
+	saveCustomerID(ctx context.Context, orgID, accountID, customerID string) error
+}
+
+// dbCustomerStore reads and writes the stripe_customers table
+type dbCustomerStore struct {
+	db *sql.DB
+}
+
+func (s *dbCustomerStore) getCustomerID(ctx context.Context, orgID, accountID string) (string, error) {
+	var customerID string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT stripe_customer_id FROM stripe_customers WHERE organization_id = $1 AND stripe_account_id = $2",
+		orgID, accountID,
+	).Scan(&customerID)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get Stripe customer mapping: %w", err)
+	}
+
+	return customerID, nil
+}
+
+func (s *dbCustomerStore) saveCustomerID(ctx context.Context, orgID, accountID, customerID string) error {
+	query := `
+		INSERT INTO stripe_customers (organization_id, stripe_account_id, stripe_customer_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, stripe_account_id)
+		DO UPDATE SET stripe_customer_id = EXCLUDED.stripe_customer_id, updated_at = NOW()
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, orgID, accountID, customerID); err != nil {
+		return fmt.Errorf("failed to save Stripe customer mapping: %w", err)
+	}
+
+	return nil
+}
+
+// customerCache memoizes customer IDs for the life of the process, in front of the customerStore
+type customerCache struct {
+	mu  sync.RWMutex
+	ids map[string]string // orgID + "/" + accountID → customer ID
+}
+
+func customerCacheKey(orgID, accountID string) string {
+	return orgID + "/" + accountID
+}
+
+func (c *customerCache) get(orgID, accountID string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ids[customerCacheKey(orgID, accountID)]
+}
+
+func (c *customerCache) set(orgID, accountID, customerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if customerID == "" {
+		delete(c.ids, customerCacheKey(orgID, accountID))
+		return
+	}
+	c.ids[customerCacheKey(orgID, accountID)] = customerID
+}
+
+// WithCustomerTable persists customer IDs in stripe_customers so they survive restarts
+// Without it, IDs are only cached in memory
+func (si *StripeIntegration) WithCustomerTable(db *sql.DB) *StripeIntegration {
+	if db != nil {
+		si.customerStore = &dbCustomerStore{db: db}
+	}
+	return si
+}
+
+// cachedCustomerID looks up a known customer ID in memory, then in the customer table
+func (si *StripeIntegration) cachedCustomerID(ctx context.Context, org *Organization) string {
+	if id := si.customers.get(org.ID, org.StripeAccountID); id != "" {
+		return id
+	}
+	if si.customerStore == nil {
+		return ""
+	}
+
+	id, err := si.customerStore.getCustomerID(ctx, org.ID, org.StripeAccountID)
+	if err != nil {
+		// Fall back to searching Stripe
+		log.Printf("[StripeIntegration] WARNING: %v", err)
+		return ""
+	}
+	if id != "" {
+		si.customers.set(org.ID, org.StripeAccountID, id)
+	}
+	return id
+}
+
+// rememberCustomer records a customer ID in memory and in the customer table
+func (si *StripeIntegration) rememberCustomer(ctx context.Context, org *Organization, customerID string) {
+	si.customers.set(org.ID, org.StripeAccountID, customerID)
+	if si.customerStore == nil {
+		return
+	}
+	if err := si.customerStore.saveCustomerID(ctx, org.ID, org.StripeAccountID, customerID); err != nil {
+		// The next run will search Stripe again and retry the write
+		log.Printf("[StripeIntegration] WARNING: %v", err)
+	}
+}
+
+// isCustomerGone reports whether a customer lookup failed because it was deleted in Stripe
+func isCustomerGone(customer *stripe.Customer, err error) bool {
+	if err == nil {
+		return customer == nil || customer.Deleted
+	}
+
+	var stripeErr *stripe.Error
+	return errors.As(err, &stripeErr) &&
+		(stripeErr.HTTPStatusCode == http.StatusNotFound || stripeErr.Code == stripe.ErrorCodeResourceMissing)
+}
@@ -0,0 +1,170 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stripe/stripe-go/v76"
+)
+
+// fakeCustomerAPI records Stripe customer API calls
+type fakeCustomerAPI struct {
+	customers map[string]*stripe.Customer // by ID
+	searchHit *stripe.Customer            // returned by search, nil for no match
+
+	searches, gets, creates int
+}
+
+func (f *fakeCustomerAPI) search(params *stripe.CustomerSearchParams) (*stripe.Customer, error) {
+	f.searches++
+	return f.searchHit, nil
+}
+
+func (f *fakeCustomerAPI) get(id string, params *stripe.CustomerParams) (*stripe.Customer, error) {
+	f.gets++
+	customer, ok := f.customers[id]
+	if !ok {
+		return nil, &stripe.Error{HTTPStatusCode: http.StatusNotFound, Code: stripe.ErrorCodeResourceMissing}
+	}
+	return customer, nil
+}
+
+func (f *fakeCustomerAPI) create(params *stripe.CustomerParams) (*stripe.Customer, error) {
+	f.creates++
+	customer := &stripe.Customer{ID: fmt.Sprintf("cus_new%d", f.creates), Email: *params.Email}
+	f.customers[customer.ID] = customer
+	return customer, nil
+}
+
+// fakeCustomerStore is an in-memory stripe_customers table
+type fakeCustomerStore struct {
+	ids map[string]string
+}
+
+func (f *fakeCustomerStore) getCustomerID(ctx context.Context, orgID, accountID string) (string, error) {
+	return f.ids[customerCacheKey(orgID, accountID)], nil
+}
+
+func (f *fakeCustomerStore) saveCustomerID(ctx context.Context, orgID, accountID, customerID string) error {
+	f.ids[customerCacheKey(orgID, accountID)] = customerID
+	return nil
+}
+
+func newCustomerTestIntegration(api *fakeCustomerAPI, store *fakeCustomerStore) *StripeIntegration {
+	si := NewStripeIntegration(nil, &InvoiceConfig{EnableStripe: true})
+	si.customerAPI = api
+	si.customerStore = store
+	return si
+}
+
+func TestCreateOrGetCustomer_CachesCustomerID(t *testing.T) {
+	api := &fakeCustomerAPI{customers: map[string]*stripe.Customer{}}
+	store := &fakeCustomerStore{ids: map[string]string{}}
+	si := newCustomerTestIntegration(api, store)
+	org := &Organization{ID: "org-1", Name: "Acme", Email: "billing@acme.test"}
+	ctx := context.Background()
+
+	first, err := si.CreateOrGetCustomer(ctx, org)
+	if err != nil {
+		t.Fatalf("First CreateOrGetCustomer() error = %v", err)
+	}
+	if api.searches != 1 || api.creates != 1 {
+		t.Fatalf("First lookup: %d searches, %d creates, want 1 and 1", api.searches, api.creates)
+	}
+	if store.ids[customerCacheKey("org-1", "")] != first.ID {
+		t.Errorf("Customer ID not written back to the customer table: %v", store.ids)
+	}
+
+	second, err := si.CreateOrGetCustomer(ctx, org)
+	if err != nil {
+		t.Fatalf("Second CreateOrGetCustomer() error = %v", err)
+	}
+	if api.searches != 1 {
+		t.Errorf("Second lookup searched Stripe (%d searches), want cached", api.searches)
+	}
+	if api.creates != 1 || second.ID != first.ID {
+		t.Errorf("Second lookup returned %s after %d creates, want %s", second.ID, api.creates, first.ID)
+	}
+
+	// A new process (empty memory cache) uses the customer table instead of searching
+	restarted := newCustomerTestIntegration(api, store)
+	if _, err := restarted.CreateOrGetCustomer(ctx, org); err != nil {
+		t.Fatalf("CreateOrGetCustomer() after restart error = %v", err)
+	}
+	if api.searches != 1 {
+		t.Errorf("Lookup after restart searched Stripe (%d searches), want customer table hit", api.searches)
+	}
+}
+
+func TestCreateOrGetCustomer_SearchHitIsCached(t *testing.T) {
+	existing := &stripe.Customer{ID: "cus_existing"}
+	api := &fakeCustomerAPI{customers: map[string]*stripe.Customer{"cus_existing": existing}, searchHit: existing}
+	store := &fakeCustomerStore{ids: map[string]string{}}
+	si := newCustomerTestIntegration(api, store)
+	org := &Organization{ID: "org-1", Email: "billing@acme.test"}
+
+	for i := 0; i < 3; i++ {
+		customer, err := si.CreateOrGetCustomer(context.Background(), org)
+		if err != nil {
+			t.Fatalf("CreateOrGetCustomer() error = %v", err)
+		}
+		if customer.ID != "cus_existing" {
+			t.Errorf("Customer = %s, want cus_existing", customer.ID)
+		}
+	}
+
+	if api.searches != 1 || api.creates != 0 {
+		t.Errorf("%d searches, %d creates, want 1 and 0", api.searches, api.creates)
+	}
+}
+
+func TestCreateOrGetCustomer_DeletedCustomerRecreated(t *testing.T) {
+	tests := []struct {
+		name      string
+		customers map[string]*stripe.Customer
+	}{
+		{"Not found (404)", map[string]*stripe.Customer{}},
+		{"Deleted", map[string]*stripe.Customer{"cus_gone": {ID: "cus_gone", Deleted: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeCustomerAPI{customers: tt.customers}
+			store := &fakeCustomerStore{ids: map[string]string{customerCacheKey("org-1", ""): "cus_gone"}}
+			si := newCustomerTestIntegration(api, store)
+			org := &Organization{ID: "org-1", Email: "billing@acme.test"}
+
+			customer, err := si.CreateOrGetCustomer(context.Background(), org)
+			if err != nil {
+				t.Fatalf("CreateOrGetCustomer() error = %v", err)
+			}
+
+			if customer.ID == "cus_gone" || api.creates != 1 {
+				t.Errorf("Customer = %s after %d creates, want a new customer", customer.ID, api.creates)
+			}
+			if api.searches != 0 {
+				t.Errorf("Recreating a deleted customer searched Stripe %d times", api.searches)
+			}
+			if store.ids[customerCacheKey("org-1", "")] != customer.ID {
+				t.Errorf("Customer table = %v, want %s", store.ids, customer.ID)
+			}
+		})
+	}
+}
+
+func TestCreateOrGetCustomer_PerConnectedAccount(t *testing.T) {
+	api := &fakeCustomerAPI{customers: map[string]*stripe.Customer{}}
+	store := &fakeCustomerStore{ids: map[string]string{}}
+	si := newCustomerTestIntegration(api, store)
+	ctx := context.Background()
+
+	// Moving an org to a connected account needs a customer on that account
+	platform, _ := si.CreateOrGetCustomer(ctx, &Organization{ID: "org-1", Email: "billing@acme.test"})
+	connected, _ := si.CreateOrGetCustomer(ctx, &Organization{ID: "org-1", Email: "billing@acme.test", StripeAccountID: "acct_123"})
+
+	if platform.ID == connected.ID || api.creates != 2 {
+		t.Errorf("Platform %s and connected %s customers after %d creates, want distinct", platform.ID, connected.ID, api.creates)
+	}
+}
@@ -0,0 +1,135 @@
+package invoice
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInvoiceItemParams_QuantityAndUnitPrice(t *testing.T) {
+	si := NewStripeIntegration(nil, &InvoiceConfig{EnableStripe: true})
+	inv := &Invoice{ID: "inv-1", OrganizationID: "org-1"}
+
+	tests := []struct {
+		name        string
+		item        LineItem
+		amount      int64   // 0 = unset
+		quantity    int64   // 0 = unset
+		unitAmount  int64   // 0 = unset
+		unitDecimal float64 // 0 = unset
+	}{
+		{
+			name:   "Flat base charge",
+			item:   LineItem{Description: "Starter Plan - Base Fee", Quantity: 1, UnitPriceCents: 2900, AmountCents: 2900, ItemType: "base_plan"},
+			amount: 2900,
+		},
+		{
+			name:       "Whole-cent unit price",
+			item:       LineItem{Description: "Seats", Quantity: 25, UnitPriceCents: 400, AmountCents: 10000, ItemType: "overage"},
+			quantity:   25,
+			unitAmount: 400,
+		},
+		{
+			name:        "Sub-cent unit price",
+			item:        LineItem{Description: "API Calls Overage", Quantity: 500000, UnitPriceCents: 0, AmountCents: 2500, ItemType: "overage"},
+			quantity:    500000,
+			unitDecimal: 0.005,
+		},
+		{
+			name:   "Discount",
+			item:   LineItem{Description: "Coupon SPRING25: 25% off", Quantity: 1, AmountCents: -2500, ItemType: "discount"},
+			amount: -2500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := si.invoiceItemParams(inv, tt.item, "cus_1")
+
+			if got := params.Metadata["item_type"]; got != tt.item.ItemType {
+				t.Errorf("item_type metadata = %q, want %q", got, tt.item.ItemType)
+			}
+
+			checkInt64 := func(field string, got *int64, want int64) {
+				if want == 0 {
+					if got != nil {
+						t.Errorf("%s = %d, want unset", field, *got)
+					}
+					return
+				}
+				if got == nil || *got != want {
+					t.Errorf("%s = %v, want %d", field, got, want)
+				}
+			}
+			checkInt64("Amount", params.Amount, tt.amount)
+			checkInt64("Quantity", params.Quantity, tt.quantity)
+			checkInt64("UnitAmount", params.UnitAmount, tt.unitAmount)
+
+			if tt.unitDecimal == 0 {
+				if params.UnitAmountDecimal != nil {
+					t.Errorf("UnitAmountDecimal = %v, want unset", *params.UnitAmountDecimal)
+				}
+				return
+			}
+			if params.UnitAmountDecimal == nil || *params.UnitAmountDecimal != tt.unitDecimal {
+				t.Fatalf("UnitAmountDecimal = %v, want %v", params.UnitAmountDecimal, tt.unitDecimal)
+			}
+
+			// Stripe's total (quantity x unit price) must match our line item
+			total := math.Round(float64(*params.Quantity) * *params.UnitAmountDecimal)
+			if int64(total) != tt.item.AmountCents {
+				t.Errorf("Stripe total = %v, want %d", total, tt.item.AmountCents)
+			}
+		})
+	}
+}
+
+func TestInvoiceParams_PONumberAndCustomFields(t *testing.T) {
+	si := NewStripeIntegration(nil, &InvoiceConfig{EnableStripe: true})
+	inv := &Invoice{
+		ID:             "inv-1",
+		OrganizationID: "org-1",
+		InvoiceNumber:  "INV-2026-03-0001",
+		PONumber:       "PO-7781",
+		CustomFields: map[string]string{
+			"cost_center": "CC-42",
+			"invoice_id":  "spoofed", // Reserved keys keep our values
+		},
+	}
+
+	metadata := si.invoiceParams(inv, "cus_1").Metadata
+
+	expected := map[string]string{
+		"invoice_id":     "inv-1",
+		"invoice_number": "INV-2026-03-0001",
+		"po_number":      "PO-7781",
+		"cost_center":    "CC-42",
+	}
+	for key, want := range expected {
+		if got := metadata[key]; got != want {
+			t.Errorf("Metadata[%q] = %q, want %q", key, got, want)
+		}
+	}
+
+	// No PO number, no po_number key
+	inv.PONumber = ""
+	if _, ok := si.invoiceParams(inv, "cus_1").Metadata["po_number"]; ok {
+		t.Error("Expected no po_number metadata without a PO number")
+	}
+}
+
+func TestStripeUnitAmountDecimal(t *testing.T) {
+	tests := []struct {
+		amountCents, quantity int64
+		expected              float64
+	}{
+		{2500, 500000, 0.005},
+		{1, 3, 0.333333333333},
+		{200, 3, 66.666666666667},
+	}
+
+	for _, tt := range tests {
+		if got := stripeUnitAmountDecimal(tt.amountCents, tt.quantity); got != tt.expected {
+			t.Errorf("stripeUnitAmountDecimal(%d, %d) = %v, want %v", tt.amountCents, tt.quantity, got, tt.expected)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/stripe/stripe-go/v76"
 )
@@ -31,7 +30,7 @@ func TestNewStripeIntegration(t *testing.T) {
 	config := createTestConfig()
 	config.StripeAPIKey = "sk_test_123"
 
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	if integration == nil {
 		t.Fatal("Expected non-nil Stripe integration")
@@ -45,7 +44,7 @@ func TestNewStripeIntegration(t *testing.T) {
 // TestStripeIntegration_CreateOrGetCustomer tests customer creation and retrieval
 func TestStripeIntegration_CreateOrGetCustomer(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 
@@ -77,23 +76,24 @@ func TestStripeIntegration_CreateOrGetCustomer(t *testing.T) {
 			// Skipping actual API call in unit test
 			t.Skip("Skipping Stripe API call in unit test")
 
-			customerID, err := integration.CreateOrGetCustomer(ctx, tt.orgID, tt.email, tt.orgName)
+			org := &Organization{ID: tt.orgID, Email: tt.email, Name: tt.orgName}
+			customer, err := integration.CreateOrGetCustomer(ctx, org)
 			if err != nil {
 				t.Fatalf("Failed to create customer: %v", err)
 			}
 
-			if customerID == "" {
+			if customer.ID == "" {
 				t.Error("Expected non-empty customer ID")
 			}
 
 			// Verify customer can be retrieved again
-			customerID2, err := integration.CreateOrGetCustomer(ctx, tt.orgID, tt.email, tt.orgName)
+			customer2, err := integration.CreateOrGetCustomer(ctx, org)
 			if err != nil {
 				t.Fatalf("Failed to get existing customer: %v", err)
 			}
 
-			if customerID != customerID2 {
-				t.Errorf("Expected same customer ID, got %s and %s", customerID, customerID2)
+			if customer.ID != customer2.ID {
+				t.Errorf("Expected same customer ID, got %s and %s", customer.ID, customer2.ID)
 			}
 		})
 	}
@@ -102,28 +102,24 @@ func TestStripeIntegration_CreateOrGetCustomer(t *testing.T) {
 // TestStripeIntegration_CreateInvoice tests invoice creation
 func TestStripeIntegration_CreateInvoice(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	invoice := createTestInvoice()
-	customerID := "cus_test_123"
+	customer := &stripe.Customer{ID: "cus_test_123"}
 
 	t.Run("Create invoice", func(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		stripeInvoiceID, err := integration.CreateInvoice(ctx, invoice, customerID)
+		stripeInvoice, err := integration.CreateInvoice(ctx, invoice, customer)
 		if err != nil {
 			t.Fatalf("Failed to create invoice: %v", err)
 		}
 
-		if stripeInvoiceID == "" {
-			t.Error("Expected non-empty Stripe invoice ID")
-		}
-
 		// Verify invoice ID starts with "in_"
-		if !strings.HasPrefix(stripeInvoiceID, "in_") {
-			t.Errorf("Expected Stripe invoice ID to start with 'in_', got %s", stripeInvoiceID)
+		if !strings.HasPrefix(stripeInvoice.ID, "in_") {
+			t.Errorf("Expected Stripe invoice ID to start with 'in_', got %s", stripeInvoice.ID)
 		}
 	})
 
@@ -151,7 +147,7 @@ func TestStripeIntegration_CreateInvoice(t *testing.T) {
 // TestStripeIntegration_FinalizeInvoice tests invoice finalization
 func TestStripeIntegration_FinalizeInvoice(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	stripeInvoiceID := "in_test_123"
@@ -160,7 +156,7 @@ func TestStripeIntegration_FinalizeInvoice(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		err := integration.FinalizeInvoice(ctx, stripeInvoiceID)
+		_, err := integration.FinalizeInvoice(ctx, stripeInvoiceID)
 		if err != nil {
 			t.Fatalf("Failed to finalize invoice: %v", err)
 		}
@@ -170,7 +166,7 @@ func TestStripeIntegration_FinalizeInvoice(t *testing.T) {
 // TestStripeIntegration_ChargeInvoice tests charging an invoice
 func TestStripeIntegration_ChargeInvoice(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	stripeInvoiceID := "in_test_123"
@@ -179,7 +175,7 @@ func TestStripeIntegration_ChargeInvoice(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		err := integration.ChargeInvoice(ctx, stripeInvoiceID)
+		_, err := integration.ChargeInvoice(ctx, stripeInvoiceID)
 		if err != nil {
 			t.Fatalf("Failed to charge invoice: %v", err)
 		}
@@ -189,7 +185,7 @@ func TestStripeIntegration_ChargeInvoice(t *testing.T) {
 // TestStripeIntegration_GetInvoice tests retrieving an invoice
 func TestStripeIntegration_GetInvoice(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	stripeInvoiceID := "in_test_123"
@@ -216,7 +212,7 @@ func TestStripeIntegration_GetInvoice(t *testing.T) {
 // TestStripeIntegration_VoidInvoice tests voiding an invoice
 func TestStripeIntegration_VoidInvoice(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	stripeInvoiceID := "in_test_123"
@@ -225,7 +221,7 @@ func TestStripeIntegration_VoidInvoice(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		err := integration.VoidInvoice(ctx, stripeInvoiceID)
+		_, err := integration.VoidInvoice(ctx, stripeInvoiceID)
 		if err != nil {
 			t.Fatalf("Failed to void invoice: %v", err)
 		}
@@ -235,7 +231,7 @@ func TestStripeIntegration_VoidInvoice(t *testing.T) {
 // TestStripeIntegration_SendInvoice tests sending an invoice
 func TestStripeIntegration_SendInvoice(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	stripeInvoiceID := "in_test_123"
@@ -244,7 +240,7 @@ func TestStripeIntegration_SendInvoice(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		err := integration.SendInvoice(ctx, stripeInvoiceID)
+		_, err := integration.SendInvoice(ctx, stripeInvoiceID)
 		if err != nil {
 			t.Fatalf("Failed to send invoice: %v", err)
 		}
@@ -254,10 +250,10 @@ func TestStripeIntegration_SendInvoice(t *testing.T) {
 // TestStripeIntegration_CreateRefund tests creating a refund
 func TestStripeIntegration_CreateRefund(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
-	chargeID := "ch_test_123"
+	stripeInvoiceID := "in_test_123"
 
 	tests := []struct {
 		name   string
@@ -281,12 +277,12 @@ func TestStripeIntegration_CreateRefund(t *testing.T) {
 			// Skip actual API call in unit test
 			t.Skip("Skipping Stripe API call in unit test")
 
-			refundID, err := integration.CreateRefund(ctx, chargeID, tt.amount, tt.reason)
+			refund, err := integration.CreateRefund(ctx, stripeInvoiceID, tt.amount, tt.reason, "refund_test_123")
 			if err != nil {
 				t.Fatalf("Failed to create refund: %v", err)
 			}
 
-			if refundID == "" {
+			if refund.ID == "" {
 				t.Error("Expected non-empty refund ID")
 			}
 		})
@@ -296,7 +292,7 @@ func TestStripeIntegration_CreateRefund(t *testing.T) {
 // TestStripeIntegration_HandleWebhook tests webhook event handling
 func TestStripeIntegration_HandleWebhook(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 
@@ -391,7 +387,7 @@ func TestStripeIntegration_HandleWebhook(t *testing.T) {
 // TestStripeIntegration_GetCustomerPaymentMethods tests listing payment methods
 func TestStripeIntegration_GetCustomerPaymentMethods(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	customerID := "cus_test_123"
@@ -415,7 +411,7 @@ func TestStripeIntegration_GetCustomerPaymentMethods(t *testing.T) {
 // TestStripeIntegration_AttachPaymentMethod tests attaching a payment method
 func TestStripeIntegration_AttachPaymentMethod(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	customerID := "cus_test_123"
@@ -425,7 +421,7 @@ func TestStripeIntegration_AttachPaymentMethod(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		err := integration.AttachPaymentMethod(ctx, paymentMethodID, customerID)
+		_, err := integration.AttachPaymentMethod(ctx, paymentMethodID, customerID)
 		if err != nil {
 			t.Fatalf("Failed to attach payment method: %v", err)
 		}
@@ -435,7 +431,7 @@ func TestStripeIntegration_AttachPaymentMethod(t *testing.T) {
 // TestStripeIntegration_SetDefaultPaymentMethod tests setting default payment method
 func TestStripeIntegration_SetDefaultPaymentMethod(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 	customerID := "cus_test_123"
@@ -445,7 +441,7 @@ func TestStripeIntegration_SetDefaultPaymentMethod(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		err := integration.SetDefaultPaymentMethod(ctx, customerID, paymentMethodID)
+		_, err := integration.SetDefaultPaymentMethod(ctx, customerID, paymentMethodID)
 		if err != nil {
 			t.Fatalf("Failed to set default payment method: %v", err)
 		}
@@ -455,7 +451,7 @@ func TestStripeIntegration_SetDefaultPaymentMethod(t *testing.T) {
 // TestStripeIntegration_ErrorHandling tests error scenarios
 func TestStripeIntegration_ErrorHandling(t *testing.T) {
 	config := createTestConfig()
-	integration := NewStripeIntegration(config)
+	integration := NewStripeIntegration(nil, config)
 
 	ctx := context.Background()
 
@@ -464,7 +460,7 @@ func TestStripeIntegration_ErrorHandling(t *testing.T) {
 		t.Skip("Skipping Stripe API call in unit test")
 
 		invoice := createTestInvoice()
-		_, err := integration.CreateInvoice(ctx, invoice, "invalid_customer")
+		_, err := integration.CreateInvoice(ctx, invoice, &stripe.Customer{ID: "invalid_customer"})
 		if err == nil {
 			t.Error("Expected error for invalid customer")
 		}
@@ -484,7 +480,7 @@ func TestStripeIntegration_ErrorHandling(t *testing.T) {
 		// Skip actual API call in unit test
 		t.Skip("Skipping Stripe API call in unit test")
 
-		err := integration.ChargeInvoice(ctx, "in_test_no_payment")
+		_, err := integration.ChargeInvoice(ctx, "in_test_no_payment")
 		if err == nil {
 			t.Error("Expected error when charging invoice without payment method")
 		}
@@ -516,7 +512,7 @@ func TestStripeIntegration_Metadata(t *testing.T) {
 // TestStripeWebhookSignatureValidation tests webhook signature validation
 func TestStripeWebhookSignatureValidation(t *testing.T) {
 	config := createTestConfig()
-	config.StripeWebhookSecret = "whsec_test_secret"
+	config.StripeWebhook = "whsec_test_secret"
 
 	t.Run("Valid signature", func(t *testing.T) {
 		// This would test actual signature validation
@@ -0,0 +1,128 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Organization statuses, stored in organizations.status
+// The gateway answers requests of suspended organizations with 402 Payment Required
+const (
+	OrgStatusActive    = "active"
+	OrgStatusSuspended = "suspended"
+
+	// SuspensionReasonNonPayment marks suspensions made by the dunning flow
+	SuspensionReasonNonPayment = "non_payment"
+)
+
+// Suspension errors
+var (
+	ErrSuspensionReasonRequired = errors.New("suspension reason is required")
+	ErrOrganizationNotFound     = errors.New("organization not found")
+)
+
+// orgTransitions lists the statuses each organization status may move to
+var orgTransitions = map[string][]string{
+	OrgStatusActive:    {OrgStatusSuspended},
+	OrgStatusSuspended: {OrgStatusActive},
+}
+
+// OrgStatusChange describes the result of suspending or reactivating an organization
+type OrgStatusChange struct {
+	OrganizationID string
+	From           string
+	To             string
+	Changed        bool // False when the organization already had the target status
+	ChangedAt      time.Time
+}
+
+// CanTransitionOrg reports whether an organization may move from one status to another
+// Repeating the current status is a no-op, so a dunning run can be retried safely
+func CanTransitionOrg(from, to string) bool {
+	if _, ok := orgTransitions[to]; !ok {
+		return false
+	}
+	if from == to {
+		return true
+	}
+
+	for _, allowed := range orgTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SuspendOrganization suspends an organization, e.g. with SuspensionReasonNonPayment
+// Its API keys stop working at the gateway; its users can still sign in to the dashboard to pay
+func (g *InvoiceGenerator) SuspendOrganization(ctx context.Context, orgID, reason string) (*OrgStatusChange, error) {
+	if reason == "" {
+		return nil, ErrSuspensionReasonRequired
+	}
+	return g.setOrganizationStatus(ctx, orgID, OrgStatusSuspended, reason)
+}
+
+// ReactivateOrganization returns a suspended organization to active and clears its suspension reason
+func (g *InvoiceGenerator) ReactivateOrganization(ctx context.Context, orgID string) (*OrgStatusChange, error) {
+	return g.setOrganizationStatus(ctx, orgID, OrgStatusActive, "")
+}
+
+// setOrganizationStatus validates and applies an organization status change
+func (g *InvoiceGenerator) setOrganizationStatus(ctx context.Context, orgID, status, reason string) (*OrgStatusChange, error) {
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Step 1: Lock the organization and read its current status
+	var current string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM organizations WHERE id = $1 FOR UPDATE", orgID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrOrganizationNotFound, orgID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization status: %w", err)
+	}
+
+	// Step 2: Validate the transition
+	if !CanTransitionOrg(current, status) {
+		return nil, fmt.Errorf("invalid organization status transition for %s: %s -> %s", orgID, current, status)
+	}
+
+	change := &OrgStatusChange{
+		OrganizationID: orgID,
+		From:           current,
+		To:             status,
+		ChangedAt:      time.Now(),
+	}
+
+	if current == status {
+		return change, nil
+	}
+
+	// Step 3: Apply it; the status trigger evicts the org's keys from the gateway cache
+	query := `
+		UPDATE organizations
+		SET status = $1,
+		    suspension_reason = NULLIF($2, ''),
+		    suspended_at = CASE WHEN $1 = 'suspended' THEN $3 ELSE NULL END,
+		    updated_at = $3
+		WHERE id = $4
+	`
+
+	if _, err := tx.ExecContext(ctx, query, status, reason, change.ChangedAt, orgID); err != nil {
+		return nil, fmt.Errorf("failed to update organization status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	change.Changed = true
+	return change, nil
+}
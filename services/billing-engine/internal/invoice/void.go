@@ -0,0 +1,237 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stripe/stripe-go/v76"
+)
+
+// Void errors
+var (
+	ErrInvoicePaid          = errors.New("invoice is paid and cannot be voided; issue a refund instead")
+	ErrInvoiceAlreadyVoided = errors.New("invoice is already voided")
+	ErrVoidReasonRequired   = errors.New("void reason is required")
+)
+
+// InvoiceEventVoided is the invoice_events type recorded by VoidInvoice
+const InvoiceEventVoided = "voided"
+
+// stripeVoider voids invoices on Stripe (implemented by StripeIntegration)
+type stripeVoider interface {
+	VoidInvoice(ctx context.Context, stripeInvoiceID string) (*stripe.Invoice, error)
+}
+
+// checkVoidable returns an error if an invoice in status cannot be voided
+func checkVoidable(status string) error {
+	switch status {
+	case InvoiceStatusPaid:
+		return ErrInvoicePaid
+	case InvoiceStatusVoided:
+		return ErrInvoiceAlreadyVoided
+	}
+	return ValidateTransition(status, InvoiceStatusVoided)
+}
+
+// VoidInvoice cancels an unpaid invoice, voids it on Stripe, and records who voided it and why
+// Voided invoices can never move to paid, so they cannot be charged again
+func (g *InvoiceGenerator) VoidInvoice(ctx context.Context, invoiceID, reason, actorUserID string) error {
+	if reason == "" {
+		return ErrVoidReasonRequired
+	}
+
+	tx, err := g.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Step 1: Lock the invoice and check it can be voided
+	var status string
+	var stripeInvoiceID sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, stripe_invoice_id FROM invoices WHERE id = $1 FOR UPDATE", invoiceID,
+	).Scan(&status, &stripeInvoiceID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("invoice not found: %s", invoiceID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	if err := checkVoidable(status); err != nil {
+		recordStatusTransition(status, InvoiceStatusVoided, false)
+		return err
+	}
+
+	// Step 2: Void on Stripe first, so a Stripe failure leaves the invoice collectible in both places
+	now := time.Now()
+	var stripeVoidedAt *time.Time
+	if stripeInvoiceID.Valid && stripeInvoiceID.String != "" && g.config.EnableStripe {
+		if _, err := g.stripe.VoidInvoice(ctx, stripeInvoiceID.String); err != nil {
+			return err
+		}
+		stripeVoidedAt = &now
+	}
+
+	// Step 3: Update status and write the audit row
+	query := `
+		UPDATE invoices
+		SET status = $1, updated_at = $2, stripe_voided_at = $3
+		WHERE id = $4
+	`
+	if _, err := tx.ExecContext(ctx, query, InvoiceStatusVoided, now, stripeVoidedAt, invoiceID); err != nil {
+		return fmt.Errorf("failed to void invoice: %w", err)
+	}
+
+	if err := insertInvoiceEvent(ctx, tx, invoiceID, InvoiceEventVoided, status, InvoiceStatusVoided, actorUserID, reason, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	recordStatusTransition(status, InvoiceStatusVoided, true)
+	return nil
+}
+
+// SyncStripeVoids voids on Stripe any invoice voided without Stripe access (e.g. from the dashboard)
+// Returns the number of invoices synced
+func (g *InvoiceGenerator) SyncStripeVoids(ctx context.Context) (int, error) {
+	if !g.config.EnableStripe {
+		return 0, nil
+	}
+
+	query := `
+		SELECT id, stripe_invoice_id
+		FROM invoices
+		WHERE status = 'voided'
+		  AND stripe_invoice_id IS NOT NULL
+		  AND stripe_voided_at IS NULL
+		ORDER BY updated_at
+		LIMIT 100
+	`
+
+	rows, err := g.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending Stripe voids: %w", err)
+	}
+
+	type pendingVoid struct{ invoiceID, stripeInvoiceID string }
+	var pending []pendingVoid
+	for rows.Next() {
+		var p pendingVoid
+		if err := rows.Scan(&p.invoiceID, &p.stripeInvoiceID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan pending Stripe void: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating pending Stripe voids: %w", err)
+	}
+
+	synced := 0
+	for _, p := range pending {
+		if _, err := g.stripe.VoidInvoice(ctx, p.stripeInvoiceID); err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to void Stripe invoice %s for %s: %v", p.stripeInvoiceID, p.invoiceID, err)
+			continue
+		}
+
+		if _, err := g.db.ExecContext(ctx, "UPDATE invoices SET stripe_voided_at = NOW() WHERE id = $1", p.invoiceID); err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to mark Stripe void for %s: %v", p.invoiceID, err)
+			continue
+		}
+		synced++
+	}
+
+	return synced, nil
+}
+
+// pdfDeleter deletes stored invoice PDFs (implemented by StorageManager)
+type pdfDeleter interface {
+	DeletePDF(ctx context.Context, invoice *Invoice) error
+}
+
+// voidedPDFCutoff returns the invoice date before which voided invoices' PDFs may be deleted
+// ok is false when VoidedPDFRetentionDays keeps them indefinitely
+func voidedPDFCutoff(config *InvoiceConfig, now time.Time) (cutoff time.Time, ok bool) {
+	if config.VoidedPDFRetentionDays <= 0 {
+		return time.Time{}, false
+	}
+	return now.AddDate(0, 0, -config.VoidedPDFRetentionDays), true
+}
+
+// PurgeVoidedPDFs deletes the stored PDFs of voided invoices past VoidedPDFRetentionDays
+// The invoice rows are kept; only pdf_url and pdf_sha256 are cleared. Returns the number of PDFs deleted
+func (g *InvoiceGenerator) PurgeVoidedPDFs(ctx context.Context, storage pdfDeleter, now time.Time) (int, error) {
+	cutoff, ok := voidedPDFCutoff(g.config, now)
+	if !ok || !g.config.EnableS3 {
+		return 0, nil
+	}
+
+	query := `
+		SELECT id, organization_id, invoice_number, billing_period_start
+		FROM invoices
+		WHERE status = 'voided'
+		  AND pdf_url IS NOT NULL
+		  AND invoice_date < $1
+		ORDER BY invoice_date
+		LIMIT 100
+	`
+
+	rows, err := g.db.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query voided invoice PDFs: %w", err)
+	}
+
+	var expired []*Invoice
+	for rows.Next() {
+		inv := &Invoice{}
+		if err := rows.Scan(&inv.ID, &inv.OrganizationID, &inv.InvoiceNumber, &inv.BillingPeriodStart); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan voided invoice: %w", err)
+		}
+		expired = append(expired, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating voided invoices: %w", err)
+	}
+
+	deleted := 0
+	for _, inv := range expired {
+		if err := storage.DeletePDF(ctx, inv); err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to delete PDF of voided invoice %s: %v", inv.InvoiceNumber, err)
+			continue
+		}
+
+		if _, err := g.db.ExecContext(ctx, "UPDATE invoices SET pdf_url = NULL, pdf_sha256 = NULL WHERE id = $1", inv.ID); err != nil {
+			log.Printf("[InvoiceGenerator] ERROR: Failed to clear PDF of voided invoice %s: %v", inv.InvoiceNumber, err)
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// insertInvoiceEvent writes an audit row to invoice_events
+func insertInvoiceEvent(ctx context.Context, tx *sql.Tx, invoiceID, eventType, fromStatus, toStatus, actorUserID, reason string, at time.Time) error {
+	query := `
+		INSERT INTO invoice_events (invoice_id, event_type, from_status, to_status, actor_user_id, reason, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+	`
+
+	if _, err := tx.ExecContext(ctx, query, invoiceID, eventType, fromStatus, toStatus, actorUserID, reason, at); err != nil {
+		return fmt.Errorf("failed to record invoice event: %w", err)
+	}
+
+	return nil
+}
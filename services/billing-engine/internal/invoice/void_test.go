@@ -0,0 +1,100 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckVoidable(t *testing.T) {
+	tests := []struct {
+		status  string
+		wantErr error
+	}{
+		{InvoiceStatusDraft, nil},
+		{InvoiceStatusPending, nil},
+		{InvoiceStatusSendFailed, nil},
+		{InvoiceStatusEmailFailed, nil},
+		{InvoiceStatusFailed, nil},
+		{InvoiceStatusPaid, ErrInvoicePaid},
+		{InvoiceStatusVoided, ErrInvoiceAlreadyVoided},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if err := checkVoidable(tt.status); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkVoidable(%s) = %v, want %v", tt.status, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Refunded invoices are past voiding
+	var transitionErr *InvalidTransitionError
+	if err := checkVoidable(InvoiceStatusRefunded); !errors.As(err, &transitionErr) {
+		t.Errorf("checkVoidable(refunded) = %v, want *InvalidTransitionError", err)
+	}
+}
+
+func TestVoidInvoice_RequiresReason(t *testing.T) {
+	gen := NewInvoiceGenerator(nil, nil, nil, createTestConfig())
+
+	if err := gen.VoidInvoice(context.Background(), "inv-123", "", "user-1"); !errors.Is(err, ErrVoidReasonRequired) {
+		t.Errorf("VoidInvoice() error = %v, want %v", err, ErrVoidReasonRequired)
+	}
+}
+
+// TestVoidInvoice_PaidInvoiceRejected tests that a paid invoice is never voided
+func TestVoidInvoice_PaidInvoiceRejected(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gen := NewInvoiceGenerator(db, nil, nil, createTestConfig())
+	ctx := context.Background()
+
+	var invoiceID string
+	err := db.QueryRowContext(ctx, "SELECT id FROM invoices WHERE status = 'paid' LIMIT 1").Scan(&invoiceID)
+	if err != nil {
+		t.Skipf("Skipping test: no paid invoice available: %v", err)
+	}
+
+	if err := gen.VoidInvoice(ctx, invoiceID, "duplicate charge", "user-1"); !errors.Is(err, ErrInvoicePaid) {
+		t.Errorf("VoidInvoice() error = %v, want %v", err, ErrInvoicePaid)
+	}
+}
+
+func TestVoidedPDFCutoff(t *testing.T) {
+	now := time.Date(2033, 3, 15, 0, 0, 0, 0, time.UTC)
+	config := createTestConfig()
+
+	// Invoices are legal documents: kept indefinitely unless configured
+	if _, ok := voidedPDFCutoff(config, now); ok {
+		t.Error("voidedPDFCutoff() with no retention configured = ok, want keep indefinitely")
+	}
+
+	config.VoidedPDFRetentionDays = 7 * 365
+	cutoff, ok := voidedPDFCutoff(config, now)
+	if want := time.Date(2026, 3, 17, 0, 0, 0, 0, time.UTC); !ok || !cutoff.Equal(want) {
+		t.Errorf("voidedPDFCutoff() = %v, %v, want %v", cutoff, ok, want)
+	}
+}
+
+// recordingDeleter records the invoices whose PDFs were deleted
+type recordingDeleter struct{ deleted []*Invoice }
+
+func (d *recordingDeleter) DeletePDF(ctx context.Context, invoice *Invoice) error {
+	d.deleted = append(d.deleted, invoice)
+	return nil
+}
+
+func TestPurgeVoidedPDFs_KeepsByDefault(t *testing.T) {
+	config := createTestConfig()
+	config.EnableS3 = true
+	gen := NewInvoiceGenerator(nil, nil, nil, config)
+	deleter := &recordingDeleter{}
+
+	deleted, err := gen.PurgeVoidedPDFs(context.Background(), deleter, time.Now())
+	if err != nil || deleted != 0 || len(deleter.deleted) != 0 {
+		t.Errorf("PurgeVoidedPDFs() = %d, %v with %d deletions, want nothing purged", deleted, err, len(deleter.deleted))
+	}
+}
@@ -0,0 +1,68 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"log"
+)
+
+// Job names used in lock keys
+const (
+	JobHourlyAggregation = "hourly_aggregation"
+	JobMonthlyBilling    = "monthly_billing"
+)
+
+// AdvisoryLocker serializes billing jobs across engine instances with PostgreSQL advisory locks
+// It protects whole runs; the per-organization idempotency checks still apply inside a run
+type AdvisoryLocker struct {
+	db *sql.DB
+}
+
+// NewAdvisoryLocker creates a locker on the billing database
+func NewAdvisoryLocker(db *sql.DB) *AdvisoryLocker {
+	return &AdvisoryLocker{db: db}
+}
+
+// Key returns the advisory lock key for one run of a job, e.g. ("monthly_billing", "2026-01")
+func Key(job, period string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(job + ":" + period))
+	return int64(h.Sum64())
+}
+
+// TryLock acquires the lock for a job and period without waiting
+// acquired is false when another run holds it. Otherwise release must be called when the run ends.
+// The lock is held by a dedicated connection, so it is also freed if the engine dies mid-run
+func (l *AdvisoryLocker) TryLock(ctx context.Context, job, period string) (release func(), acquired bool, err error) {
+	key := Key(job, period)
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get lock connection: %w", err)
+	}
+
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire %s lock: %w", job, err)
+	}
+
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release = func() {
+		var unlocked bool
+		err := conn.QueryRowContext(context.Background(), "SELECT pg_advisory_unlock($1)", key).Scan(&unlocked)
+		if err != nil || !unlocked {
+			// Session locks outlive pooled connections, so drop the connection to end the session
+			log.Printf("⚠️  Failed to release %s lock for %s, closing its connection: %v", job, period, err)
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
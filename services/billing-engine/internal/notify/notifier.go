@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+)
+
+// Notifier delivers billing run results to operators
+type Notifier interface {
+	// NotifyRun reports the summary of a completed billing run
+	NotifyRun(ctx context.Context, summary *invoice.RunSummary) error
+	// NotifyFailure alerts that a run's error count exceeded the failure threshold
+	NotifyFailure(ctx context.Context, summary *invoice.RunSummary, threshold int) error
+}
+
+// Report sends a run summary, followed by a failure alert when the run had more than threshold errors
+// Both are attempted even if the summary fails to send
+func Report(ctx context.Context, n Notifier, summary *invoice.RunSummary, threshold int) error {
+	err := n.NotifyRun(ctx, summary)
+
+	if summary.ErrorCount() > threshold {
+		err = errors.Join(err, n.NotifyFailure(ctx, summary, threshold))
+	}
+
+	return err
+}
+
+// Multi fans notifications out to several notifiers (e.g. email and Slack)
+// Every notifier is tried; their errors are joined
+type Multi []Notifier
+
+// NotifyRun reports the run summary to every notifier
+func (m Multi) NotifyRun(ctx context.Context, summary *invoice.RunSummary) error {
+	var errs []error
+	for _, n := range m {
+		errs = append(errs, n.NotifyRun(ctx, summary))
+	}
+	return errors.Join(errs...)
+}
+
+// NotifyFailure sends the failure alert to every notifier
+func (m Multi) NotifyFailure(ctx context.Context, summary *invoice.RunSummary, threshold int) error {
+	var errs []error
+	for _, n := range m {
+		errs = append(errs, n.NotifyFailure(ctx, summary, threshold))
+	}
+	return errors.Join(errs...)
+}
+
+// runSummaryEmailer is implemented by invoice.EmailSender
+type runSummaryEmailer interface {
+	SendRunSummaryEmail(ctx context.Context, to string, summary *invoice.RunSummary) error
+}
+
+// EmailNotifier emails run summaries to an operator address
+type EmailNotifier struct {
+	sender runSummaryEmailer
+	to     string
+}
+
+// NewEmailNotifier creates a notifier sending run summaries to an address
+func NewEmailNotifier(sender runSummaryEmailer, to string) *EmailNotifier {
+	return &EmailNotifier{sender: sender, to: to}
+}
+
+// NotifyRun emails the run summary
+func (e *EmailNotifier) NotifyRun(ctx context.Context, summary *invoice.RunSummary) error {
+	return e.sender.SendRunSummaryEmail(ctx, e.to, summary)
+}
+
+// NotifyFailure sends nothing: the summary email already lists every failure
+func (e *EmailNotifier) NotifyFailure(ctx context.Context, summary *invoice.RunSummary, threshold int) error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+)
+
+// webhookServer records the JSON payloads posted to it
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads []map[string]interface{}
+}
+
+func newWebhookServer(t *testing.T, status int) *webhookServer {
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Webhook body is not JSON: %v", err)
+		}
+		s.mu.Lock()
+		s.payloads = append(s.payloads, payload)
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// fakeNotifier records calls and returns err
+type fakeNotifier struct {
+	runs     int
+	failures int
+	err      error
+}
+
+func (f *fakeNotifier) NotifyRun(ctx context.Context, summary *invoice.RunSummary) error {
+	f.runs++
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyFailure(ctx context.Context, summary *invoice.RunSummary, threshold int) error {
+	f.failures++
+	return f.err
+}
+
+// testRunSummary returns a January 2026 run with the given number of email failures
+func testRunSummary(emailFailures int) *invoice.RunSummary {
+	summary := &invoice.RunSummary{
+		BillingMonth: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Invoices:     &invoice.InvoiceSummary{SuccessCount: 10, TotalRevenue: 99000},
+		Processed:    10,
+		EmailErrors:  emailFailures,
+		Duration:     3 * time.Second,
+	}
+	for i := 0; i < emailFailures; i++ {
+		summary.Failures = append(summary.Failures, invoice.InvoiceError{
+			OrganizationID: "org-" + string(rune('a'+i)),
+			Operation:      "email",
+			Error:          errors.New("mailbox unavailable"),
+		})
+	}
+	return summary
+}
+
+func TestReport_FailuresAboveThresholdAlert(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	webhook := NewWebhookNotifier(server.URL, FormatJSON)
+
+	if err := Report(context.Background(), webhook, testRunSummary(3), 2); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+
+	if len(server.payloads) != 2 {
+		t.Fatalf("Webhook received %d payloads, want the summary and the alert", len(server.payloads))
+	}
+	if got := server.payloads[0]["event"]; got != EventRunCompleted {
+		t.Errorf("First payload event = %v, want %s", got, EventRunCompleted)
+	}
+
+	alert := server.payloads[1]
+	if alert["event"] != EventRunFailed {
+		t.Errorf("Alert event = %v, want %s", alert["event"], EventRunFailed)
+	}
+	if alert["error_count"] != 3.0 || alert["failure_threshold"] != 2.0 || alert["billing_month"] != "2026-01" {
+		t.Errorf("Alert = error_count %v, failure_threshold %v, billing_month %v, want 3, 2, 2026-01",
+			alert["error_count"], alert["failure_threshold"], alert["billing_month"])
+	}
+	if failures, _ := alert["failures"].([]interface{}); len(failures) != 3 {
+		t.Errorf("Alert lists %d failures, want 3", len(failures))
+	}
+}
+
+func TestReport_AtThresholdNoAlert(t *testing.T) {
+	notifier := &fakeNotifier{}
+
+	if err := Report(context.Background(), notifier, testRunSummary(2), 2); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if notifier.runs != 1 || notifier.failures != 0 {
+		t.Errorf("Report() sent %d summaries and %d alerts, want 1 and 0", notifier.runs, notifier.failures)
+	}
+}
+
+func TestMulti_NotifiesAll(t *testing.T) {
+	failing := &fakeNotifier{err: errors.New("webhook down")}
+	working := &fakeNotifier{}
+
+	err := Report(context.Background(), Multi{failing, working}, testRunSummary(1), 0)
+	if err == nil || !strings.Contains(err.Error(), "webhook down") {
+		t.Errorf("Report() error = %v, want the failing notifier's error", err)
+	}
+
+	// One notifier failing does not stop the others
+	if working.runs != 1 || working.failures != 1 {
+		t.Errorf("Working notifier got %d summaries and %d alerts, want 1 and 1", working.runs, working.failures)
+	}
+}
+
+func TestWebhookNotifier_SlackFormat(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	webhook := NewWebhookNotifier(server.URL, FormatSlack)
+
+	if err := webhook.NotifyFailure(context.Background(), testRunSummary(1), 0); err != nil {
+		t.Fatalf("NotifyFailure() error = %v", err)
+	}
+
+	payload := server.payloads[0]
+	text, _ := payload["text"].(string)
+	if !strings.Contains(text, "2026-01") || !strings.Contains(text, "1 errors") {
+		t.Errorf("Slack text = %q, want the month and error count", text)
+	}
+	if blocks, _ := payload["blocks"].([]interface{}); len(blocks) != 3 {
+		t.Errorf("Slack message has %d blocks, want title, figures and failures", len(blocks))
+	}
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	server := newWebhookServer(t, http.StatusInternalServerError)
+
+	err := NewWebhookNotifier(server.URL, FormatJSON).NotifyRun(context.Background(), testRunSummary(0))
+	if err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Errorf("NotifyRun() error = %v, want status 500", err)
+	}
+}
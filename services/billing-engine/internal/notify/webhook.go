@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/invoice"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// Webhook payload formats
+const (
+	FormatJSON  = "json"  // Generic JSON event (WebhookEvent)
+	FormatSlack = "slack" // Slack incoming webhook with Block Kit blocks
+)
+
+// Webhook event types
+const (
+	EventRunCompleted = "billing.run_completed"
+	EventRunFailed    = "billing.run_failed"
+)
+
+// webhookTimeout bounds each webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// maxListedFailures caps the failures included in one payload
+const maxListedFailures = 20
+
+// WebhookEvent is the JSON payload posted in FormatJSON
+type WebhookEvent struct {
+	Event             string           `json:"event"`
+	BillingMonth      string           `json:"billing_month"` // YYYY-MM
+	InvoicesGenerated int              `json:"invoices_generated"`
+	InvoicesSkipped   int              `json:"invoices_skipped"`
+	InvoicesProcessed int              `json:"invoices_processed"`
+	TotalRevenueCents int64            `json:"total_revenue_cents"`
+	Errors            map[string]int   `json:"errors"` // Per step: generate, pdf, upload, stripe, email
+	ErrorCount        int              `json:"error_count"`
+	FailureThreshold  *int             `json:"failure_threshold,omitempty"` // Set on billing.run_failed
+	Failures          []WebhookFailure `json:"failures,omitempty"`
+	DurationSeconds   float64          `json:"duration_seconds"`
+	DryRun            bool             `json:"dry_run"`
+}
+
+// WebhookFailure is one failed organization in a WebhookEvent
+type WebhookFailure struct {
+	OrganizationID string `json:"organization_id"`
+	Operation      string `json:"operation"`
+	Error          string `json:"error"`
+}
+
+// WebhookNotifier posts billing run results to a webhook, e.g. a Slack incoming webhook
+type WebhookNotifier struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url in FormatJSON or FormatSlack
+func NewWebhookNotifier(url, format string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		format: format,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// NotifyRun posts the run summary
+func (w *WebhookNotifier) NotifyRun(ctx context.Context, summary *invoice.RunSummary) error {
+	return w.post(ctx, newWebhookEvent(EventRunCompleted, summary, nil))
+}
+
+// NotifyFailure posts a failure alert
+func (w *WebhookNotifier) NotifyFailure(ctx context.Context, summary *invoice.RunSummary, threshold int) error {
+	return w.post(ctx, newWebhookEvent(EventRunFailed, summary, &threshold))
+}
+
+// post encodes an event in the configured format and delivers it
+func (w *WebhookNotifier) post(ctx context.Context, event *WebhookEvent) error {
+	var payload interface{} = event
+	if w.format == FormatSlack {
+		payload = slackMessage(event)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post %s webhook: %w", event.Event, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post %s webhook: status %d", event.Event, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// newWebhookEvent builds the event for a run; threshold is set for failure alerts
+func newWebhookEvent(eventType string, summary *invoice.RunSummary, threshold *int) *WebhookEvent {
+	event := &WebhookEvent{
+		Event:             eventType,
+		BillingMonth:      summary.BillingMonth.Format("2006-01"),
+		InvoicesGenerated: summary.Invoices.SuccessCount,
+		InvoicesSkipped:   summary.Invoices.SkippedCount,
+		InvoicesProcessed: summary.Processed,
+		TotalRevenueCents: summary.Invoices.TotalRevenue,
+		Errors: map[string]int{
+			"generate": summary.Invoices.FailureCount,
+			"pdf":      summary.PDFErrors,
+			"upload":   summary.S3Errors,
+			"stripe":   summary.StripeErrors,
+			"email":    summary.EmailErrors,
+		},
+		ErrorCount:       summary.ErrorCount(),
+		FailureThreshold: threshold,
+		DurationSeconds:  summary.Duration.Seconds(),
+		DryRun:           summary.DryRun,
+	}
+
+	for _, failures := range [][]invoice.InvoiceError{summary.Invoices.Errors, summary.Failures} {
+		for _, f := range failures {
+			if len(event.Failures) == maxListedFailures {
+				return event
+			}
+			event.Failures = append(event.Failures, WebhookFailure{
+				OrganizationID: f.OrganizationID,
+				Operation:      f.Operation,
+				Error:          fmt.Sprint(f.Error),
+			})
+		}
+	}
+
+	return event
+}
+
+// slackMessage renders an event as a Slack Block Kit message, with text as the notification fallback
+func slackMessage(event *WebhookEvent) map[string]interface{} {
+	title := fmt.Sprintf(":white_check_mark: Billing run for %s completed", event.BillingMonth)
+	if event.Event == EventRunFailed {
+		title = fmt.Sprintf(":rotating_light: Billing run for %s had %d errors (threshold %d)",
+			event.BillingMonth, event.ErrorCount, *event.FailureThreshold)
+	}
+	if event.DryRun {
+		title += " [dry run]"
+	}
+
+	fields := fmt.Sprintf("*Generated:* %d   *Skipped:* %d   *Processed:* %d\n*Revenue:* %s   *Errors:* %d   *Duration:* %s",
+		event.InvoicesGenerated, event.InvoicesSkipped, event.InvoicesProcessed,
+		pricing.FormatPrice(event.TotalRevenueCents), event.ErrorCount,
+		time.Duration(event.DurationSeconds*float64(time.Second)).Round(time.Second))
+
+	blocks := []map[string]interface{}{
+		{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": "*" + title + "*"}},
+		{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": fields}},
+	}
+
+	if len(event.Failures) > 0 {
+		var lines []string
+		for _, f := range event.Failures {
+			lines = append(lines, fmt.Sprintf("• `%s` %s: %s", f.OrganizationID, f.Operation, f.Error))
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": strings.Join(lines, "\n")},
+		})
+	}
+
+	return map[string]interface{}{"text": title, "blocks": blocks}
+}
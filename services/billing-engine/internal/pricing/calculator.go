@@ -8,16 +8,23 @@ import (
 
 // Calculator handles pricing calculations for billing
 type Calculator struct {
-	// Could add configuration here if needed
+	rounding RoundingMode // How fractional cents of overage and metric charges are rounded
 }
 
-// NewCalculator creates a new pricing calculator
+// NewCalculator creates a new pricing calculator that rounds fractional cents down
 func NewCalculator() *Calculator {
-	return &Calculator{}
+	return &Calculator{rounding: DefaultRoundingMode}
+}
+
+// WithRounding sets how fractional cents of overage and metric charges are rounded
+func (c *Calculator) WithRounding(mode RoundingMode) *Calculator {
+	c.rounding = mode
+	return c
 }
 
 // CalculateCharge calculates the billing charge for a given usage and pricing tier
 // Returns: baseCharge, overageCharge, totalCharge (all in cents)
+// totalCharge is raised to the tier's MinimumChargeCents when usage falls short of it
 func (c *Calculator) CalculateCharge(
 	tier PricingTier,
 	usageUnits int64,
@@ -25,51 +32,108 @@ func (c *Calculator) CalculateCharge(
 	// Base price is always charged (monthly subscription fee)
 	baseCharge = tier.BasePrice
 
-	// Calculate overage if usage exceeds included units
-	if usageUnits > tier.IncludedUnits {
-		overageUnits := usageUnits - tier.IncludedUnits
+	if tier.MaxUnits > 0 && usageUnits > tier.MaxUnits {
+		log.Printf("[Calculator] WARNING: Usage %d exceeded hard limit %d for tier %s",
+			usageUnits, tier.MaxUnits, tier.Name)
+	}
+
+	// Calculate overage charge
+	// OverageRate is in cents per 1000 units
+	// Formula: (overageUnits * OverageRate) / 1000, with the fractional cent rounded by c.rounding
+	overageCharge = c.rounding.mulDiv(billableOverageUnits(tier, usageUnits), tier.OverageRate, 1000)
 
-		// Check hard limit (if MaxUnits > 0)
-		if tier.MaxUnits > 0 && usageUnits > tier.MaxUnits {
-			// Usage exceeded hard limit, cap at MaxUnits
-			overageUnits = tier.MaxUnits - tier.IncludedUnits
-			log.Printf("[Calculator] WARNING: Usage %d exceeded hard limit %d for tier %s",
-				usageUnits, tier.MaxUnits, tier.Name)
-		}
+	totalCharge = baseCharge + overageCharge + trueUpCharge(tier, baseCharge+overageCharge)
+
+	return baseCharge, overageCharge, totalCharge
+}
 
-		// Calculate overage charge
-		// OverageRate is in cents per 1000 units
-		// Formula: (overageUnits / 1000) * OverageRate
-		overageCharge = (overageUnits * tier.OverageRate) / 1000
-	} else {
-		overageCharge = 0
+// billableOverageUnits returns the units billed at the overage rate
+// Usage beyond a hard cap (MaxUnits > 0) is not billed, and the tier's grace
+// allowance above the included units is waived; the result is never negative
+func billableOverageUnits(tier PricingTier, usageUnits int64) int64 {
+	if tier.MaxUnits > 0 && usageUnits > tier.MaxUnits {
+		usageUnits = tier.MaxUnits
 	}
 
-	totalCharge = baseCharge + overageCharge
+	units := usageUnits - tier.IncludedUnits - tier.GraceUnits()
+	if units < 0 {
+		return 0
+	}
+	return units
+}
 
-	return baseCharge, overageCharge, totalCharge
+// trueUpCharge returns the shortfall between a usage-based charge and the tier's minimum
+func trueUpCharge(tier PricingTier, charge int64) int64 {
+	if charge >= tier.MinimumChargeCents {
+		return 0
+	}
+	return tier.MinimumChargeCents - charge
+}
+
+// CalculateMetricCharges prices the tier's metrics (e.g. bandwidth) for the period's usage
+// OverageUnits are billed at Rate per UnitSize, prorated to the cent and rounded by c.rounding
+func (c *Calculator) CalculateMetricCharges(tier PricingTier, usage UsageData) []MetricCharge {
+	if len(tier.Metrics) == 0 {
+		return nil
+	}
+
+	charges := make([]MetricCharge, 0, len(tier.Metrics))
+	for _, m := range tier.Metrics {
+		charge := MetricCharge{
+			Metric:        m.Metric,
+			UsedUnits:     usage.MetricUsage(m.Metric),
+			IncludedUnits: m.IncludedUnits,
+			UnitSize:      m.UnitSize,
+			UnitName:      m.UnitName,
+			Rate:          m.Rate,
+		}
+		if charge.UnitSize <= 0 {
+			charge.UnitSize = DefaultMetricUnitSize
+		}
+		if charge.UnitName == "" {
+			charge.UnitName = "GB"
+		}
+
+		if charge.UsedUnits > charge.IncludedUnits {
+			charge.OverageUnits = charge.UsedUnits - charge.IncludedUnits
+			charge.Charge = c.rounding.mulDiv(charge.OverageUnits, charge.Rate, charge.UnitSize)
+		}
+
+		charges = append(charges, charge)
+	}
+
+	return charges
 }
 
 // CalculateBilling performs full billing calculation for an organization
+// Metric charges are added to the request charges before the minimum commitment is applied,
+// and a breached latency SLA is credited last
 func (c *Calculator) CalculateBilling(
 	orgPlan OrganizationPlan,
 	usage UsageData,
 ) BillingCalculation {
-	baseCharge, overageCharge, totalCharge := c.CalculateCharge(
+	baseCharge, overageCharge, _ := c.CalculateCharge(
 		orgPlan.Tier,
 		usage.BillableUnits,
 	)
 
-	overageUnits := int64(0)
-	if usage.BillableUnits > orgPlan.Tier.IncludedUnits {
-		overageUnits = usage.BillableUnits - orgPlan.Tier.IncludedUnits
+	metricCharges := c.CalculateMetricCharges(orgPlan.Tier, usage)
+	usageCharge := baseCharge + overageCharge
+	for _, m := range metricCharges {
+		usageCharge += m.Charge
+	}
+	trueUp := trueUpCharge(orgPlan.Tier, usageCharge)
 
-		// Respect hard limit
-		if orgPlan.Tier.MaxUnits > 0 && overageUnits > (orgPlan.Tier.MaxUnits - orgPlan.Tier.IncludedUnits) {
-			overageUnits = orgPlan.Tier.MaxUnits - orgPlan.Tier.IncludedUnits
-		}
+	// A breached SLA credits part of everything billed for the period, commitment included
+	slaCredit := c.CalculateSLACredit(orgPlan.Tier, usage, usageCharge+trueUp)
+	totalCharge := usageCharge + trueUp
+	if slaCredit != nil {
+		totalCharge -= slaCredit.Credit
 	}
 
+	// Respects the hard limit and excludes the grace allowance, matching the overage charge
+	overageUnits := billableOverageUnits(orgPlan.Tier, usage.BillableUnits)
+
 	return BillingCalculation{
 		OrganizationID:  usage.OrganizationID,
 		Month:           usage.Month,
@@ -80,6 +144,9 @@ func (c *Calculator) CalculateBilling(
 		OverageUnits:    overageUnits,
 		OverageRate:     orgPlan.Tier.OverageRate,
 		OverageCharge:   overageCharge,
+		TrueUpCharge:    trueUp,
+		MetricCharges:   metricCharges,
+		SLACredit:       slaCredit,
 		TotalCharge:     totalCharge,
 		CalculatedAt:    time.Now(),
 		Status:          "pending",
@@ -131,7 +198,7 @@ type PlanComparison struct {
 func (c *Calculator) ComparePlans(usageUnits int64) []PlanComparison {
 	comparisons := make([]PlanComparison, 0)
 
-	for planID, plan := range PredefinedPlans {
+	for planID, plan := range currentPlans() {
 		if !plan.Active {
 			continue
 		}
@@ -165,6 +232,7 @@ func (c *Calculator) ComparePlans(usageUnits int64) []PlanComparison {
 }
 
 // GetRecommendedPlan recommends the most cost-effective plan for a given usage
+// Plans that cannot serve the usage (a hard limit below it, or no overage allowed) are skipped
 func (c *Calculator) GetRecommendedPlan(averageMonthlyUnits int64) (string, Plan, error) {
 	comparisons := c.ComparePlans(averageMonthlyUnits)
 
@@ -173,16 +241,21 @@ func (c *Calculator) GetRecommendedPlan(averageMonthlyUnits int64) (string, Plan
 	}
 
 	// Find the cheapest plan
-	cheapestIdx := 0
-	cheapestCharge := comparisons[0].TotalCharge
-
+	cheapestIdx := -1
 	for i, comp := range comparisons {
-		if comp.TotalCharge < cheapestCharge {
+		plan, _ := GetPlanByID(comp.PlanID)
+		if c.ValidateUsage(plan.Tier, averageMonthlyUnits) != nil {
+			continue
+		}
+		if cheapestIdx < 0 || comp.TotalCharge < comparisons[cheapestIdx].TotalCharge {
 			cheapestIdx = i
-			cheapestCharge = comp.TotalCharge
 		}
 	}
 
+	if cheapestIdx < 0 {
+		return "", Plan{}, fmt.Errorf("no active plan allows %d units per month", averageMonthlyUnits)
+	}
+
 	recommendedPlanID := comparisons[cheapestIdx].PlanID
 	plan, _ := GetPlanByID(recommendedPlanID)
 
@@ -206,6 +279,12 @@ func (c *Calculator) ValidateUsage(tier PricingTier, usageUnits int64) error {
 	return nil
 }
 
+// IsOverHardCap reports whether usage has exhausted a hard-capped plan
+// Plans without a cap (MaxUnits = 0) are never over
+func (c *Calculator) IsOverHardCap(tier PricingTier, usageUnits int64) bool {
+	return tier.MaxUnits > 0 && usageUnits >= tier.MaxUnits
+}
+
 // ProjectAnnualCost projects the annual cost based on average monthly usage
 func (c *Calculator) ProjectAnnualCost(planID string, avgMonthlyUnits int64) (int64, error) {
 	plan, exists := GetPlanByID(planID)
@@ -200,6 +200,204 @@ func TestCalculateCharge_Business(t *testing.T) {
 	}
 }
 
+func TestCalculateBilling_MinimumCommitment(t *testing.T) {
+	calc := NewCalculator()
+
+	// Starter with a $100/month commitment
+	tier := PredefinedPlans["starter"].Tier
+	tier.MinimumChargeCents = 10000
+	orgPlan := OrganizationPlan{OrganizationID: "org-committed", PlanName: "Starter", Tier: tier}
+
+	tests := []struct {
+		name           string
+		usage          int64
+		expectedTrueUp int64
+		expectedTotal  int64
+	}{
+		{
+			name:           "Below minimum (true-up applied)",
+			usage:          600000, // $29 + 100K * $0.005/1K = $34
+			expectedTrueUp: 6600,
+			expectedTotal:  10000,
+		},
+		{
+			name:           "Above minimum (no true-up)",
+			usage:          20000000, // $29 + 19.5M * $0.005/1K = $1,004
+			expectedTrueUp: 0,
+			expectedTotal:  100400,
+		},
+		{
+			name:           "Exactly at minimum",
+			usage:          1920000, // $29 + 1.42M * $0.005/1K = $100
+			expectedTrueUp: 0,
+			expectedTotal:  10000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, total := calc.CalculateCharge(tier, tt.usage)
+			if total != tt.expectedTotal {
+				t.Errorf("CalculateCharge total: got %d, want %d", total, tt.expectedTotal)
+			}
+
+			billing := calc.CalculateBilling(orgPlan, UsageData{OrganizationID: "org-committed", BillableUnits: tt.usage})
+			if billing.TrueUpCharge != tt.expectedTrueUp {
+				t.Errorf("True-up: got %d, want %d", billing.TrueUpCharge, tt.expectedTrueUp)
+			}
+			if billing.TotalCharge != tt.expectedTotal {
+				t.Errorf("Total charge: got %d, want %d", billing.TotalCharge, tt.expectedTotal)
+			}
+			if billing.BasePrice+billing.OverageCharge+billing.TrueUpCharge != billing.TotalCharge {
+				t.Errorf("Breakdown %d + %d + %d does not sum to total %d",
+					billing.BasePrice, billing.OverageCharge, billing.TrueUpCharge, billing.TotalCharge)
+			}
+		})
+	}
+}
+
+func TestCalculateCharge_GraceAllowance(t *testing.T) {
+	calc := NewCalculator()
+
+	// 10% grace = 50K units free above the 500K included, hard cap at 600K
+	tier := PricingTier{Name: "Grace", BasePrice: 2900, IncludedUnits: 500000, OverageRate: 5, MaxUnits: 600000, GracePercent: 10}
+	orgPlan := OrganizationPlan{OrganizationID: "org-grace", PlanName: "Grace", Tier: tier}
+
+	tests := []struct {
+		name                 string
+		usage                int64
+		expectedOverageUnits int64
+		expectedOverage      int64
+	}{
+		{
+			name:                 "Within grace (no overage)",
+			usage:                540000,
+			expectedOverageUnits: 0,
+			expectedOverage:      0,
+		},
+		{
+			name:                 "Exactly at grace limit",
+			usage:                550000,
+			expectedOverageUnits: 0,
+			expectedOverage:      0,
+		},
+		{
+			name:                 "Beyond grace (only the excess billed)",
+			usage:                580000, // 30K * $0.05/1K = $1.50
+			expectedOverageUnits: 30000,
+			expectedOverage:      150,
+		},
+		{
+			name:                 "Above hard cap (capped, then grace applied)",
+			usage:                900000, // capped at 600K, 50K * $0.05/1K = $2.50
+			expectedOverageUnits: 50000,
+			expectedOverage:      250,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, over, total := calc.CalculateCharge(tier, tt.usage)
+			if over != tt.expectedOverage {
+				t.Errorf("Overage charge: got %d, want %d", over, tt.expectedOverage)
+			}
+			if total != base+tt.expectedOverage {
+				t.Errorf("Total charge: got %d, want %d", total, base+tt.expectedOverage)
+			}
+
+			billing := calc.CalculateBilling(orgPlan, UsageData{OrganizationID: "org-grace", BillableUnits: tt.usage})
+			if billing.OverageUnits != tt.expectedOverageUnits {
+				t.Errorf("Overage units: got %d, want %d", billing.OverageUnits, tt.expectedOverageUnits)
+			}
+			if billing.OverageCharge != tt.expectedOverage {
+				t.Errorf("Billing overage charge: got %d, want %d", billing.OverageCharge, tt.expectedOverage)
+			}
+		})
+	}
+}
+
+func TestCalculateBilling_MetricCharges(t *testing.T) {
+	calc := NewCalculator()
+
+	// 10 GB out included, then $0.09/GB; inbound free up to 50 GB, then $0.02/GB
+	tier := PricingTier{
+		Name:          "Metered",
+		BasePrice:     2900,
+		IncludedUnits: 500000,
+		OverageRate:   5,
+		Metrics: []MetricPricing{
+			{Metric: MetricBytesOut, IncludedUnits: 10 * DefaultMetricUnitSize, Rate: 9},
+			{Metric: MetricBytesIn, IncludedUnits: 50 * DefaultMetricUnitSize, Rate: 2},
+		},
+	}
+	orgPlan := OrganizationPlan{OrganizationID: "org-metered", PlanName: "Metered", Tier: tier}
+
+	tests := []struct {
+		name          string
+		usage         UsageData
+		expectedOut   int64
+		expectedIn    int64
+		expectedTotal int64
+	}{
+		{
+			name:          "Within included bandwidth",
+			usage:         UsageData{BillableUnits: 400000, BytesIn: 1 * DefaultMetricUnitSize, BytesOut: 9 * DefaultMetricUnitSize},
+			expectedOut:   0,
+			expectedIn:    0,
+			expectedTotal: 2900,
+		},
+		{
+			name:          "Outbound overage",
+			usage:         UsageData{BillableUnits: 400000, BytesOut: 110 * DefaultMetricUnitSize}, // 100 GB * $0.09
+			expectedOut:   900,
+			expectedIn:    0,
+			expectedTotal: 3800,
+		},
+		{
+			name:          "Requests and both directions over",
+			usage:         UsageData{BillableUnits: 600000, BytesIn: 100 * DefaultMetricUnitSize, BytesOut: 12500000000}, // $5 + $1 + 2.5 GB * $0.09
+			expectedOut:   22, // $0.225 rounded down
+			expectedIn:    100,
+			expectedTotal: 2900 + 500 + 22 + 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			billing := calc.CalculateBilling(orgPlan, tt.usage)
+			if len(billing.MetricCharges) != 2 {
+				t.Fatalf("Metric charges: got %d, want 2", len(billing.MetricCharges))
+			}
+			out, in := billing.MetricCharges[0], billing.MetricCharges[1]
+			if out.Metric != MetricBytesOut || out.Charge != tt.expectedOut {
+				t.Errorf("Outbound charge: got %s %d, want %s %d", out.Metric, out.Charge, MetricBytesOut, tt.expectedOut)
+			}
+			if in.Metric != MetricBytesIn || in.Charge != tt.expectedIn {
+				t.Errorf("Inbound charge: got %s %d, want %s %d", in.Metric, in.Charge, MetricBytesIn, tt.expectedIn)
+			}
+			if out.UnitName != "GB" || out.UnitSize != DefaultMetricUnitSize {
+				t.Errorf("Unit: got %s/%d, want GB/%d", out.UnitName, out.UnitSize, DefaultMetricUnitSize)
+			}
+			if billing.TotalCharge != tt.expectedTotal {
+				t.Errorf("Total charge: got %d, want %d", billing.TotalCharge, tt.expectedTotal)
+			}
+		})
+	}
+
+	// Bandwidth counts toward the minimum commitment
+	tier.MinimumChargeCents = 5000
+	billing := calc.CalculateBilling(OrganizationPlan{Tier: tier}, UsageData{BytesOut: 110 * DefaultMetricUnitSize})
+	if billing.TrueUpCharge != 5000-3800 || billing.TotalCharge != 5000 {
+		t.Errorf("True-up: got %d (total %d), want %d (total 5000)", billing.TrueUpCharge, billing.TotalCharge, 5000-3800)
+	}
+
+	// Plans without metrics bill requests only
+	billing = calc.CalculateBilling(OrganizationPlan{Tier: PricingTier{BasePrice: 2900, IncludedUnits: 500000}}, UsageData{BytesOut: 1e12})
+	if billing.MetricCharges != nil || billing.TotalCharge != 2900 {
+		t.Errorf("Without metrics: got %+v (total %d), want none (total 2900)", billing.MetricCharges, billing.TotalCharge)
+	}
+}
+
 func TestFormatPrice(t *testing.T) {
 	tests := []struct {
 		cents    int64
@@ -321,9 +519,9 @@ func TestProjectAnnualCost(t *testing.T) {
 		t.Fatalf("ProjectAnnualCost failed: %v", err)
 	}
 
-	// Starter: $29 base + 250K overage at $5/1M = $29 + $1.25 = $30.25 = 3025 cents/month
-	// Annual: 3025 * 12 = 36300 cents
-	expectedAnnual := int64(36300)
+	// Starter: $29 base + 250K overage at 5 cents/1K = $29 + $12.50 = $41.50 = 4150 cents/month
+	// Annual: 4150 * 12 = 49800 cents
+	expectedAnnual := int64(49800)
 
 	if annualCost != expectedAnnual {
 		t.Errorf("Annual cost: got %d, want %d", annualCost, expectedAnnual)
@@ -348,6 +546,31 @@ func TestValidateUsage(t *testing.T) {
 	}
 }
 
+func TestIsOverHardCap(t *testing.T) {
+	calc := NewCalculator()
+
+	tests := []struct {
+		name     string
+		planID   string
+		usage    int64
+		expected bool
+	}{
+		{"Free under cap", "free", 99999, false},
+		{"Free at cap", "free", 100000, true},
+		{"Free over cap", "free", 150000, true},
+		{"Unlimited plan never capped", "starter", 100000000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tier := PredefinedPlans[tt.planID].Tier
+			if got := calc.IsOverHardCap(tier, tt.usage); got != tt.expected {
+				t.Errorf("IsOverHardCap(%s, %d) = %v, want %v", tt.planID, tt.usage, got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestComparePlans(t *testing.T) {
 	calc := NewCalculator()
 
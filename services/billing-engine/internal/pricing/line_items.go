@@ -0,0 +1,133 @@
+package pricing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RequestsMetric tags request overage line items, which have no pricing metric of their own
+const RequestsMetric = "requests"
+
+// LineItem is one charge of a billing calculation as it is listed on the invoice
+type LineItem struct {
+	Description    string `json:"description"`
+	Quantity       int64  `json:"quantity"`
+	UnitPriceCents int64  `json:"unit_price_cents"`
+	AmountCents    int64  `json:"amount_cents"`
+	ItemType       string `json:"item_type"` // "base_plan", "overage", "true_up" or "credit"
+	Metric         string `json:"-"`         // RequestsMetric or a MetricPricing metric for usage charges, else ""
+}
+
+// BuildLineItems turns a billing calculation into one line item per charge for the period
+// [periodStart, periodEnd], with request overage described in unitLabel (e.g. "requests").
+// It is the single source of invoice line items: generated invoices and previews both use it,
+// and the items always add up to calc.TotalCharge
+func BuildLineItems(calc BillingCalculation, periodStart, periodEnd time.Time, unitLabel string) []LineItem {
+	items := make([]LineItem, 0)
+
+	// Base plan charge
+	if calc.BasePrice > 0 {
+		items = append(items, LineItem{
+			Description:    fmt.Sprintf("%s Plan - %s", calc.PlanName, FormatPeriod(periodStart, periodEnd)),
+			Quantity:       1,
+			UnitPriceCents: calc.BasePrice,
+			AmountCents:    calc.BasePrice,
+			ItemType:       "base_plan",
+		})
+	}
+
+	// Overage charge
+	if calc.OverageCharge > 0 {
+		items = append(items, LineItem{
+			Description:    fmt.Sprintf("Usage overage - %s %s over limit", formatLineItemUsage(calc.OverageUnits), unitLabel),
+			Quantity:       calc.OverageUnits,
+			UnitPriceCents: UnitPrice(calc.OverageCharge, calc.OverageUnits),
+			AmountCents:    calc.OverageCharge,
+			ItemType:       "overage",
+			Metric:         RequestsMetric,
+		})
+	}
+
+	// Bandwidth and other metered usage
+	for _, m := range calc.MetricCharges {
+		if m.Charge <= 0 {
+			continue
+		}
+		items = append(items, LineItem{
+			Description: fmt.Sprintf("%s - %s %s over %s %s included at %s/%s",
+				MetricLabel(m.Metric),
+				formatMetricUnits(m.OverageUnits, m.UnitSize), m.UnitName,
+				formatMetricUnits(m.IncludedUnits, m.UnitSize), m.UnitName,
+				FormatPrice(m.Rate), m.UnitName),
+			Quantity:       1,
+			UnitPriceCents: m.Charge,
+			AmountCents:    m.Charge,
+			ItemType:       "overage",
+			Metric:         m.Metric,
+		})
+	}
+
+	// Minimum commitment shortfall
+	if calc.TrueUpCharge > 0 {
+		items = append(items, LineItem{
+			Description:    "Minimum commitment true-up",
+			Quantity:       1,
+			UnitPriceCents: calc.TrueUpCharge,
+			AmountCents:    calc.TrueUpCharge,
+			ItemType:       "true_up",
+		})
+	}
+
+	// Credit for a breached latency SLA, already deducted from the total
+	if calc.SLACredit != nil && calc.SLACredit.Credit > 0 {
+		sla := calc.SLACredit
+		items = append(items, LineItem{
+			Description: fmt.Sprintf("SLA credit - %s of requests over %dms (SLA allows %s), %s off",
+				formatPercent(sla.BreachPercent), sla.LatencyThresholdMs,
+				formatPercent(sla.MaxBreachPercent), formatPercent(sla.CreditPercent)),
+			Quantity:       1,
+			UnitPriceCents: -sla.Credit,
+			AmountCents:    -sla.Credit,
+			ItemType:       "credit",
+		})
+	}
+
+	return items
+}
+
+// FormatPeriod formats an invoice period with inclusive dates, e.g. "Jan 1 - Jan 31, 2026"
+func FormatPeriod(start, end time.Time) string {
+	return start.Format("Jan 2") + " - " + end.Format("Jan 2, 2006")
+}
+
+// UnitPrice returns the per-unit price of a line item, rounded down (0 without a quantity)
+func UnitPrice(total, quantity int64) int64 {
+	if quantity == 0 {
+		return 0
+	}
+	return total / quantity
+}
+
+// formatLineItemUsage formats a unit count with a K/M suffix and one decimal, e.g. "500.0K"
+func formatLineItemUsage(units int64) string {
+	if units >= 1000000 {
+		return fmt.Sprintf("%.1fM", float64(units)/1000000.0)
+	} else if units >= 1000 {
+		return fmt.Sprintf("%.1fK", float64(units)/1000.0)
+	}
+	return fmt.Sprintf("%d", units)
+}
+
+// formatMetricUnits formats a base-unit quantity (e.g. bytes) in billed units of unitSize
+func formatMetricUnits(units, unitSize int64) string {
+	if unitSize <= 0 {
+		unitSize = DefaultMetricUnitSize
+	}
+	return fmt.Sprintf("%.2f", float64(units)/float64(unitSize))
+}
+
+// formatPercent formats a percentage with up to two decimals, e.g. "2.5%" or "10%"
+func formatPercent(percent float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", percent), "0"), ".") + "%"
+}
@@ -0,0 +1,49 @@
+package pricing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestBuildLineItems tests the items of a calculation with every kind of charge
+func TestBuildLineItems(t *testing.T) {
+	calc := NewCalculator()
+	tier := PredefinedPlans["growth"].Tier
+	tier.MinimumChargeCents = 20000
+	tier.Metrics = []MetricPricing{{Metric: MetricBytesOut, IncludedUnits: 10000000000, Rate: 9}}
+	tier.SLA = &SLAPolicy{LatencyThresholdMs: 500, MaxBreachPercent: 1, CreditPercent: 10}
+	plan := OrganizationPlan{OrganizationID: "org-1", PlanName: "Growth", Tier: tier}
+
+	usage := UsageData{
+		OrganizationID:      "org-1",
+		BillableUnits:       2500000,
+		BytesOut:            15000000000,
+		SLARequests:         2500000,
+		SLABreachedRequests: 50000,
+	}
+	result := calc.CalculateBilling(plan, usage)
+
+	start := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 4, 14, 23, 59, 59, 0, time.UTC)
+	got := BuildLineItems(result, start, end, "messages")
+
+	want := []LineItem{
+		{Description: "Growth Plan - Mar 15 - Apr 14, 2026", Quantity: 1, UnitPriceCents: 9900, AmountCents: 9900, ItemType: "base_plan"},
+		{Description: "Usage overage - 500.0K messages over limit", Quantity: 500000, UnitPriceCents: 0, AmountCents: 2000, ItemType: "overage", Metric: RequestsMetric},
+		{Description: "Data transfer out - 5.00 GB over 10.00 GB included at $0.09/GB", Quantity: 1, UnitPriceCents: 45, AmountCents: 45, ItemType: "overage", Metric: MetricBytesOut},
+		{Description: "Minimum commitment true-up", Quantity: 1, UnitPriceCents: 8055, AmountCents: 8055, ItemType: "true_up"},
+		{Description: "SLA credit - 2% of requests over 500ms (SLA allows 1%), 10% off", Quantity: 1, UnitPriceCents: -2000, AmountCents: -2000, ItemType: "credit"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildLineItems() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	var sum int64
+	for _, item := range got {
+		sum += item.AmountCents
+	}
+	if sum != result.TotalCharge {
+		t.Errorf("Line items sum to %d, want the %d total", sum, result.TotalCharge)
+	}
+}
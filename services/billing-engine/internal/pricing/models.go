@@ -6,12 +6,63 @@ import (
 
 // PricingTier represents a subscription tier with base price and overage rates
 type PricingTier struct {
-	Name           string `json:"name"`
-	BasePrice      int64  `json:"base_price"`       // in cents (e.g., 9900 = $99.00)
-	IncludedUnits  int64  `json:"included_units"`   // number of free units included
-	OverageRate    int64  `json:"overage_rate"`     // cents per 1000 units (e.g., 10 = $0.01 per 1000)
-	MaxUnits       int64  `json:"max_units"`        // 0 = unlimited, >0 = hard cap
-	BillingPeriod  string `json:"billing_period"`   // "monthly" or "yearly"
+	Name               string `json:"name"`
+	BasePrice          int64  `json:"base_price"`           // in cents (e.g., 9900 = $99.00)
+	IncludedUnits      int64  `json:"included_units"`       // number of free units included
+	OverageRate        int64  `json:"overage_rate"`         // cents per 1000 units (e.g., 10 = $0.01 per 1000)
+	MaxUnits           int64  `json:"max_units"`            // 0 = unlimited, >0 = hard cap
+	BillingPeriod      string `json:"billing_period"`       // "monthly" or "yearly"
+	MinimumChargeCents int64  `json:"minimum_charge_cents"` // committed spend per period, 0 = none
+
+	// GracePercent is a goodwill buffer above the included units that is not billed,
+	// as a percentage of IncludedUnits (e.g. 5 = the first 5% of overage is free, 0 = none)
+	GracePercent float64 `json:"grace_percent"`
+
+	// Metrics prices usage other than requests (e.g. bandwidth), billed on top of the request charges
+	Metrics []MetricPricing `json:"metrics,omitempty"`
+
+	// SLA is the plan's latency SLA, credited on the invoice when breached (nil = no SLA)
+	SLA *SLAPolicy `json:"sla,omitempty"`
+}
+
+// Usage metrics that can be priced with MetricPricing, besides billable requests
+const (
+	MetricBytesIn  = "bytes_in"  // Request body bytes (data transfer in)
+	MetricBytesOut = "bytes_out" // Response body bytes (data transfer out)
+)
+
+// DefaultMetricUnitSize bills byte metrics per decimal gigabyte
+const DefaultMetricUnitSize = 1000000000
+
+// metricLabels names each metric on invoices
+var metricLabels = map[string]string{
+	MetricBytesIn:  "Data transfer in",
+	MetricBytesOut: "Data transfer out",
+}
+
+// MetricLabel returns the invoice name of a metric, or the metric itself if unknown
+func MetricLabel(metric string) string {
+	if label, ok := metricLabels[metric]; ok {
+		return label
+	}
+	return metric
+}
+
+// MetricPricing prices one usage metric: usage above IncludedUnits is billed at Rate per UnitSize
+type MetricPricing struct {
+	Metric        string `json:"metric"`         // MetricBytesIn or MetricBytesOut
+	IncludedUnits int64  `json:"included_units"` // Free quantity per period, in the metric's base unit (bytes)
+	UnitSize      int64  `json:"unit_size"`      // Base units per billed unit (0 = DefaultMetricUnitSize)
+	UnitName      string `json:"unit_name"`      // Billed unit shown on invoices (default "GB")
+	Rate          int64  `json:"rate"`           // cents per billed unit
+}
+
+// GraceUnits returns the overage units the tier does not bill, rounded down
+func (t PricingTier) GraceUnits() int64 {
+	if t.GracePercent <= 0 {
+		return 0
+	}
+	return int64(float64(t.IncludedUnits) * t.GracePercent / 100)
 }
 
 // Plan represents a complete pricing plan with metadata
@@ -26,6 +77,38 @@ type Plan struct {
 	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
+// PricingOverride is negotiated pricing for one organization
+// Nil fields keep the value from the subscribed plan's tier
+type PricingOverride struct {
+	OrganizationID     string `json:"organization_id"`
+	BasePrice          *int64 `json:"base_price,omitempty"` // cents
+	IncludedUnits      *int64 `json:"included_units,omitempty"`
+	OverageRate        *int64 `json:"overage_rate,omitempty"` // cents per 1000 units
+	MinimumChargeCents *int64 `json:"minimum_charge_cents,omitempty"`
+}
+
+// Apply returns tier with the override's non-nil fields replacing the plan values
+func (o PricingOverride) Apply(tier PricingTier) PricingTier {
+	if o.BasePrice != nil {
+		tier.BasePrice = *o.BasePrice
+	}
+	if o.IncludedUnits != nil {
+		tier.IncludedUnits = *o.IncludedUnits
+	}
+	if o.OverageRate != nil {
+		tier.OverageRate = *o.OverageRate
+	}
+	if o.MinimumChargeCents != nil {
+		tier.MinimumChargeCents = *o.MinimumChargeCents
+	}
+
+	// A hard cap below the negotiated allowance would produce negative overage
+	if tier.MaxUnits > 0 && tier.IncludedUnits > tier.MaxUnits {
+		tier.MaxUnits = tier.IncludedUnits
+	}
+	return tier
+}
+
 // OrganizationPlan represents an organization's subscription
 type OrganizationPlan struct {
 	OrganizationID string    `json:"organization_id"`
@@ -35,16 +118,38 @@ type OrganizationPlan struct {
 	StartDate      time.Time `json:"start_date"`
 	NextBillingDate time.Time `json:"next_billing_date"`
 	Status         string    `json:"status"` // "active", "paused", "cancelled"
+	CustomPricing  bool      `json:"custom_pricing"` // Tier includes a PricingOverride
 }
 
 // UsageData represents monthly usage for billing
 type UsageData struct {
 	OrganizationID string    `json:"organization_id"`
-	Month          time.Time `json:"month"`
+	Month          time.Time `json:"month"`        // First day of the month the billing period starts in
+	PeriodStart    time.Time `json:"period_start"` // Billing period [PeriodStart, PeriodEnd), anchor to anchor
+	PeriodEnd      time.Time `json:"period_end"`
 	BillableUnits  int64     `json:"billable_units"`
 	TotalRequests  int64     `json:"total_requests"`
 	AvgResponseTime float64  `json:"avg_response_time_ms"`
 	ErrorCount     int64     `json:"error_count"`
+
+	// Bandwidth of billable requests
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+
+	// Requests measured against the tier's SLA latency threshold; only loaded for plans with an SLA
+	SLARequests         int64 `json:"sla_requests,omitempty"`
+	SLABreachedRequests int64 `json:"sla_breached_requests,omitempty"`
+}
+
+// MetricUsage returns the period's usage of a metric (0 for metrics that are not measured)
+func (u UsageData) MetricUsage(metric string) int64 {
+	switch metric {
+	case MetricBytesIn:
+		return u.BytesIn
+	case MetricBytesOut:
+		return u.BytesOut
+	}
+	return 0
 }
 
 // BillingCalculation represents the result of a billing calculation
@@ -57,9 +162,16 @@ type BillingCalculation struct {
 	BasePrice       int64     `json:"base_price"`        // cents
 	IncludedUnits   int64     `json:"included_units"`
 	UsedUnits       int64     `json:"used_units"`
-	OverageUnits    int64     `json:"overage_units"`     // units beyond included
+	OverageUnits    int64     `json:"overage_units"`     // billed units beyond included (and grace)
 	OverageRate     int64     `json:"overage_rate"`      // cents per 1000 units
 	OverageCharge   int64     `json:"overage_charge"`    // cents
+	TrueUpCharge    int64     `json:"true_up_charge"`    // cents to reach the tier's minimum charge
+
+	// Usage other than requests (e.g. bandwidth), one per priced metric; included in TotalCharge
+	MetricCharges []MetricCharge `json:"metric_charges,omitempty"`
+
+	// Credit for a breached latency SLA (nil if the plan has none or it was met); deducted from TotalCharge
+	SLACredit *SLACredit `json:"sla_credit,omitempty"`
 
 	// Total
 	TotalCharge     int64     `json:"total_charge"`      // cents
@@ -69,7 +181,20 @@ type BillingCalculation struct {
 	Status          string    `json:"status"`            // "pending", "invoiced", "paid"
 }
 
+// MetricCharge is the billed usage of one priced metric
+type MetricCharge struct {
+	Metric        string `json:"metric"`
+	UsedUnits     int64  `json:"used_units"`     // Base units (bytes)
+	IncludedUnits int64  `json:"included_units"`
+	OverageUnits  int64  `json:"overage_units"`
+	UnitSize      int64  `json:"unit_size"`
+	UnitName      string `json:"unit_name"`
+	Rate          int64  `json:"rate"`   // cents per billed unit
+	Charge        int64  `json:"charge"` // cents
+}
+
 // PredefinedPlans contains common pricing tiers
+// These are the seed defaults (migration 021) and the fallback when no PlanRepository has loaded
 var PredefinedPlans = map[string]Plan{
 	"free": {
 		ID:          "plan_free",
@@ -181,8 +306,12 @@ var PredefinedPlans = map[string]Plan{
 	},
 }
 
-// GetPlanByID retrieves a predefined plan by ID
+// GetPlanByID retrieves a plan by ID from the plan repository when one is set,
+// otherwise from PredefinedPlans
 func GetPlanByID(planID string) (Plan, bool) {
+	if repo := loadedRepository(); repo != nil {
+		return repo.Get(planID)
+	}
 	plan, exists := PredefinedPlans[planID]
 	return plan, exists
 }
@@ -190,7 +319,7 @@ func GetPlanByID(planID string) (Plan, bool) {
 // GetActivePlans returns all active plans
 func GetActivePlans() []Plan {
 	plans := make([]Plan, 0)
-	for _, plan := range PredefinedPlans {
+	for _, plan := range currentPlans() {
 		if plan.Active {
 			plans = append(plans, plan)
 		}
@@ -0,0 +1,75 @@
+package pricing
+
+import (
+	"math"
+	"time"
+)
+
+// MaxBillingAnchorDay is the latest day of the month a billing cycle may be anchored to
+const MaxBillingAnchorDay = 31
+
+// NormalizeAnchorDay returns the anchor day to bill on; 0 (unset) or out-of-range values
+// fall back to 1, i.e. calendar-month billing
+func NormalizeAnchorDay(anchorDay int) int {
+	if anchorDay < 1 || anchorDay > MaxBillingAnchorDay {
+		return 1
+	}
+	return anchorDay
+}
+
+// anchorDate returns the anchor day in the given month, clamped to the month's last day
+// (anchor 31 falls on Feb 28, or Feb 29 in leap years)
+func anchorDate(year int, month time.Month, anchorDay int) time.Time {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if anchorDay > lastDay {
+		anchorDay = lastDay
+	}
+	return time.Date(year, month, anchorDay, 0, 0, 0, 0, time.UTC)
+}
+
+// BillingPeriod returns the billing period that starts in the given month: [start, end) from
+// the anchor day in that month to the anchor day in the next. Periods are identified by the
+// month they start in, so anchor 1 gives calendar months
+func BillingPeriod(anchorDay int, month time.Time) (start, end time.Time) {
+	anchorDay = NormalizeAnchorDay(anchorDay)
+	start = anchorDate(month.Year(), month.Month(), anchorDay)
+	end = anchorDate(month.Year(), month.Month()+1, anchorDay)
+	return start, end
+}
+
+// BillingPeriodContaining returns the billing period that t falls in
+func BillingPeriodContaining(anchorDay int, t time.Time) (start, end time.Time) {
+	t = t.UTC()
+	start, end = BillingPeriod(anchorDay, t)
+	if t.Before(start) {
+		// Before this month's anchor: still in the period that began last month
+		return BillingPeriod(anchorDay, start.AddDate(0, 0, -start.Day()))
+	}
+	return start, end
+}
+
+// PeriodClosingIn returns the month of the billing period that ends during month: the month
+// itself for calendar billing, or the month before for anchored periods (Jan 15 - Feb 14 closes in February)
+func PeriodClosingIn(anchorDay int, month time.Time) time.Time {
+	monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if NormalizeAnchorDay(anchorDay) == 1 {
+		return monthStart
+	}
+	return monthStart.AddDate(0, -1, 0)
+}
+
+// Prorate returns the price difference for the part of [periodStart, periodEnd) left at
+// effectiveAt when switching from fromCents to toCents, rounded half away from zero:
+// positive is charged, negative credited
+func Prorate(fromCents, toCents int64, periodStart, periodEnd, effectiveAt time.Time) int64 {
+	total := periodEnd.Sub(periodStart)
+	remaining := periodEnd.Sub(effectiveAt)
+	if total <= 0 || remaining <= 0 {
+		return 0
+	}
+	if remaining > total {
+		remaining = total
+	}
+
+	return int64(math.Round(float64(toCents-fromCents) * remaining.Seconds() / total.Seconds()))
+}
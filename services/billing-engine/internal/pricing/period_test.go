@@ -0,0 +1,127 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestBillingPeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		anchorDay int
+		month     time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"Unset anchor bills calendar months", 0, date(2026, 3, 1), date(2026, 3, 1), date(2026, 4, 1)},
+		{"Anchor 1 is a calendar month", 1, date(2026, 12, 1), date(2026, 12, 1), date(2027, 1, 1)},
+		{"Mid-month anchor", 15, date(2026, 3, 1), date(2026, 3, 15), date(2026, 4, 15)},
+		{"Month argument need not be normalized", 15, date(2026, 3, 27), date(2026, 3, 15), date(2026, 4, 15)},
+		{"Anchor 31 ends on the last day of February", 31, date(2026, 1, 1), date(2026, 1, 31), date(2026, 2, 28)},
+		{"Anchor 31 starts February on its last day", 31, date(2026, 2, 1), date(2026, 2, 28), date(2026, 3, 31)},
+		{"Anchor 31 in a leap year", 31, date(2028, 2, 1), date(2028, 2, 29), date(2028, 3, 31)},
+		{"Anchor 31 in a 30-day month", 31, date(2026, 4, 1), date(2026, 4, 30), date(2026, 5, 31)},
+		{"Anchor 30 across February", 30, date(2026, 1, 1), date(2026, 1, 30), date(2026, 2, 28)},
+		{"Out-of-range anchor falls back to calendar months", 45, date(2026, 3, 1), date(2026, 3, 1), date(2026, 4, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := BillingPeriod(tt.anchorDay, tt.month)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("BillingPeriod(%d, %s) = [%s, %s), want [%s, %s)", tt.anchorDay, tt.month.Format("2006-01-02"),
+					start.Format("2006-01-02"), end.Format("2006-01-02"), tt.wantStart.Format("2006-01-02"), tt.wantEnd.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+// TestBillingPeriod_Contiguous tests that consecutive periods neither overlap nor leave gaps
+func TestBillingPeriod_Contiguous(t *testing.T) {
+	for _, anchorDay := range []int{1, 15, 28, 29, 30, 31} {
+		_, prevEnd := BillingPeriod(anchorDay, date(2027, 12, 1))
+		for month := date(2028, 1, 1); month.Year() == 2028; month = month.AddDate(0, 1, 0) {
+			start, end := BillingPeriod(anchorDay, month)
+			if !start.Equal(prevEnd) {
+				t.Errorf("Anchor %d: period %s starts %s, previous ended %s", anchorDay, month.Format("2006-01"),
+					start.Format("2006-01-02"), prevEnd.Format("2006-01-02"))
+			}
+			prevEnd = end
+		}
+	}
+}
+
+func TestBillingPeriodContaining(t *testing.T) {
+	tests := []struct {
+		name      string
+		anchorDay int
+		at        time.Time
+		wantStart time.Time
+	}{
+		{"After the anchor", 15, time.Date(2026, 3, 20, 8, 0, 0, 0, time.UTC), date(2026, 3, 15)},
+		{"On the anchor", 15, date(2026, 3, 15), date(2026, 3, 15)},
+		{"Before the anchor", 15, time.Date(2026, 3, 14, 23, 59, 59, 0, time.UTC), date(2026, 2, 15)},
+		{"Before the anchor in January", 15, date(2026, 1, 3), date(2025, 12, 15)},
+		{"Early March with anchor 31", 31, date(2026, 3, 5), date(2026, 2, 28)},
+		{"Calendar month", 1, time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC), date(2026, 3, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := BillingPeriodContaining(tt.anchorDay, tt.at)
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("Period start = %s, want %s", start.Format("2006-01-02"), tt.wantStart.Format("2006-01-02"))
+			}
+			if tt.at.Before(start) || !tt.at.Before(end) {
+				t.Errorf("%s is outside [%s, %s)", tt.at, start, end)
+			}
+		})
+	}
+}
+
+func TestPeriodClosingIn(t *testing.T) {
+	for _, anchorDay := range []int{0, 1, 15, 31} {
+		for month := date(2026, 1, 1); month.Year() == 2026; month = month.AddDate(0, 1, 0) {
+			periodMonth := PeriodClosingIn(anchorDay, month)
+			_, end := BillingPeriod(anchorDay, periodMonth)
+
+			// The period's last second falls in month
+			last := end.Add(-time.Second)
+			if last.Year() != month.Year() || last.Month() != month.Month() {
+				t.Errorf("Anchor %d: period %s closes %s, not in %s", anchorDay, periodMonth.Format("2006-01"),
+					last.Format("2006-01-02"), month.Format("2006-01"))
+			}
+		}
+	}
+}
+
+func TestProrate(t *testing.T) {
+	start, end := BillingPeriod(15, date(2026, 2, 1)) // Feb 15 - Mar 15: 28 days
+
+	tests := []struct {
+		name     string
+		from, to int64
+		at       time.Time
+		want     int64
+	}{
+		{"Start of period", 2900, 9900, start, 7000},
+		{"Halfway", 2900, 9900, date(2026, 3, 1), 3500},
+		{"Downgrade halfway", 9900, 2900, date(2026, 3, 1), -3500},
+		{"Rounds half away from zero", 0, 1, date(2026, 3, 1), 1},
+		{"End of period", 2900, 9900, end, 0},
+		{"Before the period", 2900, 9900, start.AddDate(0, 0, -3), 7000},
+		{"Same price", 2900, 2900, date(2026, 2, 18), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Prorate(tt.from, tt.to, start, end, tt.at); got != tt.want {
+				t.Errorf("Prorate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package pricing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultPlanRefreshInterval is how often the plan cache is reloaded from pricing_plans
+const DefaultPlanRefreshInterval = 5 * time.Minute
+
+// PlanRepository caches plans loaded from the pricing_plans table
+// Plans are keyed by pricing_plans.id, which organization_subscriptions.plan_id references
+type PlanRepository struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	plans  map[string]Plan
+	loaded bool
+}
+
+// NewPlanRepository creates a plan repository; call Refresh to load it
+func NewPlanRepository(db *sql.DB) *PlanRepository {
+	return &PlanRepository{
+		db:    db,
+		plans: make(map[string]Plan),
+	}
+}
+
+// Refresh reloads every plan from the database, replacing the cache atomically
+// On error the previously loaded plans are kept
+func (r *PlanRepository) Refresh(ctx context.Context) error {
+	query := `
+		SELECT
+			id,
+			name,
+			COALESCE(description, '') as description,
+			base_price_cents,
+			included_units,
+			overage_rate_cents,
+			COALESCE(max_units, 0) as max_units,
+			COALESCE(overage_grace_percent, 0) as overage_grace_percent,
+			COALESCE(metric_pricing, '[]') as metric_pricing,
+			sla_policy,
+			features,
+			COALESCE(is_active, true) as is_active,
+			created_at,
+			updated_at
+		FROM pricing_plans
+		ORDER BY display_order, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query pricing plans: %w", err)
+	}
+	defer rows.Close()
+
+	plans := make(map[string]Plan)
+	for rows.Next() {
+		plan := Plan{Tier: PricingTier{BillingPeriod: "monthly"}}
+		var features pq.StringArray
+		var metrics, slaPolicy []byte
+		var createdAt, updatedAt sql.NullTime
+
+		err := rows.Scan(
+			&plan.ID,
+			&plan.Name,
+			&plan.Description,
+			&plan.Tier.BasePrice,
+			&plan.Tier.IncludedUnits,
+			&plan.Tier.OverageRate,
+			&plan.Tier.MaxUnits,
+			&plan.Tier.GracePercent,
+			&metrics,
+			&slaPolicy,
+			&features,
+			&plan.Active,
+			&createdAt,
+			&updatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan pricing plan: %w", err)
+		}
+
+		if plan.Tier.Metrics, err = ParseMetricPricing(metrics); err != nil {
+			return fmt.Errorf("invalid metric pricing for plan %s: %w", plan.ID, err)
+		}
+		if plan.Tier.SLA, err = ParseSLAPolicy(slaPolicy); err != nil {
+			return fmt.Errorf("invalid SLA policy for plan %s: %w", plan.ID, err)
+		}
+
+		plan.Tier.Name = plan.Name
+		plan.Features = []string(features)
+		plan.CreatedAt = createdAt.Time
+		plan.UpdatedAt = updatedAt.Time
+		plans[plan.ID] = plan
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating pricing plans: %w", err)
+	}
+
+	if len(plans) == 0 {
+		return fmt.Errorf("pricing_plans table is empty")
+	}
+
+	r.mu.Lock()
+	r.plans = plans
+	r.loaded = true
+	r.mu.Unlock()
+
+	log.Printf("[PlanRepository] Loaded %d pricing plans", len(plans))
+	return nil
+}
+
+// ParseMetricPricing decodes a pricing_plans.metric_pricing value (nil for no metrics)
+func ParseMetricPricing(raw []byte) ([]MetricPricing, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var metrics []MetricPricing
+	if err := json.Unmarshal(raw, &metrics); err != nil {
+		return nil, err
+	}
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+	return metrics, nil
+}
+
+// Get returns a cached plan by ID
+func (r *PlanRepository) Get(planID string) (Plan, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	plan, exists := r.plans[planID]
+	return plan, exists
+}
+
+// Plans returns a copy of every cached plan keyed by ID, or nil if nothing has loaded yet
+func (r *PlanRepository) Plans() map[string]Plan {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.loaded {
+		return nil
+	}
+
+	plans := make(map[string]Plan, len(r.plans))
+	for id, plan := range r.plans {
+		plans[id] = plan
+	}
+	return plans
+}
+
+// Loaded reports whether at least one refresh has succeeded
+func (r *PlanRepository) Loaded() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loaded
+}
+
+// defaultRepository is consulted by GetPlanByID and GetActivePlans when set
+var (
+	defaultRepositoryMu sync.RWMutex
+	defaultRepository   *PlanRepository
+)
+
+// SetPlanRepository makes the package-level plan lookups read from repo
+// Until repo has loaded (or if repo is nil) lookups fall back to PredefinedPlans
+func SetPlanRepository(repo *PlanRepository) {
+	defaultRepositoryMu.Lock()
+	defaultRepository = repo
+	defaultRepositoryMu.Unlock()
+}
+
+// loadedRepository returns the package-level repository if it has loaded plans
+func loadedRepository() *PlanRepository {
+	defaultRepositoryMu.RLock()
+	repo := defaultRepository
+	defaultRepositoryMu.RUnlock()
+
+	if repo == nil || !repo.Loaded() {
+		return nil
+	}
+	return repo
+}
+
+// currentPlans returns the repository's plans if loaded, otherwise PredefinedPlans
+func currentPlans() map[string]Plan {
+	if repo := loadedRepository(); repo != nil {
+		return repo.Plans()
+	}
+	return PredefinedPlans
+}
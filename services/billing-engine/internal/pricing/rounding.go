@@ -0,0 +1,65 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// RoundingMode decides how fractional cents of overage and metric charges are rounded
+type RoundingMode string
+
+// Rounding modes, as configured with OVERAGE_ROUNDING
+const (
+	RoundDown     RoundingMode = "down"      // Drop the fraction: 7.5 -> 7 (the historical behavior)
+	RoundHalfUp   RoundingMode = "half_up"   // Half a cent or more rounds up: 7.5 -> 8, 7.4 -> 7
+	RoundHalfEven RoundingMode = "half_even" // Banker's rounding, ties to the even cent: 7.5 -> 8, 6.5 -> 6
+)
+
+// DefaultRoundingMode keeps charges identical to the truncating calculator
+const DefaultRoundingMode = RoundDown
+
+// ParseRoundingMode validates a rounding mode name ("" = DefaultRoundingMode)
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	switch mode := RoundingMode(s); mode {
+	case "":
+		return DefaultRoundingMode, nil
+	case RoundDown, RoundHalfUp, RoundHalfEven:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown rounding mode %q (want %s, %s or %s)", s, RoundDown, RoundHalfUp, RoundHalfEven)
+	}
+}
+
+// mulDiv returns a*b/den rounded according to the mode
+// a and b must not be negative and den must be positive; the product may exceed int64
+// (e.g. bytes times a per-GB rate), and results beyond int64 saturate at math.MaxInt64
+func (m RoundingMode) mulDiv(a, b, den int64) int64 {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	d := uint64(den)
+	if hi >= d {
+		return math.MaxInt64
+	}
+	q, r := bits.Div64(hi, lo, d)
+
+	// Compare the remainder with half the divisor without overflowing 2*r
+	switch m {
+	case RoundHalfUp:
+		if r >= d-r {
+			q++
+		}
+	case RoundHalfEven:
+		if r > d-r || (r == d-r && q%2 == 1) {
+			q++
+		}
+	}
+
+	if q > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(q)
+}
@@ -0,0 +1,112 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseRoundingMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected RoundingMode
+		wantErr  bool
+	}{
+		{"", RoundDown, false},
+		{"down", RoundDown, false},
+		{"half_up", RoundHalfUp, false},
+		{"half_even", RoundHalfEven, false},
+		{"HALF_UP", "", true},
+		{"nearest", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseRoundingMode(tt.input)
+			if (err != nil) != tt.wantErr || mode != tt.expected {
+				t.Errorf("ParseRoundingMode(%q) = %q, %v, want %q (error %v)", tt.input, mode, err, tt.expected, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCalculateCharge_Rounding(t *testing.T) {
+	// Rate 5 cents per 1000 units: every 100 units is half a cent
+	tier := PricingTier{Name: "Rounding", BasePrice: 2900, IncludedUnits: 0, OverageRate: 5}
+
+	tests := []struct {
+		name     string
+		usage    int64
+		expected map[RoundingMode]int64
+	}{
+		{
+			name:     "Exact cents",
+			usage:    2000, // 10 cents
+			expected: map[RoundingMode]int64{RoundDown: 10, RoundHalfUp: 10, RoundHalfEven: 10},
+		},
+		{
+			name:     "Half cent, odd below",
+			usage:    1500, // 7.5 cents
+			expected: map[RoundingMode]int64{RoundDown: 7, RoundHalfUp: 8, RoundHalfEven: 8},
+		},
+		{
+			name:     "Half cent, even below",
+			usage:    1300, // 6.5 cents
+			expected: map[RoundingMode]int64{RoundDown: 6, RoundHalfUp: 7, RoundHalfEven: 6},
+		},
+		{
+			name:     "Just below half a cent",
+			usage:    1499, // 7.495 cents
+			expected: map[RoundingMode]int64{RoundDown: 7, RoundHalfUp: 7, RoundHalfEven: 7},
+		},
+		{
+			name:     "Just above half a cent",
+			usage:    1301, // 6.505 cents
+			expected: map[RoundingMode]int64{RoundDown: 6, RoundHalfUp: 7, RoundHalfEven: 7},
+		},
+		{
+			name:     "Under one cent",
+			usage:    100, // 0.5 cents
+			expected: map[RoundingMode]int64{RoundDown: 0, RoundHalfUp: 1, RoundHalfEven: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		for mode, expected := range tt.expected {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				calc := NewCalculator().WithRounding(mode)
+
+				base, over, total := calc.CalculateCharge(tier, tt.usage)
+				if over != expected {
+					t.Errorf("Overage charge: got %d, want %d", over, expected)
+				}
+				if total != base+expected {
+					t.Errorf("Total charge: got %d, want %d", total, base+expected)
+				}
+			})
+		}
+	}
+}
+
+func TestCalculateMetricCharges_Rounding(t *testing.T) {
+	// 2.5 GB over at $0.09/GB = 22.5 cents
+	tier := PricingTier{Metrics: []MetricPricing{{Metric: MetricBytesOut, Rate: 9}}}
+	usage := UsageData{BytesOut: 2500000000}
+
+	expected := map[RoundingMode]int64{RoundDown: 22, RoundHalfUp: 23, RoundHalfEven: 22}
+	for mode, want := range expected {
+		charges := NewCalculator().WithRounding(mode).CalculateMetricCharges(tier, usage)
+		if len(charges) != 1 || charges[0].Charge != want {
+			t.Errorf("%s: charges = %+v, want one charge of %d", mode, charges, want)
+		}
+	}
+}
+
+func TestMulDiv_LargeProduct(t *testing.T) {
+	// 5 EB at $10/GB: the product overflows int64 but the charge does not
+	if got := RoundHalfUp.mulDiv(5000000000000000000, 1000, DefaultMetricUnitSize); got != 5000000000000 {
+		t.Errorf("mulDiv() = %d, want 5000000000000", got)
+	}
+	if got := RoundDown.mulDiv(math.MaxInt64, math.MaxInt64, 1); got != math.MaxInt64 {
+		t.Errorf("mulDiv() overflow = %d, want math.MaxInt64", got)
+	}
+}
@@ -0,0 +1,96 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// SLAPolicy is a plan's latency SLA: when more than MaxBreachPercent of a period's requests
+// take longer than LatencyThresholdMs, CreditPercent of the period's charges is credited
+type SLAPolicy struct {
+	LatencyThresholdMs int64   `json:"latency_threshold_ms"` // e.g. 500 = requests slower than 500ms breach
+	MaxBreachPercent   float64 `json:"max_breach_percent"`   // e.g. 1 = up to 1% of requests may breach
+	CreditPercent      float64 `json:"credit_percent"`       // e.g. 10 = 10% of the period's charges credited
+}
+
+// Validate checks that the policy can be enforced
+func (p SLAPolicy) Validate() error {
+	if p.LatencyThresholdMs <= 0 {
+		return fmt.Errorf("latency_threshold_ms must be positive, got %d", p.LatencyThresholdMs)
+	}
+	if p.MaxBreachPercent < 0 || p.MaxBreachPercent >= 100 {
+		return fmt.Errorf("max_breach_percent must be in [0, 100), got %g", p.MaxBreachPercent)
+	}
+	if p.CreditPercent <= 0 || p.CreditPercent > 100 {
+		return fmt.Errorf("credit_percent must be in (0, 100], got %g", p.CreditPercent)
+	}
+	return nil
+}
+
+// SLACredit is the credit for a billing period that breached the plan's latency SLA
+type SLACredit struct {
+	LatencyThresholdMs int64   `json:"latency_threshold_ms"`
+	MaxBreachPercent   float64 `json:"max_breach_percent"`
+	Requests           int64   `json:"requests"`          // Requests measured in the period
+	BreachedRequests   int64   `json:"breached_requests"` // Requests slower than the threshold
+	BreachPercent      float64 `json:"breach_percent"`    // BreachedRequests as a percentage of Requests
+	CreditPercent      float64 `json:"credit_percent"`
+	Credit             int64   `json:"credit"` // cents, deducted from the period's charges
+}
+
+// BreachPercent returns the percentage of requests slower than the SLA threshold (0 without requests)
+func (u UsageData) BreachPercent() float64 {
+	if u.SLARequests <= 0 {
+		return 0
+	}
+	return float64(u.SLABreachedRequests) * 100 / float64(u.SLARequests)
+}
+
+// CalculateSLACredit returns the credit owed on charge (cents) when the period's breach rate is
+// above the tier's SLA limit, rounded to the nearest cent and never more than charge
+// Returns nil for tiers without an SLA and periods that met it
+func (c *Calculator) CalculateSLACredit(tier PricingTier, usage UsageData, charge int64) *SLACredit {
+	if tier.SLA == nil || usage.SLARequests <= 0 {
+		return nil
+	}
+
+	breachPercent := usage.BreachPercent()
+	if breachPercent <= tier.SLA.MaxBreachPercent {
+		return nil
+	}
+
+	credit := int64(0)
+	if charge > 0 {
+		credit = int64(math.Round(float64(charge) * tier.SLA.CreditPercent / 100))
+		if credit > charge {
+			credit = charge
+		}
+	}
+
+	return &SLACredit{
+		LatencyThresholdMs: tier.SLA.LatencyThresholdMs,
+		MaxBreachPercent:   tier.SLA.MaxBreachPercent,
+		Requests:           usage.SLARequests,
+		BreachedRequests:   usage.SLABreachedRequests,
+		BreachPercent:      breachPercent,
+		CreditPercent:      tier.SLA.CreditPercent,
+		Credit:             credit,
+	}
+}
+
+// ParseSLAPolicy decodes a pricing_plans.sla_policy value (nil for plans without an SLA)
+func ParseSLAPolicy(raw []byte) (*SLAPolicy, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var policy SLAPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return nil, err
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,109 @@
+package pricing
+
+import (
+	"testing"
+)
+
+// slaTier is the enterprise plan with a 300ms latency SLA for 99.5% of requests, crediting 10%
+func slaTier() PricingTier {
+	tier := PredefinedPlans["enterprise"].Tier
+	tier.SLA = &SLAPolicy{LatencyThresholdMs: 300, MaxBreachPercent: 0.5, CreditPercent: 10}
+	return tier
+}
+
+// TestCalculateBilling_SLABreached tests that a period over the breach limit is credited
+func TestCalculateBilling_SLABreached(t *testing.T) {
+	calc := NewCalculator()
+	tier := slaTier()
+	plan := OrganizationPlan{OrganizationID: "org-1", PlanName: "Enterprise", Tier: tier}
+
+	// 1.2% of requests slower than 300ms
+	usage := UsageData{OrganizationID: "org-1", BillableUnits: 500000, SLARequests: 500000, SLABreachedRequests: 6000}
+	result := calc.CalculateBilling(plan, usage)
+
+	charge := result.BasePrice + result.OverageCharge + result.TrueUpCharge
+	if result.SLACredit == nil {
+		t.Fatal("Expected an SLA credit for a breached period")
+	}
+	if result.SLACredit.BreachPercent != 1.2 || result.SLACredit.BreachedRequests != 6000 || result.SLACredit.LatencyThresholdMs != 300 {
+		t.Errorf("SLA credit = %+v, want 6000 breaches (1.2%%) over 300ms", result.SLACredit)
+	}
+	if want := charge / 10; result.SLACredit.Credit != want || result.TotalCharge != charge-want {
+		t.Errorf("Credit = %d, total = %d, want %d off %d", result.SLACredit.Credit, result.TotalCharge, want, charge)
+	}
+}
+
+// TestCalculateBilling_SLAMet tests that periods within the SLA are billed in full
+func TestCalculateBilling_SLAMet(t *testing.T) {
+	calc := NewCalculator()
+
+	tests := []struct {
+		name     string
+		tier     PricingTier
+		requests int64
+		breached int64
+	}{
+		{"Under the limit", slaTier(), 500000, 1000},
+		{"At the limit", slaTier(), 500000, 2500},
+		{"No requests", slaTier(), 0, 0},
+		{"Plan without an SLA", PredefinedPlans["enterprise"].Tier, 500000, 500000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := OrganizationPlan{OrganizationID: "org-1", PlanName: "Enterprise", Tier: tt.tier}
+			usage := UsageData{OrganizationID: "org-1", BillableUnits: 500000, SLARequests: tt.requests, SLABreachedRequests: tt.breached}
+			result := calc.CalculateBilling(plan, usage)
+
+			if result.SLACredit != nil {
+				t.Errorf("SLA credit = %+v, want none", result.SLACredit)
+			}
+			if result.TotalCharge != result.BasePrice+result.OverageCharge+result.TrueUpCharge {
+				t.Errorf("Total = %d, want the full charge", result.TotalCharge)
+			}
+		})
+	}
+}
+
+func TestCalculateSLACredit_Rounding(t *testing.T) {
+	calc := NewCalculator()
+	tier := slaTier()
+	tier.SLA.CreditPercent = 15
+	usage := UsageData{SLARequests: 100, SLABreachedRequests: 100}
+
+	if got := calc.CalculateSLACredit(tier, usage, 9999).Credit; got != 1500 {
+		t.Errorf("15%% of 9999 = %d, want 1500 (rounded to the nearest cent)", got)
+	}
+	if got := calc.CalculateSLACredit(tier, usage, 0).Credit; got != 0 {
+		t.Errorf("Credit on a free period = %d, want 0", got)
+	}
+}
+
+func TestParseSLAPolicy(t *testing.T) {
+	policy, err := ParseSLAPolicy([]byte(`{"latency_threshold_ms": 500, "max_breach_percent": 1, "credit_percent": 10}`))
+	if err != nil {
+		t.Fatalf("ParseSLAPolicy() error = %v", err)
+	}
+	if *policy != (SLAPolicy{LatencyThresholdMs: 500, MaxBreachPercent: 1, CreditPercent: 10}) {
+		t.Errorf("Policy = %+v", policy)
+	}
+
+	for _, raw := range []string{"", "null"} {
+		if policy, err := ParseSLAPolicy([]byte(raw)); err != nil || policy != nil {
+			t.Errorf("ParseSLAPolicy(%q) = %v, %v, want no SLA", raw, policy, err)
+		}
+	}
+
+	invalid := []string{
+		`{"max_breach_percent": 1, "credit_percent": 10}`,                                // No threshold
+		`{"latency_threshold_ms": 500, "max_breach_percent": 100, "credit_percent": 10}`, // Never breachable
+		`{"latency_threshold_ms": 500, "max_breach_percent": 1, "credit_percent": 0}`,    // Nothing credited
+		`{"latency_threshold_ms": 500, "max_breach_percent": 1, "credit_percent": 150}`,
+		`not json`,
+	}
+	for _, raw := range invalid {
+		if _, err := ParseSLAPolicy([]byte(raw)); err == nil {
+			t.Errorf("Expected error for %s", raw)
+		}
+	}
+}
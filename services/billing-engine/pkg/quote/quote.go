@@ -0,0 +1,293 @@
+// Package quote prices an organization's usage exactly as the billing engine invoices it, for
+// services outside the engine (the dashboard API's previews and cost views). It shares the
+// engine's usage queries, effective plans (subscription or default plan, with any pricing
+// override), calculator and line item builder, so a quote never drifts from the invoice
+package quote
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/aggregator"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/billing"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// Billing engine types used by quotes
+type (
+	Plan        = pricing.OrganizationPlan
+	Tier        = pricing.PricingTier
+	Override    = pricing.PricingOverride
+	Usage       = pricing.UsageData
+	Calculation = pricing.BillingCalculation
+	LineItem    = pricing.LineItem
+)
+
+// Errors returned by a Source, for use with errors.Is
+var (
+	ErrNoSubscription = aggregator.ErrNoSubscription // Organization has no plan assignment
+	ErrPlanNotFound   = aggregator.ErrPlanNotFound   // Plan is unknown or no longer active
+)
+
+// Source provides usage and effective plans (implemented by the engine's usage aggregator)
+type Source interface {
+	// GetRealTimeUsage returns usage of the billing period in progress from raw events
+	GetRealTimeUsage(orgID string) (*Usage, error)
+	// GetOrganizationPlan returns the plan the organization is billed on, override applied
+	GetOrganizationPlan(orgID string) (*Plan, error)
+	// GetLatencyBreaches counts requests in [start, end) and those slower than thresholdMs
+	GetLatencyBreaches(orgID string, start, end time.Time, thresholdMs int64) (requests, breached int64, err error)
+	// GetBillingAnchorDay returns the day of the month the organization's billing periods start on
+	GetBillingAnchorDay(orgID string) (int, error)
+	// GetBillableUnits sums billable units from raw events in [start, end)
+	GetBillableUnits(orgID string, start, end time.Time) (int64, error)
+	// GetDailyUsage returns usage per UTC day in [start, end) from the rollups, oldest first
+	GetDailyUsage(orgID string, start, end time.Time) ([]Usage, error)
+	// GetPlanForOrganization returns an active plan with the organization's override applied
+	GetPlanForOrganization(orgID, planID string) (*Plan, error)
+}
+
+// defaultUnitLabel matches the engine's invoice.DefaultUsageUnitLabel
+const defaultUnitLabel = "requests"
+
+// Config holds the billing engine settings a Quoter must share with it to price identically
+type Config struct {
+	DefaultPlanID   string // DEFAULT_PLAN_ID: plan for organizations without a subscription ("" disables)
+	OverageRounding string // OVERAGE_ROUNDING: rounding of fractional usage cents ("" = the engine default)
+	UsageUnitLabel  string // USAGE_UNIT_LABEL: billable unit named in line items ("" = "requests")
+}
+
+// Quoter prices organizations' usage with the billing engine's pricing
+type Quoter struct {
+	source     Source
+	calculator *pricing.Calculator
+	unitLabel  string
+}
+
+// New creates a quoter reading usage and plans from the billing database
+func New(db *sql.DB, cfg Config) (*Quoter, error) {
+	return NewQuoter(aggregator.NewUsageAggregator(db, cfg.DefaultPlanID), cfg)
+}
+
+// NewQuoter creates a quoter over source; cfg.DefaultPlanID is left to the source
+func NewQuoter(source Source, cfg Config) (*Quoter, error) {
+	rounding, err := pricing.ParseRoundingMode(cfg.OverageRounding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid overage rounding: %w", err)
+	}
+
+	unitLabel := cfg.UsageUnitLabel
+	if unitLabel == "" {
+		unitLabel = defaultUnitLabel
+	}
+
+	return &Quoter{
+		source:     source,
+		calculator: pricing.NewCalculator().WithRounding(rounding),
+		unitLabel:  unitLabel,
+	}, nil
+}
+
+// Quote is an organization's billing period in progress, priced as its invoice will be
+type Quote struct {
+	Plan        *Plan // nil for organizations without a plan, whose usage is priced at zero
+	Usage       Usage // Period-to-date usage from raw events
+	PeriodStart time.Time
+	PeriodEnd   time.Time   // Last second of the period, as on invoices
+	Calculation Calculation // Before tax and coupons, which the invoice applies
+	LineItems   []LineItem  // Add up to Calculation.TotalCharge
+	UnitLabel   string      // Billable unit named in the line items
+}
+
+// Quote prices the organization's billing period in progress from its real-time usage
+func (q *Quoter) Quote(orgID string) (*Quote, error) {
+	usage, err := q.source.GetRealTimeUsage(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	quote := &Quote{
+		Usage:       *usage,
+		PeriodStart: usage.PeriodStart,
+		PeriodEnd:   usage.PeriodEnd.Add(-time.Second),
+		LineItems:   []LineItem{},
+		UnitLabel:   q.unitLabel,
+	}
+
+	plan, err := q.source.GetOrganizationPlan(orgID)
+	if errors.Is(err, ErrNoSubscription) {
+		return quote, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+	quote.Plan = plan
+
+	quote.Calculation, err = billing.PriceUsage(q.source, q.calculator, *plan, *usage)
+	if err != nil {
+		return nil, err
+	}
+	quote.LineItems = pricing.BuildLineItems(quote.Calculation, quote.PeriodStart, quote.PeriodEnd, q.unitLabel)
+
+	return quote, nil
+}
+
+// Plan returns the plan the organization is billed on, with its pricing override applied
+// Returns an error wrapping ErrNoSubscription for organizations without a plan
+func (q *Quoter) Plan(orgID string) (*Plan, error) {
+	return q.source.GetOrganizationPlan(orgID)
+}
+
+// PlanFor returns an active plan as the organization would be billed on it after switching,
+// with its pricing override (negotiated rates and minimum commitment) applied
+func (q *Quoter) PlanFor(orgID, planID string) (*Plan, error) {
+	return q.source.GetPlanForOrganization(orgID, planID)
+}
+
+// Price prices a period's usage on the plan; SLA credits apply only if usage carries SLA counts
+func (q *Quoter) Price(plan Plan, usage Usage) Calculation {
+	return q.calculator.CalculateBilling(plan, usage)
+}
+
+// DayCost is one day's usage and the charge it added to its billing period
+type DayCost struct {
+	Date          time.Time // Midnight UTC
+	BillableUnits int64
+	CostCents     int64 // Includes the bandwidth charges the day added
+}
+
+// UsageCosts are the charges of the billing periods a date range touches, attributed to its days
+type UsageCosts struct {
+	Plan       *Plan     // nil for organizations without a plan, whose usage is priced at zero
+	Days       []DayCost // Days with usage in the range, oldest first
+	TotalCents int64     // Day costs plus the zero-usage charge of each billing period touched
+}
+
+// UsageCosts prices the days in [start, end] (inclusive dates) on the organization's current plan
+// Costs are marginal: a day costs what it added to its billing period's total given every earlier
+// day of the period, so days within the included units, or still under a minimum commitment, cost
+// nothing. A period's charge at zero usage (its base price, or its minimum) belongs to no day and
+// is counted once in TotalCents for every period the range touches, so a range of whole periods
+// adds up to their invoice subtotals before tax and coupons
+func (q *Quoter) UsageCosts(orgID string, start, end time.Time) (*UsageCosts, error) {
+	rangeStart := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	rangeEnd := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+
+	anchorDay, err := q.source.GetBillingAnchorDay(orgID)
+	if err != nil {
+		return nil, err
+	}
+	firstStart, _ := pricing.BillingPeriodContaining(anchorDay, rangeStart)
+	_, lastEnd := pricing.BillingPeriodContaining(anchorDay, rangeEnd.Add(-time.Second))
+
+	// Days before the range still count towards their period's allowance and minimum
+	days, err := q.source.GetDailyUsage(orgID, firstStart, lastEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily usage: %w", err)
+	}
+
+	costs := &UsageCosts{Days: []DayCost{}}
+	plan, err := q.source.GetOrganizationPlan(orgID)
+	if err != nil && !errors.Is(err, ErrNoSubscription) {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+	if err == nil {
+		costs.Plan = plan
+	}
+
+	next := 0
+	for periodStart := firstStart; periodStart.Before(lastEnd); {
+		_, periodEnd := pricing.BillingPeriodContaining(anchorDay, periodStart)
+		usage := Usage{
+			OrganizationID: orgID,
+			Month:          time.Date(periodStart.Year(), periodStart.Month(), 1, 0, 0, 0, 0, time.UTC),
+			PeriodStart:    periodStart,
+			PeriodEnd:      periodEnd,
+		}
+
+		// The period's SLA breach rate applies to every day of it
+		var charged int64
+		if costs.Plan != nil {
+			if err := billing.MeasureSLA(q.source, *costs.Plan, &usage); err != nil {
+				return nil, err
+			}
+			charged = q.Price(*costs.Plan, usage).TotalCharge
+			costs.TotalCents += charged
+		}
+
+		for ; next < len(days) && days[next].PeriodStart.Before(periodEnd); next++ {
+			day := days[next]
+			usage.BillableUnits += day.BillableUnits
+			usage.BytesIn += day.BytesIn
+			usage.BytesOut += day.BytesOut
+
+			var cost int64
+			if costs.Plan != nil {
+				total := q.Price(*costs.Plan, usage).TotalCharge
+				cost, charged = total-charged, total
+			}
+
+			if day.PeriodStart.Before(rangeStart) || !day.PeriodStart.Before(rangeEnd) {
+				continue
+			}
+			costs.Days = append(costs.Days, DayCost{
+				Date:          day.PeriodStart,
+				BillableUnits: day.BillableUnits,
+				CostCents:     cost,
+			})
+			costs.TotalCents += cost
+		}
+
+		periodStart = periodEnd
+	}
+
+	return costs, nil
+}
+
+// PlanChange is a switch between plans at EffectiveAt, prorated over the rest of its billing period
+type PlanChange struct {
+	From          Plan
+	To            Plan // As the organization will be billed on it, override applied
+	EffectiveAt   time.Time
+	PeriodStart   time.Time // Billing period containing EffectiveAt, from the org's anchor day
+	PeriodEnd     time.Time // Exclusive
+	UsedUnits     int64     // Billable units from PeriodStart to EffectiveAt
+	ProratedCents int64     // Base price difference for the rest of the period: positive is charged, negative credited
+}
+
+// OverLimit reports whether the period's usage already exceeds the new plan's hard cap
+func (c *PlanChange) OverLimit() bool {
+	return c.To.Tier.MaxUnits > 0 && c.UsedUnits > c.To.Tier.MaxUnits
+}
+
+// PlanChange prices switching the organization from its plan to toPlanID at effectiveAt
+// Returns an error wrapping ErrPlanNotFound if toPlanID is not an active plan
+func (q *Quoter) PlanChange(from Plan, toPlanID string, effectiveAt time.Time) (*PlanChange, error) {
+	to, err := q.source.GetPlanForOrganization(from.OrganizationID, toPlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	anchorDay, err := q.source.GetBillingAnchorDay(from.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	periodStart, periodEnd := pricing.BillingPeriodContaining(anchorDay, effectiveAt)
+
+	units, err := q.source.GetBillableUnits(from.OrganizationID, periodStart, effectiveAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	return &PlanChange{
+		From:          from,
+		To:            *to,
+		EffectiveAt:   effectiveAt,
+		PeriodStart:   periodStart,
+		PeriodEnd:     periodEnd,
+		UsedUnits:     units,
+		ProratedCents: pricing.Prorate(from.Tier.BasePrice, to.Tier.BasePrice, periodStart, periodEnd, effectiveAt),
+	}, nil
+}
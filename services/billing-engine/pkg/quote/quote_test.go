@@ -0,0 +1,328 @@
+package quote
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/billing"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/internal/pricing"
+)
+
+// fakeSource serves one period of usage per org and the org's effective plan
+// It also implements billing.UsageSource, so tests can compare quotes with billing records
+type fakeSource struct {
+	anchors map[string]int
+	usage   map[string]Usage
+	plans   map[string]Plan
+	latency map[string][2]int64 // org -> requests, requests slower than the threshold
+	daily   map[string][]Usage  // org -> usage per day, oldest first
+}
+
+func (f *fakeSource) GetRealTimeUsage(orgID string) (*Usage, error) {
+	usage := f.usage[orgID]
+	usage.OrganizationID = orgID
+	return &usage, nil
+}
+
+func (f *fakeSource) GetMonthlyUsage(orgID string, month time.Time) (*Usage, error) {
+	return f.GetRealTimeUsage(orgID)
+}
+
+func (f *fakeSource) GetBillingAnchorDay(orgID string) (int, error) {
+	return pricing.NormalizeAnchorDay(f.anchors[orgID]), nil
+}
+
+func (f *fakeSource) GetOrganizationPlan(orgID string) (*Plan, error) {
+	plan, ok := f.plans[orgID]
+	if !ok {
+		return nil, fmt.Errorf("%w for organization: %s", ErrNoSubscription, orgID)
+	}
+	return &plan, nil
+}
+
+func (f *fakeSource) GetLatencyBreaches(orgID string, start, end time.Time, thresholdMs int64) (int64, int64, error) {
+	counts := f.latency[orgID]
+	return counts[0], counts[1], nil
+}
+
+func (f *fakeSource) GetDailyUsage(orgID string, start, end time.Time) ([]Usage, error) {
+	var days []Usage
+	for _, day := range f.daily[orgID] {
+		if !day.PeriodStart.Before(start) && day.PeriodStart.Before(end) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (f *fakeSource) GetBillableUnits(orgID string, start, end time.Time) (int64, error) {
+	var units int64
+	for _, day := range f.daily[orgID] {
+		if !day.PeriodStart.Before(start) && day.PeriodStart.Before(end) {
+			units += day.BillableUnits
+		}
+	}
+	return units, nil
+}
+
+func (f *fakeSource) GetPlanForOrganization(orgID, planID string) (*Plan, error) {
+	if planID != "starter" {
+		return nil, fmt.Errorf("%w: %s", ErrPlanNotFound, planID)
+	}
+	tier := pricing.PricingTier{Name: "Starter", BasePrice: 2900, IncludedUnits: 100000, OverageRate: 50, MaxUnits: 2000000}
+	return &Plan{OrganizationID: orgID, PlanID: planID, PlanName: tier.Name, Tier: tier}, nil
+}
+
+func ptr(v int64) *int64 { return &v }
+
+var (
+	march      = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	growthTier = pricing.PricingTier{Name: "Growth", BasePrice: 9900, IncludedUnits: 2000000, OverageRate: 4}
+)
+
+// negotiatedPlan is Growth with a negotiated allowance and rate and a $250 monthly commitment
+func negotiatedPlan() Plan {
+	override := pricing.PricingOverride{IncludedUnits: ptr(1000000), OverageRate: ptr(10), MinimumChargeCents: ptr(25000)}
+	return Plan{OrganizationID: "org-1", PlanID: "growth", PlanName: "Growth", Tier: override.Apply(growthTier), CustomPricing: true}
+}
+
+func newTestSource() *fakeSource {
+	return &fakeSource{
+		usage: map[string]Usage{
+			"org-1":    {Month: march, PeriodStart: march, PeriodEnd: march.AddDate(0, 1, 0), BillableUnits: 3000000},
+			"org-none": {Month: march, PeriodStart: march, PeriodEnd: march.AddDate(0, 1, 0), BillableUnits: 500},
+		},
+		plans: map[string]Plan{"org-1": negotiatedPlan()},
+	}
+}
+
+func newTestQuoter(t *testing.T, source Source) *Quoter {
+	t.Helper()
+	quoter, err := NewQuoter(source, Config{})
+	if err != nil {
+		t.Fatalf("NewQuoter() error = %v", err)
+	}
+	return quoter
+}
+
+// TestQuote_MatchesBillingRecord tests that a quote prices the period as its billing record does
+func TestQuote_MatchesBillingRecord(t *testing.T) {
+	source := newTestSource()
+	quote, err := newTestQuoter(t, source).Quote("org-1")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+
+	// 2M over the negotiated 1M at $0.10 per 1000 is $200; with the $99 base that is $299,
+	// above the $250 commitment, so nothing is trued up
+	if calc := quote.Calculation; calc.OverageCharge != 20000 || calc.TrueUpCharge != 0 || calc.TotalCharge != 29900 {
+		t.Errorf("Calculation = %+v, want $200 overage and $299 total", calc)
+	}
+
+	record, err := billing.NewBillingRecordComputer(source, nil, pricing.NewCalculator()).ComputeRecord("org-1", march.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("ComputeRecord() error = %v", err)
+	}
+	if quote.Calculation.TotalCharge != record.SubtotalCents {
+		t.Errorf("Quote total = %d, want the billing record's %d", quote.Calculation.TotalCharge, record.SubtotalCents)
+	}
+
+	var sum int64
+	for _, item := range quote.LineItems {
+		sum += item.AmountCents
+	}
+	if sum != quote.Calculation.TotalCharge {
+		t.Errorf("Line items sum to %d, want %d", sum, quote.Calculation.TotalCharge)
+	}
+	if want := time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC); !quote.PeriodEnd.Equal(want) {
+		t.Errorf("PeriodEnd = %s, want %s", quote.PeriodEnd, want)
+	}
+}
+
+// TestQuote_MinimumCommitment tests that a period short of the commitment is trued up
+func TestQuote_MinimumCommitment(t *testing.T) {
+	source := newTestSource()
+	usage := source.usage["org-1"]
+	usage.BillableUnits = 1200000
+	source.usage["org-1"] = usage
+
+	quote, err := newTestQuoter(t, source).Quote("org-1")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+
+	// $99 + $20 overage is $131 short of the $250 commitment
+	if calc := quote.Calculation; calc.TrueUpCharge != 13100 || calc.TotalCharge != 25000 {
+		t.Errorf("Calculation = %+v, want a $131 true-up to $250", calc)
+	}
+	if last := quote.LineItems[len(quote.LineItems)-1]; last.ItemType != "true_up" || last.AmountCents != 13100 {
+		t.Errorf("Last line item = %+v, want the true-up", last)
+	}
+}
+
+// TestQuote_SLACredit tests that plans with a latency SLA are measured and credited
+func TestQuote_SLACredit(t *testing.T) {
+	source := newTestSource()
+	plan := source.plans["org-1"]
+	plan.Tier.SLA = &pricing.SLAPolicy{LatencyThresholdMs: 500, MaxBreachPercent: 1, CreditPercent: 10}
+	source.plans["org-1"] = plan
+	source.latency = map[string][2]int64{"org-1": {3000000, 60000}}
+
+	quote, err := newTestQuoter(t, source).Quote("org-1")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+
+	if credit := quote.Calculation.SLACredit; credit == nil || credit.Credit != 2990 || quote.Calculation.TotalCharge != 29900-2990 {
+		t.Errorf("Calculation = %+v, want 10%% of $299 credited", quote.Calculation)
+	}
+}
+
+// TestQuote_NoSubscription tests that usage without a plan is priced at zero
+func TestQuote_NoSubscription(t *testing.T) {
+	quote, err := newTestQuoter(t, newTestSource()).Quote("org-none")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+
+	if quote.Plan != nil || quote.Calculation.TotalCharge != 0 || len(quote.LineItems) != 0 {
+		t.Errorf("Quote = %+v, want no plan and nothing charged", quote)
+	}
+	if quote.Usage.BillableUnits != 500 {
+		t.Errorf("BillableUnits = %d, want the usage still reported", quote.Usage.BillableUnits)
+	}
+}
+
+func TestNewQuoter_Config(t *testing.T) {
+	quoter, err := NewQuoter(newTestSource(), Config{OverageRounding: "half_up", UsageUnitLabel: "messages"})
+	if err != nil {
+		t.Fatalf("NewQuoter() error = %v", err)
+	}
+	quote, err := quoter.Quote("org-1")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	if quote.UnitLabel != "messages" || quote.LineItems[1].Description != "Usage overage - 2.0M messages over limit" {
+		t.Errorf("Line items = %+v, want the overage in messages", quote.LineItems)
+	}
+
+	if _, err := NewQuoter(newTestSource(), Config{OverageRounding: "up"}); err == nil {
+		t.Error("Expected error for an unknown rounding mode")
+	}
+}
+
+// newAnchoredSource bills org-1 on the negotiated plan from the 15th of each month
+func newAnchoredSource() *fakeSource {
+	day := func(month time.Month, d int, units int64) Usage {
+		start := time.Date(2026, month, d, 0, 0, 0, 0, time.UTC)
+		return Usage{OrganizationID: "org-1", PeriodStart: start, PeriodEnd: start.AddDate(0, 0, 1), BillableUnits: units}
+	}
+
+	source := newTestSource()
+	source.anchors = map[string]int{"org-1": 15}
+	source.daily = map[string][]Usage{"org-1": {
+		day(3, 14, 500000), // Last day of the period before
+		day(3, 15, 600000),
+		day(3, 20, 900000), // 1.5M: $99 + $50 overage, still under the $250 minimum
+		day(4, 1, 1500000), // 3.0M: $99 + $200 overage
+		day(4, 14, 100000),
+		day(4, 15, 200000), // First day of the next period
+	}}
+	periodStart := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	source.usage["org-1"] = Usage{Month: march, PeriodStart: periodStart, PeriodEnd: periodStart.AddDate(0, 1, 0), BillableUnits: 3100000}
+	return source
+}
+
+// TestUsageCosts_MatchesBillingRecord tests that the days of a period add up to its billing record
+func TestUsageCosts_MatchesBillingRecord(t *testing.T) {
+	source := newAnchoredSource()
+	costs, err := newTestQuoter(t, source).UsageCosts("org-1", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("UsageCosts() error = %v", err)
+	}
+
+	// Days cost nothing until usage passes the $250 minimum
+	want := []int64{0, 0, 4900, 1000}
+	if len(costs.Days) != len(want) {
+		t.Fatalf("Days = %+v, want the %d days of the Mar 15 period", costs.Days, len(want))
+	}
+	for i, day := range costs.Days {
+		if day.CostCents != want[i] {
+			t.Errorf("Cost on %s = %d, want %d", day.Date.Format("2006-01-02"), day.CostCents, want[i])
+		}
+	}
+
+	record, err := billing.NewBillingRecordComputer(source, nil, pricing.NewCalculator()).ComputeRecord("org-1", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ComputeRecord() error = %v", err)
+	}
+	if costs.TotalCents != record.SubtotalCents || record.SubtotalCents != 30900 {
+		t.Errorf("TotalCents = %d, want the billing record's %d", costs.TotalCents, record.SubtotalCents)
+	}
+}
+
+// TestUsageCosts_PartialPeriods tests that days are priced against earlier usage in their period
+func TestUsageCosts_PartialPeriods(t *testing.T) {
+	costs, err := newTestQuoter(t, newAnchoredSource()).UsageCosts("org-1", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("UsageCosts() error = %v", err)
+	}
+
+	if len(costs.Days) != 3 || costs.Days[0].CostCents != 4900 || costs.Days[2].CostCents != 0 {
+		t.Errorf("Days = %+v, want Apr 1 at $49 and Apr 15 within the new period's minimum", costs.Days)
+	}
+	// Two periods touched, each at least its $250 minimum, plus $49 + $10 of March's overage
+	if costs.TotalCents != 25000+4900+1000+25000 {
+		t.Errorf("TotalCents = %d, want %d", costs.TotalCents, 25000+4900+1000+25000)
+	}
+}
+
+// TestUsageCosts_NoSubscription tests that usage without a plan is listed at no cost
+func TestUsageCosts_NoSubscription(t *testing.T) {
+	source := newAnchoredSource()
+	delete(source.plans, "org-1")
+
+	costs, err := newTestQuoter(t, source).UsageCosts("org-1", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("UsageCosts() error = %v", err)
+	}
+	if costs.Plan != nil || costs.TotalCents != 0 || len(costs.Days) != 4 || costs.Days[0].BillableUnits != 600000 {
+		t.Errorf("Costs = %+v, want the days' usage and nothing charged", costs)
+	}
+}
+
+// TestPlanChange_AnchoredPeriod tests that a change is prorated over the org's anchored period
+func TestPlanChange_AnchoredPeriod(t *testing.T) {
+	source := newAnchoredSource()
+	from := source.plans["org-1"]
+	effectiveAt := time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC) // 16 of the Mar 15 - Apr 15 period's 31 days left
+
+	change, err := newTestQuoter(t, source).PlanChange(from, "starter", effectiveAt)
+	if err != nil {
+		t.Fatalf("PlanChange() error = %v", err)
+	}
+
+	wantStart, wantEnd := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC)
+	if !change.PeriodStart.Equal(wantStart) || !change.PeriodEnd.Equal(wantEnd) {
+		t.Errorf("Period = %s - %s, want Mar 15 - Apr 15", change.PeriodStart, change.PeriodEnd)
+	}
+	// (2900 - 9900) * 16/31 = -3612.9, credited
+	if change.ProratedCents != -3613 {
+		t.Errorf("ProratedCents = %d, want -3613", change.ProratedCents)
+	}
+	// Mar 15 and Mar 20 count; Mar 14 belongs to the period before
+	if change.UsedUnits != 1500000 || change.OverLimit() {
+		t.Errorf("UsedUnits = %d (over limit %v), want 1500000 within Starter's 2M cap", change.UsedUnits, change.OverLimit())
+	}
+
+	// After Apr 1 the period's 3M units exceed the cap
+	if change, err = newTestQuoter(t, source).PlanChange(from, "starter", time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC)); err != nil || !change.OverLimit() {
+		t.Errorf("PlanChange() = %+v, %v, want over Starter's limit", change, err)
+	}
+
+	if _, err := newTestQuoter(t, source).PlanChange(from, "legacy", effectiveAt); !errors.Is(err, ErrPlanNotFound) {
+		t.Errorf("Error = %v, want ErrPlanNotFound", err)
+	}
+}
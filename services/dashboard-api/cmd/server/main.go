@@ -10,12 +10,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/config"
-	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/handlers"
-	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/middleware"
-	"github.com/go-chi/chi/v5"
-	chiMiddleware "github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/notify"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
 )
 
 func main() {
@@ -39,81 +37,41 @@ func main() {
 
 	log.Println("✅ Database connected")
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, cfg)
-	usageHandler := handlers.NewUsageHandler(db)
-	apiKeyHandler := handlers.NewAPIKeyHandler(db)
-	invoiceHandler := handlers.NewInvoiceHandler(db)
-
-	// Setup router
-	r := chi.NewRouter()
-
-	// Global middleware
-	r.Use(chiMiddleware.RequestID)
-	r.Use(chiMiddleware.RealIP)
-	r.Use(chiMiddleware.Logger)
-	r.Use(chiMiddleware.Recoverer)
-	r.Use(chiMiddleware.Timeout(60 * time.Second))
-
-	// CORS middleware
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   cfg.CORS.AllowedOrigins,
-		AllowedMethods:   cfg.CORS.AllowedMethods,
-		AllowedHeaders:   cfg.CORS.AllowedHeaders,
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           cfg.CORS.MaxAge,
-	}))
-
-	// Health check endpoint (no auth required)
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","service":"dashboard-api"}`))
+	// Price previews, usage costs and plan changes with the billing engine's own pricing
+	quoter, err := quote.New(db, quote.Config{
+		DefaultPlanID:   cfg.Billing.DefaultPlanID,
+		OverageRounding: cfg.Billing.OverageRounding,
+		UsageUnitLabel:  cfg.Billing.UsageUnitLabel,
 	})
+	if err != nil {
+		log.Fatalf("Invalid billing configuration: %v", err)
+	}
 
-	// Public routes (no authentication required)
-	r.Route("/api/v1/auth", func(r chi.Router) {
-		r.Post("/login", authHandler.Login)
-	})
+	// Revoke rotated API keys once their grace period ends
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	defer stopCleanup()
+	go repository.NewAPIKeyRepository(db, cfg.APIKeys.Pepper).RunRotationCleanup(cleanupCtx, cfg.APIKeys.RotationCleanupInterval)
 
-	// Protected routes (authentication required)
-	r.Route("/api/v1", func(r chi.Router) {
-		// Apply tenant context middleware for multi-tenancy
-		r.Use(middleware.TenantContextMiddleware(db, cfg))
-
-		// Auth validation endpoint
-		r.Get("/auth/validate", authHandler.ValidateToken)
-
-		// Usage endpoints
-		r.Route("/usage", func(r chi.Router) {
-			r.Get("/current", usageHandler.GetCurrentUsage)
-			r.Get("/history", usageHandler.GetUsageHistory)
-			r.Get("/metrics", usageHandler.GetUsageByMetric)
-		})
-
-		// API Key endpoints
-		r.Route("/apikeys", func(r chi.Router) {
-			r.Get("/", apiKeyHandler.ListAPIKeys)
-			r.Post("/", apiKeyHandler.CreateAPIKey)
-			r.Get("/{id}", apiKeyHandler.GetAPIKey)
-			r.Delete("/{id}", apiKeyHandler.RevokeAPIKey)
-		})
-
-		// Invoice endpoints
-		r.Route("/invoices", func(r chi.Router) {
-			r.Get("/", invoiceHandler.ListInvoices)
-			r.Get("/{id}", invoiceHandler.GetInvoice)
-			r.Get("/{id}/pdf", invoiceHandler.GetInvoicePDF)
-		})
-	})
+	// Write buffered API key last_used_at timestamps in batches
+	lastUsedDone := make(chan struct{})
+	go func() {
+		defer close(lastUsedDone)
+		repository.NewAPIKeyRepository(db, cfg.APIKeys.Pepper).RunLastUsedFlush(cleanupCtx, cfg.APIKeys.LastUsedFlushInterval)
+	}()
 
-	// 404 handler
-	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error":"Not Found","message":"The requested endpoint does not exist"}`))
-	})
+	// Remind billing contacts before their API keys expire (needs email)
+	if cfg.Email.Enabled && cfg.APIKeys.ExpiryReminderWindow > 0 {
+		reminder := notify.NewKeyExpiryReminder(repository.NewAPIKeyRepository(db, cfg.APIKeys.Pepper),
+			notify.NewSMTPMailer(cfg.Email), cfg.APIKeys.ExpiryReminderWindow, cfg.Email.FromName)
+		go reminder.Run(cleanupCtx, cfg.APIKeys.ExpiryReminderInterval)
+		log.Printf("✅ API key expiry reminders enabled (%s before expiry)", cfg.APIKeys.ExpiryReminderWindow)
+	}
+
+	// Setup router
+	r, err := newRouter(cfg, db, quoter)
+	if err != nil {
+		log.Fatalf("Failed to set up routes: %v", err)
+	}
 
 	// Create HTTP server
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
@@ -131,16 +89,32 @@ func main() {
 		log.Println("📋 Available endpoints:")
 		log.Println("  POST   /api/v1/auth/login")
 		log.Println("  GET    /api/v1/auth/validate")
+		log.Println("  GET    /api/v1/plans")
 		log.Println("  GET    /api/v1/usage/current")
 		log.Println("  GET    /api/v1/usage/history")
 		log.Println("  GET    /api/v1/usage/metrics")
+		log.Println("  GET    /api/v1/usage/recommendation")
+		log.Println("  GET    /api/v1/usage/export")
 		log.Println("  GET    /api/v1/apikeys")
 		log.Println("  POST   /api/v1/apikeys")
 		log.Println("  GET    /api/v1/apikeys/{id}")
+		log.Println("  GET    /api/v1/apikeys/{id}/usage")
 		log.Println("  DELETE /api/v1/apikeys/{id}")
+		log.Println("  POST   /api/v1/apikeys/{id}/rotate")
+		log.Println("  GET    /api/v1/apikeys/{id}/audit")
+		log.Println("  PUT    /api/v1/apikeys/{id}/allowed-cidrs")
+		log.Println("  GET    /api/v1/organization")
+		log.Println("  POST   /api/v1/organization/suspend")
+		log.Println("  POST   /api/v1/organization/reactivate")
+		log.Println("  GET    /api/v1/organization/invoice-defaults")
+		log.Println("  PATCH  /api/v1/organization/invoice-defaults")
+		log.Println("  POST   /api/v1/subscription/change-plan")
 		log.Println("  GET    /api/v1/invoices")
+		log.Println("  GET    /api/v1/invoices/preview")
 		log.Println("  GET    /api/v1/invoices/{id}")
+		log.Println("  GET    /api/v1/invoices/{id}/line-items")
 		log.Println("  GET    /api/v1/invoices/{id}/pdf")
+		log.Println("  PATCH  /api/v1/invoices/{id}")
 		log.Println("")
 		log.Println("✅ Dashboard API is ready!")
 
@@ -163,5 +137,9 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Stop the background jobs and wait for the final last_used_at flush
+	stopCleanup()
+	<-lastUsedDone
+
 	log.Println("✅ Server stopped gracefully")
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/config"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/handlers"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/middleware"
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+// newRouter builds the API's handlers, middleware and routes
+func newRouter(cfg *config.Config, db *sql.DB, quoter *quote.Quoter) (http.Handler, error) {
+	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(db, cfg)
+	usageHandler := handlers.NewUsageHandler(db, quoter)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, cfg.APIKeys.RotationGrace, cfg.APIKeys.Pepper)
+	organizationHandler := handlers.NewOrganizationHandler(db)
+	invoiceHandler := handlers.NewInvoiceHandler(db, quoter, cfg.Billing.TaxRate)
+	planHandler := handlers.NewPlanHandler(db, cfg.Billing.UsageUnitLabel)
+	subscriptionHandler := handlers.NewSubscriptionHandler(db, quoter)
+
+	r := chi.NewRouter()
+
+	// Global middleware (X-Forwarded-For only counts from trusted proxies, which were validated
+	// with the configuration)
+	trustedProxies, err := config.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	r.Use(chiMiddleware.RequestID)
+	r.Use(middleware.RealIP(trustedProxies))
+	r.Use(chiMiddleware.Logger)
+	r.Use(chiMiddleware.Recoverer)
+	r.Use(chiMiddleware.Timeout(60 * time.Second))
+
+	// CORS middleware (origins were validated with the configuration)
+	origins, err := config.NewOriginMatcher(cfg.CORS.AllowedOrigins)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS configuration: %w", err)
+	}
+	r.Use(cors.Handler(cors.Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			return origins.Allowed(origin)
+		},
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}))
+
+	// Health check endpoint (no auth required)
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"healthy","service":"dashboard-api"}`))
+	})
+
+	// Public routes (no authentication required)
+	r.Route("/api/v1/auth", func(r chi.Router) {
+		r.Post("/login", authHandler.Login)
+	})
+
+	// Plan catalog (public; a valid token marks the organization's current plan)
+	r.With(middleware.OptionalAuthMiddleware(cfg)).Get("/api/v1/plans", planHandler.ListPlans)
+
+	// Protected routes (authentication required)
+	r.Route("/api/v1", func(r chi.Router) {
+		// Apply tenant context middleware for multi-tenancy
+		r.Use(middleware.TenantContextMiddleware(cfg))
+
+		// Auth validation endpoint
+		r.Get("/auth/validate", authHandler.ValidateToken)
+
+		// Usage endpoints (read-only, open to admin, member and viewer)
+		r.Route("/usage", func(r chi.Router) {
+			r.Get("/current", usageHandler.GetCurrentUsage)
+			r.Get("/history", usageHandler.GetUsageHistory)
+			r.Get("/metrics", usageHandler.GetUsageByMetric)
+			r.Get("/regions", usageHandler.GetUsageByRegion)
+			r.Get("/recommendation", usageHandler.GetPlanRecommendation)
+			r.Get("/export", usageHandler.ExportUsage)
+		})
+
+		// API Key endpoints (any role may list; only admins change keys or read the audit log)
+		r.Route("/apikeys", func(r chi.Router) {
+			r.Get("/", apiKeyHandler.ListAPIKeys)
+			r.With(middleware.RoleMiddleware("admin")).Post("/", apiKeyHandler.CreateAPIKey)
+			r.Get("/{id}", apiKeyHandler.GetAPIKey)
+			r.Get("/{id}/usage", apiKeyHandler.GetAPIKeyUsage)
+			r.With(middleware.RoleMiddleware("admin")).Delete("/{id}", apiKeyHandler.RevokeAPIKey)
+			r.With(middleware.RoleMiddleware("admin")).Post("/{id}/rotate", apiKeyHandler.RotateAPIKey)
+			r.With(middleware.RoleMiddleware("admin")).Get("/{id}/audit", apiKeyHandler.GetAPIKeyAudit)
+			r.With(middleware.RoleMiddleware("admin")).Put("/{id}/allowed-cidrs", apiKeyHandler.UpdateAllowedCIDRs)
+		})
+
+		// Organization status (open to every role, so suspended orgs can see why; admins suspend/reactivate)
+		// and invoice defaults (admins set them)
+		r.Route("/organization", func(r chi.Router) {
+			r.Get("/", organizationHandler.GetOrganization)
+			r.With(middleware.RoleMiddleware("admin")).Post("/suspend", organizationHandler.SuspendOrganization)
+			r.With(middleware.RoleMiddleware("admin")).Post("/reactivate", organizationHandler.ReactivateOrganization)
+			r.Get("/invoice-defaults", organizationHandler.GetInvoiceDefaults)
+			r.With(middleware.RoleMiddleware("admin")).Patch("/invoice-defaults", organizationHandler.UpdateInvoiceDefaults)
+		})
+
+		// Plan changes (admin-only; they change what the organization is billed)
+		r.Route("/subscription", func(r chi.Router) {
+			r.With(middleware.RoleMiddleware("admin")).Post("/change-plan", subscriptionHandler.ChangePlan)
+		})
+
+		// Invoice endpoints (reads open to every role; edits, void and refund are admin-only)
+		r.Route("/invoices", func(r chi.Router) {
+			r.Get("/", invoiceHandler.ListInvoices)
+			r.Get("/preview", invoiceHandler.PreviewInvoice)
+			r.Get("/{id}", invoiceHandler.GetInvoice)
+			r.Get("/{id}/line-items", invoiceHandler.ListInvoiceLineItems)
+			r.Get("/{id}/pdf", invoiceHandler.GetInvoicePDF)
+			r.With(middleware.RoleMiddleware("admin")).Patch("/{id}", invoiceHandler.UpdateInvoice)
+			r.With(middleware.RoleMiddleware("admin")).Post("/{id}/void", invoiceHandler.VoidInvoice)
+			r.With(middleware.RoleMiddleware("admin")).Post("/{id}/refund", invoiceHandler.RefundInvoice)
+		})
+	})
+
+	// 404 handler
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"Not Found","message":"The requested endpoint does not exist"}`))
+	})
+
+	return r, nil
+}
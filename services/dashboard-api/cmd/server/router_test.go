@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// routerFakeDriver counts queries; role checks must reject requests before any reach it
+type routerFakeDriver struct{ queries atomic.Int32 }
+
+type routerFakeConn struct{ driver *routerFakeDriver }
+
+func (d *routerFakeDriver) Open(string) (driver.Conn, error) { return &routerFakeConn{driver: d}, nil }
+
+func (c *routerFakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *routerFakeConn) Close() error { return nil }
+func (c *routerFakeConn) Begin() (driver.Tx, error) {
+	c.driver.queries.Add(1)
+	return nil, errors.New("begin not supported")
+}
+
+func (c *routerFakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.queries.Add(1)
+	return nil, errors.New("exec not supported")
+}
+
+func (c *routerFakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.queries.Add(1)
+	return nil, errors.New("query not supported")
+}
+
+func testRouter(t *testing.T) (http.Handler, *config.Config, *routerFakeDriver) {
+	t.Helper()
+	d := &routerFakeDriver{}
+	sql.Register("routerfake", d)
+	db, err := sql.Open("routerfake", "")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	quoter, err := quote.New(db, quote.Config{})
+	if err != nil {
+		t.Fatalf("quote.New() error = %v", err)
+	}
+
+	cfg := &config.Config{
+		JWT:  config.JWTConfig{Secret: "test-secret"},
+		CORS: config.CORSConfig{AllowedOrigins: []string{"http://localhost:3000"}},
+	}
+	r, err := newRouter(cfg, db, quoter)
+	if err != nil {
+		t.Fatalf("newRouter() error = %v", err)
+	}
+	return r, cfg, d
+}
+
+func signTestToken(t *testing.T, secret, role string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":         "user-1",
+		"organization_id": "org-123",
+		"role":            role,
+		"exp":             time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+// TestRouter_APIKeyCreationRequiresAdmin tests the /api/v1/apikeys routes as main serves them
+func TestRouter_APIKeyCreationRequiresAdmin(t *testing.T) {
+	r, cfg, d := testRouter(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		role   string
+		want   int
+	}{
+		{"Viewer cannot create keys", http.MethodPost, "/api/v1/apikeys", "viewer", http.StatusForbidden},
+		{"Member cannot create keys", http.MethodPost, "/api/v1/apikeys", "member", http.StatusForbidden},
+		{"Viewer cannot revoke keys", http.MethodDelete, "/api/v1/apikeys/key-1", "viewer", http.StatusForbidden},
+		{"Viewer cannot rotate keys", http.MethodPost, "/api/v1/apikeys/key-1/rotate", "viewer", http.StatusForbidden},
+		{"Anonymous cannot create keys", http.MethodPost, "/api/v1/apikeys", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name":"ci"}`))
+			if tt.role != "" {
+				req.Header.Set("Authorization", "Bearer "+signTestToken(t, cfg.JWT.Secret, tt.role))
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("Status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusForbidden && !strings.Contains(rec.Body.String(), `"error": "Forbidden"`) {
+				t.Errorf("Body = %s, want the 403 JSON envelope", rec.Body.String())
+			}
+		})
+	}
+
+	if n := d.queries.Load(); n != 0 {
+		t.Errorf("Rejected requests ran %d queries, want 0", n)
+	}
+}
@@ -3,9 +3,12 @@ module github.com/devwithmohit/billing-system/services/dashboard-api
 go 1.21
 
 require (
+	github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine v0.0.0
 	github.com/go-chi/chi/v5 v5.0.11
 	github.com/go-chi/cors v1.2.1
 	github.com/golang-jwt/jwt/v5 v5.2.0
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.18.0
 )
+
+replace github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine => ../billing-engine
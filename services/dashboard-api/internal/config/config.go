@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -16,6 +17,9 @@ type Config struct {
 	Database DatabaseConfig
 	JWT      JWTConfig
 	CORS     CORSConfig
+	Billing  BillingConfig
+	APIKeys  APIKeyConfig
+	Email    EmailConfig
 }
 
 // ServerConfig holds HTTP server configuration
@@ -25,7 +29,8 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
-	Environment     string // development, staging, production
+	Environment     string   // development, staging, production
+	TrustedProxies  []string // Proxies whose X-Forwarded-For names the client (IPs or CIDR ranges)
 }
 
 // DatabaseConfig holds database connection configuration
@@ -50,10 +55,40 @@ type JWTConfig struct {
 
 // CORSConfig holds CORS configuration
 type CORSConfig struct {
-	AllowedOrigins []string
-	AllowedMethods []string
-	AllowedHeaders []string
-	MaxAge         int
+	AllowedOrigins   []string // Exact origins, "https://*.example.com" patterns, or "*"
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool // Send cookies and Authorization headers; not allowed with "*"
+	MaxAge           int  // Seconds browsers may cache a preflight response
+}
+
+// BillingConfig holds settings mirrored from the billing engine for estimates
+type BillingConfig struct {
+	TaxRate         float64 // Applied to invoice previews (e.g., 0.08 for 8%)
+	UsageUnitLabel  string  // Plural name of a billable unit (e.g., "requests", "messages")
+	DefaultPlanID   string  // Plan the engine bills organizations without a subscription ("" disables)
+	OverageRounding string  // How the engine rounds fractional cents of usage charges
+}
+
+// APIKeyConfig holds API key lifecycle settings
+type APIKeyConfig struct {
+	RotationGrace           time.Duration // How long a rotated key keeps working (0 = revoke at once)
+	RotationCleanupInterval time.Duration // How often keys past their grace period are revoked
+	LastUsedFlushInterval   time.Duration // How often buffered last_used_at timestamps are written
+	Pepper                  string        // HMAC key for the indexed key lookup hash
+	ExpiryReminderWindow    time.Duration // Remind the billing contact this long before a key expires (0 = never)
+	ExpiryReminderInterval  time.Duration // How often keys are checked for upcoming expiry
+}
+
+// EmailConfig holds SMTP settings; the variables are shared with the billing engine
+type EmailConfig struct {
+	Enabled      bool
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	FromEmail    string
+	FromName     string
 }
 
 // Load loads configuration from environment variables
@@ -66,6 +101,7 @@ func Load() (*Config, error) {
 			WriteTimeout:    getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
 			ShutdownTimeout: getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
 			Environment:     getEnv("ENVIRONMENT", "development"),
+			TrustedProxies:  getListEnv("TRUSTED_PROXIES", nil),
 		},
 		Database: DatabaseConfig{
 			Host:            getEnv("DB_HOST", "localhost"),
@@ -84,12 +120,34 @@ func Load() (*Config, error) {
 			ExpirationHours: getIntEnv("JWT_EXPIRATION_HOURS", 24),
 		},
 		CORS: CORSConfig{
-			AllowedOrigins: []string{
-				getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000"),
-			},
-			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-			MaxAge:         300,
+			AllowedOrigins:   getListEnv("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000"}),
+			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+			AllowCredentials: getBoolEnv("CORS_ALLOW_CREDENTIALS", true),
+			MaxAge:           getIntEnv("CORS_MAX_AGE", DefaultCORSMaxAge),
+		},
+		Billing: BillingConfig{
+			TaxRate:         getFloatEnv("TAX_RATE", 0.0),
+			UsageUnitLabel:  getEnv("USAGE_UNIT_LABEL", "requests"),
+			DefaultPlanID:   getEnv("DEFAULT_PLAN_ID", "free"),
+			OverageRounding: getEnv("OVERAGE_ROUNDING", ""),
+		},
+		APIKeys: APIKeyConfig{
+			RotationGrace:           getDurationEnv("API_KEY_ROTATION_GRACE", 24*time.Hour),
+			RotationCleanupInterval: getDurationEnv("API_KEY_ROTATION_CLEANUP_INTERVAL", 5*time.Minute),
+			LastUsedFlushInterval:   getDurationEnv("API_KEY_LAST_USED_FLUSH_INTERVAL", time.Minute),
+			Pepper:                  getEnv("API_KEY_PEPPER", "dev-api-key-pepper-change-in-production"),
+			ExpiryReminderWindow:    getDurationEnv("API_KEY_EXPIRY_REMINDER_WINDOW", 7*24*time.Hour),
+			ExpiryReminderInterval:  getDurationEnv("API_KEY_EXPIRY_REMINDER_INTERVAL", time.Hour),
+		},
+		Email: EmailConfig{
+			Enabled:      getBoolEnv("ENABLE_EMAIL", false),
+			SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
+			SMTPPort:     getIntEnv("SMTP_PORT", 587),
+			SMTPUser:     getEnv("SMTP_USER", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			FromEmail:    getEnv("FROM_EMAIL", "billing@example.com"),
+			FromName:     getEnv("FROM_NAME", "Billing Team"),
 		},
 	}
 
@@ -109,6 +167,47 @@ func (c *Config) Validate() error {
 	if c.JWT.Secret == "your-secret-key-change-in-production" && c.Server.Environment == "production" {
 		return fmt.Errorf("JWT_SECRET must be set in production")
 	}
+	if c.Billing.TaxRate < 0 || c.Billing.TaxRate > 1 {
+		return fmt.Errorf("TAX_RATE must be between 0 and 1 (e.g., 0.08 for 8%%)")
+	}
+	if c.APIKeys.RotationGrace < 0 {
+		return fmt.Errorf("API_KEY_ROTATION_GRACE must not be negative")
+	}
+	if c.APIKeys.RotationCleanupInterval <= 0 {
+		return fmt.Errorf("API_KEY_ROTATION_CLEANUP_INTERVAL must be positive")
+	}
+	if c.APIKeys.Pepper == "dev-api-key-pepper-change-in-production" && c.Server.Environment == "production" {
+		return fmt.Errorf("API_KEY_PEPPER must be set in production")
+	}
+	if c.APIKeys.LastUsedFlushInterval <= 0 {
+		return fmt.Errorf("API_KEY_LAST_USED_FLUSH_INTERVAL must be positive")
+	}
+	if c.APIKeys.ExpiryReminderWindow < 0 {
+		return fmt.Errorf("API_KEY_EXPIRY_REMINDER_WINDOW must not be negative")
+	}
+	if c.APIKeys.ExpiryReminderInterval <= 0 {
+		return fmt.Errorf("API_KEY_EXPIRY_REMINDER_INTERVAL must be positive")
+	}
+	if c.Email.Enabled {
+		if c.Email.SMTPHost == "" {
+			return fmt.Errorf("SMTP_HOST required when ENABLE_EMAIL is true")
+		}
+		if c.Email.SMTPUser == "" {
+			return fmt.Errorf("SMTP_USER required when ENABLE_EMAIL is true")
+		}
+		if c.Email.SMTPPassword == "" {
+			return fmt.Errorf("SMTP_PASSWORD required when ENABLE_EMAIL is true")
+		}
+		if c.Email.FromEmail == "" {
+			return fmt.Errorf("FROM_EMAIL required when ENABLE_EMAIL is true")
+		}
+	}
+	if err := c.CORS.validate(); err != nil {
+		return err
+	}
+	if _, err := ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
 	return nil
 }
 
@@ -160,6 +259,30 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getListEnv splits a comma-separated variable, dropping empty entries
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -168,3 +291,12 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
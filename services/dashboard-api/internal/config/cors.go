@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Preflight cache limits (seconds); browsers cap Access-Control-Max-Age themselves
+// (Chromium at 2 hours), so longer values only hide configuration mistakes
+const (
+	DefaultCORSMaxAge = 300
+	MaxCORSMaxAge     = 86400
+)
+
+// OriginMatcher decides which browser origins may call the API
+// An origin is "*" (any origin), an exact origin such as "https://app.ourapp.com",
+// or a subdomain pattern such as "https://*.ourapp.com"
+type OriginMatcher struct {
+	any      bool
+	exact    map[string]bool
+	patterns []originPattern
+}
+
+// originPattern is a parsed "scheme://*.domain[:port]" origin
+type originPattern struct {
+	scheme string
+	suffix string // ".domain", matched against the host
+	port   string
+}
+
+// NewOriginMatcher parses the allowed origins, rejecting entries a browser could never send
+func NewOriginMatcher(origins []string) (*OriginMatcher, error) {
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("at least one origin is required")
+	}
+
+	m := &OriginMatcher{exact: make(map[string]bool)}
+	for _, origin := range origins {
+		if origin == "*" {
+			m.any = true
+			continue
+		}
+
+		scheme, host, port, err := splitOrigin(origin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid origin %q: %w", origin, err)
+		}
+
+		if !strings.Contains(host, "*") {
+			m.exact[joinOrigin(scheme, host, port)] = true
+			continue
+		}
+
+		// Only a whole leftmost label may be a wildcard, under a domain of at least two labels
+		domain := strings.TrimPrefix(host, "*.")
+		if domain == host || strings.Contains(domain, "*") {
+			return nil, fmt.Errorf("invalid origin %q: only a leading \"*.\" wildcard is supported", origin)
+		}
+		if !strings.Contains(domain, ".") {
+			return nil, fmt.Errorf("invalid origin %q: wildcard domain %q is too broad", origin, domain)
+		}
+		m.patterns = append(m.patterns, originPattern{scheme: scheme, suffix: "." + domain, port: port})
+	}
+
+	return m, nil
+}
+
+// AllowsAny reports whether every origin is allowed ("*")
+func (m *OriginMatcher) AllowsAny() bool {
+	return m.any
+}
+
+// Allowed reports whether a request Origin header matches the allowed origins
+// Subdomain patterns match any depth of subdomain but not the domain itself
+func (m *OriginMatcher) Allowed(origin string) bool {
+	if m.any {
+		return true
+	}
+
+	scheme, host, port, err := splitOrigin(origin)
+	if err != nil || strings.Contains(host, "*") {
+		return false
+	}
+	if m.exact[joinOrigin(scheme, host, port)] {
+		return true
+	}
+
+	for _, p := range m.patterns {
+		if scheme == p.scheme && port == p.port && strings.HasSuffix(host, p.suffix) && len(host) > len(p.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitOrigin splits "scheme://host[:port]" into lowercase parts
+// Paths, queries, and credentials are rejected: they are never part of an Origin header
+func splitOrigin(origin string) (scheme, host, port string, err error) {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil {
+		return "", "", "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", "", fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return "", "", "", fmt.Errorf("missing host")
+	}
+	if u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.ForceQuery {
+		return "", "", "", fmt.Errorf("an origin is only scheme://host[:port], without a path or trailing slash")
+	}
+	return u.Scheme, u.Hostname(), u.Port(), nil
+}
+
+// joinOrigin is the inverse of splitOrigin
+func joinOrigin(scheme, host, port string) string {
+	if port == "" {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + host + ":" + port
+}
+
+// validate checks the CORS settings for combinations browsers reject
+func (c CORSConfig) validate() error {
+	matcher, err := NewOriginMatcher(c.AllowedOrigins)
+	if err != nil {
+		return fmt.Errorf("CORS_ALLOWED_ORIGINS: %w", err)
+	}
+	if matcher.AllowsAny() && c.AllowCredentials {
+		return fmt.Errorf("CORS_ALLOWED_ORIGINS cannot be \"*\" while CORS_ALLOW_CREDENTIALS is true; list the origins instead")
+	}
+	if c.MaxAge < 0 || c.MaxAge > MaxCORSMaxAge {
+		return fmt.Errorf("CORS_MAX_AGE must be between 0 and %d seconds", MaxCORSMaxAge)
+	}
+	return nil
+}
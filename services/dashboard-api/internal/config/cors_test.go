@@ -0,0 +1,132 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOriginMatcher_Allowed(t *testing.T) {
+	matcher, err := NewOriginMatcher([]string{
+		"http://localhost:3000",
+		"https://dashboard.example.com",
+		"https://*.ourapp.com",
+		"https://*.staging.ourapp.io:8443",
+	})
+	if err != nil {
+		t.Fatalf("NewOriginMatcher() error = %v", err)
+	}
+
+	tests := []struct {
+		origin   string
+		expected bool
+	}{
+		// Exact origins
+		{"http://localhost:3000", true},
+		{"https://dashboard.example.com", true},
+		{"https://DASHBOARD.example.com", true},
+		{"http://localhost:3001", false},
+		{"https://localhost:3000", false},
+		{"http://dashboard.example.com", false},
+
+		// Subdomain patterns
+		{"https://app.ourapp.com", true},
+		{"https://eu.app.ourapp.com", true},
+		{"https://ourapp.com", false}, // The domain itself is not a subdomain
+		{"http://app.ourapp.com", false},
+		{"https://app.ourapp.com:8443", false},
+		{"https://evilourapp.com", false},
+		{"https://app.ourapp.com.evil.com", false},
+		{"https://pr-12.staging.ourapp.io:8443", true},
+		{"https://pr-12.staging.ourapp.io", false},
+
+		// Not origins
+		{"", false},
+		{"null", false},
+		{"https://app.ourapp.com/", false},
+		{"https://*.ourapp.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := matcher.Allowed(tt.origin); got != tt.expected {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOriginMatcher_Any(t *testing.T) {
+	matcher, err := NewOriginMatcher([]string{"*"})
+	if err != nil {
+		t.Fatalf("NewOriginMatcher() error = %v", err)
+	}
+	if !matcher.AllowsAny() || !matcher.Allowed("https://anything.test") {
+		t.Error("Expected \"*\" to allow every origin")
+	}
+}
+
+func TestNewOriginMatcher_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+	}{
+		{"Missing scheme", "app.ourapp.com"},
+		{"Unsupported scheme", "ftp://app.ourapp.com"},
+		{"Trailing slash", "https://app.ourapp.com/"},
+		{"Path", "https://app.ourapp.com/dashboard"},
+		{"Wildcard inside a label", "https://app-*.ourapp.com"},
+		{"Wildcard not leftmost", "https://app.*.com"},
+		{"Wildcard top-level domain", "https://*.com"},
+		{"Wildcard host", "https://*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewOriginMatcher([]string{"http://localhost:3000", tt.origin}); err == nil {
+				t.Errorf("NewOriginMatcher(%q) error = nil, want an error", tt.origin)
+			}
+		})
+	}
+
+	if _, err := NewOriginMatcher(nil); err == nil {
+		t.Error("NewOriginMatcher(nil) error = nil, want an error")
+	}
+}
+
+func TestCORSConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     CORSConfig
+		wantErr string
+	}{
+		{"Listed origins with credentials", CORSConfig{AllowedOrigins: []string{"https://*.ourapp.com"}, AllowCredentials: true, MaxAge: DefaultCORSMaxAge}, ""},
+		{"Wildcard without credentials", CORSConfig{AllowedOrigins: []string{"*"}, MaxAge: DefaultCORSMaxAge}, ""},
+		{"Wildcard with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true, MaxAge: DefaultCORSMaxAge}, "CORS_ALLOW_CREDENTIALS"},
+		{"Invalid origin", CORSConfig{AllowedOrigins: []string{"https://app.ourapp.com/"}, MaxAge: DefaultCORSMaxAge}, "CORS_ALLOWED_ORIGINS"},
+		{"Negative max age", CORSConfig{AllowedOrigins: []string{"https://app.ourapp.com"}, MaxAge: -1}, "CORS_MAX_AGE"},
+		{"Max age over a day", CORSConfig{AllowedOrigins: []string{"https://app.ourapp.com"}, MaxAge: MaxCORSMaxAge + 1}, "CORS_MAX_AGE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want one mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetListEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://a.example.com, ,https://*.ourapp.com ")
+	got := getListEnv("CORS_ALLOWED_ORIGINS", nil)
+	if len(got) != 2 || got[0] != "https://a.example.com" || got[1] != "https://*.ourapp.com" {
+		t.Errorf("getListEnv() = %q, want the two trimmed origins", got)
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	expected := []string{"10.0.0.0/8", "192.0.2.10/32", "2001:db8::1/128"}
+	if len(proxies) != len(expected) {
+		t.Fatalf("Proxies = %v, want %v", proxies, expected)
+	}
+	for i, want := range expected {
+		if got := proxies[i].String(); got != want {
+			t.Errorf("Proxy %d = %s, want %s", i, got, want)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.internal", "192.0.2.300"} {
+		if _, err := ParseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) error = nil, want an error", invalid)
+		}
+	}
+}
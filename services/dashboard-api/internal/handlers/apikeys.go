@@ -1,29 +1,50 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
 	"github.com/go-chi/chi/v5"
 )
 
+// apiKeyStore is the subset of APIKeyRepository used by the handler
+type apiKeyStore interface {
+	ListAPIKeys(ctx context.Context, orgID string, opts models.APIKeyListOptions) (*models.APIKeyListResponse, error)
+	CreateAPIKey(ctx context.Context, orgID, name string, expiresAt *time.Time, actor models.APIKeyActor) (*models.APIKey, string, error)
+	GetAPIKey(ctx context.Context, keyID, orgID string) (*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID, orgID string, actor models.APIKeyActor) error
+	RotateAPIKey(ctx context.Context, keyID, orgID string, grace time.Duration, actor models.APIKeyActor) (*models.APIKey, string, error)
+	ListAPIKeyAudit(ctx context.Context, keyID, orgID string) ([]models.APIKeyAuditEntry, error)
+	GetAPIKeyUsage(ctx context.Context, keyID, orgID string, startDate, endDate time.Time) (*models.APIKeyUsageResponse, error)
+	UpdateAllowedCIDRs(ctx context.Context, keyID, orgID string, cidrs []string, actor models.APIKeyActor) (*models.APIKey, error)
+}
+
 // APIKeyHandler handles API key operations
 type APIKeyHandler struct {
-	repo *repository.APIKeyRepository
+	repo          apiKeyStore
+	rotationGrace time.Duration // How long a rotated key keeps working
 }
 
 // NewAPIKeyHandler creates a new API key handler
-func NewAPIKeyHandler(db *sql.DB) *APIKeyHandler {
+func NewAPIKeyHandler(db *sql.DB, rotationGrace time.Duration, pepper string) *APIKeyHandler {
 	return &APIKeyHandler{
-		repo: repository.NewAPIKeyRepository(db),
+		repo:          repository.NewAPIKeyRepository(db, pepper),
+		rotationGrace: rotationGrace,
 	}
 }
 
 // ListAPIKeys handles GET /api/v1/apikeys
-// Lists all API keys for the organization
+// Lists a page of the organization's API keys, optionally sorted and filtered by status
 func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 	// Extract organization ID from context
 	orgID, ok := r.Context().Value("organization_id").(string)
@@ -32,34 +53,36 @@ func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all API keys
-	keys, err := h.repo.ListAPIKeys(r.Context(), orgID)
+	// Parse pagination and filter parameters
+	opts := models.APIKeyListOptions{
+		Sort:   r.URL.Query().Get("sort"),
+		Status: r.URL.Query().Get("status"),
+	}
+	opts.Page, opts.PageSize = parsePagination(r)
+	if fields := opts.Validate(); len(fields) > 0 {
+		respondValidationError(w, fields)
+		return
+	}
+
+	keys, err := h.repo.ListAPIKeys(r.Context(), orgID, opts)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to list API keys", err.Error())
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"api_keys": keys,
-		"count":    len(keys),
-	})
+	respondJSON(w, http.StatusOK, keys)
 }
 
 // CreateAPIKey handles POST /api/v1/apikeys
 // Creates a new API key for the organization
 func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
-	// Extract organization ID and user ID from context
+	// Extract organization ID from context
 	orgID, ok := r.Context().Value("organization_id").(string)
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
 		return
 	}
 
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok {
-		userID = "system" // fallback
-	}
-
 	// Parse request body
 	var req models.CreateAPIKeyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -67,15 +90,21 @@ func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate name
-	if req.Name == "" {
-		respondError(w, http.StatusBadRequest, "API key name is required", "")
+	req.Name = strings.TrimSpace(req.Name)
+	if fields := req.Validate(time.Now()); len(fields) > 0 {
+		respondValidationError(w, fields)
 		return
 	}
 
-	// Create API key
-	apiKey, fullKey, err := h.repo.CreateAPIKey(r.Context(), orgID, req.Name, userID, req.ExpiresAt)
+	// Create API key; the repository enforces the plan's key limit in the same transaction
+	apiKey, fullKey, err := h.repo.CreateAPIKey(r.Context(), orgID, req.Name, req.ExpiresAt, auditActor(r))
 	if err != nil {
+		var limitErr *repository.APIKeyLimitError
+		if errors.As(err, &limitErr) {
+			respondError(w, http.StatusConflict, "API key limit reached",
+				fmt.Sprintf("The %s plan allows at most %d active API keys. Revoke an existing key to create a new one.", limitErr.PlanTier, limitErr.Limit))
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to create API key", err.Error())
 		return
 	}
@@ -139,7 +168,7 @@ func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Revoke API key
-	err := h.repo.RevokeAPIKey(r.Context(), keyID, orgID)
+	err := h.repo.RevokeAPIKey(r.Context(), keyID, orgID, auditActor(r))
 	if err != nil {
 		if err.Error() == "API key not found or already revoked" {
 			respondError(w, http.StatusNotFound, "API key not found or already revoked", "")
@@ -156,3 +185,197 @@ func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	respondJSON(w, http.StatusOK, response)
 }
+
+// RotateAPIKey handles POST /api/v1/apikeys/:id/rotate
+// Issues a replacement key; the old key keeps working for the rotation grace period
+func (h *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Get key ID from URL
+	keyID := chi.URLParam(r, "id")
+	if keyID == "" {
+		respondError(w, http.StatusBadRequest, "Missing API key ID", "")
+		return
+	}
+
+	// Rotation swaps one active key for another, so the plan limit is not checked
+	apiKey, fullKey, err := h.repo.RotateAPIKey(r.Context(), keyID, orgID, h.rotationGrace, auditActor(r))
+	if err != nil {
+		if err.Error() == "API key not found" || err.Error() == "API key not found or already revoked" {
+			respondError(w, http.StatusNotFound, "API key not found or already revoked", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to rotate API key", err.Error())
+		}
+		return
+	}
+
+	response := models.RotateAPIKeyResponse{
+		APIKey:  apiKey,
+		FullKey: fullKey,
+		Message: "API key rotated successfully. The old key no longer works; please save this key as it won't be shown again.",
+	}
+	if h.rotationGrace > 0 {
+		oldKeyValidUntil := time.Now().Add(h.rotationGrace).UTC()
+		response.OldKeyValidUntil = &oldKeyValidUntil
+		response.Message = fmt.Sprintf("API key rotated successfully. The old key keeps working until %s; please save this key as it won't be shown again.",
+			oldKeyValidUntil.Format(time.RFC3339))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetAPIKeyAudit handles GET /api/v1/apikeys/:id/audit
+// Returns the lifecycle history of an API key, oldest first
+func (h *APIKeyHandler) GetAPIKeyAudit(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Get key ID from URL
+	keyID := chi.URLParam(r, "id")
+	if keyID == "" {
+		respondError(w, http.StatusBadRequest, "Missing API key ID", "")
+		return
+	}
+
+	entries, err := h.repo.ListAPIKeyAudit(r.Context(), keyID, orgID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get API key audit log", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"key_id":  keyID,
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// GetAPIKeyUsage handles GET /api/v1/apikeys/:id/usage
+// Summarizes the key's traffic for ?start=YYYY-MM-DD&end=YYYY-MM-DD (default: last 90 days)
+func (h *APIKeyHandler) GetAPIKeyUsage(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Get key ID from URL
+	keyID := chi.URLParam(r, "id")
+	if keyID == "" {
+		respondError(w, http.StatusBadRequest, "Missing API key ID", "")
+		return
+	}
+
+	startDate, endDate, err := parseDateRange(r, time.Now().UTC())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range", err.Error())
+		return
+	}
+
+	usage, err := h.repo.GetAPIKeyUsage(r.Context(), keyID, orgID, startDate, endDate)
+	if err != nil {
+		if err.Error() == "API key not found" {
+			respondError(w, http.StatusNotFound, "API key not found", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to get API key usage", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, usage)
+}
+
+// UpdateAllowedCIDRs handles PUT /api/v1/apikeys/:id/allowed-cidrs
+// Replaces the source IP ranges the key may be used from (an empty list allows any IP)
+func (h *APIKeyHandler) UpdateAllowedCIDRs(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Get key ID from URL
+	keyID := chi.URLParam(r, "id")
+	if keyID == "" {
+		respondError(w, http.StatusBadRequest, "Missing API key ID", "")
+		return
+	}
+
+	// Parse request body
+	var req models.UpdateAllowedCIDRsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if len(req.AllowedCIDRs) > models.MaxAllowedCIDRs {
+		respondError(w, http.StatusBadRequest, "Too many allowed CIDRs",
+			fmt.Sprintf("An API key may have at most %d allowed CIDRs", models.MaxAllowedCIDRs))
+		return
+	}
+
+	cidrs, err := normalizeCIDRs(req.AllowedCIDRs)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid allowed CIDR", err.Error())
+		return
+	}
+
+	apiKey, err := h.repo.UpdateAllowedCIDRs(r.Context(), keyID, orgID, cidrs, auditActor(r))
+	if err != nil {
+		if err.Error() == "API key not found or already revoked" {
+			respondError(w, http.StatusNotFound, "API key not found or already revoked", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to update allowed CIDRs", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, apiKey)
+}
+
+// normalizeCIDRs validates IPv4/IPv6 allowlist entries, turning single addresses into /32
+// or /128 and clearing host bits (203.0.113.7/24 -> 203.0.113.0/24), so the gateway
+// never sees a value it cannot parse
+func normalizeCIDRs(values []string) ([]string, error) {
+	cidrs := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("%q is not a CIDR (e.g. 203.0.113.0/24 or 2001:db8::/32) or IP address", value)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		cidrs = append(cidrs, prefix.Masked().String())
+	}
+	return cidrs, nil
+}
+
+// auditActor identifies the requesting user for the API key audit log
+// RemoteAddr has already been rewritten by the RealIP middleware when a proxy header was present
+func auditActor(r *http.Request) models.APIKeyActor {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		userID = "system" // fallback
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	return models.APIKeyActor{UserID: userID, IPAddress: ip}
+}
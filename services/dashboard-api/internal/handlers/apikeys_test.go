@@ -0,0 +1,664 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeAPIKeyStore is an in-memory apiKeyStore for handler tests
+type fakeAPIKeyStore struct {
+	planTier string
+	keys     []models.APIKey
+	audit    []models.APIKeyAuditEntry
+}
+
+func (f *fakeAPIKeyStore) ListAPIKeys(ctx context.Context, orgID string, opts models.APIKeyListOptions) (*models.APIKeyListResponse, error) {
+	matching := []models.APIKey{}
+	for _, key := range f.keys {
+		if opts.Status == "" || key.Status == opts.Status {
+			matching = append(matching, key)
+		}
+	}
+	resp := &models.APIKeyListResponse{APIKeys: []models.APIKey{}, TotalCount: len(matching), Page: opts.Page, PageSize: opts.PageSize}
+	if start := (opts.Page - 1) * opts.PageSize; start < len(matching) {
+		resp.APIKeys = matching[start:min(start+opts.PageSize, len(matching))]
+	}
+	return resp, nil
+}
+
+func (f *fakeAPIKeyStore) CreateAPIKey(ctx context.Context, orgID, name string, expiresAt *time.Time, actor models.APIKeyActor) (*models.APIKey, string, error) {
+	if limit := models.MaxActiveAPIKeys(f.planTier); f.countedKeys() >= limit {
+		return nil, "", &repository.APIKeyLimitError{PlanTier: f.planTier, Limit: limit}
+	}
+	return f.insert(orgID, name, expiresAt, actor)
+}
+
+func (f *fakeAPIKeyStore) insert(orgID, name string, expiresAt *time.Time, actor models.APIKeyActor) (*models.APIKey, string, error) {
+	key := models.APIKey{
+		ID:             fmt.Sprintf("key-%d", len(f.keys)+1),
+		OrganizationID: orgID,
+		Name:           name,
+		Status:         "active",
+		CreatedBy:      actor.UserID,
+		CreatedAt:      time.Now(),
+	}
+	f.keys = append(f.keys, key)
+	f.recordAudit(key.ID, models.APIKeyAuditCreated, actor)
+	return &key, "sk_test_full_key", nil
+}
+
+func (f *fakeAPIKeyStore) GetAPIKey(ctx context.Context, keyID, orgID string) (*models.APIKey, error) {
+	for i := range f.keys {
+		if f.keys[i].ID == keyID {
+			return &f.keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("API key not found")
+}
+
+func (f *fakeAPIKeyStore) RevokeAPIKey(ctx context.Context, keyID, orgID string, actor models.APIKeyActor) error {
+	return f.revoke(keyID, models.APIKeyAuditRevoked, actor)
+}
+
+func (f *fakeAPIKeyStore) RotateAPIKey(ctx context.Context, keyID, orgID string, grace time.Duration, actor models.APIKeyActor) (*models.APIKey, string, error) {
+	old, err := f.GetAPIKey(ctx, keyID, orgID)
+	if err != nil {
+		return nil, "", err
+	}
+	if old.Status != "active" {
+		return nil, "", fmt.Errorf("API key not found or already revoked")
+	}
+	name, expiresAt := old.Name, old.ExpiresAt
+	if grace <= 0 {
+		f.revoke(keyID, models.APIKeyAuditRotated, actor)
+	} else {
+		graceEnd := time.Now().Add(grace)
+		old.Status = "rotating"
+		old.RotationExpiresAt = &graceEnd
+		f.recordAudit(keyID, models.APIKeyAuditRotated, actor)
+	}
+	return f.insert(orgID, name, expiresAt, actor)
+}
+
+func (f *fakeAPIKeyStore) ListAPIKeyAudit(ctx context.Context, keyID, orgID string) ([]models.APIKeyAuditEntry, error) {
+	entries := []models.APIKeyAuditEntry{}
+	for _, entry := range f.audit {
+		if entry.KeyID == keyID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeAPIKeyStore) GetAPIKeyUsage(ctx context.Context, keyID, orgID string, startDate, endDate time.Time) (*models.APIKeyUsageResponse, error) {
+	for _, key := range f.keys {
+		if key.ID == keyID && key.OrganizationID == orgID {
+			return &models.APIKeyUsageResponse{
+				KeyID:     keyID,
+				StartDate: startDate.Format("2006-01-02"),
+				EndDate:   endDate.Format("2006-01-02"),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("API key not found")
+}
+
+func (f *fakeAPIKeyStore) UpdateAllowedCIDRs(ctx context.Context, keyID, orgID string, cidrs []string, actor models.APIKeyActor) (*models.APIKey, error) {
+	for i := range f.keys {
+		if f.keys[i].ID == keyID && f.keys[i].Status != "revoked" {
+			f.keys[i].AllowedCIDRs = cidrs
+			f.recordAudit(keyID, models.APIKeyAuditAllowlistUpdated, actor)
+			return &f.keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("API key not found or already revoked")
+}
+
+func (f *fakeAPIKeyStore) revoke(keyID, action string, actor models.APIKeyActor) error {
+	for i := range f.keys {
+		if f.keys[i].ID == keyID && f.keys[i].Status == "active" {
+			f.keys[i].Status = "revoked"
+			f.recordAudit(keyID, action, actor)
+			return nil
+		}
+	}
+	return fmt.Errorf("API key not found or already revoked")
+}
+
+func (f *fakeAPIKeyStore) recordAudit(keyID, action string, actor models.APIKeyActor) {
+	f.audit = append(f.audit, models.APIKeyAuditEntry{
+		ID:          fmt.Sprintf("audit-%d", len(f.audit)+1),
+		KeyID:       keyID,
+		Action:      action,
+		ActorUserID: actor.UserID,
+		IPAddress:   actor.IPAddress,
+		CreatedAt:   time.Now(),
+	})
+}
+
+// countedKeys returns the keys that count against the plan's limit, like APIKeyRepository
+func (f *fakeAPIKeyStore) countedKeys() int {
+	count := 0
+	for _, key := range f.keys {
+		if key.Status == "active" || key.Status == "rotating" {
+			count++
+		}
+	}
+	return count
+}
+
+// newCreateKeyRequest builds an authenticated POST /api/v1/apikeys request
+func newCreateKeyRequest(name string) *http.Request {
+	return newCreateKeyRequestBody(`{"name":"` + name + `"}`)
+}
+
+// newCreateKeyRequestBody builds an authenticated POST /api/v1/apikeys request with a raw body
+func newCreateKeyRequestBody(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/apikeys", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), "organization_id", "org-123")
+	ctx = context.WithValue(ctx, "user_id", "user-1")
+	return req.WithContext(ctx)
+}
+
+// TestCreateAPIKey_PlanLimit tests creating keys up to the plan limit and rejecting the next one
+func TestCreateAPIKey_PlanLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		planTier string
+		limit    int
+	}{
+		{"Basic plan", "basic", models.MaxActiveAPIKeysByPlan["basic"]},
+		{"Premium plan", "premium", models.MaxActiveAPIKeysByPlan["premium"]},
+		{"Unknown plan falls back to basic", "legacy", models.MaxActiveAPIKeysByPlan["basic"]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeAPIKeyStore{planTier: tt.planTier}
+			h := &APIKeyHandler{repo: store}
+
+			// Create up to the limit
+			for i := 0; i < tt.limit; i++ {
+				rec := httptest.NewRecorder()
+				h.CreateAPIKey(rec, newCreateKeyRequest(fmt.Sprintf("key %d", i)))
+				if rec.Code != http.StatusCreated {
+					t.Fatalf("Create #%d status = %d, want %d (body: %s)", i+1, rec.Code, http.StatusCreated, rec.Body.String())
+				}
+			}
+
+			// Next creation is rejected
+			rec := httptest.NewRecorder()
+			h.CreateAPIKey(rec, newCreateKeyRequest("one too many"))
+			if rec.Code != http.StatusConflict {
+				t.Fatalf("Create over limit status = %d, want %d", rec.Code, http.StatusConflict)
+			}
+			if len(store.keys) != tt.limit {
+				t.Errorf("Stored keys = %d, want %d", len(store.keys), tt.limit)
+			}
+		})
+	}
+}
+
+// TestCreateAPIKey_RevokedKeysFreeSlots tests that revoking a key allows a new one
+func TestCreateAPIKey_RevokedKeysFreeSlots(t *testing.T) {
+	store := &fakeAPIKeyStore{planTier: "basic"}
+	h := &APIKeyHandler{repo: store}
+	limit := models.MaxActiveAPIKeys("basic")
+
+	for i := 0; i < limit; i++ {
+		h.CreateAPIKey(httptest.NewRecorder(), newCreateKeyRequest(fmt.Sprintf("key %d", i)))
+	}
+
+	if err := store.RevokeAPIKey(context.Background(), "key-1", "org-123", models.APIKeyActor{UserID: "user-1"}); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.CreateAPIKey(rec, newCreateKeyRequest("replacement"))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Create after revoke status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+}
+
+// TestCreateAPIKey_RotatingKeysCount tests that a key in its rotation grace period keeps its slot
+func TestCreateAPIKey_RotatingKeysCount(t *testing.T) {
+	store := &fakeAPIKeyStore{planTier: "basic"}
+	h := &APIKeyHandler{repo: store, rotationGrace: time.Hour}
+	limit := models.MaxActiveAPIKeys("basic")
+
+	for i := 0; i < limit; i++ {
+		h.CreateAPIKey(httptest.NewRecorder(), newCreateKeyRequest(fmt.Sprintf("key %d", i)))
+	}
+
+	// Rotating is not blocked by the limit
+	rec := httptest.NewRecorder()
+	h.RotateAPIKey(rec, newKeyRequest(http.MethodPost, "/api/v1/apikeys/key-1/rotate", "key-1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Rotate at limit status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateAPIKey(rec, newCreateKeyRequest("during grace"))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Create during rotation grace status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+// decodeValidationError decodes a validation_failed response, failing the test on any other shape
+func decodeValidationError(t *testing.T, rec *httptest.ResponseRecorder) models.ErrorResponse {
+	t.Helper()
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	var resp models.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if resp.Code != models.ErrCodeValidationFailed {
+		t.Errorf("Code = %q, want %q", resp.Code, models.ErrCodeValidationFailed)
+	}
+	return resp
+}
+
+// TestCreateAPIKey_Validation tests the field-level errors returned for invalid key requests
+func TestCreateAPIKey_Validation(t *testing.T) {
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	future := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	longName := strings.Repeat("k", models.MaxAPIKeyNameLength+1)
+
+	tests := []struct {
+		name     string
+		body     string
+		expected []models.FieldError
+	}{
+		{"Missing name", `{}`, []models.FieldError{{Field: "name", Code: models.FieldCodeRequired}}},
+		{"Blank name", `{"name":"   "}`, []models.FieldError{{Field: "name", Code: models.FieldCodeRequired}}},
+		{"Name too long", `{"name":"` + longName + `"}`, []models.FieldError{{Field: "name", Code: models.FieldCodeTooLong}}},
+		{"Expiry in the past", `{"name":"ci","expires_at":"` + past + `"}`, []models.FieldError{{Field: "expires_at", Code: models.FieldCodeNotInFuture}}},
+		{"Every field invalid", `{"name":"","expires_at":"` + past + `"}`, []models.FieldError{
+			{Field: "name", Code: models.FieldCodeRequired},
+			{Field: "expires_at", Code: models.FieldCodeNotInFuture},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeAPIKeyStore{planTier: "basic"}
+			h := &APIKeyHandler{repo: store}
+			rec := httptest.NewRecorder()
+			h.CreateAPIKey(rec, newCreateKeyRequestBody(tt.body))
+
+			resp := decodeValidationError(t, rec)
+			if len(resp.Fields) != len(tt.expected) {
+				t.Fatalf("Fields = %+v, want %d entries", resp.Fields, len(tt.expected))
+			}
+			for i, want := range tt.expected {
+				got := resp.Fields[i]
+				if got.Field != want.Field || got.Code != want.Code || got.Message == "" {
+					t.Errorf("Fields[%d] = %+v, want field %q with code %q and a message", i, got, want.Field, want.Code)
+				}
+			}
+			if len(store.keys) != 0 {
+				t.Errorf("Stored keys = %d, want 0", len(store.keys))
+			}
+		})
+	}
+
+	// A name at the limit with a future expiry is accepted
+	store := &fakeAPIKeyStore{planTier: "basic"}
+	h := &APIKeyHandler{repo: store}
+	rec := httptest.NewRecorder()
+	h.CreateAPIKey(rec, newCreateKeyRequestBody(`{"name":"`+longName[1:]+`","expires_at":"`+future+`"}`))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Valid create status = %d, want %d (body: %s)", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+}
+
+// TestErrorResponse_ValidationShape pins the JSON clients parse for validation errors
+func TestErrorResponse_ValidationShape(t *testing.T) {
+	rec := httptest.NewRecorder()
+	respondValidationError(rec, []models.FieldError{{Field: "name", Code: models.FieldCodeRequired, Message: "API key name is required"}})
+
+	expected := `{"error":"Invalid request","message":"One or more fields are invalid","code":"validation_failed",` +
+		`"fields":[{"field":"name","code":"required","message":"API key name is required"}]}`
+	if got := strings.TrimSpace(rec.Body.String()); got != expected {
+		t.Errorf("Body = %s\nwant %s", got, expected)
+	}
+}
+
+// TestListAPIKeys_Pagination tests the page envelope at the edges of the key list
+func TestListAPIKeys_Pagination(t *testing.T) {
+	store := &fakeAPIKeyStore{}
+	for i := 1; i <= 45; i++ {
+		status := "active"
+		if i%5 == 0 {
+			status = "revoked"
+		}
+		store.keys = append(store.keys, models.APIKey{ID: fmt.Sprintf("key-%03d", i), OrganizationID: "org-123", Status: status})
+	}
+	h := &APIKeyHandler{repo: store}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedPage  int
+		expectedSize  int
+		expectedCount int
+		expectedTotal int
+		expectedFirst string
+	}{
+		{"Defaults", "", 1, 20, 20, 45, "key-001"},
+		{"Last partial page", "?page=3&page_size=20", 3, 20, 5, 45, "key-041"},
+		{"Exactly the last key", "?page=45&page_size=1", 45, 1, 1, 45, "key-045"},
+		{"Past the end", "?page=4&page_size=20", 4, 20, 0, 45, ""},
+		{"Maximum page_size", "?page_size=100", 1, 100, 45, 45, "key-001"},
+		{"Oversized page_size falls back to default", "?page_size=101", 1, 20, 20, 45, "key-001"},
+		{"Invalid page falls back to first", "?page=0&page_size=10", 1, 10, 10, 45, "key-001"},
+		{"Status filter counts matching keys only", "?status=revoked&page=2&page_size=5", 2, 5, 4, 9, "key-030"},
+		{"Sort is accepted", "?sort=last_used_at", 1, 20, 20, 45, "key-001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ListAPIKeys(rec, newKeyRequest(http.MethodGet, "/api/v1/apikeys"+tt.query, ""))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			var resp models.APIKeyListResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Page != tt.expectedPage || resp.PageSize != tt.expectedSize {
+				t.Errorf("Page/PageSize = %d/%d, want %d/%d", resp.Page, resp.PageSize, tt.expectedPage, tt.expectedSize)
+			}
+			if len(resp.APIKeys) != tt.expectedCount || resp.TotalCount != tt.expectedTotal {
+				t.Errorf("Keys/TotalCount = %d/%d, want %d/%d", len(resp.APIKeys), resp.TotalCount, tt.expectedCount, tt.expectedTotal)
+			}
+			if resp.APIKeys == nil {
+				t.Error("api_keys is null, want an empty list")
+			}
+			if tt.expectedFirst != "" && len(resp.APIKeys) > 0 && resp.APIKeys[0].ID != tt.expectedFirst {
+				t.Errorf("First key = %s, want %s", resp.APIKeys[0].ID, tt.expectedFirst)
+			}
+		})
+	}
+}
+
+// TestListAPIKeys_InvalidParams tests that unsupported sort and status values are rejected
+func TestListAPIKeys_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"Unknown sort column", "?sort=key_hash", "sort"},
+		{"Injection attempt", "?sort=created_at%3B+DROP+TABLE+api_keys", "sort"},
+		{"Descending syntax", "?sort=-name", "sort"},
+		{"Unknown status", "?status=deleted", "status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &APIKeyHandler{repo: &fakeAPIKeyStore{}}
+			rec := httptest.NewRecorder()
+			h.ListAPIKeys(rec, newKeyRequest(http.MethodGet, "/api/v1/apikeys"+tt.query, ""))
+
+			resp := decodeValidationError(t, rec)
+			if len(resp.Fields) != 1 || resp.Fields[0].Field != tt.field || resp.Fields[0].Code != models.FieldCodeInvalidChoice {
+				t.Errorf("Fields = %+v, want one %s error with code %s", resp.Fields, tt.field, models.FieldCodeInvalidChoice)
+			}
+		})
+	}
+}
+
+// newKeyRequest builds an authenticated request for an /api/v1/apikeys/{id} route
+func newKeyRequest(method, path, keyID string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "203.0.113.7:52100"
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", keyID)
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+	ctx = context.WithValue(ctx, "organization_id", "org-123")
+	ctx = context.WithValue(ctx, "user_id", "user-1")
+	return req.WithContext(ctx)
+}
+
+// TestAPIKeyAudit_Lifecycle tests that create, rotate and revoke are recorded with the actor and IP
+func TestAPIKeyAudit_Lifecycle(t *testing.T) {
+	store := &fakeAPIKeyStore{planTier: "basic"}
+	h := &APIKeyHandler{repo: store}
+
+	createReq := newCreateKeyRequest("production")
+	createReq.RemoteAddr = "203.0.113.7:52100"
+	h.CreateAPIKey(httptest.NewRecorder(), createReq)
+
+	rec := httptest.NewRecorder()
+	h.RotateAPIKey(rec, newKeyRequest(http.MethodPost, "/api/v1/apikeys/key-1/rotate", "key-1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Rotate status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.RevokeAPIKey(rec, newKeyRequest(http.MethodDelete, "/api/v1/apikeys/key-2", "key-2"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Revoke status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetAPIKeyAudit(rec, newKeyRequest(http.MethodGet, "/api/v1/apikeys/key-1/audit", "key-1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Audit status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Entries []models.APIKeyAuditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode audit response: %v", err)
+	}
+
+	wantActions := []string{models.APIKeyAuditCreated, models.APIKeyAuditRotated}
+	if len(body.Entries) != len(wantActions) {
+		t.Fatalf("Audit entries = %d, want %d", len(body.Entries), len(wantActions))
+	}
+	for i, entry := range body.Entries {
+		if entry.Action != wantActions[i] {
+			t.Errorf("Entry %d action = %s, want %s", i, entry.Action, wantActions[i])
+		}
+		if entry.ActorUserID != "user-1" || entry.IPAddress != "203.0.113.7" {
+			t.Errorf("Entry %d actor = %s from %s, want user-1 from 203.0.113.7", i, entry.ActorUserID, entry.IPAddress)
+		}
+	}
+
+	// The replacement key's history starts with its creation and ends with the revoke
+	if got, _ := store.ListAPIKeyAudit(context.Background(), "key-2", "org-123"); len(got) != 2 || got[1].Action != models.APIKeyAuditRevoked {
+		t.Errorf("Replacement key audit = %+v, want created then revoked", got)
+	}
+}
+
+// TestRotateAPIKey_RevokedKey tests that a revoked key cannot be rotated
+func TestRotateAPIKey_RevokedKey(t *testing.T) {
+	store := &fakeAPIKeyStore{planTier: "basic"}
+	h := &APIKeyHandler{repo: store}
+
+	h.CreateAPIKey(httptest.NewRecorder(), newCreateKeyRequest("staging"))
+	store.RevokeAPIKey(context.Background(), "key-1", "org-123", models.APIKeyActor{UserID: "user-1"})
+
+	rec := httptest.NewRecorder()
+	h.RotateAPIKey(rec, newKeyRequest(http.MethodPost, "/api/v1/apikeys/key-1/rotate", "key-1"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Rotate revoked key status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if len(store.keys) != 1 {
+		t.Errorf("Stored keys = %d, want no replacement for a revoked key", len(store.keys))
+	}
+}
+
+// TestRotateAPIKey_GracePeriod tests that the old key stays rotating alongside its replacement
+func TestRotateAPIKey_GracePeriod(t *testing.T) {
+	store := &fakeAPIKeyStore{planTier: "basic"}
+	h := &APIKeyHandler{repo: store, rotationGrace: 24 * time.Hour}
+
+	h.CreateAPIKey(httptest.NewRecorder(), newCreateKeyRequest("production"))
+
+	rec := httptest.NewRecorder()
+	h.RotateAPIKey(rec, newKeyRequest(http.MethodPost, "/api/v1/apikeys/key-1/rotate", "key-1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Rotate status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var body models.RotateAPIKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode rotate response: %v", err)
+	}
+	if body.FullKey == "" || body.APIKey.ID != "key-2" {
+		t.Errorf("Rotate returned key %s with full key %q, want key-2 and its full key", body.APIKey.ID, body.FullKey)
+	}
+	if body.OldKeyValidUntil == nil || time.Until(*body.OldKeyValidUntil) < 23*time.Hour {
+		t.Errorf("OldKeyValidUntil = %v, want about 24h from now", body.OldKeyValidUntil)
+	}
+
+	// Both keys authenticate during the overlap window
+	old, _ := store.GetAPIKey(context.Background(), "key-1", "org-123")
+	if old.Status != "rotating" || old.RotationExpiresAt == nil {
+		t.Errorf("Old key status = %s (grace end %v), want rotating", old.Status, old.RotationExpiresAt)
+	}
+	if count := store.countedKeys(); count != 2 {
+		t.Errorf("Counted keys = %d, want the rotating key counted against the plan with its replacement", count)
+	}
+
+	// A key already rotating cannot be rotated again
+	rec = httptest.NewRecorder()
+	h.RotateAPIKey(rec, newKeyRequest(http.MethodPost, "/api/v1/apikeys/key-1/rotate", "key-1"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Second rotate status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// newAllowedCIDRsRequest builds an authenticated PUT /api/v1/apikeys/{id}/allowed-cidrs request
+func newAllowedCIDRsRequest(keyID, body string) *http.Request {
+	req := newKeyRequest(http.MethodPut, "/api/v1/apikeys/"+keyID+"/allowed-cidrs", keyID)
+	req.Body = io.NopCloser(strings.NewReader(body))
+	return req
+}
+
+// TestUpdateAllowedCIDRs tests validating, normalizing and storing a key's IP allowlist
+func TestUpdateAllowedCIDRs(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+		cidrs    []string
+	}{
+		{"IPv4 and IPv6 ranges", `{"allowed_cidrs":["203.0.113.0/24","2001:db8::/32"]}`, http.StatusOK, []string{"203.0.113.0/24", "2001:db8::/32"}},
+		{"Single addresses and host bits are normalized", `{"allowed_cidrs":["198.51.100.7","10.1.2.3/8","2001:db8::1"]}`, http.StatusOK, []string{"198.51.100.7/32", "10.0.0.0/8", "2001:db8::1/128"}},
+		{"Empty list removes the restriction", `{"allowed_cidrs":[]}`, http.StatusOK, []string{}},
+		{"Invalid CIDR", `{"allowed_cidrs":["203.0.113.0/33"]}`, http.StatusBadRequest, nil},
+		{"Not an address", `{"allowed_cidrs":["office"]}`, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeAPIKeyStore{planTier: "basic"}
+			h := &APIKeyHandler{repo: store}
+			h.CreateAPIKey(httptest.NewRecorder(), newCreateKeyRequest("office"))
+
+			rec := httptest.NewRecorder()
+			h.UpdateAllowedCIDRs(rec, newAllowedCIDRsRequest("key-1", tt.body))
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+			if tt.expected != http.StatusOK {
+				if store.keys[0].AllowedCIDRs != nil {
+					t.Errorf("AllowedCIDRs = %v, want unchanged after a rejected update", store.keys[0].AllowedCIDRs)
+				}
+				return
+			}
+
+			got := store.keys[0].AllowedCIDRs
+			if len(got) != len(tt.cidrs) {
+				t.Fatalf("AllowedCIDRs = %v, want %v", got, tt.cidrs)
+			}
+			for i := range tt.cidrs {
+				if got[i] != tt.cidrs[i] {
+					t.Errorf("CIDR %d = %s, want %s", i, got[i], tt.cidrs[i])
+				}
+			}
+
+			audit, _ := store.ListAPIKeyAudit(context.Background(), "key-1", "org-123")
+			if last := audit[len(audit)-1]; last.Action != models.APIKeyAuditAllowlistUpdated {
+				t.Errorf("Last audit action = %s, want %s", last.Action, models.APIKeyAuditAllowlistUpdated)
+			}
+		})
+	}
+}
+
+// TestUpdateAllowedCIDRs_RevokedKey tests that a revoked key's allowlist cannot be changed
+func TestUpdateAllowedCIDRs_RevokedKey(t *testing.T) {
+	store := &fakeAPIKeyStore{planTier: "basic"}
+	h := &APIKeyHandler{repo: store}
+	h.CreateAPIKey(httptest.NewRecorder(), newCreateKeyRequest("old"))
+	store.RevokeAPIKey(context.Background(), "key-1", "org-123", models.APIKeyActor{UserID: "user-1"})
+
+	rec := httptest.NewRecorder()
+	h.UpdateAllowedCIDRs(rec, newAllowedCIDRsRequest("key-1", `{"allowed_cidrs":["203.0.113.0/24"]}`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// TestGetAPIKeyUsage tests the date range handling and that other organizations' keys are hidden
+func TestGetAPIKeyUsage(t *testing.T) {
+	store := &fakeAPIKeyStore{keys: []models.APIKey{
+		{ID: "key-1", OrganizationID: "org-123", Status: "active"},
+		{ID: "key-other", OrganizationID: "org-456", Status: "active"},
+	}}
+	h := &APIKeyHandler{repo: store}
+
+	tests := []struct {
+		name     string
+		keyID    string
+		query    string
+		expected int
+	}{
+		{"Own key", "key-1", "?start=2026-03-01&end=2026-03-31", http.StatusOK},
+		{"Other organization's key", "key-other", "?start=2026-03-01&end=2026-03-31", http.StatusNotFound},
+		{"Unknown key", "key-missing", "", http.StatusNotFound},
+		{"Start after end", "key-1", "?start=2026-04-01&end=2026-03-01", http.StatusBadRequest},
+		{"Invalid date", "key-1", "?start=March", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.GetAPIKeyUsage(rec, newKeyRequest(http.MethodGet, "/api/v1/apikeys/"+tt.keyID+"/usage"+tt.query, tt.keyID))
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var usage models.APIKeyUsageResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &usage); err != nil {
+				t.Fatalf("Failed to decode usage response: %v", err)
+			}
+			if usage.KeyID != tt.keyID || usage.StartDate != "2026-03-01" || usage.EndDate != "2026-03-31" {
+				t.Errorf("Usage = %+v, want key-1 from 2026-03-01 to 2026-03-31", usage)
+			}
+		})
+	}
+}
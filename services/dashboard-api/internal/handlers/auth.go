@@ -3,7 +3,10 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/config"
@@ -14,19 +17,22 @@ import (
 
 // AuthHandler handles authentication operations
 type AuthHandler struct {
-	db  *sql.DB
-	cfg *config.Config
+	db      *sql.DB
+	cfg     *config.Config
+	limiter *loginLimiter
 }
 
 // NewAuthHandler creates a new auth handler
 func NewAuthHandler(db *sql.DB, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		db:  db,
-		cfg: cfg,
+		db:      db,
+		cfg:     cfg,
+		limiter: newLoginLimiter(),
 	}
 }
 
 // Login handles user login and JWT token generation
+// Repeated wrong passwords lock out the email/IP pair with 429 and Retry-After
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -34,9 +40,18 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
-	if req.Email == "" || req.Password == "" {
-		respondError(w, http.StatusBadRequest, "Email and password are required", "")
+	// Validate input before it reaches the limiter or the database
+	req.Email = strings.TrimSpace(req.Email)
+	if fields := req.Validate(); len(fields) > 0 {
+		respondValidationError(w, fields)
+		return
+	}
+
+	// Throttle password guessing; the attempt counts as a failure unless the password is right
+	ip := clientIP(r)
+	if wait := h.limiter.Allow(req.Email, ip); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondError(w, http.StatusTooManyRequests, "Too many login attempts", "Try again later")
 		return
 	}
 
@@ -56,6 +71,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusUnauthorized, "Invalid credentials", "")
 		return
 	}
+	h.limiter.Success(req.Email, ip)
 
 	// Update last login timestamp
 	go h.updateLastLogin(user.ID)
@@ -175,3 +191,13 @@ func respondError(w http.ResponseWriter, status int, message, details string) {
 	}
 	respondJSON(w, status, resp)
 }
+
+// respondValidationError writes a 400 listing each invalid field
+func respondValidationError(w http.ResponseWriter, fields []models.FieldError) {
+	respondJSON(w, http.StatusBadRequest, models.ErrorResponse{
+		Error:   "Invalid request",
+		Message: "One or more fields are invalid",
+		Code:    models.ErrCodeValidationFailed,
+		Fields:  fields,
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// TestLogin_Validation tests that malformed credentials are rejected before any database lookup
+func TestLogin_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected map[string]string // field -> code
+	}{
+		{"Empty body", `{}`, map[string]string{"email": models.FieldCodeRequired, "password": models.FieldCodeRequired}},
+		{"Missing password", `{"email":"alice@acme.test"}`, map[string]string{"password": models.FieldCodeRequired}},
+		{"No at sign", `{"email":"alice.acme.test","password":"secret"}`, map[string]string{"email": models.FieldCodeInvalidFormat}},
+		{"No domain dot", `{"email":"alice@localhost","password":"secret"}`, map[string]string{"email": models.FieldCodeInvalidFormat}},
+		{"Display name", `{"email":"Alice <alice@acme.test>","password":"secret"}`, map[string]string{"email": models.FieldCodeInvalidFormat}},
+		{"Two addresses", `{"email":"alice@acme.test, bob@acme.test","password":"secret"}`, map[string]string{"email": models.FieldCodeInvalidFormat}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No database: reaching the lookup would panic
+			h := &AuthHandler{limiter: newLoginLimiter()}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(tt.body))
+			req.RemoteAddr = "203.0.113.7:51234"
+			rec := httptest.NewRecorder()
+			h.Login(rec, req)
+
+			resp := decodeValidationError(t, rec)
+			if len(resp.Fields) != len(tt.expected) {
+				t.Fatalf("Fields = %+v, want %d entries", resp.Fields, len(tt.expected))
+			}
+			for _, field := range resp.Fields {
+				if want, ok := tt.expected[field.Field]; !ok || field.Code != want {
+					t.Errorf("Field error %+v not expected; want %v", field, tt.expected)
+				}
+			}
+
+			// Rejected requests do not count against the limiter
+			if len(h.limiter.pairs) != 0 {
+				t.Errorf("Limiter entries = %d, want 0", len(h.limiter.pairs))
+			}
+		})
+	}
+}
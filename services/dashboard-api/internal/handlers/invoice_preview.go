@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// invoiceQuoter prices the billing period in progress (implemented by quote.Quoter)
+type invoiceQuoter interface {
+	Quote(orgID string) (*quote.Quote, error)
+}
+
+// PreviewInvoice handles GET /api/v1/invoices/preview
+// Returns a projected invoice for the billing period in progress from real-time usage, priced by
+// the billing engine's own calculator and line item builder (nothing is persisted)
+func (h *InvoiceHandler) PreviewInvoice(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	asOf := time.Now().UTC()
+	q, err := h.quotes.Quote(orgID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to price usage", err.Error())
+		return
+	}
+	if q.Plan == nil {
+		respondError(w, http.StatusNotFound, "No active subscription", "")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, buildInvoicePreview(orgID, q, h.taxRate, asOf))
+}
+
+// buildInvoicePreview adds the estimated tax to the billing engine's quote of the period
+func buildInvoicePreview(orgID string, q *quote.Quote, taxRate float64, asOf time.Time) *models.InvoicePreview {
+	calc := q.Calculation
+	taxCents := int64(float64(calc.TotalCharge) * taxRate)
+
+	lineItems := make([]models.InvoiceLineItem, 0, len(q.LineItems))
+	for _, item := range q.LineItems {
+		lineItems = append(lineItems, models.InvoiceLineItem{
+			Description: item.Description,
+			Quantity:    float64(item.Quantity),
+			UnitPrice:   centsToDollars(item.UnitPriceCents),
+			Amount:      centsToDollars(item.AmountCents),
+		})
+	}
+
+	return &models.InvoicePreview{
+		OrganizationID:     orgID,
+		IsEstimate:         true,
+		AsOf:               asOf,
+		PlanName:           calc.PlanName,
+		BillingPeriodStart: q.PeriodStart,
+		BillingPeriodEnd:   q.PeriodEnd,
+		UsedUnits:          calc.UsedUnits,
+		IncludedUnits:      calc.IncludedUnits,
+		OverageUnits:       calc.OverageUnits,
+		UnitLabel:          q.UnitLabel,
+		LineItems:          lineItems,
+		Subtotal:           centsToDollars(calc.TotalCharge),
+		EstimatedTax:       centsToDollars(taxCents),
+		Total:              centsToDollars(calc.TotalCharge + taxCents),
+		Currency:           "USD",
+		Note:               "Estimate based on usage as of " + asOf.Format(time.RFC3339) + "; the final invoice is issued after the billing period ends",
+	}
+}
+
+// centsToDollars converts integer cents to a dollar amount
+func centsToDollars(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// formatUnits formats large usage numbers with K/M suffix (same as invoice descriptions)
+func formatUnits(units int64) string {
+	if units >= 1000000 {
+		return fmt.Sprintf("%.1fM", float64(units)/1000000.0)
+	} else if units >= 1000 {
+		return fmt.Sprintf("%.1fK", float64(units)/1000.0)
+	}
+	return fmt.Sprintf("%d", units)
+}
@@ -1,23 +1,46 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
 	"github.com/go-chi/chi/v5"
 )
 
+// invoiceStore is the subset of InvoiceRepository used by the handler
+type invoiceStore interface {
+	ListInvoices(ctx context.Context, orgID string, page, pageSize int) (*models.InvoiceListResponse, error)
+	GetInvoice(ctx context.Context, invoiceID, orgID string) (*models.Invoice, error)
+	GetInvoiceLineItems(ctx context.Context, invoiceID, orgID string) ([]models.InvoiceLineItem, error)
+	ListInvoiceLineItems(ctx context.Context, invoiceID, orgID string, page, pageSize int) (*models.InvoiceLineItemListResponse, error)
+	GetInvoicePDFURL(ctx context.Context, invoiceID, orgID string) (string, error)
+	VoidInvoice(ctx context.Context, invoiceID, orgID, reason, actorUserID string) (*models.Invoice, error)
+	UpdateInvoice(ctx context.Context, invoiceID, orgID string, update models.UpdateInvoiceRequest, actorUserID string) (*models.Invoice, error)
+	RequestRefund(ctx context.Context, invoiceID, orgID string, amountCents int64, reason, actorUserID string) (*models.InvoiceRefund, error)
+}
+
 // InvoiceHandler handles invoice-related requests
 type InvoiceHandler struct {
-	repo *repository.InvoiceRepository
+	repo    invoiceStore
+	quotes  invoiceQuoter // Prices invoice previews
+	taxRate float64       // Used for invoice preview estimates
 }
 
 // NewInvoiceHandler creates a new invoice handler
-func NewInvoiceHandler(db *sql.DB) *InvoiceHandler {
+func NewInvoiceHandler(db *sql.DB, quoter *quote.Quoter, taxRate float64) *InvoiceHandler {
 	return &InvoiceHandler{
-		repo: repository.NewInvoiceRepository(db),
+		repo:    repository.NewInvoiceRepository(db),
+		quotes:  quoter,
+		taxRate: taxRate,
 	}
 }
 
@@ -32,23 +55,7 @@ func (h *InvoiceHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse pagination parameters
-	pageStr := r.URL.Query().Get("page")
-	pageSizeStr := r.URL.Query().Get("page_size")
-
-	page := 1
-	pageSize := 20 // default page size
-
-	if pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
-			page = parsedPage
-		}
-	}
-
-	if pageSizeStr != "" {
-		if parsedPageSize, err := strconv.Atoi(pageSizeStr); err == nil && parsedPageSize > 0 && parsedPageSize <= 100 {
-			pageSize = parsedPageSize
-		}
-	}
+	page, pageSize := parsePagination(r)
 
 	// Get invoices
 	invoices, err := h.repo.ListInvoices(r.Context(), orgID, page, pageSize)
@@ -89,7 +96,7 @@ func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get line items
-	lineItems, err := h.repo.GetInvoiceLineItems(r.Context(), invoiceID)
+	lineItems, err := h.repo.GetInvoiceLineItems(r.Context(), invoiceID, orgID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get invoice line items", err.Error())
 		return
@@ -102,6 +109,39 @@ func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ListInvoiceLineItems handles GET /api/v1/invoices/:id/line-items
+// Returns paginated line items for an invoice owned by the organization
+func (h *InvoiceHandler) ListInvoiceLineItems(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Get invoice ID from URL
+	invoiceID := chi.URLParam(r, "id")
+	if invoiceID == "" {
+		respondError(w, http.StatusBadRequest, "Missing invoice ID", "")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+
+	// Get line items (ownership enforced by the repository)
+	lineItems, err := h.repo.ListInvoiceLineItems(r.Context(), invoiceID, orgID, page, pageSize)
+	if err != nil {
+		if err.Error() == "invoice not found" {
+			respondError(w, http.StatusNotFound, "Invoice not found", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to get invoice line items", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, lineItems)
+}
+
 // GetInvoicePDF handles GET /api/v1/invoices/:id/pdf
 // Returns the PDF URL for downloading an invoice
 func (h *InvoiceHandler) GetInvoicePDF(w http.ResponseWriter, r *http.Request) {
@@ -136,3 +176,241 @@ func (h *InvoiceHandler) GetInvoicePDF(w http.ResponseWriter, r *http.Request) {
 	// For better UX, we redirect to the PDF URL
 	http.Redirect(w, r, pdfURL, http.StatusFound)
 }
+
+// VoidInvoice handles POST /api/v1/invoices/:id/void (admin only)
+// Voids an unpaid invoice; paid invoices must be refunded instead
+func (h *InvoiceHandler) VoidInvoice(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID and user ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(string)
+
+	// Get invoice ID from URL
+	invoiceID := chi.URLParam(r, "id")
+	if invoiceID == "" {
+		respondError(w, http.StatusBadRequest, "Missing invoice ID", "")
+		return
+	}
+
+	// Parse request body
+	var req models.VoidInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondError(w, http.StatusBadRequest, "Void reason is required", "")
+		return
+	}
+
+	invoice, err := h.repo.VoidInvoice(r.Context(), invoiceID, orgID, req.Reason, userID)
+	if err != nil {
+		switch err.Error() {
+		case "invoice not found":
+			respondError(w, http.StatusNotFound, "Invoice not found", "")
+		case "invoice is paid":
+			respondError(w, http.StatusConflict, "Paid invoices cannot be voided", "Issue a refund instead")
+		case "invoice already voided":
+			respondError(w, http.StatusConflict, "Invoice is already voided", "")
+		case "invoice cannot be voided":
+			respondError(w, http.StatusConflict, "Invoice cannot be voided", "")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to void invoice", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, invoice)
+}
+
+// UpdateInvoice handles PATCH /api/v1/invoices/:id (admin only)
+// Sets the notes, PO number, and custom fields of an unpaid invoice; paid and voided invoices are final
+func (h *InvoiceHandler) UpdateInvoice(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID and user ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(string)
+
+	// Get invoice ID from URL
+	invoiceID := chi.URLParam(r, "id")
+	if invoiceID == "" {
+		respondError(w, http.StatusBadRequest, "Missing invoice ID", "")
+		return
+	}
+
+	// Parse request body
+	var req models.UpdateInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.Notes == nil && req.PONumber == nil && req.CustomFields == nil {
+		respondError(w, http.StatusBadRequest, "Nothing to update", "Set notes, po_number, or custom_fields")
+		return
+	}
+	if req.Notes != nil {
+		notes := strings.TrimSpace(*req.Notes)
+		if utf8.RuneCountInString(notes) > models.MaxInvoiceNotesLength {
+			respondError(w, http.StatusBadRequest, "Notes are too long", fmt.Sprintf("At most %d characters", models.MaxInvoiceNotesLength))
+			return
+		}
+		req.Notes = &notes
+	}
+	if req.PONumber != nil {
+		po := strings.TrimSpace(*req.PONumber)
+		if utf8.RuneCountInString(po) > models.MaxInvoicePONumberLength {
+			respondError(w, http.StatusBadRequest, "PO number is too long", fmt.Sprintf("At most %d characters", models.MaxInvoicePONumberLength))
+			return
+		}
+		req.PONumber = &po
+	}
+	if req.CustomFields != nil {
+		fields, err := cleanCustomFields(req.CustomFields)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid custom fields", err.Error())
+			return
+		}
+		req.CustomFields = fields
+	}
+
+	invoice, err := h.repo.UpdateInvoice(r.Context(), invoiceID, orgID, req, userID)
+	if err != nil {
+		switch err.Error() {
+		case "invoice not found":
+			respondError(w, http.StatusNotFound, "Invoice not found", "")
+		case "invoice is paid":
+			respondError(w, http.StatusConflict, "Paid invoices cannot be edited", "")
+		case "invoice is voided":
+			respondError(w, http.StatusConflict, "Voided invoices cannot be edited", "")
+		case "invoice cannot be edited":
+			respondError(w, http.StatusConflict, "Invoice cannot be edited", "")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to update invoice", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, invoice)
+}
+
+// cleanCustomFields trims custom field names and values and checks them against the models limits
+func cleanCustomFields(fields map[string]string) (map[string]string, error) {
+	if len(fields) > models.MaxInvoiceCustomFields {
+		return nil, fmt.Errorf("at most %d custom fields", models.MaxInvoiceCustomFields)
+	}
+
+	cleaned := make(map[string]string, len(fields))
+	for name, value := range fields {
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("custom field names cannot be empty")
+		case utf8.RuneCountInString(name) > models.MaxInvoiceCustomFieldNameLength:
+			return nil, fmt.Errorf("custom field name %q is longer than %d characters", name, models.MaxInvoiceCustomFieldNameLength)
+		case value == "":
+			return nil, fmt.Errorf("custom field %q has no value", name)
+		case utf8.RuneCountInString(value) > models.MaxInvoiceCustomFieldLength:
+			return nil, fmt.Errorf("custom field %q is longer than %d characters", name, models.MaxInvoiceCustomFieldLength)
+		}
+		for _, reserved := range models.ReservedInvoiceCustomFields {
+			if strings.EqualFold(name, reserved) {
+				return nil, fmt.Errorf("custom field name %q is reserved", name)
+			}
+		}
+		if _, dup := cleaned[name]; dup {
+			return nil, fmt.Errorf("custom field %q is set twice", name)
+		}
+		cleaned[name] = value
+	}
+
+	return cleaned, nil
+}
+
+// RefundInvoice handles POST /api/v1/invoices/:id/refund (admin only)
+// Accepts a full or partial refund; the billing engine issues it on Stripe and emails the customer
+func (h *InvoiceHandler) RefundInvoice(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID and user ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(string)
+
+	// Get invoice ID from URL
+	invoiceID := chi.URLParam(r, "id")
+	if invoiceID == "" {
+		respondError(w, http.StatusBadRequest, "Missing invoice ID", "")
+		return
+	}
+
+	// Parse request body
+	var req models.RefundInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondError(w, http.StatusBadRequest, "Refund reason is required", "")
+		return
+	}
+	if req.AmountCents <= 0 {
+		respondError(w, http.StatusBadRequest, "Refund amount must be positive", "")
+		return
+	}
+
+	refund, err := h.repo.RequestRefund(r.Context(), invoiceID, orgID, req.AmountCents, req.Reason, userID)
+	if err != nil {
+		switch err.Error() {
+		case "invoice not found":
+			respondError(w, http.StatusNotFound, "Invoice not found", "")
+		case "invoice is not paid":
+			respondError(w, http.StatusConflict, "Only paid invoices can be refunded", "")
+		case "refund exceeds remaining balance":
+			respondError(w, http.StatusConflict, "Refund exceeds the invoice's unrefunded balance", "")
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to refund invoice", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, refund)
+}
+
+// parsePagination reads page and page_size query parameters
+// Defaults to page 1 with 20 items; page_size is capped at 100
+func parsePagination(r *http.Request) (int, int) {
+	pageStr := r.URL.Query().Get("page")
+	pageSizeStr := r.URL.Query().Get("page_size")
+
+	page := 1
+	pageSize := 20 // default page size
+
+	if pageStr != "" {
+		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	if pageSizeStr != "" {
+		if parsedPageSize, err := strconv.Atoi(pageSizeStr); err == nil && parsedPageSize > 0 && parsedPageSize <= 100 {
+			pageSize = parsedPageSize
+		}
+	}
+
+	return page, pageSize
+}
@@ -0,0 +1,665 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeInvoiceStore is an in-memory invoiceStore for handler tests
+type fakeInvoiceStore struct {
+	invoices  map[string]models.Invoice // invoice ID -> invoice
+	lineItems map[string][]models.InvoiceLineItem
+}
+
+func (f *fakeInvoiceStore) ListInvoices(ctx context.Context, orgID string, page, pageSize int) (*models.InvoiceListResponse, error) {
+	return &models.InvoiceListResponse{Page: page, PageSize: pageSize}, nil
+}
+
+func (f *fakeInvoiceStore) GetInvoice(ctx context.Context, invoiceID, orgID string) (*models.Invoice, error) {
+	inv, ok := f.invoices[invoiceID]
+	if !ok || inv.OrganizationID != orgID {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	return &inv, nil
+}
+
+func (f *fakeInvoiceStore) GetInvoiceLineItems(ctx context.Context, invoiceID, orgID string) ([]models.InvoiceLineItem, error) {
+	if inv, ok := f.invoices[invoiceID]; !ok || inv.OrganizationID != orgID {
+		return nil, nil
+	}
+	return f.lineItems[invoiceID], nil
+}
+
+func (f *fakeInvoiceStore) ListInvoiceLineItems(ctx context.Context, invoiceID, orgID string, page, pageSize int) (*models.InvoiceLineItemListResponse, error) {
+	if _, err := f.GetInvoice(ctx, invoiceID, orgID); err != nil {
+		return nil, err
+	}
+
+	all := f.lineItems[invoiceID]
+	start := (page - 1) * pageSize
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + pageSize
+	if end > len(all) {
+		end = len(all)
+	}
+
+	return &models.InvoiceLineItemListResponse{
+		InvoiceID:  invoiceID,
+		LineItems:  all[start:end],
+		TotalCount: len(all),
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+func (f *fakeInvoiceStore) GetInvoicePDFURL(ctx context.Context, invoiceID, orgID string) (string, error) {
+	return "", fmt.Errorf("PDF not available for this invoice")
+}
+
+func (f *fakeInvoiceStore) VoidInvoice(ctx context.Context, invoiceID, orgID, reason, actorUserID string) (*models.Invoice, error) {
+	inv, ok := f.invoices[invoiceID]
+	if !ok || inv.OrganizationID != orgID {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	switch inv.Status {
+	case "paid":
+		return nil, fmt.Errorf("invoice is paid")
+	case "voided":
+		return nil, fmt.Errorf("invoice already voided")
+	case "refunded":
+		return nil, fmt.Errorf("invoice cannot be voided")
+	}
+	inv.Status = "voided"
+	f.invoices[invoiceID] = inv
+	return &inv, nil
+}
+
+func (f *fakeInvoiceStore) UpdateInvoice(ctx context.Context, invoiceID, orgID string, update models.UpdateInvoiceRequest, actorUserID string) (*models.Invoice, error) {
+	inv, ok := f.invoices[invoiceID]
+	if !ok || inv.OrganizationID != orgID {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	switch inv.Status {
+	case "paid", "partially_refunded", "refunded":
+		return nil, fmt.Errorf("invoice is paid")
+	case "voided":
+		return nil, fmt.Errorf("invoice is voided")
+	}
+	if update.Notes != nil {
+		inv.Notes = *update.Notes
+	}
+	if update.PONumber != nil {
+		inv.PONumber = *update.PONumber
+	}
+	if update.CustomFields != nil {
+		inv.CustomFields = update.CustomFields
+	}
+	inv.PDFURL = "" // Invalidated until the billing engine regenerates it
+	f.invoices[invoiceID] = inv
+	return &inv, nil
+}
+
+func (f *fakeInvoiceStore) RequestRefund(ctx context.Context, invoiceID, orgID string, amountCents int64, reason, actorUserID string) (*models.InvoiceRefund, error) {
+	inv, ok := f.invoices[invoiceID]
+	if !ok || inv.OrganizationID != orgID {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	if inv.Status != "paid" && inv.Status != "partially_refunded" {
+		return nil, fmt.Errorf("invoice is not paid")
+	}
+	if int64(inv.RefundedAmount*100)+amountCents > int64(inv.Total*100) {
+		return nil, fmt.Errorf("refund exceeds remaining balance")
+	}
+	inv.RefundedAmount += float64(amountCents) / 100
+	f.invoices[invoiceID] = inv
+	return &models.InvoiceRefund{ID: "ref-1", InvoiceID: invoiceID, AmountCents: amountCents, Reason: reason, Status: "pending"}, nil
+}
+
+// newLineItemStore creates a store with one invoice owned by org-123 holding n line items
+func newLineItemStore(n int) *fakeInvoiceStore {
+	items := make([]models.InvoiceLineItem, n)
+	for i := range items {
+		items[i] = models.InvoiceLineItem{
+			ID:          fmt.Sprintf("li-%03d", i+1),
+			InvoiceID:   "inv-1",
+			Description: fmt.Sprintf("Usage item %d", i+1),
+		}
+	}
+
+	return &fakeInvoiceStore{
+		invoices: map[string]models.Invoice{
+			"inv-1": {ID: "inv-1", OrganizationID: "org-123"},
+		},
+		lineItems: map[string][]models.InvoiceLineItem{"inv-1": items},
+	}
+}
+
+// serveLineItems routes a GET line-items request as the given organization
+func serveLineItems(h *InvoiceHandler, orgID, path string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Get("/api/v1/invoices/{id}/line-items", h.ListInvoiceLineItems)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req = req.WithContext(context.WithValue(req.Context(), "organization_id", orgID))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestListInvoiceLineItems_Pagination tests paging through an invoice with many items
+func TestListInvoiceLineItems_Pagination(t *testing.T) {
+	h := &InvoiceHandler{repo: newLineItemStore(45)}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedPage  int
+		expectedSize  int
+		expectedLen   int
+		expectedFirst string
+	}{
+		{"Default page", "", 1, 20, 20, "li-001"},
+		{"Second page", "?page=2&page_size=20", 2, 20, 20, "li-021"},
+		{"Last partial page", "?page=3&page_size=20", 3, 20, 5, "li-041"},
+		{"Past the end", "?page=10&page_size=20", 10, 20, 0, ""},
+		{"Oversized page_size falls back to default", "?page_size=500", 1, 20, 20, "li-001"},
+		{"Invalid page falls back to first", "?page=-1&page_size=50", 1, 50, 45, "li-001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveLineItems(h, "org-123", "/api/v1/invoices/inv-1/line-items"+tt.query)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			var resp models.InvoiceLineItemListResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Invalid JSON response: %v", err)
+			}
+
+			if resp.TotalCount != 45 {
+				t.Errorf("TotalCount = %d, want 45", resp.TotalCount)
+			}
+			if resp.Page != tt.expectedPage || resp.PageSize != tt.expectedSize {
+				t.Errorf("Page/PageSize = %d/%d, want %d/%d", resp.Page, resp.PageSize, tt.expectedPage, tt.expectedSize)
+			}
+			if len(resp.LineItems) != tt.expectedLen {
+				t.Fatalf("Items = %d, want %d", len(resp.LineItems), tt.expectedLen)
+			}
+			if tt.expectedLen > 0 && resp.LineItems[0].ID != tt.expectedFirst {
+				t.Errorf("First item = %s, want %s", resp.LineItems[0].ID, tt.expectedFirst)
+			}
+		})
+	}
+}
+
+// TestListInvoiceLineItems_TenantOwnership tests that other organizations cannot read line items
+func TestListInvoiceLineItems_TenantOwnership(t *testing.T) {
+	h := &InvoiceHandler{repo: newLineItemStore(3)}
+
+	tests := []struct {
+		name     string
+		orgID    string
+		path     string
+		expected int
+	}{
+		{"Owner can read", "org-123", "/api/v1/invoices/inv-1/line-items", http.StatusOK},
+		{"Other org gets not found", "org-999", "/api/v1/invoices/inv-1/line-items", http.StatusNotFound},
+		{"Unknown invoice", "org-123", "/api/v1/invoices/inv-404/line-items", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveLineItems(h, tt.orgID, tt.path)
+			if rec.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// fakeQuoteSource is an in-memory quote.Source with one organization's usage and plans
+type fakeQuoteSource struct {
+	usage     quote.Usage           // Period in progress
+	plan      *quote.Plan           // nil = no subscription
+	anchorDay int                   // 0 = calendar months
+	daily     []quote.Usage         // Usage per day, oldest first
+	tiers     map[string]quote.Tier // Plans the org may switch to
+	override  *quote.Override       // Applied to tiers, like the org's negotiated pricing
+
+	realTimeQueries int
+}
+
+func (f *fakeQuoteSource) GetRealTimeUsage(orgID string) (*quote.Usage, error) {
+	f.realTimeQueries++
+	usage := f.usage
+	usage.OrganizationID = orgID
+	return &usage, nil
+}
+
+func (f *fakeQuoteSource) GetOrganizationPlan(orgID string) (*quote.Plan, error) {
+	if f.plan == nil {
+		return nil, fmt.Errorf("%w for organization: %s", quote.ErrNoSubscription, orgID)
+	}
+	plan := *f.plan
+	return &plan, nil
+}
+
+func (f *fakeQuoteSource) GetLatencyBreaches(orgID string, start, end time.Time, thresholdMs int64) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (f *fakeQuoteSource) GetBillingAnchorDay(orgID string) (int, error) {
+	if f.anchorDay == 0 {
+		return 1, nil
+	}
+	return f.anchorDay, nil
+}
+
+func (f *fakeQuoteSource) GetBillableUnits(orgID string, start, end time.Time) (int64, error) {
+	var units int64
+	for _, day := range f.daily {
+		if !day.PeriodStart.Before(start) && day.PeriodStart.Before(end) {
+			units += day.BillableUnits
+		}
+	}
+	return units, nil
+}
+
+func (f *fakeQuoteSource) GetDailyUsage(orgID string, start, end time.Time) ([]quote.Usage, error) {
+	var days []quote.Usage
+	for _, day := range f.daily {
+		if !day.PeriodStart.Before(start) && day.PeriodStart.Before(end) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (f *fakeQuoteSource) GetPlanForOrganization(orgID, planID string) (*quote.Plan, error) {
+	tier, ok := f.tiers[planID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", quote.ErrPlanNotFound, planID)
+	}
+	if f.override != nil {
+		tier = f.override.Apply(tier)
+	}
+	return &quote.Plan{OrganizationID: orgID, PlanID: planID, PlanName: tier.Name, Tier: tier, CustomPricing: f.override != nil}, nil
+}
+
+var (
+	starterTier = quote.Tier{Name: "Starter", BasePrice: 4900, IncludedUnits: 1000000, OverageRate: 10}
+	freeTier    = quote.Tier{Name: "Free", IncludedUnits: 100000, MaxUnits: 100000}
+	march2026   = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// newQuoteSource serves units of usage in March 2026 on tier (nil = no subscription)
+func newQuoteSource(tier *quote.Tier, units int64) *fakeQuoteSource {
+	source := &fakeQuoteSource{
+		usage: quote.Usage{Month: march2026, PeriodStart: march2026, PeriodEnd: march2026.AddDate(0, 1, 0), BillableUnits: units},
+	}
+	if tier != nil {
+		source.plan = &quote.Plan{PlanID: strings.ToLower(tier.Name), PlanName: tier.Name, Tier: *tier}
+	}
+	return source
+}
+
+func newTestQuoter(t *testing.T, source quote.Source, unitLabel string) *quote.Quoter {
+	t.Helper()
+	quoter, err := quote.NewQuoter(source, quote.Config{UsageUnitLabel: unitLabel})
+	if err != nil {
+		t.Fatalf("NewQuoter() error = %v", err)
+	}
+	return quoter
+}
+
+// TestBuildInvoicePreview tests pricing and line items of the preview invoice
+func TestBuildInvoicePreview(t *testing.T) {
+	asOf := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		tier             quote.Tier
+		units            int64
+		taxRate          float64
+		expectedItems    int
+		expectedOverage  int64
+		expectedSubtotal float64
+		expectedTax      float64
+		expectedTotal    float64
+	}{
+		{"Within included units", starterTier, 500000, 0, 1, 0, 49.00, 0, 49.00},
+		{"With overage", starterTier, 1500000, 0, 2, 500000, 99.00, 0, 99.00},
+		{"With tax", starterTier, 1500000, 0.10, 2, 500000, 99.00, 9.90, 108.90},
+		{"Free plan over hard cap", freeTier, 250000, 0, 0, 0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newTestQuoter(t, newQuoteSource(&tt.tier, tt.units), "").Quote("org-123")
+			if err != nil {
+				t.Fatalf("Quote() error = %v", err)
+			}
+			preview := buildInvoicePreview("org-123", q, tt.taxRate, asOf)
+
+			if !preview.IsEstimate {
+				t.Error("Expected preview to be marked as an estimate")
+			}
+			if !preview.AsOf.Equal(asOf) {
+				t.Errorf("AsOf = %v, want %v", preview.AsOf, asOf)
+			}
+			if len(preview.LineItems) != tt.expectedItems {
+				t.Fatalf("LineItems = %d, want %d (%+v)", len(preview.LineItems), tt.expectedItems, preview.LineItems)
+			}
+			if preview.OverageUnits != tt.expectedOverage {
+				t.Errorf("OverageUnits = %d, want %d", preview.OverageUnits, tt.expectedOverage)
+			}
+			if preview.Subtotal != tt.expectedSubtotal || preview.EstimatedTax != tt.expectedTax || preview.Total != tt.expectedTotal {
+				t.Errorf("Subtotal/Tax/Total = %.2f/%.2f/%.2f, want %.2f/%.2f/%.2f",
+					preview.Subtotal, preview.EstimatedTax, preview.Total,
+					tt.expectedSubtotal, tt.expectedTax, tt.expectedTotal)
+			}
+		})
+	}
+}
+
+// TestBuildInvoicePreview_PricingOverride tests that negotiated pricing and commitments are previewed
+func TestBuildInvoicePreview_PricingOverride(t *testing.T) {
+	rate, minimum := int64(20), int64(15000)
+	tier := quote.Override{OverageRate: &rate, MinimumChargeCents: &minimum}.Apply(starterTier)
+
+	q, err := newTestQuoter(t, newQuoteSource(&tier, 1500000), "").Quote("org-123")
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	preview := buildInvoicePreview("org-123", q, 0, time.Now())
+
+	// $49 + 500K at $0.20 per 1000 is $149, trued up to the $150 commitment
+	want := []models.InvoiceLineItem{
+		{Description: "Starter Plan - Mar 1 - Mar 31, 2026", Quantity: 1, UnitPrice: 49, Amount: 49},
+		{Description: "Usage overage - 500.0K requests over limit", Quantity: 500000, UnitPrice: 0, Amount: 100},
+		{Description: "Minimum commitment true-up", Quantity: 1, UnitPrice: 1, Amount: 1},
+	}
+	if !reflect.DeepEqual(preview.LineItems, want) || preview.Total != 150 {
+		t.Errorf("Preview = %+v, want $150 with a $1 true-up", preview)
+	}
+}
+
+// TestBuildInvoicePreview_UnitLabel tests the overage line item uses the configured unit
+func TestBuildInvoicePreview_UnitLabel(t *testing.T) {
+	tests := []struct {
+		name         string
+		unitLabel    string
+		expectedDesc string
+	}{
+		{"Default label", "", "Usage overage - 500.0K requests over limit"},
+		{"Custom label", "messages", "Usage overage - 500.0K messages over limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newTestQuoter(t, newQuoteSource(&starterTier, 1500000), tt.unitLabel).Quote("org-123")
+			if err != nil {
+				t.Fatalf("Quote() error = %v", err)
+			}
+			preview := buildInvoicePreview("org-123", q, 0, time.Now())
+
+			if len(preview.LineItems) != 2 {
+				t.Fatalf("LineItems = %d, want 2", len(preview.LineItems))
+			}
+			if preview.LineItems[1].Description != tt.expectedDesc {
+				t.Errorf("Overage description = %q, want %q", preview.LineItems[1].Description, tt.expectedDesc)
+			}
+		})
+	}
+}
+
+// TestPreviewInvoice tests the preview endpoint response
+func TestPreviewInvoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		tier     *quote.Tier
+		expected int
+	}{
+		{"Subscribed org", &starterTier, http.StatusOK},
+		{"No subscription", nil, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &InvoiceHandler{repo: &fakeInvoiceStore{}, quotes: newTestQuoter(t, newQuoteSource(tt.tier, 1200000), "")}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/invoices/preview", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+			rec := httptest.NewRecorder()
+			h.PreviewInvoice(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+			if tt.expected != http.StatusOK {
+				return
+			}
+
+			var preview models.InvoicePreview
+			if err := json.Unmarshal(rec.Body.Bytes(), &preview); err != nil {
+				t.Fatalf("Invalid JSON response: %v", err)
+			}
+			if !preview.IsEstimate || preview.UsedUnits != 1200000 || !preview.BillingPeriodStart.Equal(march2026) {
+				t.Errorf("Preview = %+v, want estimate with 1200000 used units from Mar 1", preview)
+			}
+		})
+	}
+}
+
+// serveVoid routes a POST void request as the given organization
+func serveVoid(h *InvoiceHandler, orgID, invoiceID, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Post("/api/v1/invoices/{id}/void", h.VoidInvoice)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/invoices/"+invoiceID+"/void", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), "organization_id", orgID)
+	ctx = context.WithValue(ctx, "user_id", "user-1")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req.WithContext(ctx))
+	return rec
+}
+
+// TestVoidInvoice tests voiding invoices in each status
+func TestVoidInvoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		orgID    string
+		body     string
+		expected int
+	}{
+		{"Pending invoice is voided", "pending", "org-123", `{"reason":"duplicate"}`, http.StatusOK},
+		{"Paid invoice needs a refund", "paid", "org-123", `{"reason":"duplicate"}`, http.StatusConflict},
+		{"Already voided", "voided", "org-123", `{"reason":"duplicate"}`, http.StatusConflict},
+		{"Reason is required", "pending", "org-123", `{"reason":"  "}`, http.StatusBadRequest},
+		{"Other org gets not found", "pending", "org-999", `{"reason":"duplicate"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeInvoiceStore{invoices: map[string]models.Invoice{
+				"inv-1": {ID: "inv-1", OrganizationID: "org-123", Status: tt.status},
+			}}
+			h := &InvoiceHandler{repo: store}
+
+			rec := serveVoid(h, tt.orgID, "inv-1", tt.body)
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+
+			if tt.expected == http.StatusOK {
+				var inv models.Invoice
+				if err := json.NewDecoder(rec.Body).Decode(&inv); err != nil {
+					t.Fatalf("Decode error = %v", err)
+				}
+				if inv.Status != "voided" {
+					t.Errorf("Invoice status = %q, want voided", inv.Status)
+				}
+			}
+		})
+	}
+}
+
+// serveUpdate routes a PATCH invoice request as the given organization
+func serveUpdate(h *InvoiceHandler, orgID, invoiceID, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Patch("/api/v1/invoices/{id}", h.UpdateInvoice)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/invoices/"+invoiceID, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), "organization_id", orgID)
+	ctx = context.WithValue(ctx, "user_id", "user-1")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req.WithContext(ctx))
+	return rec
+}
+
+func TestUpdateInvoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		orgID    string
+		body     string
+		expected int
+	}{
+		{"Pending invoice gets notes", "pending", "org-123", `{"notes":"Credit applied per ticket #123"}`, http.StatusOK},
+		{"Draft invoice gets a PO number", "draft", "org-123", `{"po_number":"PO-7781"}`, http.StatusOK},
+		{"Draft invoice gets custom fields", "draft", "org-123", `{"custom_fields":{"Cost Center":"CC-42"}}`, http.StatusOK},
+		{"Reserved custom field", "draft", "org-123", `{"custom_fields":{"po_number":"PO-1"}}`, http.StatusBadRequest},
+		{"Paid invoice is final", "paid", "org-123", `{"notes":"Credit applied per ticket #123"}`, http.StatusConflict},
+		{"Refunded invoice is final", "refunded", "org-123", `{"notes":"Credit applied per ticket #123"}`, http.StatusConflict},
+		{"Voided invoice is final", "voided", "org-123", `{"notes":"Credit applied per ticket #123"}`, http.StatusConflict},
+		{"Nothing to update", "pending", "org-123", `{}`, http.StatusBadRequest},
+		{"Notes too long", "pending", "org-123", `{"notes":"` + strings.Repeat("x", 1001) + `"}`, http.StatusBadRequest},
+		{"Other org gets not found", "pending", "org-999", `{"notes":"hello"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeInvoiceStore{invoices: map[string]models.Invoice{
+				"inv-1": {ID: "inv-1", OrganizationID: "org-123", Status: tt.status, Notes: "Original note", PDFURL: "https://s3/inv-1.pdf"},
+			}}
+			h := &InvoiceHandler{repo: store}
+
+			rec := serveUpdate(h, tt.orgID, "inv-1", tt.body)
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+
+			if tt.expected != http.StatusOK && store.invoices["inv-1"].Notes != "Original note" {
+				t.Errorf("Rejected edit changed notes to %q", store.invoices["inv-1"].Notes)
+			}
+		})
+	}
+}
+
+func TestUpdateInvoice_PartialUpdate(t *testing.T) {
+	store := &fakeInvoiceStore{invoices: map[string]models.Invoice{
+		"inv-1": {ID: "inv-1", OrganizationID: "org-123", Status: "pending", Notes: "Original note", PDFURL: "https://s3/inv-1.pdf"},
+	}}
+	h := &InvoiceHandler{repo: store}
+
+	// Only the PO number is sent; surrounding whitespace is trimmed
+	rec := serveUpdate(h, "org-123", "inv-1", `{"po_number":"  PO-7781 "}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+
+	var inv models.Invoice
+	if err := json.NewDecoder(rec.Body).Decode(&inv); err != nil {
+		t.Fatalf("Decode error = %v", err)
+	}
+	if inv.PONumber != "PO-7781" || inv.Notes != "Original note" {
+		t.Errorf("Invoice = PO %q, notes %q, want PO-7781 and the original note", inv.PONumber, inv.Notes)
+	}
+	if inv.PDFURL != "" {
+		t.Errorf("PDF URL = %q after an edit, want it invalidated", inv.PDFURL)
+	}
+}
+
+// serveRefund routes a POST refund request as the given organization
+func serveRefund(h *InvoiceHandler, orgID, invoiceID, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Post("/api/v1/invoices/{id}/refund", h.RefundInvoice)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/invoices/"+invoiceID+"/refund", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), "organization_id", orgID)
+	ctx = context.WithValue(ctx, "user_id", "user-1")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req.WithContext(ctx))
+	return rec
+}
+
+// TestRefundInvoice tests refund validation against invoice status and balance
+func TestRefundInvoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		refunded float64
+		body     string
+		expected int
+	}{
+		{"Full refund", "paid", 0, `{"amount_cents":10000,"reason":"duplicate"}`, http.StatusAccepted},
+		{"Partial refund", "paid", 0, `{"amount_cents":2500,"reason":"service outage"}`, http.StatusAccepted},
+		{"Remaining balance after partial", "partially_refunded", 25, `{"amount_cents":7500,"reason":"service outage"}`, http.StatusAccepted},
+		{"Partials cannot exceed total", "partially_refunded", 25, `{"amount_cents":7501,"reason":"service outage"}`, http.StatusConflict},
+		{"Unpaid invoice", "pending", 0, `{"amount_cents":100,"reason":"duplicate"}`, http.StatusConflict},
+		{"Amount is required", "paid", 0, `{"reason":"duplicate"}`, http.StatusBadRequest},
+		{"Reason is required", "paid", 0, `{"amount_cents":100}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeInvoiceStore{invoices: map[string]models.Invoice{
+				"inv-1": {ID: "inv-1", OrganizationID: "org-123", Status: tt.status, Total: 100, RefundedAmount: tt.refunded},
+			}}
+			h := &InvoiceHandler{repo: store}
+
+			rec := serveRefund(h, "org-123", "inv-1", tt.body)
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestRefundInvoice_RepeatedPartials tests that successive partial refunds stop at the invoice total
+func TestRefundInvoice_RepeatedPartials(t *testing.T) {
+	store := &fakeInvoiceStore{invoices: map[string]models.Invoice{
+		"inv-1": {ID: "inv-1", OrganizationID: "org-123", Status: "paid", Total: 100},
+	}}
+	h := &InvoiceHandler{repo: store}
+
+	for i := 0; i < 4; i++ {
+		if rec := serveRefund(h, "org-123", "inv-1", `{"amount_cents":2500,"reason":"partial"}`); rec.Code != http.StatusAccepted {
+			t.Fatalf("Refund #%d status = %d, want %d", i+1, rec.Code, http.StatusAccepted)
+		}
+	}
+
+	if rec := serveRefund(h, "org-123", "inv-1", `{"amount_cents":1,"reason":"partial"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Refund past total status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// loginMaxFailures is how many wrong passwords an email/IP pair may try before it is locked out
+	loginMaxFailures = 5
+	// loginBaseLockout is the first lockout; each further failure doubles it up to loginMaxLockout
+	loginBaseLockout = time.Minute
+	loginMaxLockout  = time.Hour
+
+	// loginIPMaxFailures bounds failures from one IP across all emails per loginIPWindow,
+	// which stops one client from trying a password against many accounts
+	loginIPMaxFailures = 20
+	loginIPWindow      = time.Minute
+
+	// loginEmailBurst failures of one email may come from any number of IPs before attempts
+	// on it are slowed to one per loginEmailInterval; the wait never exceeds the interval, so
+	// a distributed attack on an account is throttled without locking its owner out
+	loginEmailBurst    = 10
+	loginEmailInterval = 6 * time.Second
+
+	// loginForgetAfter drops the failure count of a pair that has been quiet this long
+	loginForgetAfter = time.Hour
+	// loginSweepInterval is how often idle entries are removed
+	loginSweepInterval = time.Minute
+)
+
+// loginPairState tracks failed logins for one email from one IP
+type loginPairState struct {
+	failures    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// loginIPState counts failed logins from one IP in the current window
+type loginIPState struct {
+	failures    int
+	windowStart time.Time
+}
+
+// loginEmailState is a token bucket of attempts on one email across all IPs
+type loginEmailState struct {
+	tokens  float64 // Attempts left, refilled at one per loginEmailInterval up to loginEmailBurst
+	updated time.Time
+}
+
+// refill adds the tokens earned since the last update
+func (s *loginEmailState) refill(now time.Time) {
+	s.tokens += float64(now.Sub(s.updated)) / float64(loginEmailInterval)
+	if s.tokens > loginEmailBurst {
+		s.tokens = loginEmailBurst
+	}
+	s.updated = now
+}
+
+// loginLimiter throttles password guessing on POST /auth/login
+// Lockouts are scoped to the email/IP pair, so an attack on one email from one address
+// neither locks the real user out from their own network nor affects the rest of the org.
+// Attempts on an email from many addresses are rate limited rather than locked out
+// IPs are the client addresses resolved by middleware.RealIP from trusted proxies only
+type loginLimiter struct {
+	mu        sync.Mutex
+	pairs     map[string]*loginPairState
+	ips       map[string]*loginIPState
+	emails    map[string]*loginEmailState
+	lastSweep time.Time
+	now       func() time.Time // Overridable for tests
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		pairs:  make(map[string]*loginPairState),
+		ips:    make(map[string]*loginIPState),
+		emails: make(map[string]*loginEmailState),
+		now:    time.Now,
+	}
+}
+
+// Allow records a login attempt, or returns how long the caller must wait before trying again
+// Attempts count as failures until Success is called, so concurrent guesses cannot slip past
+// the limit while their passwords are being checked
+// From loginMaxFailures failures on, the pair is locked out for loginBaseLockout, doubling
+// with each further failure
+func (l *loginLimiter) Allow(email, ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	key := loginPairKey(email, ip)
+	pair, ok := l.pairs[key]
+	if ok && now.Before(pair.lockedUntil) {
+		return pair.lockedUntil.Sub(now)
+	}
+
+	state, ok := l.ips[ip]
+	if !ok || !now.Before(state.windowStart.Add(loginIPWindow)) {
+		state = &loginIPState{windowStart: now}
+		l.ips[ip] = state
+	}
+	if state.failures >= loginIPMaxFailures {
+		return state.windowStart.Add(loginIPWindow).Sub(now)
+	}
+
+	emailKey := loginEmailKey(email)
+	bucket, ok := l.emails[emailKey]
+	if !ok {
+		bucket = &loginEmailState{tokens: loginEmailBurst, updated: now}
+		l.emails[emailKey] = bucket
+	}
+	bucket.refill(now)
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) * float64(loginEmailInterval))
+	}
+
+	if pair == nil {
+		pair = &loginPairState{}
+		l.pairs[key] = pair
+	}
+	pair.failures++
+	pair.lastFailure = now
+	if pair.failures >= loginMaxFailures {
+		pair.lockedUntil = now.Add(loginLockout(pair.failures))
+	}
+	state.failures++
+	bucket.tokens--
+
+	return 0
+}
+
+// Success clears the pair's failures after a correct password, including the attempt
+// Allow just recorded
+func (l *loginLimiter) Success(email, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.pairs, loginPairKey(email, ip))
+	if state, ok := l.ips[ip]; ok && state.failures > 0 {
+		state.failures--
+	}
+	if bucket, ok := l.emails[loginEmailKey(email)]; ok {
+		bucket.tokens++
+		bucket.refill(l.now())
+	}
+}
+
+// sweep removes idle pairs, expired IP windows and refilled email buckets, at most once per
+// loginSweepInterval
+func (l *loginLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < loginSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, pair := range l.pairs {
+		if now.After(pair.lockedUntil) && now.Sub(pair.lastFailure) > loginForgetAfter {
+			delete(l.pairs, key)
+		}
+	}
+	for ip, state := range l.ips {
+		if !now.Before(state.windowStart.Add(loginIPWindow)) {
+			delete(l.ips, ip)
+		}
+	}
+	for email, bucket := range l.emails {
+		if bucket.refill(now); bucket.tokens >= loginEmailBurst {
+			delete(l.emails, email)
+		}
+	}
+}
+
+// loginLockout returns the lockout after the given number of consecutive failures
+func loginLockout(failures int) time.Duration {
+	lockout := loginBaseLockout
+	for i := loginMaxFailures; i < failures && lockout < loginMaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > loginMaxLockout {
+		lockout = loginMaxLockout
+	}
+	return lockout
+}
+
+// loginPairKey identifies an email/IP pair
+func loginPairKey(email, ip string) string {
+	return loginEmailKey(email) + "|" + ip
+}
+
+// loginEmailKey normalizes an email; emails are compared case-insensitively
+func loginEmailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// clientIP returns the request's IP without the port (middleware.RealIP has already applied
+// X-Forwarded-For from trusted proxies)
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
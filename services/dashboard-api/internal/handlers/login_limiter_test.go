@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/middleware"
+)
+
+// newTestLoginLimiter returns a limiter on a clock the test advances
+func newTestLoginLimiter() (*loginLimiter, *time.Time) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+// failLogins records n attempts that are never followed by Success
+func failLogins(l *loginLimiter, email, ip string, n int) {
+	for i := 0; i < n; i++ {
+		l.Allow(email, ip)
+	}
+}
+
+func TestLoginLimiter_Lockout(t *testing.T) {
+	l, now := newTestLoginLimiter()
+
+	// Four failures leave the fifth attempt open; the fifth failure locks the pair
+	failLogins(l, "alice@acme.test", "203.0.113.7", loginMaxFailures-1)
+	if wait := l.Allow("alice@acme.test", "203.0.113.7"); wait != 0 {
+		t.Fatalf("Attempt %d wait = %v, want allowed", loginMaxFailures, wait)
+	}
+	if wait := l.Allow("alice@acme.test", "203.0.113.7"); wait != loginBaseLockout {
+		t.Fatalf("Wait after %d failures = %v, want %v", loginMaxFailures, wait, loginBaseLockout)
+	}
+
+	// The lockout doubles with each failure after it ends
+	expected := []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+	for _, want := range expected {
+		*now = now.Add(l.Allow("alice@acme.test", "203.0.113.7"))
+		if wait := l.Allow("alice@acme.test", "203.0.113.7"); wait != 0 {
+			t.Fatalf("Attempt after lockout wait = %v, want allowed", wait)
+		}
+		if wait := l.Allow("alice@acme.test", "203.0.113.7"); wait != want {
+			t.Fatalf("Wait = %v, want %v", wait, want)
+		}
+	}
+
+	// Emails match case-insensitively
+	if wait := l.Allow("  ALICE@acme.test", "203.0.113.7"); wait == 0 {
+		t.Error("Expected the lockout to apply to the same email in another case")
+	}
+}
+
+func TestLoginLockout_Capped(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{loginMaxFailures, time.Minute},
+		{loginMaxFailures + 1, 2 * time.Minute},
+		{loginMaxFailures + 5, 32 * time.Minute},
+		{loginMaxFailures + 6, time.Hour},
+		{loginMaxFailures + 100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := loginLockout(tt.failures); got != tt.expected {
+			t.Errorf("loginLockout(%d) = %v, want %v", tt.failures, got, tt.expected)
+		}
+	}
+}
+
+func TestLoginLimiter_SuccessResets(t *testing.T) {
+	l, _ := newTestLoginLimiter()
+
+	// Four failures, then the right password
+	failLogins(l, "alice@acme.test", "203.0.113.7", loginMaxFailures-1)
+	l.Allow("alice@acme.test", "203.0.113.7")
+	l.Success("alice@acme.test", "203.0.113.7")
+
+	// The count starts over: four more failures still do not lock the pair
+	failLogins(l, "alice@acme.test", "203.0.113.7", loginMaxFailures-1)
+	if wait := l.Allow("alice@acme.test", "203.0.113.7"); wait != 0 {
+		t.Errorf("Wait after a successful login = %v, want allowed", wait)
+	}
+}
+
+func TestLoginLimiter_ScopedToPair(t *testing.T) {
+	l, _ := newTestLoginLimiter()
+	failLogins(l, "alice@acme.test", "203.0.113.7", loginMaxFailures)
+
+	tests := []struct {
+		name  string
+		email string
+		ip    string
+	}{
+		{"Same email from the user's own network", "alice@acme.test", "198.51.100.20"},
+		{"Colleague from the attacking IP", "bob@acme.test", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if wait := l.Allow(tt.email, tt.ip); wait != 0 {
+				t.Errorf("Allow(%s, %s) wait = %v, want allowed", tt.email, tt.ip, wait)
+			}
+		})
+	}
+}
+
+func TestLoginLimiter_IPLimit(t *testing.T) {
+	l, now := newTestLoginLimiter()
+
+	// One IP trying one password against many accounts
+	for i := 0; i < loginIPMaxFailures; i++ {
+		if wait := l.Allow(fmt.Sprintf("user%d@acme.test", i), "203.0.113.7"); wait != 0 {
+			t.Fatalf("Attempt %d wait = %v, want allowed", i+1, wait)
+		}
+	}
+	if wait := l.Allow("another@acme.test", "203.0.113.7"); wait != loginIPWindow {
+		t.Fatalf("Wait over the IP limit = %v, want %v", wait, loginIPWindow)
+	}
+	if wait := l.Allow("another@acme.test", "198.51.100.20"); wait != 0 {
+		t.Errorf("Other IP wait = %v, want allowed", wait)
+	}
+
+	// The window resets
+	*now = now.Add(loginIPWindow)
+	if wait := l.Allow("another@acme.test", "203.0.113.7"); wait != 0 {
+		t.Errorf("Wait after the window = %v, want allowed", wait)
+	}
+}
+
+func TestLoginLimiter_EmailThrottle(t *testing.T) {
+	l, now := newTestLoginLimiter()
+
+	// A botnet guessing one account's password, one attempt per IP
+	for i := 0; i < loginEmailBurst; i++ {
+		if wait := l.Allow("alice@acme.test", fmt.Sprintf("203.0.113.%d", i)); wait != 0 {
+			t.Fatalf("Attempt %d wait = %v, want allowed", i+1, wait)
+		}
+	}
+	if wait := l.Allow("alice@acme.test", "203.0.113.200"); wait != loginEmailInterval {
+		t.Fatalf("Wait over the email burst = %v, want %v", wait, loginEmailInterval)
+	}
+
+	// Throttled, not locked: the owner gets in from their own network after one interval
+	*now = now.Add(loginEmailInterval)
+	if wait := l.Allow("alice@acme.test", "198.51.100.20"); wait != 0 {
+		t.Fatalf("Owner wait after %v = %v, want allowed", loginEmailInterval, wait)
+	}
+	l.Success("alice@acme.test", "198.51.100.20")
+
+	// Other accounts are not affected
+	if wait := l.Allow("bob@acme.test", "203.0.113.200"); wait != 0 {
+		t.Errorf("Other email wait = %v, want allowed", wait)
+	}
+}
+
+func TestLoginLimiter_Sweep(t *testing.T) {
+	l, now := newTestLoginLimiter()
+	failLogins(l, "alice@acme.test", "203.0.113.7", 2)
+
+	*now = now.Add(loginForgetAfter + time.Second)
+	l.Allow("bob@acme.test", "198.51.100.20")
+
+	if _, ok := l.pairs[loginPairKey("alice@acme.test", "203.0.113.7")]; ok {
+		t.Error("Expected the idle pair to be swept")
+	}
+	if _, ok := l.ips["203.0.113.7"]; ok {
+		t.Error("Expected the expired IP window to be swept")
+	}
+	if len(l.pairs) != 1 || len(l.ips) != 1 {
+		t.Errorf("Entries after sweep = %d pairs, %d IPs, want the new attempt only", len(l.pairs), len(l.ips))
+	}
+}
+
+func TestLogin_TooManyAttempts(t *testing.T) {
+	h := &AuthHandler{limiter: newLoginLimiter()}
+	failLogins(h.limiter, "alice@acme.test", "203.0.113.7", loginMaxFailures)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"email":"alice@acme.test","password":"guess"}`))
+	req.RemoteAddr = "203.0.113.7:51234"
+	rec := httptest.NewRecorder()
+	h.Login(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
+
+// serveLogin posts a login for email through middleware.RealIP, as in cmd/server
+func serveLogin(h *AuthHandler, trustedProxies []*net.IPNet, remoteAddr, forwardedFor, email string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"email":"`+email+`","password":"guess"}`))
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	middleware.RealIP(trustedProxies)(http.HandlerFunc(h.Login)).ServeHTTP(rec, req)
+	return rec
+}
+
+// TestLogin_SpoofedForwardedFor tests that a client rotating X-Forwarded-For cannot escape its lockout
+func TestLogin_SpoofedForwardedFor(t *testing.T) {
+	h := &AuthHandler{limiter: newLoginLimiter()}
+	failLogins(h.limiter, "alice@acme.test", "203.0.113.7", loginMaxFailures)
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name         string
+		trusted      []*net.IPNet
+		remoteAddr   string
+		forwardedFor string
+	}{
+		{"Direct client, no trusted proxies", nil, "203.0.113.7:51234", "198.51.100.99"},
+		{"Direct client, header not from a proxy", []*net.IPNet{proxies}, "203.0.113.7:51234", "198.51.100.99"},
+		{"Spoofed entry forwarded by the proxy", []*net.IPNet{proxies}, "10.0.0.5:443", "198.51.100.99, 203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveLogin(h, tt.trusted, tt.remoteAddr, tt.forwardedFor, "alice@acme.test"); rec.Code != http.StatusTooManyRequests {
+				t.Errorf("Status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+			}
+		})
+	}
+}
+
+// TestLogin_SpoofedForwardedFor_EmailThrottle tests that spoofing a new IP per attempt still hits
+// the per-email throttle, even where the spoofed addresses are believed
+func TestLogin_SpoofedForwardedFor_EmailThrottle(t *testing.T) {
+	h := &AuthHandler{limiter: newLoginLimiter()}
+	_, proxies, _ := net.ParseCIDR("0.0.0.0/0") // Misconfigured: every peer is trusted
+	trusted := []*net.IPNet{proxies}
+
+	for i := 0; i < loginEmailBurst; i++ {
+		h.limiter.Allow("alice@acme.test", fmt.Sprintf("198.51.100.%d", i))
+	}
+
+	rec := serveLogin(h, trusted, "203.0.113.7:51234", "192.0.2.250", "alice@acme.test")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "6" {
+		t.Errorf("Retry-After = %q, want 6", got)
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
+)
+
+// organizationStore is the subset of OrganizationRepository used by the handler
+type organizationStore interface {
+	GetOrganizationStatus(ctx context.Context, orgID string) (*models.OrganizationStatus, error)
+	SuspendOrganization(ctx context.Context, orgID, reason string) (*models.OrganizationStatus, error)
+	ReactivateOrganization(ctx context.Context, orgID string) (*models.OrganizationStatus, error)
+	GetInvoiceDefaults(ctx context.Context, orgID string) (*models.InvoiceDefaults, error)
+	UpdateInvoiceDefaults(ctx context.Context, orgID string, update models.UpdateInvoiceDefaultsRequest) (*models.InvoiceDefaults, error)
+}
+
+// OrganizationHandler handles organization status and invoice default operations
+type OrganizationHandler struct {
+	repo organizationStore
+}
+
+// NewOrganizationHandler creates a new organization handler
+func NewOrganizationHandler(db *sql.DB) *OrganizationHandler {
+	return &OrganizationHandler{
+		repo: repository.NewOrganizationRepository(db),
+	}
+}
+
+// GetOrganization handles GET /api/v1/organization
+// Returns the organization's status, so a suspended organization can be told why
+func (h *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	org, err := h.repo.GetOrganizationStatus(r.Context(), orgID)
+	if err != nil {
+		if err.Error() == "organization not found" {
+			respondError(w, http.StatusNotFound, "Organization not found", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to get organization", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, org)
+}
+
+// SuspendOrganization handles POST /api/v1/organization/suspend
+// Stops all of the organization's API keys at the gateway until it is reactivated
+func (h *OrganizationHandler) SuspendOrganization(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Parse request body
+	var req models.SuspendOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondError(w, http.StatusBadRequest, "Suspension reason is required", "")
+		return
+	}
+
+	org, err := h.repo.SuspendOrganization(r.Context(), orgID, req.Reason)
+	if err != nil {
+		h.respondStatusError(w, err, "Failed to suspend organization")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, org)
+}
+
+// ReactivateOrganization handles POST /api/v1/organization/reactivate
+// Suspensions for non-payment are lifted by the billing engine once the invoices are paid
+func (h *OrganizationHandler) ReactivateOrganization(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	org, err := h.repo.ReactivateOrganization(r.Context(), orgID)
+	if err != nil {
+		h.respondStatusError(w, err, "Failed to reactivate organization")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, org)
+}
+
+// GetInvoiceDefaults handles GET /api/v1/organization/invoice-defaults
+// Returns the PO number and custom fields put on the organization's new invoices
+func (h *OrganizationHandler) GetInvoiceDefaults(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	defaults, err := h.repo.GetInvoiceDefaults(r.Context(), orgID)
+	if err != nil {
+		if err.Error() == "organization not found" {
+			respondError(w, http.StatusNotFound, "Organization not found", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to get invoice defaults", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, defaults)
+}
+
+// UpdateInvoiceDefaults handles PATCH /api/v1/organization/invoice-defaults (admin only)
+// Sets the default PO number and custom fields of invoices generated from now on
+func (h *OrganizationHandler) UpdateInvoiceDefaults(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Parse request body
+	var req models.UpdateInvoiceDefaultsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.DefaultPONumber == nil && req.CustomFields == nil {
+		respondError(w, http.StatusBadRequest, "Nothing to update", "Set default_po_number or custom_fields")
+		return
+	}
+	if req.DefaultPONumber != nil {
+		po := strings.TrimSpace(*req.DefaultPONumber)
+		if utf8.RuneCountInString(po) > models.MaxInvoicePONumberLength {
+			respondError(w, http.StatusBadRequest, "PO number is too long", fmt.Sprintf("At most %d characters", models.MaxInvoicePONumberLength))
+			return
+		}
+		req.DefaultPONumber = &po
+	}
+	if req.CustomFields != nil {
+		fields, err := cleanCustomFields(req.CustomFields)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid custom fields", err.Error())
+			return
+		}
+		req.CustomFields = fields
+	}
+
+	defaults, err := h.repo.UpdateInvoiceDefaults(r.Context(), orgID, req)
+	if err != nil {
+		if err.Error() == "organization not found" {
+			respondError(w, http.StatusNotFound, "Organization not found", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to update invoice defaults", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, defaults)
+}
+
+// respondStatusError maps status transition errors from the repository to responses
+func (h *OrganizationHandler) respondStatusError(w http.ResponseWriter, err error, message string) {
+	switch err.Error() {
+	case "organization not found":
+		respondError(w, http.StatusNotFound, "Organization not found", "")
+	case "organization already suspended":
+		respondError(w, http.StatusConflict, "Organization is already suspended", "")
+	case "organization not suspended":
+		respondError(w, http.StatusConflict, "Organization is not suspended", "")
+	case "organization suspended for non-payment":
+		respondError(w, http.StatusConflict, "Organization is suspended for non-payment", "Pay the outstanding invoices to reactivate it")
+	default:
+		respondError(w, http.StatusInternalServerError, message, err.Error())
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// fakeOrganizationStore keeps one organization's status and invoice defaults in memory
+type fakeOrganizationStore struct {
+	org      models.OrganizationStatus
+	defaults models.InvoiceDefaults
+}
+
+func (f *fakeOrganizationStore) GetOrganizationStatus(ctx context.Context, orgID string) (*models.OrganizationStatus, error) {
+	org := f.org
+	return &org, nil
+}
+
+func (f *fakeOrganizationStore) SuspendOrganization(ctx context.Context, orgID, reason string) (*models.OrganizationStatus, error) {
+	if f.org.Status == models.OrgStatusSuspended {
+		return nil, fmt.Errorf("organization already suspended")
+	}
+	f.org.Status, f.org.SuspensionReason = models.OrgStatusSuspended, &reason
+	return f.GetOrganizationStatus(ctx, orgID)
+}
+
+func (f *fakeOrganizationStore) ReactivateOrganization(ctx context.Context, orgID string) (*models.OrganizationStatus, error) {
+	switch {
+	case f.org.Status != models.OrgStatusSuspended:
+		return nil, fmt.Errorf("organization not suspended")
+	case f.org.SuspensionReason != nil && *f.org.SuspensionReason == models.SuspensionReasonNonPayment:
+		return nil, fmt.Errorf("organization suspended for non-payment")
+	}
+	f.org.Status, f.org.SuspensionReason = models.OrgStatusActive, nil
+	return f.GetOrganizationStatus(ctx, orgID)
+}
+
+func (f *fakeOrganizationStore) GetInvoiceDefaults(ctx context.Context, orgID string) (*models.InvoiceDefaults, error) {
+	defaults := f.defaults
+	return &defaults, nil
+}
+
+func (f *fakeOrganizationStore) UpdateInvoiceDefaults(ctx context.Context, orgID string, update models.UpdateInvoiceDefaultsRequest) (*models.InvoiceDefaults, error) {
+	if update.DefaultPONumber != nil {
+		f.defaults.DefaultPONumber = *update.DefaultPONumber
+	}
+	if update.CustomFields != nil {
+		f.defaults.CustomFields = update.CustomFields
+	}
+	return f.GetInvoiceDefaults(ctx, orgID)
+}
+
+func newOrganizationRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	return req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+}
+
+// TestOrganizationSuspension tests the suspend/reactivate round trip and its conflicts
+func TestOrganizationSuspension(t *testing.T) {
+	store := &fakeOrganizationStore{org: models.OrganizationStatus{OrganizationID: "org-123", Status: models.OrgStatusActive}}
+	h := &OrganizationHandler{repo: store}
+
+	steps := []struct {
+		name     string
+		handler  http.HandlerFunc
+		body     string
+		expected int
+		status   string
+	}{
+		{"Suspend without reason", h.SuspendOrganization, `{"reason": "  "}`, http.StatusBadRequest, models.OrgStatusActive},
+		{"Suspend", h.SuspendOrganization, `{"reason": "migrating to a new account"}`, http.StatusOK, models.OrgStatusSuspended},
+		{"Suspend again", h.SuspendOrganization, `{"reason": "again"}`, http.StatusConflict, models.OrgStatusSuspended},
+		{"Reactivate", h.ReactivateOrganization, "", http.StatusOK, models.OrgStatusActive},
+		{"Reactivate again", h.ReactivateOrganization, "", http.StatusConflict, models.OrgStatusActive},
+	}
+
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		step.handler(rec, newOrganizationRequest(http.MethodPost, "/api/v1/organization", step.body))
+		if rec.Code != step.expected {
+			t.Fatalf("%s: status = %d, want %d (body: %s)", step.name, rec.Code, step.expected, rec.Body.String())
+		}
+		if store.org.Status != step.status {
+			t.Fatalf("%s: organization status = %s, want %s", step.name, store.org.Status, step.status)
+		}
+	}
+}
+
+// TestReactivateOrganization_NonPayment tests that a dunning suspension cannot be lifted from the dashboard
+func TestReactivateOrganization_NonPayment(t *testing.T) {
+	reason := models.SuspensionReasonNonPayment
+	store := &fakeOrganizationStore{org: models.OrganizationStatus{
+		OrganizationID:   "org-123",
+		Status:           models.OrgStatusSuspended,
+		SuspensionReason: &reason,
+	}}
+	h := &OrganizationHandler{repo: store}
+
+	rec := httptest.NewRecorder()
+	h.ReactivateOrganization(rec, newOrganizationRequest(http.MethodPost, "/api/v1/organization/reactivate", ""))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// Every role can still read the status, so the dashboard can explain the suspension
+	rec = httptest.NewRecorder()
+	h.GetOrganization(rec, newOrganizationRequest(http.MethodGet, "/api/v1/organization", ""))
+	var org models.OrganizationStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &org); err != nil {
+		t.Fatalf("Failed to decode organization: %v", err)
+	}
+	if org.Status != models.OrgStatusSuspended || org.SuspensionReason == nil || *org.SuspensionReason != reason {
+		t.Errorf("Organization = %+v, want suspended for %s", org, reason)
+	}
+}
+
+// TestUpdateInvoiceDefaults tests validation of the organization's default PO number and custom fields
+func TestUpdateInvoiceDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+		po       string
+		fields   map[string]string
+	}{
+		{"Default PO number", `{"default_po_number":"  PO-7781 "}`, http.StatusOK, "PO-7781", map[string]string{"Project": "Apollo"}},
+		{"Custom fields are trimmed", `{"custom_fields":{" Cost Center ":" CC-42 "}}`, http.StatusOK, "PO-1", map[string]string{"Cost Center": "CC-42"}},
+		{"Clear custom fields", `{"custom_fields":{}}`, http.StatusOK, "PO-1", map[string]string{}},
+		{"Nothing to update", `{}`, http.StatusBadRequest, "PO-1", map[string]string{"Project": "Apollo"}},
+		{"PO number too long", `{"default_po_number":"` + strings.Repeat("x", 101) + `"}`, http.StatusBadRequest, "PO-1", map[string]string{"Project": "Apollo"}},
+		{"Reserved field name", `{"custom_fields":{"Invoice_ID":"x"}}`, http.StatusBadRequest, "PO-1", map[string]string{"Project": "Apollo"}},
+		{"Empty field value", `{"custom_fields":{"Project":" "}}`, http.StatusBadRequest, "PO-1", map[string]string{"Project": "Apollo"}},
+		{"Field value too long", `{"custom_fields":{"Project":"` + strings.Repeat("x", 41) + `"}}`, http.StatusBadRequest, "PO-1", map[string]string{"Project": "Apollo"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeOrganizationStore{defaults: models.InvoiceDefaults{
+				OrganizationID:  "org-123",
+				DefaultPONumber: "PO-1",
+				CustomFields:    map[string]string{"Project": "Apollo"},
+			}}
+			h := &OrganizationHandler{repo: store}
+
+			rec := httptest.NewRecorder()
+			h.UpdateInvoiceDefaults(rec, newOrganizationRequest(http.MethodPatch, "/api/v1/organization/invoice-defaults", tt.body))
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+
+			if store.defaults.DefaultPONumber != tt.po || !reflect.DeepEqual(store.defaults.CustomFields, tt.fields) {
+				t.Errorf("Defaults = %q %v, want %q %v", store.defaults.DefaultPONumber, store.defaults.CustomFields, tt.po, tt.fields)
+			}
+		})
+	}
+}
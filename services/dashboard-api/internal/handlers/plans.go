@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
+)
+
+// planStore is the subset of UsageRepository used by the plan handler
+type planStore interface {
+	ListPlans(ctx context.Context) ([]models.Plan, error)
+	GetCurrentPlanID(ctx context.Context, orgID string) (string, error)
+}
+
+// PlanHandler handles the public plan catalog
+type PlanHandler struct {
+	repo      planStore
+	unitLabel string // Billable unit name in overage rate display strings
+}
+
+// NewPlanHandler creates a new plan handler
+func NewPlanHandler(db *sql.DB, unitLabel string) *PlanHandler {
+	return &PlanHandler{
+		repo:      repository.NewUsageRepository(db),
+		unitLabel: unitLabel,
+	}
+}
+
+// ListPlans handles GET /api/v1/plans
+// Public; when the request carries a valid token, the organization's current plan is marked
+func (h *PlanHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.repo.ListPlans(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get plans", err.Error())
+		return
+	}
+
+	// Organization ID is only set when OptionalAuthMiddleware accepted a token
+	var currentPlanID string
+	if orgID, ok := r.Context().Value("organization_id").(string); ok && orgID != "" {
+		currentPlanID, err = h.repo.GetCurrentPlanID(r.Context(), orgID)
+		if err != nil && err.Error() != "subscription not found" {
+			respondError(w, http.StatusInternalServerError, "Failed to get current plan", err.Error())
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, buildPlanList(plans, currentPlanID, h.unitLabel))
+}
+
+// buildPlanList keeps the active plans, mirroring the billing engine's pricing.GetActivePlans,
+// and fills in the display prices. A retired current plan is not listed, so current_plan_id
+// is only returned when it matches a listed plan
+func buildPlanList(plans []models.Plan, currentPlanID, unitLabel string) *models.PlanListResponse {
+	if unitLabel == "" {
+		unitLabel = "units"
+	}
+
+	response := &models.PlanListResponse{Plans: []models.Plan{}}
+	for _, plan := range plans {
+		if !plan.IsActive {
+			continue
+		}
+		if plan.Features == nil {
+			plan.Features = []string{}
+		}
+		plan.BasePriceDisplay = formatDollars(plan.BasePriceCents)
+		plan.OverageRateDisplay = formatOverageRate(plan.OverageRateCents, unitLabel)
+		plan.IsCurrent = currentPlanID != "" && plan.ID == currentPlanID
+		if plan.IsCurrent {
+			response.CurrentPlanID = plan.ID
+		}
+		response.Plans = append(response.Plans, plan)
+	}
+	return response
+}
+
+// formatDollars formats cents as a dollar amount with thousands separators, e.g. "$1,299.00"
+func formatDollars(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s$%s.%02d", sign, groupThousands(cents/100), cents%100)
+}
+
+// formatOverageRate describes a per-1000-unit overage rate, e.g. "$0.05 per 1,000 requests"
+// Plans without an overage rate stop at their unit limit instead
+func formatOverageRate(ratePer1000Cents int64, unitLabel string) string {
+	if ratePer1000Cents == 0 {
+		return "No overage"
+	}
+	return fmt.Sprintf("%s per 1,000 %s", formatDollars(ratePer1000Cents), unitLabel)
+}
+
+// groupThousands inserts commas between groups of three digits
+func groupThousands(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
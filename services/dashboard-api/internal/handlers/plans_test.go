@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// fakePlanStore is an in-memory planStore for handler tests
+type fakePlanStore struct {
+	plans         []models.Plan
+	currentPlanID string // Empty means no subscription
+}
+
+func (f *fakePlanStore) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	return f.plans, nil
+}
+
+func (f *fakePlanStore) GetCurrentPlanID(ctx context.Context, orgID string) (string, error) {
+	if f.currentPlanID == "" {
+		return "", fmt.Errorf("subscription not found")
+	}
+	return f.currentPlanID, nil
+}
+
+// catalogPlans mirrors the seeded plans plus one retired plan
+var catalogPlans = []models.Plan{
+	{ID: "free", Name: "Free", BasePriceCents: 0, IncludedUnits: 10000, MaxUnits: 10000, Features: []string{"Community support"}, IsActive: true},
+	{ID: "starter", Name: "Starter", BasePriceCents: 2900, IncludedUnits: 100000, OverageRateCents: 5, IsActive: true},
+	{ID: "legacy", Name: "Legacy", BasePriceCents: 1900, IncludedUnits: 50000, OverageRateCents: 8, IsActive: false},
+	{ID: "enterprise", Name: "Enterprise", BasePriceCents: 129900, IncludedUnits: 50000000, OverageRateCents: 2, IsActive: true},
+}
+
+func listPlans(t *testing.T, store *fakePlanStore, orgID string) models.PlanListResponse {
+	t.Helper()
+
+	h := &PlanHandler{repo: store, unitLabel: "requests"}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/plans", nil)
+	if orgID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), "organization_id", orgID))
+	}
+	rec := httptest.NewRecorder()
+
+	h.ListPlans(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp models.PlanListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp
+}
+
+// TestListPlans tests that every active plan is listed and retired plans are not
+func TestListPlans(t *testing.T) {
+	resp := listPlans(t, &fakePlanStore{plans: catalogPlans, currentPlanID: "starter"}, "")
+
+	want := []string{"free", "starter", "enterprise"}
+	if len(resp.Plans) != len(want) {
+		t.Fatalf("Got %d plans, want %d: %+v", len(resp.Plans), len(want), resp.Plans)
+	}
+	for i, id := range want {
+		if resp.Plans[i].ID != id {
+			t.Errorf("Plan %d = %s, want %s", i, resp.Plans[i].ID, id)
+		}
+		if resp.Plans[i].IsCurrent {
+			t.Errorf("Plan %s is current for an anonymous request", id)
+		}
+	}
+	if resp.CurrentPlanID != "" {
+		t.Errorf("CurrentPlanID = %q, want none for an anonymous request", resp.CurrentPlanID)
+	}
+
+	enterprise := resp.Plans[2]
+	if enterprise.BasePriceCents != 129900 || enterprise.BasePriceDisplay != "$1,299.00" {
+		t.Errorf("Base price = %d (%q), want 129900 ($1,299.00)", enterprise.BasePriceCents, enterprise.BasePriceDisplay)
+	}
+	if enterprise.OverageRateDisplay != "$0.02 per 1,000 requests" {
+		t.Errorf("Overage rate = %q, want %q", enterprise.OverageRateDisplay, "$0.02 per 1,000 requests")
+	}
+	if resp.Plans[0].OverageRateDisplay != "No overage" || len(resp.Plans[0].Features) != 1 {
+		t.Errorf("Free plan = %+v, want no overage and its features", resp.Plans[0])
+	}
+}
+
+// TestListPlans_Authenticated tests that the organization's current plan is marked
+func TestListPlans_Authenticated(t *testing.T) {
+	tests := []struct {
+		name          string
+		currentPlanID string
+		wantCurrent   string
+	}{
+		{"Active plan", "starter", "starter"},
+		{"Retired plan", "legacy", ""},
+		{"No subscription", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := listPlans(t, &fakePlanStore{plans: catalogPlans, currentPlanID: tt.currentPlanID}, "org-123")
+
+			if resp.CurrentPlanID != tt.wantCurrent {
+				t.Errorf("CurrentPlanID = %q, want %q", resp.CurrentPlanID, tt.wantCurrent)
+			}
+			for _, plan := range resp.Plans {
+				if plan.IsCurrent != (plan.ID == tt.wantCurrent) {
+					t.Errorf("Plan %s IsCurrent = %v", plan.ID, plan.IsCurrent)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatDollars(t *testing.T) {
+	tests := []struct {
+		cents int64
+		want  string
+	}{
+		{0, "$0.00"},
+		{5, "$0.05"},
+		{2900, "$29.00"},
+		{99999, "$999.99"},
+		{129900, "$1,299.00"},
+		{123456789, "$1,234,567.89"},
+		{-2500, "-$25.00"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDollars(tt.cents); got != tt.want {
+			t.Errorf("formatDollars(%d) = %q, want %q", tt.cents, got, tt.want)
+		}
+	}
+}
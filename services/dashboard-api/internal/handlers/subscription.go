@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
+)
+
+// subscriptionStore is the subset of SubscriptionRepository used by the handler
+type subscriptionStore interface {
+	ChangePlan(ctx context.Context, change *models.PlanChange, actorUserID string) error
+}
+
+// planChangeQuoter prices plan changes as the billing engine bills them (implemented by quote.Quoter)
+type planChangeQuoter interface {
+	Plan(orgID string) (*quote.Plan, error)
+	PlanChange(from quote.Plan, toPlanID string, effectiveAt time.Time) (*quote.PlanChange, error)
+}
+
+// SubscriptionHandler handles self-service plan changes
+type SubscriptionHandler struct {
+	repo   subscriptionStore
+	quotes planChangeQuoter
+	now    func() time.Time // Overridable for tests
+}
+
+// NewSubscriptionHandler creates a new subscription handler
+func NewSubscriptionHandler(db *sql.DB, quoter *quote.Quoter) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		repo:   repository.NewSubscriptionRepository(db),
+		quotes: quoter,
+		now:    time.Now,
+	}
+}
+
+// ChangePlan handles POST /api/v1/subscription/change-plan (admin only)
+// The change takes effect immediately and the base price difference is prorated over the rest of
+// the billing period, which starts on the organization's billing anchor day. Plans whose hard cap
+// this period's usage already exceeds are rejected.
+// With "preview": true the proration is returned without changing the plan
+func (h *SubscriptionHandler) ChangePlan(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID and user ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(string)
+
+	// Parse request body
+	var req models.ChangePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	req.PlanID = strings.TrimSpace(req.PlanID)
+	if req.PlanID == "" {
+		respondError(w, http.StatusBadRequest, "plan_id is required", "")
+		return
+	}
+
+	current, err := h.quotes.Plan(orgID)
+	if err != nil {
+		if errors.Is(err, quote.ErrNoSubscription) {
+			respondError(w, http.StatusNotFound, "No active subscription", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to get current plan", err.Error())
+		}
+		return
+	}
+
+	if req.PlanID == current.PlanID {
+		respondError(w, http.StatusConflict, "Already subscribed to this plan", "")
+		return
+	}
+
+	// Only active plans can be subscribed to
+	quoted, err := h.quotes.PlanChange(*current, req.PlanID, h.now().UTC())
+	if err != nil {
+		if errors.Is(err, quote.ErrPlanNotFound) {
+			respondError(w, http.StatusNotFound, "Plan not found", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to price plan change", err.Error())
+		}
+		return
+	}
+
+	if quoted.OverLimit() {
+		respondError(w, http.StatusConflict, "Current usage exceeds the plan's limit",
+			fmt.Sprintf("%s units used this period; the %s plan allows %s", formatUnits(quoted.UsedUnits), quoted.To.PlanName, formatUnits(quoted.To.Tier.MaxUnits)))
+		return
+	}
+
+	change := buildPlanChange(orgID, quoted)
+	change.Preview = req.Preview
+
+	if !req.Preview {
+		if err := h.repo.ChangePlan(r.Context(), change, userID); err != nil {
+			switch err.Error() {
+			case "subscription not found":
+				respondError(w, http.StatusNotFound, "No active subscription", "")
+			case "plan changed concurrently":
+				respondError(w, http.StatusConflict, "Plan was changed by another request", "Retry to prorate against the new plan")
+			default:
+				respondError(w, http.StatusInternalServerError, "Failed to change plan", err.Error())
+			}
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, change)
+}
+
+// buildPlanChange records a plan change priced by the billing engine
+// Plans are compared on their effective tiers, so negotiated pricing decides the direction
+func buildPlanChange(orgID string, quoted *quote.PlanChange) *models.PlanChange {
+	from, to := quoted.From.Tier, quoted.To.Tier
+
+	direction := "upgrade"
+	if to.BasePrice < from.BasePrice ||
+		(to.BasePrice == from.BasePrice && to.IncludedUnits < from.IncludedUnits) {
+		direction = "downgrade"
+	}
+
+	return &models.PlanChange{
+		OrganizationID:        orgID,
+		FromPlanID:            quoted.From.PlanID,
+		ToPlanID:              quoted.To.PlanID,
+		ToPlanName:            quoted.To.PlanName,
+		Direction:             direction,
+		EffectiveAt:           quoted.EffectiveAt,
+		PeriodStart:           quoted.PeriodStart,
+		PeriodEnd:             quoted.PeriodEnd,
+		ProratedAmountCents:   quoted.ProratedCents,
+		ProratedAmountDisplay: formatDollars(quoted.ProratedCents),
+	}
+}
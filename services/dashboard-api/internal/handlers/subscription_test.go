@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// fakeSubscriptionStore is an in-memory subscriptionStore for handler tests
+type fakeSubscriptionStore struct {
+	currentPlanID string
+	stripe        bool // Billed through a Stripe subscription
+
+	changes []models.PlanChange // Applied changes
+	actor   string
+}
+
+func (f *fakeSubscriptionStore) ChangePlan(ctx context.Context, change *models.PlanChange, actorUserID string) error {
+	if change.FromPlanID != f.currentPlanID {
+		return fmt.Errorf("plan changed concurrently")
+	}
+	change.ID = fmt.Sprintf("change-%d", len(f.changes)+1)
+	change.StripeSyncPending = f.stripe
+	f.currentPlanID = change.ToPlanID
+	f.changes = append(f.changes, *change)
+	f.actor = actorUserID
+	return nil
+}
+
+// subscriptionTiers are the free (capped), starter and growth plans
+var subscriptionTiers = map[string]quote.Tier{
+	"free":    {Name: "Free", BasePrice: 0, IncludedUnits: 10000, MaxUnits: 10000},
+	"starter": {Name: "Starter", BasePrice: 2900, IncludedUnits: 100000, OverageRate: 5},
+	"growth":  {Name: "Growth", BasePrice: 9900, IncludedUnits: 1000000, OverageRate: 4},
+}
+
+// newSubscriptionSource serves an org on currentPlanID ("" = no subscription) that used units on Jan 1
+func newSubscriptionSource(currentPlanID string, units int64) *fakeQuoteSource {
+	source := &fakeQuoteSource{
+		daily: []quote.Usage{usageDay(1, 1, units)},
+		tiers: subscriptionTiers,
+	}
+	if tier, ok := subscriptionTiers[currentPlanID]; ok {
+		source.plan = &quote.Plan{OrganizationID: "org-123", PlanID: currentPlanID, PlanName: tier.Name, Tier: tier}
+	}
+	return source
+}
+
+func changePlan(t *testing.T, store *fakeSubscriptionStore, source *fakeQuoteSource, now time.Time, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	h := &SubscriptionHandler{repo: store, quotes: newTestQuoter(t, source, ""), now: func() time.Time { return now }}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/subscription/change-plan", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), "organization_id", "org-123")
+	ctx = context.WithValue(ctx, "user_id", "user-1")
+	rec := httptest.NewRecorder()
+
+	h.ChangePlan(rec, req.WithContext(ctx))
+	return rec
+}
+
+// TestChangePlan_UpgradeMidCycle tests that an upgrade is applied at once and prorated over the rest of the month
+func TestChangePlan_UpgradeMidCycle(t *testing.T) {
+	store := &fakeSubscriptionStore{currentPlanID: "starter", stripe: true}
+	now := time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC) // 16 of January's 31 days left
+
+	rec := changePlan(t, store, newSubscriptionSource("starter", 60000), now, `{"plan_id": "growth"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var change models.PlanChange
+	if err := json.NewDecoder(rec.Body).Decode(&change); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// (9900 - 2900) * 16/31 = 3612.9
+	if change.ProratedAmountCents != 3613 || change.ProratedAmountDisplay != "$36.13" {
+		t.Errorf("Prorated = %d (%q), want 3613 ($36.13)", change.ProratedAmountCents, change.ProratedAmountDisplay)
+	}
+	if !change.EffectiveAt.Equal(now) || !change.PeriodEnd.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Effective %v for period ending %v, want now and Feb 1", change.EffectiveAt, change.PeriodEnd)
+	}
+	if change.Direction != "upgrade" || change.Preview || change.ID == "" || !change.StripeSyncPending {
+		t.Errorf("Change = %+v, want an applied upgrade pending Stripe sync", change)
+	}
+
+	if store.currentPlanID != "growth" || len(store.changes) != 1 || store.actor != "user-1" {
+		t.Errorf("Store on %s with %d changes by %q, want growth with 1 change by user-1", store.currentPlanID, len(store.changes), store.actor)
+	}
+}
+
+// TestChangePlan_BlockedDowngrade tests that a plan whose hard cap usage already exceeds is rejected
+func TestChangePlan_BlockedDowngrade(t *testing.T) {
+	store := &fakeSubscriptionStore{currentPlanID: "starter"}
+
+	rec := changePlan(t, store, newSubscriptionSource("starter", 25000), time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC), `{"plan_id": "free"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "exceeds the plan's limit") {
+		t.Errorf("Body = %s, want the hard cap error", rec.Body.String())
+	}
+	if store.currentPlanID != "starter" || len(store.changes) != 0 {
+		t.Errorf("Plan changed to %s, want starter kept", store.currentPlanID)
+	}
+
+	// Within the cap the downgrade goes through, crediting the rest of the month
+	rec = changePlan(t, store, newSubscriptionSource("starter", 8000), time.Date(2026, 1, 17, 0, 0, 0, 0, time.UTC), `{"plan_id": "free"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if len(store.changes) != 1 || store.changes[0].ProratedAmountCents != -1403 || store.changes[0].Direction != "downgrade" {
+		t.Errorf("Changes = %+v, want one downgrade crediting 1403", store.changes)
+	}
+}
+
+func TestChangePlan_Errors(t *testing.T) {
+	now := time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		current string
+		body    string
+		want    int
+	}{
+		{"Missing plan", "starter", `{}`, http.StatusBadRequest},
+		{"Unknown plan", "starter", `{"plan_id": "legacy"}`, http.StatusNotFound},
+		{"Same plan", "starter", `{"plan_id": "starter"}`, http.StatusConflict},
+		{"No subscription", "", `{"plan_id": "growth"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeSubscriptionStore{currentPlanID: tt.current}
+			if rec := changePlan(t, store, newSubscriptionSource(tt.current, 0), now, tt.body); rec.Code != tt.want {
+				t.Errorf("Status = %d, want %d (body: %s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestChangePlan_Preview tests that a preview prorates without changing the plan
+func TestChangePlan_Preview(t *testing.T) {
+	store := &fakeSubscriptionStore{currentPlanID: "starter"}
+
+	rec := changePlan(t, store, newSubscriptionSource("starter", 0), time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC), `{"plan_id": "growth", "preview": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var change models.PlanChange
+	if err := json.NewDecoder(rec.Body).Decode(&change); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !change.Preview || change.ID != "" || change.ProratedAmountCents != 3613 {
+		t.Errorf("Change = %+v, want an unsaved preview of 3613", change)
+	}
+	if store.currentPlanID != "starter" || len(store.changes) != 0 {
+		t.Error("Expected a preview to leave the plan unchanged")
+	}
+}
+
+// TestChangePlan_AnchoredPeriod tests that an org billed from the 15th is prorated over, and capped
+// on the usage of, its anchored billing period rather than the calendar month
+func TestChangePlan_AnchoredPeriod(t *testing.T) {
+	source := newSubscriptionSource("starter", 0)
+	source.anchorDay = 15
+	source.daily = []quote.Usage{
+		usageDay(3, 10, 50000), // Previous period
+		usageDay(3, 20, 20000),
+	}
+	now := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC) // 14 of the Mar 15 - Apr 15 period's 31 days left
+
+	store := &fakeSubscriptionStore{currentPlanID: "starter"}
+	rec := changePlan(t, store, source, now, `{"plan_id": "growth", "preview": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var change models.PlanChange
+	if err := json.NewDecoder(rec.Body).Decode(&change); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// (9900 - 2900) * 14/31 = 3161.3; the calendar month would charge the full 7000
+	if change.ProratedAmountCents != 3161 {
+		t.Errorf("Prorated = %d, want 3161", change.ProratedAmountCents)
+	}
+	wantStart := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	if !change.PeriodStart.Equal(wantStart) || !change.PeriodEnd.Equal(wantStart.AddDate(0, 1, 0)) {
+		t.Errorf("Period = %v - %v, want Mar 15 - Apr 15", change.PeriodStart, change.PeriodEnd)
+	}
+
+	// The 20K used since Mar 15 exceed the free plan's cap, though April has no usage yet
+	rec = changePlan(t, store, source, now, `{"plan_id": "free"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "20.0K units used this period") {
+		t.Errorf("Body = %s, want the anchored period's 20.0K units", rec.Body.String())
+	}
+}
@@ -1,29 +1,57 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/repository"
 )
 
+// usageStore is the subset of UsageRepository used by the handler
+type usageStore interface {
+	GetUsageHistory(ctx context.Context, orgID string, startDate, endDate time.Time, page, pageSize int) (*models.UsageHistoryResponse, error)
+	GetUsageByMetric(ctx context.Context, orgID, metricName string, days int) ([]models.UsageMetric, error)
+	GetUsageByRegion(ctx context.Context, orgID string, days int) (*models.UsageByRegionResponse, error)
+	GetAverageMonthlyUsage(ctx context.Context, orgID string, months int) (int64, int, error)
+	ListPlanPricing(ctx context.Context, includePlanID string) ([]models.PlanPricing, error)
+	StreamDailyUsage(ctx context.Context, orgID string, startDate, endDate time.Time, fn func(models.UsageExportRow) error) error
+}
+
+// usageQuoter prices usage as the billing engine invoices it (implemented by quote.Quoter)
+type usageQuoter interface {
+	Quote(orgID string) (*quote.Quote, error)
+	Plan(orgID string) (*quote.Plan, error)
+	PlanFor(orgID, planID string) (*quote.Plan, error)
+	Price(plan quote.Plan, usage quote.Usage) quote.Calculation
+	UsageCosts(orgID string, start, end time.Time) (*quote.UsageCosts, error)
+}
+
 // UsageHandler handles usage-related requests
 type UsageHandler struct {
-	repo *repository.UsageRepository
+	repo    usageStore
+	quotes  usageQuoter
+	current *currentUsageCache // nil disables caching
 }
 
 // NewUsageHandler creates a new usage handler
-func NewUsageHandler(db *sql.DB) *UsageHandler {
+func NewUsageHandler(db *sql.DB, quoter *quote.Quoter) *UsageHandler {
 	return &UsageHandler{
-		repo: repository.NewUsageRepository(db),
+		repo:    repository.NewUsageRepository(db),
+		quotes:  quoter,
+		current: newCurrentUsageCache(currentUsageCacheTTL),
 	}
 }
 
 // GetCurrentUsage handles GET /api/v1/usage/current
-// Returns real-time usage for the current day
+// Returns usage of the billing period in progress from raw events, priced as its invoice will be,
+// cached per organization
+// for currentUsageCacheTTL so dashboard refreshes don't each scan usage_events
 func (h *UsageHandler) GetCurrentUsage(w http.ResponseWriter, r *http.Request) {
 	// Extract organization ID from context (set by middleware)
 	orgID, ok := r.Context().Value("organization_id").(string)
@@ -32,18 +60,31 @@ func (h *UsageHandler) GetCurrentUsage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get current day usage
-	usage, err := h.repo.GetCurrentDayUsage(r.Context(), orgID)
+	if usage, ok := h.current.Get(orgID); ok {
+		respondJSON(w, http.StatusOK, usage)
+		return
+	}
+
+	// Organizations without a plan still see their usage, priced at zero
+	asOf := time.Now().UTC()
+	q, err := h.quotes.Quote(orgID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to retrieve usage", err.Error())
 		return
 	}
 
+	usage := buildCurrentUsage(orgID, q, asOf)
+	h.current.Set(orgID, usage)
+
 	respondJSON(w, http.StatusOK, usage)
 }
 
+// maxUsageHistoryDays caps the date range of a usage history request
+const maxUsageHistoryDays = 366
+
 // GetUsageHistory handles GET /api/v1/usage/history
-// Returns historical usage for the last N days (default 90)
+// Returns paginated daily usage for ?start=YYYY-MM-DD&end=YYYY-MM-DD (default: last 90 days),
+// with each day's marginal cost under the organization's plan (see quote.Quoter.UsageCosts)
 func (h *UsageHandler) GetUsageHistory(w http.ResponseWriter, r *http.Request) {
 	// Extract organization ID from context
 	orgID, ok := r.Context().Value("organization_id").(string)
@@ -52,25 +93,72 @@ func (h *UsageHandler) GetUsageHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse query parameter for number of days (default 90)
-	daysStr := r.URL.Query().Get("days")
-	days := 90 // default
-	if daysStr != "" {
-		if parsedDays, err := strconv.Atoi(daysStr); err == nil && parsedDays > 0 && parsedDays <= 365 {
-			days = parsedDays
-		}
+	startDate, endDate, err := parseDateRange(r, time.Now().UTC())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range", err.Error())
+		return
 	}
 
+	page, pageSize := parsePagination(r)
+
 	// Get usage history
-	history, err := h.repo.GetUsageHistory(r.Context(), orgID, days)
+	history, err := h.repo.GetUsageHistory(r.Context(), orgID, startDate, endDate, page, pageSize)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to retrieve usage history", err.Error())
 		return
 	}
 
+	costs, err := h.quotes.UsageCosts(orgID, startDate, endDate)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to price usage history", err.Error())
+		return
+	}
+
+	priceUsageHistory(history, costs)
+
 	respondJSON(w, http.StatusOK, history)
 }
 
+// parseDateRange reads start and end (YYYY-MM-DD, inclusive) query parameters
+// Without start, the legacy days parameter (default 90) counts back from end (default today)
+func parseDateRange(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	endDate := today
+	if endStr := query.Get("end"); endStr != "" {
+		parsed, err := time.Parse("2006-01-02", endStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("end must be YYYY-MM-DD")
+		}
+		endDate = parsed
+	}
+
+	var startDate time.Time
+	if startStr := query.Get("start"); startStr != "" {
+		parsed, err := time.Parse("2006-01-02", startStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("start must be YYYY-MM-DD")
+		}
+		startDate = parsed
+	} else {
+		days := 90 // default
+		if parsedDays, err := strconv.Atoi(query.Get("days")); err == nil && parsedDays > 0 && parsedDays <= 365 {
+			days = parsedDays
+		}
+		startDate = endDate.AddDate(0, 0, -days)
+	}
+
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must not be after end")
+	}
+	if int(endDate.Sub(startDate).Hours()/24)+1 > maxUsageHistoryDays {
+		return time.Time{}, time.Time{}, fmt.Errorf("range must not exceed %d days", maxUsageHistoryDays)
+	}
+
+	return startDate, endDate, nil
+}
+
 // GetUsageByMetric handles GET /api/v1/usage/metrics/{metric_name}
 // Returns usage for a specific metric
 func (h *UsageHandler) GetUsageByMetric(w http.ResponseWriter, r *http.Request) {
@@ -110,3 +198,31 @@ func (h *UsageHandler) GetUsageByMetric(w http.ResponseWriter, r *http.Request)
 		"data":        metrics,
 	})
 }
+
+// GetUsageByRegion handles GET /api/v1/usage/regions
+// Returns request counts grouped by client region for the last N days (default 30)
+func (h *UsageHandler) GetUsageByRegion(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Parse days parameter
+	daysStr := r.URL.Query().Get("days")
+	days := 30 // default
+	if daysStr != "" {
+		if parsedDays, err := strconv.Atoi(daysStr); err == nil && parsedDays > 0 && parsedDays <= 365 {
+			days = parsedDays
+		}
+	}
+
+	usage, err := h.repo.GetUsageByRegion(r.Context(), orgID, days)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve usage by region", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// billableMetricName is the usage metric plans charge for; other metrics are shown at no cost
+const billableMetricName = "api_requests"
+
+// priceUsageHistory fills in a usage history's daily and total costs from the billing engine's
+// attribution of each billing period's charges to its days (quote.Quoter.UsageCosts)
+// A day's cost is the marginal charge it added to its billing period; TotalCost covers the whole
+// range and, for whole periods, equals their invoice subtotals before tax and coupons
+func priceUsageHistory(history *models.UsageHistoryResponse, costs *quote.UsageCosts) {
+	days := make(map[string]quote.DayCost, len(costs.Days))
+	for _, day := range costs.Days {
+		days[day.Date.Format("2006-01-02")] = day
+	}
+
+	for i := range history.DailyUsage {
+		day := &history.DailyUsage[i]
+		day.BillableUnits = days[day.Date].BillableUnits
+		day.Cost = centsToDollars(days[day.Date].CostCents)
+
+		for j := range day.Metrics {
+			day.Metrics[j].Cost = 0
+			if day.Metrics[j].MetricName == billableMetricName {
+				day.Metrics[j].Cost = day.Cost
+			}
+		}
+	}
+
+	history.TotalCost = centsToDollars(costs.TotalCents)
+}
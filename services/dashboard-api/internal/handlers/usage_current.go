@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+const (
+	// currentUsageCacheTTL is how stale the current usage view may be
+	currentUsageCacheTTL = 30 * time.Second
+	// currentUsageCacheMaxEntries bounds memory; expired entries are dropped, then the cache is emptied, when full
+	currentUsageCacheMaxEntries = 10000
+)
+
+// buildCurrentUsage reports the quoted billing period in progress
+// Without a plan (no subscription and no default plan) the cost stays at zero
+func buildCurrentUsage(orgID string, q *quote.Quote, asOf time.Time) *models.CurrentUsageResponse {
+	response := &models.CurrentUsageResponse{
+		OrganizationID:    orgID,
+		Date:              asOf.Format("2006-01-02"),
+		PeriodStart:       q.PeriodStart,
+		TotalRequests:     q.Usage.TotalRequests,
+		BillableUnits:     q.Usage.BillableUnits,
+		ErrorCount:        q.Usage.ErrorCount,
+		AvgResponseTimeMs: q.Usage.AvgResponseTime,
+		UpdatedAt:         asOf,
+	}
+
+	if q.Plan != nil {
+		response.PlanName = q.Plan.PlanName
+		response.IncludedUnits = q.Calculation.IncludedUnits
+		response.OverageUnits = q.Calculation.OverageUnits
+		response.TotalCost = centsToDollars(q.Calculation.TotalCharge)
+	}
+
+	return response
+}
+
+// cachedUsage is an organization's current usage, valid until expiresAt
+type cachedUsage struct {
+	usage     *models.CurrentUsageResponse
+	expiresAt time.Time
+}
+
+// currentUsageCache remembers each organization's current usage for a short TTL
+// All methods are safe on a nil cache, which never hits
+type currentUsageCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedUsage
+	now     func() time.Time // Overridable for tests
+}
+
+func newCurrentUsageCache(ttl time.Duration) *currentUsageCache {
+	return &currentUsageCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedUsage),
+		now:     time.Now,
+	}
+}
+
+// Get returns the organization's cached usage if it has not expired
+func (c *currentUsageCache) Get(orgID string) (*models.CurrentUsageResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[orgID]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, orgID)
+		return nil, false
+	}
+	return entry.usage, true
+}
+
+// Set caches the organization's usage for the cache TTL
+func (c *currentUsageCache) Set(orgID string, usage *models.CurrentUsageResponse) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+
+	if len(c.entries) >= currentUsageCacheMaxEntries {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= currentUsageCacheMaxEntries {
+			c.entries = make(map[string]cachedUsage)
+		}
+	}
+	c.entries[orgID] = cachedUsage{usage: usage, expiresAt: now.Add(c.ttl)}
+}
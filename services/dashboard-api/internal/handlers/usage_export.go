@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// usageExportHeader is the CSV column order for usage exports
+var usageExportHeader = []string{"date", "total_requests", "billable_units", "error_count", "avg_response_time_ms"}
+
+// ExportUsage handles GET /api/v1/usage/export?format=csv|json&start=YYYY-MM-DD&end=YYYY-MM-DD
+// Streams daily usage rows for reconciliation; the date range follows /usage/history rules
+func (h *UsageHandler) ExportUsage(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		respondError(w, http.StatusBadRequest, "Invalid format", "format must be csv or json")
+		return
+	}
+
+	startDate, endDate, err := parseDateRange(r, time.Now().UTC())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid date range", err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("usage-%s-%s-%s.%s", orgID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if format == "csv" {
+		err = h.streamUsageCSV(w, r, orgID, startDate, endDate)
+	} else {
+		err = h.streamUsageJSON(w, r, orgID, startDate, endDate)
+	}
+
+	// Headers are already sent, so failures can only be logged
+	if err != nil {
+		log.Printf("[UsageExport] ERROR: Export failed for org %s: %v", orgID, err)
+	}
+}
+
+// streamUsageCSV writes the export as CSV, flushing after every row
+// A failed export is cut short; clients should compare row counts against the date range
+func (h *UsageHandler) streamUsageCSV(w http.ResponseWriter, r *http.Request, orgID string, startDate, endDate time.Time) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(usageExportHeader); err != nil {
+		return err
+	}
+
+	err := h.repo.StreamDailyUsage(r.Context(), orgID, startDate, endDate, func(row models.UsageExportRow) error {
+		record := []string{
+			row.Date,
+			strconv.FormatInt(row.TotalRequests, 10),
+			strconv.FormatInt(row.BillableUnits, 10),
+			strconv.FormatInt(row.ErrorCount, 10),
+			strconv.FormatFloat(row.AvgResponseTimeMs, 'f', 2, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		writer.Flush()
+		flushResponse(w)
+		return writer.Error()
+	})
+
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// streamUsageJSON writes the export as a JSON object, encoding rows one at a time
+func (h *UsageHandler) streamUsageJSON(w http.ResponseWriter, r *http.Request, orgID string, startDate, endDate time.Time) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	header, err := json.Marshal(map[string]string{
+		"organization_id": orgID,
+		"start_date":      startDate.Format("2006-01-02"),
+		"end_date":        endDate.Format("2006-01-02"),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reopen the object to append the rows array
+	if _, err := fmt.Fprintf(w, `%s,"rows":[`, header[:len(header)-1]); err != nil {
+		return err
+	}
+
+	first := true
+	err = h.repo.StreamDailyUsage(r.Context(), orgID, startDate, endDate, func(row models.UsageExportRow) error {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		flushResponse(w)
+		return nil
+	})
+	if err != nil {
+		// Leave the document unterminated so a truncated export fails to parse
+		return err
+	}
+
+	_, err = w.Write([]byte("]}\n"))
+	return err
+}
+
+// flushResponse pushes buffered output to the client when the writer supports it
+func flushResponse(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// GetPlanRecommendation handles GET /api/v1/usage/recommendation
+// Compares every plan at the org's average monthly usage over the last N months (default 3)
+func (h *UsageHandler) GetPlanRecommendation(w http.ResponseWriter, r *http.Request) {
+	// Extract organization ID from context
+	orgID, ok := r.Context().Value("organization_id").(string)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Missing organization context", "")
+		return
+	}
+
+	// Parse months parameter
+	monthsStr := r.URL.Query().Get("months")
+	months := 3 // default
+	if monthsStr != "" {
+		if parsedMonths, err := strconv.Atoi(monthsStr); err == nil && parsedMonths > 0 && parsedMonths <= 12 {
+			months = parsedMonths
+		}
+	}
+
+	// The plan the org is billed on, which is the default plan for orgs without a subscription
+	current, err := h.quotes.Plan(orgID)
+	if err != nil {
+		if errors.Is(err, quote.ErrNoSubscription) {
+			respondError(w, http.StatusNotFound, "No active subscription", "")
+		} else {
+			respondError(w, http.StatusInternalServerError, "Failed to get current plan", err.Error())
+		}
+		return
+	}
+
+	averageUnits, monthsAnalyzed, err := h.repo.GetAverageMonthlyUsage(r.Context(), orgID, months)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get usage", err.Error())
+		return
+	}
+
+	listed, err := h.repo.ListPlanPricing(r.Context(), current.PlanID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get plans", err.Error())
+		return
+	}
+
+	// Other plans are priced as the org would be billed on them, negotiated pricing included
+	plans := make([]quote.Plan, 0, len(listed))
+	for _, listedPlan := range listed {
+		if listedPlan.PlanID == current.PlanID {
+			plans = append(plans, *current)
+			continue
+		}
+		plan, err := h.quotes.PlanFor(orgID, listedPlan.PlanID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to get plans", err.Error())
+			return
+		}
+		plans = append(plans, *plan)
+	}
+
+	recommendation, err := buildPlanRecommendation(orgID, current.PlanID, plans, averageUnits, h.quotes.Price)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to compare plans", err.Error())
+		return
+	}
+	recommendation.MonthsAnalyzed = monthsAnalyzed
+
+	respondJSON(w, http.StatusOK, recommendation)
+}
+
+// buildPlanRecommendation prices usage on every plan and picks the cheapest that fits
+// Plans are priced with the billing engine's calculator (price), so each total is what the
+// month's invoice would charge on that plan before tax and coupons, minimum commitment included.
+// Savings are measured against the org's current plan
+func buildPlanRecommendation(orgID, currentPlanID string, plans []quote.Plan, averageUnits int64, price func(quote.Plan, quote.Usage) quote.Calculation) (*models.PlanRecommendationResponse, error) {
+	// Step 1: Price the average month on each plan
+	usage := quote.Usage{OrganizationID: orgID, BillableUnits: averageUnits}
+	calcs := make([]quote.Calculation, len(plans))
+	currentTotal := int64(-1)
+	for i, plan := range plans {
+		calcs[i] = price(plan, usage)
+		if plan.PlanID == currentPlanID {
+			currentTotal = calcs[i].TotalCharge
+		}
+	}
+
+	if currentTotal < 0 {
+		return nil, fmt.Errorf("current plan not found: %s", currentPlanID)
+	}
+
+	// Step 2: Build comparisons and find the cheapest plan the usage fits in
+	// Hard-capped plans look free above their cap because overage is capped, so they are skipped
+	comparisons := make([]models.PlanCostComparison, 0, len(plans))
+	recommended := -1
+	for i, plan := range plans {
+		withinLimit := plan.Tier.MaxUnits == 0 || averageUnits <= plan.Tier.MaxUnits
+		total := calcs[i].TotalCharge
+
+		comparisons = append(comparisons, models.PlanCostComparison{
+			PlanID:        plan.PlanID,
+			PlanName:      plan.PlanName,
+			BasePrice:     centsToDollars(calcs[i].BasePrice),
+			OverageCharge: centsToDollars(calcs[i].OverageCharge),
+			TrueUpCharge:  centsToDollars(calcs[i].TrueUpCharge),
+			TotalCharge:   centsToDollars(total),
+			Savings:       centsToDollars(currentTotal - total),
+			IsCurrent:     plan.PlanID == currentPlanID,
+			WithinLimit:   withinLimit,
+		})
+
+		if !withinLimit {
+			continue
+		}
+		// Ties keep the current plan so we never suggest a switch that saves nothing
+		if recommended < 0 || total < calcs[recommended].TotalCharge ||
+			(total == calcs[recommended].TotalCharge && plan.PlanID == currentPlanID) {
+			recommended = i
+		}
+	}
+
+	// Step 3: Fall back to the current plan if nothing fits (e.g. only capped plans)
+	if recommended < 0 {
+		for i, plan := range plans {
+			if plan.PlanID == currentPlanID {
+				recommended = i
+			}
+		}
+	}
+
+	return &models.PlanRecommendationResponse{
+		OrganizationID:      orgID,
+		CurrentPlanID:       currentPlanID,
+		AverageMonthlyUnits: averageUnits,
+		Plans:               comparisons,
+		RecommendedPlanID:   plans[recommended].PlanID,
+		RecommendedPlanName: plans[recommended].PlanName,
+		MonthlySavings:      centsToDollars(currentTotal - calcs[recommended].TotalCharge),
+		Currency:            "USD",
+	}, nil
+}
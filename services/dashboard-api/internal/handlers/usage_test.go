@@ -0,0 +1,654 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/billing-engine/pkg/quote"
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// fakeUsageStore is an in-memory usageStore for handler tests
+type fakeUsageStore struct {
+	averageUnits int64
+	months       int
+	plans        []models.PlanPricing
+
+	// Last usage history query
+	historyStart, historyEnd time.Time
+	historyPage, historySize int
+
+	// Last export query
+	exportOrgID string
+}
+
+func (f *fakeUsageStore) GetUsageHistory(ctx context.Context, orgID string, startDate, endDate time.Time, page, pageSize int) (*models.UsageHistoryResponse, error) {
+	f.historyStart, f.historyEnd = startDate, endDate
+	f.historyPage, f.historySize = page, pageSize
+	return &models.UsageHistoryResponse{
+		OrganizationID: orgID,
+		StartDate:      startDate.Format("2006-01-02"),
+		EndDate:        endDate.Format("2006-01-02"),
+		Page:           page,
+		PageSize:       pageSize,
+	}, nil
+}
+
+func (f *fakeUsageStore) StreamDailyUsage(ctx context.Context, orgID string, startDate, endDate time.Time, fn func(models.UsageExportRow) error) error {
+	f.exportOrgID = orgID
+	for _, row := range exportRows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeUsageStore) GetUsageByMetric(ctx context.Context, orgID, metricName string, days int) ([]models.UsageMetric, error) {
+	return nil, nil
+}
+
+func (f *fakeUsageStore) GetUsageByRegion(ctx context.Context, orgID string, days int) (*models.UsageByRegionResponse, error) {
+	return &models.UsageByRegionResponse{OrganizationID: orgID}, nil
+}
+
+func (f *fakeUsageStore) GetAverageMonthlyUsage(ctx context.Context, orgID string, months int) (int64, int, error) {
+	return f.averageUnits, f.months, nil
+}
+
+func (f *fakeUsageStore) ListPlanPricing(ctx context.Context, includePlanID string) ([]models.PlanPricing, error) {
+	return f.plans, nil
+}
+
+// testTiers mirror the seeded Free, Starter and Growth plans
+var testTiers = map[string]quote.Tier{
+	"free":    {Name: "Free", IncludedUnits: 1000, MaxUnits: 1000},
+	"starter": {Name: "Starter", BasePrice: 2900, IncludedUnits: 100000, OverageRate: 50},
+	"growth":  {Name: "Growth", BasePrice: 9900, IncludedUnits: 1000000, OverageRate: 30},
+}
+
+// testPlans lists testTiers in display order, as ListPlanPricing does
+var testPlans = []models.PlanPricing{
+	{PlanID: "free", PlanName: "Free"},
+	{PlanID: "starter", PlanName: "Starter"},
+	{PlanID: "growth", PlanName: "Growth"},
+}
+
+// newUsageSource bills the org on planID (with override, if any); "" = no subscription
+func newUsageSource(planID string, override *quote.Override) *fakeQuoteSource {
+	source := &fakeQuoteSource{
+		usage:    quote.Usage{Month: march2026, PeriodStart: march2026, PeriodEnd: march2026.AddDate(0, 1, 0)},
+		tiers:    testTiers,
+		override: override,
+	}
+	if planID != "" {
+		source.plan, _ = source.GetPlanForOrganization("org-123", planID)
+	}
+	return source
+}
+
+// plansFor prices every test plan as the source's org would be billed on it
+func plansFor(t *testing.T, source *fakeQuoteSource) []quote.Plan {
+	t.Helper()
+	plans := make([]quote.Plan, 0, len(testPlans))
+	for _, listed := range testPlans {
+		plan, err := source.GetPlanForOrganization("org-123", listed.PlanID)
+		if err != nil {
+			t.Fatalf("GetPlanForOrganization(%s) error = %v", listed.PlanID, err)
+		}
+		plans = append(plans, *plan)
+	}
+	return plans
+}
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func TestBuildPlanRecommendation(t *testing.T) {
+	tests := []struct {
+		name            string
+		currentPlanID   string
+		averageUnits    int64
+		wantRecommended string
+		wantSavings     float64
+	}{
+		{"Over-provisioned on Growth", "growth", 150000, "starter", 45.00},          // Starter $29 + $25 overage vs Growth $99
+		{"Heavy Starter user should upgrade", "starter", 3000000, "growth", 780.00}, // Starter $1479 vs Growth $699
+		{"Free usage stays on Free", "free", 500, "free", 0},
+		{"Capped plan never recommended above its limit", "starter", 50000, "starter", 0},
+	}
+
+	source := newUsageSource("", nil)
+	quoter := newTestQuoter(t, source, "")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildPlanRecommendation("org-123", tt.currentPlanID, plansFor(t, source), tt.averageUnits, quoter.Price)
+			if err != nil {
+				t.Fatalf("buildPlanRecommendation() error = %v", err)
+			}
+			if got.RecommendedPlanID != tt.wantRecommended {
+				t.Errorf("RecommendedPlanID = %q, want %q", got.RecommendedPlanID, tt.wantRecommended)
+			}
+			if got.MonthlySavings != tt.wantSavings {
+				t.Errorf("MonthlySavings = %.2f, want %.2f", got.MonthlySavings, tt.wantSavings)
+			}
+			if got.CurrentPlanID != tt.currentPlanID {
+				t.Errorf("CurrentPlanID = %q, want %q", got.CurrentPlanID, tt.currentPlanID)
+			}
+			if len(got.Plans) != len(testPlans) {
+				t.Fatalf("Plans = %d, want %d", len(got.Plans), len(testPlans))
+			}
+			for _, plan := range got.Plans {
+				if plan.IsCurrent != (plan.PlanID == tt.currentPlanID) {
+					t.Errorf("Plan %s IsCurrent = %v", plan.PlanID, plan.IsCurrent)
+				}
+				if plan.IsCurrent && plan.Savings != 0 {
+					t.Errorf("Current plan savings = %.2f, want 0", plan.Savings)
+				}
+			}
+		})
+	}
+}
+
+// TestBuildPlanRecommendation_MinimumCommitment tests that a negotiated minimum applies on every plan
+func TestBuildPlanRecommendation_MinimumCommitment(t *testing.T) {
+	source := newUsageSource("growth", &quote.Override{MinimumChargeCents: int64Ptr(15000)})
+	quoter := newTestQuoter(t, source, "")
+
+	got, err := buildPlanRecommendation("org-123", "growth", plansFor(t, source), 150000, quoter.Price)
+	if err != nil {
+		t.Fatalf("buildPlanRecommendation() error = %v", err)
+	}
+
+	// Starter's $54 and Growth's $99 are both trued up to $150, so switching saves nothing
+	starter := got.Plans[1]
+	if starter.TotalCharge != 150.00 || starter.TrueUpCharge != 96.00 || starter.Savings != 0 {
+		t.Errorf("Starter = %+v, want $54 trued up to $150", starter)
+	}
+	if got.RecommendedPlanID != "growth" || got.MonthlySavings != 0 {
+		t.Errorf("Recommendation = %s saving %.2f, want to stay on growth", got.RecommendedPlanID, got.MonthlySavings)
+	}
+}
+
+func TestBuildPlanRecommendation_UnknownCurrentPlan(t *testing.T) {
+	source := newUsageSource("", nil)
+	if _, err := buildPlanRecommendation("org-123", "legacy", plansFor(t, source), 1000, newTestQuoter(t, source, "").Price); err == nil {
+		t.Error("Expected error when current plan is not in the plan list")
+	}
+}
+
+func TestGetPlanRecommendation(t *testing.T) {
+	store := &fakeUsageStore{averageUnits: 150000, months: 3, plans: testPlans}
+	h := &UsageHandler{repo: store, quotes: newTestQuoter(t, newUsageSource("growth", nil), "")}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/recommendation", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+	rec := httptest.NewRecorder()
+
+	h.GetPlanRecommendation(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp models.PlanRecommendationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.RecommendedPlanID != "starter" || resp.MonthsAnalyzed != 3 {
+		t.Errorf("Recommendation = %s over %d months, want starter over 3", resp.RecommendedPlanID, resp.MonthsAnalyzed)
+	}
+}
+
+func TestGetPlanRecommendation_NoSubscription(t *testing.T) {
+	h := &UsageHandler{repo: &fakeUsageStore{plans: testPlans}, quotes: newTestQuoter(t, newUsageSource("", nil), "")}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/recommendation", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+	rec := httptest.NewRecorder()
+
+	h.GetPlanRecommendation(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestBuildCurrentUsage(t *testing.T) {
+	asOf := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		planID       string
+		units        int64
+		wantCost     float64
+		wantOverage  int64
+		wantPlanName string
+	}{
+		{"Within included units", "starter", 80000, 29.00, 0, "Starter"},
+		{"Overage billed per 1000 units", "starter", 250000, 104.00, 150000, "Starter"}, // $29 + 150K × $0.50/1K
+		{"Capped plan stops at max units", "free", 5000, 0, 0, "Free"},
+		{"No subscription is priced at zero", "", 250000, 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newUsageSource(tt.planID, nil)
+			source.usage.TotalRequests, source.usage.BillableUnits = tt.units+10, tt.units
+			source.usage.ErrorCount, source.usage.AvgResponseTime = 3, 42.5
+			q, err := newTestQuoter(t, source, "").Quote("org-123")
+			if err != nil {
+				t.Fatalf("Quote() error = %v", err)
+			}
+
+			got := buildCurrentUsage("org-123", q, asOf)
+
+			if got.TotalCost != tt.wantCost {
+				t.Errorf("TotalCost = %.2f, want %.2f", got.TotalCost, tt.wantCost)
+			}
+			if got.OverageUnits != tt.wantOverage {
+				t.Errorf("OverageUnits = %d, want %d", got.OverageUnits, tt.wantOverage)
+			}
+			if got.PlanName != tt.wantPlanName {
+				t.Errorf("PlanName = %q, want %q", got.PlanName, tt.wantPlanName)
+			}
+			if got.TotalRequests != tt.units+10 || got.ErrorCount != 3 || got.AvgResponseTimeMs != 42.5 {
+				t.Errorf("Usage = %+v, want the real-time totals", got)
+			}
+			if got.Date != "2026-03-15" || !got.PeriodStart.Equal(march2026) {
+				t.Errorf("Date = %s from %s, want 2026-03-15 from %s", got.Date, got.PeriodStart, march2026)
+			}
+		})
+	}
+}
+
+func TestGetCurrentUsage_Cached(t *testing.T) {
+	source := newUsageSource("growth", nil)
+	source.usage.TotalRequests, source.usage.BillableUnits = 1500000, 1500000
+	cache := newCurrentUsageCache(currentUsageCacheTTL)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	h := &UsageHandler{repo: &fakeUsageStore{}, quotes: newTestQuoter(t, source, ""), current: cache}
+
+	get := func() models.CurrentUsageResponse {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/current", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+		rec := httptest.NewRecorder()
+		h.GetCurrentUsage(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp models.CurrentUsageResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := get(); resp.TotalCost != 249.00 { // $99 + 500K × $0.30/1K
+		t.Errorf("TotalCost = %.2f, want 249.00", resp.TotalCost)
+	}
+
+	// Refreshes within the TTL are served from the cache
+	source.usage.BillableUnits = 2000000
+	get()
+	if source.realTimeQueries != 1 {
+		t.Errorf("Real-time queries = %d within the TTL, want 1", source.realTimeQueries)
+	}
+
+	now = now.Add(currentUsageCacheTTL)
+	if resp := get(); source.realTimeQueries != 2 || resp.BillableUnits != 2000000 {
+		t.Errorf("After the TTL: %d queries, %d units, want a fresh read of 2000000", source.realTimeQueries, resp.BillableUnits)
+	}
+}
+
+// usageDay is one day of usage as the engine's daily rollup query returns it
+func usageDay(month time.Month, day int, units int64) quote.Usage {
+	start := time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+	return quote.Usage{OrganizationID: "org-123", PeriodStart: start, PeriodEnd: start.AddDate(0, 0, 1), BillableUnits: units}
+}
+
+// overageDays push a Starter org (100K included, $0.50 per 1K over) into overage on Mar 3
+var overageDays = []quote.Usage{
+	usageDay(3, 1, 40000),
+	usageDay(3, 2, 50000),
+	usageDay(3, 3, 30000), // 120K month to date: 20K over
+	usageDay(3, 4, 10000),
+	usageDay(4, 1, 10000), // New billing period, back within included units
+}
+
+func TestPriceUsageHistory(t *testing.T) {
+	march := func(day int) time.Time { return time.Date(2026, 3, day, 0, 0, 0, 0, time.UTC) }
+	source := newUsageSource("starter", nil)
+	source.daily = overageDays
+	quoter := newTestQuoter(t, source, "")
+
+	usageCosts := func(start, end time.Time) *quote.UsageCosts {
+		t.Helper()
+		costs, err := quoter.UsageCosts("org-123", start, end)
+		if err != nil {
+			t.Fatalf("UsageCosts() error = %v", err)
+		}
+		return costs
+	}
+
+	history := &models.UsageHistoryResponse{DailyUsage: []models.DailyUsageSummary{
+		{Date: "2026-03-04", Metrics: []models.UsageMetricSummary{{MetricName: "api_requests"}, {MetricName: "storage_gb", Cost: 1.23}}},
+		{Date: "2026-03-03", Metrics: []models.UsageMetricSummary{{MetricName: "api_requests"}}},
+		{Date: "2026-03-02", Metrics: []models.UsageMetricSummary{{MetricName: "api_requests"}}},
+	}}
+
+	// Mar 2-4, priced against the units already used on Mar 1
+	priceUsageHistory(history, usageCosts(march(2), march(4)))
+
+	wantCosts := []float64{5.00, 10.00, 0}
+	for i, day := range history.DailyUsage {
+		if day.Cost != wantCosts[i] {
+			t.Errorf("Cost on %s = %.2f, want %.2f", day.Date, day.Cost, wantCosts[i])
+		}
+		if day.Metrics[0].Cost != day.Cost {
+			t.Errorf("api_requests cost on %s = %.2f, want the day's %.2f", day.Date, day.Metrics[0].Cost, day.Cost)
+		}
+	}
+	if history.DailyUsage[1].BillableUnits != 30000 {
+		t.Errorf("BillableUnits on Mar 3 = %d, want 30000", history.DailyUsage[1].BillableUnits)
+	}
+	if cost := history.DailyUsage[0].Metrics[1].Cost; cost != 0 {
+		t.Errorf("storage_gb cost = %.2f, want 0 (not billed by the plan)", cost)
+	}
+	if history.TotalCost != 44.00 { // $29 base + $15 overage
+		t.Errorf("TotalCost = %.2f, want 44.00", history.TotalCost)
+	}
+
+	// The whole month matches the invoice
+	full := &models.UsageHistoryResponse{}
+	priceUsageHistory(full, usageCosts(march(1), march(31)))
+	invoice := quoter.Price(*source.plan, quote.Usage{BillableUnits: 130000})
+	if want := centsToDollars(invoice.TotalCharge); full.TotalCost != want {
+		t.Errorf("March TotalCost = %.2f, want the invoice subtotal %.2f", full.TotalCost, want)
+	}
+
+	// Without a subscription nothing is charged
+	source.plan = nil
+	unpriced := &models.UsageHistoryResponse{DailyUsage: []models.DailyUsageSummary{{Date: "2026-03-03"}}}
+	priceUsageHistory(unpriced, usageCosts(march(2), march(4)))
+	if unpriced.TotalCost != 0 || unpriced.DailyUsage[0].Cost != 0 || unpriced.DailyUsage[0].BillableUnits != 30000 {
+		t.Errorf("Unsubscribed = %.2f total, %+v on Mar 3, want the usage at no cost", unpriced.TotalCost, unpriced.DailyUsage[0])
+	}
+}
+
+// TestUsageCosts_MatchInvoicePreview tests that an org with negotiated pricing and a minimum
+// commitment, billed from the 15th, sees the same period total on every usage view as on its invoice
+func TestUsageCosts_MatchInvoicePreview(t *testing.T) {
+	periodStart := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	override := &quote.Override{OverageRate: int64Ptr(20), MinimumChargeCents: int64Ptr(15000)}
+
+	source := newUsageSource("starter", override)
+	source.anchorDay = 15
+	source.daily = []quote.Usage{
+		usageDay(3, 14, 900000), // Previous period
+		usageDay(3, 16, 200000),
+		usageDay(3, 28, 300000), // 500K: $29 + $80 overage, under the $150 minimum
+		usageDay(4, 10, 400000), // 900K: $29 + $160 overage
+	}
+	source.usage = quote.Usage{Month: march2026, PeriodStart: periodStart, PeriodEnd: periodStart.AddDate(0, 1, 0), TotalRequests: 900000, BillableUnits: 900000}
+	quoter := newTestQuoter(t, source, "")
+
+	orgRequest := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		return req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+	}
+	decode := func(rec *httptest.ResponseRecorder, v interface{}) {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+
+	// The invoice preview is priced by the engine exactly as the invoice
+	var preview models.InvoicePreview
+	rec := httptest.NewRecorder()
+	(&InvoiceHandler{quotes: quoter}).PreviewInvoice(rec, orgRequest("/api/v1/invoices/preview"))
+	decode(rec, &preview)
+	if preview.Subtotal != 189.00 || !preview.BillingPeriodStart.Equal(periodStart) {
+		t.Fatalf("Preview = $%.2f from %s, want $189.00 from Mar 15", preview.Subtotal, preview.BillingPeriodStart)
+	}
+
+	store := &fakeUsageStore{averageUnits: 900000, months: 1, plans: testPlans}
+	h := &UsageHandler{repo: store, quotes: quoter}
+
+	var current models.CurrentUsageResponse
+	rec = httptest.NewRecorder()
+	h.GetCurrentUsage(rec, orgRequest("/api/v1/usage/current"))
+	decode(rec, &current)
+	if current.TotalCost != preview.Subtotal || !current.PeriodStart.Equal(periodStart) {
+		t.Errorf("Current usage = $%.2f from %s, want the preview's $%.2f from Mar 15", current.TotalCost, current.PeriodStart, preview.Subtotal)
+	}
+
+	var history models.UsageHistoryResponse
+	rec = httptest.NewRecorder()
+	h.GetUsageHistory(rec, orgRequest("/api/v1/usage/history?start=2026-03-15&end=2026-04-14"))
+	decode(rec, &history)
+	if history.TotalCost != preview.Subtotal {
+		t.Errorf("History total = $%.2f, want the preview's $%.2f", history.TotalCost, preview.Subtotal)
+	}
+
+	var recommendation models.PlanRecommendationResponse
+	rec = httptest.NewRecorder()
+	h.GetPlanRecommendation(rec, orgRequest("/api/v1/usage/recommendation?months=1"))
+	decode(rec, &recommendation)
+	for _, plan := range recommendation.Plans {
+		if plan.IsCurrent && plan.TotalCharge != preview.Subtotal {
+			t.Errorf("Current plan projection = $%.2f, want the preview's $%.2f", plan.TotalCharge, preview.Subtotal)
+		}
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{"Default last 90 days", "", "2025-12-15", "2026-03-15", false},
+		{"Legacy days parameter", "?days=7", "2026-03-08", "2026-03-15", false},
+		{"Explicit range", "?start=2026-01-01&end=2026-01-31", "2026-01-01", "2026-01-31", false},
+		{"Single day", "?start=2026-01-01&end=2026-01-01", "2026-01-01", "2026-01-01", false},
+		{"Full year", "?start=2025-01-01&end=2025-12-31", "2025-01-01", "2025-12-31", false},
+		{"Inverted range", "?start=2026-02-01&end=2026-01-01", "", "", true},
+		{"Range too large", "?start=2024-01-01&end=2026-01-01", "", "", true},
+		{"Bad start format", "?start=01/02/2026", "", "", true},
+		{"Bad end format", "?end=yesterday", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/history"+tt.query, nil)
+			start, end, err := parseDateRange(req, now)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %s - %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDateRange() error = %v", err)
+			}
+			if start.Format("2006-01-02") != tt.wantStart || end.Format("2006-01-02") != tt.wantEnd {
+				t.Errorf("Range = %s - %s, want %s - %s",
+					start.Format("2006-01-02"), end.Format("2006-01-02"), tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestGetUsageHistory_Pagination(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expected     int
+		wantPage     int
+		wantPageSize int
+	}{
+		{"Page and size", "?start=2026-01-01&end=2026-01-31&page=2&page_size=10", http.StatusOK, 2, 10},
+		{"Page size capped", "?start=2026-01-01&end=2026-01-31&page_size=5000", http.StatusOK, 1, 20},
+		{"Inverted range rejected", "?start=2026-02-01&end=2026-01-01", http.StatusBadRequest, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeUsageStore{}
+			h := &UsageHandler{repo: store, quotes: newTestQuoter(t, newUsageSource("", nil), "")}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/history"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+			rec := httptest.NewRecorder()
+
+			h.GetUsageHistory(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, tt.expected, rec.Body.String())
+			}
+			if tt.expected != http.StatusOK {
+				return
+			}
+			if store.historyPage != tt.wantPage || store.historySize != tt.wantPageSize {
+				t.Errorf("Page/size = %d/%d, want %d/%d", store.historyPage, store.historySize, tt.wantPage, tt.wantPageSize)
+			}
+
+			var resp models.UsageHistoryResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.StartDate != "2026-01-01" || resp.EndDate != "2026-01-31" {
+				t.Errorf("Range = %s - %s, want 2026-01-01 - 2026-01-31", resp.StartDate, resp.EndDate)
+			}
+		})
+	}
+}
+
+// exportRows are returned by fakeUsageStore.StreamDailyUsage
+var exportRows = []models.UsageExportRow{
+	{Date: "2026-01-01", TotalRequests: 1200, BillableUnits: 1150, ErrorCount: 3, AvgResponseTimeMs: 42.5},
+	{Date: "2026-01-02", TotalRequests: 800, BillableUnits: 790, ErrorCount: 0, AvgResponseTimeMs: 38},
+}
+
+func TestExportUsage(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		filename    string
+	}{
+		{"CSV by default", "?start=2026-01-01&end=2026-01-31", "text/csv; charset=utf-8", `attachment; filename="usage-org-123-2026-01-01-2026-01-31.csv"`},
+		{"JSON", "?format=json&start=2026-01-01&end=2026-01-31", "application/json", `attachment; filename="usage-org-123-2026-01-01-2026-01-31.json"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeUsageStore{}
+			h := &UsageHandler{repo: store}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/export"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+			rec := httptest.NewRecorder()
+
+			h.ExportUsage(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Status = %d, want %d (body: %s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := rec.Header().Get("Content-Disposition"); got != tt.filename {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.filename)
+			}
+			if store.exportOrgID != "org-123" {
+				t.Errorf("Exported org = %q, want org-123", store.exportOrgID)
+			}
+		})
+	}
+}
+
+func TestExportUsage_CSVBody(t *testing.T) {
+	h := &UsageHandler{repo: &fakeUsageStore{}}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/export?start=2026-01-01&end=2026-01-02", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+	rec := httptest.NewRecorder()
+
+	h.ExportUsage(rec, req)
+
+	expected := "date,total_requests,billable_units,error_count,avg_response_time_ms\n" +
+		"2026-01-01,1200,1150,3,42.50\n" +
+		"2026-01-02,800,790,0,38.00\n"
+	if rec.Body.String() != expected {
+		t.Errorf("CSV body = %q, want %q", rec.Body.String(), expected)
+	}
+}
+
+func TestExportUsage_JSONBody(t *testing.T) {
+	h := &UsageHandler{repo: &fakeUsageStore{}}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/export?format=json&start=2026-01-01&end=2026-01-02", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+	rec := httptest.NewRecorder()
+
+	h.ExportUsage(rec, req)
+
+	var resp struct {
+		OrganizationID string                  `json:"organization_id"`
+		StartDate      string                  `json:"start_date"`
+		EndDate        string                  `json:"end_date"`
+		Rows           []models.UsageExportRow `json:"rows"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid JSON export: %v (body: %s)", err, rec.Body.String())
+	}
+	if resp.OrganizationID != "org-123" || resp.StartDate != "2026-01-01" || resp.EndDate != "2026-01-02" {
+		t.Errorf("Export header = %+v", resp)
+	}
+	if len(resp.Rows) != len(exportRows) || resp.Rows[0] != exportRows[0] {
+		t.Errorf("Rows = %+v, want %+v", resp.Rows, exportRows)
+	}
+}
+
+func TestExportUsage_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Unknown format", "?format=xml"},
+		{"Inverted range", "?start=2026-02-01&end=2026-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &UsageHandler{repo: &fakeUsageStore{}}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/usage/export"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "organization_id", "org-123"))
+			rec := httptest.NewRecorder()
+
+			h.ExportUsage(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
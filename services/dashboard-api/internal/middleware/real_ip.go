@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP sets r.RemoteAddr to the client's IP
+// X-Forwarded-For is only honored when the request comes from a trusted proxy, since any
+// client can send the header. The entries are read right to left, skipping trusted proxies,
+// so the client is the address the outermost trusted proxy saw; entries a client put in
+// front of it are ignored. Without trusted proxies the peer address is always used
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = clientIP(r, trusted)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP resolves the client's IP from the peer address and a trusted proxy's X-Forwarded-For
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !isTrustedProxy(net.ParseIP(peer), trusted) {
+		return peer
+	}
+
+	// Proxies may each add a header or append to one
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// Nothing left of a malformed entry can be trusted
+			break
+		}
+		client = ip.String()
+		if !isTrustedProxy(ip, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+// isTrustedProxy reports whether ip belongs to one of the trusted proxy ranges
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"Direct client", "198.51.100.20:51234", nil, "198.51.100.20"},
+		{"Spoofed header from a direct client", "198.51.100.20:51234", []string{"203.0.113.7"}, "198.51.100.20"},
+		{"Client behind a trusted proxy", "10.0.0.5:443", []string{"198.51.100.20"}, "198.51.100.20"},
+		{"Spoofed entry in front of the proxy's", "10.0.0.5:443", []string{"203.0.113.7, 198.51.100.20"}, "198.51.100.20"},
+		{"Chain of trusted proxies", "10.0.0.5:443", []string{"198.51.100.20, 10.0.1.9"}, "198.51.100.20"},
+		{"Separate headers per proxy", "10.0.0.5:443", []string{"198.51.100.20", "10.0.1.9"}, "198.51.100.20"},
+		{"Malformed entry", "10.0.0.5:443", []string{"198.51.100.20, not-an-ip"}, "10.0.0.5"},
+		{"Trusted proxy without a header", "10.0.0.5:443", nil, "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.expected {
+				t.Errorf("RemoteAddr = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRealIP_NoTrustedProxies(t *testing.T) {
+	var got string
+	h := RealIP(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.RemoteAddr
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.5:443"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "10.0.0.5" {
+		t.Errorf("RemoteAddr = %s, want the peer address", got)
+	}
+}
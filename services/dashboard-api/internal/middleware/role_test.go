@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signTestToken(t *testing.T, secret, role string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":         "user-1",
+		"organization_id": "org-123",
+		"role":            role,
+		"exp":             time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+// TestOptionalAuthMiddleware tests that the public plans route accepts anonymous requests
+func TestOptionalAuthMiddleware(t *testing.T) {
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret"}}
+
+	handler := OptionalAuthMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgID, _ := r.Context().Value("organization_id").(string)
+		w.Write([]byte(orgID))
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantOrgID  string
+	}{
+		{"Anonymous", "", http.StatusOK, ""},
+		{"Valid token", "Bearer " + signTestToken(t, cfg.JWT.Secret, "viewer"), http.StatusOK, "org-123"},
+		{"Invalid token", "Bearer " + signTestToken(t, "other-secret", "viewer"), http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/plans", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != tt.wantOrgID {
+				t.Errorf("organization_id = %q, want %q", rec.Body.String(), tt.wantOrgID)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"context"
-	"database/sql"
 	"net/http"
 	"strings"
 
@@ -12,8 +11,11 @@ import (
 )
 
 // TenantContextMiddleware extracts JWT claims and injects organization_id into context
-// It also sets PostgreSQL session variable for Row-Level Security
-func TenantContextMiddleware(db *sql.DB, cfg *config.Config) func(http.Handler) http.Handler {
+// Repositories set the Row-Level Security tenant in their own transactions (repository.WithTenantConn),
+// so no database connection is held for the request
+func TenantContextMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	tokens := newTokenCache()
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract JWT token from Authorization header
@@ -32,58 +34,59 @@ func TenantContextMiddleware(db *sql.DB, cfg *config.Config) func(http.Handler)
 
 			tokenString := parts[1]
 
-			// Parse and validate JWT token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				// Validate signing method
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrSignatureInvalid
+			// Reuse the claims of a token verified by an earlier request
+			jwtClaims, cached := tokens.Get(tokenString)
+			if !cached {
+				// Parse and validate JWT token
+				token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+					// Validate signing method
+					if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+						return nil, jwt.ErrSignatureInvalid
+					}
+					return []byte(cfg.JWT.Secret), nil
+				})
+
+				if err != nil || !token.Valid {
+					respondUnauthorized(w, "Invalid or expired token")
+					return
 				}
-				return []byte(cfg.JWT.Secret), nil
-			})
 
-			if err != nil || !token.Valid {
-				respondUnauthorized(w, "Invalid or expired token")
-				return
-			}
+				// Extract claims
+				claims, ok := token.Claims.(jwt.MapClaims)
+				if !ok {
+					respondUnauthorized(w, "Invalid token claims")
+					return
+				}
 
-			// Extract claims
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				respondUnauthorized(w, "Invalid token claims")
-				return
-			}
+				// Extract organization_id and other claims
+				orgID, ok := claims["organization_id"].(string)
+				if !ok || orgID == "" {
+					respondUnauthorized(w, "Missing organization_id in token")
+					return
+				}
 
-			// Extract organization_id and other claims
-			orgID, ok := claims["organization_id"].(string)
-			if !ok || orgID == "" {
-				respondUnauthorized(w, "Missing organization_id in token")
-				return
-			}
+				userID, _ := claims["user_id"].(string)
+				email, _ := claims["email"].(string)
+				role, _ := claims["role"].(string)
 
-			userID, _ := claims["user_id"].(string)
-			email, _ := claims["email"].(string)
-			role, _ := claims["role"].(string)
-
-			// Set PostgreSQL session variable for Row-Level Security (RLS)
-			// This allows database-level multi-tenancy enforcement
-			_, err = db.Exec("SET LOCAL app.current_org = $1", orgID)
-			if err != nil {
-				// Log error but don't fail the request
-				// Some queries might not need RLS
-			}
+				// Create JWT claims object for easy access
+				jwtClaims = models.JWTClaims{
+					UserID:         userID,
+					Email:          email,
+					OrganizationID: orgID,
+					Role:           role,
+				}
 
-			// Create JWT claims object for easy access
-			jwtClaims := models.JWTClaims{
-				UserID:         userID,
-				Email:          email,
-				OrganizationID: orgID,
-				Role:           role,
+				// Cache until the token's own expiry
+				if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+					tokens.Set(tokenString, jwtClaims, exp.Time)
+				}
 			}
 
 			// Inject claims and organization_id into request context
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, "organization_id", orgID)
-			ctx = context.WithValue(ctx, "user_id", userID)
+			ctx = context.WithValue(ctx, "organization_id", jwtClaims.OrganizationID)
+			ctx = context.WithValue(ctx, "user_id", jwtClaims.UserID)
 			ctx = context.WithValue(ctx, "claims", jwtClaims)
 
 			// Call next handler with enriched context
@@ -154,6 +157,23 @@ func AuthMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
 	}
 }
 
+// OptionalAuthMiddleware is AuthMiddleware for public routes that personalize their response
+// Requests without an Authorization header pass through anonymously; a present but invalid token is still rejected
+func OptionalAuthMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	auth := AuthMiddleware(cfg)
+
+	return func(next http.Handler) http.Handler {
+		authenticated := auth(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authenticated.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RoleMiddleware checks if user has required role
 func RoleMiddleware(requiredRoles ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+const (
+	// tokenCacheMaxEntries bounds memory; the cache is cleared of expired entries, then emptied, when full
+	tokenCacheMaxEntries = 10000
+	// tokenCacheMaxTTL caps how long a verified token is trusted without re-verification
+	tokenCacheMaxTTL = 5 * time.Minute
+)
+
+// cachedToken is a verified token's claims, valid until expiresAt
+type cachedToken struct {
+	claims    models.JWTClaims
+	expiresAt time.Time
+}
+
+// tokenCache remembers verified JWTs so a burst of dashboard requests parses each token once
+// Entries are keyed by the token's SHA-256 and never outlive the token's own exp claim
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cachedToken
+	now     func() time.Time // Overridable for tests
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		entries: make(map[[sha256.Size]byte]cachedToken),
+		now:     time.Now,
+	}
+}
+
+// Get returns the claims of a previously verified, unexpired token
+func (c *tokenCache) Get(token string) (models.JWTClaims, bool) {
+	key := sha256.Sum256([]byte(token))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return models.JWTClaims{}, false
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return models.JWTClaims{}, false
+	}
+	return entry.claims, true
+}
+
+// Set caches a verified token until its expiry (capped at tokenCacheMaxTTL)
+// Tokens without an exp claim are not cached
+func (c *tokenCache) Set(token string, claims models.JWTClaims, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if limit := now.Add(tokenCacheMaxTTL); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	if len(c.entries) >= tokenCacheMaxEntries {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= tokenCacheMaxEntries {
+			c.entries = make(map[[sha256.Size]byte]cachedToken)
+		}
+	}
+	c.entries[sha256.Sum256([]byte(token))] = cachedToken{claims: claims, expiresAt: expiresAt}
+}
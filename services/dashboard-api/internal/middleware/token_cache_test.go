@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+func TestTokenCache_ExpiresWithToken(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := newTokenCache()
+	cache.now = func() time.Time { return now }
+
+	claims := models.JWTClaims{UserID: "user_1", OrganizationID: "org_1"}
+	cache.Set("token-a", claims, now.Add(time.Minute))
+
+	if got, ok := cache.Get("token-a"); !ok || got != claims {
+		t.Fatalf("Get() = %+v, %v, want cached claims", got, ok)
+	}
+	if _, ok := cache.Get("token-b"); ok {
+		t.Error("Unknown token was a cache hit")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.Get("token-a"); ok {
+		t.Error("Token was served past its exp claim")
+	}
+}
+
+func TestTokenCache_CapsTTLAndSkipsExpired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := newTokenCache()
+	cache.now = func() time.Time { return now }
+
+	claims := models.JWTClaims{OrganizationID: "org_1"}
+	cache.Set("long-lived", claims, now.Add(24*time.Hour))
+	cache.Set("expired", claims, now.Add(-time.Second))
+	cache.Set("no-exp", claims, time.Time{})
+
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("Expired token was cached")
+	}
+	if _, ok := cache.Get("no-exp"); ok {
+		t.Error("Token without exp was cached")
+	}
+
+	now = now.Add(tokenCacheMaxTTL)
+	if _, ok := cache.Get("long-lived"); ok {
+		t.Errorf("Token was trusted for longer than %v without re-verification", tokenCacheMaxTTL)
+	}
+}
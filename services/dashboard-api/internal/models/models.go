@@ -56,13 +56,20 @@ type UsageMetric struct {
 	Timestamp   time.Time `json:"timestamp"`
 }
 
-// CurrentUsageResponse represents real-time usage for today
+// CurrentUsageResponse represents real-time usage of the billing period in progress, read from raw usage events
 type CurrentUsageResponse struct {
-	OrganizationID string               `json:"organization_id"`
-	Date           string               `json:"date"` // YYYY-MM-DD
-	Metrics        []UsageMetricSummary `json:"metrics"`
-	TotalCost      float64              `json:"total_cost"`
-	UpdatedAt      time.Time            `json:"updated_at"`
+	OrganizationID    string    `json:"organization_id"`
+	Date              string    `json:"date"`         // YYYY-MM-DD
+	PeriodStart       time.Time `json:"period_start"` // Start of the billing period, on the org's billing anchor day
+	TotalRequests     int64     `json:"total_requests"`
+	BillableUnits     int64     `json:"billable_units"`
+	ErrorCount        int64     `json:"error_count"` // 5xx responses
+	AvgResponseTimeMs float64   `json:"avg_response_time_ms"`
+	PlanName          string    `json:"plan_name,omitempty"` // Empty without a subscription
+	IncludedUnits     int64     `json:"included_units"`
+	OverageUnits      int64     `json:"overage_units"`
+	TotalCost         float64   `json:"total_cost"` // Period-to-date charge as invoiced, before tax and coupons
+	UpdatedAt         time.Time `json:"updated_at"` // When the usage was read
 }
 
 // UsageMetricSummary represents aggregated usage for a metric
@@ -75,35 +82,150 @@ type UsageMetricSummary struct {
 	Description string  `json:"description,omitempty"`
 }
 
-// UsageHistoryResponse represents historical usage data
+// UsageHistoryResponse represents a page of historical usage data (one entry per day)
 type UsageHistoryResponse struct {
-	OrganizationID string             `json:"organization_id"`
-	StartDate      string             `json:"start_date"` // YYYY-MM-DD
-	EndDate        string             `json:"end_date"`   // YYYY-MM-DD
+	OrganizationID string              `json:"organization_id"`
+	StartDate      string              `json:"start_date"` // YYYY-MM-DD
+	EndDate        string              `json:"end_date"`   // YYYY-MM-DD
 	DailyUsage     []DailyUsageSummary `json:"daily_usage"`
-	TotalCost      float64            `json:"total_cost"`
+	TotalCost      float64             `json:"total_cost"`  // Whole date range, not just this page: daily costs + each billing period's zero-usage charge
+	TotalCount     int                 `json:"total_count"` // Days with usage in the range
+	Page           int                 `json:"page"`
+	PageSize       int                 `json:"page_size"`
+}
+
+// UsageExportRow represents one day of usage in a reconciliation export
+type UsageExportRow struct {
+	Date              string  `json:"date"` // YYYY-MM-DD (UTC)
+	TotalRequests     int64   `json:"total_requests"`
+	BillableUnits     int64   `json:"billable_units"`
+	ErrorCount        int64   `json:"error_count"`
+	AvgResponseTimeMs float64 `json:"avg_response_time_ms"`
 }
 
 // DailyUsageSummary represents usage summary for a single day
 type DailyUsageSummary struct {
-	Date    string               `json:"date"` // YYYY-MM-DD
-	Metrics []UsageMetricSummary `json:"metrics"`
-	Cost    float64              `json:"cost"`
+	Date          string               `json:"date"` // YYYY-MM-DD
+	Metrics       []UsageMetricSummary `json:"metrics"`
+	BillableUnits int64                `json:"billable_units"`
+	Cost          float64              `json:"cost"` // Marginal charge the day added to its billing period
+}
+
+// RegionUsage represents aggregated API requests from a single client region
+type RegionUsage struct {
+	Region           string `json:"region"` // Country code, or "unknown"
+	Requests         int64  `json:"requests"`
+	BillableRequests int64  `json:"billable_requests"`
+}
+
+// UsageByRegionResponse represents usage grouped by client region
+type UsageByRegionResponse struct {
+	OrganizationID string        `json:"organization_id"`
+	StartDate      string        `json:"start_date"` // YYYY-MM-DD
+	EndDate        string        `json:"end_date"`   // YYYY-MM-DD
+	Regions        []RegionUsage `json:"regions"`
+}
+
+// PlanCostComparison represents one plan's projected monthly cost at the org's usage level
+type PlanCostComparison struct {
+	PlanID        string  `json:"plan_id"`
+	PlanName      string  `json:"plan_name"`
+	BasePrice     float64 `json:"base_price"`
+	OverageCharge float64 `json:"overage_charge"`
+	TrueUpCharge  float64 `json:"true_up_charge"` // Shortfall below a negotiated minimum commitment
+	TotalCharge   float64 `json:"total_charge"`
+	Savings       float64 `json:"savings"` // Versus the current plan (negative = more expensive)
+	IsCurrent     bool    `json:"is_current"`
+	WithinLimit   bool    `json:"within_limit"` // False if usage exceeds the plan's hard cap
+}
+
+// PlanRecommendationResponse compares plans at the org's average monthly usage
+type PlanRecommendationResponse struct {
+	OrganizationID      string               `json:"organization_id"`
+	CurrentPlanID       string               `json:"current_plan_id"`
+	AverageMonthlyUnits int64                `json:"average_monthly_units"`
+	MonthsAnalyzed      int                  `json:"months_analyzed"`
+	Plans               []PlanCostComparison `json:"plans"`
+	RecommendedPlanID   string               `json:"recommended_plan_id"`
+	RecommendedPlanName string               `json:"recommended_plan_name"`
+	MonthlySavings      float64              `json:"monthly_savings"` // Recommended versus current plan
+	Currency            string               `json:"currency"`
 }
 
 // APIKey represents an API key for authentication
 type APIKey struct {
-	ID             string     `json:"id"`
-	OrganizationID string     `json:"organization_id"`
-	Name           string     `json:"name"`
-	KeyPrefix      string     `json:"key_prefix"` // First 8 chars for display
-	KeyHash        string     `json:"-"`          // Never expose full key
-	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
-	CreatedAt      time.Time  `json:"created_at"`
-	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
-	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
-	Status         string     `json:"status"` // active, revoked, expired
-	CreatedBy      string     `json:"created_by"` // User ID
+	ID                string     `json:"id"`
+	OrganizationID    string     `json:"organization_id"`
+	Name              string     `json:"name"`
+	KeyPrefix         string     `json:"key_prefix"` // First 8 chars for display
+	KeyHash           string     `json:"-"`          // Never expose full key
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	Status            string     `json:"status"`                        // active, rotating, revoked, expired
+	CreatedBy         string     `json:"created_by"`                    // User ID
+	RotationExpiresAt *time.Time `json:"rotation_expires_at,omitempty"` // When a rotating key stops working
+	AllowedCIDRs      []string   `json:"allowed_cidrs"`                 // Source IP ranges the key may be used from (empty = any)
+	DaysUntilExpiry   *int       `json:"days_until_expiry,omitempty"`   // Whole days left; omitted without a future expires_at
+}
+
+// DaysUntilExpiry returns the whole days until expiresAt (0 within the last day), or nil if
+// the key never expires or has already expired
+func DaysUntilExpiry(expiresAt *time.Time, now time.Time) *int {
+	if expiresAt == nil || !expiresAt.After(now) {
+		return nil
+	}
+	days := int(expiresAt.Sub(now) / (24 * time.Hour))
+	return &days
+}
+
+// ExpiringAPIKey is an active key nearing its expiry, with the contact to remind
+type ExpiringAPIKey struct {
+	ID               string
+	OrganizationID   string
+	OrganizationName string
+	BillingEmail     string
+	Name             string
+	KeyPrefix        string
+	ExpiresAt        time.Time
+}
+
+// MaxActiveAPIKeysByPlan caps the number of active API keys per organization plan tier
+var MaxActiveAPIKeysByPlan = map[string]int{
+	"basic":      5,
+	"premium":    25,
+	"enterprise": 100,
+}
+
+// MaxActiveAPIKeys returns the active key limit for a plan tier (unknown tiers get the basic limit)
+func MaxActiveAPIKeys(planTier string) int {
+	if limit, ok := MaxActiveAPIKeysByPlan[planTier]; ok {
+		return limit
+	}
+	return MaxActiveAPIKeysByPlan["basic"]
+}
+
+// API key list sort orders: created_at and last_used_at are newest first, name is A-Z
+var APIKeySortFields = []string{"created_at", "last_used_at", "name"}
+
+// API key list status filters; "expired" includes active keys past their expires_at
+var APIKeyStatusFilters = []string{"active", "rotating", "revoked", "expired"}
+
+// APIKeyListOptions selects one page of an organization's API keys
+type APIKeyListOptions struct {
+	Page     int
+	PageSize int
+	Sort     string // One of APIKeySortFields; empty means created_at
+	Status   string // One of APIKeyStatusFilters; empty means every key
+}
+
+// APIKeyListResponse represents a page of API keys
+type APIKeyListResponse struct {
+	APIKeys    []APIKey `json:"api_keys"`
+	TotalCount int      `json:"total_count"`
+	Page       int      `json:"page"`
+	PageSize   int      `json:"page_size"`
 }
 
 // CreateAPIKeyRequest represents request to create a new API key
@@ -119,6 +241,82 @@ type CreateAPIKeyResponse struct {
 	Message   string  `json:"message"`
 }
 
+// RotateAPIKeyResponse represents the response with a rotated API key's replacement
+type RotateAPIKeyResponse struct {
+	APIKey           *APIKey    `json:"api_key"`
+	FullKey          string     `json:"full_key"`                      // Only returned once at rotation
+	OldKeyValidUntil *time.Time `json:"old_key_valid_until,omitempty"` // End of the grace period (omitted when revoked at once)
+	Message          string     `json:"message"`
+}
+
+// UpdateAllowedCIDRsRequest replaces an API key's IP allowlist (empty allows any IP)
+type UpdateAllowedCIDRsRequest struct {
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+}
+
+// MaxAllowedCIDRs caps the number of IP ranges on one API key
+const MaxAllowedCIDRs = 50
+
+// API key audit actions
+const (
+	APIKeyAuditCreated          = "created"
+	APIKeyAuditRevoked          = "revoked"
+	APIKeyAuditRotated          = "rotated"
+	APIKeyAuditAllowlistUpdated = "allowlist_updated"
+)
+
+// APIKeyActor identifies who made an API key change, for the audit log
+type APIKeyActor struct {
+	UserID    string // From the JWT claims
+	IPAddress string // Client IP as resolved by the RealIP middleware
+}
+
+// APIKeyAuditEntry is one row of an API key's lifecycle history
+type APIKeyAuditEntry struct {
+	ID          string    `json:"id"`
+	KeyID       string    `json:"key_id"`
+	Action      string    `json:"action"` // created, revoked, rotated, allowlist_updated
+	ActorUserID string    `json:"actor_user_id"`
+	IPAddress   string    `json:"ip_address"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// APIKeyUsageResponse summarizes the traffic attributed to one API key over a date range
+type APIKeyUsageResponse struct {
+	KeyID            string  `json:"key_id"`
+	StartDate        string  `json:"start_date"` // YYYY-MM-DD
+	EndDate          string  `json:"end_date"`   // YYYY-MM-DD, inclusive
+	TotalRequests    int64   `json:"total_requests"`
+	BillableRequests int64   `json:"billable_requests"`
+	BillableUnits    int64   `json:"billable_units"`
+	ErrorCount       int64   `json:"error_count"`        // 5xx responses
+	ClientErrorCount int64   `json:"client_error_count"` // 4xx responses
+	ErrorRate        float64 `json:"error_rate"`         // 4xx and 5xx responses / total requests
+}
+
+// Organization statuses; the gateway rejects API requests of suspended organizations with 402
+const (
+	OrgStatusActive    = "active"
+	OrgStatusSuspended = "suspended"
+
+	// SuspensionReasonNonPayment marks suspensions made by the billing engine's dunning flow
+	// They are lifted by paying, not from the dashboard
+	SuspensionReasonNonPayment = "non_payment"
+)
+
+// OrganizationStatus is the response of the /organization endpoints
+type OrganizationStatus struct {
+	OrganizationID   string     `json:"organization_id"`
+	Status           string     `json:"status"` // active, suspended
+	SuspensionReason *string    `json:"suspension_reason,omitempty"`
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+}
+
+// SuspendOrganizationRequest is the body of POST /organization/suspend
+type SuspendOrganizationRequest struct {
+	Reason string `json:"reason"`
+}
+
 // Invoice represents an invoice
 type Invoice struct {
 	ID                string    `json:"id"`
@@ -128,19 +326,85 @@ type Invoice struct {
 	CustomerEmail     string    `json:"customer_email"`
 	BillingPeriodStart time.Time `json:"billing_period_start"`
 	BillingPeriodEnd   time.Time `json:"billing_period_end"`
-	Status            string    `json:"status"` // draft, pending, paid, failed, refunded, voided
+	Status            string    `json:"status"` // draft, pending, paid, failed, refunded, partially_refunded, voided
 	Subtotal          float64   `json:"subtotal"`
 	Tax               float64   `json:"tax"`
 	Total             float64   `json:"total"`
+	RefundedAmount    float64   `json:"refunded_amount"`
 	Currency          string    `json:"currency"`
 	DueDate           time.Time `json:"due_date"`
 	PaidAt            *time.Time `json:"paid_at,omitempty"`
 	PDFURL            string    `json:"pdf_url,omitempty"`
 	StripeInvoiceID   string    `json:"stripe_invoice_id,omitempty"`
+	Notes             string    `json:"notes,omitempty"`     // Shown in the PDF footer and invoice email
+	PONumber          string    `json:"po_number,omitempty"` // Customer purchase-order number
+	CustomFields      map[string]string `json:"custom_fields,omitempty"` // Printed on the PDF and sent as Stripe metadata
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 }
 
+// VoidInvoiceRequest is the body of POST /invoices/{id}/void
+type VoidInvoiceRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Limits on UpdateInvoiceRequest fields (the PDF shows at most 1000 characters of notes)
+const (
+	MaxInvoiceNotesLength    = 1000
+	MaxInvoicePONumberLength = 100
+)
+
+// Limits on invoice custom fields, sized to fit a row of the PDF's invoice details box
+const (
+	MaxInvoiceCustomFields          = 10
+	MaxInvoiceCustomFieldNameLength = 20
+	MaxInvoiceCustomFieldLength     = 40
+)
+
+// ReservedInvoiceCustomFields are Stripe metadata keys the billing engine sets itself
+var ReservedInvoiceCustomFields = []string{"invoice_id", "invoice_number", "organization_id", "billing_month", "po_number"}
+
+// UpdateInvoiceRequest is the body of PATCH /invoices/{id}
+// Omitted fields are left unchanged; an empty string clears a field
+// custom_fields replaces all of the invoice's custom fields ({} clears them)
+type UpdateInvoiceRequest struct {
+	Notes        *string           `json:"notes"`
+	PONumber     *string           `json:"po_number"`
+	CustomFields map[string]string `json:"custom_fields"`
+}
+
+// InvoiceDefaults are copied onto each new invoice of an organization
+// They can be overridden per invoice with PATCH /invoices/{id}
+type InvoiceDefaults struct {
+	OrganizationID  string            `json:"organization_id"`
+	DefaultPONumber string            `json:"default_po_number"`
+	CustomFields    map[string]string `json:"custom_fields"`
+}
+
+// UpdateInvoiceDefaultsRequest is the body of PATCH /organization/invoice-defaults
+// Omitted fields are left unchanged, as in UpdateInvoiceRequest
+type UpdateInvoiceDefaultsRequest struct {
+	DefaultPONumber *string           `json:"default_po_number"`
+	CustomFields    map[string]string `json:"custom_fields"`
+}
+
+// RefundInvoiceRequest is the body of POST /invoices/{id}/refund
+type RefundInvoiceRequest struct {
+	AmountCents int64  `json:"amount_cents"`
+	Reason      string `json:"reason"`
+}
+
+// InvoiceRefund is a refund requested against a paid invoice
+// Refunds are issued on Stripe by the billing engine; Status moves from pending to succeeded or failed
+type InvoiceRefund struct {
+	ID          string    `json:"id"`
+	InvoiceID   string    `json:"invoice_id"`
+	AmountCents int64     `json:"amount_cents"`
+	Reason      string    `json:"reason"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 // InvoiceLineItem represents a line item on an invoice
 type InvoiceLineItem struct {
 	ID          string  `json:"id"`
@@ -160,11 +424,96 @@ type InvoiceListResponse struct {
 	PageSize   int       `json:"page_size"`
 }
 
+// InvoiceLineItemListResponse represents a page of invoice line items
+type InvoiceLineItemListResponse struct {
+	InvoiceID  string            `json:"invoice_id"`
+	LineItems  []InvoiceLineItem `json:"line_items"`
+	TotalCount int               `json:"total_count"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+}
+
+// PlanPricing holds a plan's list pricing
+type PlanPricing struct {
+	PlanID           string `json:"plan_id"`
+	PlanName         string `json:"plan_name"`
+	BasePriceCents   int64  `json:"base_price_cents"`
+	IncludedUnits    int64  `json:"included_units"`
+	OverageRateCents int64  `json:"overage_rate_cents"` // Per 1000 units
+	MaxUnits         int64  `json:"max_units"`          // 0 = unlimited
+}
+
+// Plan is a pricing plan as listed publicly by GET /api/v1/plans
+type Plan struct {
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Description        string   `json:"description"`
+	BasePriceCents     int64    `json:"base_price_cents"`
+	BasePriceDisplay   string   `json:"base_price_display"` // e.g., "$99.00"
+	IncludedUnits      int64    `json:"included_units"`
+	OverageRateCents   int64    `json:"overage_rate_cents"`   // Per 1000 units
+	OverageRateDisplay string   `json:"overage_rate_display"` // e.g., "$0.05 per 1,000 requests"
+	MaxUnits           int64    `json:"max_units"`            // 0 = unlimited
+	Features           []string `json:"features"`
+	IsCurrent          bool     `json:"is_current"` // Requesting organization's plan; always false unauthenticated
+	IsActive           bool     `json:"-"`
+}
+
+// PlanListResponse is the response of GET /api/v1/plans
+type PlanListResponse struct {
+	Plans         []Plan `json:"plans"`
+	CurrentPlanID string `json:"current_plan_id,omitempty"`
+}
+
+// ChangePlanRequest is the body of POST /api/v1/subscription/change-plan
+type ChangePlanRequest struct {
+	PlanID  string `json:"plan_id"`
+	Preview bool   `json:"preview"` // Compute the proration without changing the plan
+}
+
+// PlanChange is a subscription plan change, prorated over the rest of the billing period
+type PlanChange struct {
+	ID                    string    `json:"id,omitempty"` // Empty for previews
+	OrganizationID        string    `json:"organization_id"`
+	FromPlanID            string    `json:"from_plan_id"`
+	ToPlanID              string    `json:"to_plan_id"`
+	ToPlanName            string    `json:"to_plan_name"`
+	Direction             string    `json:"direction"` // upgrade, downgrade
+	EffectiveAt           time.Time `json:"effective_at"`
+	PeriodStart           time.Time `json:"period_start"`
+	PeriodEnd             time.Time `json:"period_end"`              // Exclusive
+	ProratedAmountCents   int64     `json:"prorated_amount_cents"`   // Positive = charge, negative = credit
+	ProratedAmountDisplay string    `json:"prorated_amount_display"` // e.g., "$35.00" or "-$12.50"
+	Preview               bool      `json:"preview"`
+	StripeSyncPending     bool      `json:"stripe_sync_pending"` // The billing engine updates the Stripe subscription
+}
+
+// InvoicePreview represents a projected invoice for the current period (not persisted)
+type InvoicePreview struct {
+	OrganizationID     string            `json:"organization_id"`
+	IsEstimate         bool              `json:"is_estimate"` // Always true
+	AsOf               time.Time         `json:"as_of"`       // Usage counted up to this time
+	PlanName           string            `json:"plan_name"`
+	BillingPeriodStart time.Time         `json:"billing_period_start"`
+	BillingPeriodEnd   time.Time         `json:"billing_period_end"`
+	UsedUnits          int64             `json:"used_units"`
+	IncludedUnits      int64             `json:"included_units"`
+	OverageUnits       int64             `json:"overage_units"`
+	UnitLabel          string            `json:"unit_label"` // e.g. "requests", "messages"
+	LineItems          []InvoiceLineItem `json:"line_items"`
+	Subtotal           float64           `json:"subtotal"`
+	EstimatedTax       float64           `json:"estimated_tax"`
+	Total              float64           `json:"total"`
+	Currency           string            `json:"currency"`
+	Note               string            `json:"note"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
-	Code    string `json:"code,omitempty"`
+	Error   string       `json:"error"`
+	Message string       `json:"message"`
+	Code    string       `json:"code,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"` // Set when Code is ErrCodeValidationFailed
 }
 
 // SuccessResponse represents a generic success response
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrorResponse codes for requests that fail validation; Fields lists the problems
+const ErrCodeValidationFailed = "validation_failed"
+
+// Field error codes; clients can match on these rather than on the message
+const (
+	FieldCodeRequired      = "required"
+	FieldCodeTooLong       = "too_long"
+	FieldCodeInvalidFormat = "invalid_format"
+	FieldCodeNotInFuture   = "not_in_future"
+	FieldCodeInvalidChoice = "invalid_choice"
+)
+
+// MaxAPIKeyNameLength matches api_keys.name (VARCHAR(100))
+const MaxAPIKeyNameLength = 100
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	Field   string `json:"field"` // JSON name of the field
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Validate checks the login credentials' shape before they are looked up
+func (r LoginRequest) Validate() []FieldError {
+	var errs []FieldError
+	switch {
+	case r.Email == "":
+		errs = append(errs, FieldError{Field: "email", Code: FieldCodeRequired, Message: "Email is required"})
+	case !validEmail(r.Email):
+		errs = append(errs, FieldError{Field: "email", Code: FieldCodeInvalidFormat, Message: "Email must be an address like user@company.com"})
+	}
+	if r.Password == "" {
+		errs = append(errs, FieldError{Field: "password", Code: FieldCodeRequired, Message: "Password is required"})
+	}
+	return errs
+}
+
+// Validate checks a new API key's name and expiry; Name must already be trimmed
+func (r CreateAPIKeyRequest) Validate(now time.Time) []FieldError {
+	var errs []FieldError
+	switch {
+	case r.Name == "":
+		errs = append(errs, FieldError{Field: "name", Code: FieldCodeRequired, Message: "API key name is required"})
+	case utf8.RuneCountInString(r.Name) > MaxAPIKeyNameLength:
+		errs = append(errs, FieldError{Field: "name", Code: FieldCodeTooLong, Message: fmt.Sprintf("API key name must be at most %d characters", MaxAPIKeyNameLength)})
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(now) {
+		errs = append(errs, FieldError{Field: "expires_at", Code: FieldCodeNotInFuture, Message: "Expiry must be in the future"})
+	}
+	return errs
+}
+
+// Validate checks the sort and status filter against the supported values
+func (o APIKeyListOptions) Validate() []FieldError {
+	var errs []FieldError
+	if o.Sort != "" && !slices.Contains(APIKeySortFields, o.Sort) {
+		errs = append(errs, FieldError{Field: "sort", Code: FieldCodeInvalidChoice, Message: "Sort must be one of " + strings.Join(APIKeySortFields, ", ")})
+	}
+	if o.Status != "" && !slices.Contains(APIKeyStatusFilters, o.Status) {
+		errs = append(errs, FieldError{Field: "status", Code: FieldCodeInvalidChoice, Message: "Status must be one of " + strings.Join(APIKeyStatusFilters, ", ")})
+	}
+	return errs
+}
+
+// validEmail accepts a bare address (no display name) with a dotted domain
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	return strings.Contains(email[at+1:], ".")
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// expiringKeyStore finds expiring keys and records sent reminders (implemented by
+// repository.APIKeyRepository)
+type expiringKeyStore interface {
+	ListExpiringAPIKeys(ctx context.Context, from, to time.Time) ([]models.ExpiringAPIKey, error)
+	ClaimExpiryReminder(ctx context.Context, keyID string, expiresAt time.Time, sentTo string) (bool, error)
+	ReleaseExpiryReminder(ctx context.Context, keyID string, expiresAt time.Time) error
+}
+
+// mailer delivers a plain-text email (implemented by SMTPMailer)
+type mailer interface {
+	Send(to, subject, body string) error
+}
+
+// KeyExpiryReminder emails each organization's billing contact before its API keys expire
+// Each key is reminded about once per expiry, however often the job runs
+type KeyExpiryReminder struct {
+	store  expiringKeyStore
+	mailer mailer
+	window time.Duration
+	from   string           // Signature, e.g. "Billing Team"
+	now    func() time.Time // Overridable for tests
+}
+
+// NewKeyExpiryReminder creates a reminder for keys expiring within window
+func NewKeyExpiryReminder(store expiringKeyStore, mailer mailer, window time.Duration, from string) *KeyExpiryReminder {
+	return &KeyExpiryReminder{
+		store:  store,
+		mailer: mailer,
+		window: window,
+		from:   from,
+		now:    time.Now,
+	}
+}
+
+// SendDue sends one email per organization listing its keys that expire within the window
+// and were not reminded about yet. Returns the number of emails sent; a failed email is
+// released and retried on the next run
+func (r *KeyExpiryReminder) SendDue(ctx context.Context) (int, error) {
+	now := r.now()
+	keys, err := r.store.ListExpiringAPIKeys(ctx, now, now.Add(r.window))
+	if err != nil {
+		return 0, err
+	}
+
+	// Group by organization, keeping the soonest-first order
+	var orgIDs []string
+	byOrg := make(map[string][]models.ExpiringAPIKey)
+	for _, key := range keys {
+		if _, ok := byOrg[key.OrganizationID]; !ok {
+			orgIDs = append(orgIDs, key.OrganizationID)
+		}
+		byOrg[key.OrganizationID] = append(byOrg[key.OrganizationID], key)
+	}
+
+	sent, failed := 0, 0
+	var firstErr error
+	for _, orgID := range orgIDs {
+		if err := r.remind(ctx, byOrg[orgID], now); err != nil {
+			log.Printf("API key expiry reminder for organization %s failed: %v", orgID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	if failed > 0 {
+		return sent, fmt.Errorf("%d of %d expiry reminders failed: %w", failed, len(orgIDs), firstErr)
+	}
+	return sent, nil
+}
+
+// remind claims an organization's keys and emails the ones no other replica claimed first
+func (r *KeyExpiryReminder) remind(ctx context.Context, keys []models.ExpiringAPIKey, now time.Time) error {
+	to := keys[0].BillingEmail
+
+	var claimed []models.ExpiringAPIKey
+	for _, key := range keys {
+		ok, err := r.store.ClaimExpiryReminder(ctx, key.ID, key.ExpiresAt, to)
+		if err != nil {
+			r.release(ctx, claimed)
+			return err
+		}
+		if ok {
+			claimed = append(claimed, key)
+		}
+	}
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	subject, body := r.buildEmail(claimed, now)
+	if err := r.mailer.Send(to, subject, body); err != nil {
+		r.release(ctx, claimed)
+		return fmt.Errorf("failed to send expiry reminder to %s: %w", to, err)
+	}
+	return nil
+}
+
+// release removes claims after a failed send
+func (r *KeyExpiryReminder) release(ctx context.Context, keys []models.ExpiringAPIKey) {
+	for _, key := range keys {
+		if err := r.store.ReleaseExpiryReminder(ctx, key.ID, key.ExpiresAt); err != nil {
+			log.Printf("Failed to release expiry reminder for API key %s: %v", key.ID, err)
+		}
+	}
+}
+
+// buildEmail returns the subject and body of a reminder for one organization's keys
+func (r *KeyExpiryReminder) buildEmail(keys []models.ExpiringAPIKey, now time.Time) (string, string) {
+	subject := "Your API key expires soon"
+	if len(keys) > 1 {
+		subject = fmt.Sprintf("%d of your API keys expire soon", len(keys))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dear %s,\n\n", keys[0].OrganizationName)
+	b.WriteString("The following API keys of your organization expire soon:\n\n")
+	for _, key := range keys {
+		name := key.Name
+		if name == "" {
+			name = "Unnamed key"
+		}
+		fmt.Fprintf(&b, "- %s (%s...) expires %s, %s\n",
+			name, key.KeyPrefix, key.ExpiresAt.UTC().Format("January 2, 2006 at 15:04 MST"), expiresIn(key.ExpiresAt, now))
+	}
+	b.WriteString(`
+Requests made with an expired key are rejected. To avoid an outage, create a new key in the
+dashboard, deploy it, and revoke the old key once nothing uses it.
+
+`)
+	fmt.Fprintf(&b, "Best regards,\n%s\n", r.from)
+
+	return subject, b.String()
+}
+
+// expiresIn describes the time left before expiresAt, e.g. "in 3 days"
+func expiresIn(expiresAt, now time.Time) string {
+	days := models.DaysUntilExpiry(&expiresAt, now)
+	switch {
+	case days == nil || *days == 0:
+		return "in less than a day"
+	case *days == 1:
+		return "in 1 day"
+	default:
+		return fmt.Sprintf("in %d days", *days)
+	}
+}
+
+// Run calls SendDue every interval until ctx is cancelled
+func (r *KeyExpiryReminder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := r.SendDue(ctx)
+			if err != nil {
+				log.Printf("API key expiry reminders failed: %v", err)
+			}
+			if sent > 0 {
+				log.Printf("Sent %d API key expiry reminders", sent)
+			}
+		}
+	}
+}
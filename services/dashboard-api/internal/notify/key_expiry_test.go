@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeKeyStore serves keys expiring within the requested window and records claims
+type fakeKeyStore struct {
+	keys    []models.ExpiringAPIKey
+	claimed map[string]bool
+	from    time.Time
+	to      time.Time
+}
+
+func newFakeKeyStore(keys ...models.ExpiringAPIKey) *fakeKeyStore {
+	return &fakeKeyStore{keys: keys, claimed: make(map[string]bool)}
+}
+
+func (f *fakeKeyStore) ListExpiringAPIKeys(ctx context.Context, from, to time.Time) ([]models.ExpiringAPIKey, error) {
+	f.from, f.to = from, to
+	var due []models.ExpiringAPIKey
+	for _, key := range f.keys {
+		if key.ExpiresAt.After(from) && !key.ExpiresAt.After(to) && !f.claimed[key.ID] {
+			due = append(due, key)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeKeyStore) ClaimExpiryReminder(ctx context.Context, keyID string, expiresAt time.Time, sentTo string) (bool, error) {
+	if f.claimed[keyID] {
+		return false, nil
+	}
+	f.claimed[keyID] = true
+	return true, nil
+}
+
+func (f *fakeKeyStore) ReleaseExpiryReminder(ctx context.Context, keyID string, expiresAt time.Time) error {
+	delete(f.claimed, keyID)
+	return nil
+}
+
+// fakeMailer records sent emails
+type fakeMailer struct {
+	sent []sentEmail
+	err  error
+}
+
+type sentEmail struct {
+	to, subject, body string
+}
+
+func (f *fakeMailer) Send(to, subject, body string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, sentEmail{to, subject, body})
+	return nil
+}
+
+func expiringKey(id, orgID string, expiresIn time.Duration) models.ExpiringAPIKey {
+	return models.ExpiringAPIKey{
+		ID:               id,
+		OrganizationID:   orgID,
+		OrganizationName: "Org " + orgID,
+		BillingEmail:     "billing@" + orgID + ".test",
+		Name:             "Key " + id,
+		KeyPrefix:        "sk_" + id,
+		ExpiresAt:        testNow.Add(expiresIn),
+	}
+}
+
+func newTestReminder(store *fakeKeyStore, mailer *fakeMailer) *KeyExpiryReminder {
+	r := NewKeyExpiryReminder(store, mailer, 7*24*time.Hour, "Billing Team")
+	r.now = func() time.Time { return testNow }
+	return r
+}
+
+// TestKeyExpiryReminder_Window tests which keys fall inside the reminder window
+func TestKeyExpiryReminder_Window(t *testing.T) {
+	store := newFakeKeyStore(
+		expiringKey("soon", "acme", time.Hour),
+		expiringKey("edge", "acme", 7*24*time.Hour),
+		expiringKey("later", "acme", 7*24*time.Hour+time.Minute),
+		expiringKey("gone", "acme", -time.Minute),
+	)
+	mailer := &fakeMailer{}
+
+	sent, err := newTestReminder(store, mailer).SendDue(context.Background())
+	if err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+
+	if !store.from.Equal(testNow) || !store.to.Equal(testNow.Add(7*24*time.Hour)) {
+		t.Errorf("Window = (%v, %v], want (%v, %v]", store.from, store.to, testNow, testNow.Add(7*24*time.Hour))
+	}
+	if sent != 1 || len(mailer.sent) != 1 {
+		t.Fatalf("Sent = %d (%d emails), want 1", sent, len(mailer.sent))
+	}
+
+	body := mailer.sent[0].body
+	for _, want := range []string{"Key soon (sk_soon...)", "in less than a day", "Key edge (sk_edge...)", "in 7 days"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Body missing %q:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"Key later", "Key gone"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("Body mentions %q, which is outside the window", unwanted)
+		}
+	}
+	if got := mailer.sent[0].subject; got != "2 of your API keys expire soon" {
+		t.Errorf("Subject = %q", got)
+	}
+}
+
+// TestKeyExpiryReminder_OneEmailPerOrg tests grouping and that reminders are not repeated
+func TestKeyExpiryReminder_OneEmailPerOrg(t *testing.T) {
+	store := newFakeKeyStore(
+		expiringKey("a1", "acme", time.Hour),
+		expiringKey("g1", "globex", 2*time.Hour),
+		expiringKey("a2", "acme", 3*24*time.Hour),
+	)
+	mailer := &fakeMailer{}
+	r := newTestReminder(store, mailer)
+
+	if sent, err := r.SendDue(context.Background()); err != nil || sent != 2 {
+		t.Fatalf("SendDue() = %d, %v, want 2 emails", sent, err)
+	}
+	if mailer.sent[0].to != "billing@acme.test" || mailer.sent[1].to != "billing@globex.test" {
+		t.Errorf("Recipients = %s, %s, want acme then globex", mailer.sent[0].to, mailer.sent[1].to)
+	}
+	if got := mailer.sent[1].subject; got != "Your API key expires soon" {
+		t.Errorf("Single-key subject = %q", got)
+	}
+
+	// The next run finds nothing new
+	if sent, err := r.SendDue(context.Background()); err != nil || sent != 0 {
+		t.Errorf("Second SendDue() = %d, %v, want 0", sent, err)
+	}
+	if len(mailer.sent) != 2 {
+		t.Errorf("Emails after second run = %d, want 2", len(mailer.sent))
+	}
+}
+
+// TestKeyExpiryReminder_SendFailureRetries tests that a failed email releases its claims
+func TestKeyExpiryReminder_SendFailureRetries(t *testing.T) {
+	store := newFakeKeyStore(expiringKey("a1", "acme", time.Hour))
+	mailer := &fakeMailer{err: errors.New("smtp down")}
+	r := newTestReminder(store, mailer)
+
+	if sent, err := r.SendDue(context.Background()); err == nil || sent != 0 {
+		t.Fatalf("SendDue() = %d, %v, want an error and nothing sent", sent, err)
+	}
+	if store.claimed["a1"] {
+		t.Error("Expected the claim to be released after the failed send")
+	}
+
+	mailer.err = nil
+	if sent, err := r.SendDue(context.Background()); err != nil || sent != 1 {
+		t.Errorf("Retry SendDue() = %d, %v, want 1", sent, err)
+	}
+}
+
+// TestKeyExpiryReminder_ClaimedElsewhere tests that keys another replica claimed are skipped
+func TestKeyExpiryReminder_ClaimedElsewhere(t *testing.T) {
+	store := newFakeKeyStore(expiringKey("a1", "acme", time.Hour), expiringKey("a2", "acme", 2*time.Hour))
+	mailer := &fakeMailer{}
+	r := newTestReminder(store, mailer)
+
+	// Listed before the other replica's claim commits
+	keys, _ := store.ListExpiringAPIKeys(context.Background(), testNow, testNow.Add(r.window))
+	store.claimed["a1"] = true
+	if err := r.remind(context.Background(), keys, testNow); err != nil {
+		t.Fatalf("remind() error = %v", err)
+	}
+
+	if len(mailer.sent) != 1 || strings.Contains(mailer.sent[0].body, "Key a1") || !strings.Contains(mailer.sent[0].body, "Key a2") {
+		t.Errorf("Expected one email listing only a2, got %+v", mailer.sent)
+	}
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/smtp"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/config"
+)
+
+// SMTPMailer sends plain-text notification emails through the configured SMTP server
+type SMTPMailer struct {
+	cfg config.EmailConfig
+}
+
+// NewSMTPMailer creates a mailer for the given SMTP settings
+func NewSMTPMailer(cfg config.EmailConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Send delivers one message to a single recipient
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	if !m.cfg.Enabled {
+		return fmt.Errorf("email sending is disabled")
+	}
+
+	message := m.buildMessage(to, subject, body, time.Now())
+	addr := fmt.Sprintf("%s:%d", m.cfg.SMTPHost, m.cfg.SMTPPort)
+	auth := smtp.PlainAuth("", m.cfg.SMTPUser, m.cfg.SMTPPassword, m.cfg.SMTPHost)
+
+	// Port 465 is TLS from the first byte; other ports use STARTTLS when the server offers it
+	if m.cfg.SMTPPort == 465 {
+		return m.sendTLS(addr, auth, to, message)
+	}
+	return smtp.SendMail(addr, auth, m.cfg.FromEmail, []string{to}, message)
+}
+
+// buildMessage formats a single-part text/plain message
+func (m *SMTPMailer) buildMessage(to, subject, body string, date time.Time) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("From: %s <%s>\r\n", mime.QEncoding.Encode("utf-8", m.cfg.FromName), m.cfg.FromEmail))
+	buf.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
+	buf.WriteString(fmt.Sprintf("Date: %s\r\n", date.Format(time.RFC1123Z)))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(body))
+	qp.Close()
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+// sendTLS sends a message over an implicit TLS connection
+func (m *SMTPMailer) sendTLS(addr string, auth smtp.Auth, to string, message []byte) error {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: m.cfg.SMTPHost})
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	client, err := smtp.NewClient(conn, m.cfg.SMTPHost)
+	if err != nil {
+		return fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.Auth(auth); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if err := client.Mail(m.cfg.FromEmail); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("failed to set recipient: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to open data writer: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to close data writer: %w", err)
+	}
+
+	return client.Quit()
+}
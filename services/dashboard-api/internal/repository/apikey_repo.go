@@ -2,43 +2,114 @@ package repository
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // APIKeyRepository handles API key operations
 type APIKeyRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	pepper   []byte           // HMAC key for key_lookup hashes
+	lastUsed *lastUsedTracker // Buffered last_used_at updates, written by RunLastUsedFlush
 }
 
 // NewAPIKeyRepository creates a new API key repository
-func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
-	return &APIKeyRepository{db: db}
+// pepper keys the lookup hashes; changing it strands every stored key_lookup
+func NewAPIKeyRepository(db *sql.DB, pepper string) *APIKeyRepository {
+	return &APIKeyRepository{db: db, pepper: []byte(pepper), lastUsed: sharedLastUsed}
 }
 
-// ListAPIKeys retrieves all API keys for an organization
-func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, orgID string) ([]models.APIKey, error) {
+// apiKeyOrderBy maps each models.APIKeySortFields value to its ORDER BY clause
+// Requested sorts are only ever looked up here, never interpolated, so they cannot inject SQL
+// id breaks ties so pages do not overlap when timestamps or names are equal
+var apiKeyOrderBy = map[string]string{
+	"created_at":   "created_at DESC, id",
+	"last_used_at": "last_used_at DESC NULLS LAST, created_at DESC, id",
+	"name":         "name, created_at DESC, id",
+}
+
+// apiKeyStatusWhere maps each models.APIKeyStatusFilters value to its WHERE condition
+// Keys past expires_at keep status active until something updates them, so they count as expired
+var apiKeyStatusWhere = map[string]string{
+	"active":   "status = 'active' AND (expires_at IS NULL OR expires_at > NOW())",
+	"rotating": "status = 'rotating'",
+	"revoked":  "status = 'revoked'",
+	"expired":  "(status = 'expired' OR (status IN ('active', 'rotating') AND expires_at <= NOW()))",
+}
+
+// apiKeyListFilter returns the WHERE and ORDER BY clauses for a key list, rejecting unknown values
+func apiKeyListFilter(opts models.APIKeyListOptions) (where, orderBy string, err error) {
+	where = "organization_id = $1"
+	if opts.Status != "" {
+		cond, ok := apiKeyStatusWhere[opts.Status]
+		if !ok {
+			return "", "", fmt.Errorf("invalid status filter %q", opts.Status)
+		}
+		where += " AND " + cond
+	}
+
+	sort := opts.Sort
+	if sort == "" {
+		sort = "created_at"
+	}
+	orderBy, ok := apiKeyOrderBy[sort]
+	if !ok {
+		return "", "", fmt.Errorf("invalid sort column %q", opts.Sort)
+	}
+	return where, orderBy, nil
+}
+
+// ListAPIKeys retrieves one page of an organization's API keys
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, orgID string, opts models.APIKeyListOptions) (_ *models.APIKeyListResponse, err error) {
+	where, orderBy, err := apiKeyListFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	offset := (opts.Page - 1) * opts.PageSize
+
+	// Get total count
+	var totalCount int
+	countQuery := `SELECT COUNT(*) FROM api_keys WHERE ` + where
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, countQuery, orgID).Scan(&totalCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count API keys: %w", err)
+	}
+
 	query := `
 		SELECT id, organization_id, name, key_prefix, last_used_at,
-		       created_at, expires_at, revoked_at, status, created_by
+		       created_at, expires_at, revoked_at, status, created_by,
+		       rotation_expires_at, allowed_cidrs
 		FROM api_keys
-		WHERE organization_id = $1
-		ORDER BY created_at DESC
+		WHERE ` + where + `
+		ORDER BY ` + orderBy + `
+		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, orgID)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, orgID, opts.PageSize, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list API keys: %w", err)
 	}
 	defer rows.Close()
 
-	var keys []models.APIKey
+	now := time.Now()
+	keys := []models.APIKey{}
 	for rows.Next() {
 		var key models.APIKey
 		err := rows.Scan(
@@ -52,18 +123,89 @@ func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, orgID string) ([]mod
 			&key.RevokedAt,
 			&key.Status,
 			&key.CreatedBy,
+			&key.RotationExpiresAt,
+			pq.Array(&key.AllowedCIDRs),
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan API key: %w", err)
 		}
+		key.DaysUntilExpiry = models.DaysUntilExpiry(key.ExpiresAt, now)
 		keys = append(keys, key)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
 
-	return keys, rows.Err()
+	return &models.APIKeyListResponse{
+		APIKeys:    keys,
+		TotalCount: totalCount,
+		Page:       opts.Page,
+		PageSize:   opts.PageSize,
+	}, nil
+}
+
+// APIKeyLimitError is returned by CreateAPIKey when the organization has as many keys as its plan allows
+type APIKeyLimitError struct {
+	PlanTier string
+	Limit    int
+}
+
+func (e *APIKeyLimitError) Error() string {
+	return fmt.Sprintf("the %s plan allows at most %d active API keys", e.PlanTier, e.Limit)
+}
+
+// CreateAPIKey creates a new API key and records the creation in the audit log
+// It fails with *APIKeyLimitError when the organization is at its plan's key limit
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, orgID, name string, expiresAt *time.Time, actor models.APIKeyActor) (_ *models.APIKey, _ string, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, "", err
+	}
+	defer done(&err)
+
+	if err = r.checkAPIKeyLimit(ctx, orgID); err != nil {
+		return nil, "", err
+	}
+
+	return r.insertAPIKey(ctx, orgID, name, expiresAt, actor)
+}
+
+// checkAPIKeyLimit returns *APIKeyLimitError when the organization's active and rotating keys
+// already fill its plan's limit. It locks the organization row until the tenant transaction
+// ends, so concurrent creations are counted one after another; callers hold the tenant scope
+func (r *APIKeyRepository) checkAPIKeyLimit(ctx context.Context, orgID string) error {
+	var planTier string
+	err := dbFor(ctx, r.db).QueryRowContext(ctx,
+		`SELECT plan_tier FROM organizations WHERE id = $1 FOR UPDATE`, orgID).Scan(&planTier)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("organization not found")
+		}
+		return fmt.Errorf("failed to get organization plan: %w", err)
+	}
+
+	query := `
+		SELECT COUNT(*)
+		FROM api_keys
+		WHERE organization_id = $1
+			AND status IN ('active', 'rotating')
+			AND (expires_at IS NULL OR expires_at > NOW())
+	`
+
+	var count int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, query, orgID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count active API keys: %w", err)
+	}
+
+	if limit := models.MaxActiveAPIKeys(planTier); count >= limit {
+		return &APIKeyLimitError{PlanTier: planTier, Limit: limit}
+	}
+
+	return nil
 }
 
-// CreateAPIKey creates a new API key
-func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, orgID, name, userID string, expiresAt *time.Time) (*models.APIKey, string, error) {
+// insertAPIKey generates, stores and audits a new key; callers hold the tenant scope
+func (r *APIKeyRepository) insertAPIKey(ctx context.Context, orgID, name string, expiresAt *time.Time, actor models.APIKeyActor) (*models.APIKey, string, error) {
 	// Generate random API key
 	fullKey, err := r.generateAPIKey()
 	if err != nil {
@@ -87,8 +229,8 @@ func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, orgID, name, userID
 
 	// Insert into database
 	query := `
-		INSERT INTO api_keys (organization_id, name, key_prefix, key_hash, expires_at, status, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO api_keys (organization_id, name, key_prefix, key_hash, key_lookup, expires_at, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at
 	`
 
@@ -99,14 +241,16 @@ func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, orgID, name, userID
 		KeyHash:        string(keyHash),
 		ExpiresAt:      expiresAt,
 		Status:         status,
-		CreatedBy:      userID,
+		CreatedBy:      actor.UserID,
+		AllowedCIDRs:   []string{},
 	}
 
-	err = r.db.QueryRowContext(ctx, query,
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query,
 		apiKey.OrganizationID,
 		apiKey.Name,
 		apiKey.KeyPrefix,
 		apiKey.KeyHash,
+		r.lookupHash(fullKey),
 		apiKey.ExpiresAt,
 		apiKey.Status,
 		apiKey.CreatedBy,
@@ -116,18 +260,263 @@ func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, orgID, name, userID
 		return nil, "", fmt.Errorf("failed to insert API key: %w", err)
 	}
 
+	if err := r.recordAudit(ctx, apiKey.ID, orgID, models.APIKeyAuditCreated, actor); err != nil {
+		return nil, "", err
+	}
+
 	return apiKey, fullKey, nil
 }
 
-// RevokeAPIKey revokes an API key
-func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, keyID, orgID string) error {
+// RevokeAPIKey revokes an API key and records the revocation in the audit log
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, keyID, orgID string, actor models.APIKeyActor) (err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return err
+	}
+	defer done(&err)
+
+	return r.revokeAPIKey(ctx, keyID, orgID, models.APIKeyAuditRevoked, actor)
+}
+
+// RotateAPIKey replaces an active key with a new one of the same name and expiry
+// The old key is marked rotating and keeps working for grace, after which
+// RevokeExpiredRotations revokes it; a zero grace revokes it at once
+func (r *APIKeyRepository) RotateAPIKey(ctx context.Context, keyID, orgID string, grace time.Duration, actor models.APIKeyActor) (_ *models.APIKey, _ string, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, "", err
+	}
+	defer done(&err)
+
+	oldKey, err := r.GetAPIKey(ctx, keyID, orgID)
+	if err != nil {
+		return nil, "", err
+	}
+	if oldKey.Status != "active" {
+		return nil, "", fmt.Errorf("API key not found or already revoked")
+	}
+
+	if grace <= 0 {
+		err = r.revokeAPIKey(ctx, keyID, orgID, models.APIKeyAuditRotated, actor)
+	} else {
+		err = r.startRotation(ctx, keyID, orgID, time.Now().Add(grace), actor)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The replacement takes the old key's place, so the plan's key limit does not apply
+	newKey, fullKey, err := r.insertAPIKey(ctx, orgID, oldKey.Name, oldKey.ExpiresAt, actor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The replacement inherits the old key's IP allowlist
+	if len(oldKey.AllowedCIDRs) > 0 {
+		if err = r.setAllowedCIDRs(ctx, newKey.ID, orgID, oldKey.AllowedCIDRs); err != nil {
+			return nil, "", err
+		}
+		newKey.AllowedCIDRs = oldKey.AllowedCIDRs
+	}
+
+	return newKey, fullKey, nil
+}
+
+// UpdateAllowedCIDRs replaces the IP allowlist of an active or rotating key and audits the change
+// cidrs must already be validated; an empty list lets the key be used from any IP
+func (r *APIKeyRepository) UpdateAllowedCIDRs(ctx context.Context, keyID, orgID string, cidrs []string, actor models.APIKeyActor) (_ *models.APIKey, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	if err = r.setAllowedCIDRs(ctx, keyID, orgID, cidrs); err != nil {
+		return nil, err
+	}
+
+	if err = r.recordAudit(ctx, keyID, orgID, models.APIKeyAuditAllowlistUpdated, actor); err != nil {
+		return nil, err
+	}
+
+	return r.GetAPIKey(ctx, keyID, orgID)
+}
+
+// setAllowedCIDRs stores a key's IP allowlist; callers hold the tenant scope
+func (r *APIKeyRepository) setAllowedCIDRs(ctx context.Context, keyID, orgID string, cidrs []string) error {
+	query := `
+		UPDATE api_keys
+		SET allowed_cidrs = $1
+		WHERE id = $2 AND organization_id = $3 AND status IN ('active', 'rotating')
+	`
+
+	if cidrs == nil {
+		cidrs = []string{}
+	}
+
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, query, pq.Array(cidrs), keyID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to update allowed CIDRs: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API key not found or already revoked")
+	}
+
+	return nil
+}
+
+// startRotation marks an active key rotating until graceEnd; callers hold the tenant scope
+func (r *APIKeyRepository) startRotation(ctx context.Context, keyID, orgID string, graceEnd time.Time, actor models.APIKeyActor) error {
+	query := `
+		UPDATE api_keys
+		SET status = 'rotating', rotation_expires_at = $1
+		WHERE id = $2 AND organization_id = $3 AND status = 'active'
+	`
+
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, query, graceEnd, keyID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API key not found or already revoked")
+	}
+
+	return r.recordAudit(ctx, keyID, orgID, models.APIKeyAuditRotated, actor)
+}
+
+// RevokeExpiredRotations revokes every rotating key whose grace period has ended and
+// audits each as revoked by "system". It runs across all organizations, so it is not
+// tenant scoped; the revocation trigger then evicts the keys from the gateway cache
+func (r *APIKeyRepository) RevokeExpiredRotations(ctx context.Context) (int64, error) {
+	query := `
+		WITH revoked AS (
+			UPDATE api_keys
+			SET status = 'revoked', revoked_at = NOW()
+			WHERE status = 'rotating' AND rotation_expires_at <= NOW()
+			RETURNING id, organization_id
+		)
+		INSERT INTO api_key_audit (organization_id, key_id, action, actor_user_id, ip_address)
+		SELECT organization_id, id, 'revoked', 'system', '' FROM revoked
+	`
+
+	result, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke expired rotations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// ListExpiringAPIKeys returns active keys expiring in (from, to] that have not been reminded
+// about that expiry, soonest first. Like RevokeExpiredRotations it runs across all
+// organizations, so it is not tenant scoped
+func (r *APIKeyRepository) ListExpiringAPIKeys(ctx context.Context, from, to time.Time) ([]models.ExpiringAPIKey, error) {
+	query := `
+		SELECT k.id, k.organization_id, o.name, o.billing_email, COALESCE(k.name, ''), k.key_prefix, k.expires_at
+		FROM api_keys k
+		JOIN organizations o ON o.id = k.organization_id
+		WHERE k.status = 'active'
+			AND k.expires_at > $1 AND k.expires_at <= $2
+			AND NOT EXISTS (
+				SELECT 1 FROM api_key_expiry_reminders rem
+				WHERE rem.key_id = k.id AND rem.expires_at = k.expires_at
+			)
+		ORDER BY k.expires_at, k.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.ExpiringAPIKey
+	for rows.Next() {
+		var key models.ExpiringAPIKey
+		if err := rows.Scan(&key.ID, &key.OrganizationID, &key.OrganizationName, &key.BillingEmail,
+			&key.Name, &key.KeyPrefix, &key.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan expiring API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// ClaimExpiryReminder records a reminder for a key's expiry, returning false if one was already
+// sent; the primary key deduplicates across dashboard replicas
+func (r *APIKeyRepository) ClaimExpiryReminder(ctx context.Context, keyID string, expiresAt time.Time, sentTo string) (bool, error) {
+	query := `
+		INSERT INTO api_key_expiry_reminders (key_id, expires_at, sent_to)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key_id, expires_at) DO NOTHING
+	`
+
+	result, err := r.db.ExecContext(ctx, query, keyID, expiresAt, sentTo)
+	if err != nil {
+		return false, fmt.Errorf("failed to record expiry reminder: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// ReleaseExpiryReminder removes a claim after the reminder failed to send, so the next run retries
+func (r *APIKeyRepository) ReleaseExpiryReminder(ctx context.Context, keyID string, expiresAt time.Time) error {
+	query := `DELETE FROM api_key_expiry_reminders WHERE key_id = $1 AND expires_at = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, keyID, expiresAt); err != nil {
+		return fmt.Errorf("failed to delete expiry reminder: %w", err)
+	}
+
+	return nil
+}
+
+// RunRotationCleanup calls RevokeExpiredRotations every interval until ctx is cancelled
+func (r *APIKeyRepository) RunRotationCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			revoked, err := r.RevokeExpiredRotations(ctx)
+			if err != nil {
+				log.Printf("API key rotation cleanup failed: %v", err)
+			} else if revoked > 0 {
+				log.Printf("API key rotation cleanup revoked %d keys", revoked)
+			}
+		}
+	}
+}
+
+// revokeAPIKey marks a key revoked and audits it under action; callers hold the tenant scope
+func (r *APIKeyRepository) revokeAPIKey(ctx context.Context, keyID, orgID, action string, actor models.APIKeyActor) error {
 	query := `
 		UPDATE api_keys
 		SET status = 'revoked', revoked_at = $1
-		WHERE id = $2 AND organization_id = $3
+		WHERE id = $2 AND organization_id = $3 AND status <> 'revoked'
 	`
 
-	result, err := r.db.ExecContext(ctx, query, time.Now(), keyID, orgID)
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, query, time.Now(), keyID, orgID)
 	if err != nil {
 		return fmt.Errorf("failed to revoke API key: %w", err)
 	}
@@ -141,20 +530,137 @@ func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, keyID, orgID string
 		return fmt.Errorf("API key not found or already revoked")
 	}
 
+	return r.recordAudit(ctx, keyID, orgID, action, actor)
+}
+
+// recordAudit appends a row to api_key_audit inside the caller's tenant transaction,
+// so an audit entry exists exactly when the change it describes was committed
+func (r *APIKeyRepository) recordAudit(ctx context.Context, keyID, orgID, action string, actor models.APIKeyActor) error {
+	query := `
+		INSERT INTO api_key_audit (organization_id, key_id, action, actor_user_id, ip_address)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := dbFor(ctx, r.db).ExecContext(ctx, query, orgID, keyID, action, actor.UserID, actor.IPAddress)
+	if err != nil {
+		return fmt.Errorf("failed to record API key audit: %w", err)
+	}
+
 	return nil
 }
 
+// ListAPIKeyAudit returns a key's audit history, oldest first
+func (r *APIKeyRepository) ListAPIKeyAudit(ctx context.Context, keyID, orgID string) (_ []models.APIKeyAuditEntry, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	query := `
+		SELECT id, key_id, action, actor_user_id, ip_address, created_at
+		FROM api_key_audit
+		WHERE key_id = $1 AND organization_id = $2
+		ORDER BY created_at, id
+	`
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, keyID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API key audit: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.APIKeyAuditEntry{}
+	for rows.Next() {
+		var entry models.APIKeyAuditEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.KeyID,
+			&entry.Action,
+			&entry.ActorUserID,
+			&entry.IPAddress,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetAPIKeyUsage aggregates the usage events recorded for one key between two dates (inclusive)
+// The key must belong to orgID; keys of other organizations are reported as not found
+func (r *APIKeyRepository) GetAPIKeyUsage(ctx context.Context, keyID, orgID string, startDate, endDate time.Time) (_ *models.APIKeyUsageResponse, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	// The LEFT JOIN keeps a row of zeros for a key with no traffic in the range
+	query := `
+		SELECT
+			ak.id,
+			COUNT(ue.time) as total_requests,
+			COUNT(ue.time) FILTER (WHERE ue.billable = true) as billable_requests,
+			COALESCE(SUM(ue.weight) FILTER (WHERE ue.billable = true), 0) as billable_units,
+			COUNT(ue.time) FILTER (WHERE ue.status_code >= 500) as error_count,
+			COUNT(ue.time) FILTER (WHERE ue.status_code >= 400 AND ue.status_code < 500) as client_error_count
+		FROM api_keys ak
+		LEFT JOIN usage_events ue ON ue.api_key_id = ak.id
+			AND ue.organization_id = ak.organization_id
+			AND ue.time >= $3
+			AND ue.time < $4
+		WHERE ak.id = $1 AND ak.organization_id = $2
+		GROUP BY ak.id
+	`
+
+	usage := models.APIKeyUsageResponse{
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+	}
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query, keyID, orgID, startDate, endDate.AddDate(0, 0, 1)).Scan(
+		&usage.KeyID,
+		&usage.TotalRequests,
+		&usage.BillableRequests,
+		&usage.BillableUnits,
+		&usage.ErrorCount,
+		&usage.ClientErrorCount,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API key not found")
+		}
+		return nil, fmt.Errorf("failed to query API key usage: %w", err)
+	}
+
+	if usage.TotalRequests > 0 {
+		usage.ErrorRate = float64(usage.ErrorCount+usage.ClientErrorCount) / float64(usage.TotalRequests)
+	}
+
+	return &usage, nil
+}
+
 // GetAPIKey retrieves a single API key by ID
-func (r *APIKeyRepository) GetAPIKey(ctx context.Context, keyID, orgID string) (*models.APIKey, error) {
+func (r *APIKeyRepository) GetAPIKey(ctx context.Context, keyID, orgID string) (_ *models.APIKey, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
 	query := `
 		SELECT id, organization_id, name, key_prefix, last_used_at,
-		       created_at, expires_at, revoked_at, status, created_by
+		       created_at, expires_at, revoked_at, status, created_by,
+		       rotation_expires_at, allowed_cidrs
 		FROM api_keys
 		WHERE id = $1 AND organization_id = $2
 	`
 
 	var key models.APIKey
-	err := r.db.QueryRowContext(ctx, query, keyID, orgID).Scan(
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query, keyID, orgID).Scan(
 		&key.ID,
 		&key.OrganizationID,
 		&key.Name,
@@ -165,6 +671,8 @@ func (r *APIKeyRepository) GetAPIKey(ctx context.Context, keyID, orgID string) (
 		&key.RevokedAt,
 		&key.Status,
 		&key.CreatedBy,
+		&key.RotationExpiresAt,
+		pq.Array(&key.AllowedCIDRs),
 	)
 
 	if err != nil {
@@ -173,21 +681,55 @@ func (r *APIKeyRepository) GetAPIKey(ctx context.Context, keyID, orgID string) (
 		}
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
+	key.DaysUntilExpiry = models.DaysUntilExpiry(key.ExpiresAt, time.Now())
 
 	return &key, nil
 }
 
 // ValidateAPIKey validates an API key and returns the organization ID
+// The key is found by its indexed HMAC lookup hash, and bcrypt confirms the match
 func (r *APIKeyRepository) ValidateAPIKey(ctx context.Context, fullKey string) (string, error) {
+	lookup := r.lookupHash(fullKey)
+
+	query := `
+		SELECT id, organization_id, key_hash, status, expires_at, rotation_expires_at
+		FROM api_keys
+		WHERE key_lookup = $1
+	`
+
+	var id, orgID, keyHash, status string
+	var expiresAt, rotationExpiresAt *time.Time
+	err := dbFor(ctx, r.db).QueryRowContext(ctx, query, lookup).Scan(&id, &orgID, &keyHash, &status, &expiresAt, &rotationExpiresAt)
+	if err == sql.ErrNoRows {
+		// Keys created before the lookup hash existed are found by prefix once, then migrated
+		return r.validateLegacyAPIKey(ctx, fullKey, lookup)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query API key: %w", err)
+	}
+
+	if !apiKeyUsable(status, expiresAt, rotationExpiresAt, time.Now()) ||
+		bcrypt.CompareHashAndPassword([]byte(keyHash), []byte(fullKey)) != nil {
+		return "", fmt.Errorf("invalid API key")
+	}
+
+	// Key is valid - last_used_at is written by the next flush
+	r.lastUsed.touch(id, time.Now())
+	return orgID, nil
+}
+
+// validateLegacyAPIKey bcrypt-compares every unmigrated key sharing fullKey's prefix
+// and stores the lookup hash of the one that matches, so it takes the fast path next time
+func (r *APIKeyRepository) validateLegacyAPIKey(ctx context.Context, fullKey, lookup string) (string, error) {
 	keyPrefix := fullKey[:8]
 
 	query := `
-		SELECT id, organization_id, key_hash, status, expires_at
+		SELECT id, organization_id, key_hash, status, expires_at, rotation_expires_at
 		FROM api_keys
-		WHERE key_prefix = $1
+		WHERE key_prefix = $1 AND key_lookup IS NULL
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, keyPrefix)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, keyPrefix)
 	if err != nil {
 		return "", fmt.Errorf("failed to query API keys: %w", err)
 	}
@@ -196,28 +738,26 @@ func (r *APIKeyRepository) ValidateAPIKey(ctx context.Context, fullKey string) (
 	// Check each key with matching prefix
 	for rows.Next() {
 		var id, orgID, keyHash, status string
-		var expiresAt *time.Time
+		var expiresAt, rotationExpiresAt *time.Time
 
-		err := rows.Scan(&id, &orgID, &keyHash, &status, &expiresAt)
+		err := rows.Scan(&id, &orgID, &keyHash, &status, &expiresAt, &rotationExpiresAt)
 		if err != nil {
 			continue
 		}
 
-		// Check if key is active
-		if status != "active" {
-			continue
-		}
-
-		// Check expiration
-		if expiresAt != nil && expiresAt.Before(time.Now()) {
+		if !apiKeyUsable(status, expiresAt, rotationExpiresAt, time.Now()) {
 			continue
 		}
 
 		// Verify key hash
 		err = bcrypt.CompareHashAndPassword([]byte(keyHash), []byte(fullKey))
 		if err == nil {
-			// Key is valid - update last_used_at
-			go r.updateLastUsed(id)
+			rows.Close()
+			if _, err := dbFor(ctx, r.db).ExecContext(ctx,
+				`UPDATE api_keys SET key_lookup = $1 WHERE id = $2 AND key_lookup IS NULL`, lookup, id); err != nil {
+				log.Printf("Failed to store lookup hash for API key %s: %v", id, err)
+			}
+			r.lastUsed.touch(id, time.Now())
 			return orgID, nil
 		}
 	}
@@ -225,10 +765,28 @@ func (r *APIKeyRepository) ValidateAPIKey(ctx context.Context, fullKey string) (
 	return "", fmt.Errorf("invalid API key")
 }
 
-// updateLastUsed updates the last_used_at timestamp for an API key
-func (r *APIKeyRepository) updateLastUsed(keyID string) {
-	query := `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`
-	r.db.Exec(query, time.Now(), keyID)
+// lookupHash returns the hex HMAC-SHA256 of fullKey under the server pepper
+// Unlike bcrypt it is deterministic, so it can be indexed and matched exactly
+func (r *APIKeyRepository) lookupHash(fullKey string) string {
+	mac := hmac.New(sha256.New, r.pepper)
+	mac.Write([]byte(fullKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// apiKeyUsable reports whether a key may authenticate at now: active keys until they
+// expire, and rotating keys until the earlier of their expiry and grace period end
+func apiKeyUsable(status string, expiresAt, rotationExpiresAt *time.Time, now time.Time) bool {
+	switch status {
+	case "active":
+	case "rotating":
+		if rotationExpiresAt == nil || !now.Before(*rotationExpiresAt) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return expiresAt == nil || now.Before(*expiresAt)
 }
 
 // generateAPIKey generates a cryptographically secure random API key
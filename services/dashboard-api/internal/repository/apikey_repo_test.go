@@ -0,0 +1,530 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestAPIKeyUsable tests which keys may authenticate, including the rotation overlap window
+func TestAPIKeyUsable(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name              string
+		status            string
+		expiresAt         *time.Time
+		rotationExpiresAt *time.Time
+		expected          bool
+	}{
+		{"Active key", "active", nil, nil, true},
+		{"Active key before expiry", "active", &future, nil, true},
+		{"Active key past expiry", "active", &past, nil, false},
+		{"Rotating key inside grace period", "rotating", nil, &future, true},
+		{"Rotating key at grace end", "rotating", nil, &now, false},
+		{"Rotating key past grace period", "rotating", nil, &past, false},
+		{"Rotating key expires before grace ends", "rotating", &past, &future, false},
+		{"Rotating key without grace end", "rotating", nil, nil, false},
+		{"Revoked key", "revoked", nil, nil, false},
+		{"Expired key", "expired", nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiKeyUsable(tt.status, tt.expiresAt, tt.rotationExpiresAt, now); got != tt.expected {
+				t.Errorf("apiKeyUsable() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestAPIKeyListFilter tests that sorts and status filters only come from the whitelists
+func TestAPIKeyListFilter(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          models.APIKeyListOptions
+		expectedWhere string
+		expectedOrder string
+		wantErr       bool
+	}{
+		{"Defaults", models.APIKeyListOptions{}, "organization_id = $1", "created_at DESC, id", false},
+		{"Name sort", models.APIKeyListOptions{Sort: "name"}, "organization_id = $1", "name, created_at DESC, id", false},
+		{"Last used sort", models.APIKeyListOptions{Sort: "last_used_at"}, "organization_id = $1", "last_used_at DESC NULLS LAST, created_at DESC, id", false},
+		{"Revoked filter", models.APIKeyListOptions{Status: "revoked"}, "organization_id = $1 AND status = 'revoked'", "created_at DESC, id", false},
+		{"Unknown sort column", models.APIKeyListOptions{Sort: "key_hash"}, "", "", true},
+		{"Injection attempt", models.APIKeyListOptions{Sort: "created_at; DROP TABLE api_keys"}, "", "", true},
+		{"Unknown status", models.APIKeyListOptions{Status: "1=1 OR status"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, orderBy, err := apiKeyListFilter(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apiKeyListFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if where != tt.expectedWhere || orderBy != tt.expectedOrder {
+				t.Errorf("apiKeyListFilter() = %q, %q, want %q, %q", where, orderBy, tt.expectedWhere, tt.expectedOrder)
+			}
+		})
+	}
+
+	// Every value the handler accepts has a clause
+	for _, sort := range models.APIKeySortFields {
+		if _, _, err := apiKeyListFilter(models.APIKeyListOptions{Sort: sort}); err != nil {
+			t.Errorf("Sort %q error = %v", sort, err)
+		}
+	}
+	for _, status := range models.APIKeyStatusFilters {
+		if _, _, err := apiKeyListFilter(models.APIKeyListOptions{Status: status}); err != nil {
+			t.Errorf("Status %q error = %v", status, err)
+		}
+	}
+}
+
+// TestGetAPIKeyUsage_Postgres tests the per-key aggregation against a real database
+// Temporary tables shadow api_keys and usage_events, so no migrated schema or data is touched
+func TestGetAPIKeyUsage_Postgres(t *testing.T) {
+	url := os.Getenv("DASHBOARD_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("DASHBOARD_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // Temporary tables only exist on the connection that created them
+	ctx := context.Background()
+
+	const (
+		keyA     = "00000000-0000-0000-0000-00000000000a"
+		keyB     = "00000000-0000-0000-0000-00000000000b"
+		keyOther = "00000000-0000-0000-0000-00000000000c"
+	)
+	setup := []string{
+		`CREATE TEMP TABLE api_keys (id UUID PRIMARY KEY, organization_id TEXT NOT NULL)`,
+		`CREATE TEMP TABLE usage_events (
+			time TIMESTAMPTZ NOT NULL, organization_id TEXT NOT NULL, api_key_id UUID,
+			status_code INT NOT NULL, billable BOOLEAN NOT NULL, weight INT NOT NULL)`,
+		`INSERT INTO api_keys VALUES ('` + keyA + `', 'org_1'), ('` + keyB + `', 'org_1'), ('` + keyOther + `', 'org_2')`,
+		`INSERT INTO usage_events VALUES
+			('2026-03-01 00:00:00+00', 'org_1', '` + keyA + `', 200, true, 1),
+			('2026-03-15 12:00:00+00', 'org_1', '` + keyA + `', 200, true, 5),
+			('2026-03-31 23:59:59+00', 'org_1', '` + keyA + `', 404, false, 1),
+			('2026-03-20 08:00:00+00', 'org_1', '` + keyA + `', 503, true, 1),
+			('2026-04-01 00:00:00+00', 'org_1', '` + keyA + `', 200, true, 1),
+			('2026-02-28 23:59:59+00', 'org_1', '` + keyA + `', 200, true, 1),
+			('2026-03-10 00:00:00+00', 'org_1', '` + keyB + `', 200, true, 1),
+			('2026-03-10 00:00:00+00', 'org_2', '` + keyOther + `', 500, true, 1)`,
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("Setup %q error = %v", stmt, err)
+		}
+	}
+
+	repo := NewAPIKeyRepository(db, "test-pepper")
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	usage, err := repo.GetAPIKeyUsage(ctx, keyA, "org_1", start, end)
+	if err != nil {
+		t.Fatalf("GetAPIKeyUsage() error = %v", err)
+	}
+	// Events on Feb 28 and Apr 1 fall outside the range; key B's event belongs to another key
+	if usage.TotalRequests != 4 || usage.BillableRequests != 3 || usage.BillableUnits != 7 {
+		t.Errorf("Requests = %d, billable = %d, units = %d, want 4, 3, 7",
+			usage.TotalRequests, usage.BillableRequests, usage.BillableUnits)
+	}
+	if usage.ErrorCount != 1 || usage.ClientErrorCount != 1 || usage.ErrorRate != 0.5 {
+		t.Errorf("Errors = %d, client errors = %d, rate = %v, want 1, 1, 0.5",
+			usage.ErrorCount, usage.ClientErrorCount, usage.ErrorRate)
+	}
+
+	// A key with no traffic in the range reports zeros rather than not found
+	usage, err = repo.GetAPIKeyUsage(ctx, keyB, "org_1", end, end)
+	if err != nil || usage.TotalRequests != 0 || usage.ErrorRate != 0 {
+		t.Errorf("Idle key usage = %+v, %v, want zero totals", usage, err)
+	}
+
+	// Another organization's key is not visible
+	if _, err := repo.GetAPIKeyUsage(ctx, keyOther, "org_1", start, end); err == nil || err.Error() != "API key not found" {
+		t.Errorf("Other org's key error = %v, want API key not found", err)
+	}
+}
+
+// TestListExpiringAPIKeys_Postgres tests the reminder window against a real database
+// Temporary tables shadow the real ones, so no migrated schema or data is touched
+func TestListExpiringAPIKeys_Postgres(t *testing.T) {
+	url := os.Getenv("DASHBOARD_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("DASHBOARD_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // Temporary tables only exist on the connection that created them
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	setup := []string{
+		`CREATE TEMP TABLE organizations (id TEXT PRIMARY KEY, name TEXT NOT NULL, billing_email TEXT NOT NULL)`,
+		`CREATE TEMP TABLE api_keys (
+			id TEXT PRIMARY KEY, organization_id TEXT NOT NULL, name TEXT, key_prefix TEXT NOT NULL,
+			status TEXT NOT NULL, expires_at TIMESTAMPTZ)`,
+		`CREATE TEMP TABLE api_key_expiry_reminders (
+			key_id TEXT NOT NULL, expires_at TIMESTAMPTZ NOT NULL, sent_to TEXT NOT NULL,
+			sent_at TIMESTAMPTZ DEFAULT NOW(), PRIMARY KEY (key_id, expires_at))`,
+		`INSERT INTO organizations VALUES ('org_1', 'Acme', 'billing@acme.test')`,
+		`INSERT INTO api_keys VALUES
+			('in_window',    'org_1', 'CI',   'sk_aaaaa', 'active',   '2026-03-03 12:00:00+00'),
+			('window_end',   'org_1', NULL,   'sk_bbbbb', 'active',   '2026-03-08 12:00:00+00'),
+			('after_window', 'org_1', 'Late', 'sk_ccccc', 'active',   '2026-03-08 12:00:01+00'),
+			('already_gone', 'org_1', 'Old',  'sk_ddddd', 'active',   '2026-03-01 12:00:00+00'),
+			('revoked',      'org_1', 'Rev',  'sk_eeeee', 'revoked',  '2026-03-02 12:00:00+00'),
+			('rotating',     'org_1', 'Rot',  'sk_fffff', 'rotating', '2026-03-02 12:00:00+00'),
+			('no_expiry',    'org_1', 'Prod', 'sk_ggggg', 'active',   NULL),
+			('reminded',     'org_1', 'Done', 'sk_hhhhh', 'active',   '2026-03-04 12:00:00+00'),
+			('extended',     'org_1', 'Ext',  'sk_iiiii', 'active',   '2026-03-05 12:00:00+00')`,
+		`INSERT INTO api_key_expiry_reminders (key_id, expires_at, sent_to) VALUES
+			('reminded', '2026-03-04 12:00:00+00', 'billing@acme.test'),
+			('extended', '2026-03-02 12:00:00+00', 'billing@acme.test')`,
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("setup %q: %v", stmt, err)
+		}
+	}
+
+	repo := NewAPIKeyRepository(db, "test-pepper")
+	keys, err := repo.ListExpiringAPIKeys(ctx, now, now.Add(7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListExpiringAPIKeys() error = %v", err)
+	}
+
+	// A reminder for an earlier expiry does not cover a key whose expiry moved
+	expected := []string{"in_window", "extended", "window_end"}
+	if len(keys) != len(expected) {
+		t.Fatalf("Keys = %+v, want %v", keys, expected)
+	}
+	for i, id := range expected {
+		if keys[i].ID != id {
+			t.Errorf("Keys[%d] = %s, want %s", i, keys[i].ID, id)
+		}
+	}
+	if keys[0].BillingEmail != "billing@acme.test" || keys[0].OrganizationName != "Acme" {
+		t.Errorf("Contact = %s <%s>, want Acme <billing@acme.test>", keys[0].OrganizationName, keys[0].BillingEmail)
+	}
+
+	// Claims deduplicate
+	claimed, err := repo.ClaimExpiryReminder(ctx, "in_window", keys[0].ExpiresAt, "billing@acme.test")
+	if err != nil || !claimed {
+		t.Fatalf("First ClaimExpiryReminder() = %v, %v, want true", claimed, err)
+	}
+	if claimed, err := repo.ClaimExpiryReminder(ctx, "in_window", keys[0].ExpiresAt, "billing@acme.test"); err != nil || claimed {
+		t.Errorf("Second ClaimExpiryReminder() = %v, %v, want false", claimed, err)
+	}
+	if err := repo.ReleaseExpiryReminder(ctx, "in_window", keys[0].ExpiresAt); err != nil {
+		t.Errorf("ReleaseExpiryReminder() error = %v", err)
+	}
+}
+
+// TestCreateAPIKey_ConcurrentLimit_Postgres tests that concurrent creations cannot exceed the plan limit
+// Temporary tables are per connection, so the tables live in a throwaway schema that every pooled
+// connection reaches through search_path
+func TestCreateAPIKey_ConcurrentLimit_Postgres(t *testing.T) {
+	url := os.Getenv("DASHBOARD_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("DASHBOARD_TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("apikey_limit_test_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("CREATE SCHEMA error = %v", err)
+	}
+	defer admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+
+	sep := " "
+	if strings.Contains(url, "://") {
+		sep = "?"
+		if strings.Contains(url, "?") {
+			sep = "&"
+		}
+	}
+	db, err := sql.Open("postgres", url+sep+"search_path="+schema)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	setup := []string{
+		`CREATE TABLE organizations (id TEXT PRIMARY KEY, plan_tier TEXT NOT NULL)`,
+		`CREATE TABLE api_keys (
+			id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text, organization_id TEXT NOT NULL, name TEXT,
+			key_prefix TEXT NOT NULL, key_hash TEXT NOT NULL, key_lookup TEXT, expires_at TIMESTAMPTZ,
+			status TEXT NOT NULL, created_by TEXT, created_at TIMESTAMPTZ NOT NULL DEFAULT NOW())`,
+		`CREATE TABLE api_key_audit (
+			organization_id TEXT NOT NULL, key_id TEXT NOT NULL, action TEXT NOT NULL,
+			actor_user_id TEXT, ip_address TEXT)`,
+		`INSERT INTO organizations VALUES ('org_1', 'basic')`,
+		`INSERT INTO api_keys (organization_id, key_prefix, key_hash, status, expires_at) VALUES
+			('org_1', 'sk_aaaaa', 'x', 'active',   NULL),
+			('org_1', 'sk_bbbbb', 'x', 'active',   NULL),
+			('org_1', 'sk_ccccc', 'x', 'active',   NULL),
+			('org_1', 'sk_ddddd', 'x', 'rotating', NULL),
+			('org_1', 'sk_eeeee', 'x', 'revoked',  NULL),
+			('org_1', 'sk_fffff', 'x', 'active',   NOW() - INTERVAL '1 day')`,
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("setup %q: %v", stmt, err)
+		}
+	}
+
+	// Three active keys and one rotating key leave one of the basic plan's slots
+	limit := models.MaxActiveAPIKeys("basic")
+	repo := NewAPIKeyRepository(db, "test-pepper")
+	const attempts = 8
+	var wg sync.WaitGroup
+	var created, limited atomic.Int32
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := repo.CreateAPIKey(ctx, "org_1", fmt.Sprintf("key %d", i), nil, models.APIKeyActor{UserID: "user-1"})
+			var limitErr *APIKeyLimitError
+			switch {
+			case err == nil:
+				created.Add(1)
+			case errors.As(err, &limitErr):
+				limited.Add(1)
+			default:
+				t.Errorf("CreateAPIKey() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if created.Load() != 1 || limited.Load() != attempts-1 {
+		t.Errorf("Created %d and limited %d, want 1 and %d", created.Load(), limited.Load(), attempts-1)
+	}
+	var counted int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys WHERE status IN ('active', 'rotating')
+		AND (expires_at IS NULL OR expires_at > NOW())`).Scan(&counted)
+	if err != nil {
+		t.Fatalf("Count error = %v", err)
+	}
+	if counted != limit {
+		t.Errorf("Counted keys = %d, want the limit %d", counted, limit)
+	}
+}
+
+// validateFakeKey is a stored API key; an empty lookup marks a key created before key_lookup
+type validateFakeKey struct {
+	id, fullKey, keyHash, lookup string
+}
+
+// validateFakeDriver answers ValidateAPIKey's queries from a slice of keys in one org,
+// applies key_lookup migrations when migrate is set, and counts last_used_at writes
+type validateFakeDriver struct {
+	mu             sync.Mutex
+	keys           []*validateFakeKey
+	migrate        bool
+	lastUsedWrites atomic.Int32
+	lastUsedIDs    atomic.Int32 // Key IDs in the most recent write
+}
+
+type validateFakeConn struct{ driver *validateFakeDriver }
+
+func (d *validateFakeDriver) Open(string) (driver.Conn, error) {
+	return &validateFakeConn{driver: d}, nil
+}
+
+func (c *validateFakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *validateFakeConn) Close() error              { return nil }
+func (c *validateFakeConn) Begin() (driver.Tx, error) { return nil, errors.New("begin not supported") }
+
+func (c *validateFakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	d := c.driver
+	switch {
+	case strings.Contains(query, "SET key_lookup"):
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, key := range d.keys {
+			if d.migrate && key.id == args[1].Value.(string) && key.lookup == "" {
+				key.lookup = args[0].Value.(string)
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+
+	case strings.Contains(query, "SET last_used_at"):
+		d.lastUsedWrites.Add(1)
+		ids := args[0].Value.(string) // pq.Array encodes as {id1,id2}
+		d.lastUsedIDs.Store(int32(strings.Count(ids, ",") + 1))
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unexpected exec: " + query)
+}
+
+func (c *validateFakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	d := c.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rows := &validateFakeRows{}
+	var match func(key *validateFakeKey) bool
+	switch {
+	case strings.Contains(query, "WHERE key_lookup = $1"):
+		match = func(key *validateFakeKey) bool { return key.lookup == args[0].Value.(string) }
+	case strings.Contains(query, "WHERE key_prefix = $1 AND key_lookup IS NULL"):
+		match = func(key *validateFakeKey) bool {
+			return key.lookup == "" && strings.HasPrefix(key.fullKey, args[0].Value.(string))
+		}
+	default:
+		return nil, errors.New("unexpected query: " + query)
+	}
+	for _, key := range d.keys {
+		if match(key) {
+			rows.values = append(rows.values, []driver.Value{key.id, "org_1", key.keyHash, "active", nil, nil})
+		}
+	}
+	return rows, nil
+}
+
+type validateFakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *validateFakeRows) Columns() []string {
+	return []string{"id", "organization_id", "key_hash", "status", "expires_at", "rotation_expires_at"}
+}
+func (r *validateFakeRows) Close() error { return nil }
+func (r *validateFakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var validateFakeCount atomic.Int32
+
+// openValidateFakeDB stores n keys sharing one prefix, hashed at cost; migrated keys
+// already have their key_lookup set
+func openValidateFakeDB(tb testing.TB, cost, n int, migrated bool) (*APIKeyRepository, *validateFakeDriver) {
+	tb.Helper()
+	d := &validateFakeDriver{migrate: true}
+	name := fmt.Sprintf("validatefake%d", validateFakeCount.Add(1))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		tb.Fatalf("sql.Open() error = %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	repo := &APIKeyRepository{db: db, pepper: []byte("test-pepper"), lastUsed: newLastUsedTracker()}
+	for i := 0; i < n; i++ {
+		fullKey := fmt.Sprintf("sk_abcde%056x", i) // Same 8-character prefix for every key
+		hash, err := bcrypt.GenerateFromPassword([]byte(fullKey), cost)
+		if err != nil {
+			tb.Fatalf("bcrypt error = %v", err)
+		}
+		key := &validateFakeKey{id: fmt.Sprintf("key-%d", i), fullKey: fullKey, keyHash: string(hash)}
+		if migrated {
+			key.lookup = repo.lookupHash(fullKey)
+		}
+		d.keys = append(d.keys, key)
+	}
+	return repo, d
+}
+
+// TestValidateAPIKey_MigratesLegacyKey tests that a key without a lookup hash is found by
+// prefix once, gets its lookup hash stored, and is found by the index from then on
+func TestValidateAPIKey_MigratesLegacyKey(t *testing.T) {
+	repo, d := openValidateFakeDB(t, bcrypt.MinCost, 3, false)
+	ctx := context.Background()
+	legacy := d.keys[2]
+
+	if orgID, err := repo.ValidateAPIKey(ctx, legacy.fullKey); err != nil || orgID != "org_1" {
+		t.Fatalf("ValidateAPIKey() = %q, %v, want org_1", orgID, err)
+	}
+	if legacy.lookup != repo.lookupHash(legacy.fullKey) {
+		t.Fatalf("Lookup hash after first validation = %q, want it stored", legacy.lookup)
+	}
+	if d.keys[0].lookup != "" || d.keys[1].lookup != "" {
+		t.Error("Keys that did not match were migrated")
+	}
+
+	// The fast path alone must now accept the key
+	d.mu.Lock()
+	d.keys = []*validateFakeKey{legacy}
+	d.mu.Unlock()
+	if _, err := repo.ValidateAPIKey(ctx, legacy.fullKey); err != nil {
+		t.Errorf("ValidateAPIKey() after migration error = %v", err)
+	}
+}
+
+func TestValidateAPIKey_RejectsUnknownKey(t *testing.T) {
+	repo, _ := openValidateFakeDB(t, bcrypt.MinCost, 2, true)
+
+	if _, err := repo.ValidateAPIKey(context.Background(), "sk_abcde"+strings.Repeat("f", 56)); err == nil {
+		t.Error("Expected error for a key that is not stored")
+	}
+}
+
+// BenchmarkValidateAPIKey compares the prefix scan (every colliding key bcrypt-compared)
+// with the indexed lookup (one bcrypt confirmation) when 10 keys share a prefix
+func BenchmarkValidateAPIKey(b *testing.B) {
+	const colliding = 10
+	ctx := context.Background()
+
+	b.Run("prefix_scan", func(b *testing.B) {
+		repo, d := openValidateFakeDB(b, bcrypt.DefaultCost, colliding, false)
+		d.migrate = false // Keep every key on the legacy path
+		// Worst case: the match is compared last
+		target := d.keys[colliding-1].fullKey
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.ValidateAPIKey(ctx, target); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("indexed_lookup", func(b *testing.B) {
+		repo, d := openValidateFakeDB(b, bcrypt.DefaultCost, colliding, true)
+		target := d.keys[colliding-1].fullKey
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.ValidateAPIKey(ctx, target); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
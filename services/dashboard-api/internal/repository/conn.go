@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// querier is the query surface shared by *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type tenantTxContextKey struct{}
+
+// tenantTx is the transaction WithTenantConn runs repository queries on
+type tenantTx struct {
+	tx    *sql.Tx
+	orgID string
+}
+
+// WithTenantConn runs fn in a transaction on which app.current_org is orgID
+// The setting is transaction-local (SET LOCAL semantics), so Row-Level Security policies
+// see the tenant on exactly the connection fn's queries use, and it cannot leak to other
+// requests through the pool. dbFor(ctx, db) inside fn returns the transaction
+func WithTenantConn(ctx context.Context, db *sql.DB, orgID string, fn func(ctx context.Context) error) (err error) {
+	ctx, done, err := tenantScope(ctx, db, orgID)
+	if err != nil {
+		return err
+	}
+	defer done(&err)
+
+	return fn(ctx)
+}
+
+// tenantScope starts the tenant transaction for a repository method, or joins the
+// caller's when one for the same organization is already open. Use with a named error:
+//
+//	ctx, done, err := tenantScope(ctx, r.db, orgID)
+//	if err != nil { ... }
+//	defer done(&err)
+//
+// done commits when *err is nil and rolls back on an error or panic
+func tenantScope(ctx context.Context, db *sql.DB, orgID string) (context.Context, func(*error), error) {
+	if current, ok := ctx.Value(tenantTxContextKey{}).(*tenantTx); ok {
+		if current.orgID != orgID {
+			return nil, nil, fmt.Errorf("tenant transaction for %s cannot be used for %s", current.orgID, orgID)
+		}
+		return ctx, func(*error) {}, nil
+	}
+
+	tx, err := beginTenantTx(ctx, db, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done := func(errp *error) {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if *errp != nil {
+			tx.Rollback()
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			*errp = fmt.Errorf("failed to commit tenant transaction: %w", err)
+		}
+	}
+	return context.WithValue(ctx, tenantTxContextKey{}, &tenantTx{tx: tx, orgID: orgID}), done, nil
+}
+
+// beginTenantTx starts a transaction with app.current_org set for its duration
+// SET LOCAL cannot take bind parameters, so set_config(..., true) is used instead
+func beginTenantTx(ctx context.Context, db *sql.DB, orgID string) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.current_org', $1, true)", orgID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to set tenant: %w", err)
+	}
+	return tx, nil
+}
+
+// dbFor returns where a repository query runs: the tenant transaction inside
+// WithTenantConn, else the pool
+func dbFor(ctx context.Context, db *sql.DB) querier {
+	if current, ok := ctx.Value(tenantTxContextKey{}).(*tenantTx); ok {
+		return current.tx
+	}
+	return db
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// tenantFakeDriver models the Postgres semantics RLS relies on: set_config(..., true)
+// only lasts until the transaction ends, and outside a transaction it has no effect
+type tenantFakeDriver struct {
+	commits atomic.Int32
+	rolls   atomic.Int32
+}
+
+type tenantFakeConn struct {
+	driver  *tenantFakeDriver
+	inTx    bool
+	txLocal string
+}
+
+func (d *tenantFakeDriver) Open(string) (driver.Conn, error) {
+	return &tenantFakeConn{driver: d}, nil
+}
+
+func (c *tenantFakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *tenantFakeConn) Close() error              { return nil }
+func (c *tenantFakeConn) Begin() (driver.Tx, error) { c.inTx = true; return c, nil }
+
+func (c *tenantFakeConn) Commit() error {
+	c.driver.commits.Add(1)
+	c.inTx, c.txLocal = false, ""
+	return nil
+}
+
+func (c *tenantFakeConn) Rollback() error {
+	c.driver.rolls.Add(1)
+	c.inTx, c.txLocal = false, ""
+	return nil
+}
+
+func (c *tenantFakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "set_config('app.current_org', $1, true)") && c.inTx {
+		c.txLocal = args[0].Value.(string)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *tenantFakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "current_setting('app.current_org', true)") {
+		return &tenantFakeRows{values: []driver.Value{c.txLocal}}, nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+type tenantFakeRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *tenantFakeRows) Columns() []string { return []string{"value"} }
+func (r *tenantFakeRows) Close() error      { return nil }
+func (r *tenantFakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+var fakeDriverCount atomic.Int32
+
+func openTenantFakeDB(t *testing.T) (*sql.DB, *tenantFakeDriver) {
+	d := &tenantFakeDriver{}
+	name := "tenantfake" + string(rune('a'+fakeDriverCount.Add(1)))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	db.SetMaxOpenConns(4)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+func currentOrg(t *testing.T, ctx context.Context, q querier) string {
+	t.Helper()
+	var org string
+	if err := q.QueryRowContext(ctx, "SELECT current_setting('app.current_org', true)").Scan(&org); err != nil {
+		t.Fatalf("current_setting query error = %v", err)
+	}
+	return org
+}
+
+func TestWithTenantConn_QueriesSeeTenant(t *testing.T) {
+	db, d := openTenantFakeDB(t)
+	ctx := context.Background()
+
+	err := WithTenantConn(ctx, db, "org_1", func(ctx context.Context) error {
+		if got := currentOrg(t, ctx, dbFor(ctx, db)); got != "org_1" {
+			t.Errorf("Query inside WithTenantConn saw app.current_org = %q, want org_1", got)
+		}
+
+		// Nested repository calls for the same tenant join the transaction
+		return WithTenantConn(ctx, db, "org_1", func(ctx context.Context) error {
+			if got := currentOrg(t, ctx, dbFor(ctx, db)); got != "org_1" {
+				t.Errorf("Nested query saw app.current_org = %q, want org_1", got)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTenantConn() error = %v", err)
+	}
+	if d.commits.Load() != 1 {
+		t.Errorf("Commits = %d, want 1 (nested call must not open a second transaction)", d.commits.Load())
+	}
+
+	// The setting ends with the transaction, so pooled connections never carry a tenant
+	if got := currentOrg(t, ctx, db); got != "" {
+		t.Errorf("Pooled query after WithTenantConn saw app.current_org = %q, want empty", got)
+	}
+}
+
+func TestWithTenantConn_ErrorRollsBack(t *testing.T) {
+	db, d := openTenantFakeDB(t)
+	wantErr := errors.New("query failed")
+
+	err := WithTenantConn(context.Background(), db, "org_1", func(ctx context.Context) error {
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("WithTenantConn() error = %v, want %v", err, wantErr)
+	}
+	if d.rolls.Load() != 1 || d.commits.Load() != 0 {
+		t.Errorf("Rollbacks = %d, commits = %d, want 1 and 0", d.rolls.Load(), d.commits.Load())
+	}
+}
+
+func TestWithTenantConn_RejectsOtherTenant(t *testing.T) {
+	db, _ := openTenantFakeDB(t)
+
+	err := WithTenantConn(context.Background(), db, "org_1", func(ctx context.Context) error {
+		return WithTenantConn(ctx, db, "org_2", func(ctx context.Context) error {
+			t.Error("org_2 query ran inside org_1's transaction")
+			return nil
+		})
+	})
+	if err == nil {
+		t.Fatal("Expected error when mixing tenants in one transaction")
+	}
+}
+
+// TestWithTenantConn_Postgres checks the same guarantee against a real database
+func TestWithTenantConn_Postgres(t *testing.T) {
+	url := os.Getenv("DASHBOARD_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("DASHBOARD_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	err = WithTenantConn(ctx, db, "org_1", func(ctx context.Context) error {
+		if got := currentOrg(t, ctx, dbFor(ctx, db)); got != "org_1" {
+			t.Errorf("app.current_org = %q, want org_1", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTenantConn() error = %v", err)
+	}
+	if got := currentOrg(t, ctx, db); got != "" {
+		t.Errorf("app.current_org leaked outside the transaction: %q", got)
+	}
+}
@@ -3,7 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
 )
@@ -19,13 +22,19 @@ func NewInvoiceRepository(db *sql.DB) *InvoiceRepository {
 }
 
 // ListInvoices retrieves invoices for an organization with pagination
-func (r *InvoiceRepository) ListInvoices(ctx context.Context, orgID string, page, pageSize int) (*models.InvoiceListResponse, error) {
+func (r *InvoiceRepository) ListInvoices(ctx context.Context, orgID string, page, pageSize int) (_ *models.InvoiceListResponse, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
 	offset := (page - 1) * pageSize
 
 	// Get total count
 	var totalCount int
 	countQuery := `SELECT COUNT(*) FROM invoices WHERE organization_id = $1`
-	err := r.db.QueryRowContext(ctx, countQuery, orgID).Scan(&totalCount)
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, countQuery, orgID).Scan(&totalCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count invoices: %w", err)
 	}
@@ -34,14 +43,15 @@ func (r *InvoiceRepository) ListInvoices(ctx context.Context, orgID string, page
 	query := `
 		SELECT id, invoice_number, organization_id, customer_name, customer_email,
 		       billing_period_start, billing_period_end, status, subtotal, tax, total,
-		       currency, due_date, paid_at, pdf_url, stripe_invoice_id, created_at, updated_at
+		       refunded_amount_cents / 100.0, currency, due_date, paid_at, COALESCE(pdf_url, ''), stripe_invoice_id,
+		       COALESCE(notes, ''), COALESCE(po_number, ''), COALESCE(custom_fields, '{}'), created_at, updated_at
 		FROM invoices
 		WHERE organization_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, orgID, pageSize, offset)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, orgID, pageSize, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list invoices: %w", err)
 	}
@@ -50,6 +60,7 @@ func (r *InvoiceRepository) ListInvoices(ctx context.Context, orgID string, page
 	var invoices []models.Invoice
 	for rows.Next() {
 		var inv models.Invoice
+		var customFields []byte
 		err := rows.Scan(
 			&inv.ID,
 			&inv.InvoiceNumber,
@@ -62,17 +73,24 @@ func (r *InvoiceRepository) ListInvoices(ctx context.Context, orgID string, page
 			&inv.Subtotal,
 			&inv.Tax,
 			&inv.Total,
+			&inv.RefundedAmount,
 			&inv.Currency,
 			&inv.DueDate,
 			&inv.PaidAt,
 			&inv.PDFURL,
 			&inv.StripeInvoiceID,
+			&inv.Notes,
+			&inv.PONumber,
+			&customFields,
 			&inv.CreatedAt,
 			&inv.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan invoice: %w", err)
 		}
+		if err := json.Unmarshal(customFields, &inv.CustomFields); err != nil {
+			return nil, fmt.Errorf("invalid custom fields on invoice %s: %w", inv.ID, err)
+		}
 		invoices = append(invoices, inv)
 	}
 
@@ -91,17 +109,25 @@ func (r *InvoiceRepository) ListInvoices(ctx context.Context, orgID string, page
 }
 
 // GetInvoice retrieves a single invoice by ID
-func (r *InvoiceRepository) GetInvoice(ctx context.Context, invoiceID, orgID string) (*models.Invoice, error) {
+func (r *InvoiceRepository) GetInvoice(ctx context.Context, invoiceID, orgID string) (_ *models.Invoice, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
 	query := `
 		SELECT id, invoice_number, organization_id, customer_name, customer_email,
 		       billing_period_start, billing_period_end, status, subtotal, tax, total,
-		       currency, due_date, paid_at, pdf_url, stripe_invoice_id, created_at, updated_at
+		       refunded_amount_cents / 100.0, currency, due_date, paid_at, COALESCE(pdf_url, ''), stripe_invoice_id,
+		       COALESCE(notes, ''), COALESCE(po_number, ''), COALESCE(custom_fields, '{}'), created_at, updated_at
 		FROM invoices
 		WHERE id = $1 AND organization_id = $2
 	`
 
 	var inv models.Invoice
-	err := r.db.QueryRowContext(ctx, query, invoiceID, orgID).Scan(
+	var customFields []byte
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query, invoiceID, orgID).Scan(
 		&inv.ID,
 		&inv.InvoiceNumber,
 		&inv.OrganizationID,
@@ -113,11 +139,15 @@ func (r *InvoiceRepository) GetInvoice(ctx context.Context, invoiceID, orgID str
 		&inv.Subtotal,
 		&inv.Tax,
 		&inv.Total,
+		&inv.RefundedAmount,
 		&inv.Currency,
 		&inv.DueDate,
 		&inv.PaidAt,
 		&inv.PDFURL,
 		&inv.StripeInvoiceID,
+		&inv.Notes,
+		&inv.PONumber,
+		&customFields,
 		&inv.CreatedAt,
 		&inv.UpdatedAt,
 	)
@@ -128,20 +158,31 @@ func (r *InvoiceRepository) GetInvoice(ctx context.Context, invoiceID, orgID str
 		}
 		return nil, fmt.Errorf("failed to get invoice: %w", err)
 	}
+	if err := json.Unmarshal(customFields, &inv.CustomFields); err != nil {
+		return nil, fmt.Errorf("invalid custom fields on invoice %s: %w", inv.ID, err)
+	}
 
 	return &inv, nil
 }
 
-// GetInvoiceLineItems retrieves line items for an invoice
-func (r *InvoiceRepository) GetInvoiceLineItems(ctx context.Context, invoiceID string) ([]models.InvoiceLineItem, error) {
+// GetInvoiceLineItems retrieves line items for an invoice owned by the organization
+// Another organization's invoice has no line items here
+func (r *InvoiceRepository) GetInvoiceLineItems(ctx context.Context, invoiceID, orgID string) (_ []models.InvoiceLineItem, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
 	query := `
-		SELECT id, invoice_id, description, quantity, unit_price, amount, metric_name
-		FROM invoice_line_items
-		WHERE invoice_id = $1
-		ORDER BY id
+		SELECT li.id, li.invoice_id, li.description, li.quantity, li.unit_price, li.amount, li.metric_name
+		FROM invoice_line_items li
+		JOIN invoices i ON li.invoice_id = i.id
+		WHERE li.invoice_id = $1 AND i.organization_id = $2
+		ORDER BY li.id
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, invoiceID, orgID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get line items: %w", err)
 	}
@@ -168,12 +209,93 @@ func (r *InvoiceRepository) GetInvoiceLineItems(ctx context.Context, invoiceID s
 	return items, rows.Err()
 }
 
+// ListInvoiceLineItems retrieves a page of line items for an invoice owned by the organization
+func (r *InvoiceRepository) ListInvoiceLineItems(ctx context.Context, invoiceID, orgID string, page, pageSize int) (_ *models.InvoiceLineItemListResponse, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	offset := (page - 1) * pageSize
+
+	// Verify ownership and count items in one query
+	var owned bool
+	var totalCount int
+	countQuery := `
+		SELECT
+			EXISTS(SELECT 1 FROM invoices WHERE id = $1 AND organization_id = $2),
+			(SELECT COUNT(*) FROM invoice_line_items li
+			 JOIN invoices i ON li.invoice_id = i.id
+			 WHERE li.invoice_id = $1 AND i.organization_id = $2)
+	`
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, countQuery, invoiceID, orgID).Scan(&owned, &totalCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count line items: %w", err)
+	}
+
+	if !owned {
+		return nil, fmt.Errorf("invoice not found")
+	}
+
+	query := `
+		SELECT li.id, li.invoice_id, li.description, li.quantity, li.unit_price, li.amount, li.metric_name
+		FROM invoice_line_items li
+		JOIN invoices i ON li.invoice_id = i.id
+		WHERE li.invoice_id = $1 AND i.organization_id = $2
+		ORDER BY li.id
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, invoiceID, orgID, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list line items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.InvoiceLineItem{}
+	for rows.Next() {
+		var item models.InvoiceLineItem
+		err := rows.Scan(
+			&item.ID,
+			&item.InvoiceID,
+			&item.Description,
+			&item.Quantity,
+			&item.UnitPrice,
+			&item.Amount,
+			&item.MetricName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan line item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating line items: %w", err)
+	}
+
+	return &models.InvoiceLineItemListResponse{
+		InvoiceID:  invoiceID,
+		LineItems:  items,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
 // GetInvoicePDFURL retrieves the PDF URL for an invoice
-func (r *InvoiceRepository) GetInvoicePDFURL(ctx context.Context, invoiceID, orgID string) (string, error) {
-	query := `SELECT pdf_url FROM invoices WHERE id = $1 AND organization_id = $2`
+func (r *InvoiceRepository) GetInvoicePDFURL(ctx context.Context, invoiceID, orgID string) (_ string, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return "", err
+	}
+	defer done(&err)
+
+	query := `SELECT COALESCE(pdf_url, '') FROM invoices WHERE id = $1 AND organization_id = $2`
 
 	var pdfURL string
-	err := r.db.QueryRowContext(ctx, query, invoiceID, orgID).Scan(&pdfURL)
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query, invoiceID, orgID).Scan(&pdfURL)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("invoice not found")
@@ -187,3 +309,225 @@ func (r *InvoiceRepository) GetInvoicePDFURL(ctx context.Context, invoiceID, org
 
 	return pdfURL, nil
 }
+
+// VoidInvoice marks an unpaid invoice voided and records the actor and reason in invoice_events
+// Stripe invoices are voided on Stripe by the billing engine (stripe_voided_at stays NULL until then)
+func (r *InvoiceRepository) VoidInvoice(ctx context.Context, invoiceID, orgID, reason, actorUserID string) (*models.Invoice, error) {
+	tx, err := beginTenantTx(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx,
+		`SELECT status FROM invoices WHERE id = $1 AND organization_id = $2 FOR UPDATE`,
+		invoiceID, orgID,
+	).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	// Mirrors the billing engine's invoice state machine
+	switch status {
+	case "paid":
+		return nil, fmt.Errorf("invoice is paid")
+	case "voided":
+		return nil, fmt.Errorf("invoice already voided")
+	case "draft", "pending", "send_failed", "email_failed", "failed":
+	default:
+		return nil, fmt.Errorf("invoice cannot be voided")
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE invoices SET status = 'voided', updated_at = $1 WHERE id = $2`,
+		now, invoiceID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to void invoice: %w", err)
+	}
+
+	query := `
+		INSERT INTO invoice_events (invoice_id, event_type, from_status, to_status, actor_user_id, reason, created_at)
+		VALUES ($1, 'voided', $2, 'voided', NULLIF($3, ''), $4, $5)
+	`
+	if _, err := tx.ExecContext(ctx, query, invoiceID, status, actorUserID, reason, now); err != nil {
+		return nil, fmt.Errorf("failed to record invoice event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.GetInvoice(ctx, invoiceID, orgID)
+}
+
+// UpdateInvoice sets the notes, PO number, and custom fields of an unpaid invoice and records the edit in invoice_events
+// The stored PDF is invalidated; the billing engine regenerates it with the new notes
+func (r *InvoiceRepository) UpdateInvoice(ctx context.Context, invoiceID, orgID string, update models.UpdateInvoiceRequest, actorUserID string) (*models.Invoice, error) {
+	tx, err := beginTenantTx(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx,
+		`SELECT status FROM invoices WHERE id = $1 AND organization_id = $2 FOR UPDATE`,
+		invoiceID, orgID,
+	).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	// Paid (and refunded) invoices are final, like voided ones
+	switch status {
+	case "paid", "partially_refunded", "refunded":
+		return nil, fmt.Errorf("invoice is paid")
+	case "voided":
+		return nil, fmt.Errorf("invoice is voided")
+	case "draft", "pending", "send_failed", "email_failed", "failed":
+	default:
+		return nil, fmt.Errorf("invoice cannot be edited")
+	}
+
+	customFields, err := customFieldsParam(update.CustomFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// NULL parameters keep the current value
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE invoices
+		SET notes = COALESCE($1, notes),
+		    po_number = COALESCE($2, po_number),
+		    custom_fields = COALESCE($3::jsonb, custom_fields),
+		    pdf_url = NULL,
+		    pdf_sha256 = NULL,
+		    pdf_invalidated_at = $4,
+		    updated_at = $4
+		WHERE id = $5
+	`, update.Notes, update.PONumber, customFields, now, invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to update invoice: %w", err)
+	}
+
+	query := `
+		INSERT INTO invoice_events (invoice_id, event_type, from_status, to_status, actor_user_id, reason, created_at)
+		VALUES ($1, 'updated', $2, $2, NULLIF($3, ''), $4, $5)
+	`
+	if _, err := tx.ExecContext(ctx, query, invoiceID, status, actorUserID, updatedFields(update), now); err != nil {
+		return nil, fmt.Errorf("failed to record invoice event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.GetInvoice(ctx, invoiceID, orgID)
+}
+
+// updatedFields describes an invoice edit for the audit trail, e.g. "updated notes, po_number"
+func updatedFields(update models.UpdateInvoiceRequest) string {
+	var fields []string
+	if update.Notes != nil {
+		fields = append(fields, "notes")
+	}
+	if update.PONumber != nil {
+		fields = append(fields, "po_number")
+	}
+	if update.CustomFields != nil {
+		fields = append(fields, "custom_fields")
+	}
+	return "updated " + strings.Join(fields, ", ")
+}
+
+// customFieldsParam encodes custom fields for a JSONB column; nil leaves the column unchanged
+func customFieldsParam(fields map[string]string) (*string, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode custom fields: %w", err)
+	}
+	param := string(encoded)
+	return &param, nil
+}
+
+// RequestRefund reserves amountCents of a paid invoice for refund and records it in invoice_events
+// The billing engine issues pending refunds on Stripe, updates the invoice status, and emails the customer
+func (r *InvoiceRepository) RequestRefund(ctx context.Context, invoiceID, orgID string, amountCents int64, reason, actorUserID string) (*models.InvoiceRefund, error) {
+	tx, err := beginTenantTx(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var totalCents, refundedCents int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, total_cents, refunded_amount_cents FROM invoices WHERE id = $1 AND organization_id = $2 FOR UPDATE`,
+		invoiceID, orgID,
+	).Scan(&status, &totalCents, &refundedCents)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	if status != "paid" && status != "partially_refunded" {
+		return nil, fmt.Errorf("invoice is not paid")
+	}
+
+	// Pending refunds are already counted in refunded_amount_cents
+	if refundedCents+amountCents > totalCents {
+		return nil, fmt.Errorf("refund exceeds remaining balance")
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE invoices SET refunded_amount_cents = refunded_amount_cents + $1, updated_at = $2 WHERE id = $3`,
+		amountCents, now, invoiceID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to reserve refund: %w", err)
+	}
+
+	refund := &models.InvoiceRefund{
+		InvoiceID:   invoiceID,
+		AmountCents: amountCents,
+		Reason:      reason,
+		Status:      "pending",
+		CreatedAt:   now,
+	}
+
+	query := `
+		INSERT INTO invoice_refunds (invoice_id, amount_cents, reason, actor_user_id, status, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), 'pending', $5)
+		RETURNING id
+	`
+	if err := tx.QueryRowContext(ctx, query, invoiceID, amountCents, reason, actorUserID, now).Scan(&refund.ID); err != nil {
+		return nil, fmt.Errorf("failed to insert refund: %w", err)
+	}
+
+	query = `
+		INSERT INTO invoice_events (invoice_id, event_type, from_status, to_status, actor_user_id, reason, created_at)
+		VALUES ($1, 'refund_requested', $2, $2, NULLIF($3, ''), $4, $5)
+	`
+	if _, err := tx.ExecContext(ctx, query, invoiceID, status, actorUserID, reason, now); err != nil {
+		return nil, fmt.Errorf("failed to record invoice event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return refund, nil
+}
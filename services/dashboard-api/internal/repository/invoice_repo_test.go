@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+)
+
+// TestGetInvoiceLineItems_Postgres tests that line items are only returned to the invoice's organization
+func TestGetInvoiceLineItems_Postgres(t *testing.T) {
+	url := os.Getenv("DASHBOARD_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("DASHBOARD_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // Temporary tables only exist on the connection that created them
+	ctx := context.Background()
+
+	setup := []string{
+		`CREATE TEMP TABLE invoices (id TEXT PRIMARY KEY, organization_id TEXT NOT NULL)`,
+		`CREATE TEMP TABLE invoice_line_items (
+			id TEXT PRIMARY KEY, invoice_id TEXT NOT NULL, description TEXT NOT NULL,
+			quantity NUMERIC NOT NULL, unit_price NUMERIC NOT NULL, amount NUMERIC NOT NULL, metric_name TEXT NOT NULL)`,
+		`INSERT INTO invoices VALUES ('inv_1', 'org_1'), ('inv_2', 'org_2')`,
+		`INSERT INTO invoice_line_items VALUES
+			('li_1', 'inv_1', 'Base plan', 1, 99, 99, 'base'),
+			('li_2', 'inv_1', 'Overage', 500, 0.004, 2, 'requests'),
+			('li_3', 'inv_2', 'Base plan', 1, 29, 29, 'base')`,
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("Setup %q error = %v", stmt, err)
+		}
+	}
+
+	repo := NewInvoiceRepository(db)
+
+	items, err := repo.GetInvoiceLineItems(ctx, "inv_1", "org_1")
+	if err != nil {
+		t.Fatalf("GetInvoiceLineItems() error = %v", err)
+	}
+	if len(items) != 2 || items[0].ID != "li_1" || items[1].ID != "li_2" {
+		t.Errorf("Items = %+v, want li_1 and li_2", items)
+	}
+
+	// Another organization cannot read the invoice's line items by ID
+	items, err = repo.GetInvoiceLineItems(ctx, "inv_1", "org_2")
+	if err != nil {
+		t.Fatalf("GetInvoiceLineItems() for another org error = %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Items for another org = %+v, want none", items)
+	}
+}
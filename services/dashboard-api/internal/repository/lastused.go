@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// lastUsedTracker buffers last_used_at timestamps in memory so that validating a key
+// costs no database write; a background writer flushes them in one batched UPDATE
+type lastUsedTracker struct {
+	mu      sync.Mutex
+	pending map[string]time.Time // Key ID -> newest use not yet written
+}
+
+func newLastUsedTracker() *lastUsedTracker {
+	return &lastUsedTracker{pending: make(map[string]time.Time)}
+}
+
+// sharedLastUsed is used by every APIKeyRepository, so one writer flushes uses recorded
+// through any of them
+var sharedLastUsed = newLastUsedTracker()
+
+// touch records that keyID was used at usedAt
+func (t *lastUsedTracker) touch(keyID string, usedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, ok := t.pending[keyID]; !ok || usedAt.After(prev) {
+		t.pending[keyID] = usedAt
+	}
+}
+
+// take removes and returns everything recorded since the last call
+func (t *lastUsedTracker) take() map[string]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending := t.pending
+	t.pending = make(map[string]time.Time, len(pending))
+	return pending
+}
+
+// restore puts back uses whose write failed, keeping any newer use recorded meanwhile
+func (t *lastUsedTracker) restore(uses map[string]time.Time) {
+	for keyID, usedAt := range uses {
+		t.touch(keyID, usedAt)
+	}
+}
+
+// FlushLastUsed writes the buffered last_used_at timestamps and returns how many keys it
+// covered. Like the rotation cleanup it spans all organizations, so it is not tenant scoped
+func (r *APIKeyRepository) FlushLastUsed(ctx context.Context) (int, error) {
+	uses := r.lastUsed.take()
+	if len(uses) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, 0, len(uses))
+	times := make([]string, 0, len(uses))
+	for keyID, usedAt := range uses {
+		ids = append(ids, keyID)
+		times = append(times, usedAt.UTC().Format(time.RFC3339Nano))
+	}
+
+	// Never move last_used_at backwards, e.g. when another replica flushed a newer use
+	query := `
+		UPDATE api_keys AS ak
+		SET last_used_at = u.used_at
+		FROM unnest($1::UUID[], $2::TIMESTAMPTZ[]) AS u(id, used_at)
+		WHERE ak.id = u.id
+		  AND (ak.last_used_at IS NULL OR ak.last_used_at < u.used_at)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, pq.Array(ids), pq.Array(times)); err != nil {
+		r.lastUsed.restore(uses)
+		return 0, fmt.Errorf("failed to update API key last_used_at: %w", err)
+	}
+
+	return len(uses), nil
+}
+
+// RunLastUsedFlush calls FlushLastUsed every interval until ctx is cancelled, then
+// flushes once more so uses recorded just before shutdown are not lost
+func (r *APIKeyRepository) RunLastUsedFlush(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := r.FlushLastUsed(finalCtx); err != nil {
+				log.Printf("API key last-used flush failed: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if _, err := r.FlushLastUsed(ctx); err != nil {
+				log.Printf("API key last-used flush failed: %v", err)
+			}
+		}
+	}
+}
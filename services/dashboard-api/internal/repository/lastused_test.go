@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidateAPIKey_BuffersLastUsed(t *testing.T) {
+	repo, d := openValidateFakeDB(t, bcrypt.MinCost, 1, true)
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		if orgID, err := repo.ValidateAPIKey(ctx, d.keys[0].fullKey); err != nil || orgID != "org_1" {
+			t.Fatalf("ValidateAPIKey() = %q, %v, want org_1", orgID, err)
+		}
+	}
+	if got := d.lastUsedWrites.Load(); got != 0 {
+		t.Fatalf("Validations wrote last_used_at %d times, want 0 before the flush", got)
+	}
+
+	// One flush covers every validation in the window with a single write
+	flushed, err := repo.FlushLastUsed(ctx)
+	if err != nil {
+		t.Fatalf("FlushLastUsed() error = %v", err)
+	}
+	if flushed != 1 || d.lastUsedWrites.Load() != 1 || d.lastUsedIDs.Load() != 1 {
+		t.Errorf("Flushed %d keys in %d writes of %d IDs, want 1 key in 1 write",
+			flushed, d.lastUsedWrites.Load(), d.lastUsedIDs.Load())
+	}
+
+	// Nothing new was used, so the next flush does not touch the database
+	if flushed, _ := repo.FlushLastUsed(ctx); flushed != 0 || d.lastUsedWrites.Load() != 1 {
+		t.Errorf("Idle flush wrote %d keys (%d writes total), want none", flushed, d.lastUsedWrites.Load())
+	}
+}
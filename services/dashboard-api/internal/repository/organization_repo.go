@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// OrganizationRepository handles organization status and invoice default operations
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+// NewOrganizationRepository creates a new organization repository
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// GetOrganizationStatus returns an organization's status and, when suspended, why
+func (r *OrganizationRepository) GetOrganizationStatus(ctx context.Context, orgID string) (_ *models.OrganizationStatus, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	query := `
+		SELECT id, status, suspension_reason, suspended_at
+		FROM organizations
+		WHERE id = $1
+	`
+
+	var org models.OrganizationStatus
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query, orgID).Scan(
+		&org.OrganizationID,
+		&org.Status,
+		&org.SuspensionReason,
+		&org.SuspendedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return &org, nil
+}
+
+// SuspendOrganization suspends an active organization; its API keys stop working at the
+// gateway, while its users can still sign in to the dashboard
+func (r *OrganizationRepository) SuspendOrganization(ctx context.Context, orgID, reason string) (*models.OrganizationStatus, error) {
+	return r.setStatus(ctx, orgID, models.OrgStatusSuspended, reason)
+}
+
+// ReactivateOrganization returns a suspended organization to active
+// Suspensions for non-payment are left to the billing engine, which lifts them once paid
+func (r *OrganizationRepository) ReactivateOrganization(ctx context.Context, orgID string) (*models.OrganizationStatus, error) {
+	return r.setStatus(ctx, orgID, models.OrgStatusActive, "")
+}
+
+// setStatus validates and applies an organization status change
+func (r *OrganizationRepository) setStatus(ctx context.Context, orgID, status, reason string) (*models.OrganizationStatus, error) {
+	tx, err := beginTenantTx(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	var currentReason sql.NullString
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, suspension_reason FROM organizations WHERE id = $1 FOR UPDATE`, orgID,
+	).Scan(&current, &currentReason)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	if err := checkOrgTransition(current, currentReason.String, status); err != nil {
+		return nil, err
+	}
+
+	// The status trigger evicts the organization's keys from the gateway cache
+	query := `
+		UPDATE organizations
+		SET status = $1,
+		    suspension_reason = NULLIF($2, ''),
+		    suspended_at = CASE WHEN $1 = 'suspended' THEN $3 ELSE NULL END,
+		    updated_at = $3
+		WHERE id = $4
+	`
+	if _, err := tx.ExecContext(ctx, query, status, reason, time.Now(), orgID); err != nil {
+		return nil, fmt.Errorf("failed to update organization status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.GetOrganizationStatus(ctx, orgID)
+}
+
+// GetInvoiceDefaults returns the PO number and custom fields copied onto the organization's new invoices
+func (r *OrganizationRepository) GetInvoiceDefaults(ctx context.Context, orgID string) (_ *models.InvoiceDefaults, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	query := `
+		SELECT id, COALESCE(default_po_number, ''), COALESCE(invoice_custom_fields, '{}')
+		FROM organizations
+		WHERE id = $1
+	`
+
+	var defaults models.InvoiceDefaults
+	var customFields []byte
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query, orgID).Scan(
+		&defaults.OrganizationID,
+		&defaults.DefaultPONumber,
+		&customFields,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to get invoice defaults: %w", err)
+	}
+	if err := json.Unmarshal(customFields, &defaults.CustomFields); err != nil {
+		return nil, fmt.Errorf("invalid invoice custom fields: %w", err)
+	}
+
+	return &defaults, nil
+}
+
+// UpdateInvoiceDefaults sets the organization's invoice defaults
+// Existing invoices keep their PO number and custom fields; only invoices generated later use the new ones
+func (r *OrganizationRepository) UpdateInvoiceDefaults(ctx context.Context, orgID string, update models.UpdateInvoiceDefaultsRequest) (_ *models.InvoiceDefaults, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	customFields, err := customFieldsParam(update.CustomFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// NULL parameters keep the current value; an empty PO number clears it
+	query := `
+		UPDATE organizations
+		SET default_po_number = CASE WHEN $1::text IS NULL THEN default_po_number ELSE NULLIF($1, '') END,
+		    invoice_custom_fields = COALESCE($2::jsonb, invoice_custom_fields),
+		    updated_at = $3
+		WHERE id = $4
+	`
+	result, err := dbFor(ctx, r.db).ExecContext(ctx, query, update.DefaultPONumber, customFields, time.Now(), orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update invoice defaults: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("organization not found")
+	}
+
+	return r.GetInvoiceDefaults(ctx, orgID)
+}
+
+// checkOrgTransition mirrors the billing engine's organization state machine, except that
+// a suspension for non-payment cannot be lifted here and a repeat is reported, not ignored
+func checkOrgTransition(from, reason, to string) error {
+	switch {
+	case to == models.OrgStatusSuspended && from == models.OrgStatusActive:
+		return nil
+	case to == models.OrgStatusSuspended && from == models.OrgStatusSuspended:
+		return fmt.Errorf("organization already suspended")
+	case to == models.OrgStatusActive && from == models.OrgStatusSuspended:
+		if reason == models.SuspensionReasonNonPayment {
+			return fmt.Errorf("organization suspended for non-payment")
+		}
+		return nil
+	case to == models.OrgStatusActive && from == models.OrgStatusActive:
+		return fmt.Errorf("organization not suspended")
+	}
+	return fmt.Errorf("invalid organization status transition: %s -> %s", from, to)
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// TestCheckOrgTransition tests which suspensions and reactivations an admin may make
+func TestCheckOrgTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		reason  string
+		to      string
+		wantErr string
+	}{
+		{"Suspend active org", models.OrgStatusActive, "", models.OrgStatusSuspended, ""},
+		{"Suspend suspended org", models.OrgStatusSuspended, "paused by admin", models.OrgStatusSuspended, "organization already suspended"},
+		{"Reactivate admin suspension", models.OrgStatusSuspended, "paused by admin", models.OrgStatusActive, ""},
+		{"Reactivate non-payment suspension", models.OrgStatusSuspended, models.SuspensionReasonNonPayment, models.OrgStatusActive, "organization suspended for non-payment"},
+		{"Reactivate active org", models.OrgStatusActive, "", models.OrgStatusActive, "organization not suspended"},
+		{"Unknown target status", models.OrgStatusActive, "", "closed", "invalid organization status transition: active -> closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOrgTransition(tt.from, tt.reason, tt.to)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("checkOrgTransition(%s, %q, %s) = %q, want %q", tt.from, tt.reason, tt.to, got, tt.wantErr)
+			}
+		})
+	}
+}
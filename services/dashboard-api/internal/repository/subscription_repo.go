@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// SubscriptionRepository handles an organization's plan subscription
+type SubscriptionRepository struct {
+	db *sql.DB
+}
+
+// NewSubscriptionRepository creates a new subscription repository
+func NewSubscriptionRepository(db *sql.DB) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db}
+}
+
+// ChangePlan moves the organization to change.ToPlanID and records the change with its proration
+// Fails with "plan changed concurrently" if the subscription is no longer on change.FromPlanID.
+// Orgs billed through a Stripe subscription get StripeSyncPending; the billing engine updates Stripe
+func (r *SubscriptionRepository) ChangePlan(ctx context.Context, change *models.PlanChange, actorUserID string) error {
+	tx, err := beginTenantTx(ctx, r.db, change.OrganizationID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var planID string
+	var stripeSubscriptionID sql.NullString
+	err = tx.QueryRowContext(ctx,
+		`SELECT plan_id, stripe_subscription_id FROM organization_subscriptions WHERE organization_id = $1 FOR UPDATE`,
+		change.OrganizationID,
+	).Scan(&planID, &stripeSubscriptionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("subscription not found")
+		}
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	// The proration was computed against the plan read before the lock
+	if planID != change.FromPlanID {
+		return fmt.Errorf("plan changed concurrently")
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE organization_subscriptions SET plan_id = $1, updated_at = $2 WHERE organization_id = $3`,
+		change.ToPlanID, change.EffectiveAt, change.OrganizationID,
+	); err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	query := `
+		INSERT INTO subscription_plan_changes (
+			organization_id, from_plan_id, to_plan_id, prorated_amount_cents,
+			period_start, period_end, effective_at, actor_user_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+		RETURNING id
+	`
+	err = tx.QueryRowContext(ctx, query,
+		change.OrganizationID, change.FromPlanID, change.ToPlanID, change.ProratedAmountCents,
+		change.PeriodStart, change.PeriodEnd, change.EffectiveAt, actorUserID,
+	).Scan(&change.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record plan change: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	change.StripeSyncPending = stripeSubscriptionID.Valid && stripeSubscriptionID.String != ""
+	return nil
+}
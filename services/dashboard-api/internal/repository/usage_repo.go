@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+	"github.com/lib/pq"
 )
 
 // UsageRepository handles usage data queries
@@ -19,99 +20,68 @@ func NewUsageRepository(db *sql.DB) *UsageRepository {
 	return &UsageRepository{db: db}
 }
 
-// GetCurrentDayUsage retrieves usage metrics for the current day
-func (r *UsageRepository) GetCurrentDayUsage(ctx context.Context, orgID string) (*models.CurrentUsageResponse, error) {
-	today := time.Now().UTC().Format("2006-01-02")
-
-	query := `
-		SELECT
-			metric_name,
-			SUM(value) as total_value,
-			unit,
-			COUNT(*) as count,
-			MAX(timestamp) as last_updated
-		FROM usage_metrics
-		WHERE organization_id = $1
-			AND DATE(timestamp) = $2
-		GROUP BY metric_name, unit
-		ORDER BY metric_name
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, orgID, today)
+// GetUsageHistory retrieves a page of daily usage for dates in [startDate, endDate] (inclusive, UTC)
+// Days are returned newest first; costs are left to the caller, which prices them against the plan
+func (r *UsageRepository) GetUsageHistory(ctx context.Context, orgID string, startDate, endDate time.Time, page, pageSize int) (_ *models.UsageHistoryResponse, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query current usage: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
-
-	var metrics []models.UsageMetricSummary
-	var lastUpdated time.Time
-	var totalCost float64
-
-	for rows.Next() {
-		var metric models.UsageMetricSummary
-		err := rows.Scan(
-			&metric.MetricName,
-			&metric.TotalValue,
-			&metric.Unit,
-			&metric.Count,
-			&lastUpdated,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan usage metric: %w", err)
-		}
-
-		// Calculate cost (this should ideally use the pricing calculator)
-		// For now, using simple estimation
-		metric.Cost = r.estimateCost(metric.MetricName, metric.TotalValue)
-		totalCost += metric.Cost
+	defer done(&err)
 
-		metrics = append(metrics, metric)
-	}
+	rangeStart := startDate
+	rangeEnd := endDate.AddDate(0, 0, 1) // Exclusive upper bound
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating usage metrics: %w", err)
-	}
+	// Step 1: Days with usage in the range
+	countQuery := `
+		SELECT COUNT(DISTINCT DATE(timestamp))
+		FROM usage_metrics
+		WHERE organization_id = $1
+			AND timestamp >= $2
+			AND timestamp < $3
+	`
 
-	response := &models.CurrentUsageResponse{
-		OrganizationID: orgID,
-		Date:           today,
-		Metrics:        metrics,
-		TotalCost:      totalCost,
-		UpdatedAt:      lastUpdated,
+	var totalCount int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, countQuery, orgID, rangeStart, rangeEnd).Scan(&totalCount); err != nil {
+		return nil, fmt.Errorf("failed to count usage history days: %w", err)
 	}
 
-	return response, nil
-}
-
-// GetUsageHistory retrieves usage metrics for a date range (last N days)
-func (r *UsageRepository) GetUsageHistory(ctx context.Context, orgID string, days int) (*models.UsageHistoryResponse, error) {
-	endDate := time.Now().UTC()
-	startDate := endDate.AddDate(0, 0, -days)
+	// Step 2: Metrics for the days on this page
+	offset := (page - 1) * pageSize
 
 	query := `
+		WITH page_days AS (
+			SELECT DISTINCT DATE(timestamp) as date
+			FROM usage_metrics
+			WHERE organization_id = $1
+				AND timestamp >= $2
+				AND timestamp < $3
+			ORDER BY date DESC
+			LIMIT $4 OFFSET $5
+		)
 		SELECT
-			DATE(timestamp) as date,
-			metric_name,
-			SUM(value) as total_value,
-			unit,
+			DATE(um.timestamp) as date,
+			um.metric_name,
+			SUM(um.value) as total_value,
+			um.unit,
 			COUNT(*) as count
-		FROM usage_metrics
-		WHERE organization_id = $1
-			AND timestamp >= $2
-			AND timestamp <= $3
-		GROUP BY DATE(timestamp), metric_name, unit
-		ORDER BY date DESC, metric_name
+		FROM usage_metrics um
+		JOIN page_days pd ON DATE(um.timestamp) = pd.date
+		WHERE um.organization_id = $1
+			AND um.timestamp >= $2
+			AND um.timestamp < $3
+		GROUP BY DATE(um.timestamp), um.metric_name, um.unit
+		ORDER BY date DESC, um.metric_name
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, orgID, startDate, endDate)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, orgID, rangeStart, rangeEnd, pageSize, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query usage history: %w", err)
 	}
 	defer rows.Close()
 
-	// Group by date
-	dailyUsageMap := make(map[string]*models.DailyUsageSummary)
-	var totalCost float64
+	// Rows arrive ordered by date, so consecutive rows share a day
+	dailyUsage := []models.DailyUsageSummary{}
 
 	for rows.Next() {
 		var date time.Time
@@ -130,46 +100,93 @@ func (r *UsageRepository) GetUsageHistory(ctx context.Context, orgID string, day
 
 		dateStr := date.Format("2006-01-02")
 
-		// Calculate cost
-		metric.Cost = r.estimateCost(metric.MetricName, metric.TotalValue)
-
-		// Add to daily summary
-		if dailyUsageMap[dateStr] == nil {
-			dailyUsageMap[dateStr] = &models.DailyUsageSummary{
+		// Start a new daily summary when the date changes
+		if len(dailyUsage) == 0 || dailyUsage[len(dailyUsage)-1].Date != dateStr {
+			dailyUsage = append(dailyUsage, models.DailyUsageSummary{
 				Date:    dateStr,
 				Metrics: []models.UsageMetricSummary{},
-				Cost:    0,
-			}
+			})
 		}
 
-		dailyUsageMap[dateStr].Metrics = append(dailyUsageMap[dateStr].Metrics, metric)
-		dailyUsageMap[dateStr].Cost += metric.Cost
-		totalCost += metric.Cost
+		day := &dailyUsage[len(dailyUsage)-1]
+		day.Metrics = append(day.Metrics, metric)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating usage history: %w", err)
 	}
 
-	// Convert map to slice
-	var dailyUsage []models.DailyUsageSummary
-	for _, summary := range dailyUsageMap {
-		dailyUsage = append(dailyUsage, *summary)
-	}
-
 	response := &models.UsageHistoryResponse{
 		OrganizationID: orgID,
 		StartDate:      startDate.Format("2006-01-02"),
 		EndDate:        endDate.Format("2006-01-02"),
 		DailyUsage:     dailyUsage,
-		TotalCost:      totalCost,
+		TotalCount:     totalCount,
+		Page:           page,
+		PageSize:       pageSize,
 	}
 
 	return response, nil
 }
 
+// StreamDailyUsage calls fn for each day with usage in [startDate, endDate] (inclusive, UTC), oldest first
+// Reads the usage_hourly rollup so rows are returned without buffering the whole range
+func (r *UsageRepository) StreamDailyUsage(ctx context.Context, orgID string, startDate, endDate time.Time, fn func(models.UsageExportRow) error) (err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return err
+	}
+	defer done(&err)
+
+	query := `
+		SELECT
+			DATE(hour) as date,
+			SUM(total_requests) as total_requests,
+			COALESCE(SUM(billable_units), 0) as billable_units,
+			SUM(error_count) as error_count,
+			COALESCE(SUM(avg_response_time_ms * total_requests) / NULLIF(SUM(total_requests), 0), 0) as avg_response_time_ms
+		FROM usage_hourly
+		WHERE organization_id = $1
+			AND hour >= $2
+			AND hour < $3
+		GROUP BY DATE(hour)
+		ORDER BY date
+	`
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, orgID, startDate, endDate.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("failed to query usage export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date time.Time
+		var row models.UsageExportRow
+		if err := rows.Scan(&date, &row.TotalRequests, &row.BillableUnits, &row.ErrorCount, &row.AvgResponseTimeMs); err != nil {
+			return fmt.Errorf("failed to scan usage export row: %w", err)
+		}
+		row.Date = date.Format("2006-01-02")
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating usage export: %w", err)
+	}
+
+	return nil
+}
+
 // GetUsageByMetric retrieves usage for a specific metric over time
-func (r *UsageRepository) GetUsageByMetric(ctx context.Context, orgID, metricName string, days int) ([]models.UsageMetric, error) {
+func (r *UsageRepository) GetUsageByMetric(ctx context.Context, orgID, metricName string, days int) (_ []models.UsageMetric, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
 	startDate := time.Now().UTC().AddDate(0, 0, -days)
 
 	query := `
@@ -182,7 +199,7 @@ func (r *UsageRepository) GetUsageByMetric(ctx context.Context, orgID, metricNam
 		LIMIT 1000
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, orgID, metricName, startDate)
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, orgID, metricName, startDate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query metric usage: %w", err)
 	}
@@ -201,20 +218,189 @@ func (r *UsageRepository) GetUsageByMetric(ctx context.Context, orgID, metricNam
 	return metrics, rows.Err()
 }
 
-// estimateCost estimates the cost for a metric (simplified version)
-// In production, this should use the actual pricing calculator
-func (r *UsageRepository) estimateCost(metricName string, value float64) float64 {
-	// Simple pricing estimate
-	pricePerUnit := map[string]float64{
-		"api_requests":     0.0001, // $0.0001 per request
-		"data_transfer_gb": 0.10,   // $0.10 per GB
-		"storage_gb":       0.05,   // $0.05 per GB per day
-		"compute_hours":    1.50,   // $1.50 per hour
+// GetUsageByRegion retrieves request counts grouped by client region for the last N days
+func (r *UsageRepository) GetUsageByRegion(ctx context.Context, orgID string, days int) (_ *models.UsageByRegionResponse, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return nil, err
 	}
+	defer done(&err)
 
-	if price, ok := pricePerUnit[metricName]; ok {
-		return value * price
+	endDate := time.Now().UTC()
+	startDate := endDate.AddDate(0, 0, -days)
+
+	query := `
+		SELECT
+			COALESCE(NULLIF(region, ''), 'unknown') as region,
+			COUNT(*) as requests,
+			COUNT(*) FILTER (WHERE billable = true) as billable_requests
+		FROM usage_events
+		WHERE organization_id = $1
+			AND time >= $2
+			AND time <= $3
+		GROUP BY 1
+		ORDER BY requests DESC
+	`
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, orgID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage by region: %w", err)
+	}
+	defer rows.Close()
+
+	regions := []models.RegionUsage{}
+	for rows.Next() {
+		var region models.RegionUsage
+		if err := rows.Scan(&region.Region, &region.Requests, &region.BillableRequests); err != nil {
+			return nil, fmt.Errorf("failed to scan region usage: %w", err)
+		}
+		regions = append(regions, region)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating region usage: %w", err)
+	}
+
+	return &models.UsageByRegionResponse{
+		OrganizationID: orgID,
+		StartDate:      startDate.Format("2006-01-02"),
+		EndDate:        endDate.Format("2006-01-02"),
+		Regions:        regions,
+	}, nil
+}
+
+// GetAverageMonthlyUsage calculates average monthly billable units over the last N months
+// Mirrors the billing engine's aggregator so recommendations match its projections
+func (r *UsageRepository) GetAverageMonthlyUsage(ctx context.Context, orgID string, months int) (_ int64, _ int, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer done(&err)
+
+	query := `
+		SELECT COALESCE(AVG(billable_units), 0)::BIGINT, COUNT(*)
+		FROM (
+			SELECT COALESCE(billable_units, 0) as billable_units
+			FROM usage_monthly
+			WHERE organization_id = $1
+			ORDER BY month DESC
+			LIMIT $2
+		) recent
+	`
+
+	var average int64
+	var count int
+	if err := dbFor(ctx, r.db).QueryRowContext(ctx, query, orgID, months).Scan(&average, &count); err != nil {
+		return 0, 0, fmt.Errorf("failed to get average monthly usage: %w", err)
+	}
+
+	return average, count, nil
+}
+
+// GetCurrentPlanID retrieves the plan the organization is subscribed to
+func (r *UsageRepository) GetCurrentPlanID(ctx context.Context, orgID string) (_ string, err error) {
+	ctx, done, err := tenantScope(ctx, r.db, orgID)
+	if err != nil {
+		return "", err
+	}
+	defer done(&err)
+
+	query := `SELECT plan_id FROM organization_subscriptions WHERE organization_id = $1`
+
+	var planID string
+	err = dbFor(ctx, r.db).QueryRowContext(ctx, query, orgID).Scan(&planID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("subscription not found")
+		}
+		return "", fmt.Errorf("failed to get current plan: %w", err)
+	}
+
+	return planID, nil
+}
+
+// ListPlanPricing retrieves active plans in display order, plus includePlanID even if retired
+func (r *UsageRepository) ListPlanPricing(ctx context.Context, includePlanID string) ([]models.PlanPricing, error) {
+	query := `
+		SELECT id, name, base_price_cents, included_units, overage_rate_cents,
+		       COALESCE(max_units, 0) as max_units
+		FROM pricing_plans
+		WHERE is_active = true OR id = $1
+		ORDER BY display_order, base_price_cents
+	`
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query, includePlanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plans: %w", err)
+	}
+	defer rows.Close()
+
+	plans := []models.PlanPricing{}
+	for rows.Next() {
+		var plan models.PlanPricing
+		err := rows.Scan(
+			&plan.PlanID,
+			&plan.PlanName,
+			&plan.BasePriceCents,
+			&plan.IncludedUnits,
+			&plan.OverageRateCents,
+			&plan.MaxUnits,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plans = append(plans, plan)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating plans: %w", err)
+	}
+
+	return plans, nil
+}
+
+// ListPlans retrieves every plan, including retired ones, in display order
+func (r *UsageRepository) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	query := `
+		SELECT id, name, COALESCE(description, '') as description, base_price_cents,
+		       included_units, overage_rate_cents, COALESCE(max_units, 0) as max_units,
+		       COALESCE(features, '{}') as features, COALESCE(is_active, true) as is_active
+		FROM pricing_plans
+		ORDER BY display_order, base_price_cents
+	`
+
+	rows, err := dbFor(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plans: %w", err)
+	}
+	defer rows.Close()
+
+	plans := []models.Plan{}
+	for rows.Next() {
+		var plan models.Plan
+		var features pq.StringArray
+		err := rows.Scan(
+			&plan.ID,
+			&plan.Name,
+			&plan.Description,
+			&plan.BasePriceCents,
+			&plan.IncludedUnits,
+			&plan.OverageRateCents,
+			&plan.MaxUnits,
+			&features,
+			&plan.IsActive,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plan.Features = []string(features)
+		plans = append(plans, plan)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating plans: %w", err)
 	}
 
-	return 0.0
+	return plans, nil
 }
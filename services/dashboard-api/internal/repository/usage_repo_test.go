@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/devwithmohit/billing-system/services/dashboard-api/internal/models"
+)
+
+// TestGetUsageByRegion_Postgres tests the region grouping against a real database
+// A temporary table shadows usage_events, so no migrated schema or data is touched
+func TestGetUsageByRegion_Postgres(t *testing.T) {
+	url := os.Getenv("DASHBOARD_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("DASHBOARD_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // Temporary tables only exist on the connection that created them
+	ctx := context.Background()
+
+	setup := []string{
+		`CREATE TEMP TABLE usage_events (
+			time TIMESTAMPTZ NOT NULL, organization_id TEXT NOT NULL, region TEXT, billable BOOLEAN NOT NULL)`,
+		`INSERT INTO usage_events VALUES
+			(NOW() - INTERVAL '1 hour', 'org_1', 'US', true),
+			(NOW() - INTERVAL '2 days', 'org_1', 'US', false),
+			(NOW() - INTERVAL '1 hour', 'org_1', 'DE', true),
+			(NOW() - INTERVAL '1 hour', 'org_1', 'unknown', true),
+			(NOW() - INTERVAL '1 hour', 'org_1', '', true),
+			(NOW() - INTERVAL '1 hour', 'org_1', NULL, false),
+			(NOW() - INTERVAL '30 days', 'org_1', 'DE', true),
+			(NOW() - INTERVAL '1 hour', 'org_2', 'DE', true)`,
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("Setup %q error = %v", stmt, err)
+		}
+	}
+
+	usage, err := NewUsageRepository(db).GetUsageByRegion(ctx, "org_1", 7)
+	if err != nil {
+		t.Fatalf("GetUsageByRegion() error = %v", err)
+	}
+
+	// Rows written before the processor defaulted regions count as unknown; the 30-day-old
+	// event and the other organization's are left out
+	expected := []models.RegionUsage{
+		{Region: "unknown", Requests: 3, BillableRequests: 2},
+		{Region: "US", Requests: 2, BillableRequests: 1},
+		{Region: "DE", Requests: 1, BillableRequests: 1},
+	}
+	if len(usage.Regions) != len(expected) {
+		t.Fatalf("Regions = %+v, want %+v", usage.Regions, expected)
+	}
+	for i, region := range expected {
+		if usage.Regions[i] != region {
+			t.Errorf("Regions[%d] = %+v, want %+v", i, usage.Regions[i], region)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/saas-gateway/gateway/internal/handler"
 	"github.com/saas-gateway/gateway/internal/middleware"
 	"github.com/saas-gateway/gateway/internal/ratelimit"
+	"github.com/saas-gateway/gateway/internal/tracing"
 
 	_ "github.com/lib/pq"
 )
@@ -30,6 +31,12 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Structured logger for the request path (startup messages stay on the standard logger)
+	logger, err := middleware.NewSlogLogger(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
 	// Initialize PostgreSQL
 	if cfg.DatabaseURL == "" {
 		log.Fatalf("DATABASE_URL environment variable is required")
@@ -66,8 +73,19 @@ func main() {
 	go refreshManager.Start()
 	defer refreshManager.Stop()
 
+	// Evict revoked keys immediately (Postgres LISTEN/NOTIFY)
+	revocationListener, err := database.NewRevocationListener(cfg.DatabaseURL, keyCache)
+	if err != nil {
+		log.Printf("⚠️  Warning: Revocation listener unavailable, revoked keys expire with cache TTL: %v", err)
+	} else {
+		go revocationListener.Start()
+		defer revocationListener.Stop()
+		log.Println("✅ Listening for API key revocations")
+	}
+
 	// Initialize Redis (optional for MVP - graceful degradation)
 	var rateLimitMiddleware *middleware.RateLimit
+	var responseCache *handler.ResponseCache
 	if cfg.RedisAddr != "" {
 		redisClient, err := ratelimit.NewRedisClient(ratelimit.RedisConfig{
 			Addr:     cfg.RedisAddr,
@@ -83,6 +101,12 @@ func main() {
 			limiter := ratelimit.NewRateLimiter(redisClient)
 			rateLimitMiddleware = middleware.NewRateLimit(limiter)
 
+			// Response cache shares the rate limiting Redis connection
+			if cfg.ResponseCacheEnabled {
+				responseCache = handler.NewResponseCache(redisClient, cfg.ResponseCacheTTL)
+				log.Printf("✅ Response cache enabled (TTL: %v)", cfg.ResponseCacheTTL)
+			}
+
 			// Defer close
 			defer redisClient.Close()
 		}
@@ -103,29 +127,69 @@ func main() {
 			BatchSize:     eventCfg.BatchSize,
 			FlushInterval: eventCfg.FlushInterval,
 			BufferSize:    eventCfg.BufferSize,
+
+			Compression:    eventCfg.Compression,
+			LingerMs:       eventCfg.LingerMs,
+			BatchSizeBytes: eventCfg.BatchSizeBytes,
+			Acks:           eventCfg.Acks,
+
+			PartitionKey:     eventCfg.PartitionKey,
+			PartitionBuckets: eventCfg.PartitionBuckets,
+
+			OverflowPolicy:  eventCfg.OverflowPolicy,
+			OverflowTimeout: eventCfg.OverflowTimeout,
+			SpillPath:       eventCfg.SpillPath,
+
+			WALDir:            eventCfg.ProducerWALDir(),
+			WALSegmentBytes:   eventCfg.WALSegmentBytes,
+			ReconnectInterval: eventCfg.ReconnectInterval,
 		})
 		if err != nil {
 			log.Printf("⚠️  Warning: Failed to create Kafka producer: %v", err)
 			log.Println("⚠️  Usage event tracking will be disabled")
 		} else {
-			log.Println("✅ Connected to Kafka for usage tracking")
+			log.Println("✅ Usage tracking enabled")
 			defer eventProducer.Close()
 		}
 	} else {
 		log.Println("ℹ️  Kafka disabled - usage event tracking disabled")
 	}
 
+	// Initialize OpenTelemetry tracing (optional - no-op when disabled)
+	tracingCfg, err := tracing.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load tracing config: %v", err)
+	}
+	tracer, err := tracing.Setup(context.Background(), tracingCfg)
+	if err != nil {
+		log.Printf("⚠️  Warning: Failed to initialize tracing: %v", err)
+		log.Println("⚠️  Distributed tracing will be disabled")
+		tracer = tracing.Disabled()
+	} else if tracingCfg.Enabled {
+		log.Printf("✅ Exporting traces to %s (sample ratio: %v)", tracingCfg.Endpoint, tracingCfg.SampleRatio)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				log.Printf("⚠️  Warning: Failed to flush traces: %v", err)
+			}
+		}()
+	} else {
+		log.Println("ℹ️  TRACING_ENABLED not set - distributed tracing disabled")
+	}
+
 	// Initialize handlers
 	healthHandler := handler.NewHealth()
-	proxyHandler, err := handler.NewProxy(cfg, eventProducer)
+	proxyHandler, err := handler.NewProxy(cfg, eventProducer, responseCache)
 	if err != nil {
 		log.Fatalf("Failed to initialize proxy handler: %v", err)
 	}
+	proxyHandler.UseTracing(tracer.TracerProvider, tracer.Propagator)
 
 	// Initialize middleware
 	authMiddleware := middleware.NewAuth(cfg, keyCache, repo)
-	loggerMiddleware := middleware.NewLogger()
-	recoveryMiddleware := middleware.NewRecovery()
+	loggerMiddleware := middleware.NewLogger(logger)
+	recoveryMiddleware := middleware.NewRecovery(logger)
 
 	// Setup router
 	router := mux.NewRouter()
@@ -146,9 +210,10 @@ func main() {
 
 	apiRouter.PathPrefix("/").Handler(proxyHandler)
 
-	// Apply global middleware (order matters: recovery -> logging -> routes)
-	handler := recoveryMiddleware.Middleware(
-		loggerMiddleware.Middleware(router),
+	// Apply global middleware (order matters: logging -> recovery -> routes)
+	// Logging sits outside recovery so recovered panics are logged with their 500 status
+	handler := loggerMiddleware.Middleware(
+		recoveryMiddleware.Middleware(router),
 	)
 
 	// Create HTTP server
@@ -161,6 +226,11 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Accept cleartext HTTP/2 (h2c) alongside HTTP/1.1 so gRPC clients can connect
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("🚀 Gateway server starting on http://localhost%s", addr)
@@ -185,10 +255,10 @@ func main() {
 	log.Println("🛑 Shutting down server...")
 
 	// Graceful shutdown with 30 second timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
@@ -1,8 +1,11 @@
 package cache
 
 import (
+	"net/netip"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // RateLimitConfig represents rate limit configuration for an organization
@@ -14,9 +17,52 @@ type RateLimitConfig struct {
 
 // CachedKey represents a cached API key with its associated data
 type CachedKey struct {
-	OrganizationID  string
-	RateLimitConfig RateLimitConfig
-	ExpiresAt       time.Time
+	KeyID            uuid.UUID // api_keys.id, attributed to the key's usage events
+	OrganizationID   string
+	RateLimitConfig  RateLimitConfig
+	Scopes           []string       // Permission scopes granted to the key (e.g. read:users, *)
+	KeyExpiresAt     *time.Time     // API key expiration (nil = never expires)
+	RotationEndsAt   *time.Time     // Rotated key stops working at this time (nil = not rotating)
+	AllowedCIDRs     []netip.Prefix // Source IP ranges the key may be used from (empty = any)
+	UsageCappedUntil *time.Time     // Org exhausted its plan's hard cap until this time (nil = not capped)
+	OrgStatus        string         // organizations.status: active or suspended ("" = active)
+	PlanTier         string         // organizations.plan_tier: basic, premium or enterprise
+	ExpiresAt        time.Time      // Cache entry expiration (TTL)
+}
+
+// IsKeyExpired checks if the API key itself has passed its expiration time
+func (k *CachedKey) IsKeyExpired(now time.Time) bool {
+	return k.KeyExpiresAt != nil && !now.Before(*k.KeyExpiresAt)
+}
+
+// IsRotationOver checks if a rotated key's grace period has ended
+// The cleanup job revokes such keys, but a cached entry must not outlive the grace period
+func (k *CachedKey) IsRotationOver(now time.Time) bool {
+	return k.RotationEndsAt != nil && !now.Before(*k.RotationEndsAt)
+}
+
+// IsOrgSuspended checks if the key's organization is suspended (e.g. for non-payment)
+func (k *CachedKey) IsOrgSuspended() bool {
+	return k.OrgStatus != "" && k.OrgStatus != "active"
+}
+
+// AllowsIP checks if the key may be used from addr
+// An invalid (zero) prefix matches nothing, so a malformed allowlist entry fails closed
+func (k *CachedKey) AllowsIP(addr netip.Addr) bool {
+	if len(k.AllowedCIDRs) == 0 {
+		return true
+	}
+	for _, prefix := range k.AllowedCIDRs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUsageCapped checks if the organization is blocked for exceeding its plan's hard cap
+func (k *CachedKey) IsUsageCapped(now time.Time) bool {
+	return k.UsageCappedUntil != nil && now.Before(*k.UsageCappedUntil)
 }
 
 // APIKeyCache provides thread-safe in-memory caching for API keys
@@ -66,9 +112,12 @@ func (c *APIKeyCache) Invalidate(keyHash string) {
 }
 
 // Clear removes all entries from the cache
-// Useful for testing or forced cache refresh
+// Useful for testing or forced cache refresh (safe for concurrent use)
 func (c *APIKeyCache) Clear() {
-	c.data = sync.Map{}
+	c.data.Range(func(key, _ interface{}) bool {
+		c.data.Delete(key)
+		return true
+	})
 }
 
 // Size returns the approximate number of entries in the cache
@@ -90,7 +139,7 @@ func (c *APIKeyCache) CleanExpired() int {
 
 	c.data.Range(func(key, value interface{}) bool {
 		cached := value.(*CachedKey)
-		if now.After(cached.ExpiresAt) {
+		if now.After(cached.ExpiresAt) || cached.IsKeyExpired(now) {
 			c.data.Delete(key)
 			removed++
 		}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultBillableStatuses bills successful requests and client errors, but not server errors
+const DefaultBillableStatuses = "200-499"
+
+var defaultBillableStatuses = StatusRanges{{Min: 200, Max: 499}}
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// StatusRanges is a set of status code ranges, e.g. the statuses billed to a customer
+type StatusRanges []StatusRange
+
+// Contains reports whether statusCode falls in any of the ranges
+func (r StatusRanges) Contains(statusCode int) bool {
+	for _, sr := range r {
+		if statusCode >= sr.Min && statusCode <= sr.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStatusRanges parses status codes and inclusive ranges separated by sep
+// Example: "200-428,430-499" or a single code such as "404". "none" is the empty set
+func ParseStatusRanges(value, sep string) (StatusRanges, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "none") {
+		return StatusRanges{}, nil
+	}
+
+	ranges := make(StatusRanges, 0)
+	for _, entry := range strings.Split(value, sep) {
+		entry = strings.TrimSpace(entry)
+		minStr, maxStr, isRange := strings.Cut(entry, "-")
+		if !isRange {
+			maxStr = minStr
+		}
+
+		min, minErr := strconv.Atoi(strings.TrimSpace(minStr))
+		max, maxErr := strconv.Atoi(strings.TrimSpace(maxStr))
+		if minErr != nil || maxErr != nil {
+			return nil, fmt.Errorf("invalid status range (expected 'code' or 'min-max'): %q", entry)
+		}
+		if min < 100 || max > 599 || min > max {
+			return nil, fmt.Errorf("status range must be within 100-599 with min <= max: %q", entry)
+		}
+		ranges = append(ranges, StatusRange{Min: min, Max: max})
+	}
+
+	return ranges, nil
+}
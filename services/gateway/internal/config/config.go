@@ -2,20 +2,88 @@ package config
 
 import (
 	"fmt"
+	"net/http"
+	"net/netip"
 	"os"
 	"strings"
+	"time"
 )
 
 // Config holds all gateway configuration
 type Config struct {
 	Port        string
-	LogLevel    string
+	LogLevel    string // debug, info, warn, error
+	LogFormat   string // json or text
 	BackendURLs map[string]string // service_name -> URL
 	APIKeys     map[string]*APIKeyConfig
 	RedisAddr   string
 	RedisPassword string
 	RedisDB     int
 	DatabaseURL string
+
+	// Circuit breaker settings (per backend service)
+	BreakerFailureThreshold int
+	BreakerWindow           time.Duration
+	BreakerCooldown         time.Duration
+
+	// Response cache settings (GET responses, requires Redis)
+	ResponseCacheEnabled bool
+	ResponseCacheTTL     time.Duration
+
+	// Headers (checked in order) carrying the client region set by the CDN
+	RegionHeaders []string
+
+	// Route -> required API key scope (empty disables scope enforcement)
+	RouteScopes []RouteScope
+
+	// Per-backend header rules (service_name -> rules)
+	HeaderTransforms map[string]*HeaderTransform
+
+	// Plan tier -> record 1 in N non-billable usage events (billable events are always recorded)
+	UsageSamplingRates map[string]int
+
+	// Response statuses billed to the customer: the global policy plus per-plan overrides (plan_tier -> ranges)
+	BillableStatuses     StatusRanges
+	PlanBillableStatuses map[string]StatusRanges
+
+	// Proxy retries (idempotent methods, or requests with an Idempotency-Key header)
+	ProxyRetryMax          int           // Retries after the first attempt (0 disables retries)
+	ProxyRetryBackoff      time.Duration // Delay before the first retry, doubled for each further retry
+	ProxyRetryMaxBackoff   time.Duration
+	ProxyRetryStatuses     []int // Backend statuses retried in addition to connection errors
+	ProxyRetryMaxBodyBytes int64 // Larger request bodies are not buffered, so not retried
+
+	// Backend HTTP transport settings: defaults plus per-backend overrides (service_name -> settings)
+	BackendTransport  BackendTransport
+	BackendTransports map[string]*BackendTransport
+
+	// Proxies whose X-Forwarded-For is believed when checking API key IP allowlists
+	TrustedProxies []netip.Prefix
+}
+
+// BackendTransport tunes the dedicated http.Transport of one backend
+// Zero values keep the net/http defaults (no dial or response header timeout)
+type BackendTransport struct {
+	DialTimeout           time.Duration // TCP connect timeout
+	ResponseHeaderTimeout time.Duration // Wait for response headers after the request is written (504 on expiry)
+	MaxIdleConns          int           // Idle connection pool size
+	MaxIdleConnsPerHost   int           // Idle connections kept per backend host
+}
+
+// HeaderTransform defines header rules applied to one backend's traffic
+// Request values may use placeholders: {org_id}, {request_id}, {plan_tier}
+type HeaderTransform struct {
+	SetRequestHeaders     map[string]string // Injected (or overwritten) before proxying
+	RemoveRequestHeaders  []string          // Stripped before proxying
+	SetResponseHeaders    map[string]string // Added to the backend response
+	RemoveResponseHeaders []string          // Stripped before returning to the client
+}
+
+// RouteScope maps a method and path prefix to the scope a key needs to call it
+type RouteScope struct {
+	Method     string // HTTP method, or "*" for any method
+	PathPrefix string
+	Scope      string // e.g. read:users, write:billing
 }
 
 // APIKeyConfig represents a temporary hardcoded API key configuration
@@ -30,12 +98,45 @@ func Load() (*Config, error) {
 	cfg := &Config{
 		Port:          getEnv("GATEWAY_PORT", "8080"),
 		LogLevel:      getEnv("LOG_LEVEL", "info"),
+		LogFormat:     getEnv("LOG_FORMAT", "json"),
 		BackendURLs:   make(map[string]string),
 		APIKeys:       make(map[string]*APIKeyConfig),
 		RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       getEnvInt("REDIS_DB", 0),
 		DatabaseURL:   os.Getenv("DATABASE_URL"),
+
+		BreakerFailureThreshold: getEnvInt("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerWindow:           getEnvDuration("BREAKER_WINDOW", 30*time.Second),
+		BreakerCooldown:         getEnvDuration("BREAKER_COOLDOWN", 30*time.Second),
+
+		ResponseCacheEnabled: getEnv("RESPONSE_CACHE_ENABLED", "false") == "true",
+		ResponseCacheTTL:     getEnvDuration("RESPONSE_CACHE_TTL", 60*time.Second),
+
+		RegionHeaders: getEnvList("REGION_HEADERS", []string{"CF-IPCountry", "CloudFront-Viewer-Country"}),
+
+		ProxyRetryMax:          getEnvInt("PROXY_RETRY_MAX", 2),
+		ProxyRetryBackoff:      getEnvDuration("PROXY_RETRY_BACKOFF", 100*time.Millisecond),
+		ProxyRetryMaxBackoff:   getEnvDuration("PROXY_RETRY_MAX_BACKOFF", 2*time.Second),
+		ProxyRetryMaxBodyBytes: int64(getEnvInt("PROXY_RETRY_MAX_BODY_BYTES", 1<<20)),
+
+		BackendTransport: BackendTransport{
+			DialTimeout:           getEnvDuration("BACKEND_DIAL_TIMEOUT", 5*time.Second),
+			ResponseHeaderTimeout: getEnvDuration("BACKEND_RESPONSE_HEADER_TIMEOUT", 10*time.Second),
+			MaxIdleConns:          getEnvInt("BACKEND_MAX_IDLE_CONNS", 100),
+			MaxIdleConnsPerHost:   getEnvInt("BACKEND_MAX_IDLE_CONNS_PER_HOST", 10),
+		},
+	}
+
+	switch strings.ToLower(cfg.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		return nil, fmt.Errorf("invalid LOG_LEVEL (expected debug, info, warn or error): %s", cfg.LogLevel)
+	}
+	switch strings.ToLower(cfg.LogFormat) {
+	case "json", "text":
+	default:
+		return nil, fmt.Errorf("invalid LOG_FORMAT (expected json or text): %s", cfg.LogFormat)
 	}
 
 	// Parse backend URLs
@@ -70,9 +171,323 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// Parse route scope requirements (optional)
+	routeScopes, err := parseRouteScopes(os.Getenv("ROUTE_SCOPES"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.RouteScopes = routeScopes
+
+	// Parse per-backend header transforms (optional)
+	headerTransforms, err := parseHeaderTransforms(os.Getenv("HEADER_TRANSFORMS"))
+	if err != nil {
+		return nil, err
+	}
+	for serviceName := range headerTransforms {
+		if _, exists := cfg.BackendURLs[serviceName]; !exists {
+			return nil, fmt.Errorf("HEADER_TRANSFORMS references unknown backend: %s", serviceName)
+		}
+	}
+	cfg.HeaderTransforms = headerTransforms
+
+	// Parse per-plan usage event sampling (optional)
+	samplingRates, err := parseUsageSamplingRates(os.Getenv("USAGE_SAMPLING_RATES"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.UsageSamplingRates = samplingRates
+
+	// Parse the billable status policy (default: 2xx and 4xx; 5xx are our fault)
+	billableStatuses, err := ParseStatusRanges(getEnv("BILLABLE_STATUS_CODES", DefaultBillableStatuses), ",")
+	if err != nil {
+		return nil, fmt.Errorf("invalid BILLABLE_STATUS_CODES: %w", err)
+	}
+	cfg.BillableStatuses = billableStatuses
+
+	planBillableStatuses, err := parsePlanBillableStatuses(os.Getenv("PLAN_BILLABLE_STATUS_CODES"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.PlanBillableStatuses = planBillableStatuses
+
+	// Parse retryable backend statuses
+	retryStatuses, err := parseRetryStatuses(getEnvList("PROXY_RETRY_STATUSES", []string{"502", "503"}))
+	if err != nil {
+		return nil, err
+	}
+	cfg.ProxyRetryStatuses = retryStatuses
+	if cfg.ProxyRetryMax < 0 {
+		return nil, fmt.Errorf("PROXY_RETRY_MAX must not be negative: %d", cfg.ProxyRetryMax)
+	}
+
+	// Parse per-backend transport overrides (optional)
+	backendTransports, err := parseBackendTransports(os.Getenv("BACKEND_TRANSPORT"), cfg.BackendTransport)
+	if err != nil {
+		return nil, err
+	}
+	for serviceName := range backendTransports {
+		if _, exists := cfg.BackendURLs[serviceName]; !exists {
+			return nil, fmt.Errorf("BACKEND_TRANSPORT references unknown backend: %s", serviceName)
+		}
+	}
+	cfg.BackendTransports = backendTransports
+
+	// Parse trusted proxies (optional; without them X-Forwarded-For is ignored for allowlists)
+	trustedProxies, err := parseTrustedProxies(getEnvList("TRUSTED_PROXIES", nil))
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrustedProxies = trustedProxies
+
 	return cfg, nil
 }
 
+// parseTrustedProxies parses TRUSTED_PROXIES (CIDRs or single addresses, IPv4 or IPv6)
+// Example: 10.0.0.0/8,fd00::/8,192.0.2.10
+func parseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry (expected CIDR or IP): %s", value)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// parseRetryStatuses parses PROXY_RETRY_STATUSES (5xx codes only; 4xx means the request itself is wrong)
+// Example: 502,503,504
+func parseRetryStatuses(values []string) ([]int, error) {
+	statuses := make([]int, 0, len(values))
+	for _, value := range values {
+		var status int
+		if _, err := fmt.Sscanf(value, "%d", &status); err != nil || status < 500 || status > 599 {
+			return nil, fmt.Errorf("invalid PROXY_RETRY_STATUSES status (expected 5xx): %s", value)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// parseBackendTransports parses BACKEND_TRANSPORT overrides on top of the defaults
+// Format: "service:setting=value" (comma-separated)
+// Settings: dial_timeout, response_header_timeout, max_idle_conns, max_idle_conns_per_host
+// Example: reports-api:response_header_timeout=60s,reports-api:max_idle_conns_per_host=50
+func parseBackendTransports(value string, defaults BackendTransport) (map[string]*BackendTransport, error) {
+	transports := make(map[string]*BackendTransport)
+	if strings.TrimSpace(value) == "" {
+		return transports, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		serviceName, setting, _ := strings.Cut(entry, ":")
+		name, settingValue, ok := strings.Cut(setting, "=")
+		if serviceName == "" || !ok || settingValue == "" {
+			return nil, fmt.Errorf("invalid BACKEND_TRANSPORT format (expected 'service:setting=value'): %s", entry)
+		}
+
+		transport, exists := transports[serviceName]
+		if !exists {
+			settings := defaults
+			transport = &settings
+			transports[serviceName] = transport
+		}
+
+		var err error
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "dial_timeout":
+			transport.DialTimeout, err = time.ParseDuration(settingValue)
+		case "response_header_timeout":
+			transport.ResponseHeaderTimeout, err = time.ParseDuration(settingValue)
+		case "max_idle_conns":
+			_, err = fmt.Sscanf(settingValue, "%d", &transport.MaxIdleConns)
+		case "max_idle_conns_per_host":
+			_, err = fmt.Sscanf(settingValue, "%d", &transport.MaxIdleConnsPerHost)
+		default:
+			return nil, fmt.Errorf("unknown BACKEND_TRANSPORT setting: %s", entry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid BACKEND_TRANSPORT value: %s: %w", entry, err)
+		}
+		if transport.DialTimeout < 0 || transport.ResponseHeaderTimeout < 0 || transport.MaxIdleConns < 0 || transport.MaxIdleConnsPerHost < 0 {
+			return nil, fmt.Errorf("BACKEND_TRANSPORT values must not be negative: %s", entry)
+		}
+	}
+
+	return transports, nil
+}
+
+// parseUsageSamplingRates parses USAGE_SAMPLING_RATES entries
+// Format: "plan_tier=N" (comma-separated), recording 1 in N non-billable events; N=1 records all
+// Example: free=10
+func parseUsageSamplingRates(value string) (map[string]int, error) {
+	rates := make(map[string]int)
+	if strings.TrimSpace(value) == "" {
+		return rates, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		tier, rateStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		tier = strings.TrimSpace(tier)
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid USAGE_SAMPLING_RATES format (expected 'plan_tier=N'): %s", entry)
+		}
+
+		var rate int
+		if _, err := fmt.Sscanf(strings.TrimSpace(rateStr), "%d", &rate); err != nil || rate < 1 {
+			return nil, fmt.Errorf("invalid USAGE_SAMPLING_RATES rate (must be an integer >= 1): %s", entry)
+		}
+		rates[tier] = rate
+	}
+
+	return rates, nil
+}
+
+// parsePlanBillableStatuses parses PLAN_BILLABLE_STATUS_CODES entries
+// Format: "plan_tier=ranges" (comma-separated), with the plan's ranges separated by "|"
+// Example: enterprise=200-403|405-428|430-499
+func parsePlanBillableStatuses(value string) (map[string]StatusRanges, error) {
+	policies := make(map[string]StatusRanges)
+	if strings.TrimSpace(value) == "" {
+		return policies, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		tier, ranges, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		tier = strings.TrimSpace(tier)
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid PLAN_BILLABLE_STATUS_CODES format (expected 'plan_tier=ranges'): %s", entry)
+		}
+
+		statuses, err := ParseStatusRanges(ranges, "|")
+		if err != nil {
+			return nil, fmt.Errorf("invalid PLAN_BILLABLE_STATUS_CODES for %s: %w", tier, err)
+		}
+		policies[tier] = statuses
+	}
+
+	return policies, nil
+}
+
+// parseHeaderTransforms parses HEADER_TRANSFORMS entries
+// Format: "service:request|response:set|remove:Header[=value]" (comma-separated)
+// Example: users-api:request:set:X-API-Version=2024-01,users-api:response:remove:X-Powered-By
+func parseHeaderTransforms(value string) (map[string]*HeaderTransform, error) {
+	transforms := make(map[string]*HeaderTransform)
+	if strings.TrimSpace(value) == "" {
+		return transforms, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, ":", 4)
+		if len(parts) != 4 || parts[0] == "" || parts[3] == "" {
+			return nil, fmt.Errorf("invalid HEADER_TRANSFORMS format (expected 'service:request|response:set|remove:Header[=value]'): %s", entry)
+		}
+
+		serviceName, direction, action := parts[0], strings.ToLower(parts[1]), strings.ToLower(parts[2])
+		header, headerValue, hasValue := strings.Cut(parts[3], "=")
+		header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if header == "" {
+			return nil, fmt.Errorf("invalid HEADER_TRANSFORMS header name: %s", entry)
+		}
+
+		rules, exists := transforms[serviceName]
+		if !exists {
+			rules = &HeaderTransform{
+				SetRequestHeaders:  make(map[string]string),
+				SetResponseHeaders: make(map[string]string),
+			}
+			transforms[serviceName] = rules
+		}
+
+		switch {
+		case action == "set" && !hasValue:
+			return nil, fmt.Errorf("HEADER_TRANSFORMS set rule requires a value (Header=value): %s", entry)
+		case action == "remove" && hasValue:
+			return nil, fmt.Errorf("HEADER_TRANSFORMS remove rule takes no value: %s", entry)
+		case direction == "request" && action == "set":
+			rules.SetRequestHeaders[header] = headerValue
+		case direction == "request" && action == "remove":
+			rules.RemoveRequestHeaders = append(rules.RemoveRequestHeaders, header)
+		case direction == "response" && action == "set":
+			rules.SetResponseHeaders[header] = headerValue
+		case direction == "response" && action == "remove":
+			rules.RemoveResponseHeaders = append(rules.RemoveResponseHeaders, header)
+		default:
+			return nil, fmt.Errorf("invalid HEADER_TRANSFORMS rule (direction must be request|response, action set|remove): %s", entry)
+		}
+	}
+
+	return transforms, nil
+}
+
+// parseRouteScopes parses ROUTE_SCOPES entries
+// Format: "METHOD /path/prefix=scope" (comma-separated), METHOD may be "*" or omitted
+// Example: GET /api/users=read:users,POST /api/billing=write:billing
+func parseRouteScopes(value string) ([]RouteScope, error) {
+	var rules []RouteScope
+	if strings.TrimSpace(value) == "" {
+		return rules, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid ROUTE_SCOPES format (expected 'METHOD /path=scope'): %s", entry)
+		}
+
+		rule := RouteScope{Method: "*", Scope: strings.TrimSpace(parts[1])}
+		route := strings.Fields(parts[0])
+		switch len(route) {
+		case 1:
+			rule.PathPrefix = route[0]
+		case 2:
+			rule.Method = strings.ToUpper(route[0])
+			rule.PathPrefix = route[1]
+		default:
+			return nil, fmt.Errorf("invalid ROUTE_SCOPES route: %s", parts[0])
+		}
+
+		if !strings.HasPrefix(rule.PathPrefix, "/") {
+			return nil, fmt.Errorf("invalid ROUTE_SCOPES path (must start with /): %s", rule.PathPrefix)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// RequiredScope returns the scope needed for a request, using the longest matching path prefix
+// Returns false when no rule matches (the route is open to any valid key)
+func (c *Config) RequiredScope(method, path string) (string, bool) {
+	var match *RouteScope
+	for i := range c.RouteScopes {
+		rule := &c.RouteScopes[i]
+		if rule.Method != "*" && rule.Method != method {
+			continue
+		}
+		if !strings.HasPrefix(path, rule.PathPrefix) {
+			continue
+		}
+		if match == nil || len(rule.PathPrefix) > len(match.PathPrefix) {
+			match = rule
+		}
+	}
+
+	if match == nil {
+		return "", false
+	}
+	return match.Scope, true
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -93,6 +508,32 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvList retrieves a comma-separated environment variable or returns a default value
+func getEnvList(key string, defaultValue []string) []string {
+	value, set := os.LookupEnv(key)
+	if !set {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvDuration retrieves a duration environment variable or returns a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
 // GetDefaultBackend returns the first backend URL (used when no specific service is requested)
 func (c *Config) GetDefaultBackend() string {
 	for _, url := range c.BackendURLs {
@@ -106,3 +547,23 @@ func (c *Config) GetBackendForService(serviceName string) (string, bool) {
 	url, exists := c.BackendURLs[serviceName]
 	return url, exists
 }
+
+// BillableStatusesFor returns the billable status policy of a plan tier (its override, or the global policy)
+// A config without a global policy bills DefaultBillableStatuses
+func (c *Config) BillableStatusesFor(planTier string) StatusRanges {
+	if statuses, exists := c.PlanBillableStatuses[planTier]; exists {
+		return statuses
+	}
+	if c.BillableStatuses == nil {
+		return defaultBillableStatuses
+	}
+	return c.BillableStatuses
+}
+
+// TransportFor returns the transport settings of a backend (its overrides, or the defaults)
+func (c *Config) TransportFor(serviceName string) BackendTransport {
+	if transport, exists := c.BackendTransports[serviceName]; exists {
+		return *transport
+	}
+	return c.BackendTransport
+}
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRouteScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []RouteScope
+		wantErr  bool
+	}{
+		{"Empty", "", nil, false},
+		{
+			"Method and path",
+			"GET /api/users=read:users,POST /api/billing=write:billing",
+			[]RouteScope{
+				{Method: "GET", PathPrefix: "/api/users", Scope: "read:users"},
+				{Method: "POST", PathPrefix: "/api/billing", Scope: "write:billing"},
+			},
+			false,
+		},
+		{
+			"Path only matches any method",
+			"/api/admin=admin",
+			[]RouteScope{{Method: "*", PathPrefix: "/api/admin", Scope: "admin"}},
+			false,
+		},
+		{"Missing scope", "GET /api/users=", nil, true},
+		{"Missing separator", "GET /api/users", nil, true},
+		{"Relative path", "GET api/users=read:users", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRouteScopes(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRouteScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(rules) != len(tt.expected) {
+				t.Fatalf("parseRouteScopes() = %v, want %v", rules, tt.expected)
+			}
+			for i := range tt.expected {
+				if rules[i] != tt.expected[i] {
+					t.Errorf("Rule %d = %+v, want %+v", i, rules[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	cfg := &Config{
+		RouteScopes: []RouteScope{
+			{Method: "GET", PathPrefix: "/api/users", Scope: "read:users"},
+			{Method: "*", PathPrefix: "/api/users/admin", Scope: "admin"},
+			{Method: "POST", PathPrefix: "/api/billing", Scope: "write:billing"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected string
+		found    bool
+	}{
+		{"Exact prefix", "GET", "/api/users", "read:users", true},
+		{"Nested path", "GET", "/api/users/123", "read:users", true},
+		{"Longest prefix wins", "GET", "/api/users/admin/settings", "admin", true},
+		{"Wildcard method", "DELETE", "/api/users/admin", "admin", true},
+		{"Method mismatch", "GET", "/api/billing", "", false},
+		{"Unmapped route", "GET", "/api/orders", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, found := cfg.RequiredScope(tt.method, tt.path)
+			if scope != tt.expected || found != tt.found {
+				t.Errorf("RequiredScope(%s, %s) = (%q, %v), want (%q, %v)", tt.method, tt.path, scope, found, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestParseHeaderTransforms(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		check   func(t *testing.T, transforms map[string]*HeaderTransform)
+		wantErr bool
+	}{
+		{"Empty", "", func(t *testing.T, transforms map[string]*HeaderTransform) {
+			if len(transforms) != 0 {
+				t.Errorf("Expected no transforms, got %v", transforms)
+			}
+		}, false},
+		{
+			"Request set and response remove",
+			"users-api:request:set:x-api-version=2024-01, users-api:response:remove:X-Powered-By",
+			func(t *testing.T, transforms map[string]*HeaderTransform) {
+				rules := transforms["users-api"]
+				if rules == nil {
+					t.Fatal("Expected rules for users-api")
+				}
+				if got := rules.SetRequestHeaders["X-Api-Version"]; got != "2024-01" {
+					t.Errorf("SetRequestHeaders[X-Api-Version] = %q, want 2024-01", got)
+				}
+				if len(rules.RemoveResponseHeaders) != 1 || rules.RemoveResponseHeaders[0] != "X-Powered-By" {
+					t.Errorf("RemoveResponseHeaders = %v, want [X-Powered-By]", rules.RemoveResponseHeaders)
+				}
+			},
+			false,
+		},
+		{
+			"Value with placeholder and equals sign",
+			"billing:request:set:X-Tenant=org={org_id}",
+			func(t *testing.T, transforms map[string]*HeaderTransform) {
+				if got := transforms["billing"].SetRequestHeaders["X-Tenant"]; got != "org={org_id}" {
+					t.Errorf("SetRequestHeaders[X-Tenant] = %q, want org={org_id}", got)
+				}
+			},
+			false,
+		},
+		{"Set without value", "users-api:request:set:X-API-Version", nil, true},
+		{"Remove with value", "users-api:response:remove:Server=nginx", nil, true},
+		{"Unknown direction", "users-api:both:set:X-A=1", nil, true},
+		{"Missing fields", "users-api:request:X-A=1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transforms, err := parseHeaderTransforms(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHeaderTransforms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, transforms)
+			}
+		})
+	}
+}
+
+func TestParseUsageSamplingRates(t *testing.T) {
+	rates, err := parseUsageSamplingRates("free=10, basic=2")
+	if err != nil {
+		t.Fatalf("parseUsageSamplingRates() error = %v", err)
+	}
+	if rates["free"] != 10 || rates["basic"] != 2 {
+		t.Errorf("Rates = %v, want free=10 basic=2", rates)
+	}
+
+	if rates, err := parseUsageSamplingRates(""); err != nil || len(rates) != 0 {
+		t.Errorf("Empty value = %v, %v, want no rates", rates, err)
+	}
+
+	for _, value := range []string{"free", "free=0", "free=-1", "=10", "free=ten"} {
+		if _, err := parseUsageSamplingRates(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParseBackendTransports(t *testing.T) {
+	defaults := BackendTransport{DialTimeout: 5 * time.Second, ResponseHeaderTimeout: 10 * time.Second, MaxIdleConns: 100, MaxIdleConnsPerHost: 10}
+
+	transports, err := parseBackendTransports("reports-api:response_header_timeout=60s, reports-api:max_idle_conns_per_host=50", defaults)
+	if err != nil {
+		t.Fatalf("parseBackendTransports() error = %v", err)
+	}
+	want := BackendTransport{DialTimeout: 5 * time.Second, ResponseHeaderTimeout: 60 * time.Second, MaxIdleConns: 100, MaxIdleConnsPerHost: 50}
+	if got := transports["reports-api"]; got == nil || *got != want {
+		t.Errorf("reports-api = %+v, want %+v", got, want)
+	}
+
+	cfg := &Config{BackendTransport: defaults, BackendTransports: transports}
+	if got := cfg.TransportFor("users-api"); got != defaults {
+		t.Errorf("TransportFor(users-api) = %+v, want defaults", got)
+	}
+
+	for _, value := range []string{"reports-api", "reports-api:timeout=1s", "reports-api:dial_timeout=soon", "reports-api:max_idle_conns=-1", ":dial_timeout=1s"} {
+		if _, err := parseBackendTransports(value, defaults); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParseRetryStatuses(t *testing.T) {
+	statuses, err := parseRetryStatuses([]string{"502", "503", "504"})
+	if err != nil || len(statuses) != 3 || statuses[0] != 502 {
+		t.Errorf("parseRetryStatuses() = %v, %v, want [502 503 504]", statuses, err)
+	}
+
+	for _, value := range []string{"404", "600", "bad"} {
+		if _, err := parseRetryStatuses([]string{value}); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10", "fd00::/8", "2001:db8::1", "172.16.5.4/12"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() error = %v", err)
+	}
+
+	expected := []string{"10.0.0.0/8", "192.0.2.10/32", "fd00::/8", "2001:db8::1/128", "172.16.0.0/12"}
+	if len(prefixes) != len(expected) {
+		t.Fatalf("Got %d prefixes, want %d", len(prefixes), len(expected))
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != expected[i] {
+			t.Errorf("Prefix %d = %s, want %s", i, prefix, expected[i])
+		}
+	}
+
+	for _, value := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0"} {
+		if _, err := parseTrustedProxies([]string{value}); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	ranges, err := ParseStatusRanges("200-428, 430-499, 503", ",")
+	if err != nil {
+		t.Fatalf("ParseStatusRanges() error = %v", err)
+	}
+	want := StatusRanges{{200, 428}, {430, 499}, {503, 503}}
+	if len(ranges) != len(want) {
+		t.Fatalf("Ranges = %v, want %v", ranges, want)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("Range %d = %v, want %v", i, ranges[i], want[i])
+		}
+	}
+
+	if ranges, err := ParseStatusRanges("none", ","); err != nil || ranges == nil || len(ranges) != 0 {
+		t.Errorf("none = %v, %v, want an empty policy", ranges, err)
+	}
+
+	for _, value := range []string{"", "2xx", "200-", "499-200", "99", "200-600", "200-299,"} {
+		if _, err := ParseStatusRanges(value, ","); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParsePlanBillableStatuses(t *testing.T) {
+	policies, err := parsePlanBillableStatuses("enterprise=200-403|405-428|430-499, basic=200-299")
+	if err != nil {
+		t.Fatalf("parsePlanBillableStatuses() error = %v", err)
+	}
+	if len(policies["enterprise"]) != 3 || len(policies["basic"]) != 1 {
+		t.Errorf("Policies = %v, want 3 enterprise ranges and 1 basic range", policies)
+	}
+
+	if policies, err := parsePlanBillableStatuses(""); err != nil || len(policies) != 0 {
+		t.Errorf("Empty value = %v, %v, want no policies", policies, err)
+	}
+
+	for _, value := range []string{"enterprise", "=200-299", "basic=2xx", "basic=200-299,premium"} {
+		if _, err := parsePlanBillableStatuses(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+// TestBillableStatusesFor tests several billing policies against a range of status codes
+func TestBillableStatusesFor(t *testing.T) {
+	mustParse := func(value, sep string) StatusRanges {
+		ranges, err := ParseStatusRanges(value, sep)
+		if err != nil {
+			t.Fatalf("ParseStatusRanges(%q) error = %v", value, err)
+		}
+		return ranges
+	}
+
+	cfg := &Config{
+		BillableStatuses: mustParse(DefaultBillableStatuses, ","),
+		PlanBillableStatuses: map[string]StatusRanges{
+			"enterprise": mustParse("200-403|405-428|430-499", "|"), // Not billed for 404s or backend 429s
+			"premium":    mustParse("200-299", "|"),                 // Successful requests only
+			"trial":      mustParse("none", "|"),                    // Nothing billed
+		},
+	}
+
+	codes := []int{101, 200, 201, 304, 400, 404, 429, 499, 500, 503}
+	tests := []struct {
+		planTier string
+		billed   []int
+	}{
+		{"basic", []int{200, 201, 304, 400, 404, 429, 499}}, // Global default
+		{"enterprise", []int{200, 201, 304, 400, 499}},
+		{"premium", []int{200, 201}},
+		{"trial", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.planTier, func(t *testing.T) {
+			billed := make(map[int]bool)
+			for _, code := range tt.billed {
+				billed[code] = true
+			}
+
+			policy := cfg.BillableStatusesFor(tt.planTier)
+			for _, code := range codes {
+				if got := policy.Contains(code); got != billed[code] {
+					t.Errorf("%s bills %d = %v, want %v", tt.planTier, code, got, billed[code])
+				}
+			}
+		})
+	}
+
+	// A config without a policy keeps the historical 2xx-4xx behavior
+	empty := &Config{}
+	if !empty.BillableStatusesFor("basic").Contains(404) || empty.BillableStatusesFor("basic").Contains(500) {
+		t.Error("Expected an unconfigured policy to bill 2xx-4xx only")
+	}
+}
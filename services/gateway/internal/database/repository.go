@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"net/netip"
 	"time"
 
-	"gateway/internal/cache"
-
-	_ "github.com/lib/pq"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/saas-gateway/gateway/internal/cache"
 )
 
 // Repository handles database operations for API keys
@@ -22,19 +24,34 @@ func NewRepository(db *sql.DB) *Repository {
 }
 
 // FetchAllAPIKeys retrieves all active API keys from PostgreSQL
+// Keys being rotated are included until their grace period ends, and keys of suspended
+// organizations are included so auth can answer 402 rather than 401
 // Implements cache.KeyFetcher interface
 func (r *Repository) FetchAllAPIKeys(ctx context.Context) (map[string]*cache.CachedKey, error) {
 	query := `
 		SELECT
 			ak.key_hash,
+			ak.id,
 			ak.organization_id,
+			COALESCE(ak.scopes, ARRAY[]::TEXT[]) as scopes,
+			COALESCE(ak.allowed_cidrs, ARRAY[]::TEXT[]) as allowed_cidrs,
+			ak.expires_at,
+			ak.rotation_expires_at,
+			rls.capped_until,
+			COALESCE(o.status, 'active') as organization_status,
+			COALESCE(o.plan_tier, 'basic') as plan_tier,
 			COALESCE(rl.requests_per_minute, 60) as requests_per_minute,
 			COALESCE(rl.requests_per_day, 10000) as requests_per_day,
 			COALESCE(rl.burst_size, 10) as burst_size
 		FROM api_keys ak
+		LEFT JOIN organizations o ON ak.organization_id = o.id
 		LEFT JOIN rate_limit_configs rl ON ak.organization_id = rl.organization_id
+		LEFT JOIN rate_limit_state rls ON ak.organization_id = rls.organization_id
+			AND rls.capped_until > NOW()
 		WHERE ak.is_active = true
 		  AND ak.revoked_at IS NULL
+		  AND (ak.expires_at IS NULL OR ak.expires_at > NOW())
+		  AND (ak.rotation_expires_at IS NULL OR ak.rotation_expires_at > NOW())
 	`
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -46,22 +63,33 @@ func (r *Repository) FetchAllAPIKeys(ctx context.Context) (map[string]*cache.Cac
 	keys := make(map[string]*cache.CachedKey)
 
 	for rows.Next() {
-		var keyHash, orgID string
+		var keyHash, orgID, orgStatus, planTier string
+		var keyID uuid.UUID
+		var scopes, allowedCIDRs []string
+		var keyExpiresAt, rotationExpiresAt, cappedUntil sql.NullTime
 		var reqsPerMinute, reqsPerDay, burstSize int
 
-		err := rows.Scan(&keyHash, &orgID, &reqsPerMinute, &reqsPerDay, &burstSize)
+		err := rows.Scan(&keyHash, &keyID, &orgID, pq.Array(&scopes), pq.Array(&allowedCIDRs), &keyExpiresAt, &rotationExpiresAt, &cappedUntil, &orgStatus, &planTier, &reqsPerMinute, &reqsPerDay, &burstSize)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		keys[keyHash] = &cache.CachedKey{
+			KeyID:          keyID,
 			OrganizationID: orgID,
 			RateLimitConfig: cache.RateLimitConfig{
 				RequestsPerMinute: reqsPerMinute,
 				RequestsPerDay:    reqsPerDay,
 				BurstSize:         burstSize,
 			},
-			ExpiresAt: time.Time{}, // Will be set by cache
+			Scopes:           scopes,
+			KeyExpiresAt:     nullTimePtr(keyExpiresAt),
+			RotationEndsAt:   nullTimePtr(rotationExpiresAt),
+			AllowedCIDRs:     parseAllowedCIDRs(allowedCIDRs),
+			UsageCappedUntil: nullTimePtr(cappedUntil),
+			OrgStatus:        orgStatus,
+			PlanTier:         planTier,
+			ExpiresAt:        time.Time{}, // Will be set by cache
 		}
 	}
 
@@ -76,22 +104,38 @@ func (r *Repository) FetchAllAPIKeys(ctx context.Context) (map[string]*cache.Cac
 func (r *Repository) GetAPIKey(ctx context.Context, keyHash string) (*cache.CachedKey, error) {
 	query := `
 		SELECT
+			ak.id,
 			ak.organization_id,
+			COALESCE(ak.scopes, ARRAY[]::TEXT[]) as scopes,
+			COALESCE(ak.allowed_cidrs, ARRAY[]::TEXT[]) as allowed_cidrs,
+			ak.expires_at,
+			ak.rotation_expires_at,
+			rls.capped_until,
+			COALESCE(o.status, 'active') as organization_status,
+			COALESCE(o.plan_tier, 'basic') as plan_tier,
 			COALESCE(rl.requests_per_minute, 60) as requests_per_minute,
 			COALESCE(rl.requests_per_day, 10000) as requests_per_day,
 			COALESCE(rl.burst_size, 10) as burst_size
 		FROM api_keys ak
+		LEFT JOIN organizations o ON ak.organization_id = o.id
 		LEFT JOIN rate_limit_configs rl ON ak.organization_id = rl.organization_id
+		LEFT JOIN rate_limit_state rls ON ak.organization_id = rls.organization_id
+			AND rls.capped_until > NOW()
 		WHERE ak.key_hash = $1
 		  AND ak.is_active = true
 		  AND ak.revoked_at IS NULL
+		  AND (ak.expires_at IS NULL OR ak.expires_at > NOW())
+		  AND (ak.rotation_expires_at IS NULL OR ak.rotation_expires_at > NOW())
 	`
 
-	var orgID string
+	var orgID, orgStatus, planTier string
+	var keyID uuid.UUID
+	var scopes, allowedCIDRs []string
+	var keyExpiresAt, rotationExpiresAt, cappedUntil sql.NullTime
 	var reqsPerMinute, reqsPerDay, burstSize int
 
 	err := r.db.QueryRowContext(ctx, query, keyHash).Scan(
-		&orgID, &reqsPerMinute, &reqsPerDay, &burstSize,
+		&keyID, &orgID, pq.Array(&scopes), pq.Array(&allowedCIDRs), &keyExpiresAt, &rotationExpiresAt, &cappedUntil, &orgStatus, &planTier, &reqsPerMinute, &reqsPerDay, &burstSize,
 	)
 
 	if err == sql.ErrNoRows {
@@ -103,13 +147,21 @@ func (r *Repository) GetAPIKey(ctx context.Context, keyHash string) (*cache.Cach
 	}
 
 	return &cache.CachedKey{
+		KeyID:          keyID,
 		OrganizationID: orgID,
 		RateLimitConfig: cache.RateLimitConfig{
 			RequestsPerMinute: reqsPerMinute,
 			RequestsPerDay:    reqsPerDay,
 			BurstSize:         burstSize,
 		},
-		ExpiresAt: time.Time{}, // Will be set by cache
+		Scopes:           scopes,
+		KeyExpiresAt:     nullTimePtr(keyExpiresAt),
+		RotationEndsAt:   nullTimePtr(rotationExpiresAt),
+		AllowedCIDRs:     parseAllowedCIDRs(allowedCIDRs),
+		UsageCappedUntil: nullTimePtr(cappedUntil),
+		OrgStatus:        orgStatus,
+		PlanTier:         planTier,
+		ExpiresAt:        time.Time{}, // Will be set by cache
 	}, nil
 }
 
@@ -138,6 +190,33 @@ func (r *Repository) InvalidateAPIKey(ctx context.Context, keyHash string) error
 	return nil
 }
 
+// nullTimePtr converts a nullable timestamp to a pointer (nil when NULL)
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// parseAllowedCIDRs converts a key's allowed_cidrs column to prefixes
+// An unparseable entry becomes the zero prefix, which matches no address, so the key
+// stays restricted instead of silently accepting every IP
+func parseAllowedCIDRs(values []string) []netip.Prefix {
+	if len(values) == 0 {
+		return nil
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			log.Printf("[Repository] WARNING: invalid allowed CIDR %q will match no address: %v", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
 // Ping checks if the database connection is alive
 func (r *Repository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/saas-gateway/gateway/internal/cache"
+)
+
+// RevocationChannel is the Postgres NOTIFY channel for revoked API keys
+// Payload is the key_hash (see migration 012)
+const RevocationChannel = "api_key_revoked"
+
+// RevocationListener evicts API keys from the cache as soon as they are revoked
+type RevocationListener struct {
+	listener  *pq.Listener
+	cache     *cache.APIKeyCache
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+}
+
+// NewRevocationListener creates a listener on the revocation channel
+func NewRevocationListener(databaseURL string, keyCache *cache.APIKeyCache) (*RevocationListener, error) {
+	listener := pq.NewListener(databaseURL, 1*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("[RevocationListener] ERROR: %v", err)
+		}
+	})
+
+	if err := listener.Listen(RevocationChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &RevocationListener{
+		listener:  listener,
+		cache:     keyCache,
+		stopCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}, nil
+}
+
+// Start processes revocation notifications until Stop is called
+// This should be called in a separate goroutine
+func (rl *RevocationListener) Start() {
+	log.Printf("[RevocationListener] Listening for revocations on channel %q", RevocationChannel)
+	defer close(rl.stoppedCh)
+
+	for {
+		select {
+		case n := <-rl.listener.Notify:
+			if n == nil {
+				// Connection was re-established - notifications may have been missed
+				log.Println("[RevocationListener] Reconnected, clearing API key cache")
+				rl.cache.Clear()
+				continue
+			}
+			rl.cache.Invalidate(n.Extra)
+			log.Printf("[RevocationListener] Evicted revoked key from cache")
+
+		case <-time.After(90 * time.Second):
+			// Keep the connection healthy
+			go rl.listener.Ping()
+
+		case <-rl.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops listening and closes the connection
+func (rl *RevocationListener) Stop() {
+	close(rl.stopCh)
+	<-rl.stoppedCh
+	rl.listener.Close()
+	log.Println("[RevocationListener] Stopped")
+}
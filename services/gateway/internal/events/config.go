@@ -7,6 +7,14 @@ import (
 	"time"
 )
 
+// Kafka producer tuning defaults
+const (
+	DefaultCompression    = "snappy"
+	DefaultLingerMs       = 10
+	DefaultBatchSizeBytes = 16384 // 16KB
+	DefaultAcks           = "1"   // Leader acknowledgment only
+)
+
 // Config holds Kafka event producer configuration
 type Config struct {
 	Enabled        bool
@@ -15,6 +23,23 @@ type Config struct {
 	BatchSize      int
 	FlushInterval  time.Duration
 	BufferSize     int
+
+	Compression    string // none, gzip, snappy, lz4 or zstd
+	LingerMs       int
+	BatchSizeBytes int
+	Acks           string // 0, 1 or all
+
+	PartitionKey     string // org_id, request_id or org_id_bucketed
+	PartitionBuckets int
+
+	OverflowPolicy  string // drop, block_with_timeout or spill_to_disk
+	OverflowTimeout time.Duration
+	SpillPath       string
+
+	WALEnabled        bool // Buffer events on local disk while Kafka is unreachable
+	WALDir            string
+	WALSegmentBytes   int64
+	ReconnectInterval time.Duration
 }
 
 // LoadConfig reads event producer configuration from environment variables
@@ -26,6 +51,23 @@ func LoadConfig() (*Config, error) {
 		BatchSize:      getEnvInt("KAFKA_BATCH_SIZE", 100),
 		FlushInterval:  getEnvDuration("KAFKA_FLUSH_INTERVAL", 500*time.Millisecond),
 		BufferSize:     getEnvInt("KAFKA_BUFFER_SIZE", 1000),
+
+		Compression:    getEnv("KAFKA_COMPRESSION", DefaultCompression),
+		LingerMs:       getEnvInt("KAFKA_LINGER_MS", DefaultLingerMs),
+		BatchSizeBytes: getEnvInt("KAFKA_BATCH_BYTES", DefaultBatchSizeBytes),
+		Acks:           getEnv("KAFKA_ACKS", DefaultAcks),
+
+		PartitionKey:     getEnv("KAFKA_PARTITION_KEY", PartitionByOrgID),
+		PartitionBuckets: getEnvInt("KAFKA_PARTITION_BUCKETS", DefaultPartitionBuckets),
+
+		OverflowPolicy:  getEnv("KAFKA_OVERFLOW_POLICY", OverflowDrop),
+		OverflowTimeout: getEnvDuration("KAFKA_OVERFLOW_TIMEOUT", 5*time.Millisecond),
+		SpillPath:       getEnv("KAFKA_SPILL_PATH", "usage-events-spill.jsonl"),
+
+		WALEnabled:        getEnvBool("KAFKA_WAL_ENABLED", true),
+		WALDir:            getEnv("KAFKA_WAL_DIR", "usage-wal"),
+		WALSegmentBytes:   int64(getEnvInt("KAFKA_WAL_SEGMENT_BYTES", int(DefaultWALSegmentBytes))),
+		ReconnectInterval: getEnvDuration("KAFKA_RECONNECT_INTERVAL", 10*time.Second),
 	}
 
 	// Validate required settings
@@ -41,9 +83,88 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("KAFKA_BUFFER_SIZE must be positive, got: %d", cfg.BufferSize)
 	}
 
+	if err := validateKafkaTuning(cfg.Compression, cfg.LingerMs, cfg.BatchSizeBytes, cfg.Acks); err != nil {
+		return nil, err
+	}
+
+	if _, err := newPartitionKeyFunc(cfg.PartitionKey, cfg.PartitionBuckets); err != nil {
+		return nil, fmt.Errorf("KAFKA_PARTITION_KEY/KAFKA_PARTITION_BUCKETS: %w", err)
+	}
+
+	if !validOverflowPolicy(cfg.OverflowPolicy) {
+		return nil, fmt.Errorf("KAFKA_OVERFLOW_POLICY must be drop, block_with_timeout or spill_to_disk, got: %s", cfg.OverflowPolicy)
+	}
+
+	if cfg.OverflowPolicy == OverflowBlockWithTimeout && (cfg.OverflowTimeout <= 0 || cfg.OverflowTimeout > time.Second) {
+		return nil, fmt.Errorf("KAFKA_OVERFLOW_TIMEOUT must be between 0 and 1s, got: %v", cfg.OverflowTimeout)
+	}
+
+	if cfg.OverflowPolicy == OverflowSpillToDisk && cfg.SpillPath == "" {
+		return nil, fmt.Errorf("KAFKA_SPILL_PATH is required when KAFKA_OVERFLOW_POLICY=spill_to_disk")
+	}
+
+	if cfg.WALEnabled && cfg.WALSegmentBytes < 1<<20 {
+		return nil, fmt.Errorf("KAFKA_WAL_SEGMENT_BYTES must be at least 1MB, got: %d", cfg.WALSegmentBytes)
+	}
+
+	if cfg.WALEnabled && cfg.ReconnectInterval < time.Second {
+		return nil, fmt.Errorf("KAFKA_RECONNECT_INTERVAL must be at least 1s, got: %v", cfg.ReconnectInterval)
+	}
+
 	return cfg, nil
 }
 
+// applyKafkaDefaults fills unset Kafka tuning fields with the defaults and validates them
+// A zero LingerMs or BatchSizeBytes means the default
+func (config *ProducerConfig) applyKafkaDefaults() error {
+	if config.Compression == "" {
+		config.Compression = DefaultCompression
+	}
+	if config.LingerMs == 0 {
+		config.LingerMs = DefaultLingerMs
+	}
+	if config.BatchSizeBytes == 0 {
+		config.BatchSizeBytes = DefaultBatchSizeBytes
+	}
+	if config.Acks == "" {
+		config.Acks = DefaultAcks
+	}
+	return validateKafkaTuning(config.Compression, config.LingerMs, config.BatchSizeBytes, config.Acks)
+}
+
+// validateKafkaTuning checks the tuning values librdkafka would otherwise reject at runtime
+func validateKafkaTuning(compression string, lingerMs, batchSizeBytes int, acks string) error {
+	switch compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		return fmt.Errorf("compression must be none, gzip, snappy, lz4 or zstd, got: %s", compression)
+	}
+
+	switch acks {
+	case "0", "1", "all":
+	default:
+		return fmt.Errorf("acks must be 0, 1 or all, got: %s", acks)
+	}
+
+	if lingerMs < 0 || lingerMs > 900000 {
+		return fmt.Errorf("linger ms must be between 0 and 900000, got: %d", lingerMs)
+	}
+
+	if batchSizeBytes <= 0 || batchSizeBytes > 2147483647 {
+		return fmt.Errorf("batch size bytes must be between 1 and 2147483647, got: %d", batchSizeBytes)
+	}
+
+	return nil
+}
+
+// ProducerWALDir returns the WAL directory to pass to the producer, or "" when it is disabled
+func (c *Config) ProducerWALDir() string {
+	if !c.WALEnabled {
+		return ""
+	}
+	return c.WALDir
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
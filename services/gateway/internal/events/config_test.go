@@ -0,0 +1,62 @@
+package events
+
+import "testing"
+
+func TestApplyKafkaDefaults(t *testing.T) {
+	config := ProducerConfig{Brokers: "localhost:9092"}
+	if err := config.applyKafkaDefaults(); err != nil {
+		t.Fatalf("applyKafkaDefaults() error = %v", err)
+	}
+
+	if config.Compression != "snappy" || config.LingerMs != 10 || config.BatchSizeBytes != 16384 || config.Acks != "1" {
+		t.Errorf("Defaults = %s/%d/%d/%s, want snappy/10/16384/1",
+			config.Compression, config.LingerMs, config.BatchSizeBytes, config.Acks)
+	}
+}
+
+func TestApplyKafkaDefaults_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ProducerConfig
+		wantErr bool
+	}{
+		{"Financial grade", ProducerConfig{Acks: "all", Compression: "zstd"}, false},
+		{"No compression", ProducerConfig{Compression: "none", LingerMs: 50, BatchSizeBytes: 1 << 20}, false},
+		{"Acks zero", ProducerConfig{Acks: "0"}, false},
+		{"Unknown compression", ProducerConfig{Compression: "brotli"}, true},
+		{"Compression is case sensitive", ProducerConfig{Compression: "ZSTD"}, true},
+		{"Acks -1 alias rejected", ProducerConfig{Acks: "-1"}, true},
+		{"Acks two", ProducerConfig{Acks: "2"}, true},
+		{"Negative linger", ProducerConfig{LingerMs: -1}, true},
+		{"Negative batch size", ProducerConfig{BatchSizeBytes: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.applyKafkaDefaults()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applyKafkaDefaults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_KafkaTuning(t *testing.T) {
+	t.Setenv("KAFKA_ACKS", "all")
+	t.Setenv("KAFKA_COMPRESSION", "lz4")
+	t.Setenv("KAFKA_LINGER_MS", "25")
+	t.Setenv("KAFKA_BATCH_BYTES", "65536")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Acks != "all" || cfg.Compression != "lz4" || cfg.LingerMs != 25 || cfg.BatchSizeBytes != 65536 {
+		t.Errorf("Tuning = %s/%s/%d/%d", cfg.Acks, cfg.Compression, cfg.LingerMs, cfg.BatchSizeBytes)
+	}
+
+	t.Setenv("KAFKA_COMPRESSION", "brotli")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected error for invalid KAFKA_COMPRESSION")
+	}
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/metrics"
+)
+
+// Overflow policies for events that arrive while the buffer is full
+const (
+	OverflowDrop             = "drop"               // Drop the event (never blocks the request)
+	OverflowBlockWithTimeout = "block_with_timeout" // Wait up to OverflowTimeout for space, then drop
+	OverflowSpillToDisk      = "spill_to_disk"      // Append the event to SpillPath for later replay
+)
+
+// validOverflowPolicy reports whether policy is one of the Overflow* constants
+func validOverflowPolicy(policy string) bool {
+	switch policy {
+	case OverflowDrop, OverflowBlockWithTimeout, OverflowSpillToDisk:
+		return true
+	}
+	return false
+}
+
+// spillFile appends events to a local file, one JSON object per line
+type spillFile struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// openSpillFile opens (or creates) path for appending
+func openSpillFile(path string) (*spillFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spill file: %w", err)
+	}
+	return &spillFile{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Write appends one event
+func (s *spillFile) Write(event UsageEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(event)
+}
+
+// Close syncs and closes the file
+func (s *spillFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// handleOverflow applies the overflow policy to an event that did not fit in the buffer
+func (ep *EventProducer) handleOverflow(event UsageEvent) {
+	switch ep.overflowPolicy {
+	case OverflowBlockWithTimeout:
+		timer := time.NewTimer(ep.overflowTimeout)
+		defer timer.Stop()
+
+		select {
+		case ep.buffer <- event:
+			return
+		case <-timer.C:
+		}
+
+	case OverflowSpillToDisk:
+		err := ep.spill.Write(event)
+		if err == nil {
+			ep.spilled.Add(1)
+			return
+		}
+		log.Printf("[EventProducer] ERROR: Failed to spill event to disk: %v", err)
+	}
+
+	ep.dropEvent(event)
+}
+
+// dropEvent counts an event lost to a full buffer so operators can alarm on it
+func (ep *EventProducer) dropEvent(event UsageEvent) {
+	ep.dropped.Add(1)
+	metrics.RecordUsageError(event.OrganizationID, "buffer_full")
+	log.Printf("[EventProducer] WARNING: Buffer full, dropping event for org: %s", event.OrganizationID)
+}
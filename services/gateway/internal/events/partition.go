@@ -0,0 +1,48 @@
+package events
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// Partition key strategies for usage event messages
+const (
+	PartitionByOrgID         = "org_id"          // All of an org's events on one partition, in order
+	PartitionByRequestID     = "request_id"      // Even spread, no ordering guarantee
+	PartitionByOrgIDBucketed = "org_id_bucketed" // An org spread over N sub-keys, ordered within each
+
+	// DefaultPartitionBuckets is the sub-key count for PartitionByOrgIDBucketed
+	DefaultPartitionBuckets = 8
+)
+
+// partitionKeyFunc returns the Kafka message key for an event
+type partitionKeyFunc func(event UsageEvent) []byte
+
+// newPartitionKeyFunc returns the key function for a strategy
+func newPartitionKeyFunc(strategy string, buckets int) (partitionKeyFunc, error) {
+	switch strategy {
+	case PartitionByOrgID:
+		return func(event UsageEvent) []byte {
+			return []byte(event.OrganizationID)
+		}, nil
+
+	case PartitionByRequestID:
+		return func(event UsageEvent) []byte {
+			return []byte(event.RequestID)
+		}, nil
+
+	case PartitionByOrgIDBucketed:
+		if buckets <= 0 {
+			return nil, fmt.Errorf("partition buckets must be positive, got: %d", buckets)
+		}
+		return func(event UsageEvent) []byte {
+			// The request ID picks the bucket, so one org's traffic spreads over at most N partitions
+			h := fnv.New32a()
+			h.Write([]byte(event.RequestID))
+			bucket := h.Sum32() % uint32(buckets)
+			return []byte(event.OrganizationID + "#" + strconv.FormatUint(uint64(bucket), 10))
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid partition key strategy: %s", strategy)
+}
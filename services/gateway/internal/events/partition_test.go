@@ -0,0 +1,59 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPartitionKey(t *testing.T) {
+	event := UsageEvent{RequestID: "req-003", OrganizationID: "org_1"}
+
+	tests := []struct {
+		strategy string
+		buckets  int
+		expected string
+	}{
+		{PartitionByOrgID, 0, "org_1"},
+		{PartitionByRequestID, 0, "req-003"},
+		{PartitionByOrgIDBucketed, 8, "org_1#3"}, // fnv32a("req-003") % 8 == 3
+		{PartitionByOrgIDBucketed, 1, "org_1#0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.strategy, tt.buckets), func(t *testing.T) {
+			key, err := newPartitionKeyFunc(tt.strategy, tt.buckets)
+			if err != nil {
+				t.Fatalf("newPartitionKeyFunc() error = %v", err)
+			}
+			if got := string(key(event)); got != tt.expected {
+				t.Errorf("Key = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPartitionKey_BucketedSpreadsOneOrg(t *testing.T) {
+	key, _ := newPartitionKeyFunc(PartitionByOrgIDBucketed, DefaultPartitionBuckets)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		k := string(key(UsageEvent{RequestID: fmt.Sprintf("req-%d", i), OrganizationID: "org_whale"}))
+		if !strings.HasPrefix(k, "org_whale#") {
+			t.Fatalf("Key %q lost the org prefix", k)
+		}
+		seen[k] = true
+	}
+	if len(seen) != DefaultPartitionBuckets {
+		t.Errorf("Got %d distinct keys, want %d", len(seen), DefaultPartitionBuckets)
+	}
+}
+
+func TestPartitionKey_Invalid(t *testing.T) {
+	if _, err := newPartitionKeyFunc("round_robin", 0); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+	if _, err := newPartitionKeyFunc(PartitionByOrgIDBucketed, 0); err == nil {
+		t.Error("Expected error for zero buckets")
+	}
+}
@@ -2,8 +2,10 @@ package events
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
@@ -20,6 +22,11 @@ type UsageEvent struct {
 	ResponseTimeMs int64     `json:"response_time_ms"`
 	Timestamp      time.Time `json:"timestamp"`
 	Billable       bool      `json:"billable"`
+	Cached         bool      `json:"cached"`                // Served from the gateway response cache
+	Region         string    `json:"region"`                // Client country code, or "unknown"
+	SampleRate     int       `json:"sample_rate,omitempty"` // Set when 1 in SampleRate non-billable events is recorded
+	BytesIn        int64     `json:"bytes_in"`              // Request body bytes read from the client
+	BytesOut       int64     `json:"bytes_out"`             // Response body bytes written to the client
 }
 
 // EventProducer buffers and sends usage events to Kafka
@@ -32,6 +39,23 @@ type EventProducer struct {
 	flushWg     sync.WaitGroup
 	batchSize   int
 	flushInterv time.Duration
+
+	partitionKey partitionKeyFunc // Message key for each event
+
+	// Full-buffer handling
+	overflowPolicy  string
+	overflowTimeout time.Duration
+	spill           *spillFile // Set for OverflowSpillToDisk
+	dropped         atomic.Int64
+	spilled         atomic.Int64
+
+	// Local fallback while Kafka is unreachable
+	kafkaConfig       *kafka.ConfigMap
+	wal               *WAL // nil when the WAL is disabled
+	online            atomic.Bool
+	reconnectInterval time.Duration
+	connect           func() (ReplaySink, error) // Connects to Kafka; replaced in tests
+	walWritten        atomic.Int64
 }
 
 // ProducerConfig holds configuration for the event producer
@@ -41,6 +65,22 @@ type ProducerConfig struct {
 	BatchSize      int           // Events to batch before sending (default: 100)
 	FlushInterval  time.Duration // Max time to wait before flushing (default: 500ms)
 	BufferSize     int           // Channel buffer size (default: 1000)
+
+	Compression    string // Kafka compression.type: none, gzip, snappy, lz4 or zstd (default: snappy)
+	LingerMs       int    // Kafka linger.ms, max wait to fill a batch (default: 10)
+	BatchSizeBytes int    // Kafka batch.size in bytes (default: 16384)
+	Acks           string // Kafka acks: 0, 1 or all (default: 1)
+
+	PartitionKey     string // Message key strategy (default: PartitionByOrgID)
+	PartitionBuckets int    // Sub-keys per org for PartitionByOrgIDBucketed (default: 8)
+
+	OverflowPolicy  string        // What to do when the buffer is full (default: OverflowDrop)
+	OverflowTimeout time.Duration // Max wait for OverflowBlockWithTimeout (default: 5ms)
+	SpillPath       string        // File for OverflowSpillToDisk
+
+	WALDir            string        // Directory for the local fallback log; empty disables it
+	WALSegmentBytes   int64         // Segment rotation size (default: 64MB)
+	ReconnectInterval time.Duration // How often to retry Kafka while on the WAL (default: 10s)
 }
 
 // NewEventProducer creates a new Kafka event producer
@@ -55,55 +95,157 @@ func NewEventProducer(config ProducerConfig) (*EventProducer, error) {
 	if config.BufferSize == 0 {
 		config.BufferSize = 1000
 	}
-
-	// Create Kafka producer
-	kafkaConfig := &kafka.ConfigMap{
-		"bootstrap.servers": config.Brokers,
-		"client.id":         "saas-gateway-producer",
-		"acks":              "1",                  // Leader acknowledgment only (balance between speed and reliability)
-		"compression.type":  "snappy",             // Compress messages
-		"linger.ms":         10,                   // Wait up to 10ms to batch messages
-		"batch.size":        16384,                // 16KB batch size
-		"retries":           3,                    // Retry failed sends
-		"retry.backoff.ms":  100,                  // Wait 100ms between retries
-	}
-
-	producer, err := kafka.NewProducer(kafkaConfig)
+	if config.OverflowPolicy == "" {
+		config.OverflowPolicy = OverflowDrop
+	}
+	if config.OverflowTimeout == 0 {
+		config.OverflowTimeout = 5 * time.Millisecond
+	}
+	if config.ReconnectInterval == 0 {
+		config.ReconnectInterval = 10 * time.Second
+	}
+	if !validOverflowPolicy(config.OverflowPolicy) {
+		return nil, fmt.Errorf("invalid overflow policy: %s", config.OverflowPolicy)
+	}
+	if err := config.applyKafkaDefaults(); err != nil {
+		return nil, err
+	}
+	partitionKey, err := config.partitionKeyFunc()
 	if err != nil {
 		return nil, err
 	}
 
+	var spill *spillFile
+	if config.OverflowPolicy == OverflowSpillToDisk {
+		if spill, err = openSpillFile(config.SpillPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var wal *WAL
+	if config.WALDir != "" {
+		if wal, err = OpenWAL(config.WALDir, config.WALSegmentBytes); err != nil {
+			if spill != nil {
+				spill.Close()
+			}
+			return nil, err
+		}
+	}
+
 	ep := &EventProducer{
-		producer:    producer,
 		buffer:      make(chan UsageEvent, config.BufferSize),
 		topic:       config.Topic,
 		stopCh:      make(chan struct{}),
 		stoppedCh:   make(chan struct{}),
 		batchSize:   config.BatchSize,
 		flushInterv: config.FlushInterval,
+
+		partitionKey: partitionKey,
+
+		overflowPolicy:  config.OverflowPolicy,
+		overflowTimeout: config.OverflowTimeout,
+		spill:           spill,
+
+		kafkaConfig:       kafkaConfigMap(config),
+		wal:               wal,
+		reconnectInterval: config.ReconnectInterval,
+	}
+	ep.connect = ep.connectKafka
+
+	// Create Kafka producer
+	if err := ep.start(); err != nil {
+		if spill != nil {
+			spill.Close()
+		}
+		if wal != nil {
+			wal.Close()
+		}
+		if ep.producer != nil {
+			ep.producer.Close()
+		}
+		return nil, err
 	}
 
 	// Start background flush worker
 	ep.flushWg.Add(1)
 	go ep.flushWorker()
 
-	// Start delivery report handler
-	go ep.handleDeliveryReports()
-
-	log.Printf("[EventProducer] Started (batch_size=%d, flush_interval=%v, buffer=%d)",
-		config.BatchSize, config.FlushInterval, config.BufferSize)
+	log.Printf("[EventProducer] Started (batch_size=%d, flush_interval=%v, buffer=%d, overflow=%s, partition_key=%s, wal=%q)",
+		config.BatchSize, config.FlushInterval, config.BufferSize, config.OverflowPolicy, config.PartitionKey, config.WALDir)
 
 	return ep, nil
 }
 
+// partitionKeyFunc applies the partition key defaults and returns the key function
+func (config *ProducerConfig) partitionKeyFunc() (partitionKeyFunc, error) {
+	if config.PartitionKey == "" {
+		config.PartitionKey = PartitionByOrgID
+	}
+	if config.PartitionBuckets == 0 {
+		config.PartitionBuckets = DefaultPartitionBuckets
+	}
+	return newPartitionKeyFunc(config.PartitionKey, config.PartitionBuckets)
+}
+
+// start connects to Kafka. Without a WAL a producer error is fatal, as before; with one,
+// the producer starts on the WAL and the flush worker keeps retrying Kafka
+func (ep *EventProducer) start() error {
+	if ep.wal == nil {
+		producer, err := kafka.NewProducer(ep.kafkaConfig)
+		if err != nil {
+			return err
+		}
+		ep.producer = producer
+		ep.online.Store(true)
+		go ep.handleDeliveryReports()
+		return nil
+	}
+
+	if _, err := ep.connect(); err != nil {
+		log.Printf("[EventProducer] WARNING: Kafka unavailable, writing usage events to the WAL until it recovers: %v", err)
+		return nil
+	}
+
+	// Segments left by an earlier run are drained before anything new goes to Kafka
+	pending, err := ep.wal.Pending()
+	if err != nil {
+		return err
+	}
+	if pending {
+		log.Println("[EventProducer] WARNING: WAL has events from a previous run, replaying on the next reconnect")
+		return nil
+	}
+
+	ep.online.Store(true)
+	return nil
+}
+
+// connectKafka creates the Kafka producer if needed and checks the brokers answer
+// It only runs from start and the flush worker, so ep.producer needs no lock
+func (ep *EventProducer) connectKafka() (ReplaySink, error) {
+	if ep.producer == nil {
+		producer, err := kafka.NewProducer(ep.kafkaConfig)
+		if err != nil {
+			return nil, err
+		}
+		ep.producer = producer
+		go ep.handleDeliveryReports()
+	}
+
+	if _, err := ep.producer.GetMetadata(&ep.topic, false, kafkaProbeTimeoutMs); err != nil {
+		return nil, fmt.Errorf("kafka unreachable: %w", err)
+	}
+	return newKafkaSink(ep.producer, ep.topic, ep.partitionKey), nil
+}
+
 // RecordUsage queues a usage event for async sending to Kafka
 func (ep *EventProducer) RecordUsage(event UsageEvent) {
 	select {
 	case ep.buffer <- event:
 		// Event buffered successfully
 	default:
-		// Buffer full - log warning but don't block request
-		log.Printf("[EventProducer] WARNING: Buffer full, dropping event for org: %s", event.OrganizationID)
+		// Buffer full - wait, spill or drop according to the overflow policy
+		ep.handleOverflow(event)
 	}
 }
 
@@ -115,6 +257,14 @@ func (ep *EventProducer) flushWorker() {
 	ticker := time.NewTicker(ep.flushInterv)
 	defer ticker.Stop()
 
+	// Retry Kafka while events are going to the WAL
+	var reconnect <-chan time.Time
+	if ep.wal != nil {
+		reconnectTicker := time.NewTicker(ep.reconnectInterval)
+		defer reconnectTicker.Stop()
+		reconnect = reconnectTicker.C
+	}
+
 	batch := make([]UsageEvent, 0, ep.batchSize)
 
 	for {
@@ -135,6 +285,11 @@ func (ep *EventProducer) flushWorker() {
 				batch = batch[:0]
 			}
 
+		case <-reconnect:
+			if !ep.online.Load() {
+				ep.reconnect()
+			}
+
 		case <-ep.stopCh:
 			// Flush remaining events on shutdown
 			if len(batch) > 0 {
@@ -168,10 +323,15 @@ func (ep *EventProducer) sendBatch(batch []UsageEvent) {
 		return
 	}
 
+	if !ep.online.Load() {
+		ep.writeWAL(batch)
+		return
+	}
+
 	successCount := 0
 	failCount := 0
 
-	for _, event := range batch {
+	for i, event := range batch {
 		// Serialize event to JSON
 		value, err := json.Marshal(event)
 		if err != nil {
@@ -186,10 +346,18 @@ func (ep *EventProducer) sendBatch(batch []UsageEvent) {
 				Topic:     &ep.topic,
 				Partition: kafka.PartitionAny,
 			},
-			Key:   []byte(event.OrganizationID), // Partition by organization
+			Key:   ep.partitionKey(event),
 			Value: value,
 		}, nil)
 
+		if err != nil && ep.wal != nil {
+			// Keep the rest of the batch on disk until Kafka accepts events again
+			log.Printf("[EventProducer] ERROR: Failed to produce event, switching to the WAL: %v", err)
+			ep.online.Store(false)
+			ep.writeWAL(batch[i:])
+			break
+		}
+
 		if err != nil {
 			log.Printf("[EventProducer] ERROR: Failed to produce event: %v", err)
 			failCount++
@@ -209,6 +377,7 @@ func (ep *EventProducer) handleDeliveryReports() {
 		case *kafka.Message:
 			if ev.TopicPartition.Error != nil {
 				log.Printf("[EventProducer] ERROR: Delivery failed: %v", ev.TopicPartition.Error)
+				ep.recoverUndelivered(ev)
 			}
 			// Success case: silent (too verbose to log every message)
 		case kafka.Error:
@@ -217,6 +386,23 @@ func (ep *EventProducer) handleDeliveryReports() {
 	}
 }
 
+// recoverUndelivered writes a message Kafka gave up on to the WAL and switches to it,
+// so events are kept when the brokers go away after startup
+func (ep *EventProducer) recoverUndelivered(msg *kafka.Message) {
+	if ep.wal == nil {
+		return
+	}
+
+	var event UsageEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		log.Printf("[EventProducer] ERROR: Failed to decode undelivered event: %v", err)
+		return
+	}
+
+	ep.online.Store(false)
+	ep.writeWAL([]UsageEvent{event})
+}
+
 // Flush blocks until all buffered events are sent to Kafka
 func (ep *EventProducer) Flush() {
 	log.Println("[EventProducer] Flushing pending events...")
@@ -228,9 +414,11 @@ func (ep *EventProducer) Flush() {
 	ep.flushWg.Wait()
 
 	// Flush Kafka producer's internal queue
-	remaining := ep.producer.Flush(10000) // 10 second timeout
-	if remaining > 0 {
-		log.Printf("[EventProducer] WARNING: %d messages were not delivered", remaining)
+	if ep.producer != nil {
+		remaining := ep.producer.Flush(10000) // 10 second timeout
+		if remaining > 0 {
+			log.Printf("[EventProducer] WARNING: %d messages were not delivered", remaining)
+		}
 	}
 
 	log.Println("[EventProducer] Flush complete")
@@ -244,7 +432,21 @@ func (ep *EventProducer) Close() error {
 	ep.Flush()
 
 	// Close Kafka producer
-	ep.producer.Close()
+	if ep.producer != nil {
+		ep.producer.Close()
+	}
+
+	if ep.wal != nil {
+		if err := ep.wal.Close(); err != nil {
+			log.Printf("[EventProducer] ERROR: Failed to close WAL: %v", err)
+		}
+	}
+
+	if ep.spill != nil {
+		if err := ep.spill.Close(); err != nil {
+			log.Printf("[EventProducer] ERROR: Failed to close spill file: %v", err)
+		}
+	}
 
 	// Wait for delivery report handler to finish
 	<-ep.stoppedCh
@@ -256,9 +458,14 @@ func (ep *EventProducer) Close() error {
 // Stats returns current producer statistics
 func (ep *EventProducer) Stats() map[string]interface{} {
 	return map[string]interface{}{
-		"buffer_length": len(ep.buffer),
-		"buffer_cap":    cap(ep.buffer),
-		"batch_size":    ep.batchSize,
-		"flush_interval": ep.flushInterv.String(),
+		"buffer_length":   len(ep.buffer),
+		"buffer_cap":      cap(ep.buffer),
+		"batch_size":      ep.batchSize,
+		"flush_interval":  ep.flushInterv.String(),
+		"overflow_policy": ep.overflowPolicy,
+		"dropped":         ep.dropped.Load(),
+		"spilled":         ep.spilled.Load(),
+		"kafka_online":    ep.online.Load(),
+		"wal_written":     ep.walWritten.Load(),
 	}
 }
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/saas-gateway/gateway/internal/metrics"
+)
+
+// newOverflowTestProducer builds a producer with a full one-slot buffer and no Kafka connection
+func newOverflowTestProducer(policy string) *EventProducer {
+	ep := &EventProducer{
+		buffer:          make(chan UsageEvent, 1),
+		overflowPolicy:  policy,
+		overflowTimeout: 50 * time.Millisecond,
+	}
+	ep.buffer <- UsageEvent{RequestID: "req-queued", OrganizationID: "org_overflow"}
+	return ep
+}
+
+func bufferFullErrors() float64 {
+	return testutil.ToFloat64(metrics.UsageRecordingErrors.WithLabelValues("org_overflow", "buffer_full"))
+}
+
+func TestRecordUsage_FullBufferDrops(t *testing.T) {
+	ep := newOverflowTestProducer(OverflowDrop)
+	before := bufferFullErrors()
+
+	start := time.Now()
+	ep.RecordUsage(UsageEvent{RequestID: "req-dropped", OrganizationID: "org_overflow"})
+
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("RecordUsage blocked for %v with the drop policy", elapsed)
+	}
+	if got := ep.dropped.Load(); got != 1 {
+		t.Errorf("Dropped = %d, want 1", got)
+	}
+	if got := bufferFullErrors() - before; got != 1 {
+		t.Errorf("buffer_full errors increased by %v, want 1", got)
+	}
+}
+
+func TestRecordUsage_BlockWithTimeout(t *testing.T) {
+	// Space frees up within the timeout: the event is buffered
+	ep := newOverflowTestProducer(OverflowBlockWithTimeout)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-ep.buffer
+	}()
+
+	ep.RecordUsage(UsageEvent{RequestID: "req-waited", OrganizationID: "org_overflow"})
+
+	if got := ep.dropped.Load(); got != 0 {
+		t.Errorf("Dropped = %d, want 0 when space frees up", got)
+	}
+	if event := <-ep.buffer; event.RequestID != "req-waited" {
+		t.Errorf("Buffered event = %s, want req-waited", event.RequestID)
+	}
+
+	// Buffer stays full: the event is dropped after the timeout
+	ep = newOverflowTestProducer(OverflowBlockWithTimeout)
+	before := bufferFullErrors()
+
+	start := time.Now()
+	ep.RecordUsage(UsageEvent{RequestID: "req-timed-out", OrganizationID: "org_overflow"})
+
+	if elapsed := time.Since(start); elapsed < ep.overflowTimeout {
+		t.Errorf("RecordUsage returned after %v, want at least the %v timeout", elapsed, ep.overflowTimeout)
+	}
+	if got := ep.dropped.Load(); got != 1 {
+		t.Errorf("Dropped = %d, want 1", got)
+	}
+	if got := bufferFullErrors() - before; got != 1 {
+		t.Errorf("buffer_full errors increased by %v, want 1", got)
+	}
+}
+
+func TestRecordUsage_SpillToDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	spill, err := openSpillFile(path)
+	if err != nil {
+		t.Fatalf("openSpillFile() error = %v", err)
+	}
+
+	ep := newOverflowTestProducer(OverflowSpillToDisk)
+	ep.spill = spill
+
+	ep.RecordUsage(UsageEvent{RequestID: "req-spilled-1", OrganizationID: "org_overflow"})
+	ep.RecordUsage(UsageEvent{RequestID: "req-spilled-2", OrganizationID: "org_overflow"})
+
+	if err := spill.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if ep.dropped.Load() != 0 || ep.spilled.Load() != 2 {
+		t.Errorf("Dropped = %d, spilled = %d, want 0 and 2", ep.dropped.Load(), ep.spilled.Load())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open spill file: %v", err)
+	}
+	defer file.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event UsageEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Spill line is not a usage event: %q", scanner.Text())
+		}
+		ids = append(ids, event.RequestID)
+	}
+	if len(ids) != 2 || ids[0] != "req-spilled-1" || ids[1] != "req-spilled-2" {
+		t.Errorf("Spilled events = %v, want req-spilled-1, req-spilled-2", ids)
+	}
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// kafkaProbeTimeoutMs bounds the broker metadata request used to check Kafka is reachable
+const kafkaProbeTimeoutMs = 5000
+
+// kafkaSink delivers replayed events to Kafka, waiting for acknowledgements on Flush
+type kafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+	key      partitionKeyFunc
+	delivery chan kafka.Event
+	pending  int
+}
+
+func newKafkaSink(producer *kafka.Producer, topic string, key partitionKeyFunc) *kafkaSink {
+	return &kafkaSink{
+		producer: producer,
+		topic:    topic,
+		key:      key,
+		delivery: make(chan kafka.Event, 10000),
+	}
+}
+
+// Send produces one event asynchronously; its delivery report is collected by Flush
+func (s *kafkaSink) Send(event UsageEvent) error {
+	// Never let more reports be outstanding than the delivery channel can hold
+	if s.pending >= cap(s.delivery) {
+		if err := s.Flush(); err != nil {
+			return err
+		}
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	err = s.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &s.topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:   s.key(event),
+		Value: value,
+	}, s.delivery)
+	if err != nil {
+		return err
+	}
+
+	s.pending++
+	return nil
+}
+
+// Flush waits for every outstanding delivery report and fails if any message was not delivered
+func (s *kafkaSink) Flush() error {
+	var firstErr error
+	failed := 0
+
+	for ; s.pending > 0; s.pending-- {
+		msg, ok := (<-s.delivery).(*kafka.Message)
+		if ok && msg.TopicPartition.Error != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = msg.TopicPartition.Error
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d events not delivered: %w", failed, firstErr)
+	}
+	return nil
+}
+
+// ReplayToKafka flushes a WAL directory, a single segment, or a spill file into Kafka
+// using the Brokers, Topic and partition key settings from config. It is meant for
+// operators recovering events by hand (see cmd/wal-replay)
+func ReplayToKafka(config ProducerConfig, path string) (int, error) {
+	if err := config.applyKafkaDefaults(); err != nil {
+		return 0, err
+	}
+	partitionKey, err := config.partitionKeyFunc()
+	if err != nil {
+		return 0, err
+	}
+
+	producer, err := kafka.NewProducer(kafkaConfigMap(config))
+	if err != nil {
+		return 0, err
+	}
+	defer producer.Close()
+
+	if _, err := producer.GetMetadata(&config.Topic, false, kafkaProbeTimeoutMs); err != nil {
+		return 0, fmt.Errorf("kafka unreachable: %w", err)
+	}
+
+	return ReplayWAL(path, newKafkaSink(producer, config.Topic, partitionKey))
+}
+
+// kafkaConfigMap is the producer configuration shared by the gateway and the replay tool
+// config must have been through applyKafkaDefaults
+func kafkaConfigMap(config ProducerConfig) *kafka.ConfigMap {
+	return &kafka.ConfigMap{
+		"bootstrap.servers": config.Brokers,
+		"client.id":         "saas-gateway-producer",
+		"acks":              config.Acks,           // 1 = leader only (balance between speed and reliability), all = every in-sync replica
+		"compression.type":  config.Compression,    // Compress messages
+		"linger.ms":         config.LingerMs,       // Wait to batch messages
+		"batch.size":        config.BatchSizeBytes, // Max batch size in bytes
+		"retries":           3,                     // Retry failed sends
+		"retry.backoff.ms":  100,                   // Wait 100ms between retries
+	}
+}
@@ -0,0 +1,294 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/saas-gateway/gateway/internal/metrics"
+)
+
+// WAL segment naming: wal-<sequence>.jsonl, zero-padded so names sort oldest first
+const (
+	walSegmentPrefix = "wal-"
+	walSegmentSuffix = ".jsonl"
+
+	// DefaultWALSegmentBytes is the size at which the active segment is rotated
+	DefaultWALSegmentBytes int64 = 64 << 20
+
+	// maxWALLineBytes bounds a single serialized event when replaying
+	maxWALLineBytes = 1 << 20
+)
+
+// ReplaySink receives events replayed from a WAL
+// Flush is called after each segment and must only return nil once every event
+// sent so far has been delivered; the segment is deleted after that
+type ReplaySink interface {
+	Send(event UsageEvent) error
+	Flush() error
+}
+
+// WAL is an append-only, size-rotated log of usage events kept on local disk
+// while Kafka is unreachable. Segments hold one JSON event per line, the same
+// format as the spill_to_disk overflow file
+type WAL struct {
+	mu           sync.Mutex
+	dir          string
+	segmentBytes int64
+	seq          int64    // Sequence number of the newest segment
+	file         *os.File // Active segment, nil until the next Append
+	size         int64
+}
+
+// OpenWAL opens (or creates) a WAL directory; existing segments are kept for replay
+func OpenWAL(dir string, segmentBytes int64) (*WAL, error) {
+	if segmentBytes <= 0 {
+		segmentBytes = DefaultWALSegmentBytes
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+
+	segments, err := walSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAL{dir: dir, segmentBytes: segmentBytes}
+	if len(segments) > 0 {
+		w.seq = segmentSeq(segments[len(segments)-1])
+	}
+	return w, nil
+}
+
+// Append writes one event, rotating to a new segment once the active one is full
+func (w *WAL) Append(event UsageEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil || w.size >= w.segmentBytes {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write WAL segment: %w", err)
+	}
+	return nil
+}
+
+// rotate closes the active segment and opens the next one; callers hold w.mu
+func (w *WAL) rotate() error {
+	if err := w.closeSegment(); err != nil {
+		return err
+	}
+
+	w.seq++
+	path := filepath.Join(w.dir, segmentName(w.seq))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open WAL segment: %w", err)
+	}
+
+	w.file = file
+	w.size = 0
+	return nil
+}
+
+// closeSegment syncs and closes the active segment; callers hold w.mu
+func (w *WAL) closeSegment() error {
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync WAL segment: %w", err)
+	}
+	return file.Close()
+}
+
+// Pending reports whether any segments are waiting to be replayed
+func (w *WAL) Pending() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	segments, err := walSegments(w.dir)
+	return len(segments) > 0, err
+}
+
+// Drain replays every segment into sink, oldest first, deleting each one once it is delivered
+// Appends block until Drain returns. On error the failed segment is kept, so its events may be
+// sent again on the next drain; the usage processor deduplicates them by request_id
+func (w *WAL) Drain(sink ReplaySink) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.closeSegment(); err != nil {
+		return 0, err
+	}
+	return replayDir(w.dir, sink)
+}
+
+// Close syncs and closes the active segment, leaving all segments for replay
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeSegment()
+}
+
+// writeWAL appends a batch to the WAL instead of sending it to Kafka
+func (ep *EventProducer) writeWAL(batch []UsageEvent) {
+	for _, event := range batch {
+		if err := ep.wal.Append(event); err != nil {
+			metrics.RecordUsageError(event.OrganizationID, "wal_write_failed")
+			log.Printf("[EventProducer] ERROR: Failed to write event to WAL, dropping event for org %s: %v", event.OrganizationID, err)
+			continue
+		}
+		ep.walWritten.Add(1)
+	}
+}
+
+// reconnect drains the WAL into Kafka once the brokers answer again
+// It runs on the flush worker, so no batch is written to the WAL while it drains
+func (ep *EventProducer) reconnect() {
+	sink, err := ep.connect()
+	if err != nil {
+		return
+	}
+
+	replayed, err := ep.wal.Drain(sink)
+	if err != nil {
+		log.Printf("[EventProducer] ERROR: WAL replay stopped after %d events, retrying later: %v", replayed, err)
+		return
+	}
+
+	ep.online.Store(true)
+	log.Printf("[EventProducer] Kafka reachable again, replayed %d events from the WAL", replayed)
+}
+
+// ReplayWAL sends the events in a WAL directory, a single segment, or a spill file to sink
+// Each file is deleted once its events are delivered, so an interrupted replay can be rerun.
+// It must not be pointed at the WAL directory of a running gateway
+func ReplayWAL(path string, sink ReplaySink) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return replayDir(path, sink)
+	}
+	return replayFile(path, sink)
+}
+
+// replayDir replays the segments in dir, oldest first
+func replayDir(dir string, sink ReplaySink) (int, error) {
+	segments, err := walSegments(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, segment := range segments {
+		n, err := replayFile(filepath.Join(dir, segment), sink)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// replayFile sends every event in path, then deletes it once sink confirms delivery
+// Lines that do not parse (a write torn by a crash) are logged and skipped
+func replayFile(path string, sink ReplaySink) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxWALLineBytes)
+
+	sent := 0
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event UsageEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			log.Printf("[EventProducer] WARNING: Skipping unreadable WAL entry %s:%d: %v", path, line, err)
+			continue
+		}
+		if err := sink.Send(event); err != nil {
+			return sent, fmt.Errorf("failed to replay %s: %w", path, err)
+		}
+		sent++
+	}
+	if err := scanner.Err(); err != nil {
+		return sent, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := sink.Flush(); err != nil {
+		return sent, fmt.Errorf("failed to deliver %s: %w", path, err)
+	}
+
+	file.Close()
+	if err := os.Remove(path); err != nil {
+		return sent, fmt.Errorf("failed to remove replayed %s: %w", path, err)
+	}
+	return sent, nil
+}
+
+// walSegments lists the segment file names in dir, oldest first
+func walSegments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+
+	var segments []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, walSegmentPrefix) && strings.HasSuffix(name, walSegmentSuffix) && segmentSeq(name) > 0 {
+			segments = append(segments, name)
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+func segmentName(seq int64) string {
+	return fmt.Sprintf("%s%020d%s", walSegmentPrefix, seq, walSegmentSuffix)
+}
+
+// segmentSeq parses the sequence number from a segment name, or returns 0
+func segmentSeq(name string) int64 {
+	var seq int64
+	digits := strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentSuffix)
+	if _, err := fmt.Sscanf(digits, "%d", &seq); err != nil || segmentName(seq) != name {
+		return 0
+	}
+	return seq
+}
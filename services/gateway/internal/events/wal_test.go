@@ -0,0 +1,231 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSink records replayed events in place of Kafka
+type fakeSink struct {
+	mu      sync.Mutex
+	events  []UsageEvent
+	sendErr error
+	flushes int
+}
+
+func (s *fakeSink) Send(event UsageEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sendErr != nil {
+		return s.sendErr
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *fakeSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	return nil
+}
+
+func (s *fakeSink) received() []UsageEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]UsageEvent(nil), s.events...)
+}
+
+func walTestEvent(i int) UsageEvent {
+	return UsageEvent{RequestID: fmt.Sprintf("req-%03d", i), OrganizationID: "org_wal", StatusCode: 200, Billable: true}
+}
+
+func openTestWAL(t *testing.T, segmentBytes int64) (*WAL, string) {
+	dir := filepath.Join(t.TempDir(), "wal")
+	wal, err := OpenWAL(dir, segmentBytes)
+	if err != nil {
+		t.Fatalf("OpenWAL() error = %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	return wal, dir
+}
+
+func TestWAL_AppendRotatesSegments(t *testing.T) {
+	wal, dir := openTestWAL(t, 256)
+
+	for i := 0; i < 20; i++ {
+		if err := wal.Append(walTestEvent(i)); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	segments, err := walSegments(dir)
+	if err != nil {
+		t.Fatalf("walSegments() error = %v", err)
+	}
+	if len(segments) < 2 {
+		t.Fatalf("Got %d segments, want rotation past 256 bytes", len(segments))
+	}
+	if segments[0] != segmentName(1) {
+		t.Errorf("First segment = %s, want %s", segments[0], segmentName(1))
+	}
+
+	// Reopening continues after the newest segment instead of appending to old ones
+	wal.Close()
+	reopened, err := OpenWAL(dir, 256)
+	if err != nil {
+		t.Fatalf("OpenWAL() error = %v", err)
+	}
+	defer reopened.Close()
+	if err := reopened.Append(walTestEvent(20)); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentName(int64(len(segments)+1)))); err != nil {
+		t.Errorf("Reopened WAL did not start a new segment: %v", err)
+	}
+}
+
+func TestWAL_DrainReplaysInOrder(t *testing.T) {
+	wal, dir := openTestWAL(t, 256)
+	for i := 0; i < 20; i++ {
+		wal.Append(walTestEvent(i))
+	}
+
+	sink := &fakeSink{}
+	replayed, err := wal.Drain(sink)
+	if err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if replayed != 20 {
+		t.Errorf("Replayed = %d, want 20", replayed)
+	}
+
+	got := sink.received()
+	for i, event := range got {
+		if event.RequestID != walTestEvent(i).RequestID {
+			t.Fatalf("Event %d = %s, want %s (oldest first)", i, event.RequestID, walTestEvent(i).RequestID)
+		}
+	}
+
+	if pending, _ := wal.Pending(); pending {
+		t.Error("Segments remain after a successful drain")
+	}
+	if segments, _ := walSegments(dir); len(segments) != 0 {
+		t.Errorf("Segments = %v, want none", segments)
+	}
+}
+
+func TestWAL_DrainKeepsSegmentOnError(t *testing.T) {
+	wal, _ := openTestWAL(t, DefaultWALSegmentBytes)
+	wal.Append(walTestEvent(1))
+
+	if _, err := wal.Drain(&fakeSink{sendErr: errors.New("broker down")}); err == nil {
+		t.Fatal("Expected error from failing sink")
+	}
+	if pending, _ := wal.Pending(); !pending {
+		t.Fatal("Segment was deleted although delivery failed")
+	}
+
+	sink := &fakeSink{}
+	if replayed, err := wal.Drain(sink); err != nil || replayed != 1 {
+		t.Errorf("Retry Drain() = %d, %v, want 1 event", replayed, err)
+	}
+}
+
+func TestReplayWAL_File(t *testing.T) {
+	// Spill files share the segment format, so operators can replay either
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	spill, err := openSpillFile(path)
+	if err != nil {
+		t.Fatalf("openSpillFile() error = %v", err)
+	}
+	spill.Write(walTestEvent(1))
+	spill.Write(walTestEvent(2))
+	spill.Close()
+
+	// A torn final write from a crash is skipped, not fatal
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	f.WriteString(`{"request_id":"req-torn`)
+	f.Close()
+
+	sink := &fakeSink{}
+	replayed, err := ReplayWAL(path, sink)
+	if err != nil {
+		t.Fatalf("ReplayWAL() error = %v", err)
+	}
+	if replayed != 2 || sink.flushes != 1 {
+		t.Errorf("Replayed = %d with %d flushes, want 2 events and 1 flush", replayed, sink.flushes)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Replayed file was not removed")
+	}
+}
+
+func TestEventProducer_WALFallbackAndDrain(t *testing.T) {
+	wal, _ := openTestWAL(t, DefaultWALSegmentBytes)
+
+	sink := &fakeSink{}
+	var kafkaUp atomic.Bool
+	ep := &EventProducer{
+		buffer:            make(chan UsageEvent, 100),
+		stopCh:            make(chan struct{}),
+		stoppedCh:         make(chan struct{}),
+		batchSize:         10,
+		flushInterv:       5 * time.Millisecond,
+		wal:               wal,
+		reconnectInterval: 10 * time.Millisecond,
+		connect: func() (ReplaySink, error) {
+			if !kafkaUp.Load() {
+				return nil, errors.New("kafka unreachable")
+			}
+			return sink, nil
+		},
+	}
+	ep.flushWg.Add(1)
+	go ep.flushWorker()
+	defer func() {
+		close(ep.stopCh)
+		ep.flushWg.Wait()
+	}()
+
+	// Kafka down: the proxy's RecordUsage calls land in the WAL
+	for i := 0; i < 25; i++ {
+		ep.RecordUsage(walTestEvent(i))
+	}
+	waitFor(t, func() bool { return ep.walWritten.Load() == 25 })
+	if len(sink.received()) != 0 {
+		t.Fatal("Events reached the sink while Kafka was down")
+	}
+
+	// Kafka back: the reconnect loop drains the WAL and goes online
+	kafkaUp.Store(true)
+	waitFor(t, ep.online.Load)
+
+	got := sink.received()
+	if len(got) != 25 {
+		t.Fatalf("Drained %d events, want 25", len(got))
+	}
+	if got[0].RequestID != "req-000" || got[24].RequestID != "req-024" {
+		t.Errorf("Drained events out of order: first %s, last %s", got[0].RequestID, got[24].RequestID)
+	}
+	if pending, _ := wal.Pending(); pending {
+		t.Error("WAL still has segments after reconnect")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState represents the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed allows all requests through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fast-fails all requests until the cooldown expires
+	BreakerOpen
+	// BreakerHalfOpen allows a single probe request through
+	BreakerHalfOpen
+)
+
+// String returns a human-readable breaker state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig defines circuit breaker parameters
+type BreakerConfig struct {
+	FailureThreshold int           // Consecutive failures before tripping
+	Window           time.Duration // Failures must occur within this window
+	Cooldown         time.Duration // Time to stay open before probing
+}
+
+// CircuitBreaker tracks consecutive backend failures for a single service
+type CircuitBreaker struct {
+	config BreakerConfig
+
+	mu            sync.Mutex
+	state         BreakerState
+	failures      int
+	firstFailure  time.Time
+	openedAt      time.Time
+	probeInFlight bool
+	probeStarted  time.Time
+
+	onStateChange func(BreakerState)
+	now           func() time.Time // Overridable for tests
+}
+
+// NewCircuitBreaker creates a new circuit breaker in the closed state
+// onStateChange (optional) is called on every transition, e.g. to update metrics
+func NewCircuitBreaker(config BreakerConfig, onStateChange func(BreakerState)) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.Window <= 0 {
+		config.Window = 30 * time.Second
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+
+	cb := &CircuitBreaker{
+		config:        config,
+		state:         BreakerClosed,
+		onStateChange: onStateChange,
+		now:           time.Now,
+	}
+
+	if onStateChange != nil {
+		onStateChange(BreakerClosed)
+	}
+
+	return cb
+}
+
+// Allow reports whether a request may be sent to the backend
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		// Cooldown expired - let one probe through
+		if cb.now().Sub(cb.openedAt) >= cb.config.Cooldown {
+			cb.setState(BreakerHalfOpen)
+			cb.startProbe()
+			return true
+		}
+		return false
+
+	case BreakerHalfOpen:
+		// Only one probe at a time (unless the last probe never reported back)
+		if cb.probeInFlight && cb.now().Sub(cb.probeStarted) < cb.config.Cooldown {
+			return false
+		}
+		cb.startProbe()
+		return true
+
+	default:
+		return true
+	}
+}
+
+// RecordSuccess records a request that reached the backend
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.probeInFlight = false
+
+	if cb.state != BreakerClosed {
+		cb.setState(BreakerClosed)
+	}
+}
+
+// RecordFailure records a connection or timeout error
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+
+	// Failed probe - reopen for another cooldown
+	if cb.state == BreakerHalfOpen {
+		cb.probeInFlight = false
+		cb.openedAt = now
+		cb.setState(BreakerOpen)
+		return
+	}
+
+	if cb.state == BreakerOpen {
+		return
+	}
+
+	// Start a new window if the previous failures are stale
+	if cb.failures == 0 || now.Sub(cb.firstFailure) > cb.config.Window {
+		cb.failures = 0
+		cb.firstFailure = now
+	}
+
+	cb.failures++
+
+	if cb.failures >= cb.config.FailureThreshold {
+		cb.openedAt = now
+		cb.setState(BreakerOpen)
+	}
+}
+
+// State returns the current breaker state
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// startProbe marks a half-open probe as in flight (caller must hold the lock)
+func (cb *CircuitBreaker) startProbe() {
+	cb.probeInFlight = true
+	cb.probeStarted = cb.now()
+}
+
+// setState transitions the breaker (caller must hold the lock)
+func (cb *CircuitBreaker) setState(state BreakerState) {
+	cb.state = state
+	if state == BreakerClosed {
+		cb.failures = 0
+	}
+	if cb.onStateChange != nil {
+		cb.onStateChange(state)
+	}
+}
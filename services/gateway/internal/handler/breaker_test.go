@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestBreaker creates a breaker with a controllable clock
+func newTestBreaker(threshold int) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: threshold,
+		Window:           10 * time.Second,
+		Cooldown:         30 * time.Second,
+	}, nil)
+	cb.now = func() time.Time { return now }
+	return cb, &now
+}
+
+func TestCircuitBreaker_TripsAfterThreshold(t *testing.T) {
+	cb, _ := newTestBreaker(3)
+
+	for i := 0; i < 2; i++ {
+		cb.RecordFailure()
+		if cb.State() != BreakerClosed {
+			t.Fatalf("State after %d failures = %s, want closed", i+1, cb.State())
+		}
+	}
+
+	cb.RecordFailure()
+	if cb.State() != BreakerOpen {
+		t.Fatalf("State = %s, want open", cb.State())
+	}
+	if cb.Allow() {
+		t.Error("Expected open breaker to reject requests")
+	}
+}
+
+func TestCircuitBreaker_FailuresOutsideWindow(t *testing.T) {
+	cb, now := newTestBreaker(3)
+
+	cb.RecordFailure()
+	cb.RecordFailure()
+
+	// Window expires - count restarts
+	*now = now.Add(11 * time.Second)
+	cb.RecordFailure()
+
+	if cb.State() != BreakerClosed {
+		t.Errorf("State = %s, want closed (failures spread across windows)", cb.State())
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsCount(t *testing.T) {
+	cb, _ := newTestBreaker(3)
+
+	cb.RecordFailure()
+	cb.RecordFailure()
+	cb.RecordSuccess()
+	cb.RecordFailure()
+	cb.RecordFailure()
+
+	if cb.State() != BreakerClosed {
+		t.Errorf("State = %s, want closed (failures not consecutive)", cb.State())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	tests := []struct {
+		name          string
+		probeSucceeds bool
+		expected      BreakerState
+	}{
+		{"Probe succeeds closes breaker", true, BreakerClosed},
+		{"Probe fails reopens breaker", false, BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb, now := newTestBreaker(1)
+			cb.RecordFailure()
+
+			// Still cooling down
+			*now = now.Add(10 * time.Second)
+			if cb.Allow() {
+				t.Fatal("Expected request to be rejected during cooldown")
+			}
+
+			// Cooldown expired - exactly one probe allowed
+			*now = now.Add(20 * time.Second)
+			if !cb.Allow() {
+				t.Fatal("Expected probe request to be allowed")
+			}
+			if cb.State() != BreakerHalfOpen {
+				t.Fatalf("State = %s, want half_open", cb.State())
+			}
+			if cb.Allow() {
+				t.Error("Expected concurrent request to be rejected while probe is in flight")
+			}
+
+			if tt.probeSucceeds {
+				cb.RecordSuccess()
+			} else {
+				cb.RecordFailure()
+			}
+
+			if cb.State() != tt.expected {
+				t.Errorf("State = %s, want %s", cb.State(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_StateChangeCallback(t *testing.T) {
+	var states []BreakerState
+	cb := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, Cooldown: time.Millisecond}, func(s BreakerState) {
+		states = append(states, s)
+	})
+
+	cb.RecordFailure()
+	time.Sleep(2 * time.Millisecond)
+	cb.Allow()
+	cb.RecordSuccess()
+
+	expected := []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(states) != len(expected) {
+		t.Fatalf("States = %v, want %v", states, expected)
+	}
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Errorf("Transition %d = %s, want %s", i, states[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// gRPC backend schemes accepted in BACKEND_URLS
+// grpc:// is plaintext HTTP/2 (h2c), grpcs:// is HTTP/2 over TLS
+const (
+	grpcScheme    = "grpc"
+	grpcsScheme   = "grpcs"
+	grpcMediaType = "application/grpc"
+)
+
+// gRPC status codes used by the gateway itself
+const (
+	grpcCodeDeadlineExceeded = 4
+	grpcCodeUnavailable      = 14
+)
+
+// grpcHTTPStatus maps gRPC status codes to the HTTP status used for usage events and billing
+// gRPC responses are always HTTP 200, so the real outcome is in the grpc-status trailer
+var grpcHTTPStatus = map[int]int{
+	0:  http.StatusOK,                  // OK
+	1:  499,                            // Canceled (client closed request)
+	2:  http.StatusInternalServerError, // Unknown
+	3:  http.StatusBadRequest,          // InvalidArgument
+	4:  http.StatusGatewayTimeout,      // DeadlineExceeded
+	5:  http.StatusNotFound,            // NotFound
+	6:  http.StatusConflict,            // AlreadyExists
+	7:  http.StatusForbidden,           // PermissionDenied
+	8:  http.StatusTooManyRequests,     // ResourceExhausted
+	9:  http.StatusBadRequest,          // FailedPrecondition
+	10: http.StatusConflict,            // Aborted
+	11: http.StatusBadRequest,          // OutOfRange
+	12: http.StatusNotImplemented,      // Unimplemented
+	13: http.StatusInternalServerError, // Internal
+	14: http.StatusServiceUnavailable,  // Unavailable
+	15: http.StatusInternalServerError, // DataLoss
+	16: http.StatusUnauthorized,        // Unauthenticated
+}
+
+// isGRPCBackend reports whether a backend URL uses a gRPC scheme
+func isGRPCBackend(target *url.URL) bool {
+	return target.Scheme == grpcScheme || target.Scheme == grpcsScheme
+}
+
+// configureGRPCTransport restricts a backend transport to HTTP/2 and
+// rewrites the target to the http/https scheme the reverse proxy dials
+func configureGRPCTransport(transport *http.Transport, target *url.URL) {
+	transport.Protocols = new(http.Protocols)
+
+	if target.Scheme == grpcsScheme {
+		target.Scheme = "https"
+		transport.Protocols.SetHTTP2(true)
+	} else {
+		target.Scheme = "http"
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+}
+
+// isGRPCRequest reports whether a request carries a gRPC payload (application/grpc, application/grpc+proto, ...)
+func isGRPCRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return contentType == grpcMediaType ||
+		strings.HasPrefix(contentType, grpcMediaType+"+") ||
+		strings.HasPrefix(contentType, grpcMediaType+";")
+}
+
+// grpcMethod extracts the full gRPC method from a request path
+// Example: /orders-api/orders.v1.OrderService/GetOrder -> "/orders.v1.OrderService/GetOrder"
+func grpcMethod(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return path
+	}
+	return "/" + parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// grpcResponseStatus returns the HTTP equivalent of the grpc-status a backend sent
+// The status arrives as a trailer, or as a header for trailers-only error responses;
+// fallback is returned when no valid status is present
+func grpcResponseStatus(header http.Header, fallback int) int {
+	value := header.Get("Grpc-Status")
+	if value == "" {
+		value = header.Get(http.TrailerPrefix + "Grpc-Status")
+	}
+
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	if status, ok := grpcHTTPStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeGRPCError sends a trailers-only gRPC error so gRPC clients see a status instead of a JSON body
+func writeGRPCError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", grpcMediaType)
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", url.PathEscape(message))
+	w.WriteHeader(http.StatusOK)
+}
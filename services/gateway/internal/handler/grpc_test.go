@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saas-gateway/gateway/internal/config"
+)
+
+// grpcFrame wraps a message in the gRPC length-prefixed framing (uncompressed)
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(message)))
+	copy(frame[5:], message)
+	return frame
+}
+
+// newGRPCBackend starts a minimal h2c gRPC server that echoes unary requests
+func newGRPCBackend(t *testing.T) *httptest.Server {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" || r.Header.Get("TE") != "trailers" {
+			t.Errorf("Backend got %s %q TE=%q, want HTTP/2 application/grpc with TE: trailers",
+				r.Proto, r.Header.Get("Content-Type"), r.Header.Get("TE"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if len(body) < 5 {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "3")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		w.Write(grpcFrame(append([]byte("echo:"), body[5:]...)))
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "")
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+// newGRPCTestGateway serves the proxy over h2c with a gRPC backend named after its service
+func newGRPCTestGateway(t *testing.T, backendURL string, recorder *fakeUsageRecorder) *httptest.Server {
+	cfg := &config.Config{BackendURLs: map[string]string{"orders.v1.OrderService": backendURL}}
+	gateway := httptest.NewUnstartedServer(newTestGateway(t, cfg, recorder))
+	gateway.Config.Protocols = new(http.Protocols)
+	gateway.Config.Protocols.SetHTTP1(true)
+	gateway.Config.Protocols.SetUnencryptedHTTP2(true)
+	gateway.Start()
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+// newGRPCClient returns an HTTP/2 prior-knowledge (h2c) client, as gRPC clients use
+func newGRPCClient() *http.Client {
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: transport}
+}
+
+func grpcRequest(t *testing.T, url string, message []byte) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(grpcFrame(message)))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("Authorization", "Bearer sk_test_valid")
+	return req
+}
+
+func TestProxy_GRPCUnary(t *testing.T) {
+	backend := newGRPCBackend(t)
+	recorder := &fakeUsageRecorder{}
+	gateway := newGRPCTestGateway(t, strings.Replace(backend.URL, "http://", "grpc://", 1), recorder)
+
+	resp, err := newGRPCClient().Do(grpcRequest(t, gateway.URL+"/orders.v1.OrderService/GetOrder", []byte("order-42")))
+	if err != nil {
+		t.Fatalf("gRPC call failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Response = %s %d, want HTTP/2 200", resp.Proto, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/grpc" {
+		t.Errorf("Content-Type = %q, want application/grpc", got)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(body, grpcFrame([]byte("echo:order-42"))) {
+		t.Errorf("Body = %q, want framed echo:order-42", body)
+	}
+	// Trailers are only complete once the body has been read
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Errorf("Trailer Grpc-Status = %q, want 0", got)
+	}
+
+	if len(recorder.events) != 1 {
+		t.Fatalf("Got %d usage events, want 1", len(recorder.events))
+	}
+	event := recorder.events[0]
+	if event.Method != "GRPC" || event.Endpoint != "/orders.v1.OrderService/GetOrder" {
+		t.Errorf("Usage event = %s %s, want GRPC /orders.v1.OrderService/GetOrder", event.Method, event.Endpoint)
+	}
+	if event.StatusCode != http.StatusOK || !event.Billable {
+		t.Errorf("Usage event status = %d billable=%v, want billable 200", event.StatusCode, event.Billable)
+	}
+}
+
+func TestProxy_GRPCBackendUnavailable(t *testing.T) {
+	backend := newGRPCBackend(t)
+	backendURL := strings.Replace(backend.URL, "http://", "grpc://", 1)
+	backend.Close()
+
+	recorder := &fakeUsageRecorder{}
+	gateway := newGRPCTestGateway(t, backendURL, recorder)
+
+	resp, err := newGRPCClient().Do(grpcRequest(t, gateway.URL+"/orders.v1.OrderService/GetOrder", []byte("order-42")))
+	if err != nil {
+		t.Fatalf("gRPC call failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if got := resp.Header.Get("Grpc-Status"); got != "14" {
+		t.Errorf("Grpc-Status = %q, want 14 (UNAVAILABLE)", got)
+	}
+	if len(recorder.events) != 1 || recorder.events[0].Billable {
+		t.Errorf("Usage events = %+v, want one non-billable event", recorder.events)
+	}
+}
+
+func TestGRPCMethod(t *testing.T) {
+	tests := map[string]string{
+		"/orders.v1.OrderService/GetOrder":            "/orders.v1.OrderService/GetOrder",
+		"/orders-api/orders.v1.OrderService/GetOrder": "/orders.v1.OrderService/GetOrder",
+		"/health": "/health",
+	}
+	for path, want := range tests {
+		if got := grpcMethod(path); got != want {
+			t.Errorf("grpcMethod(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/saas-gateway/gateway/internal/config"
+	"github.com/saas-gateway/gateway/pkg/models"
+)
+
+// applyRequestTransform applies a backend's request header rules
+// Runs in the Director after the gateway's own headers, so rules can override them
+func applyRequestTransform(header http.Header, rules *config.HeaderTransform, reqCtx *models.RequestContext) {
+	if rules == nil {
+		return
+	}
+
+	for _, name := range rules.RemoveRequestHeaders {
+		header.Del(name)
+	}
+
+	for name, value := range rules.SetRequestHeaders {
+		header.Set(name, expandHeaderValue(value, reqCtx))
+	}
+}
+
+// applyResponseTransform applies a backend's response header rules
+// Runs in ModifyResponse, before the response is cached or returned to the client
+func applyResponseTransform(header http.Header, rules *config.HeaderTransform) {
+	if rules == nil {
+		return
+	}
+
+	for _, name := range rules.RemoveResponseHeaders {
+		header.Del(name)
+	}
+
+	for name, value := range rules.SetResponseHeaders {
+		header.Set(name, value)
+	}
+}
+
+// expandHeaderValue replaces {org_id}, {request_id} and {plan_tier} placeholders
+// Placeholders expand to empty strings when there is no request context
+func expandHeaderValue(value string, reqCtx *models.RequestContext) string {
+	if !strings.Contains(value, "{") {
+		return value
+	}
+
+	var orgID, requestID, planTier string
+	if reqCtx != nil {
+		requestID = reqCtx.RequestID
+		if reqCtx.APIKey != nil {
+			orgID = reqCtx.APIKey.OrganizationID
+			planTier = reqCtx.APIKey.PlanTier
+		}
+	}
+
+	return strings.NewReplacer(
+		"{org_id}", orgID,
+		"{request_id}", requestID,
+		"{plan_tier}", planTier,
+	).Replace(value)
+}
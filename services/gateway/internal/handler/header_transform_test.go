@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/saas-gateway/gateway/internal/config"
+	"github.com/saas-gateway/gateway/pkg/models"
+)
+
+// newTransformProxy proxies to backend with the rules wired like NewProxy does
+func newTransformProxy(t *testing.T, backend *httptest.Server, rules *config.HeaderTransform, reqCtx *models.RequestContext) *httputil.ReverseProxy {
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("Invalid backend URL: %v", err)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		applyRequestTransform(req.Header, rules, reqCtx)
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		applyResponseTransform(resp.Header, rules)
+		return nil
+	}
+	return proxy
+}
+
+func TestHeaderTransform_InjectsRequestHeader(t *testing.T) {
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	rules := &config.HeaderTransform{
+		SetRequestHeaders: map[string]string{
+			"X-Api-Version": "2024-01",
+			"X-Tenant":      "org={org_id}",
+		},
+		RemoveRequestHeaders: []string{"X-Debug"},
+	}
+	reqCtx := &models.RequestContext{
+		RequestID: "req-1",
+		APIKey:    &models.APIKey{OrganizationID: "org_123"},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("X-Debug", "true")
+	newTransformProxy(t, backend, rules, reqCtx).ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := received.Get("X-Api-Version"); got != "2024-01" {
+		t.Errorf("X-Api-Version = %q, want 2024-01", got)
+	}
+	if got := received.Get("X-Tenant"); got != "org=org_123" {
+		t.Errorf("X-Tenant = %q, want org=org_123", got)
+	}
+	if got := received.Get("X-Debug"); got != "" {
+		t.Errorf("X-Debug = %q, want stripped", got)
+	}
+}
+
+func TestHeaderTransform_StripsResponseHeader(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Internal-Host", "users-7f9c")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+
+	rules := &config.HeaderTransform{
+		RemoveResponseHeaders: []string{"X-Internal-Host"},
+		SetResponseHeaders:    map[string]string{"X-Served-By": "gateway"},
+	}
+
+	rec := httptest.NewRecorder()
+	newTransformProxy(t, backend, rules, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if got := rec.Header().Get("X-Internal-Host"); got != "" {
+		t.Errorf("X-Internal-Host = %q, want stripped", got)
+	}
+	if got := rec.Header().Get("X-Served-By"); got != "gateway" {
+		t.Errorf("X-Served-By = %q, want gateway", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want untouched", got)
+	}
+}
+
+func TestExpandHeaderValue(t *testing.T) {
+	reqCtx := &models.RequestContext{
+		RequestID: "req-9",
+		APIKey:    &models.APIKey{OrganizationID: "org_1", PlanTier: "pro"},
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		reqCtx   *models.RequestContext
+		expected string
+	}{
+		{"No placeholders", "v2", reqCtx, "v2"},
+		{"All placeholders", "{org_id}/{request_id}/{plan_tier}", reqCtx, "org_1/req-9/pro"},
+		{"No request context", "org={org_id}", nil, "org="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandHeaderValue(tt.value, tt.reqCtx); got != tt.expected {
+				t.Errorf("expandHeaderValue(%q) = %q, want %q", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
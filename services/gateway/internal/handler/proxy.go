@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -11,22 +16,58 @@ import (
 
 	"github.com/saas-gateway/gateway/internal/config"
 	"github.com/saas-gateway/gateway/internal/events"
+	"github.com/saas-gateway/gateway/internal/metrics"
 	"github.com/saas-gateway/gateway/internal/middleware"
+	"github.com/saas-gateway/gateway/pkg/models"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // Proxy handles reverse proxying to backend services
 type Proxy struct {
 	config        *config.Config
 	proxies       map[string]*httputil.ReverseProxy
-	eventProducer *events.EventProducer
+	breakers      map[string]*CircuitBreaker
+	eventProducer usageRecorder  // Optional, nil disables usage tracking
+	responseCache *ResponseCache // Optional, nil disables response caching
+	usageSampler  *usageSampler  // Optional, nil records every usage event
+	tracer        trace.Tracer   // No-op unless UseTracing is called
+	propagator    propagation.TextMapPropagator
+}
+
+// usageRecorder queues usage events for billing (implemented by events.EventProducer)
+type usageRecorder interface {
+	RecordUsage(event events.UsageEvent)
 }
 
 // NewProxy creates a new proxy handler
-func NewProxy(cfg *config.Config, eventProducer *events.EventProducer) (*Proxy, error) {
+func NewProxy(cfg *config.Config, eventProducer *events.EventProducer, responseCache *ResponseCache) (*Proxy, error) {
 	p := &Proxy{
 		config:        cfg,
 		proxies:       make(map[string]*httputil.ReverseProxy),
-		eventProducer: eventProducer,
+		breakers:      make(map[string]*CircuitBreaker),
+		responseCache: responseCache,
+		usageSampler:  newUsageSampler(cfg.UsageSamplingRates),
+		tracer:        noop.NewTracerProvider().Tracer(tracerName),
+		propagator:    propagation.NewCompositeTextMapPropagator(),
+	}
+	if eventProducer != nil {
+		p.eventProducer = eventProducer
+	}
+
+	breakerConfig := BreakerConfig{
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		Window:           cfg.BreakerWindow,
+		Cooldown:         cfg.BreakerCooldown,
+	}
+
+	retryConfig := RetryConfig{
+		MaxRetries:   cfg.ProxyRetryMax,
+		Backoff:      cfg.ProxyRetryBackoff,
+		MaxBackoff:   cfg.ProxyRetryMaxBackoff,
+		Statuses:     cfg.ProxyRetryStatuses,
+		MaxBodyBytes: cfg.ProxyRetryMaxBodyBytes,
 	}
 
 	// Create reverse proxies for each backend
@@ -36,7 +77,24 @@ func NewProxy(cfg *config.Config, eventProducer *events.EventProducer) (*Proxy,
 			return nil, fmt.Errorf("invalid backend URL for %s: %w", serviceName, err)
 		}
 
+		// Each backend gets its own transport so timeouts and pool sizes are tuned per service;
+		// gRPC backends need HTTP/2 end to end, so rewrite the scheme before the director captures it
+		transport := newBackendTransport(cfg.TransportFor(serviceName))
+		grpcBackend := isGRPCBackend(target)
+		if grpcBackend {
+			configureGRPCTransport(transport, target)
+		}
+
+		// Transient failures of idempotent requests are retried before the client sees them
+		name := serviceName
 		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.Transport = newRetryTransport(transport, retryConfig, func() {
+			metrics.RecordBackendRetry(name)
+		})
+		if grpcBackend {
+			proxy.FlushInterval = -1 // Stream gRPC messages as they arrive
+		}
+		headerRules := cfg.HeaderTransforms[serviceName] // nil when the backend has no rules
 
 		// Customize the director to preserve the original path
 		originalDirector := proxy.Director
@@ -55,12 +113,32 @@ func NewProxy(cfg *config.Config, eventProducer *events.EventProducer) (*Proxy,
 				req.Header.Set("X-Organization-ID", reqCtx.APIKey.OrganizationID)
 				req.Header.Set("X-Plan-Tier", reqCtx.APIKey.PlanTier)
 			}
+
+			// Continue the gateway's trace in the backend
+			p.injectTraceContext(req)
+
+			// Per-backend rules run last so they can override the headers above
+			reqCtx, _ := middleware.GetRequestContext(req)
+			applyRequestTransform(req.Header, headerRules, reqCtx)
+		}
+
+		// Apply per-backend response header rules before caching/returning
+		if headerRules != nil {
+			proxy.ModifyResponse = func(resp *http.Response) error {
+				applyResponseTransform(resp.Header, headerRules)
+				return nil
+			}
 		}
 
 		// Customize error handler
 		proxy.ErrorHandler = p.errorHandler
 
 		p.proxies[serviceName] = proxy
+
+		// One breaker per backend so a failing service doesn't affect others
+		p.breakers[serviceName] = NewCircuitBreaker(breakerConfig, func(state BreakerState) {
+			metrics.SetCircuitBreakerState(name, int(state))
+		})
 	}
 
 	return p, nil
@@ -88,7 +166,8 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !exists {
 		// Try default backend
 		if len(p.proxies) == 1 {
-			for _, defaultProxy := range p.proxies {
+			for defaultName, defaultProxy := range p.proxies {
+				serviceName = defaultName
 				proxy = defaultProxy
 				break
 			}
@@ -98,32 +177,141 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Trace the backend call (no-op unless tracing is enabled)
+	w, r, finishSpan := p.traceProxy(w, r, reqCtx, serviceName)
+	defer finishSpan()
+
+	// Serve idempotent GETs from the response cache when possible
+	var cacheKey string
+	if p.responseCache != nil && isCacheableRequest(r) {
+		cacheKey = p.responseCache.Key(reqCtx.APIKey.OrganizationID, r.Method, r.URL.Path, r.URL.RawQuery)
+
+		if cached, hit := p.responseCache.Get(r.Context(), cacheKey); hit {
+			metrics.RecordCacheHit("response")
+			p.writeCachedResponse(w, cached)
+			p.recordUsage(reqCtx, r, cached.StatusCode, startTime, true, requestBodySize(r), int64(len(cached.Body)))
+			return
+		}
+		metrics.RecordCacheMiss("response")
+	}
+
+	// Fast-fail if the backend's circuit breaker is open
+	breaker := p.breakers[serviceName]
+	if breaker != nil && !breaker.Allow() {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(p.config.BreakerCooldown.Seconds())))
+		p.respondError(w, http.StatusServiceUnavailable, fmt.Sprintf("service '%s' temporarily unavailable", serviceName))
+		return
+	}
+
 	// Create response writer wrapper to capture status code
 	rw := &responseWriter{
 		ResponseWriter: w,
 		statusCode:     http.StatusOK, // Default
 	}
+	if cacheKey != "" {
+		rw.body = &bytes.Buffer{}
+	}
+
+	// Count the request body as the backend reads it (bandwidth billing)
+	var body *countingReader
+	if r.Body != nil && r.Body != http.NoBody {
+		body = &countingReader{ReadCloser: r.Body}
+		r.Body = body
+	}
 
 	// Proxy the request
 	proxy.ServeHTTP(rw, r)
 
+	// Store cacheable responses for subsequent requests
+	if cacheKey != "" && rw.proxyErr == nil && !rw.bodyTruncated && isCacheableResponse(rw.statusCode, rw.Header()) {
+		err := p.responseCache.Set(r.Context(), cacheKey, &CachedResponse{
+			StatusCode: rw.statusCode,
+			Header:     rw.Header().Clone(),
+			Body:       rw.body.Bytes(),
+		})
+		if err != nil {
+			middleware.RequestLogger(r).Error("failed to cache response", slog.String("error", err.Error()))
+		}
+	}
+
+	// Only connection/timeout errors count against the breaker;
+	// any response from the backend (including 4xx/5xx) means it is reachable
+	if breaker != nil {
+		switch {
+		case rw.proxyErr == nil:
+			breaker.RecordSuccess()
+		case !errors.Is(rw.proxyErr, context.Canceled):
+			breaker.RecordFailure()
+		}
+	}
+
+	// gRPC responses are HTTP 200; bill on the grpc-status the backend returned
+	statusCode := rw.statusCode
+	if isGRPCRequest(r) {
+		statusCode = grpcResponseStatus(rw.Header(), statusCode)
+	}
+
+	var bytesIn int64
+	if body != nil {
+		bytesIn = body.n
+	}
+
+	p.recordUsage(reqCtx, r, statusCode, startTime, false, bytesIn, rw.bytesWritten)
+}
+
+// recordUsage emits a usage event to Kafka (async, non-blocking)
+// cached=true marks responses served from the response cache so they can be billed differently
+// bytesIn and bytesOut are the request and response body sizes, billed as bandwidth
+func (p *Proxy) recordUsage(reqCtx *models.RequestContext, r *http.Request, statusCode int, startTime time.Time, cached bool, bytesIn, bytesOut int64) {
+	if p.eventProducer == nil {
+		return
+	}
+
 	// Calculate response time
 	responseTime := time.Since(startTime).Milliseconds()
 
-	// Emit usage event to Kafka (async, non-blocking)
-	if p.eventProducer != nil {
-		p.eventProducer.RecordUsage(events.UsageEvent{
-			RequestID:      reqCtx.RequestID,
-			OrganizationID: reqCtx.APIKey.OrganizationID,
-			APIKeyID:       reqCtx.APIKey.ID.String(),
-			Endpoint:       r.URL.Path,
-			Method:         r.Method,
-			StatusCode:     rw.statusCode,
-			ResponseTimeMs: responseTime,
-			Timestamp:      startTime,
-			Billable:       p.isBillable(rw.statusCode),
-		})
+	// gRPC calls are recorded by their full method, e.g. GRPC /orders.v1.OrderService/GetOrder
+	endpoint, method := r.URL.Path, r.Method
+	if isGRPCRequest(r) {
+		endpoint, method = grpcMethod(r.URL.Path), "GRPC"
+	}
+
+	// The correlation ID is the one logged by the gateway and sent to the backend as X-Request-ID
+	event := events.UsageEvent{
+		RequestID:      reqCtx.RequestID,
+		OrganizationID: reqCtx.APIKey.OrganizationID,
+		APIKeyID:       reqCtx.APIKey.ID.String(),
+		Endpoint:       endpoint,
+		Method:         method,
+		StatusCode:     statusCode,
+		ResponseTimeMs: responseTime,
+		Timestamp:      startTime,
+		Billable:       p.isBillable(statusCode, reqCtx.APIKey.PlanTier),
+		Cached:         cached,
+		Region:         clientRegion(r, p.config.RegionHeaders),
+		BytesIn:        bytesIn,
+		BytesOut:       bytesOut,
 	}
+
+	// Sampled-out events are still counted so non-billable volume stays visible
+	if !p.usageSampler.Keep(&event, reqCtx.APIKey.PlanTier) {
+		metrics.RecordUsageSampledOut(event.OrganizationID, reqCtx.APIKey.PlanTier)
+		return
+	}
+
+	p.eventProducer.RecordUsage(event)
+}
+
+// writeCachedResponse replays a cached backend response
+func (p *Proxy) writeCachedResponse(w http.ResponseWriter, cached *CachedResponse) {
+	for key, values := range cached.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(cached.StatusCode)
+	w.Write(cached.Body)
 }
 
 // extractServiceName extracts the service name from the URL path
@@ -150,6 +338,11 @@ func (p *Proxy) extractServiceName(path string) string {
 func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
 	reqCtx, _ := middleware.GetRequestContext(r)
 
+	// Flag the transport error so ServeHTTP can feed the circuit breaker
+	if rw, ok := w.(*responseWriter); ok {
+		rw.proxyErr = err
+	}
+
 	// Log the error (structured logging will capture this)
 	statusCode := http.StatusBadGateway
 	message := "backend service unavailable"
@@ -160,6 +353,16 @@ func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error)
 		message = "backend service timeout"
 	}
 
+	// gRPC clients expect a grpc-status, not a JSON body
+	if isGRPCRequest(r) {
+		code := grpcCodeUnavailable
+		if statusCode == http.StatusGatewayTimeout {
+			code = grpcCodeDeadlineExceeded
+		}
+		writeGRPCError(w, code, message)
+		return
+	}
+
 	// Build error response
 	response := map[string]interface{}{
 		"error": map[string]interface{}{
@@ -196,6 +399,13 @@ func (p *Proxy) respondError(w http.ResponseWriter, statusCode int, message stri
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	proxyErr   error // Set when the backend could not be reached
+
+	// Response body capture for the response cache (nil when not caching)
+	body          *bytes.Buffer
+	bodyTruncated bool
+
+	bytesWritten int64 // Response body bytes sent to the client
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -203,9 +413,49 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer so the reverse proxy can flush streamed (gRPC) responses
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.body != nil && !rw.bodyTruncated {
+		if rw.body.Len()+len(b) > MaxCachedResponseBytes {
+			// Too large to cache - stop capturing
+			rw.bodyTruncated = true
+			rw.body.Reset()
+		} else {
+			rw.body.Write(b)
+		}
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
+}
+
+// countingReader counts the request body bytes read by the reverse proxy
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// requestBodySize returns the declared request body size for requests that are not proxied
+func requestBodySize(r *http.Request) int64 {
+	if r.ContentLength > 0 {
+		return r.ContentLength
+	}
+	return 0
+}
+
 // isBillable determines if a request should be billed based on status code
-func (p *Proxy) isBillable(statusCode int) bool {
-	// Bill for successful requests (2xx) and client errors (4xx)
-	// Don't bill for server errors (5xx) as they're our fault
-	return statusCode >= 200 && statusCode < 500
+// The plan's billable status policy decides; by default 2xx and 4xx are billed and 5xx
+// (our fault) are not, but contracts may also exclude e.g. 404s or backend 429s
+func (p *Proxy) isBillable(statusCode int, planTier string) bool {
+	return p.config.BillableStatusesFor(planTier).Contains(statusCode)
 }
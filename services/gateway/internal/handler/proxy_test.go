@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/cache"
+	"github.com/saas-gateway/gateway/internal/config"
+	"github.com/saas-gateway/gateway/internal/events"
+	"github.com/saas-gateway/gateway/internal/middleware"
+)
+
+// fakeUsageRecorder captures usage events instead of sending them to Kafka
+type fakeUsageRecorder struct {
+	events []events.UsageEvent
+}
+
+func (f *fakeUsageRecorder) RecordUsage(event events.UsageEvent) {
+	f.events = append(f.events, event)
+}
+
+// newTracingTestGateway chains the logger, auth and proxy like cmd/server, with one cached key
+func newTracingTestGateway(t *testing.T, backendURL string, recorder *fakeUsageRecorder) http.Handler {
+	return newTestGateway(t, &config.Config{BackendURLs: map[string]string{"users-api": backendURL}}, recorder)
+}
+
+// newTestGateway is newTracingTestGateway for an arbitrary gateway config
+func newTestGateway(t *testing.T, cfg *config.Config, recorder *fakeUsageRecorder) http.Handler {
+	return chainTestGateway(cfg, newTestProxy(t, cfg, recorder))
+}
+
+// newTestProxy creates a proxy that records usage into recorder
+func newTestProxy(t *testing.T, cfg *config.Config, recorder *fakeUsageRecorder) *Proxy {
+	proxy, err := NewProxy(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProxy() error = %v", err)
+	}
+	proxy.eventProducer = recorder
+	return proxy
+}
+
+// chainTestGateway puts the logger and auth in front of proxy, with one cached key
+func chainTestGateway(cfg *config.Config, proxy *Proxy) http.Handler {
+	return chainTestGatewayKeys(cfg, proxy, map[string]*cache.CachedKey{"sk_test_valid": {OrganizationID: "org_1"}})
+}
+
+// chainTestGatewayKeys is chainTestGateway with keys cached as the repository loads them
+func chainTestGatewayKeys(cfg *config.Config, proxy *Proxy, keys map[string]*cache.CachedKey) http.Handler {
+	keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+	for apiKey, cached := range keys {
+		hash := sha256.Sum256([]byte(apiKey))
+		keyCache.Set(hex.EncodeToString(hash[:]), cached)
+	}
+
+	logger := middleware.NewLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	auth := middleware.NewAuth(cfg, keyCache, nil)
+	return logger.Middleware(auth.Middleware(proxy))
+}
+
+func TestProxy_RequestIDPropagation(t *testing.T) {
+	tests := []struct {
+		name      string
+		inboundID string
+		keepsID   bool
+	}{
+		{"Inbound ID honored", "client-req-7f3a.42", true},
+		{"No inbound ID", "", false},
+		{"Invalid inbound ID replaced", "bad id\r\ninjected", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var backendID string
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				backendID = r.Header.Get("X-Request-ID")
+			}))
+			defer backend.Close()
+
+			recorder := &fakeUsageRecorder{}
+			gateway := newTracingTestGateway(t, backend.URL, recorder)
+
+			req := httptest.NewRequest(http.MethodGet, "/users-api/users", nil)
+			req.Header.Set("Authorization", "Bearer sk_test_valid")
+			if tt.inboundID != "" {
+				req.Header.Set("X-Request-ID", tt.inboundID)
+			}
+
+			rec := httptest.NewRecorder()
+			gateway.ServeHTTP(rec, req)
+
+			if len(recorder.events) != 1 {
+				t.Fatalf("Got %d usage events, want 1", len(recorder.events))
+			}
+			eventID := recorder.events[0].RequestID
+
+			if eventID == "" {
+				t.Fatal("Usage event has no request ID")
+			}
+			if tt.keepsID && eventID != tt.inboundID {
+				t.Errorf("Usage event RequestID = %q, want inbound %q", eventID, tt.inboundID)
+			}
+			if !tt.keepsID && eventID == tt.inboundID {
+				t.Errorf("Usage event RequestID = %q, want a generated ID", eventID)
+			}
+			if backendID != eventID {
+				t.Errorf("Backend X-Request-ID = %q, want %q", backendID, eventID)
+			}
+			if got := rec.Header().Get("X-Request-ID"); got != eventID {
+				t.Errorf("Response X-Request-ID = %q, want %q", got, eventID)
+			}
+		})
+	}
+}
+
+func TestProxy_RecordsBodyBytes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer backend.Close()
+
+	recorder := &fakeUsageRecorder{}
+	gateway := newTracingTestGateway(t, backend.URL, recorder)
+
+	req := httptest.NewRequest(http.MethodPost, "/users-api/users", strings.NewReader(strings.Repeat("y", 300)))
+	req.Header.Set("Authorization", "Bearer sk_test_valid")
+	gateway.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(recorder.events) != 1 {
+		t.Fatalf("Got %d usage events, want 1", len(recorder.events))
+	}
+	if event := recorder.events[0]; event.BytesIn != 300 || event.BytesOut != 2048 {
+		t.Errorf("Usage event bytes in/out = %d/%d, want 300/2048", event.BytesIn, event.BytesOut)
+	}
+}
+
+func TestProxy_SamplesByOrganizationPlan(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	recorder := &fakeUsageRecorder{}
+	cfg := &config.Config{
+		BackendURLs:        map[string]string{"users-api": backend.URL},
+		BillableStatuses:   config.StatusRanges{{Min: 200, Max: 499}},
+		UsageSamplingRates: map[string]int{"basic": 10},
+	}
+	gateway := chainTestGatewayKeys(cfg, newTestProxy(t, cfg, recorder), map[string]*cache.CachedKey{
+		"sk_test_basic":   {OrganizationID: "org_basic", PlanTier: "basic"},
+		"sk_test_premium": {OrganizationID: "org_premium", PlanTier: "premium"},
+	})
+
+	for _, apiKey := range []string{"sk_test_basic", "sk_test_premium"} {
+		for i := 0; i < 10; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/users-api/users", nil)
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			gateway.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}
+
+	// Non-billable 500s: 1 in 10 of the basic org's are kept, all of the premium org's
+	counts := make(map[string]int)
+	for _, event := range recorder.events {
+		counts[event.OrganizationID]++
+	}
+	if counts["org_basic"] != 1 || counts["org_premium"] != 10 {
+		t.Errorf("Events per org = %v, want org_basic=1 org_premium=10", counts)
+	}
+}
+
+func TestProxy_BillsByOrganizationPlan(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.WriteHeader(status)
+	}))
+	defer backend.Close()
+
+	recorder := &fakeUsageRecorder{}
+	cfg := &config.Config{
+		BackendURLs:      map[string]string{"users-api": backend.URL},
+		BillableStatuses: config.StatusRanges{{Min: 200, Max: 499}},
+		PlanBillableStatuses: map[string]config.StatusRanges{
+			"enterprise": {{Min: 200, Max: 403}, {Min: 405, Max: 499}},
+			"premium":    {{Min: 200, Max: 299}},
+		},
+	}
+	gateway := chainTestGatewayKeys(cfg, newTestProxy(t, cfg, recorder), map[string]*cache.CachedKey{
+		"sk_test_basic":      {OrganizationID: "org_basic", PlanTier: "basic"},
+		"sk_test_premium":    {OrganizationID: "org_premium", PlanTier: "premium"},
+		"sk_test_enterprise": {OrganizationID: "org_enterprise", PlanTier: "enterprise"},
+	})
+
+	tests := []struct {
+		apiKey   string
+		status   int
+		billable bool
+	}{
+		{"sk_test_basic", 404, true}, // Global policy
+		{"sk_test_premium", 200, true},
+		{"sk_test_premium", 404, false},
+		{"sk_test_enterprise", 404, false},
+		{"sk_test_enterprise", 429, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.apiKey, tt.status), func(t *testing.T) {
+			recorder.events = nil
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users-api/users?status=%d", tt.status), nil)
+			req.Header.Set("Authorization", "Bearer "+tt.apiKey)
+			gateway.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(recorder.events) != 1 {
+				t.Fatalf("Got %d usage events, want 1", len(recorder.events))
+			}
+			if event := recorder.events[0]; event.StatusCode != tt.status || event.Billable != tt.billable {
+				t.Errorf("Event status %d billable = %v, want %d billable = %v", event.StatusCode, event.Billable, tt.status, tt.billable)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// UnknownRegion is recorded when no region header is present
+const UnknownRegion = "unknown"
+
+// clientRegion resolves the client region from CDN-provided headers (e.g. CF-IPCountry)
+// Headers are checked in order; the first usable value wins
+func clientRegion(r *http.Request, headers []string) string {
+	for _, header := range headers {
+		region := strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+
+		// Cloudflare sends XX when the country cannot be determined
+		if region == "" || region == "XX" {
+			continue
+		}
+
+		return region
+	}
+
+	return UnknownRegion
+}
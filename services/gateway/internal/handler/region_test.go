@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+var testRegionHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country"}
+
+func TestClientRegion(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{"Cloudflare header", map[string]string{"CF-IPCountry": "US"}, "US"},
+		{"Lowercase value normalized", map[string]string{"CF-IPCountry": " de "}, "DE"},
+		{"Falls back to second header", map[string]string{"CloudFront-Viewer-Country": "JP"}, "JP"},
+		{"First header wins", map[string]string{"CF-IPCountry": "FR", "CloudFront-Viewer-Country": "JP"}, "FR"},
+		{"Cloudflare unknown country", map[string]string{"CF-IPCountry": "XX"}, UnknownRegion},
+		{"Missing header", map[string]string{}, UnknownRegion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/api/users", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			if got := clientRegion(r, testRegionHeaders); got != tt.expected {
+				t.Errorf("clientRegion() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClientRegion_NoHeadersConfigured(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/api/users", nil)
+	r.Header.Set("CF-IPCountry", "US")
+
+	if got := clientRegion(r, nil); got != UnknownRegion {
+		t.Errorf("clientRegion() with enrichment disabled = %q, want %q", got, UnknownRegion)
+	}
+}
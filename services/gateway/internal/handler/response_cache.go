@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/ratelimit"
+)
+
+// MaxCachedResponseBytes caps the size of a response body stored in the cache
+const MaxCachedResponseBytes = 1 << 20 // 1 MiB
+
+// CachedResponse is a backend response stored in Redis
+type CachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// ResponseCache caches idempotent GET responses in Redis
+type ResponseCache struct {
+	redis *ratelimit.RedisClient
+	ttl   time.Duration
+}
+
+// NewResponseCache creates a new Redis-backed response cache
+func NewResponseCache(redisClient *ratelimit.RedisClient, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		redis: redisClient,
+		ttl:   ttl,
+	}
+}
+
+// Key builds the cache key for a request, scoped to the organization
+// Format: resp_cache:{org_id}:{sha256(method path?query)}
+func (c *ResponseCache) Key(orgID, method, path, rawQuery string) string {
+	hash := sha256.Sum256([]byte(method + " " + path + "?" + rawQuery))
+	return fmt.Sprintf("resp_cache:%s:%s", orgID, hex.EncodeToString(hash[:]))
+}
+
+// Get retrieves a cached response, returning false on miss or error
+func (c *ResponseCache) Get(ctx context.Context, key string) (*CachedResponse, bool) {
+	data, err := c.redis.GetClient().Get(ctx, key).Bytes()
+	if err != nil {
+		// redis.Nil is a normal miss; other errors degrade to a miss
+		return nil, false
+	}
+
+	var resp CachedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false
+	}
+
+	return &resp, true
+}
+
+// Set stores a response with the configured TTL
+func (c *ResponseCache) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+
+	if err := c.redis.GetClient().Set(ctx, key, data, c.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store cached response: %w", err)
+	}
+
+	return nil
+}
+
+// isCacheableRequest reports whether a request may be served from cache
+func isCacheableRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet
+}
+
+// isCacheableResponse reports whether a backend response may be stored
+// Only 200 responses are cached, and backends can opt out with Cache-Control: no-store
+func isCacheableResponse(statusCode int, header http.Header) bool {
+	if statusCode != http.StatusOK {
+		return false
+	}
+
+	// Never cache per-user session state
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	// Key does not include request headers, so a response chosen by them (Vary: Accept-Encoding,
+	// Accept-Language, *) would be replayed to clients that asked for something else
+	if len(header.Values("Vary")) > 0 {
+		return false
+	}
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+				return false
+			}
+		}
+	}
+
+	return true
+}
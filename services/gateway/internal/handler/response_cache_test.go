@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/config"
+	"github.com/saas-gateway/gateway/internal/ratelimit"
+)
+
+func TestIsCacheableResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		header     http.Header
+		expected   bool
+	}{
+		{"200 without directives", http.StatusOK, http.Header{}, true},
+		{"200 with max-age", http.StatusOK, http.Header{"Cache-Control": {"public, max-age=60"}}, true},
+		{"200 with no-store", http.StatusOK, http.Header{"Cache-Control": {"private, no-store"}}, false},
+		{"200 with mixed-case no-store", http.StatusOK, http.Header{"Cache-Control": {"No-Store"}}, false},
+		{"200 with Set-Cookie", http.StatusOK, http.Header{"Set-Cookie": {"session=abc"}}, false},
+		{"200 with Vary: Accept-Encoding", http.StatusOK, http.Header{"Vary": {"Accept-Encoding"}}, false},
+		{"200 with Vary: *", http.StatusOK, http.Header{"Vary": {"*"}}, false},
+		{"201 Created", http.StatusCreated, http.Header{}, false},
+		{"404 Not Found", http.StatusNotFound, http.Header{}, false},
+		{"500 Internal Server Error", http.StatusInternalServerError, http.Header{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCacheableResponse(tt.statusCode, tt.header); got != tt.expected {
+				t.Errorf("isCacheableResponse() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsCacheableRequest(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodHead} {
+		r, _ := http.NewRequest(method, "/api/users", nil)
+		if isCacheableRequest(r) {
+			t.Errorf("isCacheableRequest(%s) = true, want false", method)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/api/users", nil)
+	if !isCacheableRequest(r) {
+		t.Error("isCacheableRequest(GET) = false, want true")
+	}
+}
+
+func TestResponseCache_KeyScoping(t *testing.T) {
+	c := NewResponseCache(nil, 0)
+	base := c.Key("org_1", http.MethodGet, "/api/users", "page=1")
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"Different organization", c.Key("org_2", http.MethodGet, "/api/users", "page=1")},
+		{"Different path", c.Key("org_1", http.MethodGet, "/api/orders", "page=1")},
+		{"Different query", c.Key("org_1", http.MethodGet, "/api/users", "page=2")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key == base {
+				t.Errorf("Key collision with base key: %s", tt.key)
+			}
+		})
+	}
+
+	if again := c.Key("org_1", http.MethodGet, "/api/users", "page=1"); again != base {
+		t.Errorf("Key() not deterministic: %s != %s", again, base)
+	}
+}
+
+// fakeRedisServer answers the PING, GET and SET commands ResponseCache sends, over RESP
+type fakeRedisServer struct {
+	mu   sync.Mutex
+	data map[string]string
+	sets int
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedisServer, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeRedisServer{data: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, ln.Addr().String()
+}
+
+func (s *fakeRedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			io.WriteString(conn, "+PONG\r\n")
+		case "GET":
+			if value, ok := s.data[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		case "SET":
+			s.data[args[1]] = args[2]
+			s.sets++
+			io.WriteString(conn, "+OK\r\n")
+		default:
+			io.WriteString(conn, "-ERR unknown command\r\n")
+		}
+		s.mu.Unlock()
+	}
+}
+
+// readRESPCommand reads one command, an array of bulk strings
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("unexpected command line %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected bulk string line %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// TestProxy_ResponseCacheSkipsVaryingResponses tests that a gzip response is not replayed to a client
+// that did not ask for gzip
+func TestProxy_ResponseCacheSkipsVaryingResponses(t *testing.T) {
+	const body = "plain users list"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Encoding")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			io.WriteString(w, body)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		io.WriteString(gz, body)
+		gz.Close()
+	}))
+	defer backend.Close()
+
+	redisServer, addr := startFakeRedis(t)
+	redisClient, err := ratelimit.NewRedisClient(ratelimit.RedisConfig{Addr: addr})
+	if err != nil {
+		t.Fatalf("NewRedisClient() error = %v", err)
+	}
+	defer redisClient.Close()
+
+	cfg := &config.Config{BackendURLs: map[string]string{"users-api": backend.URL}}
+	proxy, err := NewProxy(cfg, nil, NewResponseCache(redisClient, time.Minute))
+	if err != nil {
+		t.Fatalf("NewProxy() error = %v", err)
+	}
+	proxy.eventProducer = &fakeUsageRecorder{}
+	gateway := chainTestGateway(cfg, proxy)
+
+	get := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users-api/users", nil)
+		req.Header.Set("Authorization", "Bearer sk_test_valid")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		gateway.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("gzip"); rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip client Content-Encoding = %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+
+	rec := get("")
+	if rec.Header().Get("X-Cache") == "HIT" {
+		t.Error("Plain client was served the cached gzip response")
+	}
+	if rec.Header().Get("Content-Encoding") != "" || !bytes.Equal(rec.Body.Bytes(), []byte(body)) {
+		t.Errorf("Plain client got Content-Encoding %q and body %q, want %q unencoded",
+			rec.Header().Get("Content-Encoding"), rec.Body.String(), body)
+	}
+
+	redisServer.mu.Lock()
+	defer redisServer.mu.Unlock()
+	if redisServer.sets != 0 {
+		t.Errorf("Cached %d varying responses, want 0", redisServer.sets)
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryConfig defines proxy retry parameters
+type RetryConfig struct {
+	MaxRetries   int           // Retries after the first attempt (0 disables retries)
+	Backoff      time.Duration // Delay before the first retry, doubled for each further retry
+	MaxBackoff   time.Duration // Upper bound on a single delay
+	Statuses     []int         // Backend statuses retried in addition to connection errors
+	MaxBodyBytes int64         // Larger request bodies are not buffered, so not retried
+}
+
+// retryTransport retries backend requests that are safe to repeat
+// Only idempotent methods, or requests carrying an Idempotency-Key, are retried, on
+// connection errors and the configured statuses; the final attempt's result is returned as is
+type retryTransport struct {
+	next     http.RoundTripper
+	config   RetryConfig
+	statuses map[int]bool
+	onRetry  func() // Called before each retry, e.g. to update metrics
+}
+
+// newRetryTransport wraps a backend transport with retries (next itself when retries are disabled)
+func newRetryTransport(next http.RoundTripper, config RetryConfig, onRetry func()) http.RoundTripper {
+	if config.MaxRetries <= 0 {
+		return next
+	}
+
+	statuses := make(map[int]bool, len(config.Statuses))
+	for _, status := range config.Statuses {
+		statuses[status] = true
+	}
+	if onRetry == nil {
+		onRetry = func() {}
+	}
+	return &retryTransport{next: next, config: config, statuses: statuses, onRetry: onRetry}
+}
+
+// RoundTrip sends the request, retrying with exponential backoff while it is safe to
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isRetryableRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	body, ok := bufferRequestBody(req, t.config.MaxBodyBytes)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if body != nil {
+			attemptReq = req.WithContext(req.Context())
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.next.RoundTrip(attemptReq)
+		if attempt == t.config.MaxRetries || !t.shouldRetry(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		// Discard the failed response so its connection can be reused
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		t.onRetry()
+		if err := sleepContext(req.Context(), t.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry reports whether an attempt failed transiently
+// A canceled or expired client request is never retried
+func (t *retryTransport) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return t.statuses[resp.StatusCode]
+}
+
+// backoff returns the delay before retry attempt+1: Backoff * 2^attempt capped at MaxBackoff,
+// with jitter over the upper half so retries from many requests don't align
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.config.Backoff << attempt
+	if t.config.MaxBackoff > 0 && (delay > t.config.MaxBackoff || delay <= 0) {
+		delay = t.config.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// isRetryableRequest reports whether repeating a request cannot duplicate its effect
+// POST and PATCH are only retried when the client sent an Idempotency-Key
+func isRetryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// bufferRequestBody reads the request body into memory so every attempt can resend it
+// Returns ok=false (with the body left streamable) when it exceeds maxBytes; nil means no body
+func bufferRequestBody(req *http.Request, maxBytes int64) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true
+	}
+	if req.ContentLength > maxBytes {
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBytes+1))
+	if err != nil || int64(len(body)) > maxBytes {
+		// Put back what was read; the request goes out once without retries
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, false
+	}
+	req.Body.Close()
+	return body, true
+}
+
+// sleepContext waits for d, or returns early with the context's error
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
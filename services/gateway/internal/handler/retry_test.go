@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/config"
+)
+
+// newFlakyBackend fails the first `failures` requests with failWith (or a reset connection when 0)
+func newFlakyBackend(t *testing.T, failures int32, failWith int) (*httptest.Server, *atomic.Int32, *atomic.Value) {
+	var attempts atomic.Int32
+	var lastBody atomic.Value
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastBody.Store(string(body))
+
+		if attempts.Add(1) <= failures {
+			if failWith == 0 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.WriteHeader(failWith)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(backend.Close)
+	return backend, &attempts, &lastBody
+}
+
+func newRetryTestGateway(t *testing.T, backendURL string) http.Handler {
+	return newTestGateway(t, &config.Config{
+		BackendURLs:            map[string]string{"users-api": backendURL},
+		ProxyRetryMax:          2,
+		ProxyRetryBackoff:      time.Millisecond,
+		ProxyRetryMaxBackoff:   5 * time.Millisecond,
+		ProxyRetryStatuses:     []int{http.StatusBadGateway},
+		ProxyRetryMaxBodyBytes: 1024,
+	}, &fakeUsageRecorder{})
+}
+
+func sendRetryTestRequest(gateway http.Handler, method, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/users-api/users", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk_test_valid")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	gateway.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProxy_RetryFailsTwiceThenSucceeds(t *testing.T) {
+	backend, attempts, _ := newFlakyBackend(t, 2, http.StatusBadGateway)
+	gateway := newRetryTestGateway(t, backend.URL)
+
+	rec := sendRetryTestRequest(gateway, http.MethodGet, "", nil)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("Response = %d %q, want 200 ok after retries", rec.Code, rec.Body.String())
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("Backend attempts = %d, want 3", got)
+	}
+}
+
+func TestProxy_RetryConnectionReset(t *testing.T) {
+	backend, _, _ := newFlakyBackend(t, 2, 0)
+	gateway := newRetryTestGateway(t, backend.URL)
+
+	if rec := sendRetryTestRequest(gateway, http.MethodGet, "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want 200 after retrying reset connections", rec.Code)
+	}
+}
+
+func TestProxy_RetryGivesUp(t *testing.T) {
+	backend, attempts, _ := newFlakyBackend(t, 10, http.StatusBadGateway)
+	gateway := newRetryTestGateway(t, backend.URL)
+
+	if rec := sendRetryTestRequest(gateway, http.MethodGet, "", nil); rec.Code != http.StatusBadGateway {
+		t.Errorf("Status = %d, want the backend's final 502", rec.Code)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("Backend attempts = %d, want 1 + 2 retries", got)
+	}
+}
+
+func TestProxy_RetryNonIdempotent(t *testing.T) {
+	t.Run("POST is not retried", func(t *testing.T) {
+		backend, attempts, _ := newFlakyBackend(t, 2, http.StatusBadGateway)
+		gateway := newRetryTestGateway(t, backend.URL)
+
+		if rec := sendRetryTestRequest(gateway, http.MethodPost, `{"name":"a"}`, nil); rec.Code != http.StatusBadGateway {
+			t.Errorf("Status = %d, want 502", rec.Code)
+		}
+		if got := attempts.Load(); got != 1 {
+			t.Errorf("Backend attempts = %d, want 1", got)
+		}
+	})
+
+	t.Run("POST with Idempotency-Key replays the body", func(t *testing.T) {
+		backend, attempts, lastBody := newFlakyBackend(t, 2, http.StatusBadGateway)
+		gateway := newRetryTestGateway(t, backend.URL)
+
+		rec := sendRetryTestRequest(gateway, http.MethodPost, `{"name":"a"}`, http.Header{"Idempotency-Key": {"key-1"}})
+		if rec.Code != http.StatusOK || attempts.Load() != 3 {
+			t.Fatalf("Response = %d after %d attempts, want 200 after 3", rec.Code, attempts.Load())
+		}
+		if got := lastBody.Load(); got != `{"name":"a"}` {
+			t.Errorf("Retried body = %q, want the original body", got)
+		}
+	})
+
+	t.Run("Body over the buffer limit is not retried", func(t *testing.T) {
+		backend, attempts, lastBody := newFlakyBackend(t, 2, http.StatusBadGateway)
+		gateway := newRetryTestGateway(t, backend.URL)
+
+		large := strings.Repeat("x", 2048)
+		if rec := sendRetryTestRequest(gateway, http.MethodPut, large, nil); rec.Code != http.StatusBadGateway {
+			t.Errorf("Status = %d, want 502", rec.Code)
+		}
+		if attempts.Load() != 1 || lastBody.Load() != large {
+			t.Errorf("Backend got %d attempts with %d body bytes, want 1 attempt with the full body",
+				attempts.Load(), len(lastBody.Load().(string)))
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"sync/atomic"
+
+	"github.com/saas-gateway/gateway/internal/events"
+)
+
+// usageSampler records 1 in N non-billable usage events per plan tier
+// Billable events are never sampled, so invoices stay exact. Kept events carry SampleRate
+// on the Kafka message, but the usage processor does not store it: non-billable counts in
+// usage_events are the sampled counts
+type usageSampler struct {
+	rates    map[string]int
+	counters map[string]*atomic.Uint64 // Per plan tier, built once so Keep is lock-free
+}
+
+// newUsageSampler creates a sampler from plan_tier -> N rates (nil when no tier samples)
+func newUsageSampler(rates map[string]int) *usageSampler {
+	s := &usageSampler{
+		rates:    make(map[string]int),
+		counters: make(map[string]*atomic.Uint64),
+	}
+	for tier, rate := range rates {
+		if rate <= 1 {
+			continue
+		}
+		s.rates[tier] = rate
+		s.counters[tier] = new(atomic.Uint64)
+	}
+	if len(s.rates) == 0 {
+		return nil
+	}
+	return s
+}
+
+// Keep reports whether an event should be sent and sets its SampleRate when sampled
+// A nil sampler keeps every event
+func (s *usageSampler) Keep(event *events.UsageEvent, planTier string) bool {
+	if s == nil || event.Billable {
+		return true
+	}
+
+	rate, ok := s.rates[planTier]
+	if !ok {
+		return true
+	}
+
+	// Deterministic 1-in-N keeps the recorded share exact rather than approximately random
+	if (s.counters[planTier].Add(1)-1)%uint64(rate) != 0 {
+		return false
+	}
+	event.SampleRate = rate
+	return true
+}
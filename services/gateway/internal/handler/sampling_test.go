@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/saas-gateway/gateway/internal/events"
+)
+
+func TestUsageSampler_BillableNeverSampled(t *testing.T) {
+	sampler := newUsageSampler(map[string]int{"basic": 10})
+
+	for i := 0; i < 100; i++ {
+		event := events.UsageEvent{Billable: true}
+		if !sampler.Keep(&event, "basic") {
+			t.Fatalf("Billable event %d was sampled out", i)
+		}
+		if event.SampleRate != 0 {
+			t.Fatalf("Billable event %d has SampleRate %d, want 0", i, event.SampleRate)
+		}
+	}
+}
+
+func TestUsageSampler_NonBillableSampledAtRate(t *testing.T) {
+	sampler := newUsageSampler(map[string]int{"basic": 10, "premium": 1})
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		event := events.UsageEvent{Billable: false}
+		if sampler.Keep(&event, "basic") {
+			kept++
+			if event.SampleRate != 10 {
+				t.Errorf("Kept event SampleRate = %d, want 10", event.SampleRate)
+			}
+		}
+	}
+	if kept != 100 {
+		t.Errorf("Kept %d of 1000 non-billable events, want 100", kept)
+	}
+
+	// Rate 1 and unconfigured tiers record everything
+	for _, tier := range []string{"premium", "enterprise"} {
+		event := events.UsageEvent{Billable: false}
+		if !sampler.Keep(&event, tier) || event.SampleRate != 0 {
+			t.Errorf("Tier %s event was sampled", tier)
+		}
+	}
+}
+
+func TestUsageSampler_NilKeepsEverything(t *testing.T) {
+	sampler := newUsageSampler(nil)
+	if sampler != nil {
+		t.Fatal("Expected nil sampler when no tier samples")
+	}
+
+	event := events.UsageEvent{Billable: false}
+	if !sampler.Keep(&event, "basic") {
+		t.Error("Nil sampler dropped an event")
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/saas-gateway/gateway/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/saas-gateway/gateway/internal/handler"
+
+// spanStatusWriter captures the response status for the proxy span
+type spanStatusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sw *spanStatusWriter) WriteHeader(statusCode int) {
+	sw.statusCode = statusCode
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Unwrap exposes the underlying writer for http.ResponseController (flushing, trailers)
+func (sw *spanStatusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// traceProxy starts the span for a proxied request, continuing any trace the client sent
+// The returned request carries the span so the director can inject it into backend headers;
+// finish records the response status and ends the span
+func (p *Proxy) traceProxy(w http.ResponseWriter, r *http.Request, reqCtx *models.RequestContext, serviceName string) (http.ResponseWriter, *http.Request, func()) {
+	ctx := p.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	route := "/" + serviceName
+	ctx, span := p.tracer.Start(ctx, r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("gateway.organization_id", reqCtx.APIKey.OrganizationID),
+			attribute.String("gateway.backend_service", serviceName),
+			attribute.String("gateway.request_id", reqCtx.RequestID),
+		),
+	)
+
+	sw := &spanStatusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	finish := func() {
+		span.SetAttributes(attribute.Int("http.response.status_code", sw.statusCode))
+		if sw.statusCode >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(sw.statusCode))
+		}
+		span.End()
+	}
+	return sw, r.WithContext(ctx), finish
+}
+
+// injectTraceContext adds the request's trace context (traceparent) to outbound backend headers
+func (p *Proxy) injectTraceContext(req *http.Request) {
+	p.propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// UseTracing traces proxied requests with provider and forwards trace context with propagator
+// A new proxy uses no-op ones, recording no spans and sending no trace headers
+func (p *Proxy) UseTracing(provider trace.TracerProvider, propagator propagation.TextMapPropagator) {
+	p.tracer = provider.Tracer(tracerName)
+	p.propagator = propagator
+}
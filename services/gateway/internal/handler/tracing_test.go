@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saas-gateway/gateway/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTracedTestGateway is newTracingTestGateway with a recording tracer and W3C propagation
+func newTracedTestGateway(t *testing.T, backendURL string) (http.Handler, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	cfg := &config.Config{BackendURLs: map[string]string{"users-api": backendURL}}
+	proxy := newTestProxy(t, cfg, &fakeUsageRecorder{})
+	proxy.UseTracing(provider, propagation.TraceContext{})
+
+	return chainTestGateway(cfg, proxy), recorder
+}
+
+func TestProxy_TracesBackendCall(t *testing.T) {
+	var traceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer backend.Close()
+
+	gateway, spans := newTracedTestGateway(t, backend.URL)
+
+	// Continue the client's trace
+	const clientTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/users-api/users", nil)
+	req.Header.Set("Authorization", "Bearer sk_test_valid")
+	req.Header.Set("traceparent", "00-"+clientTraceID+"-00f067aa0ba902b7-01")
+	gateway.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("Got %d spans, want 1", len(ended))
+	}
+	span := ended[0]
+
+	if span.Name() != "GET /users-api" {
+		t.Errorf("Span name = %q, want GET /users-api", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != clientTraceID {
+		t.Errorf("Trace ID = %s, want the client's %s", got, clientTraceID)
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	expected := map[string]string{
+		"gateway.organization_id":   "org_1",
+		"gateway.backend_service":   "users-api",
+		"http.response.status_code": "502",
+	}
+	for key, want := range expected {
+		if attrs[key] != want {
+			t.Errorf("Attribute %s = %q, want %q", key, attrs[key], want)
+		}
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("Span status = %v, want Error for 502", span.Status().Code)
+	}
+
+	// The backend continues the gateway's span, not the client's
+	wantPrefix := "00-" + clientTraceID + "-" + span.SpanContext().SpanID().String()
+	if !strings.HasPrefix(traceparent, wantPrefix) {
+		t.Errorf("Backend traceparent = %q, want prefix %q", traceparent, wantPrefix)
+	}
+}
+
+func TestProxy_TracingDisabledAddsNoHeaders(t *testing.T) {
+	var traceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer backend.Close()
+
+	// No UseTracing, as when TRACING_ENABLED=false; a recording global provider must not leak in
+	global := sdktrace.NewTracerProvider()
+	defer global.Shutdown(context.Background())
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(global)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	}()
+
+	gateway := newTracingTestGateway(t, backend.URL, &fakeUsageRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/users-api/users", nil)
+	req.Header.Set("Authorization", "Bearer sk_test_valid")
+	gateway.ServeHTTP(httptest.NewRecorder(), req)
+
+	if traceparent != "" {
+		t.Errorf("Backend traceparent = %q, want none with tracing disabled", traceparent)
+	}
+}
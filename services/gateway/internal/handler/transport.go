@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/config"
+)
+
+// newBackendTransport builds the dedicated transport of one backend's reverse proxy
+// A response header timeout surfaces as "timeout awaiting response headers", which errorHandler maps to 504
+func newBackendTransport(settings config.BackendTransport) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   settings.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if settings.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = settings.ResponseHeaderTimeout
+	}
+	if settings.MaxIdleConns > 0 {
+		transport.MaxIdleConns = settings.MaxIdleConns
+	}
+	if settings.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = settings.MaxIdleConnsPerHost
+	}
+	return transport
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/config"
+)
+
+func TestProxy_ResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	recorder := &fakeUsageRecorder{}
+	gateway := newTestGateway(t, &config.Config{
+		BackendURLs: map[string]string{"reports-api": slow.URL},
+		BackendTransport: config.BackendTransport{
+			ResponseHeaderTimeout: 5 * time.Second, // Default is generous...
+		},
+		BackendTransports: map[string]*config.BackendTransport{
+			"reports-api": {ResponseHeaderTimeout: 50 * time.Millisecond}, // ...but this backend's is not
+		},
+	}, recorder)
+
+	req := httptest.NewRequest(http.MethodGet, "/reports-api/monthly", nil)
+	req.Header.Set("Authorization", "Bearer sk_test_valid")
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	gateway.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Status = %d, want 504", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Request took %v, want the 50ms backend timeout to apply", elapsed)
+	}
+	if len(recorder.events) != 1 || recorder.events[0].Billable {
+		t.Errorf("Usage events = %+v, want one non-billable event", recorder.events)
+	}
+}
+
+func TestNewBackendTransport(t *testing.T) {
+	transport := newBackendTransport(config.BackendTransport{
+		ResponseHeaderTimeout: 3 * time.Second,
+		MaxIdleConns:          20,
+		MaxIdleConnsPerHost:   5,
+	})
+	if transport.ResponseHeaderTimeout != 3*time.Second || transport.MaxIdleConns != 20 || transport.MaxIdleConnsPerHost != 5 {
+		t.Errorf("Transport = %v/%d/%d, want 3s/20/5",
+			transport.ResponseHeaderTimeout, transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+
+	// Zero settings keep the net/http defaults rather than disabling pooling
+	defaults := newBackendTransport(config.BackendTransport{})
+	if defaults.MaxIdleConns != http.DefaultTransport.(*http.Transport).MaxIdleConns {
+		t.Errorf("MaxIdleConns = %d, want the net/http default", defaults.MaxIdleConns)
+	}
+	if defaults == http.DefaultTransport {
+		t.Error("Backend shares http.DefaultTransport")
+	}
+}
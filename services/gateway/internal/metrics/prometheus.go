@@ -72,6 +72,15 @@ var (
 		[]string{"organization_id", "error_type"},
 	)
 
+	// UsageSampledOut counts non-billable usage events skipped by per-plan sampling
+	UsageSampledOut = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_usage_sampled_out_total",
+			Help: "Total number of non-billable usage events not sent to Kafka due to sampling",
+		},
+		[]string{"organization_id", "plan_tier"},
+	)
+
 	// KafkaProducerLatency tracks Kafka message publish latency
 	KafkaProducerLatency = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -128,6 +137,24 @@ var (
 		[]string{"endpoint"},
 	)
 
+	// CircuitBreakerState tracks backend circuit breaker state (0=closed, 1=open, 2=half-open)
+	CircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_circuit_breaker_state",
+			Help: "Circuit breaker state per backend service (0=closed, 1=open, 2=half-open)",
+		},
+		[]string{"service"},
+	)
+
+	// BackendRetries counts retried backend requests per service
+	BackendRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_backend_retries_total",
+			Help: "Total number of backend request retries per service",
+		},
+		[]string{"service"},
+	)
+
 	// ConcurrentRequests tracks requests being processed simultaneously
 	ConcurrentRequests = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -167,6 +194,11 @@ func RecordUsageError(orgID, errorType string) {
 	UsageRecordingErrors.WithLabelValues(orgID, errorType).Inc()
 }
 
+// RecordUsageSampledOut records a non-billable usage event skipped by sampling
+func RecordUsageSampledOut(orgID, planTier string) {
+	UsageSampledOut.WithLabelValues(orgID, planTier).Inc()
+}
+
 // RecordKafkaLatency records Kafka producer latency
 func RecordKafkaLatency(topic string, duration time.Duration) {
 	KafkaProducerLatency.WithLabelValues(topic).Observe(float64(duration.Milliseconds()))
@@ -197,6 +229,16 @@ func RecordResponseSize(endpoint string, sizeBytes int) {
 	ResponseSizeBytes.WithLabelValues(endpoint).Observe(float64(sizeBytes))
 }
 
+// SetCircuitBreakerState records the circuit breaker state for a backend service
+func SetCircuitBreakerState(service string, state int) {
+	CircuitBreakerState.WithLabelValues(service).Set(float64(state))
+}
+
+// RecordBackendRetry records a retried backend request
+func RecordBackendRetry(service string) {
+	BackendRetries.WithLabelValues(service).Inc()
+}
+
 // IncrementConcurrentRequests increments the concurrent requests gauge
 func IncrementConcurrentRequests() {
 	ConcurrentRequests.Inc()
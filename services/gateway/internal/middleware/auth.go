@@ -5,15 +5,18 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/saas-gateway/gateway/internal/cache"
 	"github.com/saas-gateway/gateway/internal/config"
 	"github.com/saas-gateway/gateway/internal/database"
+	"github.com/saas-gateway/gateway/internal/metrics"
 	"github.com/saas-gateway/gateway/pkg/models"
 )
 
@@ -71,7 +74,7 @@ func (a *Auth) Middleware(next http.Handler) http.Handler {
 		var err error
 		cachedKey, err = a.repo.GetAPIKey(ctx, keyHash)
 		if err != nil {
-			log.Printf("[Auth] ERROR: Database query failed: %v", err)
+			RequestLogger(r).Error("API key lookup failed", slog.String("error", err.Error()))
 			a.respondError(w, http.StatusInternalServerError, "authentication service temporarily unavailable")
 			return
 		}
@@ -84,31 +87,84 @@ func (a *Auth) Middleware(next http.Handler) http.Handler {
 
 		// Store in cache for future requests
 		a.cache.Set(keyHash, cachedKey)
-		log.Printf("[Auth] Cache miss - loaded key for org: %s", cachedKey.OrganizationID)
+		RequestLogger(r).Debug("API key cache miss", slog.String("organization_id", cachedKey.OrganizationID))
 	}
 
-	// Create API key model
+	// Reject expired keys even if still cached (no DB round-trip)
 	now := time.Now()
+	if cachedKey.IsKeyExpired(now) {
+		a.cache.Invalidate(keyHash)
+		metrics.RecordAuthFailure(cachedKey.OrganizationID, "expired")
+		a.respondError(w, http.StatusForbidden, "API key expired")
+		return
+	}
+
+	// A rotated key works only until its grace period ends, whether or not the cleanup job has run
+	if cachedKey.IsRotationOver(now) {
+		a.cache.Invalidate(keyHash)
+		metrics.RecordAuthFailure(cachedKey.OrganizationID, "rotated")
+		a.respondError(w, http.StatusForbidden, "API key was rotated; use its replacement")
+		return
+	}
+
+	// Keys with an IP allowlist only work from inside one of its ranges
+	if len(cachedKey.AllowedCIDRs) > 0 {
+		clientAddr, ok := trustedClientAddr(r, a.config.TrustedProxies)
+		if !ok || !cachedKey.AllowsIP(clientAddr) {
+			metrics.RecordAuthFailure(cachedKey.OrganizationID, "ip_not_allowed")
+			a.respondError(w, http.StatusForbidden, "API key may not be used from this IP address")
+			return
+		}
+	}
+
+	// Suspended orgs (e.g. for non-payment) are blocked until reactivated; they can still
+	// sign in to the dashboard to pay
+	if cachedKey.IsOrgSuspended() {
+		metrics.RecordAuthFailure(cachedKey.OrganizationID, "org_suspended")
+		a.respondError(w, http.StatusPaymentRequired, "organization is suspended; sign in to the dashboard to settle your account")
+		return
+	}
+
+	// Orgs past their plan's hard cap are blocked until the next billing period
+	if cachedKey.IsUsageCapped(now) {
+		metrics.RecordRateLimitHit(cachedKey.OrganizationID, "usage_cap")
+		w.Header().Set("Retry-After", strconv.Itoa(int(cachedKey.UsageCappedUntil.Sub(now).Seconds())+1))
+		a.respondError(w, http.StatusPaymentRequired, fmt.Sprintf("monthly usage limit reached for your plan, resets at %s", cachedKey.UsageCappedUntil.UTC().Format(time.RFC3339)))
+		return
+	}
+
+	// Create API key model
 	apiKey := &models.APIKey{
-		ID:             uuid.New(),
+		ID:             cachedKey.KeyID,
 		Key:            apiKeyStr,
 		OrganizationID: cachedKey.OrganizationID,
-		PlanTier:       "free", // TODO: Get from database
+		PlanTier:       cachedKey.PlanTier,
 		CreatedAt:      now,
-		ExpiresAt:      nil,
+		ExpiresAt:      cachedKey.KeyExpiresAt,
 		IsRevoked:      false,
 		LastUsedAt:     &now,
-	}		// Create request context
+		Scopes:         cachedKey.Scopes,
+	}
+
+	// Check the key is scoped for this route
+	if requiredScope, ok := a.config.RequiredScope(r.Method, r.URL.Path); ok && !apiKey.HasScope(requiredScope) {
+		metrics.RecordAuthFailure(apiKey.OrganizationID, "insufficient_scope")
+		a.respondError(w, http.StatusForbidden, fmt.Sprintf("API key lacks required scope: %s", requiredScope))
+		return
+	}
+
+		// Create request context
 		reqCtx := &models.RequestContext{
 			APIKey:    apiKey,
-			RequestID: uuid.New().String(),
+			RequestID: requestID(r),
 			StartTime: now,
 			ClientIP:  getClientIP(r),
 			Method:    r.Method,
 			Path:      r.URL.Path,
 		}
 
-		// Add context to request
+		// Add context to request (and to the access log)
+		setLogRequestContext(r, reqCtx)
 		ctx := context.WithValue(r.Context(), RequestContextKey, reqCtx)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -154,6 +210,54 @@ func getClientIP(r *http.Request) string {
 	return ip
 }
 
+// trustedClientAddr resolves the client address used for IP allowlists
+// X-Forwarded-For is only believed when the direct peer is a trusted proxy. It is then read
+// right to left, skipping trusted proxies, so a client cannot pass an allowlist by sending
+// a forged X-Forwarded-For: the first untrusted hop is the client. It reports false
+// when an address cannot be parsed
+func trustedClientAddr(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	var addr netip.Addr
+	if peer, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		addr = peer.Addr()
+	} else if addr, err = netip.ParseAddr(r.RemoteAddr); err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	if !isTrustedProxy(addr, trustedProxies) {
+		return addr, true
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		hopAddr, err := netip.ParseAddr(hop)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = hopAddr.Unmap()
+		if !isTrustedProxy(addr, trustedProxies) {
+			return addr, true
+		}
+	}
+
+	// Every hop is a trusted proxy: the leftmost one is the closest thing to a client
+	return addr, true
+}
+
+// isTrustedProxy checks if addr is inside any trusted proxy range
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // hashAPIKey creates a SHA-256 hash of the API key
 func hashAPIKey(apiKey string) string {
 	hash := sha256.Sum256([]byte(apiKey))
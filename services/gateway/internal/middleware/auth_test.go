@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saas-gateway/gateway/internal/cache"
+	"github.com/saas-gateway/gateway/internal/config"
+)
+
+// newTestAuth creates auth middleware with no database
+// Any DB round-trip panics on the nil repository, failing the test
+func newTestAuth(keyCache *cache.APIKeyCache) *Auth {
+	return NewAuth(&config.Config{}, keyCache, nil)
+}
+
+func newAuthRequest(apiKey string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	return req
+}
+
+func TestAuth_ExpiredCachedKeyRejected(t *testing.T) {
+	keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+	expired := time.Now().Add(-time.Minute)
+	keyCache.Set(hashAPIKey("sk_test_expired"), &cache.CachedKey{
+		OrganizationID: "org_1",
+		KeyExpiresAt:   &expired,
+	})
+
+	called := false
+	handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newAuthRequest("sk_test_expired"))
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if called {
+		t.Error("Expected next handler not to be called for expired key")
+	}
+	if _, found := keyCache.Get(hashAPIKey("sk_test_expired")); found {
+		t.Error("Expected expired key to be evicted from cache")
+	}
+}
+
+func TestAuth_CachedKeyExpiry(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		keyExpiresAt *time.Time
+		expected     int
+	}{
+		{"No expiration", nil, http.StatusOK},
+		{"Expires in the future", &future, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+			keyCache.Set(hashAPIKey("sk_test_valid"), &cache.CachedKey{
+				OrganizationID: "org_1",
+				KeyExpiresAt:   tt.keyExpiresAt,
+			})
+
+			handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newAuthRequest("sk_test_valid"))
+
+			if rec.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuth_RotatedKeyGracePeriod(t *testing.T) {
+	tests := []struct {
+		name           string
+		rotationEndsAt time.Time
+		expected       int
+	}{
+		{"Inside the grace period", time.Now().Add(time.Hour), http.StatusOK},
+		{"Grace period over", time.Now().Add(-time.Second), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+			keyCache.Set(hashAPIKey("sk_test_old"), &cache.CachedKey{
+				OrganizationID: "org_1",
+				RotationEndsAt: &tt.rotationEndsAt,
+			})
+			// The replacement is valid throughout, so both keys work during the overlap
+			keyCache.Set(hashAPIKey("sk_test_new"), &cache.CachedKey{OrganizationID: "org_1"})
+
+			handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newAuthRequest("sk_test_old"))
+			if rec.Code != tt.expected {
+				t.Errorf("Old key status = %d, want %d", rec.Code, tt.expected)
+			}
+
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, newAuthRequest("sk_test_new"))
+			if rec.Code != http.StatusOK {
+				t.Errorf("New key status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			if _, found := keyCache.Get(hashAPIKey("sk_test_old")); found != (tt.expected == http.StatusOK) {
+				t.Errorf("Old key cached = %v, want %v", found, tt.expected == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestAuth_UsageCappedOrgRejected(t *testing.T) {
+	resetAt := time.Now().Add(48 * time.Hour)
+	lapsed := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		cappedUntil *time.Time
+		expected    int
+	}{
+		{"Not capped", nil, http.StatusOK},
+		{"Capped until next period", &resetAt, http.StatusPaymentRequired},
+		{"Cap lapsed at period reset", &lapsed, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+			keyCache.Set(hashAPIKey("sk_test_capped"), &cache.CachedKey{
+				OrganizationID:   "org_free",
+				UsageCappedUntil: tt.cappedUntil,
+			})
+
+			handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newAuthRequest("sk_test_capped"))
+
+			if rec.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.expected)
+			}
+			if tt.expected == http.StatusPaymentRequired && rec.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After header for capped org")
+			}
+		})
+	}
+}
+
+func TestAuth_AllowedCIDRs(t *testing.T) {
+	allowed := []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("2001:db8:1::/48")}
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		expected   int
+	}{
+		{"IPv4 in range", "203.0.113.7:4711", "", http.StatusOK},
+		{"IPv4 out of range", "198.51.100.7:4711", "", http.StatusForbidden},
+		{"IPv6 in range", "[2001:db8:1::25]:4711", "", http.StatusOK},
+		{"IPv6 out of range", "[2001:db8:2::25]:4711", "", http.StatusForbidden},
+		{"IPv4-mapped IPv6 in range", "[::ffff:203.0.113.7]:4711", "", http.StatusOK},
+		{"Behind trusted proxy, client in range", "10.0.0.5:4711", "203.0.113.7", http.StatusOK},
+		{"Behind trusted proxy, client out of range", "10.0.0.5:4711", "198.51.100.7", http.StatusForbidden},
+		{"Forged hop prepended by the client is skipped", "10.0.0.5:4711", "203.0.113.7, 198.51.100.7", http.StatusForbidden},
+		{"Chain of trusted proxies", "10.0.0.5:4711", "203.0.113.7, 10.1.2.3", http.StatusOK},
+		{"Untrusted peer cannot spoof X-Forwarded-For", "198.51.100.7:4711", "203.0.113.7", http.StatusForbidden},
+		{"Unparseable hop", "10.0.0.5:4711", "not-an-ip", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+			keyCache.Set(hashAPIKey("sk_test_ip"), &cache.CachedKey{
+				OrganizationID: "org_1",
+				AllowedCIDRs:   allowed,
+			})
+
+			auth := NewAuth(&config.Config{TrustedProxies: trusted}, keyCache, nil)
+			handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := newAuthRequest("sk_test_ip")
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuth_NoAllowlistAcceptsAnyIP(t *testing.T) {
+	keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+	keyCache.Set(hashAPIKey("sk_test_open"), &cache.CachedKey{OrganizationID: "org_1"})
+
+	handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := newAuthRequest("sk_test_open")
+	req.RemoteAddr = "198.51.100.7:4711"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAuth_RequestCarriesKeyID(t *testing.T) {
+	keyID := uuid.New()
+	keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+	keyCache.Set(hashAPIKey("sk_test_usage"), &cache.CachedKey{KeyID: keyID, OrganizationID: "org_1"})
+
+	var got uuid.UUID
+	handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reqCtx, ok := GetRequestContext(r); ok {
+			got = reqCtx.APIKey.ID
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newAuthRequest("sk_test_usage"))
+	// Usage events are attributed to this ID, so it must be the stored key's, not a fresh one
+	if got != keyID {
+		t.Errorf("APIKey.ID = %s, want %s", got, keyID)
+	}
+}
+
+func TestAuth_SuspendedOrgRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		orgStatus string
+		expected  int
+	}{
+		{"Active org", "active", http.StatusOK},
+		{"Status not loaded", "", http.StatusOK},
+		{"Suspended org", "suspended", http.StatusPaymentRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+			keyCache.Set(hashAPIKey("sk_test_org"), &cache.CachedKey{OrganizationID: "org_1", OrgStatus: tt.orgStatus})
+
+			called := false
+			handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newAuthRequest("sk_test_org"))
+			if rec.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.expected)
+			}
+			if called != (tt.expected == http.StatusOK) {
+				t.Errorf("Next handler called = %v, want %v", called, tt.expected == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestAuth_RequestCarriesPlanTier(t *testing.T) {
+	keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+	keyCache.Set(hashAPIKey("sk_test_premium"), &cache.CachedKey{OrganizationID: "org_1", PlanTier: "premium"})
+
+	var got string
+	handler := newTestAuth(keyCache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reqCtx, ok := GetRequestContext(r); ok {
+			got = reqCtx.APIKey.PlanTier
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newAuthRequest("sk_test_premium"))
+	// Sampling and per-plan billing look this up, so it must be the organization's plan
+	if got != "premium" {
+		t.Errorf("APIKey.PlanTier = %q, want premium", got)
+	}
+}
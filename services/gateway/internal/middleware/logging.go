@@ -1,25 +1,139 @@
 package middleware
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/saas-gateway/gateway/pkg/models"
+)
+
+const (
+	requestLogKey contextKey = "requestLog"
+
+	// RequestIDHeader carries the correlation ID from clients to backends and back in responses
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
 )
 
 // Logger provides structured logging for HTTP requests
 type Logger struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 // NewLogger creates a new logging middleware
-func NewLogger() *Logger {
+func NewLogger(logger *slog.Logger) *Logger {
 	return &Logger{
-		logger: log.New(os.Stdout, "", 0),
+		logger: logger,
+	}
+}
+
+// NewSlogLogger creates a structured logger writing to w
+// level is debug, info, warn or error; format is json or text
+func NewSlogLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected json or text)", format)
+	}
+}
+
+// requestLog carries per-request logging state from the Logger middleware to inner handlers
+// Auth fills in reqCtx, which is otherwise only visible on the request it passes downstream
+type requestLog struct {
+	logger    *slog.Logger
+	requestID string
+	reqCtx    *models.RequestContext
+}
+
+// validRequestID reports whether an inbound request ID is safe to log and forward
+// Allows UUIDs and similar tokens: 1-128 letters, digits, '-', '_', '.' or ':'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the request's correlation ID: the one chosen by the Logger middleware,
+// otherwise the inbound X-Request-ID if valid, otherwise a new UUID
+func requestID(r *http.Request) string {
+	if state, ok := r.Context().Value(requestLogKey).(*requestLog); ok && state.requestID != "" {
+		return state.requestID
+	}
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// setLogRequestContext makes reqCtx available to the access log and RequestLogger
+func setLogRequestContext(r *http.Request, reqCtx *models.RequestContext) {
+	if state, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+		state.reqCtx = reqCtx
 	}
 }
 
+// requestContextForLog returns the request context set by Auth, seen from inside or outside it
+func requestContextForLog(r *http.Request) (*models.RequestContext, bool) {
+	if reqCtx, ok := GetRequestContext(r); ok {
+		return reqCtx, true
+	}
+	if state, ok := r.Context().Value(requestLogKey).(*requestLog); ok && state.reqCtx != nil {
+		return state.reqCtx, true
+	}
+	return nil, false
+}
+
+// RequestLogger returns the logger for a request, tagged with its request and organization IDs
+// Falls back to slog.Default() outside the Logger middleware
+func RequestLogger(r *http.Request) *slog.Logger {
+	return requestLogger(r, slog.Default())
+}
+
+// requestLogger is RequestLogger with the logger to use outside the Logger middleware
+func requestLogger(r *http.Request, fallback *slog.Logger) *slog.Logger {
+	logger := fallback
+	state, hasState := r.Context().Value(requestLogKey).(*requestLog)
+	if hasState {
+		logger = state.logger
+	}
+
+	if reqCtx, ok := requestContextForLog(r); ok {
+		return logger.With(
+			slog.String("request_id", reqCtx.RequestID),
+			slog.String("organization_id", reqCtx.APIKey.OrganizationID),
+		)
+	}
+	if hasState {
+		return logger.With(slog.String("request_id", state.requestID))
+	}
+	return logger
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -45,14 +159,22 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// Middleware logs HTTP requests in structured JSON format
+// Unwrap exposes the underlying writer for http.ResponseController (flushing, trailers)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Middleware logs one structured line per HTTP request
 func (l *Logger) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		wrapped := newResponseWriter(w)
 
-		// Get request context if available
-		reqCtx, hasContext := GetRequestContext(r)
+		// Pick the correlation ID once; Auth copies it into the request context
+		state := &requestLog{logger: l.logger}
+		state.requestID = requestID(r)
+		r = r.WithContext(context.WithValue(r.Context(), requestLogKey, state))
+		w.Header().Set(RequestIDHeader, state.requestID)
 
 		// Process request
 		next.ServeHTTP(wrapped, r)
@@ -60,54 +182,42 @@ func (l *Logger) Middleware(next http.Handler) http.Handler {
 		// Calculate duration
 		duration := time.Since(start)
 
-		// Build log entry
-		logEntry := map[string]interface{}{
-			"timestamp":     start.UTC().Format(time.RFC3339Nano),
-			"method":        r.Method,
-			"path":          r.URL.Path,
-			"query":         r.URL.RawQuery,
-			"status":        wrapped.statusCode,
-			"duration_ms":   duration.Milliseconds(),
-			"bytes":         wrapped.bytes,
-			"client_ip":     getClientIP(r),
-			"user_agent":    r.UserAgent(),
-			"protocol":      r.Proto,
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("query", r.URL.RawQuery),
+			slog.Int("status", wrapped.statusCode),
+			slog.Int64("duration_ms", duration.Milliseconds()),
+			slog.Int("bytes", wrapped.bytes),
+			slog.String("client_ip", getClientIP(r)),
+			slog.String("user_agent", r.UserAgent()),
+			slog.String("protocol", r.Proto),
+			slog.String("request_id", state.requestID),
 		}
 
-		// Add request context if available
-		if hasContext {
-			logEntry["request_id"] = reqCtx.RequestID
-			logEntry["organization_id"] = reqCtx.APIKey.OrganizationID
-			logEntry["plan_tier"] = reqCtx.APIKey.PlanTier
+		// Add request context if the request was authenticated
+		if reqCtx := state.reqCtx; reqCtx != nil {
+			attrs = append(attrs,
+				slog.String("organization_id", reqCtx.APIKey.OrganizationID),
+				slog.String("plan_tier", reqCtx.APIKey.PlanTier),
+			)
 			if reqCtx.TargetService != "" {
-				logEntry["target_service"] = reqCtx.TargetService
+				attrs = append(attrs, slog.String("backend_service", reqCtx.TargetService))
 			}
 		}
 
-		// Add log level based on status code
-		logEntry["level"] = l.getLogLevel(wrapped.statusCode)
-
-		// Marshal to JSON
-		jsonLog, err := json.Marshal(logEntry)
-		if err != nil {
-			l.logger.Printf(`{"level":"error","message":"failed to marshal log entry","error":"%s"}`, err.Error())
-			return
-		}
-
-		l.logger.Println(string(jsonLog))
+		l.logger.LogAttrs(r.Context(), l.getLogLevel(wrapped.statusCode), "request", attrs...)
 	})
 }
 
 // getLogLevel determines log level based on HTTP status code
-func (l *Logger) getLogLevel(statusCode int) string {
+func (l *Logger) getLogLevel(statusCode int) slog.Level {
 	switch {
 	case statusCode >= 500:
-		return "error"
+		return slog.LevelError
 	case statusCode >= 400:
-		return "warn"
-	case statusCode >= 300:
-		return "info"
+		return slog.LevelWarn
 	default:
-		return "info"
+		return slog.LevelInfo
 	}
 }
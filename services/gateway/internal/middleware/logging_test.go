@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saas-gateway/gateway/internal/cache"
+)
+
+func newTestLogger(t *testing.T, buf *bytes.Buffer) *Logger {
+	logger, err := NewSlogLogger(buf, "info", "json")
+	if err != nil {
+		t.Fatalf("NewSlogLogger() error = %v", err)
+	}
+	return NewLogger(logger)
+}
+
+// decodeLogLines parses one JSON object per line
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %q", line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogger_AuthenticatedRequest(t *testing.T) {
+	keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+	keyCache.Set(hashAPIKey("sk_test_valid"), &cache.CachedKey{OrganizationID: "org_1"})
+
+	var requestID string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx, _ := GetRequestContext(r)
+		reqCtx.TargetService = "users-api"
+		requestID = reqCtx.RequestID
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+
+	var buf bytes.Buffer
+	handler := newTestLogger(t, &buf).Middleware(newTestAuth(keyCache).Middleware(backend))
+	handler.ServeHTTP(httptest.NewRecorder(), newAuthRequest("sk_test_valid"))
+
+	entries := decodeLogLines(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("Got %d log lines, want 1: %s", len(entries), buf.String())
+	}
+
+	entry := entries[0]
+	expected := map[string]interface{}{
+		"msg":             "request",
+		"level":           "INFO",
+		"method":          "GET",
+		"path":            "/api/users",
+		"status":          float64(http.StatusCreated),
+		"bytes":           float64(5),
+		"request_id":      requestID,
+		"organization_id": "org_1",
+		"backend_service": "users-api",
+	}
+	for field, want := range expected {
+		if entry[field] != want {
+			t.Errorf("%s = %v, want %v", field, entry[field], want)
+		}
+	}
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Error("Missing duration_ms")
+	}
+}
+
+func TestLogger_UnauthenticatedRequest(t *testing.T) {
+	var buf bytes.Buffer
+	handler := newTestLogger(t, &buf).Middleware(newTestAuth(cache.NewAPIKeyCache(time.Minute)).Middleware(http.NotFoundHandler()))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users", nil))
+
+	entries := decodeLogLines(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("Got %d log lines, want 1", len(entries))
+	}
+	if entries[0]["level"] != "WARN" || entries[0]["status"] != float64(http.StatusUnauthorized) {
+		t.Errorf("Entry = %v, want WARN 401", entries[0])
+	}
+	if _, ok := entries[0]["organization_id"]; ok {
+		t.Error("Unauthenticated request should not log organization_id")
+	}
+}
+
+func TestRecovery_LogsPanic(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestLogger(t, &buf)
+	recovery := NewRecovery(logger.logger)
+
+	handler := logger.Middleware(recovery.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	entries := decodeLogLines(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("Got %d log lines, want panic + request: %s", len(entries), buf.String())
+	}
+
+	panicEntry := entries[0]
+	if panicEntry["level"] != "ERROR" || panicEntry["msg"] != "panic recovered" || panicEntry["error"] != "boom" {
+		t.Errorf("Panic entry = %v", panicEntry)
+	}
+	if stack, _ := panicEntry["stack"].(string); !strings.Contains(stack, "TestRecovery_LogsPanic") {
+		t.Errorf("Panic entry stack = %q, want the panicking goroutine's stack", stack)
+	}
+
+	if entries[1]["level"] != "ERROR" || entries[1]["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("Request entry = %v, want ERROR 500", entries[1])
+	}
+}
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger, err := NewSlogLogger(&buf, "warn", "text")
+	if err != nil {
+		t.Fatalf("NewSlogLogger() error = %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "status", 404)
+
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "msg=shown status=404") {
+		t.Errorf("Text output = %q", out)
+	}
+
+	if _, err := NewSlogLogger(&buf, "verbose", "json"); err == nil {
+		t.Error("Expected error for invalid level")
+	}
+	if _, err := NewSlogLogger(&buf, "info", "xml"); err == nil {
+		t.Error("Expected error for invalid format")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{"550e8400-e29b-41d4-a716-446655440000", true},
+		{"client-req_7f3a.42:1", true},
+		{"", false},
+		{"has space", false},
+		{"line\r\nbreak", false},
+		{`{"json":true}`, false},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.expected {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.expected)
+		}
+	}
+}
+
+func TestLogger_InboundRequestID(t *testing.T) {
+	keyCache := cache.NewAPIKeyCache(15 * time.Minute)
+	keyCache.Set(hashAPIKey("sk_test_valid"), &cache.CachedKey{OrganizationID: "org_1"})
+
+	var contextID string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx, _ := GetRequestContext(r)
+		contextID = reqCtx.RequestID
+	})
+
+	var buf bytes.Buffer
+	handler := newTestLogger(t, &buf).Middleware(newTestAuth(keyCache).Middleware(backend))
+
+	req := newAuthRequest("sk_test_valid")
+	req.Header.Set(RequestIDHeader, "trace-abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if contextID != "trace-abc-123" {
+		t.Errorf("Request context ID = %q, want inbound trace-abc-123", contextID)
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != "trace-abc-123" {
+		t.Errorf("Response %s = %q, want trace-abc-123", RequestIDHeader, got)
+	}
+	if entries := decodeLogLines(t, &buf); len(entries) != 1 || entries[0]["request_id"] != "trace-abc-123" {
+		t.Errorf("Log entries = %v, want request_id trace-abc-123", entries)
+	}
+}
@@ -1,43 +1,13 @@
 package middleware
 
 import (
-	"bytes"
-	"io"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/yourusername/gateway/internal/metrics"
+	"github.com/saas-gateway/gateway/internal/metrics"
 )
 
-// responseWriter wraps http.ResponseWriter to capture status code and response size
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode   int
-	bytesWritten int
-	body         *bytes.Buffer
-}
-
-func newResponseWriter(w http.ResponseWriter) *responseWriter {
-	return &responseWriter{
-		ResponseWriter: w,
-		statusCode:     http.StatusOK,
-		body:           &bytes.Buffer{},
-	}
-}
-
-func (rw *responseWriter) WriteHeader(statusCode int) {
-	rw.statusCode = statusCode
-	rw.ResponseWriter.WriteHeader(statusCode)
-}
-
-func (rw *responseWriter) Write(b []byte) (int, error) {
-	n, err := rw.ResponseWriter.Write(b)
-	rw.bytesWritten += n
-	rw.body.Write(b[:n])
-	return n, err
-}
-
 // MetricsMiddleware records HTTP request metrics for Prometheus
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -47,7 +17,7 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 		metrics.IncrementConcurrentRequests()
 		defer metrics.DecrementConcurrentRequests()
 
-		// Wrap the response writer
+		// Wrap the response writer (shared with the request logger)
 		wrapped := newResponseWriter(w)
 
 		// Extract organization ID from context (set by auth middleware)
@@ -77,7 +47,7 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 		metrics.RecordRequest(r.Method, endpoint, statusStr, orgID)
 
 		// Response size
-		metrics.RecordResponseSize(endpoint, wrapped.bytesWritten)
+		metrics.RecordResponseSize(endpoint, wrapped.bytes)
 	})
 }
 
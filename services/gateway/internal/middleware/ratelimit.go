@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -50,7 +51,7 @@ func (rl *RateLimit) Middleware(next http.Handler) http.Handler {
 		if err != nil {
 			// Rate limiter error - fail open (allow request but log error)
 			// In production, you might want to fail closed for better security
-			logRateLimitError(err, reqCtx)
+			logRateLimitError(r, err, reqCtx)
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -120,18 +121,9 @@ func (rl *RateLimit) respondRateLimited(w http.ResponseWriter, r *http.Request,
 }
 
 // logRateLimitError logs errors from the rate limiter (for monitoring)
-func logRateLimitError(err error, reqCtx *models.RequestContext) {
-	logEntry := map[string]interface{}{
-		"level":           "error",
-		"timestamp":       time.Now().UTC().Format(time.RFC3339Nano),
-		"message":         "rate limiter error - failing open",
-		"error":           err.Error(),
-		"request_id":      reqCtx.RequestID,
-		"organization_id": reqCtx.APIKey.OrganizationID,
-		"plan_tier":       reqCtx.APIKey.PlanTier,
-	}
-
-	// In production, send to structured logging system
-	jsonLog, _ := json.Marshal(logEntry)
-	fmt.Println(string(jsonLog))
+func logRateLimitError(r *http.Request, err error, reqCtx *models.RequestContext) {
+	RequestLogger(r).Error("rate limiter error - failing open",
+		slog.String("error", err.Error()),
+		slog.String("plan_tier", reqCtx.APIKey.PlanTier),
+	)
 }
@@ -3,7 +3,7 @@ package middleware
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
 	"time"
@@ -11,13 +11,14 @@ import (
 
 // Recovery handles panics and returns a 500 Internal Server Error
 type Recovery struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 // NewRecovery creates a new recovery middleware
-func NewRecovery() *Recovery {
+// logger is used for panics outside the Logger middleware
+func NewRecovery(logger *slog.Logger) *Recovery {
 	return &Recovery{
-		logger: log.New(log.Writer(), "", 0),
+		logger: logger,
 	}
 }
 
@@ -29,29 +30,13 @@ func (rec *Recovery) Middleware(next http.Handler) http.Handler {
 				// Get stack trace
 				stack := debug.Stack()
 
-				// Get request context if available
-				reqCtx, hasContext := GetRequestContext(r)
-
-				// Build error log
-				logEntry := map[string]interface{}{
-					"level":      "error",
-					"timestamp":  time.Now().UTC().Format(time.RFC3339Nano),
-					"message":    "panic recovered",
-					"error":      fmt.Sprintf("%v", err),
-					"stack":      string(stack),
-					"method":     r.Method,
-					"path":       r.URL.Path,
-					"client_ip":  getClientIP(r),
-				}
-
-				if hasContext {
-					logEntry["request_id"] = reqCtx.RequestID
-					logEntry["organization_id"] = reqCtx.APIKey.OrganizationID
-				}
-
-				// Log error
-				jsonLog, _ := json.Marshal(logEntry)
-				rec.logger.Println(string(jsonLog))
+				requestLogger(r, rec.logger).Error("panic recovered",
+					slog.String("error", fmt.Sprintf("%v", err)),
+					slog.String("stack", string(stack)),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("client_ip", getClientIP(r)),
+				)
 
 				// Return 500 response
 				w.Header().Set("Content-Type", "application/json")
@@ -66,7 +51,7 @@ func (rec *Recovery) Middleware(next http.Handler) http.Handler {
 				}
 
 				// Include request_id if available
-				if hasContext {
+				if reqCtx, hasContext := requestContextForLog(r); hasContext {
 					response["request_id"] = reqCtx.RequestID
 				}
 
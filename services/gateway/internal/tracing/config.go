@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Config holds OpenTelemetry trace export configuration
+type Config struct {
+	Enabled     bool
+	Endpoint    string  // OTLP/HTTP collector address (host:port), e.g. Jaeger's localhost:4318
+	Insecure    bool    // Send to the collector over plain HTTP
+	ServiceName string  // service.name resource attribute
+	SampleRatio float64 // Fraction of new traces recorded (0-1); sampled parents are always followed
+}
+
+// LoadConfig reads tracing configuration from environment variables
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		Enabled:     getEnvBool("TRACING_ENABLED", false),
+		Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4318"),
+		Insecure:    getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", true),
+		ServiceName: getEnv("OTEL_SERVICE_NAME", "saas-gateway"),
+		SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1.0),
+	}
+
+	if cfg.Enabled && cfg.Endpoint == "" {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT is required when TRACING_ENABLED=true")
+	}
+
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got: %v", cfg.SampleRatio)
+	}
+
+	return cfg, nil
+}
+
+// getEnv retrieves an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvBool retrieves a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloat retrieves a float environment variable or returns a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
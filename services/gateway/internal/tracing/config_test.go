@@ -0,0 +1,30 @@
+package tracing
+
+import "testing"
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TRACING_ENABLED", "")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Enabled || cfg.SampleRatio != 1.0 || cfg.ServiceName != "saas-gateway" {
+		t.Errorf("Defaults = %+v", cfg)
+	}
+
+	t.Setenv("TRACING_ENABLED", "true")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "jaeger:4318")
+	t.Setenv("TRACING_SAMPLE_RATIO", "0.25")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if !cfg.Enabled || cfg.Endpoint != "jaeger:4318" || cfg.SampleRatio != 0.25 {
+		t.Errorf("Config = %+v", cfg)
+	}
+
+	t.Setenv("TRACING_SAMPLE_RATIO", "1.5")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected error for sample ratio above 1")
+	}
+}
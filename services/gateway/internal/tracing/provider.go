@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Provider is what the proxy traces requests with
+type Provider struct {
+	TracerProvider trace.TracerProvider
+	Propagator     propagation.TextMapPropagator
+	Shutdown       func(context.Context) error // Flushes buffered spans
+}
+
+// Disabled returns a no-op provider: spans are never recorded and no headers are injected
+func Disabled() *Provider {
+	return &Provider{
+		TracerProvider: noop.NewTracerProvider(),
+		Propagator:     propagation.NewCompositeTextMapPropagator(),
+		Shutdown:       func(context.Context) error { return nil },
+	}
+}
+
+// Setup builds the OTLP tracer provider and W3C trace context propagator, or Disabled()
+// when tracing is off. Nothing is installed globally; the provider is handed to the proxy
+func Setup(ctx context.Context, cfg *Config) (*Provider, error) {
+	if !cfg.Enabled {
+		return Disabled(), nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	return &Provider{
+		TracerProvider: provider,
+		Propagator: propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		),
+		Shutdown: provider.Shutdown,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,7 @@ type APIKey struct {
 	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
 	IsRevoked      bool      `json:"is_revoked"`
 	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	Scopes         []string  `json:"scopes"`
 }
 
 // IsValid checks if the API key is valid for use
@@ -32,6 +34,20 @@ func (a *APIKey) IsValid() bool {
 	return true
 }
 
+// HasScope checks if the API key grants the required scope
+// "*" grants everything, and a bare action (e.g. "read") grants every "read:<resource>" scope
+func (a *APIKey) HasScope(required string) bool {
+	for _, scope := range a.Scopes {
+		if scope == "*" || scope == required {
+			return true
+		}
+		if !strings.Contains(scope, ":") && strings.HasPrefix(required, scope+":") {
+			return true
+		}
+	}
+	return false
+}
+
 // RateLimitConfig returns rate limit configuration based on plan tier
 // These are temporary hardcoded limits; will move to PostgreSQL in Module 1.2
 func (a *APIKey) RateLimitConfig() RateLimit {
@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestAPIKey_HasScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		required string
+		expected bool
+	}{
+		{"Exact match", []string{"read:users"}, "read:users", true},
+		{"Wildcard grants everything", []string{"*"}, "write:billing", true},
+		{"Bare action grants resource scopes", []string{"read", "write"}, "write:billing", true},
+		{"Different resource", []string{"read:users"}, "read:billing", false},
+		{"Different action", []string{"read:billing"}, "write:billing", false},
+		{"Resource scope does not grant bare action", []string{"read:users"}, "read", false},
+		{"No scopes", nil, "read:users", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &APIKey{Scopes: tt.scopes}
+			if got := key.HasScope(tt.required); got != tt.expected {
+				t.Errorf("HasScope(%q) = %v, want %v", tt.required, got, tt.expected)
+			}
+		})
+	}
+}
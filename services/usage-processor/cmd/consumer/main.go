@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,11 +14,29 @@ import (
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/usage-processor/internal/config"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/usage-processor/internal/metrics"
 	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/usage-processor/internal/processor"
+	"github.com/devwithmohit/Multi-Tenant-SaaS-API-Gateway-with-Usage-Based-Billing/services/usage-processor/internal/retry"
 )
 
+// consumerErrorPolicy backs off on repeated consumer errors (broker down, rebalance storms)
+var consumerErrorPolicy = retry.Policy{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Jitter:       0.2,
+}
+
+// writeBatchPolicy retries transient TimescaleDB failures before a flush gives up (the batch is kept)
+var writeBatchPolicy = retry.Policy{
+	MaxAttempts:  4,
+	InitialDelay: 250 * time.Millisecond,
+	MaxDelay:     5 * time.Second,
+	Jitter:       0.2,
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("🚀 Starting Usage Processor Service...")
@@ -51,9 +70,17 @@ func main() {
 	defer deduplicator.Close()
 	log.Printf("✅ Deduplicator initialized (window: %v)", cfg.DeduplicationWindow)
 
-	writer := processor.NewWriter(db, cfg.BatchSize)
+	writer := processor.NewWriter(db, cfg.BatchSize, cfg.CopyThreshold)
 	defer writer.Close()
-	log.Printf("✅ Writer initialized (batch size: %d)", cfg.BatchSize)
+	log.Printf("✅ Writer initialized (batch size: %d, COPY from %d events)", cfg.BatchSize, cfg.CopyThreshold)
+
+	// Load offsets already written to TimescaleDB so replays after a crash are skipped
+	committed, err := writer.LoadCommittedOffsets(cfg.KafkaTopic)
+	if err != nil {
+		log.Fatalf("Failed to load committed offsets: %v", err)
+	}
+	tracker := processor.NewOffsetTracker(committed)
+	log.Printf("✅ Offset tracker initialized (%d partitions)", len(committed))
 
 	// Create Kafka consumer
 	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
@@ -73,13 +100,33 @@ func main() {
 	defer consumer.Close()
 	log.Println("✅ Kafka consumer created")
 
-	// Subscribe to topic
-	err = consumer.Subscribe(cfg.KafkaTopic, nil)
+	// Subscribe to topic, reloading written offsets whenever partitions are assigned
+	err = consumer.Subscribe(cfg.KafkaTopic, func(c *kafka.Consumer, event kafka.Event) error {
+		if _, ok := event.(kafka.AssignedPartitions); ok {
+			offsets, err := writer.LoadCommittedOffsets(cfg.KafkaTopic)
+			if err != nil {
+				log.Printf("⚠️  Failed to reload committed offsets: %v", err)
+				return nil
+			}
+			tracker.Reset(offsets)
+		}
+		return nil
+	})
 	if err != nil {
 		log.Fatalf("Failed to subscribe to topic: %v", err)
 	}
 	log.Printf("✅ Subscribed to topic: %s", cfg.KafkaTopic)
 
+	// Expose Prometheus metrics (consumer lag, write throughput)
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+			log.Printf("⚠️  Metrics server stopped: %v", err)
+		}
+	}()
+	log.Printf("✅ Metrics available on %s/metrics", cfg.MetricsAddr)
+
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -95,12 +142,12 @@ func main() {
 	}()
 
 	log.Println("🎧 Consumer ready, waiting for events...")
-	processEvents(ctx, consumer, writer, deduplicator, cfg)
+	processEvents(ctx, consumer, writer, deduplicator, tracker, cfg)
 
 	// Print final statistics
 	written, duplicates := writer.GetStats()
-	log.Printf("📊 Final Stats - Written: %d, Duplicates: %d, Dedup Cache: %d",
-		written, duplicates, deduplicator.Size())
+	log.Printf("📊 Final Stats - Written: %d, Duplicates: %d, Replayed: %d, Dedup Cache: %d",
+		written, duplicates, tracker.Skipped(), deduplicator.Size())
 	log.Println("👋 Usage Processor shut down gracefully")
 }
 
@@ -110,45 +157,56 @@ func processEvents(
 	consumer *kafka.Consumer,
 	writer *processor.Writer,
 	deduplicator *processor.Deduplicator,
+	tracker *processor.OffsetTracker,
 	cfg *config.Config,
 ) {
-	batch := make([]processor.UsageEvent, 0, cfg.BatchSize)
+	batch := processor.NewBatch(cfg.BatchSize, cfg.MaxStaleness)
 	batchTimer := time.NewTimer(cfg.BatchTimeout)
 	defer batchTimer.Stop()
 
 	messageCount := 0
 	lastStatsTime := time.Now()
+	lastLagTime := time.Now()
 	statsInterval := 30 * time.Second
+	errBackoff := retry.NewBackoff(consumerErrorPolicy)
+
+	flush := func(reason string) {
+		// A batch that failed to write stays buffered with its offsets, so the next flush
+		// writes it again together with whatever arrived since
+		if err := flushBatch(consumer, writer, tracker, cfg.KafkaTopic, batch.Events(), reason); err == nil {
+			batch.Reset()
+		}
+		batchTimer.Reset(cfg.BatchTimeout)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			// Flush remaining batch before shutdown
-			if len(batch) > 0 {
-				log.Printf("⚠️  Flushing final batch of %d events...", len(batch))
-				if err := writer.WriteBatch(batch); err != nil {
-					log.Printf("❌ Failed to write final batch: %v", err)
-				}
+			if batch.Len() > 0 {
+				log.Printf("⚠️  Flushing final batch of %d events...", batch.Len())
+			}
+			// Nothing is committed if this fails, so Kafka redelivers the batch after restart
+			if err := flushBatch(consumer, writer, tracker, cfg.KafkaTopic, batch.Events(), processor.FlushShutdown); err != nil {
+				log.Printf("⚠️  Final batch of %d events not written; it will be redelivered", batch.Len())
 			}
 			return
 
 		case <-batchTimer.C:
 			// Timeout: flush current batch
-			if len(batch) > 0 {
-				if err := writer.WriteBatch(batch); err != nil {
-					log.Printf("❌ Failed to write batch: %v", err)
-				}
+			flush(processor.FlushTimeout)
 
-				// Commit offset after successful write
-				if _, err := consumer.Commit(); err != nil {
-					log.Printf("⚠️  Failed to commit offset: %v", err)
-				}
+		default:
+			// Forced flush: a trickle under BatchSize never waits longer than MaxStaleness
+			if batch.Stale() {
+				flush(processor.FlushStale)
+			}
 
-				batch = batch[:0] // Clear batch
+			if time.Since(lastLagTime) > cfg.LagInterval {
+				recordConsumerLag(consumer, tracker, cfg.KafkaTopic)
+				lastLagTime = time.Now()
 			}
-			batchTimer.Reset(cfg.BatchTimeout)
 
-		default:
 			// Poll for messages
 			msg, err := consumer.ReadMessage(100 * time.Millisecond)
 			if err != nil {
@@ -157,13 +215,20 @@ func processEvents(
 						continue // Normal timeout, keep polling
 					}
 				}
-				log.Printf("⚠️  Consumer error: %v", err)
-				time.Sleep(1 * time.Second)
+				delay := errBackoff.Next()
+				log.Printf("⚠️  Consumer error (retrying in %v): %v", delay.Round(time.Millisecond), err)
+				retry.Sleep(ctx, delay)
 				continue
 			}
 
+			errBackoff.Reset()
 			messageCount++
 
+			// Skip offsets already written to TimescaleDB (redelivery after a crash)
+			if !tracker.Accept(msg.TopicPartition.Partition, int64(msg.TopicPartition.Offset)) {
+				continue
+			}
+
 			// Parse event
 			var event processor.UsageEvent
 			if err := json.Unmarshal(msg.Value, &event); err != nil {
@@ -177,31 +242,75 @@ func processEvents(
 				continue
 			}
 
-			// Add to batch
-			batch = append(batch, event)
-
-			// Flush if batch is full
-			if len(batch) >= cfg.BatchSize {
-				if err := writer.WriteBatch(batch); err != nil {
-					log.Printf("❌ Failed to write batch: %v", err)
-				}
-
-				// Commit offset after successful write
-				if _, err := consumer.Commit(); err != nil {
-					log.Printf("⚠️  Failed to commit offset: %v", err)
-				}
-
-				batch = batch[:0] // Clear batch
-				batchTimer.Reset(cfg.BatchTimeout)
+			// Add to batch, flushing if it is full
+			if batch.Add(event) {
+				flush(processor.FlushFull)
 			}
 
 			// Print periodic statistics
 			if time.Since(lastStatsTime) > statsInterval {
 				written, duplicates := writer.GetStats()
-				log.Printf("📊 Stats - Messages: %d, Written: %d, Duplicates: %d, Dedup Cache: %d, Batch: %d",
-					messageCount, written, duplicates, deduplicator.Size(), len(batch))
+				log.Printf("📊 Stats - Messages: %d, Written: %d, Duplicates: %d, Replayed: %d, Dedup Cache: %d, Batch: %d",
+					messageCount, written, duplicates, tracker.Skipped(), deduplicator.Size(), batch.Len())
 				lastStatsTime = time.Now()
 			}
 		}
 	}
 }
+
+// flushBatch writes a batch with its offsets in one transaction, then commits the Kafka offset
+// On failure nothing is committed and the pending offsets are kept: the consumed positions
+// include the failed events, so committing them would lose that usage for good
+func flushBatch(
+	consumer *kafka.Consumer,
+	writer *processor.Writer,
+	tracker *processor.OffsetTracker,
+	topic string,
+	batch []processor.UsageEvent,
+	reason string,
+) error {
+	offsets := tracker.Pending()
+	if len(batch) == 0 && len(offsets) == 0 {
+		return nil
+	}
+
+	// Retry in a fresh context so the final flush on shutdown still gets its attempts
+	err := retry.Do(context.Background(), writeBatchPolicy, func(ctx context.Context) error {
+		return writer.WriteBatchWithOffsets(batch, topic, offsets)
+	})
+	if err != nil {
+		log.Printf("❌ Failed to write batch, keeping %d events for the next flush: %v", len(batch), err)
+		metrics.RecordBatchFailure(reason)
+		return err
+	}
+	tracker.MarkWritten()
+	stats := writer.LastBatch()
+	metrics.RecordBatchWrite(reason, stats.Written, stats.Duplicates, stats.Throughput())
+
+	// Commit offset after write
+	if _, err := consumer.Commit(); err != nil {
+		log.Printf("⚠️  Failed to commit offset: %v", err)
+	}
+	return nil
+}
+
+// recordConsumerLag publishes how far each assigned partition is behind its high-water mark
+// GetWatermarkOffsets reads the values cached from fetch responses, so this makes no broker call
+func recordConsumerLag(consumer *kafka.Consumer, tracker *processor.OffsetTracker, topic string) {
+	assigned, err := consumer.Assignment()
+	if err != nil {
+		log.Printf("⚠️  Failed to read partition assignment: %v", err)
+		return
+	}
+
+	high := make(map[int32]int64, len(assigned))
+	for _, tp := range assigned {
+		_, highWatermark, err := consumer.GetWatermarkOffsets(topic, tp.Partition)
+		if err != nil || highWatermark < 0 {
+			continue // Not fetched from this partition yet
+		}
+		high[tp.Partition] = highWatermark
+	}
+
+	metrics.SetConsumerLag(tracker.Lag(high))
+}
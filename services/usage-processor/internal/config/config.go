@@ -19,6 +19,8 @@ type Config struct {
 	BatchSize           int
 	BatchTimeout        time.Duration
 	DeduplicationWindow time.Duration
+	MaxStaleness        time.Duration // Longest an event may wait in a partial batch
+	CopyThreshold       int           // Batches this large use COPY instead of INSERT
 
 	// Database settings
 	DatabaseURL string
@@ -26,6 +28,10 @@ type Config struct {
 
 	// Logging
 	LogLevel string
+
+	// Monitoring
+	MetricsAddr string
+	LagInterval time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
@@ -41,6 +47,8 @@ func LoadConfig() (*Config, error) {
 		BatchSize:            getEnvInt("BATCH_SIZE", 1000),
 		BatchTimeout:         getEnvDuration("BATCH_TIMEOUT", 5*time.Second),
 		DeduplicationWindow:  getEnvDuration("DEDUP_WINDOW", 5*time.Minute),
+		MaxStaleness:         getEnvDuration("MAX_STALENESS", 2*time.Second),
+		CopyThreshold:        getEnvInt("COPY_THRESHOLD", 100),
 
 		// Database defaults
 		DatabaseURL:    os.Getenv("DATABASE_URL"),
@@ -48,6 +56,10 @@ func LoadConfig() (*Config, error) {
 
 		// Logging
 		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		// Monitoring
+		MetricsAddr: getEnv("METRICS_ADDR", ":9091"),
+		LagInterval: getEnvDuration("LAG_INTERVAL", 15*time.Second),
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -75,6 +87,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("DB_MAX_CONNECTIONS must be between 1 and 100")
 	}
 
+	if c.CopyThreshold < 1 {
+		return fmt.Errorf("COPY_THRESHOLD must be at least 1")
+	}
+
+	if c.MaxStaleness <= 0 {
+		return fmt.Errorf("MAX_STALENESS must be positive")
+	}
+
+	if c.LagInterval < time.Second {
+		return fmt.Errorf("LAG_INTERVAL must be at least 1s")
+	}
+
 	return nil
 }
 
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// ConsumerLag tracks how many messages each partition is behind the high-water mark
+	ConsumerLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "usage_processor_consumer_lag",
+			Help: "Messages between the last offset written to TimescaleDB and the partition high-water mark",
+		},
+		[]string{"partition"},
+	)
+
+	// EventsWritten counts events written to TimescaleDB
+	EventsWritten = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "usage_processor_events_written_total",
+			Help: "Total number of usage events written to TimescaleDB",
+		},
+	)
+
+	// DuplicatesSkipped counts events skipped by the usage_events unique constraint
+	DuplicatesSkipped = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "usage_processor_duplicates_skipped_total",
+			Help: "Total number of duplicate usage events skipped during COPY",
+		},
+	)
+
+	// WriteThroughput tracks the write rate of the last batch
+	WriteThroughput = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "usage_processor_write_throughput_events_per_second",
+			Help: "Events per second written by the last batch",
+		},
+	)
+
+	// BatchFlushes counts batch flushes by trigger
+	BatchFlushes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "usage_processor_batch_flushes_total",
+			Help: "Total number of batch flushes by reason",
+		},
+		[]string{"reason"},
+	)
+
+	// BatchWriteFailures counts batches dropped after all write retries failed
+	BatchWriteFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "usage_processor_batch_write_failures_total",
+			Help: "Total number of batches that failed to write after retries",
+		},
+	)
+)
+
+// RecordBatchWrite records a successful batch write
+func RecordBatchWrite(reason string, written, duplicates int, throughput float64) {
+	BatchFlushes.WithLabelValues(reason).Inc()
+	EventsWritten.Add(float64(written))
+	DuplicatesSkipped.Add(float64(duplicates))
+	WriteThroughput.Set(throughput)
+}
+
+// RecordBatchFailure records a batch write that failed after retries (the batch is kept for the next flush)
+func RecordBatchFailure(reason string) {
+	BatchFlushes.WithLabelValues(reason).Inc()
+	BatchWriteFailures.Inc()
+}
+
+// SetConsumerLag replaces the per-partition lag, dropping partitions no longer assigned
+func SetConsumerLag(lag map[int32]int64) {
+	ConsumerLag.Reset()
+	for partition, behind := range lag {
+		ConsumerLag.WithLabelValues(strconv.Itoa(int(partition))).Set(float64(behind))
+	}
+}
@@ -0,0 +1,79 @@
+package processor
+
+import "time"
+
+// Flush reasons, reported as the usage_processor_batch_flushes_total reason label
+const (
+	FlushFull     = "full"     // Batch reached BatchSize
+	FlushTimeout  = "timeout"  // BATCH_TIMEOUT elapsed
+	FlushStale    = "stale"    // Oldest event reached MAX_STALENESS
+	FlushShutdown = "shutdown" // Final flush before exit
+)
+
+// Batch accumulates events until it is full or its oldest event is older than maxStaleness
+// The staleness check is independent of the batch timer, which restarts on every flush,
+// so a trickle of events never waits longer than maxStaleness to be written
+type Batch struct {
+	events       []UsageEvent
+	size         int
+	maxStaleness time.Duration
+	oldest       time.Time // When the first event of the current batch was added
+	now          func() time.Time
+}
+
+// NewBatch creates a batch that is full at size events
+// maxStaleness <= 0 disables the staleness flush
+func NewBatch(size int, maxStaleness time.Duration) *Batch {
+	return &Batch{
+		events:       make([]UsageEvent, 0, size),
+		size:         size,
+		maxStaleness: maxStaleness,
+		now:          time.Now,
+	}
+}
+
+// Add appends an event and reports whether the batch is now full
+func (b *Batch) Add(event UsageEvent) bool {
+	if len(b.events) == 0 {
+		b.oldest = b.now()
+	}
+	b.events = append(b.events, event)
+	return b.Full()
+}
+
+// Full reports whether the batch has reached its size
+func (b *Batch) Full() bool {
+	return len(b.events) >= b.size
+}
+
+// Stale reports whether the oldest buffered event has waited maxStaleness or longer
+func (b *Batch) Stale() bool {
+	if b.maxStaleness <= 0 || len(b.events) == 0 {
+		return false
+	}
+	return b.now().Sub(b.oldest) >= b.maxStaleness
+}
+
+// Age returns how long the oldest buffered event has waited (0 when empty)
+func (b *Batch) Age() time.Duration {
+	if len(b.events) == 0 {
+		return 0
+	}
+	return b.now().Sub(b.oldest)
+}
+
+// Events returns the buffered events; the slice is reused after Reset
+func (b *Batch) Events() []UsageEvent {
+	return b.events
+}
+
+// Len returns the number of buffered events
+func (b *Batch) Len() int {
+	return len(b.events)
+}
+
+// Reset empties the batch after a flush, keeping its capacity
+func (b *Batch) Reset() {
+	b.events = b.events[:0]
+	b.oldest = time.Time{}
+}
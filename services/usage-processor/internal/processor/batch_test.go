@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestBatch returns a batch whose clock is advanced by the returned func
+func newTestBatch(size int, maxStaleness time.Duration) (*Batch, func(time.Duration)) {
+	now := time.Date(2026, 1, 26, 10, 0, 0, 0, time.UTC)
+	b := NewBatch(size, maxStaleness)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBatch_FullAtSize(t *testing.T) {
+	b, _ := newTestBatch(3, time.Second)
+
+	for i := 0; i < 2; i++ {
+		if b.Add(UsageEvent{}) {
+			t.Fatalf("Batch full after %d events, want 3", i+1)
+		}
+	}
+	if !b.Add(UsageEvent{}) {
+		t.Error("Batch not full at 3 events")
+	}
+}
+
+func TestBatch_StaleTrickle(t *testing.T) {
+	b, advance := newTestBatch(1000, 2*time.Second)
+
+	if b.Stale() {
+		t.Fatal("Empty batch reported stale")
+	}
+
+	// A trickle well under BatchSize: staleness is measured from the first event
+	b.Add(UsageEvent{RequestID: "req-1"})
+	advance(1500 * time.Millisecond)
+	b.Add(UsageEvent{RequestID: "req-2"})
+	if b.Stale() {
+		t.Fatalf("Batch stale after %v, want %v", b.Age(), 2*time.Second)
+	}
+
+	advance(500 * time.Millisecond)
+	if !b.Stale() {
+		t.Fatalf("Batch not stale after %v with max staleness 2s", b.Age())
+	}
+	if b.Full() {
+		t.Error("Trickle batch should not be full")
+	}
+
+	// After the forced flush the next event starts a fresh window
+	b.Reset()
+	if b.Stale() || b.Len() != 0 {
+		t.Error("Reset batch still stale or non-empty")
+	}
+	b.Add(UsageEvent{RequestID: "req-3"})
+	advance(time.Second)
+	if b.Stale() {
+		t.Error("New batch inherited the previous batch's age")
+	}
+}
+
+func TestBatch_StalenessDisabled(t *testing.T) {
+	b, advance := newTestBatch(10, 0)
+	b.Add(UsageEvent{})
+	advance(time.Hour)
+
+	if b.Stale() {
+		t.Error("Batch with max staleness 0 reported stale")
+	}
+}
+
+func TestBatchStats_Throughput(t *testing.T) {
+	stats := BatchStats{Written: 500, Duration: 250 * time.Millisecond}
+	if got := stats.Throughput(); got != 2000 {
+		t.Errorf("Throughput() = %v, want 2000", got)
+	}
+	if got := (BatchStats{}).Throughput(); got != 0 {
+		t.Errorf("Zero stats Throughput() = %v, want 0", got)
+	}
+}
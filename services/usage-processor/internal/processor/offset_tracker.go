@@ -0,0 +1,105 @@
+package processor
+
+import "sync"
+
+// OffsetTracker skips Kafka messages whose offsets were already written to TimescaleDB
+// Committed offsets come from the kafka_consumer_offsets table, which the writer updates in
+// the same transaction as the events. After a crash between that commit and the Kafka
+// offset commit, the redelivered range is dropped here instead of being written twice.
+type OffsetTracker struct {
+	mu        sync.Mutex
+	committed map[int32]int64 // partition -> highest offset written
+	pending   map[int32]int64 // partition -> highest offset in the current batch
+	skipped   int64
+}
+
+// NewOffsetTracker creates a tracker from the offsets last written for a topic
+func NewOffsetTracker(committed map[int32]int64) *OffsetTracker {
+	t := &OffsetTracker{pending: make(map[int32]int64)}
+	t.Reset(committed)
+	return t
+}
+
+// Reset replaces the committed offsets (e.g. after a partition rebalance)
+// Pending offsets are kept since their events are still in the unflushed batch
+func (t *OffsetTracker) Reset(committed map[int32]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.committed = make(map[int32]int64, len(committed))
+	for partition, offset := range committed {
+		t.committed[partition] = offset
+	}
+}
+
+// Accept reports whether a message should be processed, recording its offset for the batch
+// Returns false for offsets at or below the last one written for the partition
+func (t *OffsetTracker) Accept(partition int32, offset int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.committed[partition]; ok && offset <= last {
+		t.skipped++
+		return false
+	}
+
+	if current, ok := t.pending[partition]; !ok || offset > current {
+		t.pending[partition] = offset
+	}
+	return true
+}
+
+// Pending returns the highest accepted offset per partition for the current batch
+func (t *OffsetTracker) Pending() map[int32]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make(map[int32]int64, len(t.pending))
+	for partition, offset := range t.pending {
+		pending[partition] = offset
+	}
+	return pending
+}
+
+// MarkWritten promotes pending offsets to committed after the batch transaction succeeds
+func (t *OffsetTracker) MarkWritten() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for partition, offset := range t.pending {
+		if last, ok := t.committed[partition]; !ok || offset > last {
+			t.committed[partition] = offset
+		}
+	}
+	t.pending = make(map[int32]int64)
+}
+
+// Skipped returns the number of messages dropped as already written
+func (t *OffsetTracker) Skipped() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.skipped
+}
+
+// Lag returns how many messages each partition is behind its high-water mark,
+// counting from the last offset written to TimescaleDB
+// highWatermarks maps partition -> next offset Kafka will assign; partitions with
+// nothing written yet are left out since their starting point is unknown
+func (t *OffsetTracker) Lag(highWatermarks map[int32]int64) map[int32]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lag := make(map[int32]int64, len(highWatermarks))
+	for partition, high := range highWatermarks {
+		last, ok := t.committed[partition]
+		if !ok {
+			continue
+		}
+		behind := high - (last + 1)
+		if behind < 0 {
+			behind = 0
+		}
+		lag[partition] = behind
+	}
+	return lag
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+// replay feeds offsets [from, to] on a partition through the tracker and returns the accepted batch
+func replay(t *OffsetTracker, partition int32, from, to int64) []int64 {
+	var batch []int64
+	for offset := from; offset <= to; offset++ {
+		if t.Accept(partition, offset) {
+			batch = append(batch, offset)
+		}
+	}
+	return batch
+}
+
+func TestOffsetTracker_ReplayAfterCrashWritesNothing(t *testing.T) {
+	// First run: offsets 0-9 written, then the process dies before the Kafka commit
+	tracker := NewOffsetTracker(nil)
+	written := replay(tracker, 0, 0, 9)
+	if len(written) != 10 {
+		t.Fatalf("First run accepted %d messages, want 10", len(written))
+	}
+
+	persisted := tracker.Pending()
+	tracker.MarkWritten()
+
+	// Restart: Kafka redelivers 0-9 from its last commit, plus new messages 10-12
+	restarted := NewOffsetTracker(persisted)
+	if batch := replay(restarted, 0, 0, 9); len(batch) != 0 {
+		t.Errorf("Replayed range wrote %d rows, want 0 (offsets %v)", len(batch), batch)
+	}
+	if batch := replay(restarted, 0, 10, 12); !reflect.DeepEqual(batch, []int64{10, 11, 12}) {
+		t.Errorf("New offsets = %v, want [10 11 12]", batch)
+	}
+	if restarted.Skipped() != 10 {
+		t.Errorf("Skipped = %d, want 10", restarted.Skipped())
+	}
+}
+
+func TestOffsetTracker_PartitionsAreIndependent(t *testing.T) {
+	tracker := NewOffsetTracker(map[int32]int64{0: 100})
+
+	if tracker.Accept(0, 100) {
+		t.Error("Expected committed offset on partition 0 to be skipped")
+	}
+	if !tracker.Accept(1, 50) {
+		t.Error("Expected partition 1 offset to be accepted")
+	}
+	if !tracker.Accept(0, 101) {
+		t.Error("Expected offset past commit on partition 0 to be accepted")
+	}
+
+	expected := map[int32]int64{0: 101, 1: 50}
+	if got := tracker.Pending(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Pending = %v, want %v", got, expected)
+	}
+}
+
+func TestOffsetTracker_PendingKeptAfterFailedWrite(t *testing.T) {
+	tracker := NewOffsetTracker(map[int32]int64{0: 9})
+	replay(tracker, 0, 10, 14)
+
+	// Write failed: the batch stays buffered, so its offsets stay pending and grow with new messages
+	replay(tracker, 0, 15, 16)
+	if got := tracker.Pending(); !reflect.DeepEqual(got, map[int32]int64{0: 16}) {
+		t.Errorf("Pending after failed write = %v, want map[0:16]", got)
+	}
+
+	// The next flush writes the whole range at once
+	tracker.MarkWritten()
+	if batch := replay(tracker, 0, 10, 16); len(batch) != 0 {
+		t.Errorf("Written range accepted %d messages again, want 0", len(batch))
+	}
+}
+
+func TestOffsetTracker_ResetKeepsPending(t *testing.T) {
+	tracker := NewOffsetTracker(nil)
+	tracker.Accept(0, 5)
+
+	// Rebalance reloads committed offsets; the unflushed batch's offsets stay pending
+	tracker.Reset(map[int32]int64{1: 20})
+	if got := tracker.Pending(); !reflect.DeepEqual(got, map[int32]int64{0: 5}) {
+		t.Errorf("Pending after reset = %v, want map[0:5]", got)
+	}
+	if tracker.Accept(1, 20) {
+		t.Error("Expected reloaded commit on partition 1 to be enforced")
+	}
+}
+
+func TestOffsetTracker_Lag(t *testing.T) {
+	tracker := NewOffsetTracker(map[int32]int64{0: 99, 1: 10})
+
+	lag := tracker.Lag(map[int32]int64{
+		0: 150, // Offsets 100-149 not written yet
+		1: 11,  // Caught up: offset 10 written, next is 11
+		2: 40,  // Nothing written on this partition yet
+	})
+
+	expected := map[int32]int64{0: 50, 1: 0}
+	if !reflect.DeepEqual(lag, expected) {
+		t.Errorf("Lag() = %v, want %v", lag, expected)
+	}
+}
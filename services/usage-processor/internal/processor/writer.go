@@ -2,8 +2,10 @@ package processor
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -21,28 +23,85 @@ type UsageEvent struct {
 	ResponseTimeMs  int       `json:"response_time_ms"`
 	Billable        bool      `json:"billable"`
 	Weight          int       `json:"weight"`
+	Cached          bool      `json:"cached"`
+	Region          string    `json:"region"`
+	BytesIn         int64     `json:"bytes_in"`  // Request body size, billed as bandwidth
+	BytesOut        int64     `json:"bytes_out"` // Response body size, billed as bandwidth
 }
 
 // Writer handles batch writing of usage events to TimescaleDB
 type Writer struct {
 	db             *sql.DB
 	batchSize      int
+	copyThreshold  int // Batches of at least this many events use COPY
 	writeCount     int64
 	duplicateCount int64
+	lastBatch      BatchStats
+}
+
+// BatchStats describes the last successful batch write
+type BatchStats struct {
+	Written    int
+	Duplicates int
+	Duration   time.Duration
+	At         time.Time
+}
+
+// Throughput returns the batch's write rate in events per second
+func (s BatchStats) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Written) / s.Duration.Seconds()
+}
+
+// DefaultCopyThreshold is the batch size from which COPY beats a multi-row INSERT
+const DefaultCopyThreshold = 100
+
+// insertChunkRows keeps a multi-row INSERT well under PostgreSQL's 65535 bind parameter limit
+const insertChunkRows = 1000
+
+// usageEventColumns are written by both insert paths, in eventValues order
+var usageEventColumns = []string{
+	"time",
+	"request_id",
+	"organization_id",
+	"api_key_id",
+	"endpoint",
+	"method",
+	"status_code",
+	"response_time_ms",
+	"billable",
+	"weight",
+	"cached",
+	"region",
+	"bytes_in",
+	"bytes_out",
 }
 
 // NewWriter creates a new writer instance
-func NewWriter(db *sql.DB, batchSize int) *Writer {
+// copyThreshold <= 0 uses DefaultCopyThreshold
+func NewWriter(db *sql.DB, batchSize, copyThreshold int) *Writer {
+	if copyThreshold <= 0 {
+		copyThreshold = DefaultCopyThreshold
+	}
 	return &Writer{
-		db:        db,
-		batchSize: batchSize,
+		db:            db,
+		batchSize:     batchSize,
+		copyThreshold: copyThreshold,
 	}
 }
 
-// WriteBatch writes a batch of usage events to TimescaleDB using COPY protocol
-// This is the fastest way to insert data into PostgreSQL/TimescaleDB
+// WriteBatch writes a batch of usage events to TimescaleDB
+// Batches at or above the COPY threshold use the COPY protocol, the fastest way to load PostgreSQL/TimescaleDB
 func (w *Writer) WriteBatch(events []UsageEvent) error {
-	if len(events) == 0 {
+	return w.WriteBatchWithOffsets(events, "", nil)
+}
+
+// WriteBatchWithOffsets writes events and records the Kafka offsets they came from in one transaction
+// offsets maps partition -> highest offset in the batch; a batch of only skipped messages still advances them
+func (w *Writer) WriteBatchWithOffsets(events []UsageEvent, topic string, offsets map[int32]int64) error {
+	if len(events) == 0 && len(offsets) == 0 {
 		return nil
 	}
 
@@ -55,67 +114,35 @@ func (w *Writer) WriteBatch(events []UsageEvent) error {
 	}
 	defer txn.Rollback() // Rollback if not committed
 
-	// Prepare COPY statement
-	stmt, err := txn.Prepare(pq.CopyIn(
-		"usage_events",
-		"time",
-		"request_id",
-		"organization_id",
-		"api_key_id",
-		"endpoint",
-		"method",
-		"status_code",
-		"response_time_ms",
-		"billable",
-		"weight",
-	))
+	// Large batches use COPY; below the threshold a parameterized INSERT has less setup cost
+	var duplicates int
+	if len(events) >= w.copyThreshold {
+		duplicates, err = copyEvents(txn, events)
+	} else {
+		duplicates, err = insertEvents(txn, events)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to prepare COPY statement: %w", err)
+		return err
 	}
 
-	// Execute COPY for each event
-	duplicates := 0
-	for _, event := range events {
-		_, err = stmt.Exec(
-			event.Time,
-			event.RequestID,
-			event.OrganizationID,
-			event.APIKeyID,
-			event.Endpoint,
-			event.Method,
-			event.StatusCode,
-			event.ResponseTimeMs,
-			event.Billable,
-			event.Weight,
-		)
+	// Record offsets in the same transaction so events and offsets commit together
+	for partition, offset := range offsets {
+		_, err = txn.Exec(`
+			INSERT INTO kafka_consumer_offsets (topic, partition_id, last_offset, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (topic, partition_id) DO UPDATE
+			SET last_offset = GREATEST(kafka_consumer_offsets.last_offset, EXCLUDED.last_offset),
+			    updated_at = NOW()
+		`, topic, partition, offset)
 		if err != nil {
-			// Check for duplicate key violation (23505)
-			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-				duplicates++
-				continue // Skip duplicate, don't fail entire batch
-			}
-			stmt.Close()
-			return fmt.Errorf("failed to execute COPY: %w", err)
+			return fmt.Errorf("failed to record offset for partition %d: %w", partition, err)
 		}
 	}
 
-	// Flush buffered data
-	_, err = stmt.Exec()
-	if err != nil {
-		stmt.Close()
-		return fmt.Errorf("failed to flush COPY data: %w", err)
-	}
-
-	// Close statement
-	err = stmt.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close COPY statement: %w", err)
-	}
-
 	// Commit transaction
 	err = txn.Commit()
 	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return fmt.Errorf("failed to commit transaction (%s): %w", batchRequestIDs(events), err)
 	}
 
 	// Update metrics
@@ -123,15 +150,195 @@ func (w *Writer) WriteBatch(events []UsageEvent) error {
 	w.writeCount += int64(written)
 	w.duplicateCount += int64(duplicates)
 
-	duration := time.Since(startTime)
-	throughput := float64(written) / duration.Seconds()
+	w.lastBatch = BatchStats{
+		Written:    written,
+		Duplicates: duplicates,
+		Duration:   time.Since(startTime),
+		At:         time.Now(),
+	}
 
 	log.Printf("[Writer] Wrote %d events (%d duplicates skipped) in %v (%.0f events/sec)",
-		written, duplicates, duration, throughput)
+		written, duplicates, w.lastBatch.Duration, w.lastBatch.Throughput())
 
 	return nil
 }
 
+// eventValues returns an event's column values in usageEventColumns order
+func eventValues(event UsageEvent) []interface{} {
+	// Events from older gateways carry no region
+	region := event.Region
+	if region == "" {
+		region = "unknown"
+	}
+
+	return []interface{}{
+		event.Time,
+		event.RequestID,
+		event.OrganizationID,
+		event.APIKeyID,
+		event.Endpoint,
+		event.Method,
+		event.StatusCode,
+		event.ResponseTimeMs,
+		event.Billable,
+		event.Weight,
+		event.Cached,
+		region,
+		event.BytesIn,
+		event.BytesOut,
+	}
+}
+
+// copyEvents bulk-loads events with the COPY protocol and returns the duplicates skipped
+// COPY aborts on the first duplicate request_id, so it runs under a savepoint and a batch
+// that hits one is rewritten through insertEvents, which skips duplicates row by row
+func copyEvents(txn *sql.Tx, events []UsageEvent) (int, error) {
+	if _, err := txn.Exec("SAVEPOINT copy_events"); err != nil {
+		return 0, fmt.Errorf("failed to create COPY savepoint: %w", err)
+	}
+
+	err := copyIn(txn, events)
+	if isUniqueViolation(err) {
+		if _, err := txn.Exec("ROLLBACK TO SAVEPOINT copy_events"); err != nil {
+			return 0, fmt.Errorf("failed to roll back COPY (%s): %w", batchRequestIDs(events), err)
+		}
+		return insertEvents(txn, events)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := txn.Exec("RELEASE SAVEPOINT copy_events"); err != nil {
+		return 0, fmt.Errorf("failed to release COPY savepoint: %w", err)
+	}
+	return 0, nil
+}
+
+// copyIn streams events into usage_events with a single COPY statement
+func copyIn(txn *sql.Tx, events []UsageEvent) error {
+	stmt, err := txn.Prepare(pq.CopyIn("usage_events", usageEventColumns...))
+	if err != nil {
+		return fmt.Errorf("failed to prepare COPY statement: %w", err)
+	}
+
+	for _, event := range events {
+		if _, err := stmt.Exec(eventValues(event)...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to execute COPY for request_id=%s: %w", event.RequestID, err)
+		}
+	}
+
+	// Flush buffered data; row errors such as duplicates surface here
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to flush COPY data (%s): %w", batchRequestIDs(events), err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to close COPY statement (%s): %w", batchRequestIDs(events), err)
+	}
+	return nil
+}
+
+// insertEvents writes events with multi-row INSERTs and returns the duplicates skipped
+func insertEvents(txn *sql.Tx, events []UsageEvent) (int, error) {
+	duplicates := 0
+	for start := 0; start < len(events); start += insertChunkRows {
+		end := start + insertChunkRows
+		if end > len(events) {
+			end = len(events)
+		}
+		chunk := events[start:end]
+
+		args := make([]interface{}, 0, len(chunk)*len(usageEventColumns))
+		for _, event := range chunk {
+			args = append(args, eventValues(event)...)
+		}
+
+		result, err := txn.Exec(buildInsertQuery(len(chunk)), args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert events (%s): %w", batchRequestIDs(chunk), err)
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read inserted row count: %w", err)
+		}
+		duplicates += len(chunk) - int(inserted)
+	}
+	return duplicates, nil
+}
+
+// buildInsertQuery returns a multi-row INSERT for rows events that skips duplicate request IDs
+func buildInsertQuery(rows int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO usage_events (")
+	b.WriteString(strings.Join(usageEventColumns, ", "))
+	b.WriteString(") VALUES ")
+
+	columns := len(usageEventColumns)
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for column := 0; column < columns; column++ {
+			if column > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", row*columns+column+1)
+		}
+		b.WriteByte(')')
+	}
+
+	b.WriteString(" ON CONFLICT (request_id) DO NOTHING")
+	return b.String()
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation (23505)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// batchRequestIDs describes a batch's request IDs for error messages
+// COPY reports row errors for the whole batch, so this names the range to search gateway logs for
+func batchRequestIDs(events []UsageEvent) string {
+	switch len(events) {
+	case 0:
+		return "no events"
+	case 1:
+		return "request_id=" + events[0].RequestID
+	default:
+		return fmt.Sprintf("%d events, request_id=%s..%s", len(events), events[0].RequestID, events[len(events)-1].RequestID)
+	}
+}
+
+// LoadCommittedOffsets returns the highest offset written per partition for a topic
+func (w *Writer) LoadCommittedOffsets(topic string) (map[int32]int64, error) {
+	rows, err := w.db.Query(`SELECT partition_id, last_offset FROM kafka_consumer_offsets WHERE topic = $1`, topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query committed offsets: %w", err)
+	}
+	defer rows.Close()
+
+	offsets := make(map[int32]int64)
+	for rows.Next() {
+		var partition int32
+		var offset int64
+		if err := rows.Scan(&partition, &offset); err != nil {
+			return nil, fmt.Errorf("failed to scan committed offset: %w", err)
+		}
+		offsets[partition] = offset
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating committed offsets: %w", err)
+	}
+
+	return offsets, nil
+}
+
 // WriteOne writes a single event (convenience method)
 func (w *Writer) WriteOne(event UsageEvent) error {
 	return w.WriteBatch([]UsageEvent{event})
@@ -142,6 +349,11 @@ func (w *Writer) GetStats() (written, duplicates int64) {
 	return w.writeCount, w.duplicateCount
 }
 
+// LastBatch returns the stats of the last successful write, for throughput metrics
+func (w *Writer) LastBatch() BatchStats {
+	return w.lastBatch
+}
+
 // ResetStats resets write statistics
 func (w *Writer) ResetStats() {
 	w.writeCount = 0
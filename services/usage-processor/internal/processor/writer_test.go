@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func TestBatchRequestIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []UsageEvent
+		expected string
+	}{
+		{"Empty", nil, "no events"},
+		{"One event", []UsageEvent{{RequestID: "req-1"}}, "request_id=req-1"},
+		{"Batch", []UsageEvent{{RequestID: "req-1"}, {RequestID: "req-2"}, {RequestID: "req-3"}}, "3 events, request_id=req-1..req-3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := batchRequestIDs(tt.events); got != tt.expected {
+				t.Errorf("batchRequestIDs() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildInsertQuery(t *testing.T) {
+	query := buildInsertQuery(2)
+
+	if !strings.HasPrefix(query, "INSERT INTO usage_events (time, request_id, organization_id, api_key_id, endpoint, method, status_code, response_time_ms, billable, weight, cached, region, bytes_in, bytes_out) VALUES ") {
+		t.Errorf("Unexpected column list: %s", query)
+	}
+	if !strings.Contains(query, "($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14), ($15, ") {
+		t.Errorf("Placeholders not numbered per row: %s", query)
+	}
+	if !strings.HasSuffix(query, "$28) ON CONFLICT (request_id) DO NOTHING") {
+		t.Errorf("Query does not skip duplicates: %s", query)
+	}
+	if insertChunkRows*len(usageEventColumns) > 65535 {
+		t.Errorf("insertChunkRows=%d exceeds the bind parameter limit", insertChunkRows)
+	}
+}
+
+func TestEventValues(t *testing.T) {
+	event := UsageEvent{RequestID: "req-1", Weight: 5, Cached: true, Billable: true, BytesIn: 300, BytesOut: 2048}
+	values := eventValues(event)
+
+	if len(values) != len(usageEventColumns) {
+		t.Fatalf("Got %d values for %d columns", len(values), len(usageEventColumns))
+	}
+	byColumn := make(map[string]interface{})
+	for i, column := range usageEventColumns {
+		byColumn[column] = values[i]
+	}
+	if byColumn["request_id"] != "req-1" || byColumn["weight"] != 5 || byColumn["cached"] != true || byColumn["billable"] != true {
+		t.Errorf("Values = %v", byColumn)
+	}
+	if byColumn["bytes_in"] != int64(300) || byColumn["bytes_out"] != int64(2048) {
+		t.Errorf("bytes_in/bytes_out = %v/%v, want 300/2048", byColumn["bytes_in"], byColumn["bytes_out"])
+	}
+}
+
+func TestEventValues_Region(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   string
+		expected string
+	}{
+		{"Region from gateway", "DE", "DE"},
+		{"Gateway reported unknown", "unknown", "unknown"},
+		{"Older gateway without region", "", "unknown"},
+	}
+
+	regionColumn := -1
+	for i, column := range usageEventColumns {
+		if column == "region" {
+			regionColumn = i
+		}
+	}
+	if regionColumn < 0 {
+		t.Fatalf("usageEventColumns has no region column: %v", usageEventColumns)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := eventValues(UsageEvent{RequestID: "req-1", Region: tt.region})
+			if got := values[regionColumn]; got != tt.expected {
+				t.Errorf("region = %v, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// benchmarkDB connects to USAGE_PROCESSOR_TEST_DATABASE_URL, a database with the usage_events migrations applied
+func benchmarkDB(b *testing.B) *sql.DB {
+	url := os.Getenv("USAGE_PROCESSOR_TEST_DATABASE_URL")
+	if url == "" {
+		b.Skip("Requires TimescaleDB - set USAGE_PROCESSOR_TEST_DATABASE_URL to run")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		b.Fatalf("sql.Open() error = %v", err)
+	}
+	b.Cleanup(func() {
+		db.Exec(`DELETE FROM usage_events WHERE request_id LIKE 'bench-%'`)
+		db.Close()
+	})
+	return db
+}
+
+// benchmarkEvents builds n events with request IDs unique to this run
+func benchmarkEvents(run string, n int) []UsageEvent {
+	events := make([]UsageEvent, n)
+	now := time.Now()
+	for i := range events {
+		events[i] = UsageEvent{
+			Time:           now,
+			RequestID:      fmt.Sprintf("bench-%s-%d", run, i),
+			OrganizationID: "00000000-0000-0000-0000-000000000001",
+			APIKeyID:       "00000000-0000-0000-0000-000000000002",
+			Endpoint:       "/api/bench",
+			Method:         "GET",
+			StatusCode:     200,
+			ResponseTimeMs: 12,
+			Billable:       true,
+			Weight:         1,
+			Region:         "US",
+		}
+	}
+	return events
+}
+
+func benchmarkWriteBatch(b *testing.B, name string, copyThreshold int) {
+	writer := NewWriter(benchmarkDB(b), 10000, copyThreshold)
+	const rows = 10000
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		events := benchmarkEvents(fmt.Sprintf("%s-%d-%d", name, time.Now().UnixNano(), i), rows)
+		b.StartTimer()
+
+		if err := writer.WriteBatch(events); err != nil {
+			b.Fatalf("WriteBatch() error = %v", err)
+		}
+	}
+	b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+// go test -bench WriteBatch10k -benchtime 5x ./internal/processor
+func BenchmarkWriteBatch10k_Copy(b *testing.B) {
+	benchmarkWriteBatch(b, "copy", 1)
+}
+
+func BenchmarkWriteBatch10k_Insert(b *testing.B) {
+	benchmarkWriteBatch(b, "insert", 10001)
+}
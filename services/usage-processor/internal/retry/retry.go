@@ -0,0 +1,130 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy configures retries with exponential backoff
+type Policy struct {
+	MaxAttempts  int              // Total attempts including the first (default 3)
+	InitialDelay time.Duration    // Delay before the first retry (default 100ms)
+	MaxDelay     time.Duration    // Cap on a single delay (0 = no cap)
+	Multiplier   float64          // Delay growth per retry (default 2)
+	Jitter       float64          // Random +/- fraction of each delay, 0-1 (e.g., 0.2 = ±20%)
+	Retryable    func(error) bool // Reports whether an error is worth retrying (nil = all errors)
+}
+
+// Default policy values
+const (
+	DefaultMaxAttempts  = 3
+	DefaultInitialDelay = 100 * time.Millisecond
+	DefaultMultiplier   = 2.0
+)
+
+// withDefaults fills in unset fields
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultInitialDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultMultiplier
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	} else if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	return p
+}
+
+// Delay returns the backoff before retry n (1 = first retry), including jitter
+func (p Policy) Delay(n int) time.Duration {
+	p = p.withDefaults()
+	if n < 1 {
+		n = 1
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(n-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, runs out of
+// attempts, or ctx is cancelled
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	p = p.withDefaults()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+
+		if p.Retryable != nil && !p.Retryable(err) {
+			return err
+		}
+
+		if attempt >= p.MaxAttempts {
+			return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+
+		if sleepErr := Sleep(ctx, p.Delay(attempt)); sleepErr != nil {
+			return fmt.Errorf("retry cancelled after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// Sleep waits for d or until ctx is done, returning ctx.Err() if cancelled
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Backoff tracks consecutive failures for loops that retry in place,
+// such as a consumer poll loop
+type Backoff struct {
+	policy   Policy
+	failures int
+}
+
+// NewBackoff creates a backoff that grows with each Next and restarts on Reset
+func NewBackoff(p Policy) *Backoff {
+	return &Backoff{policy: p}
+}
+
+// Next records a failure and returns how long to wait before trying again
+func (b *Backoff) Next() time.Duration {
+	b.failures++
+	return b.policy.Delay(b.failures)
+}
+
+// Reset clears the failure count after a success
+func (b *Backoff) Reset() {
+	b.failures = 0
+}
+
+// Failures returns the number of consecutive failures
+func (b *Backoff) Failures() int {
+	return b.failures
+}
@@ -0,0 +1,126 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("connection reset")
+
+// fastPolicy keeps test delays short
+var fastPolicy = Policy{MaxAttempts: 5, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestDo_SucceedsAfterFailures(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), fastPolicy, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Calls = %d, want 3", calls)
+	}
+}
+
+func TestDo_Exhausted(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), fastPolicy, func(ctx context.Context) error {
+		calls++
+		return errTransient
+	})
+
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("Do() error = %v, want wrapped %v", err, errTransient)
+	}
+	if calls != fastPolicy.MaxAttempts {
+		t.Errorf("Calls = %d, want %d", calls, fastPolicy.MaxAttempts)
+	}
+}
+
+func TestDo_NonRetryableShortCircuits(t *testing.T) {
+	errInvalid := errors.New("invalid payload")
+	policy := fastPolicy
+	policy.Retryable = func(err error) bool { return !errors.Is(err, errInvalid) }
+
+	calls := 0
+	err := Do(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return errInvalid
+	})
+
+	if err != errInvalid {
+		t.Fatalf("Do() error = %v, want %v unwrapped", err, errInvalid)
+	}
+	if calls != 1 {
+		t.Errorf("Calls = %d, want 1", calls)
+	}
+}
+
+func TestDo_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{MaxAttempts: 10, InitialDelay: time.Hour}
+
+	calls := 0
+	err := Do(ctx, policy, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return errTransient
+	})
+
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("Do() error = %v, want wrapped %v", err, errTransient)
+	}
+	if calls != 1 {
+		t.Errorf("Calls = %d, want 1", calls)
+	}
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	policy := Policy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+
+	tests := []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second}, // Capped
+	}
+
+	for _, tt := range tests {
+		if got := policy.Delay(tt.retry); got != tt.expected {
+			t.Errorf("Delay(%d) = %v, want %v", tt.retry, got, tt.expected)
+		}
+	}
+
+	// Jitter stays within the configured fraction
+	policy.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := policy.Delay(1); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("Delay(1) with 20%% jitter = %v, want 80ms-120ms", got)
+		}
+	}
+}
+
+func TestBackoff_Reset(t *testing.T) {
+	backoff := NewBackoff(Policy{InitialDelay: 10 * time.Millisecond})
+
+	backoff.Next()
+	if got := backoff.Next(); got != 20*time.Millisecond {
+		t.Errorf("Second Next() = %v, want 20ms", got)
+	}
+
+	backoff.Reset()
+	if got := backoff.Next(); got != 10*time.Millisecond {
+		t.Errorf("Next() after Reset = %v, want 10ms", got)
+	}
+}
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +19,7 @@ var (
 	createEnv      string
 	createExpires  string
 	createCreatedBy string
+	createCIDRs     []string
 )
 
 var createCmd = &cobra.Command{
@@ -29,7 +32,8 @@ The key will only be displayed once - save it securely!
 Examples:
   keygen create --org-id=<uuid> --name="Production API"
   keygen create --org-id=<uuid> --name="Staging" --env=test
-  keygen create --org-id=<uuid> --name="Partner API" --expires="2027-12-31"`,
+  keygen create --org-id=<uuid> --name="Partner API" --expires="2027-12-31"
+  keygen create --org-id=<uuid> --name="Office only" --allowed-cidrs=203.0.113.0/24,2001:db8::/32`,
 	RunE: runCreate,
 }
 
@@ -41,6 +45,7 @@ func init() {
 	createCmd.Flags().StringVar(&createEnv, "env", "test", "Environment: test or live")
 	createCmd.Flags().StringVar(&createExpires, "expires", "", "Expiration date (YYYY-MM-DD), optional")
 	createCmd.Flags().StringVar(&createCreatedBy, "created-by", "cli", "Email of creator")
+	createCmd.Flags().StringSliceVar(&createCIDRs, "allowed-cidrs", nil, "Source IP ranges the key may be used from (IPv4/IPv6 CIDRs), optional")
 
 	createCmd.MarkFlagRequired("org-id")
 	createCmd.MarkFlagRequired("name")
@@ -72,6 +77,16 @@ func runCreate(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("invalid expiration date format (use YYYY-MM-DD): %w", err)
 		}
 		expiresAt = &parsed
+
+		if warning := expiryWarning(parsed, time.Now()); warning != "" {
+			fmt.Println(warning)
+		}
+	}
+
+	// Validate IP allowlist
+	allowedCIDRs, err := normalizeCIDRs(createCIDRs)
+	if err != nil {
+		return err
 	}
 
 	// Connect to database
@@ -105,6 +120,7 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		KeyPrefix:      prefix,
 		Name:           createName,
 		Scopes:         []string{"read", "write"}, // Default scopes
+		AllowedCIDRs:   allowedCIDRs,
 		IsActive:       true,
 		ExpiresAt:      expiresAt,
 		CreatedAt:      time.Now(),
@@ -122,6 +138,35 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// expiryWarning returns a warning when a key would be created already expired, or ""
+// Dates parse as midnight UTC, so a key expiring "today" is already expired
+func expiryWarning(expiresAt, now time.Time) string {
+	if expiresAt.After(now) {
+		return ""
+	}
+	return fmt.Sprintf("⚠️  Warning: Expiration date %s is in the past - the gateway will reject this key immediately",
+		expiresAt.Format("2006-01-02"))
+}
+
+// normalizeCIDRs validates IP allowlist entries, turning single addresses into /32 or /128
+// and clearing host bits (203.0.113.7/24 -> 203.0.113.0/24)
+func normalizeCIDRs(values []string) ([]string, error) {
+	cidrs := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid allowed CIDR (expected e.g. 203.0.113.0/24 or 2001:db8::/32): %s", value)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		cidrs = append(cidrs, prefix.Masked().String())
+	}
+	return cidrs, nil
+}
+
 func printSuccess(plaintext string, key *database.APIKey, org *database.Organization) {
 	fmt.Println()
 	fmt.Println("═══════════════════════════════════════════════════════════════")
@@ -140,6 +185,10 @@ func printSuccess(plaintext string, key *database.APIKey, org *database.Organiza
 		fmt.Printf("  Expires:      %s\n", key.ExpiresAt.Format("2006-01-02"))
 		fmt.Println()
 	}
+	if len(key.AllowedCIDRs) > 0 {
+		fmt.Printf("  Allowed IPs:  %s\n", strings.Join(key.AllowedCIDRs, ", "))
+		fmt.Println()
+	}
 	fmt.Println("═══════════════════════════════════════════════════════════════")
 	fmt.Println()
 	fmt.Println("⚠️  IMPORTANT: Save this key securely - it won't be shown again!")
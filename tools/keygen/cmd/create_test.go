@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiryWarning(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	date := func(value string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", value)
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		expires  time.Time
+		expected bool
+	}{
+		{"Future date", date("2026-06-02"), false},
+		{"Today has already started", date("2026-06-01"), true},
+		{"Past date", date("2025-12-31"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiryWarning(tt.expires, now) != ""; got != tt.expected {
+				t.Errorf("expiryWarning(%s) warned = %v, want %v", tt.expires.Format("2006-01-02"), got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeCIDRs(t *testing.T) {
+	cidrs, err := normalizeCIDRs([]string{"203.0.113.0/24", " 198.51.100.7 ", "2001:db8::/32", "10.1.2.3/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("normalizeCIDRs() error = %v", err)
+	}
+
+	expected := []string{"203.0.113.0/24", "198.51.100.7/32", "2001:db8::/32", "10.0.0.0/8", "2001:db8::1/128"}
+	if len(cidrs) != len(expected) {
+		t.Fatalf("Got %v, want %v", cidrs, expected)
+	}
+	for i := range expected {
+		if cidrs[i] != expected[i] {
+			t.Errorf("CIDR %d = %s, want %s", i, cidrs[i], expected[i])
+		}
+	}
+
+	for _, value := range []string{"203.0.113.0/33", "office", ""} {
+		if _, err := normalizeCIDRs([]string{value}); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saas-gateway/keygen/internal/database"
+	"github.com/spf13/cobra"
+)
+
+// defaultRetentionDays is how long revoked keys are kept before purging
+const defaultRetentionDays = 90
+
+var (
+	purgeRetentionDays int
+	purgeDryRun        bool
+	purgeYes           bool
+	purgeDeletedBy     string
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently delete revoked API keys past retention",
+	Long: `Hard-delete API keys that were revoked longer ago than the retention period.
+
+Each deleted key is recorded in the api_key_deletions audit table (prefix,
+name, organization, revocation details) before it is removed. Active keys
+are never purged, and the keys' usage events are kept for billing history.
+
+Examples:
+  keygen purge --dry-run
+  keygen purge --retention-days=180
+  keygen purge --retention-days=90 --yes   # Non-interactive (cron)`,
+	RunE: runPurge,
+}
+
+func init() {
+	rootCmd.AddCommand(purgeCmd)
+
+	purgeCmd.Flags().IntVar(&purgeRetentionDays, "retention-days", defaultRetentionDays, "Days to keep revoked keys before purging")
+	purgeCmd.Flags().BoolVar(&purgeDryRun, "dry-run", false, "List keys that would be purged without deleting them")
+	purgeCmd.Flags().BoolVar(&purgeYes, "yes", false, "Skip confirmation prompt")
+	purgeCmd.Flags().StringVar(&purgeDeletedBy, "deleted-by", "cli", "Recorded in the audit log as the deleting user")
+}
+
+func runPurge(cmd *cobra.Command, args []string) error {
+	if purgeRetentionDays < 1 {
+		return fmt.Errorf("retention-days must be at least 1")
+	}
+
+	cutoff := purgeCutoff(time.Now(), purgeRetentionDays)
+
+	// Connect to database
+	db, err := database.Connect(getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("database connection failed: %w", err)
+	}
+	defer db.Close()
+
+	// Find keys past retention
+	keys, err := db.ListPurgeableAPIKeys(cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to list purgeable keys: %w", err)
+	}
+
+	fmt.Println()
+	if len(keys) == 0 {
+		fmt.Printf("✅ No revoked keys older than %d days\n", purgeRetentionDays)
+		fmt.Println()
+		return nil
+	}
+
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+	fmt.Printf("⚠️  %d Revoked Key(s) Past %d-Day Retention\n", len(keys), purgeRetentionDays)
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+	for _, key := range keys {
+		fmt.Printf("  %s  %-30s  revoked %s\n", key.KeyPrefix, key.Name, key.RevokedAt.Format("2006-01-02"))
+	}
+	fmt.Println()
+
+	if purgeDryRun {
+		fmt.Println("Dry run - no keys were deleted")
+		fmt.Println()
+		return nil
+	}
+
+	if !purgeYes {
+		fmt.Println("This action cannot be undone!")
+		fmt.Print("Continue? (yes/no): ")
+
+		var response string
+		fmt.Scanln(&response)
+
+		if response != "yes" && response != "y" {
+			fmt.Println("❌ Purge cancelled")
+			return nil
+		}
+	}
+
+	// Purge with audit record (same cutoff, so keys revoked since listing are not included)
+	purged, err := db.PurgeRevokedAPIKeys(cutoff, purgeDeletedBy)
+	if err != nil {
+		return fmt.Errorf("failed to purge keys: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Printf("✅ Purged %d revoked API key(s)\n", purged)
+	fmt.Println("   Deletions recorded in api_key_deletions")
+	fmt.Println()
+
+	return nil
+}
+
+// purgeCutoff returns the revocation time before which keys are purged
+func purgeCutoff(now time.Time, retentionDays int) time.Time {
+	return now.AddDate(0, 0, -retentionDays)
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeCutoff(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	if got := purgeCutoff(now, 90); !got.Equal(expected) {
+		t.Errorf("purgeCutoff() = %v, want %v", got, expected)
+	}
+}
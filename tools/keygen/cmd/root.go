@@ -17,6 +17,7 @@ Features:
   • List all keys for an organization
   • Revoke compromised or unused keys
   • Rotate keys with configurable overlap periods
+  • Purge revoked keys past a retention period
 
 All keys are stored as SHA-256 hashes in PostgreSQL. Plaintext keys are only
 shown once during creation and cannot be recovered later.
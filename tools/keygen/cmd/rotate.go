@@ -90,6 +90,7 @@ func runRotate(cmd *cobra.Command, args []string) error {
 		KeyPrefix:      prefix,
 		Name:           fmt.Sprintf("%s (rotated)", oldKey.Name),
 		Scopes:         oldKey.Scopes,
+		AllowedCIDRs:   oldKey.AllowedCIDRs,
 		IsActive:       true,
 		ExpiresAt:      oldKey.ExpiresAt,
 		CreatedAt:      time.Now(),
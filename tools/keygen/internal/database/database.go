@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // DB wraps the database connection
@@ -32,6 +32,7 @@ type APIKey struct {
 	KeyPrefix      string
 	Name           string
 	Scopes         []string
+	AllowedCIDRs   []string // Source IP ranges the key may be used from (empty = any)
 	IsActive       bool
 	LastUsedAt     *time.Time
 	ExpiresAt      *time.Time
@@ -99,8 +100,9 @@ func (db *DB) CreateAPIKey(key *APIKey) error {
 	query := `
 		INSERT INTO api_keys (
 			id, organization_id, key_hash, key_prefix, name,
-			scopes, is_active, expires_at, created_at, created_by
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			scopes, is_active, expires_at, created_at, created_by,
+			allowed_cidrs
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, '{}'::TEXT[]))
 	`
 
 	_, err := db.conn.Exec(
@@ -115,6 +117,7 @@ func (db *DB) CreateAPIKey(key *APIKey) error {
 		key.ExpiresAt,
 		key.CreatedAt,
 		key.CreatedBy,
+		pq.Array(key.AllowedCIDRs),
 	)
 
 	if err != nil {
@@ -130,7 +133,8 @@ func (db *DB) GetAPIKey(keyID uuid.UUID) (*APIKey, error) {
 		SELECT
 			id, organization_id, key_hash, key_prefix, name,
 			scopes, is_active, last_used_at, expires_at,
-			revoked_at, revoked_reason, created_at, created_by
+			revoked_at, revoked_reason, created_at, created_by,
+			allowed_cidrs
 		FROM api_keys
 		WHERE id = $1
 	`
@@ -152,6 +156,7 @@ func (db *DB) GetAPIKey(keyID uuid.UUID) (*APIKey, error) {
 		&key.RevokedReason,
 		&key.CreatedAt,
 		&key.CreatedBy,
+		pq.Array(&key.AllowedCIDRs),
 	)
 
 	if err == sql.ErrNoRows {
@@ -287,6 +292,94 @@ func (db *DB) RevokeAPIKey(keyID uuid.UUID, reason string) error {
 	return nil
 }
 
+// ListPurgeableAPIKeys retrieves keys revoked before the cutoff (candidates for purging)
+func (db *DB) ListPurgeableAPIKeys(cutoff time.Time) ([]*APIKey, error) {
+	query := `
+		SELECT
+			id, organization_id, key_hash, key_prefix, COALESCE(name, ''),
+			COALESCE(scopes, ARRAY[]::TEXT[]), is_active, last_used_at, expires_at,
+			revoked_at, revoked_reason, created_at, COALESCE(created_by, '')
+		FROM api_keys
+		WHERE is_active = false
+		  AND revoked_at IS NOT NULL
+		  AND revoked_at < $1
+		ORDER BY revoked_at
+	`
+
+	rows, err := db.conn.Query(query, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purgeable API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key := &APIKey{}
+		var scopes []string
+
+		err := rows.Scan(
+			&key.ID,
+			&key.OrganizationID,
+			&key.KeyHash,
+			&key.KeyPrefix,
+			&key.Name,
+			pq.Array(&scopes),
+			&key.IsActive,
+			&key.LastUsedAt,
+			&key.ExpiresAt,
+			&key.RevokedAt,
+			&key.RevokedReason,
+			&key.CreatedAt,
+			&key.CreatedBy,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+
+		key.Scopes = scopes
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// PurgeRevokedAPIKeys hard-deletes keys revoked before the cutoff
+// Each deleted key is recorded in api_key_deletions in the same statement. Usage events keep
+// the deleted key's ID (migration 047 dropped their cascading foreign key)
+func (db *DB) PurgeRevokedAPIKeys(cutoff time.Time, deletedBy string) (int64, error) {
+	query := `
+		WITH purged AS (
+			DELETE FROM api_keys
+			WHERE is_active = false
+			  AND revoked_at IS NOT NULL
+			  AND revoked_at < $1
+			RETURNING id, organization_id, key_prefix, name, revoked_at, revoked_reason, created_at
+		)
+		INSERT INTO api_key_deletions (
+			api_key_id, organization_id, key_prefix, name,
+			revoked_at, revoked_reason, key_created_at, deleted_by
+		)
+		SELECT id, organization_id, key_prefix, name, revoked_at, revoked_reason, created_at, $2
+		FROM purged
+	`
+
+	result, err := db.conn.Exec(query, cutoff, deletedBy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge revoked API keys: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
 // CountActiveKeys returns the number of active API keys for an organization
 func (db *DB) CountActiveKeys(orgID uuid.UUID) (int, error) {
 	query := `
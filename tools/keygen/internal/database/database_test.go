@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+)
+
+// openTestDB connects to KEYGEN_TEST_DATABASE_URL, a database with the migrations applied
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("KEYGEN_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("KEYGEN_TEST_DATABASE_URL not set")
+	}
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetMaxOpenConns(1) // Temporary tables only exist on the connection that created them
+	return conn
+}
+
+// TestPurgeRevokedAPIKeys_Postgres tests the retention query against a real database
+// Temporary tables shadow api_keys and api_key_deletions, so no migrated schema or data is touched
+func TestPurgeRevokedAPIKeys_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+	db := &DB{conn: conn}
+
+	setup := []string{
+		`CREATE TEMP TABLE api_keys (
+			id UUID PRIMARY KEY, organization_id UUID NOT NULL, key_hash VARCHAR(64) NOT NULL,
+			key_prefix VARCHAR(12) NOT NULL, name VARCHAR(100), scopes TEXT[], is_active BOOLEAN,
+			last_used_at TIMESTAMPTZ, expires_at TIMESTAMPTZ, revoked_at TIMESTAMPTZ, revoked_reason TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), created_by VARCHAR(255))`,
+		`CREATE TEMP TABLE api_key_deletions (
+			id BIGSERIAL PRIMARY KEY, api_key_id UUID NOT NULL, organization_id UUID NOT NULL,
+			key_prefix VARCHAR(12) NOT NULL, name VARCHAR(100), revoked_at TIMESTAMPTZ NOT NULL,
+			revoked_reason TEXT, key_created_at TIMESTAMPTZ NOT NULL,
+			deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), deleted_by VARCHAR(255) NOT NULL)`,
+		`INSERT INTO api_keys (id, organization_id, key_hash, key_prefix, name, scopes, is_active, revoked_at, revoked_reason, created_by) VALUES
+			('00000000-0000-0000-0000-00000000000a', '00000000-0000-0000-0000-000000000001', 'a', 'sk_test_old', NULL, NULL, false, NOW() - INTERVAL '120 days', 'leaked', NULL),
+			('00000000-0000-0000-0000-00000000000b', '00000000-0000-0000-0000-000000000001', 'b', 'sk_test_new', 'Recent', '{read}', false, NOW() - INTERVAL '30 days', NULL, 'cli'),
+			('00000000-0000-0000-0000-00000000000c', '00000000-0000-0000-0000-000000000001', 'c', 'sk_test_live', 'Active', '{read}', true, NULL, NULL, 'cli')`,
+	}
+	for _, stmt := range setup {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("Setup %q error = %v", stmt, err)
+		}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -90)
+
+	// The listing the CLI shows must be exactly what the purge deletes
+	keys, err := db.ListPurgeableAPIKeys(cutoff)
+	if err != nil {
+		t.Fatalf("ListPurgeableAPIKeys() error = %v", err)
+	}
+	if len(keys) != 1 || keys[0].KeyPrefix != "sk_test_old" {
+		t.Fatalf("Purgeable keys = %+v, want only sk_test_old", keys)
+	}
+
+	purged, err := db.PurgeRevokedAPIKeys(cutoff, "cron")
+	if err != nil {
+		t.Fatalf("PurgeRevokedAPIKeys() error = %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Purged = %d, want 1", purged)
+	}
+
+	var remaining int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM api_keys`).Scan(&remaining); err != nil {
+		t.Fatalf("Count api_keys error = %v", err)
+	}
+	if remaining != 2 {
+		t.Errorf("Remaining keys = %d, want 2 (recently revoked and active)", remaining)
+	}
+
+	var prefix, reason, deletedBy string
+	err = conn.QueryRow(`SELECT key_prefix, revoked_reason, deleted_by FROM api_key_deletions WHERE api_key_id = '00000000-0000-0000-0000-00000000000a'`).
+		Scan(&prefix, &reason, &deletedBy)
+	if err != nil {
+		t.Fatalf("Audit record error = %v", err)
+	}
+	if prefix != "sk_test_old" || reason != "leaked" || deletedBy != "cron" {
+		t.Errorf("Audit record = %s, %s, %s, want sk_test_old, leaked, cron", prefix, reason, deletedBy)
+	}
+}
+
+// TestPurgeKeepsHistory_Postgres checks the migrated schema: deleting a key must not cascade to
+// its usage events, and deleting an organization must not cascade to the purge audit
+func TestPurgeKeepsHistory_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+
+	query := `
+		SELECT conrelid::regclass::text
+		FROM pg_constraint
+		WHERE contype = 'f'
+		  AND confdeltype = 'c'
+		  AND ((conrelid = 'usage_events'::regclass AND confrelid = 'api_keys'::regclass)
+		    OR (conrelid = 'api_key_deletions'::regclass AND confrelid = 'organizations'::regclass))
+	`
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatalf("Constraint query error = %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatalf("Scan error = %v", err)
+		}
+		t.Errorf("%s still has an ON DELETE CASCADE foreign key (migration 047 not applied?)", table)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Constraint rows error = %v", err)
+	}
+}
@@ -93,8 +93,13 @@ class APIClient {
   }
 
   // API Key endpoints
-  async listAPIKeys(): Promise<{ api_keys: APIKey[]; count: number }> {
-    const { data } = await this.client.get('/api/v1/apikeys');
+  async listAPIKeys(
+    page: number = 1,
+    pageSize: number = 100
+  ): Promise<{ api_keys: APIKey[]; total_count: number; page: number; page_size: number }> {
+    const { data } = await this.client.get('/api/v1/apikeys', {
+      params: { page, page_size: pageSize },
+    });
     return data;
   }
 
@@ -59,6 +59,7 @@ export interface APIKey {
   created_at: string;
   expires_at?: string;
   revoked_at?: string;
+  days_until_expiry?: number; // Whole days left; absent without a future expiry
   status: 'active' | 'revoked' | 'expired';
   created_by: string;
 }